@@ -1,89 +1,474 @@
 package main
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"io/fs"
-	"log"
+	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	_ "github.com/lib/pq"
+	"github.com/luisteixeira/waypoint/backend/internal/alert"
+	"github.com/luisteixeira/waypoint/backend/internal/config"
+	"github.com/luisteixeira/waypoint/backend/internal/domain"
 	"github.com/luisteixeira/waypoint/backend/internal/handler"
+	"github.com/luisteixeira/waypoint/backend/internal/health"
+	"github.com/luisteixeira/waypoint/backend/internal/idempotency"
+	"github.com/luisteixeira/waypoint/backend/internal/logging"
+	"github.com/luisteixeira/waypoint/backend/internal/metrics"
 	wmiddleware "github.com/luisteixeira/waypoint/backend/internal/middleware"
+	"github.com/luisteixeira/waypoint/backend/internal/mqtt"
+	"github.com/luisteixeira/waypoint/backend/internal/openapi"
+	"github.com/luisteixeira/waypoint/backend/internal/outbox"
+	"github.com/luisteixeira/waypoint/backend/internal/push"
+	"github.com/luisteixeira/waypoint/backend/internal/repository"
+	"github.com/luisteixeira/waypoint/backend/internal/repository/memory"
 	"github.com/luisteixeira/waypoint/backend/internal/repository/postgres"
+	"github.com/luisteixeira/waypoint/backend/internal/repository/sqlite"
 	"github.com/luisteixeira/waypoint/backend/internal/service"
+	"github.com/luisteixeira/waypoint/backend/internal/tracing"
 	"github.com/luisteixeira/waypoint/backend/internal/ui"
+	"github.com/luisteixeira/waypoint/backend/internal/webhook"
+	"github.com/luisteixeira/waypoint/backend/internal/worker"
 )
 
 func main() {
-	db := initDB()
-	defer db.Close()
+	// Generating keys needs no configuration, which may not be valid yet.
+	if len(os.Args) > 1 && os.Args[1] == "vapid-keys" {
+		runVAPIDKeys()
+		return
+	}
+
+	cfg, args, err := config.Load(os.Args[1:])
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+	slog.SetDefault(logging.New(cfg.Logging, os.Stderr))
+
+	if len(args) > 0 && args[0] == "migrate" {
+		runMigrate(cfg, args[1:])
+		return
+	}
 
-	activityRepo := postgres.NewPostgresActivityRepo(db)
-	defRepo := postgres.NewPostgresDefinitionRepo(db)
+	flushSpans, err := tracing.Setup(context.Background(), cfg.Tracing)
+	if err != nil {
+		fatal("Could not set up tracing", err)
+	}
+
+	store := initStorage(cfg)
+
+	workers := worker.NewGroup()
+	probes := health.New(readinessTimeout)
+	for name, check := range store.checks {
+		probes.Register(name, check)
+	}
+	probes.Register("workers", workers.Check)
 
-	activityService := service.NewActivityService(activityRepo, defRepo)
+	workers.Housekeeping("idempotency-cleanup", cfg.Idempotency.CleanupInterval, func(ctx context.Context) error {
+		deleted, err := store.idempotency.DeleteExpired(ctx, time.Now())
+		if deleted > 0 {
+			slog.DebugContext(ctx, "deleted expired idempotency keys", "count", deleted)
+		}
+		return err
+	})
+
+	workers.Housekeeping("outbox-cleanup", cfg.Outbox.CleanupInterval, func(ctx context.Context) error {
+		deleted, err := store.outbox.DeletePublished(ctx, time.Now().Add(-cfg.Outbox.Retention))
+		if deleted > 0 {
+			slog.DebugContext(ctx, "deleted published outbox events", "count", deleted)
+		}
+		return err
+	})
+
+	dispatcher := webhook.NewDispatcher(store.webhooks, webhook.Policy{
+		Timeout:        cfg.Webhooks.Timeout,
+		MaxAttempts:    cfg.Webhooks.MaxAttempts,
+		InitialBackoff: cfg.Webhooks.InitialBackoff,
+		MaxBackoff:     cfg.Webhooks.MaxBackoff,
+	}, time.Now)
+	workers.Every("webhook-dispatch", cfg.Webhooks.DispatchInterval, func(ctx context.Context) error {
+		delivered, err := dispatcher.Dispatch(ctx)
+		if delivered > 0 {
+			slog.DebugContext(ctx, "delivered webhooks", "count", delivered)
+		}
+		return err
+	})
+
+	telemetry := metrics.New()
+	if store.db != nil {
+		telemetry.RegisterDB(cfg.Storage.Backend, store.db)
+	}
+	activities := outbox.NewActivityRepo(metrics.NewActivityRepo(store.activities, telemetry), store.outbox, store.tx, time.Now)
+	definitions := metrics.NewDefinitionRepo(store.definitions, telemetry)
+
+	familyID, entityID := cfg.Demo.IDs()
+	coreService := service.NewActivityService(activities, definitions, store.tx, service.ClientTimeWindow{
+		MaxAge:   cfg.Sync.MaxClientAge,
+		MaxAhead: cfg.Sync.MaxClientAhead,
+	})
+	activityService := tracing.NewActivityService(metrics.NewActivityService(coreService, telemetry))
 	activityHandler := handler.NewActivityHandler(activityService)
-	uiHandler := handler.NewUIHandler(activityService)
+
+	var pushKeys *push.Keys
+	var pushClient *push.Client
+	if cfg.Push.Enabled() {
+		pushKeys, pushClient = initPush(cfg.Push)
+	}
+
+	sinks := []outbox.Sink{webhook.NewSink(store.webhooks)}
+	closeMQTT := func() {}
+	if cfg.MQTT.Enabled() {
+		var bridge *mqtt.Bridge
+		bridge, closeMQTT = initMQTT(cfg.MQTT, activityService, store)
+		sinks = append(sinks, bridge)
+	}
+	if pushClient != nil {
+		sinks = append(sinks, push.NewSink(store.push, pushClient, definitions))
+	}
+	publisher := outbox.NewDispatcher(store.outbox, outbox.Policy{
+		InitialBackoff: cfg.Outbox.InitialBackoff,
+		MaxBackoff:     cfg.Outbox.MaxBackoff,
+	}, time.Now, sinks...)
+	workers.Every("outbox-dispatch", cfg.Outbox.DispatchInterval, func(ctx context.Context) error {
+		published, err := publisher.Dispatch(ctx)
+		if published > 0 {
+			slog.DebugContext(ctx, "published outbox events", "count", published)
+		}
+		return err
+	})
+
+	notifiers := []alert.Notifier{webhook.NewAlertNotifier(store.webhooks, time.Now)}
+	if cfg.Alerts.EmailEnabled() {
+		notifiers = append(notifiers, alert.NewEmailNotifier(alert.SMTPOptions{
+			Addr:     cfg.Alerts.SMTPAddr,
+			From:     cfg.Alerts.SMTPFrom,
+			Username: cfg.Alerts.SMTPUsername,
+			Password: cfg.Alerts.SMTPPassword,
+		}))
+	}
+	if pushClient != nil {
+		notifiers = append(notifiers, push.NewAlertNotifier(store.push, pushClient))
+	}
+	evaluator := alert.NewEvaluator(store.alerts, activities, definitions, time.Now, notifiers...)
+	workers.Every("alert-evaluate", cfg.Alerts.EvaluateInterval, func(ctx context.Context) error {
+		raised, err := evaluator.Evaluate(ctx)
+		if raised > 0 {
+			slog.DebugContext(ctx, "raised alerts", "count", raised)
+		}
+		return err
+	})
+
+	batchHandler := handler.NewBatchHandler(service.NewBatchService(activityService, store.tx))
+	syncHandler := handler.NewSyncHandler(service.NewSyncService(store.changes, store.tx))
+	webhookHandler := handler.NewWebhookHandler(webhook.NewRegistry(store.webhooks))
+	alertHandler := handler.NewAlertHandler(alert.NewService(store.alerts, definitions, store.entities, time.Now, notifiers...))
+	var pushHandler *handler.PushHandler
+	if pushKeys != nil {
+		pushHandler = handler.NewPushHandler(push.NewService(store.push, pushKeys, time.Now))
+	}
+	uiHandler := handler.NewUIHandler(activityService, familyID, entityID)
+
+	apiSpec, err := openapi.Load()
+	if err != nil {
+		fatal("Could not load the API description", err)
+	}
 
 	router := chi.NewRouter()
 
-	router.Use(middleware.Logger)
+	router.Use(middleware.RequestID)
+	router.Use(tracing.Middleware)
+	router.Use(telemetry.Middleware)
+	router.Use(logging.Middleware(slog.Default()))
 	router.Use(middleware.Recoverer)
-	router.Use(middleware.Timeout(60 * time.Second))
+	router.Use(middleware.Timeout(cfg.Server.RequestTimeout))
 
 	staticFS, _ := fs.Sub(ui.Files, "static")
 	router.Handle("/static/*", http.StripPrefix("/static/", http.FileServer(http.FS(staticFS))))
 
 	router.Get("/", uiHandler.ShowDashboard)
+	router.Get("/sw.js", uiHandler.ServiceWorker)
 
-	router.Get("/health", func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte("Waypoint Backend: Active"))
-	})
+	router.Get("/livez", probes.Live)
+	router.Get("/readyz", probes.Ready)
+	router.Get("/health", probes.Ready)
+	router.Handle("/metrics", telemetry.Handler())
 
 	router.Route("/api/v1", func(r chi.Router) {
-		r.Use(wmiddleware.TenantMiddleware)
-		r.Route("/activities", func(r chi.Router) {
-			r.Post("/plan", activityHandler.PlanActivity)
-			r.Post("/start", activityHandler.StartActivity)
-			r.Get("/{id}/complete", activityHandler.CompleteActivity)
+		r.Get("/openapi.json", apiSpec.ServeJSON)
+		r.Get("/docs", openapi.DocsHandler("/api/v1/openapi.json"))
+
+		r.Group(func(r chi.Router) {
+			r.Use(wmiddleware.TenantMiddleware)
+			r.Use(apiSpec.Validate)
+			r.Use(idempotency.Middleware(store.idempotency, cfg.Idempotency.TTL))
+			activityHandler.Routes(r)
+			batchHandler.Routes(r)
+			syncHandler.Routes(r)
+			webhookHandler.Routes(r)
+			alertHandler.Routes(r)
+			if pushHandler != nil {
+				pushHandler.Routes(r)
+			}
 		})
 	})
 
-	port := ":8080"
-	log.Printf("Starting server on %s...", port)
-
 	server := &http.Server{
-		Addr:         port,
+		Addr:         cfg.Server.Addr,
 		Handler:      router,
-		ReadTimeout:  5 * time.Second,
-		WriteTimeout: 10 * time.Second,
+		ReadTimeout:  cfg.Server.ReadTimeout,
+		WriteTimeout: cfg.Server.WriteTimeout,
+		IdleTimeout:  cfg.Server.IdleTimeout,
 	}
 
-	if err := server.ListenAndServe(); err != nil {
-		log.Fatalf("Server failed: %v", err)
+	// A second signal skips draining and kills the process.
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- serve(server, cfg.Server.TLS)
+	}()
+
+	select {
+	case err = <-serveErr:
+		slog.Error("Server failed", "error", err)
+	case <-ctx.Done():
+		stop()
+		slog.Info("Shutting down")
 	}
+
+	shutdown(server, probes, workers, flushSpans, cfg.Server.ShutdownTimeout)
+	closeMQTT()
+	store.close()
+	if err != nil {
+		os.Exit(1)
+	}
+}
+
+// readinessTimeout bounds each readiness check, so a hung database answers
+// the probe with 503 rather than timing it out.
+const readinessTimeout = 2 * time.Second
+
+func serve(server *http.Server, tls config.TLSConfig) error {
+	var err error
+	if tls.Enabled() {
+		slog.Info("Starting server", "addr", server.Addr, "tls", true)
+		err = server.ListenAndServeTLS(tls.CertFile, tls.KeyFile)
+	} else {
+		slog.Info("Starting server", "addr", server.Addr, "tls", false)
+		err = server.ListenAndServe()
+	}
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
 }
 
-func initDB() *sql.DB {
-	connStr := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
-		os.Getenv("DB_HOST"), os.Getenv("DB_PORT"), os.Getenv("DB_USER"),
-		os.Getenv("DB_PASSWORD"), os.Getenv("DB_NAME"))
+// shutdown fails readiness, waits for in-flight requests, then stops the
+// background jobs and flushes spans, all within timeout.
+func shutdown(server *http.Server, probes *health.Health, workers *worker.Group, flushSpans func(context.Context) error, timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	probes.Drain()
+	if err := server.Shutdown(ctx); err != nil {
+		slog.Error("Could not drain requests", "error", err)
+	}
+	if err := workers.Stop(ctx); err != nil {
+		slog.Error("Could not stop background jobs", "error", err)
+	}
+	if err := flushSpans(ctx); err != nil {
+		slog.Error("Could not flush spans", "error", err)
+	}
+	slog.Info("Server stopped")
+}
 
-	db, err := sql.Open("postgres", connStr)
+// mqttConnectTimeout bounds the wait for the broker at startup; after it the
+// connection is retried in the background.
+const mqttConnectTimeout = 5 * time.Second
+
+// initMQTT connects the bridge to the broker and subscribes to the command
+// topics. The returned function marks the bridge offline and disconnects.
+func initMQTT(cfg config.MQTTConfig, activities domain.ActivityService, store storage) (*mqtt.Bridge, func()) {
+	ctx, cancel := context.WithTimeout(context.Background(), mqttConnectTimeout)
+	defer cancel()
+
+	conn := mqtt.Dial(ctx, mqtt.Options{
+		Broker:   cfg.Broker,
+		ClientID: cfg.ClientID,
+		Username: cfg.Username,
+		Password: cfg.Password,
+		Prefix:   cfg.TopicPrefix,
+		QoS:      byte(cfg.QoS),
+	})
+	bridge := mqtt.NewBridge(conn, cfg.TopicPrefix, activities, store.activities, store.definitions, time.Now)
+	if err := bridge.Listen(ctx); err != nil {
+		fatal("Could not subscribe to MQTT commands", err)
+	}
+	return bridge, conn.Close
+}
+
+func initPush(cfg config.PushConfig) (*push.Keys, *push.Client) {
+	keys, err := push.ParseKeys(cfg.VAPIDPublicKey, cfg.VAPIDPrivateKey)
 	if err != nil {
-		log.Fatalf("Error parsing connection stirng: %v", err)
+		fatal("Could not load the VAPID keys", err)
 	}
+	return keys, push.NewClient(keys, push.Options{
+		Subject: cfg.Subject,
+		TTL:     cfg.TTL,
+		Timeout: cfg.Timeout,
+	}, time.Now)
+}
+
+// storage is the set of repositories backing the server.
+type storage struct {
+	activities  service.ActivityRepository
+	definitions service.DefinitionRepository
+	entities    service.EntityRepository
+	changes     service.ChangeRepository
+	tx          service.TxManager
+	idempotency idempotency.Store
+	outbox      outbox.Store
+	webhooks    webhook.Store
+	alerts      alert.Store
+	push        push.Store
+	// db is the SQL connection pool, if the backend has one.
+	db *sql.DB
+	// checks tell the readiness probe whether the backend is usable.
+	checks map[string]health.Check
+	close  func()
+}
+
+// initStorage opens the configured backend: "postgres" (the default),
+// "sqlite" for single-household installs or "memory" for demos and kiosks
+// running without a database.
+func initStorage(cfg *config.Config) storage {
+	switch backend := cfg.Storage.Backend; backend {
+	case "postgres":
+		db := initDB(cfg.Database)
+		migrator, err := postgres.NewMigrator(db)
+		if err != nil {
+			fatal("Could not load migrations", err)
+		}
+		if cfg.Storage.AutoMigrate {
+			autoMigrate(migrator)
+		}
+		return storage{
+			activities:  postgres.NewPostgresActivityRepo(db),
+			definitions: postgres.NewPostgresDefinitionRepo(db),
+			entities:    postgres.NewPostgresEntityRepo(db),
+			changes:     postgres.NewPostgresChangeRepo(db),
+			tx:          repository.NewSQLTxManager(db),
+			idempotency: postgres.NewPostgresIdempotencyStore(db),
+			outbox:      postgres.NewPostgresOutboxStore(db),
+			webhooks:    postgres.NewPostgresWebhookStore(db),
+			alerts:      postgres.NewPostgresAlertStore(db),
+			push:        postgres.NewPostgresPushStore(db),
+			db:          db,
+			checks: map[string]health.Check{
+				"database": db.PingContext,
+				"schema":   migrator.Check,
+			},
+			close: func() { db.Close() },
+		}
+	case "sqlite":
+		db := initSQLite(cfg.Storage.SQLitePath)
+		return storage{
+			activities:  sqlite.NewSQLiteActivityRepo(db),
+			definitions: sqlite.NewSQLiteDefinitionRepo(db),
+			entities:    sqlite.NewSQLiteEntityRepo(db),
+			changes:     sqlite.NewSQLiteChangeRepo(db),
+			tx:          repository.NewSQLTxManager(db),
+			idempotency: sqlite.NewSQLiteIdempotencyStore(db),
+			outbox:      sqlite.NewSQLiteOutboxStore(db),
+			webhooks:    sqlite.NewSQLiteWebhookStore(db),
+			alerts:      sqlite.NewSQLiteAlertStore(db),
+			push:        sqlite.NewSQLitePushStore(db),
+			db:          db,
+			checks: map[string]health.Check{
+				"database": db.PingContext,
+				"schema":   func(ctx context.Context) error { return sqlite.CheckSchema(ctx, db) },
+			},
+			close: func() { db.Close() },
+		}
+	case "memory":
+		store := initMemoryStore(cfg.Storage)
+		return storage{
+			activities:  store.Activities,
+			definitions: store.Definitions,
+			entities:    store.Entities,
+			changes:     store.Changes,
+			tx:          memory.NewTxManager(),
+			idempotency: store.Idempotency,
+			outbox:      store.Outbox,
+			webhooks:    store.Webhooks,
+			alerts:      store.Alerts,
+			push:        store.Push,
+			checks:      map[string]health.Check{"snapshots": store.Check},
+			close: func() {
+				if err := store.Close(); err != nil {
+					slog.Error("Could not persist memory store", "error", err)
+				}
+			},
+		}
+	default:
+		fatal("Unknown storage backend", fmt.Errorf("%q: use postgres, sqlite or memory", backend))
+		return storage{}
+	}
+}
+
+func initSQLite(path string) *sql.DB {
+	db, err := sqlite.Open(path)
+	if err != nil {
+		fatal("Could not open SQLite database", err, "path", path)
+	}
+
+	if err := sqlite.Migrate(context.Background(), db); err != nil {
+		fatal("Could not migrate SQLite database", err)
+	}
+
+	slog.Info("Using SQLite database", "path", path)
+	return db
+}
+
+func initMemoryStore(cfg config.StorageConfig) *memory.Store {
+	store, err := memory.Open(cfg.MemoryDir, cfg.SnapshotInterval)
+	if err != nil {
+		fatal("Could not open memory store", err, "dir", cfg.MemoryDir)
+	}
+
+	slog.Info("Using in-memory storage", "dir", cfg.MemoryDir)
+	return store
+}
+
+func initDB(cfg config.DatabaseConfig) *sql.DB {
+	db, err := sql.Open("postgres", cfg.ConnString())
+	if err != nil {
+		fatal("Error parsing connection string", err)
+	}
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
 
 	if err := db.Ping(); err != nil {
-		log.Fatalf("Could not connect to the database: %v", err)
+		fatal("Could not connect to the database", err)
 	}
 
-	log.Println("Successfully connected to the database")
+	slog.Info("Successfully connected to the database")
 	return db
 }
+
+// fatal logs an error that keeps the server from running and exits.
+func fatal(msg string, err error, args ...any) {
+	slog.Error(msg, append([]any{"error", err}, args...)...)
+	os.Exit(1)
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log/slog"
+	"math"
+	"os"
+	"text/tabwriter"
+
+	"github.com/luisteixeira/waypoint/backend/internal/config"
+	"github.com/luisteixeira/waypoint/backend/internal/repository/postgres"
+)
+
+const migrateUsage = `usage: waypoint migrate <command>
+
+Commands:
+  up              apply all pending migrations
+  down [-steps N] roll back the last N migrations (default 1)
+  down -all       roll back every migration
+  status          list migrations and whether they are applied`
+
+// runMigrate implements `waypoint migrate up|down|status` against the
+// configured Postgres database.
+func runMigrate(cfg *config.Config, args []string) {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		os.Exit(2)
+	}
+
+	db := initDB(cfg.Database)
+	defer db.Close()
+
+	migrator, err := postgres.NewMigrator(db)
+	if err != nil {
+		fatal("Could not load migrations", err)
+	}
+	ctx := context.Background()
+
+	switch command := args[0]; command {
+	case "up":
+		applied, err := migrator.Up(ctx)
+		if err != nil {
+			fatal("Migration failed", err)
+		}
+		slog.Info("Migrations applied", "applied", applied, "version", migrator.Latest())
+	case "down":
+		flags := flag.NewFlagSet("migrate down", flag.ExitOnError)
+		steps := flags.Int("steps", 1, "number of migrations to roll back")
+		all := flags.Bool("all", false, "roll back every migration")
+		flags.Parse(args[1:])
+		if *all {
+			*steps = math.MaxInt
+		}
+
+		reverted, err := migrator.Down(ctx, *steps)
+		if err != nil {
+			fatal("Rollback failed", err)
+		}
+		slog.Info("Migrations rolled back", "reverted", reverted)
+	case "status":
+		if err := printMigrationStatus(ctx, migrator); err != nil {
+			fatal("Could not read migration status", err)
+		}
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown migrate command %q\n%s\n", command, migrateUsage)
+		os.Exit(2)
+	}
+}
+
+func printMigrationStatus(ctx context.Context, migrator *postgres.Migrator) error {
+	version, dirty, err := migrator.Version(ctx)
+	if err != nil {
+		return err
+	}
+	statuses, err := migrator.Status(ctx)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Schema version: %d", version)
+	if dirty {
+		fmt.Print(" (dirty)")
+	}
+	fmt.Println()
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED")
+	for _, s := range statuses {
+		fmt.Fprintf(w, "%d\t%s\t%t\n", s.Version, s.Name, s.Applied)
+	}
+	return w.Flush()
+}
+
+// autoMigrate brings the schema up to date before the server starts serving.
+// The advisory lock makes replicas starting together take turns.
+func autoMigrate(migrator *postgres.Migrator) {
+	applied, err := migrator.Up(context.Background())
+	if err != nil {
+		fatal("Migration failed", err)
+	}
+	if applied > 0 {
+		slog.Info("Migrations applied", "applied", applied, "version", migrator.Latest())
+	}
+}
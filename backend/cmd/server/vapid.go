@@ -0,0 +1,19 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/luisteixeira/waypoint/backend/internal/push"
+)
+
+// runVAPIDKeys implements `waypoint vapid-keys`, which prints a new key pair
+// for the push settings. Keep the pair once browsers have subscribed with
+// it: they stop receiving messages when it changes.
+func runVAPIDKeys() {
+	keys, err := push.GenerateKeys()
+	if err != nil {
+		fatal("Could not generate VAPID keys", err)
+	}
+	fmt.Printf("PUSH_VAPID_PUBLIC_KEY=%s\n", keys.PublicKey())
+	fmt.Printf("PUSH_VAPID_PRIVATE_KEY=%s\n", keys.PrivateKey())
+}
@@ -0,0 +1,142 @@
+// Package alert reminds families of activities that need attention: a planned
+// activity that has not started, one that has been going on for too long, or
+// one that has not been logged for a while. Rules say what to watch for. An
+// Evaluator checks them periodically against the realizations and raises one
+// Alert per rule and subject while the condition holds, delivering it through
+// the rule's channels.
+//
+// Every alert is listed in the app; channels such as email or webhooks carry
+// it further. Alerts can be snoozed, which raises them again once the snooze
+// is over, or acknowledged, which keeps them quiet. Either way they resolve
+// on their own once the condition clears.
+package alert
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/luisteixeira/waypoint/backend/internal/domain"
+)
+
+type Kind string
+
+const (
+	// KindNotStarted fires when a planned realization has not started
+	// Threshold after the time it was planned for.
+	KindNotStarted Kind = "not_started"
+	// KindRunningLong fires when a realization has been in progress or
+	// paused for longer than Threshold since it started.
+	KindRunningLong Kind = "running_long"
+	// KindNoActivity fires when the rule's child has not started a
+	// realization of the definition for Threshold. It needs an entity.
+	KindNoActivity Kind = "no_activity"
+)
+
+// Kinds lists every kind of rule.
+var Kinds = []Kind{KindNotStarted, KindRunningLong, KindNoActivity}
+
+// Channel names a way of delivering alerts beyond the app.
+type Channel string
+
+const (
+	ChannelEmail   Channel = "email"
+	ChannelWebhook Channel = "webhook"
+	// ChannelPush notifies the caregivers following the alert's child in
+	// their browsers.
+	ChannelPush Channel = "push"
+)
+
+// Rule watches the realizations of one definition, for one child or for all
+// of them.
+type Rule struct {
+	ID           uuid.UUID
+	FamilyID     uuid.UUID
+	Kind         Kind
+	DefinitionID uuid.UUID
+	// EntityID narrows the rule to one child; uuid.Nil watches every child.
+	EntityID  uuid.UUID
+	Threshold time.Duration
+	Channels  []Channel
+	// Email receives the alerts of a rule with the email channel.
+	Email     string
+	CreatedAt time.Time
+}
+
+type Status string
+
+const (
+	StatusOpen Status = "open"
+	// StatusSnoozed alerts are raised again once SnoozedUntil has passed, if
+	// their condition still holds.
+	StatusSnoozed Status = "snoozed"
+	// StatusAcknowledged alerts stay quiet until their condition clears.
+	StatusAcknowledged Status = "acknowledged"
+	// StatusResolved alerts are those whose condition has cleared.
+	StatusResolved Status = "resolved"
+)
+
+// Unresolved lists the statuses of alerts whose condition still held when
+// they were last checked.
+var Unresolved = []Status{StatusOpen, StatusSnoozed, StatusAcknowledged}
+
+// Alert is a rule firing for a subject: a realization, or for KindNoActivity
+// rules the child. A rule has at most one unresolved alert per subject.
+type Alert struct {
+	ID            uuid.UUID  `json:"id"`
+	FamilyID      uuid.UUID  `json:"family_id"`
+	RuleID        uuid.UUID  `json:"rule_id"`
+	Kind          Kind       `json:"kind"`
+	DefinitionID  uuid.UUID  `json:"definition_id"`
+	EntityID      uuid.UUID  `json:"entity_id"`
+	RealizationID *uuid.UUID `json:"realization_id"`
+	Message       string     `json:"message"`
+
+	Status         Status     `json:"status"`
+	TriggeredAt    time.Time  `json:"triggered_at"`
+	SnoozedUntil   *time.Time `json:"snoozed_until"`
+	AcknowledgedAt *time.Time `json:"acknowledged_at"`
+	ResolvedAt     *time.Time `json:"resolved_at"`
+}
+
+// Subject is what the alert is about: its realization or, failing that, its
+// child.
+func (a *Alert) Subject() uuid.UUID {
+	if a.RealizationID != nil {
+		return *a.RealizationID
+	}
+	return a.EntityID
+}
+
+// ErrAlreadyRaised is returned by Store.CreateAlert when the rule already has
+// an unresolved alert for the subject.
+var ErrAlreadyRaised error = &domain.ConflictError{Reason: "the rule already has an unresolved alert for this subject"}
+
+// Notifier delivers alerts through a channel.
+type Notifier interface {
+	Channel() Channel
+	// Notify delivers a newly raised alert. The context carries the alert's
+	// family.
+	Notify(ctx context.Context, rule Rule, alert Alert) error
+}
+
+// Store keeps rules and alerts per family, taken from the context like the
+// service repositories do. Only AllRules works across families.
+type Store interface {
+	CreateRule(ctx context.Context, rule *Rule) error
+	ListRules(ctx context.Context) ([]Rule, error)
+	// DeleteRule removes a rule and its alerts.
+	DeleteRule(ctx context.Context, id uuid.UUID) error
+	// AllRules returns the rules of every family, for the evaluator.
+	AllRules(ctx context.Context) ([]Rule, error)
+
+	// CreateAlert stores a new alert, or fails with ErrAlreadyRaised.
+	CreateAlert(ctx context.Context, alert *Alert) error
+	// UpdateAlert stores the message and status fields of an alert.
+	UpdateAlert(ctx context.Context, alert *Alert) error
+	GetAlert(ctx context.Context, id uuid.UUID) (*Alert, error)
+	// ListAlerts returns up to limit of the family's alerts with one of the
+	// given statuses, most recently triggered first. A limit of zero returns
+	// them all.
+	ListAlerts(ctx context.Context, statuses []Status, limit int) ([]Alert, error)
+}
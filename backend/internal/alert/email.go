@@ -0,0 +1,116 @@
+package alert
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"mime"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"time"
+)
+
+// smtpTimeout bounds a whole exchange with the mail server when the context
+// has no earlier deadline.
+const smtpTimeout = 30 * time.Second
+
+// SMTPOptions describe the mail server alerts are sent through, typically a
+// relay on the local network.
+type SMTPOptions struct {
+	// Addr is the server as host:port.
+	Addr string
+	From string
+	// Username and Password authenticate with PLAIN auth if set, which the
+	// server must offer over TLS unless it is on localhost.
+	Username string
+	Password string
+}
+
+// EmailNotifier mails alerts to the address of their rule.
+type EmailNotifier struct {
+	opts SMTPOptions
+}
+
+func NewEmailNotifier(opts SMTPOptions) *EmailNotifier {
+	return &EmailNotifier{opts: opts}
+}
+
+func (n *EmailNotifier) Channel() Channel {
+	return ChannelEmail
+}
+
+func (n *EmailNotifier) Notify(ctx context.Context, rule Rule, a Alert) error {
+	if rule.Email == "" {
+		return fmt.Errorf("rule %s has no email address", rule.ID)
+	}
+
+	deadline, ok := ctx.Deadline()
+	if !ok || time.Until(deadline) > smtpTimeout {
+		deadline = time.Now().Add(smtpTimeout)
+	}
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", n.opts.Addr)
+	if err != nil {
+		return fmt.Errorf("failed to reach the mail server: %w", err)
+	}
+	defer conn.Close()
+	if err := conn.SetDeadline(deadline); err != nil {
+		return err
+	}
+
+	host, _, err := net.SplitHostPort(n.opts.Addr)
+	if err != nil {
+		return err
+	}
+	c, err := smtp.NewClient(conn, host)
+	if err != nil {
+		return fmt.Errorf("failed to greet the mail server: %w", err)
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return fmt.Errorf("failed to start TLS: %w", err)
+		}
+	}
+	if n.opts.Username != "" {
+		if err := c.Auth(smtp.PlainAuth("", n.opts.Username, n.opts.Password, host)); err != nil {
+			return fmt.Errorf("failed to authenticate with the mail server: %w", err)
+		}
+	}
+	if err := c.Mail(n.opts.From); err != nil {
+		return fmt.Errorf("mail server refused the sender: %w", err)
+	}
+	if err := c.Rcpt(rule.Email); err != nil {
+		return fmt.Errorf("mail server refused the recipient: %w", err)
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(n.message(rule, a)); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("mail server refused the message: %w", err)
+	}
+	return c.Quit()
+}
+
+func (n *EmailNotifier) message(rule Rule, a Alert) []byte {
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", n.opts.From)
+	fmt.Fprintf(&b, "To: %s\r\n", (&mail.Address{Address: rule.Email}).String())
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", "Waypoint: "+a.Message))
+	now := time.Now()
+	fmt.Fprintf(&b, "Date: %s\r\n", now.Format(time.RFC1123Z))
+	// An alert raised again after a snooze is a new message.
+	fmt.Fprintf(&b, "Message-ID: <%s.%d@waypoint>\r\n", a.ID, now.UnixNano())
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("\r\n")
+	fmt.Fprintf(&b, "%s.\r\n\r\nTriggered at %s.\r\n", a.Message, a.TriggeredAt.Format(time.RFC1123Z))
+	return b.Bytes()
+}
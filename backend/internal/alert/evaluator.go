@@ -0,0 +1,277 @@
+package alert
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/luisteixeira/waypoint/backend/internal/domain"
+	"github.com/luisteixeira/waypoint/backend/internal/middleware"
+	"github.com/luisteixeira/waypoint/backend/internal/service"
+)
+
+// Evaluator raises, re-raises and resolves alerts by checking every rule
+// against the realizations.
+type Evaluator struct {
+	store        Store
+	realizations service.ActivityRepository
+	definitions  service.DefinitionRepository
+	notifiers    map[Channel]Notifier
+	now          func() time.Time
+}
+
+// NewEvaluator returns an evaluator that checks rules against the time now
+// tells.
+func NewEvaluator(store Store, realizations service.ActivityRepository, definitions service.DefinitionRepository, now func() time.Time, notifiers ...Notifier) *Evaluator {
+	e := &Evaluator{
+		store:        store,
+		realizations: realizations,
+		definitions:  definitions,
+		notifiers:    map[Channel]Notifier{},
+		now:          now,
+	}
+	for _, n := range notifiers {
+		e.notifiers[n.Channel()] = n
+	}
+	return e
+}
+
+// Evaluate checks every rule and returns how many alerts it raised, counting
+// snoozed ones raised again. A family that fails to evaluate is logged and
+// does not hold up the others; Evaluate only fails when no family could be
+// evaluated.
+func (e *Evaluator) Evaluate(ctx context.Context) (int, error) {
+	rules, err := e.store.AllRules(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("failed to load alert rules: %w", err)
+	}
+
+	var families []uuid.UUID
+	byFamily := map[uuid.UUID][]Rule{}
+	for _, rule := range rules {
+		if _, ok := byFamily[rule.FamilyID]; !ok {
+			families = append(families, rule.FamilyID)
+		}
+		byFamily[rule.FamilyID] = append(byFamily[rule.FamilyID], rule)
+	}
+
+	raised := 0
+	var errs []error
+	for _, familyID := range families {
+		if ctx.Err() != nil {
+			break
+		}
+		n, err := e.evaluateFamily(context.WithValue(ctx, middleware.FamilyIDKey, familyID), byFamily[familyID])
+		raised += n
+		if err != nil {
+			slog.ErrorContext(ctx, "Could not evaluate alert rules", "family_id", familyID, "error", err)
+			errs = append(errs, fmt.Errorf("family %s: %w", familyID, err))
+		}
+	}
+	if len(errs) > 0 && len(errs) == len(families) {
+		return raised, errors.Join(errs...)
+	}
+	return raised, nil
+}
+
+// key identifies the unresolved alert of a rule for a subject.
+type key struct {
+	rule, subject uuid.UUID
+}
+
+func (e *Evaluator) evaluateFamily(ctx context.Context, rules []Rule) (int, error) {
+	now := e.now().UTC().Truncate(time.Microsecond)
+
+	unresolved, err := e.store.ListAlerts(ctx, Unresolved, 0)
+	if err != nil {
+		return 0, fmt.Errorf("failed to list alerts: %w", err)
+	}
+	existing := map[key]Alert{}
+	for _, a := range unresolved {
+		existing[key{a.RuleID, a.Subject()}] = a
+	}
+	names, err := e.definitionNames(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	raised := 0
+	firing := map[key]bool{}
+	for _, rule := range rules {
+		alerts, err := e.check(ctx, rule, names[rule.DefinitionID], now)
+		if err != nil {
+			return raised, fmt.Errorf("failed to check rule %s: %w", rule.ID, err)
+		}
+
+		for _, a := range alerts {
+			k := key{rule.ID, a.Subject()}
+			firing[k] = true
+
+			if current, ok := existing[k]; ok {
+				if current.Status != StatusSnoozed || now.Before(*current.SnoozedUntil) {
+					continue
+				}
+				current.Status = StatusOpen
+				current.SnoozedUntil = nil
+				current.Message = a.Message
+				a = current
+				if err := e.store.UpdateAlert(ctx, &a); err != nil {
+					return raised, fmt.Errorf("failed to raise alert %s again: %w", a.ID, err)
+				}
+			} else {
+				a.ID = uuid.New()
+				a.Status = StatusOpen
+				a.TriggeredAt = now
+				err := e.store.CreateAlert(ctx, &a)
+				if errors.Is(err, ErrAlreadyRaised) {
+					// Another evaluator got there first.
+					continue
+				}
+				if err != nil {
+					return raised, fmt.Errorf("failed to raise alert: %w", err)
+				}
+			}
+			raised++
+			e.notify(ctx, rule, a)
+		}
+	}
+
+	for k, a := range existing {
+		if firing[k] {
+			continue
+		}
+		a.Status = StatusResolved
+		a.ResolvedAt = &now
+		a.SnoozedUntil = nil
+		if err := e.store.UpdateAlert(ctx, &a); err != nil {
+			return raised, fmt.Errorf("failed to resolve alert %s: %w", a.ID, err)
+		}
+	}
+	return raised, nil
+}
+
+// check returns an alert for every subject the rule fires for, with the
+// fields that describe the subject filled in.
+func (e *Evaluator) check(ctx context.Context, rule Rule, name string, now time.Time) ([]Alert, error) {
+	newAlert := func(entityID uuid.UUID, realizationID *uuid.UUID, message string) Alert {
+		return Alert{
+			RuleID:        rule.ID,
+			Kind:          rule.Kind,
+			DefinitionID:  rule.DefinitionID,
+			EntityID:      entityID,
+			RealizationID: realizationID,
+			Message:       message,
+		}
+	}
+	due := now.Add(-rule.Threshold)
+
+	switch rule.Kind {
+	case KindNotStarted:
+		ars, err := e.realizations.ListRealizations(ctx, domain.RealizationFilter{
+			DefinitionID:  rule.DefinitionID,
+			EntityID:      rule.EntityID,
+			Statuses:      []domain.ActivityStatus{domain.StatusPlanned},
+			PlannedBefore: &due,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		alerts := make([]Alert, len(ars))
+		for i, ar := range ars {
+			late := formatDuration(now.Sub(*ar.PlannedFor))
+			alerts[i] = newAlert(ar.EntityID, &ar.ID, fmt.Sprintf("%s has not started %s after its planned time", name, late))
+		}
+		return alerts, nil
+
+	case KindRunningLong:
+		ars, err := e.realizations.ListRealizations(ctx, domain.RealizationFilter{
+			DefinitionID:  rule.DefinitionID,
+			EntityID:      rule.EntityID,
+			Statuses:      []domain.ActivityStatus{domain.StatusInProgress, domain.StatusPaused},
+			StartedBefore: &due,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		alerts := make([]Alert, len(ars))
+		for i, ar := range ars {
+			message := fmt.Sprintf("%s has been going on for %s", name, formatDuration(now.Sub(*ar.StartedAt)))
+			alerts[i] = newAlert(ar.EntityID, &ar.ID, message)
+		}
+		return alerts, nil
+
+	case KindNoActivity:
+		latest, err := e.realizations.ListRealizations(ctx, domain.RealizationFilter{
+			DefinitionID: rule.DefinitionID,
+			EntityID:     rule.EntityID,
+			Statuses:     []domain.ActivityStatus{domain.StatusInProgress, domain.StatusPaused, domain.StatusCompleted},
+			Limit:        1,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		// The rule does not fire before it has been in place for its
+		// threshold, even for a child that never had the activity.
+		since := rule.CreatedAt
+		if len(latest) > 0 {
+			if latest[0].Status != domain.StatusCompleted {
+				return nil, nil
+			}
+			if latest[0].StartedAt != nil && latest[0].StartedAt.After(since) {
+				since = *latest[0].StartedAt
+			}
+		}
+		if since.After(due) {
+			return nil, nil
+		}
+		return []Alert{newAlert(rule.EntityID, nil, fmt.Sprintf("No %s logged in %s", name, formatDuration(now.Sub(since))))}, nil
+	}
+	return nil, nil
+}
+
+// notify delivers an alert through each of the rule's channels. Failures are
+// logged rather than retried: the alert stays listed in the app either way.
+func (e *Evaluator) notify(ctx context.Context, rule Rule, a Alert) {
+	for _, channel := range rule.Channels {
+		n, ok := e.notifiers[channel]
+		if !ok {
+			slog.WarnContext(ctx, "No notifier for alert channel", "channel", channel, "rule_id", rule.ID)
+			continue
+		}
+		if err := n.Notify(ctx, rule, a); err != nil {
+			slog.WarnContext(ctx, "Could not deliver alert", "channel", channel, "alert_id", a.ID, "error", err)
+		}
+	}
+}
+
+func (e *Evaluator) definitionNames(ctx context.Context) (map[uuid.UUID]string, error) {
+	defs, err := e.definitions.ListByFamily(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list definitions: %w", err)
+	}
+	names := make(map[uuid.UUID]string, len(defs))
+	for _, def := range defs {
+		names[def.ID] = def.Name
+	}
+	return names, nil
+}
+
+// formatDuration renders a duration to the minute, e.g. "3h 5m" or "45m".
+func formatDuration(d time.Duration) string {
+	d = d.Truncate(time.Minute)
+	hours, minutes := int(d.Hours()), int(d.Minutes())%60
+	switch {
+	case hours == 0:
+		return fmt.Sprintf("%dm", minutes)
+	case minutes == 0:
+		return fmt.Sprintf("%dh", hours)
+	default:
+		return fmt.Sprintf("%dh %dm", hours, minutes)
+	}
+}
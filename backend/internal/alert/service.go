@@ -0,0 +1,190 @@
+package alert
+
+import (
+	"context"
+	"fmt"
+	"net/mail"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/luisteixeira/waypoint/backend/internal/domain"
+	"github.com/luisteixeira/waypoint/backend/internal/service"
+)
+
+// Alert list page sizes.
+const (
+	DefaultAlertLimit = 50
+	MaxAlertLimit     = 200
+)
+
+// Rules are only checked every so often, so thresholds under a minute could
+// not be kept to; ones over a week are not reminders any more.
+const (
+	MinThreshold = time.Minute
+	MaxThreshold = 7 * 24 * time.Hour
+)
+
+// RuleInput describes a new rule.
+type RuleInput struct {
+	Kind         Kind
+	DefinitionID uuid.UUID
+	EntityID     uuid.UUID
+	Threshold    time.Duration
+	Channels     []Channel
+	Email        string
+}
+
+// Service is what families use to manage their rules and alerts.
+type Service struct {
+	store       Store
+	definitions service.DefinitionRepository
+	entities    service.EntityRepository
+	channels    []Channel
+	now         func() time.Time
+}
+
+// NewService returns a service whose rules may use the channels of the given
+// notifiers. Rules and alerts are stamped with the time now tells.
+func NewService(store Store, definitions service.DefinitionRepository, entities service.EntityRepository, now func() time.Time, notifiers ...Notifier) *Service {
+	s := &Service{store: store, definitions: definitions, entities: entities, now: now}
+	for _, n := range notifiers {
+		s.channels = append(s.channels, n.Channel())
+	}
+	return s
+}
+
+func (s *Service) CreateRule(ctx context.Context, input RuleInput) (*Rule, error) {
+	if !slices.Contains(Kinds, input.Kind) {
+		return nil, &domain.ValidationError{Field: "kind", Reason: "must be not_started, running_long or no_activity"}
+	}
+	if input.DefinitionID == uuid.Nil {
+		return nil, &domain.ValidationError{Field: "definition_id", Reason: "is required"}
+	}
+	if input.Kind == KindNoActivity && input.EntityID == uuid.Nil {
+		return nil, &domain.ValidationError{Field: "entity_id", Reason: "is required for no_activity rules"}
+	}
+	if input.Threshold < MinThreshold || input.Threshold > MaxThreshold {
+		return nil, &domain.ValidationError{Field: "threshold_minutes", Reason: "must be between a minute and a week"}
+	}
+
+	var channels []Channel
+	for _, c := range input.Channels {
+		if !slices.Contains(s.channels, c) {
+			return nil, &domain.ValidationError{Field: "channels", Reason: fmt.Sprintf("channel %q is not available", c)}
+		}
+		if !slices.Contains(channels, c) {
+			channels = append(channels, c)
+		}
+	}
+	var email string
+	if slices.Contains(channels, ChannelEmail) {
+		// Only the bare address is kept, as the mail server expects it.
+		address, err := mail.ParseAddress(input.Email)
+		if err != nil {
+			return nil, &domain.ValidationError{Field: "email", Reason: "must be an email address for the email channel"}
+		}
+		email = address.Address
+	} else if input.Email != "" {
+		return nil, &domain.ValidationError{Field: "email", Reason: "is only used with the email channel"}
+	}
+
+	defs, err := s.definitions.ListByFamily(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if !slices.ContainsFunc(defs, func(d domain.ActivityDefinition) bool { return d.ID == input.DefinitionID }) {
+		return nil, &domain.ValidationError{Field: "definition_id", Reason: "unknown activity definition"}
+	}
+	if input.EntityID != uuid.Nil {
+		entities, err := s.entities.ListByFamily(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if !slices.ContainsFunc(entities, func(e domain.Entity) bool { return e.ID == input.EntityID }) {
+			return nil, &domain.ValidationError{Field: "entity_id", Reason: "unknown child"}
+		}
+	}
+
+	rule := &Rule{
+		ID:           uuid.New(),
+		Kind:         input.Kind,
+		DefinitionID: input.DefinitionID,
+		EntityID:     input.EntityID,
+		Threshold:    input.Threshold,
+		Channels:     channels,
+		Email:        email,
+		CreatedAt:    s.now().UTC().Truncate(time.Microsecond),
+	}
+	if err := s.store.CreateRule(ctx, rule); err != nil {
+		return nil, err
+	}
+	return rule, nil
+}
+
+func (s *Service) Rules(ctx context.Context) ([]Rule, error) {
+	return s.store.ListRules(ctx)
+}
+
+func (s *Service) DeleteRule(ctx context.Context, id uuid.UUID) error {
+	return s.store.DeleteRule(ctx, id)
+}
+
+// Alerts returns the family's alerts with one of the given statuses, most
+// recently triggered first. No statuses picks the unresolved ones, and a
+// limit of zero DefaultAlertLimit.
+func (s *Service) Alerts(ctx context.Context, statuses []Status, limit int) ([]Alert, error) {
+	switch {
+	case limit == 0:
+		limit = DefaultAlertLimit
+	case limit < 0 || limit > MaxAlertLimit:
+		return nil, &domain.ValidationError{Field: "limit", Reason: "must be between 1 and 200"}
+	}
+	for _, status := range statuses {
+		if !slices.Contains(Unresolved, status) && status != StatusResolved {
+			return nil, &domain.ValidationError{Field: "status", Reason: fmt.Sprintf("unknown status %q", status)}
+		}
+	}
+	if len(statuses) == 0 {
+		statuses = Unresolved
+	}
+	return s.store.ListAlerts(ctx, statuses, limit)
+}
+
+// Snooze quiets an alert until the given time, after which it is raised again
+// if its condition still holds.
+func (s *Service) Snooze(ctx context.Context, id uuid.UUID, until time.Time) (*Alert, error) {
+	if !until.After(s.now()) {
+		return nil, &domain.ValidationError{Field: "until", Reason: "must be in the future"}
+	}
+	return s.update(ctx, id, func(a *Alert) {
+		until := until.UTC().Truncate(time.Microsecond)
+		a.Status = StatusSnoozed
+		a.SnoozedUntil = &until
+	})
+}
+
+// Acknowledge quiets an alert until its condition clears.
+func (s *Service) Acknowledge(ctx context.Context, id uuid.UUID) (*Alert, error) {
+	return s.update(ctx, id, func(a *Alert) {
+		now := s.now().UTC().Truncate(time.Microsecond)
+		a.Status = StatusAcknowledged
+		a.AcknowledgedAt = &now
+		a.SnoozedUntil = nil
+	})
+}
+
+func (s *Service) update(ctx context.Context, id uuid.UUID, change func(*Alert)) (*Alert, error) {
+	a, err := s.store.GetAlert(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if a.Status == StatusResolved {
+		return nil, &domain.ConflictError{Reason: "the alert is already resolved"}
+	}
+	change(a)
+	if err := s.store.UpdateAlert(ctx, a); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
@@ -0,0 +1,437 @@
+// Package config loads the server configuration. Values come from built-in
+// defaults, then an optional YAML or TOML file, then environment variables
+// and finally command-line flags, each source overriding the ones before it.
+package config
+
+import (
+	"fmt"
+	"log/slog"
+	"net"
+	"net/mail"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Config struct {
+	Server      ServerConfig      `yaml:"server" toml:"server"`
+	Storage     StorageConfig     `yaml:"storage" toml:"storage"`
+	Database    DatabaseConfig    `yaml:"database" toml:"database"`
+	Idempotency IdempotencyConfig `yaml:"idempotency" toml:"idempotency"`
+	Sync        SyncConfig        `yaml:"sync" toml:"sync"`
+	Outbox      OutboxConfig      `yaml:"outbox" toml:"outbox"`
+	Webhooks    WebhookConfig     `yaml:"webhooks" toml:"webhooks"`
+	MQTT        MQTTConfig        `yaml:"mqtt" toml:"mqtt"`
+	Alerts      AlertsConfig      `yaml:"alerts" toml:"alerts"`
+	Push        PushConfig        `yaml:"push" toml:"push"`
+	Tracing     TracingConfig     `yaml:"tracing" toml:"tracing"`
+	Logging     LoggingConfig     `yaml:"logging" toml:"logging"`
+	Demo        DemoConfig        `yaml:"demo" toml:"demo"`
+}
+
+type ServerConfig struct {
+	Addr           string        `yaml:"addr" toml:"addr" env:"SERVER_ADDR" flag:"addr" usage:"address to listen on"`
+	ReadTimeout    time.Duration `yaml:"read_timeout" toml:"read_timeout" env:"SERVER_READ_TIMEOUT" flag:"read-timeout" usage:"maximum duration for reading a request"`
+	WriteTimeout   time.Duration `yaml:"write_timeout" toml:"write_timeout" env:"SERVER_WRITE_TIMEOUT" flag:"write-timeout" usage:"maximum duration for writing a response"`
+	IdleTimeout    time.Duration `yaml:"idle_timeout" toml:"idle_timeout" env:"SERVER_IDLE_TIMEOUT" flag:"idle-timeout" usage:"how long keep-alive connections stay open"`
+	RequestTimeout time.Duration `yaml:"request_timeout" toml:"request_timeout" env:"SERVER_REQUEST_TIMEOUT" flag:"request-timeout" usage:"deadline for handling a request"`
+	// ShutdownTimeout bounds how long in-flight requests, streams and
+	// background jobs get to finish after SIGTERM.
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout" toml:"shutdown_timeout" env:"SERVER_SHUTDOWN_TIMEOUT" flag:"shutdown-timeout" usage:"how long to drain on shutdown"`
+	TLS             TLSConfig     `yaml:"tls" toml:"tls"`
+}
+
+// TLSConfig enables HTTPS when both files are set.
+type TLSConfig struct {
+	CertFile string `yaml:"cert_file" toml:"cert_file" env:"TLS_CERT_FILE" flag:"tls-cert" usage:"TLS certificate file"`
+	KeyFile  string `yaml:"key_file" toml:"key_file" env:"TLS_KEY_FILE" flag:"tls-key" usage:"TLS private key file"`
+}
+
+func (t TLSConfig) Enabled() bool {
+	return t.CertFile != "" || t.KeyFile != ""
+}
+
+type StorageConfig struct {
+	// Backend is postgres, sqlite or memory.
+	Backend          string        `yaml:"backend" toml:"backend" env:"STORAGE_BACKEND" flag:"storage" usage:"storage backend: postgres, sqlite or memory"`
+	SQLitePath       string        `yaml:"sqlite_path" toml:"sqlite_path" env:"SQLITE_PATH" flag:"sqlite-path" usage:"SQLite database file"`
+	MemoryDir        string        `yaml:"memory_dir" toml:"memory_dir" env:"MEMORY_DATA_DIR" flag:"memory-dir" usage:"directory the memory backend persists to"`
+	SnapshotInterval time.Duration `yaml:"snapshot_interval" toml:"snapshot_interval" env:"MEMORY_SNAPSHOT_INTERVAL" flag:"snapshot-interval" usage:"how often the memory backend writes a snapshot"`
+	AutoMigrate      bool          `yaml:"auto_migrate" toml:"auto_migrate" env:"AUTO_MIGRATE" flag:"auto-migrate" usage:"apply pending Postgres migrations before serving"`
+}
+
+// DatabaseConfig describes the Postgres connection. DSN, when set, takes the
+// place of the individual connection fields.
+type DatabaseConfig struct {
+	DSN             string        `yaml:"dsn" toml:"dsn" env:"DATABASE_URL" flag:"db-dsn" usage:"Postgres connection URL"`
+	Host            string        `yaml:"host" toml:"host" env:"DB_HOST" flag:"db-host" usage:"Postgres host"`
+	Port            int           `yaml:"port" toml:"port" env:"DB_PORT" flag:"db-port" usage:"Postgres port"`
+	User            string        `yaml:"user" toml:"user" env:"DB_USER" flag:"db-user" usage:"Postgres user"`
+	Password        string        `yaml:"password" toml:"password" env:"DB_PASSWORD" secret:"true"`
+	Name            string        `yaml:"name" toml:"name" env:"DB_NAME" flag:"db-name" usage:"Postgres database name"`
+	SSLMode         string        `yaml:"sslmode" toml:"sslmode" env:"DB_SSLMODE" flag:"db-sslmode" usage:"Postgres sslmode"`
+	MaxOpenConns    int           `yaml:"max_open_conns" toml:"max_open_conns" env:"DB_MAX_OPEN_CONNS" flag:"db-max-open-conns" usage:"maximum open connections, 0 for no limit"`
+	MaxIdleConns    int           `yaml:"max_idle_conns" toml:"max_idle_conns" env:"DB_MAX_IDLE_CONNS" flag:"db-max-idle-conns" usage:"maximum idle connections"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime" toml:"conn_max_lifetime" env:"DB_CONN_MAX_LIFETIME" flag:"db-conn-max-lifetime" usage:"maximum time a connection is reused, 0 for no limit"`
+}
+
+// ConnString returns the DSN, or builds a connection URL from the individual
+// fields.
+func (d DatabaseConfig) ConnString() string {
+	if d.DSN != "" {
+		return d.DSN
+	}
+
+	u := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(d.User, d.Password),
+		Host:     fmt.Sprintf("%s:%d", d.Host, d.Port),
+		Path:     d.Name,
+		RawQuery: url.Values{"sslmode": {d.SSLMode}}.Encode(),
+	}
+	return u.String()
+}
+
+// IdempotencyConfig controls how long responses to POSTs carrying an
+// Idempotency-Key are kept for retries.
+type IdempotencyConfig struct {
+	TTL             time.Duration `yaml:"ttl" toml:"ttl" env:"IDEMPOTENCY_TTL" flag:"idempotency-ttl" usage:"how long a response is replayed for retries with the same Idempotency-Key"`
+	CleanupInterval time.Duration `yaml:"cleanup_interval" toml:"cleanup_interval" env:"IDEMPOTENCY_CLEANUP_INTERVAL" flag:"idempotency-cleanup-interval" usage:"how often expired idempotency keys are deleted"`
+}
+
+// SyncConfig bounds the client timestamps accepted for actions queued while
+// a device was offline.
+type SyncConfig struct {
+	MaxClientAge   time.Duration `yaml:"max_client_age" toml:"max_client_age" env:"SYNC_MAX_CLIENT_AGE" flag:"sync-max-client-age" usage:"oldest client timestamp accepted for queued actions"`
+	MaxClientAhead time.Duration `yaml:"max_client_ahead" toml:"max_client_ahead" env:"SYNC_MAX_CLIENT_AHEAD" flag:"sync-max-client-ahead" usage:"how far ahead of the server clock a client timestamp may be"`
+}
+
+// OutboxConfig controls how the events stored with activity changes are
+// published and how long they are kept afterwards.
+type OutboxConfig struct {
+	DispatchInterval time.Duration `yaml:"dispatch_interval" toml:"dispatch_interval" env:"OUTBOX_DISPATCH_INTERVAL" flag:"outbox-dispatch-interval" usage:"how often pending outbox events are published"`
+	// InitialBackoff doubles after every failed attempt, up to MaxBackoff.
+	// Events are retried until every sink accepts them.
+	InitialBackoff  time.Duration `yaml:"initial_backoff" toml:"initial_backoff" env:"OUTBOX_INITIAL_BACKOFF" flag:"outbox-initial-backoff" usage:"wait before publishing a failed event again the first time"`
+	MaxBackoff      time.Duration `yaml:"max_backoff" toml:"max_backoff" env:"OUTBOX_MAX_BACKOFF" flag:"outbox-max-backoff" usage:"longest wait between attempts to publish an event"`
+	Retention       time.Duration `yaml:"retention" toml:"retention" env:"OUTBOX_RETENTION" flag:"outbox-retention" usage:"how long published outbox events are kept"`
+	CleanupInterval time.Duration `yaml:"cleanup_interval" toml:"cleanup_interval" env:"OUTBOX_CLEANUP_INTERVAL" flag:"outbox-cleanup-interval" usage:"how often published outbox events past their retention are deleted"`
+}
+
+// WebhookConfig controls how deliveries to the webhooks families register
+// are sent and retried.
+type WebhookConfig struct {
+	DispatchInterval time.Duration `yaml:"dispatch_interval" toml:"dispatch_interval" env:"WEBHOOK_DISPATCH_INTERVAL" flag:"webhook-dispatch-interval" usage:"how often due webhook deliveries are sent"`
+	Timeout          time.Duration `yaml:"timeout" toml:"timeout" env:"WEBHOOK_TIMEOUT" flag:"webhook-timeout" usage:"deadline for a receiver to answer a delivery"`
+	MaxAttempts      int           `yaml:"max_attempts" toml:"max_attempts" env:"WEBHOOK_MAX_ATTEMPTS" flag:"webhook-max-attempts" usage:"how many times a delivery is sent before it is marked failed"`
+	// InitialBackoff doubles after every failed attempt, up to MaxBackoff.
+	InitialBackoff time.Duration `yaml:"initial_backoff" toml:"initial_backoff" env:"WEBHOOK_INITIAL_BACKOFF" flag:"webhook-initial-backoff" usage:"wait before retrying a failed delivery the first time"`
+	MaxBackoff     time.Duration `yaml:"max_backoff" toml:"max_backoff" env:"WEBHOOK_MAX_BACKOFF" flag:"webhook-max-backoff" usage:"longest wait between retries of a delivery"`
+}
+
+// MQTTConfig connects the optional MQTT bridge for smart-home hubs. It is off
+// while Broker is empty.
+type MQTTConfig struct {
+	// Broker is the broker URL, e.g. tcp://localhost:1883 or ssl://hub:8883.
+	Broker   string `yaml:"broker" toml:"broker" env:"MQTT_BROKER" flag:"mqtt-broker" usage:"MQTT broker URL; empty disables the bridge"`
+	ClientID string `yaml:"client_id" toml:"client_id" env:"MQTT_CLIENT_ID" flag:"mqtt-client-id" usage:"client ID presented to the MQTT broker"`
+	Username string `yaml:"username" toml:"username" env:"MQTT_USERNAME" flag:"mqtt-username" usage:"MQTT broker user"`
+	Password string `yaml:"password" toml:"password" env:"MQTT_PASSWORD" secret:"true"`
+	// TopicPrefix is the root of the state and command topics.
+	TopicPrefix string `yaml:"topic_prefix" toml:"topic_prefix" env:"MQTT_TOPIC_PREFIX" flag:"mqtt-topic-prefix" usage:"root of the MQTT topics"`
+	QoS         int    `yaml:"qos" toml:"qos" env:"MQTT_QOS" flag:"mqtt-qos" usage:"MQTT quality of service: 0, 1 or 2"`
+}
+
+// Enabled reports whether the bridge should connect.
+func (m MQTTConfig) Enabled() bool {
+	return m.Broker != ""
+}
+
+var mqttSchemes = []string{"tcp", "mqtt", "ssl", "tls", "mqtts", "ws", "wss"}
+
+// AlertsConfig controls how often alert rules are checked and the SMTP relay
+// used by the email channel, which is off while SMTPAddr is empty.
+type AlertsConfig struct {
+	EvaluateInterval time.Duration `yaml:"evaluate_interval" toml:"evaluate_interval" env:"ALERTS_EVALUATE_INTERVAL" flag:"alerts-evaluate-interval" usage:"how often alert rules are checked"`
+	// SMTPAddr is the relay as host:port, usually a local MTA.
+	SMTPAddr     string `yaml:"smtp_addr" toml:"smtp_addr" env:"ALERTS_SMTP_ADDR" flag:"alerts-smtp-addr" usage:"SMTP relay host:port; empty disables email alerts"`
+	SMTPFrom     string `yaml:"smtp_from" toml:"smtp_from" env:"ALERTS_SMTP_FROM" flag:"alerts-smtp-from" usage:"sender address of alert emails"`
+	SMTPUsername string `yaml:"smtp_username" toml:"smtp_username" env:"ALERTS_SMTP_USERNAME" flag:"alerts-smtp-username" usage:"SMTP relay user"`
+	SMTPPassword string `yaml:"smtp_password" toml:"smtp_password" env:"ALERTS_SMTP_PASSWORD" secret:"true"`
+}
+
+// EmailEnabled reports whether alerts may be sent by email.
+func (a AlertsConfig) EmailEnabled() bool {
+	return a.SMTPAddr != ""
+}
+
+// PushConfig holds the VAPID key pair Web Push messages are signed with.
+// Push notifications are off while VAPIDPrivateKey is empty; generate a
+// pair with the vapid-keys command.
+type PushConfig struct {
+	VAPIDPublicKey  string `yaml:"vapid_public_key" toml:"vapid_public_key" env:"PUSH_VAPID_PUBLIC_KEY" flag:"push-vapid-public-key" usage:"base64url VAPID public key"`
+	VAPIDPrivateKey string `yaml:"vapid_private_key" toml:"vapid_private_key" env:"PUSH_VAPID_PRIVATE_KEY" secret:"true"`
+	// Subject is a mailto: or https: URL push services can reach the
+	// operator at.
+	Subject string        `yaml:"subject" toml:"subject" env:"PUSH_SUBJECT" flag:"push-subject" usage:"mailto: or https: contact sent to push services"`
+	TTL     time.Duration `yaml:"ttl" toml:"ttl" env:"PUSH_TTL" flag:"push-ttl" usage:"how long push services keep a message for an offline browser"`
+	Timeout time.Duration `yaml:"timeout" toml:"timeout" env:"PUSH_TIMEOUT" flag:"push-timeout" usage:"timeout of a request to a push service"`
+}
+
+// Enabled reports whether push notifications are sent.
+func (p PushConfig) Enabled() bool {
+	return p.VAPIDPrivateKey != ""
+}
+
+// TracingConfig selects where OpenTelemetry spans are exported.
+type TracingConfig struct {
+	// Exporter is none, stdout or otlp.
+	Exporter string `yaml:"exporter" toml:"exporter" env:"TRACING_EXPORTER" flag:"tracing-exporter" usage:"span exporter: none, stdout or otlp"`
+	// Endpoint is the OTLP/HTTP collector as host:port. When empty the
+	// standard OTEL_EXPORTER_OTLP_* variables apply.
+	Endpoint    string  `yaml:"endpoint" toml:"endpoint" env:"TRACING_OTLP_ENDPOINT" flag:"tracing-endpoint" usage:"OTLP/HTTP collector host:port"`
+	Insecure    bool    `yaml:"insecure" toml:"insecure" env:"TRACING_OTLP_INSECURE" flag:"tracing-insecure" usage:"send spans to the collector over plain HTTP"`
+	SampleRatio float64 `yaml:"sample_ratio" toml:"sample_ratio" env:"TRACING_SAMPLE_RATIO" flag:"tracing-sample-ratio" usage:"fraction of new traces to record"`
+	ServiceName string  `yaml:"service_name" toml:"service_name" env:"TRACING_SERVICE_NAME" flag:"tracing-service-name" usage:"service.name reported with spans"`
+}
+
+type LoggingConfig struct {
+	// Level is debug, info, warn or error.
+	Level string `yaml:"level" toml:"level" env:"LOG_LEVEL" flag:"log-level" usage:"minimum level logged: debug, info, warn or error"`
+	// Format is json or text.
+	Format string `yaml:"format" toml:"format" env:"LOG_FORMAT" flag:"log-format" usage:"log format: json or text"`
+}
+
+// DemoConfig picks the family and child the dashboard shows until it has
+// sign-in of its own.
+type DemoConfig struct {
+	FamilyID string `yaml:"family_id" toml:"family_id" env:"TEST_FAMILY_ID" flag:"demo-family" usage:"family shown on the dashboard"`
+	EntityID string `yaml:"entity_id" toml:"entity_id" env:"TEST_ENTITY_ID" flag:"demo-entity" usage:"child shown on the dashboard"`
+}
+
+// IDs returns the demo family and child, or uuid.Nil for those not set.
+// Validate has already checked that they parse.
+func (d DemoConfig) IDs() (familyID, entityID uuid.UUID) {
+	familyID, _ = uuid.Parse(d.FamilyID)
+	entityID, _ = uuid.Parse(d.EntityID)
+	return familyID, entityID
+}
+
+// Default returns the configuration used when nothing overrides it.
+func Default() *Config {
+	return &Config{
+		Server: ServerConfig{
+			Addr:            ":8080",
+			ReadTimeout:     5 * time.Second,
+			WriteTimeout:    10 * time.Second,
+			IdleTimeout:     2 * time.Minute,
+			RequestTimeout:  60 * time.Second,
+			ShutdownTimeout: 30 * time.Second,
+		},
+		Storage: StorageConfig{
+			Backend:          "postgres",
+			SQLitePath:       "waypoint.db",
+			MemoryDir:        "data",
+			SnapshotInterval: 5 * time.Minute,
+		},
+		Database: DatabaseConfig{
+			Host:            "localhost",
+			Port:            5432,
+			SSLMode:         "disable",
+			MaxOpenConns:    25,
+			MaxIdleConns:    5,
+			ConnMaxLifetime: 30 * time.Minute,
+		},
+		Idempotency: IdempotencyConfig{
+			TTL:             24 * time.Hour,
+			CleanupInterval: time.Hour,
+		},
+		Sync: SyncConfig{
+			MaxClientAge:   72 * time.Hour,
+			MaxClientAhead: 2 * time.Minute,
+		},
+		Outbox: OutboxConfig{
+			DispatchInterval: time.Second,
+			InitialBackoff:   5 * time.Second,
+			MaxBackoff:       5 * time.Minute,
+			Retention:        7 * 24 * time.Hour,
+			CleanupInterval:  time.Hour,
+		},
+		Webhooks: WebhookConfig{
+			DispatchInterval: 5 * time.Second,
+			Timeout:          10 * time.Second,
+			MaxAttempts:      8,
+			InitialBackoff:   30 * time.Second,
+			MaxBackoff:       time.Hour,
+		},
+		MQTT: MQTTConfig{
+			ClientID:    "waypoint",
+			TopicPrefix: "waypoint",
+			QoS:         1,
+		},
+		Alerts: AlertsConfig{
+			EvaluateInterval: 30 * time.Second,
+			SMTPFrom:         "waypoint@localhost",
+		},
+		Push: PushConfig{
+			Subject: "mailto:waypoint@localhost",
+			TTL:     12 * time.Hour,
+			Timeout: 10 * time.Second,
+		},
+		Tracing: TracingConfig{
+			Exporter:    "none",
+			SampleRatio: 1,
+			ServiceName: "waypoint",
+		},
+		Logging: LoggingConfig{
+			Level:  "info",
+			Format: "json",
+		},
+	}
+}
+
+var sslModes = []string{"disable", "allow", "prefer", "require", "verify-ca", "verify-full"}
+
+// Validate reports every problem with the configuration at once.
+func (c *Config) Validate() error {
+	var errs ValidationErrors
+	check := func(ok bool, field, reason string) {
+		if !ok {
+			errs = append(errs, FieldError{Field: field, Reason: reason})
+		}
+	}
+
+	check(c.Server.Addr != "", "server.addr", "must not be empty")
+	check(c.Server.ReadTimeout > 0, "server.read_timeout", "must be positive")
+	check(c.Server.WriteTimeout > 0, "server.write_timeout", "must be positive")
+	check(c.Server.IdleTimeout >= 0, "server.idle_timeout", "must not be negative")
+	check(c.Server.RequestTimeout > 0, "server.request_timeout", "must be positive")
+	check(c.Server.ShutdownTimeout > 0, "server.shutdown_timeout", "must be positive")
+	check(c.Server.TLS.CertFile != "" || c.Server.TLS.KeyFile == "", "server.tls.cert_file", "is required when key_file is set")
+	check(c.Server.TLS.KeyFile != "" || c.Server.TLS.CertFile == "", "server.tls.key_file", "is required when cert_file is set")
+
+	switch c.Storage.Backend {
+	case "postgres":
+		c.validateDatabase(check)
+	case "sqlite":
+		check(c.Storage.SQLitePath != "", "storage.sqlite_path", "must not be empty")
+	case "memory":
+		check(c.Storage.MemoryDir != "", "storage.memory_dir", "must not be empty")
+		check(c.Storage.SnapshotInterval >= 0, "storage.snapshot_interval", "must not be negative")
+	default:
+		check(false, "storage.backend", fmt.Sprintf("must be postgres, sqlite or memory, got %q", c.Storage.Backend))
+	}
+
+	check(c.Idempotency.TTL > 0, "idempotency.ttl", "must be positive")
+	check(c.Idempotency.CleanupInterval > 0, "idempotency.cleanup_interval", "must be positive")
+	check(c.Sync.MaxClientAge >= 0, "sync.max_client_age", "must not be negative")
+	check(c.Sync.MaxClientAhead >= 0, "sync.max_client_ahead", "must not be negative")
+	check(c.Outbox.DispatchInterval > 0, "outbox.dispatch_interval", "must be positive")
+	check(c.Outbox.InitialBackoff > 0, "outbox.initial_backoff", "must be positive")
+	check(c.Outbox.MaxBackoff >= c.Outbox.InitialBackoff, "outbox.max_backoff", "must not be less than initial_backoff")
+	check(c.Outbox.Retention > 0, "outbox.retention", "must be positive")
+	check(c.Outbox.CleanupInterval > 0, "outbox.cleanup_interval", "must be positive")
+	check(c.Webhooks.DispatchInterval > 0, "webhooks.dispatch_interval", "must be positive")
+	check(c.Webhooks.Timeout > 0, "webhooks.timeout", "must be positive")
+	check(c.Webhooks.MaxAttempts > 0, "webhooks.max_attempts", "must be positive")
+	check(c.Webhooks.InitialBackoff > 0, "webhooks.initial_backoff", "must be positive")
+	check(c.Webhooks.MaxBackoff >= c.Webhooks.InitialBackoff, "webhooks.max_backoff", "must not be less than initial_backoff")
+
+	if c.MQTT.Enabled() {
+		c.validateMQTT(check)
+	}
+
+	check(c.Alerts.EvaluateInterval > 0, "alerts.evaluate_interval", "must be positive")
+	if c.Alerts.EmailEnabled() {
+		_, port, err := net.SplitHostPort(c.Alerts.SMTPAddr)
+		check(err == nil && port != "", "alerts.smtp_addr", "must be host:port")
+		_, err = mail.ParseAddress(c.Alerts.SMTPFrom)
+		check(err == nil, "alerts.smtp_from", "must be an email address")
+	}
+
+	if c.Push.Enabled() {
+		check(c.Push.VAPIDPublicKey != "", "push.vapid_public_key", "must be set with the private key")
+		subject, err := url.Parse(c.Push.Subject)
+		check(err == nil && (subject.Scheme == "mailto" || subject.Scheme == "https"), "push.subject", "must be a mailto: or https: URL")
+		check(c.Push.TTL > 0, "push.ttl", "must be positive")
+		check(c.Push.Timeout > 0, "push.timeout", "must be positive")
+	}
+
+	switch c.Tracing.Exporter {
+	case "none", "stdout", "otlp":
+	default:
+		check(false, "tracing.exporter", fmt.Sprintf("must be none, stdout or otlp, got %q", c.Tracing.Exporter))
+	}
+	check(c.Tracing.SampleRatio >= 0 && c.Tracing.SampleRatio <= 1, "tracing.sample_ratio", "must be between 0 and 1")
+	check(c.Tracing.Exporter == "none" || c.Tracing.ServiceName != "", "tracing.service_name", "must not be empty")
+
+	var level slog.Level
+	check(level.UnmarshalText([]byte(c.Logging.Level)) == nil, "logging.level", "must be debug, info, warn or error")
+	check(c.Logging.Format == "json" || c.Logging.Format == "text", "logging.format", "must be json or text")
+
+	for field, id := range map[string]string{"demo.family_id": c.Demo.FamilyID, "demo.entity_id": c.Demo.EntityID} {
+		if id != "" {
+			_, err := uuid.Parse(id)
+			check(err == nil, field, "must be a UUID")
+		}
+	}
+
+	if len(errs) == 0 {
+		return nil
+	}
+	return errs
+}
+
+func (c *Config) validateMQTT(check func(ok bool, field, reason string)) {
+	m := c.MQTT
+	u, err := url.Parse(m.Broker)
+	check(err == nil && slices.Contains(mqttSchemes, u.Scheme) && u.Host != "", "mqtt.broker",
+		"must be a URL such as tcp://localhost:1883")
+	check(m.ClientID != "", "mqtt.client_id", "must not be empty")
+	check(m.TopicPrefix != "" && !strings.ContainsAny(m.TopicPrefix, "+#") && !strings.HasSuffix(m.TopicPrefix, "/"),
+		"mqtt.topic_prefix", "must be a topic without wildcards or a trailing slash")
+	check(m.QoS >= 0 && m.QoS <= 2, "mqtt.qos", "must be 0, 1 or 2")
+}
+
+func (c *Config) validateDatabase(check func(ok bool, field, reason string)) {
+	d := c.Database
+	if d.DSN != "" {
+		_, err := url.Parse(d.DSN)
+		check(err == nil && strings.HasPrefix(d.DSN, "postgres"), "database.dsn", "must be a postgres:// or postgresql:// URL")
+	} else {
+		check(d.Host != "", "database.host", "must not be empty")
+		check(d.Port > 0 && d.Port < 65536, "database.port", "must be between 1 and 65535")
+		check(d.User != "", "database.user", "must not be empty")
+		check(d.Name != "", "database.name", "must not be empty")
+		check(slices.Contains(sslModes, d.SSLMode), "database.sslmode", "must be one of "+strings.Join(sslModes, ", "))
+	}
+
+	check(d.MaxOpenConns >= 0, "database.max_open_conns", "must not be negative")
+	check(d.MaxIdleConns >= 0, "database.max_idle_conns", "must not be negative")
+	check(d.MaxOpenConns == 0 || d.MaxIdleConns <= d.MaxOpenConns, "database.max_idle_conns", "must not exceed max_open_conns")
+	check(d.ConnMaxLifetime >= 0, "database.conn_max_lifetime", "must not be negative")
+}
+
+// FieldError is one invalid setting.
+type FieldError struct {
+	Field  string
+	Reason string
+}
+
+func (e FieldError) Error() string {
+	return fmt.Sprintf("%s %s", e.Field, e.Reason)
+}
+
+// ValidationErrors lists every invalid setting found by Validate.
+type ValidationErrors []FieldError
+
+func (e ValidationErrors) Error() string {
+	lines := make([]string, len(e))
+	for i, fe := range e {
+		lines[i] = "  " + fe.Error()
+	}
+	return "invalid configuration:\n" + strings.Join(lines, "\n")
+}
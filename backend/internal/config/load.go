@@ -0,0 +1,197 @@
+package config
+
+import (
+	"bytes"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// Load builds the configuration from defaults, the file named by -config or
+// WAYPOINT_CONFIG, the environment and the flags in args, then validates it.
+// It returns the arguments left after the flags, such as a subcommand.
+func Load(args []string) (*Config, []string, error) {
+	cfg := Default()
+	settings := settingsOf(cfg)
+
+	fs := flag.NewFlagSet("waypoint", flag.ContinueOnError)
+	configPath := fs.String("config", os.Getenv("WAYPOINT_CONFIG"), "YAML or TOML configuration file (env WAYPOINT_CONFIG)")
+	flags := map[string]*flagValue{}
+	for _, s := range settings {
+		if s.flag == "" {
+			continue
+		}
+		usage := s.usage
+		if s.env != "" {
+			usage += fmt.Sprintf(" (env %s)", s.env)
+		}
+		flags[s.flag] = &flagValue{current: s.value}
+		fs.Var(flags[s.flag], s.flag, usage)
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, nil, err
+	}
+
+	if *configPath != "" {
+		if err := loadFile(cfg, *configPath); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	for _, s := range settings {
+		if s.env == "" {
+			continue
+		}
+		if raw, ok := os.LookupEnv(s.env); ok {
+			if err := set(s.value, raw); err != nil {
+				return nil, nil, fmt.Errorf("invalid %s: %w", s.env, err)
+			}
+		}
+	}
+
+	var flagErr error
+	fs.Visit(func(f *flag.Flag) {
+		v, ok := flags[f.Name]
+		if !ok || flagErr != nil {
+			return
+		}
+		if err := set(v.current, v.raw); err != nil {
+			flagErr = fmt.Errorf("invalid -%s: %w", f.Name, err)
+		}
+	})
+	if flagErr != nil {
+		return nil, nil, flagErr
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, nil, err
+	}
+	return cfg, fs.Args(), nil
+}
+
+// loadFile decodes a YAML or TOML file, chosen by extension, over cfg.
+// Unknown keys are rejected so typos do not go unnoticed.
+func loadFile(cfg *Config, path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		if err := decoder.Decode(cfg); err != nil && !errors.Is(err, io.EOF) {
+			return fmt.Errorf("invalid config file %s: %w", path, err)
+		}
+	case ".toml":
+		meta, err := toml.Decode(string(data), cfg)
+		if err != nil {
+			return fmt.Errorf("invalid config file %s: %w", path, err)
+		}
+		if undecoded := meta.Undecoded(); len(undecoded) > 0 {
+			return fmt.Errorf("invalid config file %s: unknown key %s", path, undecoded[0])
+		}
+	default:
+		return fmt.Errorf("unsupported config file type %q: use .yaml, .yml or .toml", ext)
+	}
+	return nil
+}
+
+// setting is one configurable field and the names it is read under.
+type setting struct {
+	value reflect.Value
+	env   string
+	flag  string
+	usage string
+}
+
+func settingsOf(cfg *Config) []setting {
+	var settings []setting
+	var walk func(v reflect.Value)
+	walk = func(v reflect.Value) {
+		for i := 0; i < v.NumField(); i++ {
+			field, value := v.Type().Field(i), v.Field(i)
+			if field.Type.Kind() == reflect.Struct && field.Type != reflect.TypeOf(time.Duration(0)) {
+				walk(value)
+				continue
+			}
+			settings = append(settings, setting{
+				value: value,
+				env:   field.Tag.Get("env"),
+				flag:  field.Tag.Get("flag"),
+				usage: field.Tag.Get("usage"),
+			})
+		}
+	}
+	walk(reflect.ValueOf(cfg).Elem())
+	return settings
+}
+
+// set parses raw into the field according to its type.
+func set(v reflect.Value, raw string) error {
+	switch {
+	case v.Type() == reflect.TypeOf(time.Duration(0)):
+		d, err := time.ParseDuration(raw)
+		if err != nil {
+			return err
+		}
+		v.SetInt(int64(d))
+	case v.Kind() == reflect.String:
+		v.SetString(raw)
+	case v.Kind() == reflect.Int:
+		n, err := strconv.Atoi(raw)
+		if err != nil {
+			return fmt.Errorf("%q is not a number", raw)
+		}
+		v.SetInt(int64(n))
+	case v.Kind() == reflect.Float64:
+		f, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return fmt.Errorf("%q is not a number", raw)
+		}
+		v.SetFloat(f)
+	case v.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("%q is not a boolean", raw)
+		}
+		v.SetBool(b)
+	default:
+		return fmt.Errorf("unsupported setting type %s", v.Type())
+	}
+	return nil
+}
+
+// flagValue holds a flag's raw text until the file and environment have been
+// applied, so flags win regardless of where they appear.
+type flagValue struct {
+	current reflect.Value
+	raw     string
+}
+
+func (f *flagValue) String() string {
+	if !f.current.IsValid() {
+		return ""
+	}
+	return fmt.Sprint(f.current.Interface())
+}
+
+func (f *flagValue) Set(raw string) error {
+	f.raw = raw
+	return set(reflect.New(f.current.Type()).Elem(), raw)
+}
+
+func (f *flagValue) IsBoolFlag() bool {
+	return f.current.IsValid() && f.current.Kind() == reflect.Bool
+}
@@ -11,6 +11,7 @@ type ActivityStatus string
 const (
 	StatusPlanned    ActivityStatus = "planned"
 	StatusInProgress ActivityStatus = "in_progress"
+	StatusPaused     ActivityStatus = "paused"
 	StatusCompleted  ActivityStatus = "completed"
 	StatusCancelled  ActivityStatus = "cancelled"
 )
@@ -30,6 +31,23 @@ type ActivityRealization struct {
 	EntityID      uuid.UUID      `json:"entity_id"`
 	CaregiversIDs []uuid.UUID    `json:"caregiver_ids"`
 	Status        ActivityStatus `json:"status"`
+	PlannedFor    *time.Time     `json:"planned_for"`
 	StartedAt     *time.Time     `json:"started_at"`
 	FinishedAt    *time.Time     `json:"finished_at"`
+	Version       int            `json:"version"`
+}
+
+// RealizationFilter selects realizations. Zero-valued fields match every
+// realization.
+type RealizationFilter struct {
+	DefinitionID uuid.UUID
+	EntityID     uuid.UUID
+	Statuses     []ActivityStatus
+	// PlannedBefore keeps realizations planned for at or before the given
+	// time.
+	PlannedBefore *time.Time
+	// StartedBefore keeps realizations started at or before the given time.
+	StartedBefore *time.Time
+	// Limit caps the number of realizations returned; zero means no cap.
+	Limit int
 }
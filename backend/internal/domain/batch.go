@@ -0,0 +1,68 @@
+package domain
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type BatchOperationType string
+
+const (
+	BatchStart    BatchOperationType = "start"
+	BatchComplete BatchOperationType = "complete"
+	BatchNote     BatchOperationType = "note"
+)
+
+// BatchOperation is one action a client queued while offline. Operations on
+// an existing realization name it by RealizationID, or by Ref, the ID of an
+// earlier start operation in the same batch.
+type BatchOperation struct {
+	// ID is chosen by the client and echoed in the result.
+	ID   string
+	Type BatchOperationType
+	// ClientTimestamp is when the action happened on the client, and so
+	// when the activity started or finished.
+	ClientTimestamp time.Time
+
+	RealizationID   uuid.UUID
+	Ref             string
+	ExpectedVersion int
+
+	// Start describes the activity started by a start operation.
+	Start StartActivityInput
+	// Note is the text added by a note operation.
+	Note string
+}
+
+type BatchStatus string
+
+const (
+	BatchApplied BatchStatus = "applied"
+	BatchFailed  BatchStatus = "failed"
+	// BatchRolledBack marks operations that succeeded but were undone because
+	// a later one in an atomic batch failed.
+	BatchRolledBack BatchStatus = "rolled_back"
+	// BatchSkipped marks operations not attempted after a failure in an
+	// atomic batch.
+	BatchSkipped BatchStatus = "skipped"
+)
+
+// BatchResult is the outcome of one operation. Realization is set for applied
+// operations and Err for failed ones.
+type BatchResult struct {
+	ID              string
+	Type            BatchOperationType
+	ClientTimestamp time.Time
+	Status          BatchStatus
+	Realization     *ActivityRealization
+	Err             error
+}
+
+// BatchService applies queued operations in order. A failed operation does
+// not stop the ones after it unless the batch is atomic, in which case
+// nothing is applied.
+type BatchService interface {
+	ApplyBatch(ctx context.Context, ops []BatchOperation, atomic bool) ([]BatchResult, error)
+}
@@ -0,0 +1,22 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+type clientTimeKey struct{}
+
+// WithClientTime marks the changes made with ctx as having happened at t on
+// the client, for actions that were queued while it was offline. The service
+// stamps events, and so StartedAt and FinishedAt, with t instead of its own
+// clock, provided t falls within the window it accepts.
+func WithClientTime(ctx context.Context, t time.Time) context.Context {
+	return context.WithValue(ctx, clientTimeKey{}, t)
+}
+
+// ClientTime returns the time set by WithClientTime.
+func ClientTime(ctx context.Context) (time.Time, bool) {
+	t, ok := ctx.Value(clientTimeKey{}).(time.Time)
+	return t, ok
+}
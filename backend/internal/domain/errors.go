@@ -0,0 +1,109 @@
+package domain
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+)
+
+// Error kinds. Every error returned by the service and repositories that the
+// caller can act on matches one of these with errors.Is.
+var (
+	ErrNotFound          = errors.New("not found")
+	ErrConflict          = errors.New("conflict")
+	ErrInvalidTransition = errors.New("invalid transition")
+	ErrValidation        = errors.New("validation failed")
+	ErrForbidden         = errors.New("forbidden")
+	ErrUnauthorized      = errors.New("unauthorized")
+	ErrTooLarge          = errors.New("too large")
+)
+
+var ErrEntityBusy error = &ConflictError{Reason: "child is already participating in an activity"}
+
+// ErrIdempotencyKeyReused is returned when an Idempotency-Key is sent again
+// with a request other than the one it was first used for.
+var ErrIdempotencyKeyReused = errors.New("idempotency key was already used for a different request")
+
+type NotFoundError struct {
+	Resource string
+	ID       uuid.UUID
+}
+
+func (e *NotFoundError) Error() string {
+	return fmt.Sprintf("%s %s not found", e.Resource, e.ID)
+}
+
+func (e *NotFoundError) Is(target error) bool { return target == ErrNotFound }
+
+type ConflictError struct {
+	Reason string
+}
+
+func (e *ConflictError) Error() string { return e.Reason }
+
+func (e *ConflictError) Is(target error) bool { return target == ErrConflict }
+
+// StaleVersionError is returned when a realization was changed by someone else
+// since the caller read it. Current holds the latest stored state.
+type StaleVersionError struct {
+	Current *ActivityRealization
+}
+
+func (e *StaleVersionError) Error() string {
+	return fmt.Sprintf("activity was modified concurrently: current version is %d", e.Current.Version)
+}
+
+func (e *StaleVersionError) Is(target error) bool { return target == ErrConflict }
+
+type InvalidTransitionError struct {
+	From ActivityStatus
+	To   ActivityStatus
+}
+
+func (e *InvalidTransitionError) Error() string {
+	return fmt.Sprintf("cannot move activity from %s to %s", e.From, e.To)
+}
+
+func (e *InvalidTransitionError) Is(target error) bool { return target == ErrInvalidTransition }
+
+type ValidationError struct {
+	Field  string
+	Reason string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("invalid %s: %s", e.Field, e.Reason)
+}
+
+func (e *ValidationError) Is(target error) bool { return target == ErrValidation }
+
+type ForbiddenError struct {
+	Reason string
+}
+
+func (e *ForbiddenError) Error() string { return e.Reason }
+
+func (e *ForbiddenError) Is(target error) bool { return target == ErrForbidden }
+
+// UnauthorizedError is returned when the request does not say which family it
+// acts for.
+type UnauthorizedError struct {
+	Reason string
+}
+
+func (e *UnauthorizedError) Error() string { return e.Reason }
+
+func (e *UnauthorizedError) Is(target error) bool { return target == ErrUnauthorized }
+
+// TooLargeError is returned when a request body exceeds what the server is
+// willing to read.
+type TooLargeError struct {
+	Limit int64
+}
+
+func (e *TooLargeError) Error() string {
+	return fmt.Sprintf("request body must be at most %d bytes", e.Limit)
+}
+
+func (e *TooLargeError) Is(target error) bool { return target == ErrTooLarge }
@@ -0,0 +1,122 @@
+package domain
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type EventType string
+
+const (
+	EventPlanned   EventType = "planned"
+	EventStarted   EventType = "started"
+	EventPaused    EventType = "paused"
+	EventResumed   EventType = "resumed"
+	EventCompleted EventType = "completed"
+	EventCancelled EventType = "cancelled"
+	EventEdited    EventType = "edited"
+	EventNoted     EventType = "noted"
+)
+
+// RealizationEvent is a single entry in the append-only lifecycle stream of a
+// realization. The realization's current state is a projection of its events.
+type RealizationEvent struct {
+	Sequence      int64     `json:"sequence"`
+	RealizationID uuid.UUID `json:"realization_id"`
+	FamilyID      uuid.UUID `json:"family_id"`
+	Type          EventType `json:"type"`
+	OccurredAt    time.Time `json:"occurred_at"`
+	Data          EventData `json:"data"`
+}
+
+// EventData holds the fields carried by an event. Only the fields relevant to
+// the event type are set; nil fields on an edit mean "unchanged".
+type EventData struct {
+	DefinitionID  *uuid.UUID  `json:"definition_id,omitempty"`
+	EntityID      *uuid.UUID  `json:"entity_id,omitempty"`
+	CaregiversIDs []uuid.UUID `json:"caregiver_ids"`
+	PlannedFor    *time.Time  `json:"planned_for,omitempty"`
+	StartedAt     *time.Time  `json:"started_at,omitempty"`
+	FinishedAt    *time.Time  `json:"finished_at,omitempty"`
+	Note          string      `json:"note,omitempty"`
+}
+
+// Apply folds an event into the realization.
+func (ar *ActivityRealization) Apply(e RealizationEvent) {
+	switch e.Type {
+	case EventPlanned:
+		ar.ID = e.RealizationID
+		ar.FamilyID = e.FamilyID
+		ar.Status = StatusPlanned
+		if e.Data.DefinitionID != nil {
+			ar.DefinitionID = *e.Data.DefinitionID
+		}
+		if e.Data.EntityID != nil {
+			ar.EntityID = *e.Data.EntityID
+		}
+		ar.CaregiversIDs = e.Data.CaregiversIDs
+		ar.PlannedFor = e.Data.PlannedFor
+	case EventStarted:
+		ar.Status = StatusInProgress
+		ar.StartedAt = eventTime(e, e.Data.StartedAt)
+	case EventPaused:
+		ar.Status = StatusPaused
+	case EventResumed:
+		ar.Status = StatusInProgress
+	case EventCompleted:
+		ar.Status = StatusCompleted
+		ar.FinishedAt = eventTime(e, e.Data.FinishedAt)
+	case EventCancelled:
+		ar.Status = StatusCancelled
+		ar.FinishedAt = eventTime(e, e.Data.FinishedAt)
+	case EventEdited:
+		if e.Data.DefinitionID != nil {
+			ar.DefinitionID = *e.Data.DefinitionID
+		}
+		if e.Data.CaregiversIDs != nil {
+			ar.CaregiversIDs = e.Data.CaregiversIDs
+		}
+		if e.Data.StartedAt != nil {
+			ar.StartedAt = e.Data.StartedAt
+		}
+		if e.Data.FinishedAt != nil {
+			ar.FinishedAt = e.Data.FinishedAt
+		}
+	}
+}
+
+// Project rebuilds a realization from its event stream. It returns nil when
+// the stream is empty.
+func Project(events []RealizationEvent) *ActivityRealization {
+	if len(events) == 0 {
+		return nil
+	}
+
+	var ar ActivityRealization
+	for _, e := range events {
+		ar.Apply(e)
+	}
+	return &ar
+}
+
+// ProjectAt rebuilds the realization as it was at the given time, ignoring
+// events that occurred afterwards. It returns nil if the realization did not
+// exist yet.
+func ProjectAt(events []RealizationEvent, at time.Time) *ActivityRealization {
+	var past []RealizationEvent
+	for _, e := range events {
+		if !e.OccurredAt.After(at) {
+			past = append(past, e)
+		}
+	}
+	return Project(past)
+}
+
+func eventTime(e RealizationEvent, t *time.Time) *time.Time {
+	if t != nil {
+		return t
+	}
+	occurredAt := e.OccurredAt
+	return &occurredAt
+}
@@ -2,23 +2,47 @@ package domain
 
 import (
 	"context"
-	"errors"
+	"time"
 
 	"github.com/google/uuid"
 )
 
-var ErrEntityBusy = errors.New("child is already participating in an activity")
-
 type StartActivityInput struct {
 	RealizationID      uuid.UUID
 	EntityID           uuid.UUID
 	DefinitionID       uuid.UUID
 	NewDefinittionName string
 	CaregiversIDs      []uuid.UUID
+	// PlannedFor is when a planned realization is meant to start.
+	PlannedFor      *time.Time
+	ExpectedVersion int
+}
+
+// EditActivityInput corrects the details of a realization. Nil fields are left
+// unchanged.
+type EditActivityInput struct {
+	DefinitionID  *uuid.UUID
+	CaregiversIDs []uuid.UUID
+	StartedAt     *time.Time
+	FinishedAt    *time.Time
 }
 
+// ActivityService mutations take the version of the realization the caller
+// last saw. A non-zero version that is outdated is rejected with a
+// StaleVersionError; zero skips the check.
 type ActivityService interface {
 	StartActivity(ctx context.Context, input StartActivityInput) (*ActivityRealization, error)
-	CompleteActivity(ctx context.Context, realizationID uuid.UUID) error
+	CompleteActivity(ctx context.Context, realizationID uuid.UUID, expectedVersion int) (*ActivityRealization, error)
 	PlanActivity(ctx context.Context, input StartActivityInput) (*ActivityRealization, error)
+	PauseActivity(ctx context.Context, realizationID uuid.UUID, expectedVersion int) (*ActivityRealization, error)
+	ResumeActivity(ctx context.Context, realizationID uuid.UUID, expectedVersion int) (*ActivityRealization, error)
+	CancelActivity(ctx context.Context, realizationID uuid.UUID, expectedVersion int) (*ActivityRealization, error)
+	EditActivity(ctx context.Context, realizationID uuid.UUID, expectedVersion int, input EditActivityInput) (*ActivityRealization, error)
+	// AddNote records a free-text note in the realization's history without
+	// changing its state.
+	AddNote(ctx context.Context, realizationID uuid.UUID, expectedVersion int, text string) (*ActivityRealization, error)
+	GetActivity(ctx context.Context, realizationID uuid.UUID) (*ActivityRealization, error)
+	GetActivityAt(ctx context.Context, realizationID uuid.UUID, at time.Time) (*ActivityRealization, error)
+	GetActivityHistory(ctx context.Context, realizationID uuid.UUID) ([]RealizationEvent, error)
+	RebuildActivity(ctx context.Context, realizationID uuid.UUID) (*ActivityRealization, error)
 }
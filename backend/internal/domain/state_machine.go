@@ -0,0 +1,126 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// transitions declares every allowed status change and the event recording
+// it. Realizations are created planned; completed and cancelled are final.
+var transitions = map[ActivityStatus]map[ActivityStatus]EventType{
+	StatusPlanned: {
+		StatusInProgress: EventStarted,
+		StatusCancelled:  EventCancelled,
+	},
+	StatusInProgress: {
+		StatusPaused:    EventPaused,
+		StatusCompleted: EventCompleted,
+		StatusCancelled: EventCancelled,
+	},
+	StatusPaused: {
+		StatusInProgress: EventResumed,
+		StatusCompleted:  EventCompleted,
+		StatusCancelled:  EventCancelled,
+	},
+}
+
+// CanTransition reports whether a realization may move from one status to
+// another.
+func CanTransition(from, to ActivityStatus) bool {
+	_, ok := transitions[from][to]
+	return ok
+}
+
+// StateChange describes a transition as seen by hooks. Before hooks see the
+// realization in its old state, after hooks a copy of its new, committed
+// state.
+type StateChange struct {
+	Realization *ActivityRealization
+	From        ActivityStatus
+	To          ActivityStatus
+	Event       RealizationEvent
+}
+
+// BeforeHook runs before a transition is applied. Returning an error vetoes
+// the transition.
+type BeforeHook func(ctx context.Context, change StateChange) error
+
+// AfterHook runs once a transition has been saved and the unit of work that
+// saved it has committed. A transition that rolls back never runs it.
+type AfterHook func(ctx context.Context, change StateChange)
+
+// AfterCommitFunc defers fn until the unit of work carried by ctx commits, or
+// runs it at once when there is none.
+type AfterCommitFunc func(ctx context.Context, fn func())
+
+// StateMachine applies status transitions to realizations. Hooks must be
+// registered before the machine is used concurrently.
+type StateMachine struct {
+	before      []BeforeHook
+	after       []AfterHook
+	afterCommit AfterCommitFunc
+}
+
+// NewStateMachine returns a machine that hands its after hooks to
+// afterCommit.
+func NewStateMachine(afterCommit AfterCommitFunc) *StateMachine {
+	return &StateMachine{afterCommit: afterCommit}
+}
+
+func (m *StateMachine) Before(hook BeforeHook) {
+	m.before = append(m.before, hook)
+}
+
+func (m *StateMachine) After(hook AfterHook) {
+	m.after = append(m.after, hook)
+}
+
+// Transition moves the realization to the given status at the given time. It
+// validates the move against the transition table, runs the before hooks,
+// applies the resulting event (stamping StartedAt or FinishedAt), persists it
+// through save and finally schedules the after hooks for when the unit of work
+// in ctx commits.
+func (m *StateMachine) Transition(ctx context.Context, ar *ActivityRealization, to ActivityStatus, at time.Time,
+	save func(ctx context.Context, event RealizationEvent) error) (RealizationEvent, error) {
+	eventType, ok := transitions[ar.Status][to]
+	if !ok {
+		return RealizationEvent{}, &InvalidTransitionError{From: ar.Status, To: to}
+	}
+
+	change := StateChange{
+		Realization: ar,
+		From:        ar.Status,
+		To:          to,
+		Event: RealizationEvent{
+			RealizationID: ar.ID,
+			FamilyID:      ar.FamilyID,
+			Type:          eventType,
+			OccurredAt:    at,
+		},
+	}
+
+	for _, hook := range m.before {
+		if err := hook(ctx, change); err != nil {
+			return RealizationEvent{}, err
+		}
+	}
+
+	previous := *ar
+	ar.Apply(change.Event)
+
+	if err := save(ctx, change.Event); err != nil {
+		*ar = previous
+		return RealizationEvent{}, err
+	}
+
+	if len(m.after) > 0 {
+		saved := *ar
+		change.Realization = &saved
+		m.afterCommit(ctx, func() {
+			for _, hook := range m.after {
+				hook(ctx, change)
+			}
+		})
+	}
+	return change.Event, nil
+}
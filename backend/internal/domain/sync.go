@@ -0,0 +1,67 @@
+package domain
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Entity is someone activities are recorded for, usually a child.
+type Entity struct {
+	ID          uuid.UUID  `json:"id"`
+	FamilyID    uuid.UUID  `json:"family_id"`
+	Name        string     `json:"name"`
+	DateOfBirth *time.Time `json:"date_of_birth"`
+}
+
+type ChangeKind string
+
+const (
+	ChangeRealization ChangeKind = "realization"
+	ChangeDefinition  ChangeKind = "definition"
+	ChangeEntity      ChangeKind = "entity"
+)
+
+// Change is the latest change to one object, numbered in the order changes
+// were committed. Only the object's current state is kept, so an object
+// changed several times appears once, with its last sequence. Exactly one of
+// Realization, Definition and Entity is set, unless the object was deleted.
+type Change struct {
+	Sequence int64
+	Kind     ChangeKind
+	ID       uuid.UUID
+	Deleted  bool
+
+	Realization *ActivityRealization
+	Definition  *ActivityDefinition
+	Entity      *Entity
+}
+
+// Tombstone marks an object that was deleted.
+type Tombstone struct {
+	Kind ChangeKind `json:"type"`
+	ID   uuid.UUID  `json:"id"`
+}
+
+// SyncPage is what changed after a cursor, with the cursor to ask from next.
+type SyncPage struct {
+	Realizations []ActivityRealization
+	Definitions  []ActivityDefinition
+	Entities     []Entity
+	Deleted      []Tombstone
+
+	Cursor  string
+	HasMore bool
+	// Reset tells the client its cursor is unknown, for example because the
+	// server's data was restored, and that the page starts from scratch. It
+	// should drop its replica before applying the page.
+	Reset bool
+}
+
+// SyncService lets clients keep a replica of their family's data by asking
+// for what changed since the cursor of their last sync. An empty cursor asks
+// for everything.
+type SyncService interface {
+	Changes(ctx context.Context, cursor string, limit int) (*SyncPage, error)
+}
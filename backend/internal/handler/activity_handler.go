@@ -1,14 +1,15 @@
 package handler
 
 import (
+	"context"
 	"encoding/json"
-	"errors"
-	"log"
 	"net/http"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
 	"github.com/luisteixeira/waypoint/backend/internal/domain"
+	"github.com/luisteixeira/waypoint/backend/internal/problem"
 )
 
 type ActivityRequest struct {
@@ -17,6 +18,14 @@ type ActivityRequest struct {
 	DefinitionID       *uuid.UUID  `json:"definition_id,omitempty"`
 	NewDefinittionName string      `json:"new_definition_name,omitempty"`
 	CaregiverIDs       []uuid.UUID `json:"caregiver_ids"`
+	PlannedFor         *time.Time  `json:"planned_for,omitempty"`
+}
+
+type EditActivityRequest struct {
+	DefinitionID *uuid.UUID  `json:"definition_id,omitempty"`
+	CaregiverIDs []uuid.UUID `json:"caregiver_ids,omitempty"`
+	StartedAt    *time.Time  `json:"started_at,omitempty"`
+	FinishedAt   *time.Time  `json:"finished_at,omitempty"`
 }
 
 type ActivityHandler struct {
@@ -27,105 +36,224 @@ func NewActivityHandler(service domain.ActivityService) *ActivityHandler {
 	return &ActivityHandler{service: service}
 }
 
-func (h *ActivityHandler) PlanActivity(w http.ResponseWriter, r *http.Request) {
-	var activityRequest ActivityRequest
+// Routes mounts the activity endpoints under /activities. The OpenAPI
+// description in internal/openapi must list every route added here.
+func (h *ActivityHandler) Routes(r chi.Router) {
+	r.Route("/activities", func(r chi.Router) {
+		r.Post("/plan", h.PlanActivity)
+		r.Post("/start", h.StartActivity)
+		r.Get("/{id}", h.GetActivity)
+		r.Patch("/{id}", h.EditActivity)
+		r.Get("/{id}/history", h.GetActivityHistory)
+		r.Post("/{id}/complete", h.CompleteActivity)
+		r.Post("/{id}/pause", h.PauseActivity)
+		r.Post("/{id}/resume", h.ResumeActivity)
+		r.Post("/{id}/cancel", h.CancelActivity)
+		r.Post("/{id}/rebuild", h.RebuildActivity)
+	})
+}
 
-	if err := decodeRequest(r, &activityRequest); err != nil {
-		renderError(w, "invalid request data", http.StatusBadRequest)
+func (h *ActivityHandler) PlanActivity(w http.ResponseWriter, r *http.Request) {
+	input, err := decodeActivityInput(r)
+	if err != nil {
+		problem.Render(w, r, err)
 		return
 	}
 
-	input := domain.StartActivityInput{
-		EntityID:           activityRequest.EntityID,
-		NewDefinittionName: activityRequest.NewDefinittionName,
-		CaregiversIDs:      activityRequest.CaregiverIDs,
+	activityRealization, err := h.service.PlanActivity(r.Context(), input)
+	if err != nil {
+		problem.Render(w, r, err)
+		return
 	}
 
-	if activityRequest.RealizationID != nil {
-		input.RealizationID = *activityRequest.RealizationID
-	}
-	if activityRequest.DefinitionID != nil {
-		input.DefinitionID = *activityRequest.DefinitionID
+	setETag(w, activityRealization)
+	renderJSON(w, http.StatusCreated, activityRealization)
+}
+
+func (h *ActivityHandler) StartActivity(w http.ResponseWriter, r *http.Request) {
+	input, err := decodeActivityInput(r)
+	if err != nil {
+		problem.Render(w, r, err)
+		return
 	}
 
-	activityRealization, err := h.service.PlanActivity(r.Context(), input)
+	activityRealization, err := h.service.StartActivity(r.Context(), input)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		problem.Render(w, r, err)
 		return
 	}
 
+	setETag(w, activityRealization)
 	renderJSON(w, http.StatusCreated, activityRealization)
 }
 
-func (h *ActivityHandler) StartActivity(w http.ResponseWriter, r *http.Request) {
-	var activityRequest ActivityRequest
+func (h *ActivityHandler) CompleteActivity(w http.ResponseWriter, r *http.Request) {
+	h.transition(w, r, h.service.CompleteActivity)
+}
 
-	if err := decodeRequest(r, &activityRequest); err != nil {
-		renderError(w, "invalid request data", http.StatusBadRequest)
+func (h *ActivityHandler) PauseActivity(w http.ResponseWriter, r *http.Request) {
+	h.transition(w, r, h.service.PauseActivity)
+}
+
+func (h *ActivityHandler) ResumeActivity(w http.ResponseWriter, r *http.Request) {
+	h.transition(w, r, h.service.ResumeActivity)
+}
+
+func (h *ActivityHandler) CancelActivity(w http.ResponseWriter, r *http.Request) {
+	h.transition(w, r, h.service.CancelActivity)
+}
+
+func (h *ActivityHandler) EditActivity(w http.ResponseWriter, r *http.Request) {
+	id, err := parseRealizationID(r)
+	if err != nil {
+		problem.Render(w, r, err)
 		return
 	}
 
-	input := domain.StartActivityInput{
-		EntityID:           activityRequest.EntityID,
-		NewDefinittionName: activityRequest.NewDefinittionName,
-		CaregiversIDs:      activityRequest.CaregiverIDs,
+	expectedVersion, err := parseIfMatch(r)
+	if err != nil {
+		problem.Render(w, r, err)
+		return
 	}
 
-	if activityRequest.RealizationID != nil {
-		input.RealizationID = *activityRequest.RealizationID
+	var editRequest EditActivityRequest
+	if err := decodeRequest(r, &editRequest); err != nil {
+		problem.Render(w, r, err)
+		return
 	}
-	if activityRequest.DefinitionID != nil {
-		input.DefinitionID = *activityRequest.DefinitionID
+
+	activityRealization, err := h.service.EditActivity(r.Context(), id, expectedVersion, domain.EditActivityInput{
+		DefinitionID:  editRequest.DefinitionID,
+		CaregiversIDs: editRequest.CaregiverIDs,
+		StartedAt:     editRequest.StartedAt,
+		FinishedAt:    editRequest.FinishedAt,
+	})
+	if err != nil {
+		problem.Render(w, r, err)
+		return
 	}
 
-	activityRealization, err := h.service.StartActivity(r.Context(), input)
+	setETag(w, activityRealization)
+	renderJSON(w, http.StatusOK, activityRealization)
+}
+
+// GetActivity returns the current state of a realization, or its state at the
+// time given by the "at" query parameter (RFC 3339).
+func (h *ActivityHandler) GetActivity(w http.ResponseWriter, r *http.Request) {
+	id, err := parseRealizationID(r)
 	if err != nil {
-		if errors.Is(err, domain.ErrEntityBusy) {
-			renderError(w, err.Error(), http.StatusConflict)
+		problem.Render(w, r, err)
+		return
+	}
+
+	var activityRealization *domain.ActivityRealization
+	if atStr := r.URL.Query().Get("at"); atStr != "" {
+		at, err := time.Parse(time.RFC3339, atStr)
+		if err != nil {
+			problem.Render(w, r, &domain.ValidationError{Field: "at", Reason: "must be an RFC 3339 timestamp"})
 			return
 		}
+		activityRealization, err = h.service.GetActivityAt(r.Context(), id, at)
+	} else {
+		activityRealization, err = h.service.GetActivity(r.Context(), id)
+	}
+	if err != nil {
+		problem.Render(w, r, err)
+		return
+	}
 
-		if err.Error() == "not found" {
-			renderError(w, "planned activity not found", http.StatusNotFound)
-		}
+	setETag(w, activityRealization)
+	renderJSON(w, http.StatusOK, activityRealization)
+}
 
-		log.Printf("StartActivity Error: %v", err)
-		http.Error(w, "internal server error", http.StatusInternalServerError)
+func (h *ActivityHandler) GetActivityHistory(w http.ResponseWriter, r *http.Request) {
+	id, err := parseRealizationID(r)
+	if err != nil {
+		problem.Render(w, r, err)
 		return
 	}
 
-	renderJSON(w, http.StatusCreated, activityRealization)
+	events, err := h.service.GetActivityHistory(r.Context(), id)
+	if err != nil {
+		problem.Render(w, r, err)
+		return
+	}
+
+	renderJSON(w, http.StatusOK, events)
 }
 
-func (h *ActivityHandler) CompleteActivity(w http.ResponseWriter, r *http.Request) {
-	idStr := chi.URLParam(r, "id")
-	id, err := uuid.Parse(idStr)
+func (h *ActivityHandler) RebuildActivity(w http.ResponseWriter, r *http.Request) {
+	id, err := parseRealizationID(r)
 	if err != nil {
-		renderError(w, "invalid activity id", http.StatusBadRequest)
+		problem.Render(w, r, err)
 		return
 	}
 
-	err = h.service.CompleteActivity(r.Context(), id)
+	activityRealization, err := h.service.RebuildActivity(r.Context(), id)
 	if err != nil {
-		if err.Error() == "not found" {
-			renderError(w, "activity not found", http.StatusNotFound)
-			return
-		}
+		problem.Render(w, r, err)
+		return
+	}
+
+	setETag(w, activityRealization)
+	renderJSON(w, http.StatusOK, activityRealization)
+}
+
+func (h *ActivityHandler) transition(w http.ResponseWriter, r *http.Request,
+	apply func(ctx context.Context, id uuid.UUID, expectedVersion int) (*domain.ActivityRealization, error)) {
+	id, err := parseRealizationID(r)
+	if err != nil {
+		problem.Render(w, r, err)
+		return
+	}
+
+	expectedVersion, err := parseIfMatch(r)
+	if err != nil {
+		problem.Render(w, r, err)
+		return
+	}
 
-		log.Printf("CompletedActivity Error: %v", err)
-		renderError(w, err.Error(), http.StatusBadRequest)
+	activityRealization, err := apply(r.Context(), id, expectedVersion)
+	if err != nil {
+		problem.Render(w, r, err)
 		return
 	}
 
+	setETag(w, activityRealization)
 	w.WriteHeader(http.StatusNoContent)
 }
 
+func decodeActivityInput(r *http.Request) (domain.StartActivityInput, error) {
+	var activityRequest ActivityRequest
+
+	if err := decodeRequest(r, &activityRequest); err != nil {
+		return domain.StartActivityInput{}, err
+	}
+
+	expectedVersion, err := parseIfMatch(r)
+	if err != nil {
+		return domain.StartActivityInput{}, err
+	}
+
+	input := domain.StartActivityInput{
+		EntityID:           activityRequest.EntityID,
+		NewDefinittionName: activityRequest.NewDefinittionName,
+		CaregiversIDs:      activityRequest.CaregiverIDs,
+		PlannedFor:         activityRequest.PlannedFor,
+		ExpectedVersion:    expectedVersion,
+	}
+
+	if activityRequest.RealizationID != nil {
+		input.RealizationID = *activityRequest.RealizationID
+	}
+	if activityRequest.DefinitionID != nil {
+		input.DefinitionID = *activityRequest.DefinitionID
+	}
+	return input, nil
+}
+
 func renderJSON(w http.ResponseWriter, status int, data interface{}) {
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(status)
 	json.NewEncoder(w).Encode(data)
 }
-
-func renderError(w http.ResponseWriter, message string, status int) {
-	renderJSON(w, status, map[string]string{"error": message})
-}
@@ -0,0 +1,196 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/luisteixeira/waypoint/backend/internal/alert"
+	"github.com/luisteixeira/waypoint/backend/internal/domain"
+	"github.com/luisteixeira/waypoint/backend/internal/problem"
+)
+
+type AlertRuleRequest struct {
+	Kind         alert.Kind      `json:"kind"`
+	DefinitionID uuid.UUID       `json:"definition_id"`
+	EntityID     *uuid.UUID      `json:"entity_id"`
+	Threshold    int             `json:"threshold_minutes"`
+	Channels     []alert.Channel `json:"channels"`
+	Email        string          `json:"email"`
+}
+
+type AlertRuleResponse struct {
+	ID           uuid.UUID       `json:"id"`
+	Kind         alert.Kind      `json:"kind"`
+	DefinitionID uuid.UUID       `json:"definition_id"`
+	EntityID     *uuid.UUID      `json:"entity_id"`
+	Threshold    int             `json:"threshold_minutes"`
+	Channels     []alert.Channel `json:"channels"`
+	Email        string          `json:"email,omitempty"`
+	CreatedAt    time.Time       `json:"created_at"`
+}
+
+type SnoozeRequest struct {
+	Until time.Time `json:"until"`
+}
+
+type AlertHandler struct {
+	alerts *alert.Service
+}
+
+func NewAlertHandler(alerts *alert.Service) *AlertHandler {
+	return &AlertHandler{alerts: alerts}
+}
+
+// Routes mounts the alert endpoints. The OpenAPI description in
+// internal/openapi must list every route added here.
+func (h *AlertHandler) Routes(r chi.Router) {
+	r.Post("/alert-rules", h.CreateRule)
+	r.Get("/alert-rules", h.ListRules)
+	r.Delete("/alert-rules/{id}", h.DeleteRule)
+	r.Get("/alerts", h.ListAlerts)
+	r.Post("/alerts/{id}/snooze", h.Snooze)
+	r.Post("/alerts/{id}/acknowledge", h.Acknowledge)
+}
+
+func (h *AlertHandler) CreateRule(w http.ResponseWriter, r *http.Request) {
+	var req AlertRuleRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		problem.Render(w, r, &domain.ValidationError{Field: "body", Reason: err.Error()})
+		return
+	}
+
+	input := alert.RuleInput{
+		Kind:         req.Kind,
+		DefinitionID: req.DefinitionID,
+		Threshold:    time.Duration(req.Threshold) * time.Minute,
+		Channels:     req.Channels,
+		Email:        req.Email,
+	}
+	if req.EntityID != nil {
+		input.EntityID = *req.EntityID
+	}
+	rule, err := h.alerts.CreateRule(r.Context(), input)
+	if err != nil {
+		problem.Render(w, r, err)
+		return
+	}
+
+	w.Header().Set("Location", "/api/v1/alert-rules/"+rule.ID.String())
+	renderJSON(w, http.StatusCreated, toAlertRuleResponse(*rule))
+}
+
+func (h *AlertHandler) ListRules(w http.ResponseWriter, r *http.Request) {
+	rules, err := h.alerts.Rules(r.Context())
+	if err != nil {
+		problem.Render(w, r, err)
+		return
+	}
+
+	res := make([]AlertRuleResponse, len(rules))
+	for i, rule := range rules {
+		res[i] = toAlertRuleResponse(rule)
+	}
+	renderJSON(w, http.StatusOK, res)
+}
+
+func (h *AlertHandler) DeleteRule(w http.ResponseWriter, r *http.Request) {
+	id, err := parseUUIDParam(r, "id", "invalid alert rule id")
+	if err != nil {
+		problem.Render(w, r, err)
+		return
+	}
+
+	if err := h.alerts.DeleteRule(r.Context(), id); err != nil {
+		problem.Render(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListAlerts returns the family's alerts, most recently triggered first. The
+// "status" query parameter is a comma-separated list of statuses, the
+// unresolved ones by default, and "limit" caps the number returned.
+func (h *AlertHandler) ListAlerts(w http.ResponseWriter, r *http.Request) {
+	var statuses []alert.Status
+	if value := r.URL.Query().Get("status"); value != "" {
+		for _, s := range strings.Split(value, ",") {
+			statuses = append(statuses, alert.Status(s))
+		}
+	}
+
+	var limit int
+	if value := r.URL.Query().Get("limit"); value != "" {
+		var err error
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit <= 0 {
+			problem.Render(w, r, &domain.ValidationError{Field: "limit", Reason: "must be a positive integer"})
+			return
+		}
+	}
+
+	alerts, err := h.alerts.Alerts(r.Context(), statuses, limit)
+	if err != nil {
+		problem.Render(w, r, err)
+		return
+	}
+	renderJSON(w, http.StatusOK, alerts)
+}
+
+func (h *AlertHandler) Snooze(w http.ResponseWriter, r *http.Request) {
+	id, err := parseUUIDParam(r, "id", "invalid alert id")
+	if err != nil {
+		problem.Render(w, r, err)
+		return
+	}
+	var req SnoozeRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		problem.Render(w, r, &domain.ValidationError{Field: "body", Reason: err.Error()})
+		return
+	}
+
+	a, err := h.alerts.Snooze(r.Context(), id, req.Until)
+	if err != nil {
+		problem.Render(w, r, err)
+		return
+	}
+	renderJSON(w, http.StatusOK, a)
+}
+
+func (h *AlertHandler) Acknowledge(w http.ResponseWriter, r *http.Request) {
+	id, err := parseUUIDParam(r, "id", "invalid alert id")
+	if err != nil {
+		problem.Render(w, r, err)
+		return
+	}
+
+	a, err := h.alerts.Acknowledge(r.Context(), id)
+	if err != nil {
+		problem.Render(w, r, err)
+		return
+	}
+	renderJSON(w, http.StatusOK, a)
+}
+
+func toAlertRuleResponse(rule alert.Rule) AlertRuleResponse {
+	res := AlertRuleResponse{
+		ID:           rule.ID,
+		Kind:         rule.Kind,
+		DefinitionID: rule.DefinitionID,
+		Threshold:    int(rule.Threshold / time.Minute),
+		Channels:     rule.Channels,
+		Email:        rule.Email,
+		CreatedAt:    rule.CreatedAt,
+	}
+	if rule.EntityID != uuid.Nil {
+		res.EntityID = &rule.EntityID
+	}
+	if res.Channels == nil {
+		res.Channels = []alert.Channel{}
+	}
+	return res
+}
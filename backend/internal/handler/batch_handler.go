@@ -0,0 +1,131 @@
+package handler
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/luisteixeira/waypoint/backend/internal/domain"
+	"github.com/luisteixeira/waypoint/backend/internal/problem"
+)
+
+type BatchRequest struct {
+	// Atomic applies every operation or none of them.
+	Atomic     bool                    `json:"atomic"`
+	Operations []BatchOperationRequest `json:"operations"`
+}
+
+type BatchOperationRequest struct {
+	ID              string                    `json:"id"`
+	Type            domain.BatchOperationType `json:"type"`
+	ClientTimestamp time.Time                 `json:"client_timestamp"`
+
+	RealizationID *uuid.UUID `json:"realization_id,omitempty"`
+	Ref           string     `json:"ref,omitempty"`
+	Version       int        `json:"version,omitempty"`
+
+	EntityID           uuid.UUID   `json:"entity_id"`
+	DefinitionID       *uuid.UUID  `json:"definition_id,omitempty"`
+	NewDefinittionName string      `json:"new_definition_name,omitempty"`
+	CaregiverIDs       []uuid.UUID `json:"caregiver_ids,omitempty"`
+
+	Text string `json:"text,omitempty"`
+}
+
+type BatchResponse struct {
+	Results []BatchResultResponse `json:"results"`
+}
+
+type BatchResultResponse struct {
+	ID              string                      `json:"id"`
+	Type            domain.BatchOperationType   `json:"type"`
+	ClientTimestamp time.Time                   `json:"client_timestamp"`
+	Status          domain.BatchStatus          `json:"status"`
+	Realization     *domain.ActivityRealization `json:"realization,omitempty"`
+	Error           *problem.Problem            `json:"error,omitempty"`
+}
+
+type BatchHandler struct {
+	service domain.BatchService
+}
+
+func NewBatchHandler(service domain.BatchService) *BatchHandler {
+	return &BatchHandler{service: service}
+}
+
+// Routes mounts the batch endpoint. The OpenAPI description in
+// internal/openapi must list every route added here.
+func (h *BatchHandler) Routes(r chi.Router) {
+	r.Post("/batch", h.ApplyBatch)
+}
+
+// ApplyBatch applies the operations a client queued while offline. The
+// response is 200 whenever the batch was well formed, with the outcome of
+// each operation in its result.
+func (h *BatchHandler) ApplyBatch(w http.ResponseWriter, r *http.Request) {
+	var batchRequest BatchRequest
+	if err := decodeRequest(r, &batchRequest); err != nil {
+		problem.Render(w, r, err)
+		return
+	}
+
+	ops := make([]domain.BatchOperation, len(batchRequest.Operations))
+	for i, op := range batchRequest.Operations {
+		ops[i] = op.toDomain()
+	}
+
+	results, err := h.service.ApplyBatch(r.Context(), ops, batchRequest.Atomic)
+	if err != nil {
+		problem.Render(w, r, err)
+		return
+	}
+
+	response := BatchResponse{Results: make([]BatchResultResponse, len(results))}
+	for i, result := range results {
+		response.Results[i] = BatchResultResponse{
+			ID:              result.ID,
+			Type:            result.Type,
+			ClientTimestamp: result.ClientTimestamp,
+			Status:          result.Status,
+			Realization:     result.Realization,
+		}
+		if result.Err != nil {
+			p := problem.From(result.Err)
+			if p.Status == http.StatusInternalServerError {
+				slog.ErrorContext(r.Context(), "batch operation failed", "operation", result.ID, "error", result.Err)
+			}
+			response.Results[i].Error = &p
+		}
+	}
+	renderJSON(w, http.StatusOK, response)
+}
+
+func (op BatchOperationRequest) toDomain() domain.BatchOperation {
+	operation := domain.BatchOperation{
+		ID:              op.ID,
+		Type:            op.Type,
+		ClientTimestamp: op.ClientTimestamp,
+		Ref:             op.Ref,
+		ExpectedVersion: op.Version,
+		Note:            op.Text,
+		Start: domain.StartActivityInput{
+			EntityID:           op.EntityID,
+			NewDefinittionName: op.NewDefinittionName,
+			CaregiversIDs:      op.CaregiverIDs,
+		},
+	}
+	if op.RealizationID != nil {
+		operation.RealizationID = *op.RealizationID
+		if op.Type == domain.BatchStart {
+			// Starts a planned realization.
+			operation.Start.RealizationID = *op.RealizationID
+			operation.Start.ExpectedVersion = op.Version
+		}
+	}
+	if op.DefinitionID != nil {
+		operation.Start.DefinitionID = *op.DefinitionID
+	}
+	return operation
+}
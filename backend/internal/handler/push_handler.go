@@ -0,0 +1,169 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/luisteixeira/waypoint/backend/internal/domain"
+	"github.com/luisteixeira/waypoint/backend/internal/problem"
+	"github.com/luisteixeira/waypoint/backend/internal/push"
+)
+
+// PushSubscriptionRequest is the JSON form of a browser's PushSubscription.
+type PushSubscriptionRequest struct {
+	Endpoint string `json:"endpoint"`
+	Keys     struct {
+		P256DH string `json:"p256dh"`
+		Auth   string `json:"auth"`
+	} `json:"keys"`
+}
+
+type PushSubscriptionResponse struct {
+	ID        uuid.UUID `json:"id"`
+	Endpoint  string    `json:"endpoint"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type PushPreferencesRequest struct {
+	EntityIDs         []uuid.UUID `json:"entity_ids"`
+	ActivityStarted   bool        `json:"activity_started"`
+	ActivityCompleted bool        `json:"activity_completed"`
+	Alerts            bool        `json:"alerts"`
+}
+
+type PushPreferencesResponse struct {
+	EntityIDs         []uuid.UUID `json:"entity_ids"`
+	ActivityStarted   bool        `json:"activity_started"`
+	ActivityCompleted bool        `json:"activity_completed"`
+	Alerts            bool        `json:"alerts"`
+	UpdatedAt         *time.Time  `json:"updated_at"`
+}
+
+type PushHandler struct {
+	push *push.Service
+}
+
+func NewPushHandler(push *push.Service) *PushHandler {
+	return &PushHandler{push: push}
+}
+
+// Routes mounts the push endpoints. The OpenAPI description in
+// internal/openapi must list every route added here.
+func (h *PushHandler) Routes(r chi.Router) {
+	r.Get("/push/key", h.PublicKey)
+	r.Post("/push/subscriptions", h.Subscribe)
+	r.Get("/push/subscriptions", h.ListSubscriptions)
+	r.Delete("/push/subscriptions/{id}", h.Unsubscribe)
+	r.Get("/push/preferences", h.GetPreferences)
+	r.Put("/push/preferences", h.SetPreferences)
+}
+
+// PublicKey returns the VAPID key browsers pass to pushManager.subscribe as
+// their applicationServerKey.
+func (h *PushHandler) PublicKey(w http.ResponseWriter, r *http.Request) {
+	renderJSON(w, http.StatusOK, map[string]string{"public_key": h.push.PublicKey()})
+}
+
+func (h *PushHandler) Subscribe(w http.ResponseWriter, r *http.Request) {
+	var req PushSubscriptionRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		problem.Render(w, r, &domain.ValidationError{Field: "body", Reason: err.Error()})
+		return
+	}
+
+	sub, err := h.push.Subscribe(r.Context(), push.SubscriptionInput{
+		Endpoint: req.Endpoint,
+		P256DH:   req.Keys.P256DH,
+		Auth:     req.Keys.Auth,
+	})
+	if err != nil {
+		problem.Render(w, r, err)
+		return
+	}
+
+	w.Header().Set("Location", "/api/v1/push/subscriptions/"+sub.ID.String())
+	renderJSON(w, http.StatusCreated, toPushSubscriptionResponse(*sub))
+}
+
+func (h *PushHandler) ListSubscriptions(w http.ResponseWriter, r *http.Request) {
+	subs, err := h.push.Subscriptions(r.Context())
+	if err != nil {
+		problem.Render(w, r, err)
+		return
+	}
+
+	res := make([]PushSubscriptionResponse, len(subs))
+	for i, sub := range subs {
+		res[i] = toPushSubscriptionResponse(sub)
+	}
+	renderJSON(w, http.StatusOK, res)
+}
+
+func (h *PushHandler) Unsubscribe(w http.ResponseWriter, r *http.Request) {
+	id, err := parseUUIDParam(r, "id", "invalid push subscription id")
+	if err != nil {
+		problem.Render(w, r, err)
+		return
+	}
+
+	if err := h.push.Unsubscribe(r.Context(), id); err != nil {
+		problem.Render(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *PushHandler) GetPreferences(w http.ResponseWriter, r *http.Request) {
+	prefs, err := h.push.Preferences(r.Context())
+	if err != nil {
+		problem.Render(w, r, err)
+		return
+	}
+	renderJSON(w, http.StatusOK, toPushPreferencesResponse(*prefs))
+}
+
+func (h *PushHandler) SetPreferences(w http.ResponseWriter, r *http.Request) {
+	var req PushPreferencesRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		problem.Render(w, r, &domain.ValidationError{Field: "body", Reason: err.Error()})
+		return
+	}
+
+	prefs, err := h.push.SetPreferences(r.Context(), push.PreferencesInput{
+		EntityIDs:         req.EntityIDs,
+		ActivityStarted:   req.ActivityStarted,
+		ActivityCompleted: req.ActivityCompleted,
+		Alerts:            req.Alerts,
+	})
+	if err != nil {
+		problem.Render(w, r, err)
+		return
+	}
+	renderJSON(w, http.StatusOK, toPushPreferencesResponse(*prefs))
+}
+
+func toPushSubscriptionResponse(sub push.Subscription) PushSubscriptionResponse {
+	return PushSubscriptionResponse{ID: sub.ID, Endpoint: sub.Endpoint, CreatedAt: sub.CreatedAt}
+}
+
+// toPushPreferencesResponse leaves updated_at null for the defaults of a
+// caregiver who has not saved any preferences.
+func toPushPreferencesResponse(prefs push.Preferences) PushPreferencesResponse {
+	res := PushPreferencesResponse{
+		EntityIDs:         prefs.EntityIDs,
+		ActivityStarted:   prefs.ActivityStarted,
+		ActivityCompleted: prefs.ActivityCompleted,
+		Alerts:            prefs.Alerts,
+	}
+	if res.EntityIDs == nil {
+		res.EntityIDs = []uuid.UUID{}
+	}
+	if !prefs.UpdatedAt.IsZero() {
+		updatedAt := prefs.UpdatedAt
+		res.UpdatedAt = &updatedAt
+	}
+	return res
+}
@@ -2,34 +2,110 @@ package handler
 
 import (
 	"encoding/json"
+	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
+	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
+	"github.com/luisteixeira/waypoint/backend/internal/domain"
 )
 
 func decodeRequest(r *http.Request, dst interface{}) error {
 	contentType := r.Header.Get("Content-Type")
 
 	if strings.Contains(contentType, "application/json") {
-		return json.NewDecoder(r.Body).Decode(dst)
+		if err := json.NewDecoder(r.Body).Decode(dst); err != nil {
+			return &domain.ValidationError{Field: "body", Reason: err.Error()}
+		}
+		return nil
 	}
 
 	if err := r.ParseForm(); err != nil {
-		return err
+		return &domain.ValidationError{Field: "body", Reason: err.Error()}
 	}
 
 	if request, ok := dst.(*ActivityRequest); ok {
 		if val := r.FormValue("entity_id"); val != "" {
-			id, _ := uuid.Parse(val)
+			id, err := parseFormUUID("entity_id", val)
+			if err != nil {
+				return err
+			}
 			request.EntityID = id
 		}
 		if val := r.FormValue("realization_id"); val != "" {
-			id, _ := uuid.Parse(val)
+			id, err := parseFormUUID("realization_id", val)
+			if err != nil {
+				return err
+			}
 			request.RealizationID = &id
 		}
+		if val := r.FormValue("definition_id"); val != "" {
+			id, err := parseFormUUID("definition_id", val)
+			if err != nil {
+				return err
+			}
+			request.DefinitionID = &id
+		}
+		for _, val := range r.Form["caregiver_ids"] {
+			id, err := parseFormUUID("caregiver_ids", val)
+			if err != nil {
+				return err
+			}
+			request.CaregiverIDs = append(request.CaregiverIDs, id)
+		}
+		if val := r.FormValue("planned_for"); val != "" {
+			plannedFor, err := time.Parse(time.RFC3339, val)
+			if err != nil {
+				return &domain.ValidationError{Field: "planned_for", Reason: "must be an RFC 3339 date-time"}
+			}
+			request.PlannedFor = &plannedFor
+		}
 		request.NewDefinittionName = r.FormValue("new_definition_name")
 	}
 
 	return nil
 }
+
+func parseFormUUID(field, value string) (uuid.UUID, error) {
+	id, err := uuid.Parse(value)
+	if err != nil {
+		return uuid.Nil, &domain.ValidationError{Field: field, Reason: "must be a UUID"}
+	}
+	return id, nil
+}
+
+// parseIfMatch reads the realization version from an If-Match header. It
+// returns 0 when the header is absent or "*", meaning no precondition.
+func parseIfMatch(r *http.Request) (int, error) {
+	value := strings.TrimSpace(r.Header.Get("If-Match"))
+	if value == "" || value == "*" {
+		return 0, nil
+	}
+
+	value = strings.TrimPrefix(value, "W/")
+	version, err := strconv.Atoi(strings.Trim(value, `"`))
+	if err != nil || version <= 0 {
+		return 0, &domain.ValidationError{Field: "If-Match", Reason: "must be an ETag returned by the API"}
+	}
+	return version, nil
+}
+
+func parseRealizationID(r *http.Request) (uuid.UUID, error) {
+	id, err := uuid.Parse(chi.URLParam(r, "id"))
+	if err != nil {
+		return uuid.Nil, &domain.ValidationError{Field: "id", Reason: "invalid activity id"}
+	}
+	return id, nil
+}
+
+// setETag exposes the realization version so clients can send it back in
+// If-Match. Projections without a stored version get no ETag.
+func setETag(w http.ResponseWriter, activityRealization *domain.ActivityRealization) {
+	if activityRealization.Version == 0 {
+		return
+	}
+	w.Header().Set("ETag", fmt.Sprintf(`"%d"`, activityRealization.Version))
+}
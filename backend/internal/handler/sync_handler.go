@@ -0,0 +1,65 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/luisteixeira/waypoint/backend/internal/domain"
+	"github.com/luisteixeira/waypoint/backend/internal/problem"
+)
+
+type SyncResponse struct {
+	Cursor       string                       `json:"cursor"`
+	HasMore      bool                         `json:"has_more"`
+	Reset        bool                         `json:"reset"`
+	Realizations []domain.ActivityRealization `json:"realizations"`
+	Definitions  []domain.ActivityDefinition  `json:"definitions"`
+	Entities     []domain.Entity              `json:"entities"`
+	Deleted      []domain.Tombstone           `json:"deleted"`
+}
+
+type SyncHandler struct {
+	service domain.SyncService
+}
+
+func NewSyncHandler(service domain.SyncService) *SyncHandler {
+	return &SyncHandler{service: service}
+}
+
+// Routes mounts the sync endpoint. The OpenAPI description in
+// internal/openapi must list every route added here.
+func (h *SyncHandler) Routes(r chi.Router) {
+	r.Get("/sync", h.GetChanges)
+}
+
+// GetChanges returns what changed after the "cursor" query parameter, or
+// everything when it is absent. Clients repeat the call with the returned
+// cursor while has_more is true.
+func (h *SyncHandler) GetChanges(w http.ResponseWriter, r *http.Request) {
+	var limit int
+	if value := r.URL.Query().Get("limit"); value != "" {
+		var err error
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit <= 0 {
+			problem.Render(w, r, &domain.ValidationError{Field: "limit", Reason: "must be a positive integer"})
+			return
+		}
+	}
+
+	page, err := h.service.Changes(r.Context(), r.URL.Query().Get("cursor"), limit)
+	if err != nil {
+		problem.Render(w, r, err)
+		return
+	}
+
+	renderJSON(w, http.StatusOK, SyncResponse{
+		Cursor:       page.Cursor,
+		HasMore:      page.HasMore,
+		Reset:        page.Reset,
+		Realizations: page.Realizations,
+		Definitions:  page.Definitions,
+		Entities:     page.Entities,
+		Deleted:      page.Deleted,
+	})
+}
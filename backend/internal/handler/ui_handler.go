@@ -3,7 +3,6 @@ package handler
 import (
 	"html/template"
 	"net/http"
-	"os"
 
 	"github.com/google/uuid"
 	"github.com/luisteixeira/waypoint/backend/internal/domain"
@@ -11,22 +10,39 @@ import (
 )
 
 type UIHandler struct {
-	service domain.ActivityService
-	tmpl    *template.Template
+	service  domain.ActivityService
+	tmpl     *template.Template
+	familyID uuid.UUID
+	entityID uuid.UUID
 }
 
-func NewUIHandler(svc domain.ActivityService) *UIHandler {
+// NewUIHandler serves the dashboard for the given family and child until the
+// UI has sign-in of its own.
+func NewUIHandler(svc domain.ActivityService, familyID, entityID uuid.UUID) *UIHandler {
 	tmpl := template.Must(template.ParseFS(ui.Files, "templates/*.html", "templates/partials/*.html"))
-	return &UIHandler{service: svc, tmpl: tmpl}
+	return &UIHandler{service: svc, tmpl: tmpl, familyID: familyID, entityID: entityID}
 }
 
 func (h *UIHandler) ShowDashboard(w http.ResponseWriter, r *http.Request) {
 	// For testing this will come from auth session
-	familyID := uuid.MustParse(os.Getenv("TEST_FAMILY_ID"))
-	entityID := uuid.MustParse(os.Getenv("TEST_ENTITY_ID"))
 	data := map[string]interface{}{
-		"TestFamilyID": familyID,
-		"TestEntityID": entityID,
+		"TestFamilyID": h.familyID,
+		"TestEntityID": h.entityID,
 	}
 	h.tmpl.ExecuteTemplate(w, "layout.html", data)
 }
+
+// ServiceWorker serves the offline service worker from the site root, which
+// lets it control the dashboard as well as /static. It is never cached, so
+// browsers pick up a new version on their next visit.
+func (h *UIHandler) ServiceWorker(w http.ResponseWriter, r *http.Request) {
+	script, err := ui.Files.ReadFile("static/sw.js")
+	if err != nil {
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/javascript; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Service-Worker-Allowed", "/")
+	w.Write(script)
+}
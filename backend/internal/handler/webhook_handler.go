@@ -0,0 +1,204 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/luisteixeira/waypoint/backend/internal/domain"
+	"github.com/luisteixeira/waypoint/backend/internal/problem"
+	"github.com/luisteixeira/waypoint/backend/internal/webhook"
+)
+
+type WebhookRequest struct {
+	URL         string              `json:"url"`
+	Events      []webhook.EventType `json:"events"`
+	Description string              `json:"description"`
+}
+
+type WebhookResponse struct {
+	ID          uuid.UUID           `json:"id"`
+	URL         string              `json:"url"`
+	Events      []webhook.EventType `json:"events"`
+	Description string              `json:"description"`
+	CreatedAt   time.Time           `json:"created_at"`
+	// Secret is only returned when the webhook is created.
+	Secret string `json:"secret,omitempty"`
+}
+
+type DeliveryResponse struct {
+	ID             uuid.UUID              `json:"id"`
+	WebhookID      uuid.UUID              `json:"webhook_id"`
+	EventID        uuid.UUID              `json:"event_id"`
+	EventType      webhook.EventType      `json:"event_type"`
+	Status         webhook.DeliveryStatus `json:"status"`
+	Attempts       int                    `json:"attempts"`
+	NextAttemptAt  *time.Time             `json:"next_attempt_at"`
+	LastAttemptAt  *time.Time             `json:"last_attempt_at"`
+	ResponseStatus *int                   `json:"response_status"`
+	LastError      string                 `json:"last_error,omitempty"`
+	CreatedAt      time.Time              `json:"created_at"`
+	DeliveredAt    *time.Time             `json:"delivered_at"`
+	Payload        json.RawMessage        `json:"payload"`
+}
+
+type WebhookHandler struct {
+	registry *webhook.Registry
+}
+
+func NewWebhookHandler(registry *webhook.Registry) *WebhookHandler {
+	return &WebhookHandler{registry: registry}
+}
+
+// Routes mounts the webhook endpoints. The OpenAPI description in
+// internal/openapi must list every route added here.
+func (h *WebhookHandler) Routes(r chi.Router) {
+	r.Post("/webhooks", h.CreateWebhook)
+	r.Get("/webhooks", h.ListWebhooks)
+	r.Delete("/webhooks/{id}", h.DeleteWebhook)
+	r.Get("/webhooks/{id}/deliveries", h.ListDeliveries)
+	r.Post("/webhooks/{id}/deliveries/{deliveryID}/redeliver", h.Redeliver)
+}
+
+func (h *WebhookHandler) CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	var req WebhookRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		problem.Render(w, r, &domain.ValidationError{Field: "body", Reason: err.Error()})
+		return
+	}
+
+	sub, err := h.registry.Subscribe(r.Context(), req.URL, req.Events, req.Description)
+	if err != nil {
+		problem.Render(w, r, err)
+		return
+	}
+
+	res := toWebhookResponse(*sub)
+	res.Secret = sub.Secret
+	w.Header().Set("Location", "/api/v1/webhooks/"+sub.ID.String())
+	renderJSON(w, http.StatusCreated, res)
+}
+
+func (h *WebhookHandler) ListWebhooks(w http.ResponseWriter, r *http.Request) {
+	subs, err := h.registry.Subscriptions(r.Context())
+	if err != nil {
+		problem.Render(w, r, err)
+		return
+	}
+
+	res := make([]WebhookResponse, len(subs))
+	for i, sub := range subs {
+		res[i] = toWebhookResponse(sub)
+	}
+	renderJSON(w, http.StatusOK, res)
+}
+
+func (h *WebhookHandler) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	id, err := parseUUIDParam(r, "id", "invalid webhook id")
+	if err != nil {
+		problem.Render(w, r, err)
+		return
+	}
+
+	if err := h.registry.Unsubscribe(r.Context(), id); err != nil {
+		problem.Render(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListDeliveries returns the webhook's delivery log, newest first, up to the
+// "limit" query parameter.
+func (h *WebhookHandler) ListDeliveries(w http.ResponseWriter, r *http.Request) {
+	id, err := parseUUIDParam(r, "id", "invalid webhook id")
+	if err != nil {
+		problem.Render(w, r, err)
+		return
+	}
+
+	var limit int
+	if value := r.URL.Query().Get("limit"); value != "" {
+		limit, err = strconv.Atoi(value)
+		if err != nil || limit <= 0 {
+			problem.Render(w, r, &domain.ValidationError{Field: "limit", Reason: "must be a positive integer"})
+			return
+		}
+	}
+
+	deliveries, err := h.registry.Deliveries(r.Context(), id, limit)
+	if err != nil {
+		problem.Render(w, r, err)
+		return
+	}
+
+	res := make([]DeliveryResponse, len(deliveries))
+	for i, d := range deliveries {
+		res[i] = toDeliveryResponse(d)
+	}
+	renderJSON(w, http.StatusOK, res)
+}
+
+// Redeliver queues the event of a past delivery to be sent again.
+func (h *WebhookHandler) Redeliver(w http.ResponseWriter, r *http.Request) {
+	id, err := parseUUIDParam(r, "id", "invalid webhook id")
+	if err != nil {
+		problem.Render(w, r, err)
+		return
+	}
+	deliveryID, err := parseUUIDParam(r, "deliveryID", "invalid delivery id")
+	if err != nil {
+		problem.Render(w, r, err)
+		return
+	}
+
+	delivery, err := h.registry.Redeliver(r.Context(), id, deliveryID)
+	if err != nil {
+		problem.Render(w, r, err)
+		return
+	}
+	renderJSON(w, http.StatusAccepted, toDeliveryResponse(*delivery))
+}
+
+func parseUUIDParam(r *http.Request, name, reason string) (uuid.UUID, error) {
+	id, err := uuid.Parse(chi.URLParam(r, name))
+	if err != nil {
+		return uuid.Nil, &domain.ValidationError{Field: name, Reason: reason}
+	}
+	return id, nil
+}
+
+func toWebhookResponse(sub webhook.Subscription) WebhookResponse {
+	return WebhookResponse{
+		ID:          sub.ID,
+		URL:         sub.URL,
+		Events:      sub.Events,
+		Description: sub.Description,
+		CreatedAt:   sub.CreatedAt,
+	}
+}
+
+func toDeliveryResponse(d webhook.Delivery) DeliveryResponse {
+	res := DeliveryResponse{
+		ID:            d.ID,
+		WebhookID:     d.SubscriptionID,
+		EventID:       d.EventID,
+		EventType:     d.EventType,
+		Status:        d.Status,
+		Attempts:      d.Attempts,
+		LastAttemptAt: d.LastAttemptAt,
+		LastError:     d.LastError,
+		CreatedAt:     d.CreatedAt,
+		DeliveredAt:   d.DeliveredAt,
+		Payload:       d.Payload,
+	}
+	if d.Status == webhook.DeliveryPending {
+		res.NextAttemptAt = &d.NextAttemptAt
+	}
+	if d.ResponseStatus != 0 {
+		res.ResponseStatus = &d.ResponseStatus
+	}
+	return res
+}
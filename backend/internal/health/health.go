@@ -0,0 +1,120 @@
+// Package health serves the liveness and readiness probes.
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Check reports whether a dependency is usable. It should return promptly
+// once ctx is done.
+type Check func(ctx context.Context) error
+
+// Health tracks the readiness checks and whether the server is shutting down.
+type Health struct {
+	timeout time.Duration
+
+	mu      sync.Mutex
+	checks  map[string]Check
+	drained bool
+}
+
+// New returns a Health whose readiness checks each get timeout to answer.
+func New(timeout time.Duration) *Health {
+	return &Health{
+		timeout: timeout,
+		checks:  map[string]Check{},
+	}
+}
+
+// Register adds a readiness check under name, replacing any with that name.
+func (h *Health) Register(name string, check Check) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.checks[name] = check
+}
+
+// Drain marks the server as shutting down, so readiness fails and load
+// balancers stop routing to it.
+func (h *Health) Drain() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.drained = true
+}
+
+// Report is the readiness response body.
+type Report struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks,omitempty"`
+}
+
+// Live answers the liveness probe. It only shows the process is serving
+// requests, so a database outage does not get the server restarted.
+func (h *Health) Live(w http.ResponseWriter, r *http.Request) {
+	writeReport(w, http.StatusOK, Report{Status: "ok"})
+}
+
+// Ready answers the readiness probe by running every check concurrently.
+func (h *Health) Ready(w http.ResponseWriter, r *http.Request) {
+	report := h.Run(r.Context())
+	status := http.StatusOK
+	if report.Status != "ok" {
+		status = http.StatusServiceUnavailable
+	}
+	writeReport(w, status, report)
+}
+
+// Run executes the readiness checks and summarises their results.
+func (h *Health) Run(ctx context.Context) Report {
+	h.mu.Lock()
+	if h.drained {
+		h.mu.Unlock()
+		return Report{Status: "draining"}
+	}
+	names := make([]string, 0, len(h.checks))
+	for name := range h.checks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	checks := make([]Check, len(names))
+	for i, name := range names {
+		checks[i] = h.checks[name]
+	}
+	h.mu.Unlock()
+
+	ctx, cancel := context.WithTimeout(ctx, h.timeout)
+	defer cancel()
+
+	results := make([]error, len(checks))
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = check(ctx)
+		}()
+	}
+	wg.Wait()
+
+	report := Report{Status: "ok", Checks: make(map[string]string, len(names))}
+	for i, name := range names {
+		if results[i] != nil {
+			report.Status = "unavailable"
+			report.Checks[name] = results[i].Error()
+			continue
+		}
+		report.Checks[name] = "ok"
+	}
+	return report
+}
+
+func writeReport(w http.ResponseWriter, status int, report Report) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(report)
+}
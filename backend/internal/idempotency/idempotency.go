@@ -0,0 +1,51 @@
+// Package idempotency makes POST requests safe to retry. A client sends an
+// Idempotency-Key header; the first response for a key is stored per family
+// and replayed to every retry until it expires.
+package idempotency
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/luisteixeira/waypoint/backend/internal/domain"
+)
+
+// ErrRequestInProgress is returned while the first request made with a key
+// has not finished yet.
+var ErrRequestInProgress error = &domain.ConflictError{Reason: "a request with this Idempotency-Key is still being processed"}
+
+// Record is what is kept for a key: a fingerprint of the request that first
+// used it and, once that request finished, the response it got.
+type Record struct {
+	Key         string
+	RequestHash string
+
+	// Status is zero while the request is still being processed.
+	Status int
+	Header http.Header
+	Body   []byte
+
+	CreatedAt time.Time
+	ExpiresAt time.Time
+}
+
+// Completed reports whether the response has been stored.
+func (r *Record) Completed() bool { return r.Status != 0 }
+
+// Store keeps records per family, taken from the context like the service
+// repositories do.
+type Store interface {
+	// Reserve claims rec.Key for a new request. When an unexpired record
+	// already holds the key it is returned and nothing changes; otherwise
+	// rec is stored as in progress and Reserve returns nil. Records expired
+	// at rec.CreatedAt are replaced.
+	Reserve(ctx context.Context, rec Record) (*Record, error)
+	// Complete stores the response of the request holding key.
+	Complete(ctx context.Context, key string, status int, header http.Header, body []byte) error
+	// Release forgets key so the request can be tried again.
+	Release(ctx context.Context, key string) error
+	// DeleteExpired removes the records of every family that expired by now
+	// and returns how many there were.
+	DeleteExpired(ctx context.Context, now time.Time) (int64, error)
+}
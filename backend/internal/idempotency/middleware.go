@@ -0,0 +1,165 @@
+package idempotency
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/luisteixeira/waypoint/backend/internal/domain"
+	"github.com/luisteixeira/waypoint/backend/internal/problem"
+)
+
+const (
+	// HeaderKey is the request header carrying the client's key.
+	HeaderKey = "Idempotency-Key"
+	// HeaderReplayed marks responses served from a stored record.
+	HeaderReplayed = "Idempotent-Replayed"
+
+	maxKeyLength = 255
+	// maxBodySize bounds the request bodies read into memory to fingerprint
+	// and store them. A full batch of long notes stays well below it.
+	maxBodySize = 1 << 20
+)
+
+// replayedHeaders are the response headers stored with a record. Everything
+// else, like the request ID, belongs to the retry.
+var replayedHeaders = []string{"Content-Type", "ETag", "Location"}
+
+// Middleware stores the first response to every POST carrying an
+// Idempotency-Key and replays it to retries for ttl. Reusing a key with a
+// different request is rejected. Server errors are not stored, so the
+// request can be retried with the same key. It must run after the tenant
+// middleware, since keys are scoped to the family.
+func Middleware(store Store, ttl time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			key := r.Header.Get(HeaderKey)
+			if r.Method != http.MethodPost || key == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if len(key) > maxKeyLength {
+				problem.Render(w, r, &domain.ValidationError{Field: HeaderKey, Reason: "must be at most 255 characters"})
+				return
+			}
+
+			body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodySize))
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				problem.Render(w, r, &domain.TooLargeError{Limit: tooLarge.Limit})
+				return
+			}
+			if err != nil {
+				problem.Render(w, r, &domain.ValidationError{Field: "body", Reason: "could not read request body"})
+				return
+			}
+			r.Body = io.NopCloser(bytes.NewReader(body))
+
+			now := time.Now()
+			hash := fingerprint(r, body)
+			existing, err := store.Reserve(r.Context(), Record{
+				Key:         key,
+				RequestHash: hash,
+				CreatedAt:   now,
+				ExpiresAt:   now.Add(ttl),
+			})
+			if err != nil {
+				problem.Render(w, r, err)
+				return
+			}
+			if existing != nil {
+				switch {
+				case existing.RequestHash != hash:
+					problem.Render(w, r, domain.ErrIdempotencyKeyReused)
+				case !existing.Completed():
+					problem.Render(w, r, ErrRequestInProgress)
+				default:
+					replay(w, existing)
+				}
+				return
+			}
+
+			// The request may time out or its client go away; the outcome
+			// must be recorded either way.
+			ctx := context.WithoutCancel(r.Context())
+			stored := false
+			defer func() {
+				if stored {
+					return
+				}
+				if err := store.Release(ctx, key); err != nil {
+					slog.WarnContext(ctx, "failed to release idempotency key", "error", err)
+				}
+			}()
+
+			rec := &recorder{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(rec, r)
+
+			if rec.status >= http.StatusInternalServerError {
+				return
+			}
+			header := make(http.Header)
+			for _, name := range replayedHeaders {
+				if v := rec.Header().Values(name); len(v) > 0 {
+					header[http.CanonicalHeaderKey(name)] = v
+				}
+			}
+			if err := store.Complete(ctx, key, rec.status, header, rec.body.Bytes()); err != nil {
+				slog.WarnContext(ctx, "failed to store idempotent response", "error", err)
+				return
+			}
+			stored = true
+		})
+	}
+}
+
+// fingerprint identifies a request by everything that decides its outcome.
+func fingerprint(r *http.Request, body []byte) string {
+	h := sha256.New()
+	io.WriteString(h, r.Method+" "+r.URL.RequestURI()+"\n")
+	io.WriteString(h, r.Header.Get("Content-Type")+"\n")
+	// A conditional request may succeed or fail depending on the version it
+	// names, so it is a different request.
+	io.WriteString(h, r.Header.Get("If-Match")+"\n")
+	h.Write(body)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+func replay(w http.ResponseWriter, rec *Record) {
+	for name, values := range rec.Header {
+		w.Header()[name] = values
+	}
+	w.Header().Set(HeaderReplayed, "true")
+	w.WriteHeader(rec.Status)
+	w.Write(rec.Body)
+}
+
+// recorder passes a response through while keeping a copy of it.
+type recorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (r *recorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.status = status
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *recorder) Write(b []byte) (int, error) {
+	r.wroteHeader = true
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
+
+func (r *recorder) Unwrap() http.ResponseWriter { return r.ResponseWriter }
@@ -0,0 +1,60 @@
+package logging
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
+	"github.com/google/uuid"
+)
+
+// Middleware writes one access log record per request, replacing chi's text
+// logger. It must run after chi's RequestID middleware, whose ID it echoes in
+// the X-Request-ID response header so users can quote it in bug reports.
+func Middleware(logger *slog.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			if id := chimiddleware.GetReqID(r.Context()); id != "" {
+				w.Header().Set("X-Request-ID", id)
+			}
+
+			ww := chimiddleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			next.ServeHTTP(ww, r)
+
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+			attrs := []slog.Attr{
+				slog.String("method", r.Method),
+				slog.String("path", r.URL.Path),
+				slog.Int("status", status),
+				slog.Int("bytes", ww.BytesWritten()),
+				slog.Duration("duration", time.Since(start)),
+			}
+			if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+				attrs = append(attrs, slog.String("route", rctx.RoutePattern()))
+			}
+			// The tenant middleware stores these deeper in the chain, out of
+			// reach of this context, so they are read from the headers.
+			if id, err := uuid.Parse(r.Header.Get("X-Family-ID")); err == nil {
+				attrs = append(attrs, slog.String("family_id", id.String()))
+			}
+			if id, err := uuid.Parse(r.Header.Get("X-Caregiver-ID")); err == nil {
+				attrs = append(attrs, slog.String("caregiver_id", id.String()))
+			}
+
+			level := slog.LevelInfo
+			switch {
+			case status >= http.StatusInternalServerError:
+				level = slog.LevelError
+			case status >= http.StatusBadRequest:
+				level = slog.LevelWarn
+			}
+			logger.LogAttrs(r.Context(), level, "request", attrs...)
+		})
+	}
+}
@@ -0,0 +1,79 @@
+// Package logging builds the server's slog logger. Records pick up the
+// request, trace, family and caregiver IDs from the context they are logged
+// with, and attributes that look like credentials are redacted.
+package logging
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"strings"
+
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
+	"github.com/google/uuid"
+	"github.com/luisteixeira/waypoint/backend/internal/config"
+	"github.com/luisteixeira/waypoint/backend/internal/middleware"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// Redacted replaces the value of sensitive attributes.
+const Redacted = "[REDACTED]"
+
+// sensitive lists key fragments whose values are never written out.
+var sensitive = []string{"password", "secret", "token", "authorization", "cookie", "dsn", "api_key"}
+
+// New returns a logger writing records at or above the configured level to w
+// in the configured format.
+func New(cfg config.LoggingConfig, w io.Writer) *slog.Logger {
+	var level slog.Level
+	// Validate has already checked the level parses.
+	level.UnmarshalText([]byte(cfg.Level))
+
+	opts := &slog.HandlerOptions{Level: level, ReplaceAttr: redact}
+	var handler slog.Handler
+	if cfg.Format == "text" {
+		handler = slog.NewTextHandler(w, opts)
+	} else {
+		handler = slog.NewJSONHandler(w, opts)
+	}
+	return slog.New(contextHandler{handler})
+}
+
+func redact(groups []string, a slog.Attr) slog.Attr {
+	key := strings.ToLower(a.Key)
+	for _, fragment := range sensitive {
+		if strings.Contains(key, fragment) {
+			return slog.String(a.Key, Redacted)
+		}
+	}
+	return a
+}
+
+// contextHandler adds the IDs carried by the context to every record.
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id := chimiddleware.GetReqID(ctx); id != "" {
+		r.AddAttrs(slog.String("request_id", id))
+	}
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		r.AddAttrs(slog.String("trace_id", sc.TraceID().String()))
+	}
+	if id, ok := ctx.Value(middleware.FamilyIDKey).(uuid.UUID); ok {
+		r.AddAttrs(slog.String("family_id", id.String()))
+	}
+	if id, ok := ctx.Value(middleware.CaregiverIDKey).(uuid.UUID); ok {
+		r.AddAttrs(slog.String("caregiver_id", id.String()))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
@@ -0,0 +1,40 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/go-chi/chi/v5/middleware"
+)
+
+// unmatchedRoute labels requests no route matched, so probing random URLs
+// cannot create unbounded label values.
+const unmatchedRoute = "unmatched"
+
+// Middleware records the latency and status of every request. Requests are
+// labelled with the chi route pattern rather than the path, keeping IDs out of
+// the label values.
+func (m *Metrics) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ww := middleware.NewWrapResponseWriter(w, r.ProtoMajor)
+		next.ServeHTTP(ww, r)
+
+		route := unmatchedRoute
+		if rctx := chi.RouteContext(r.Context()); rctx != nil {
+			if pattern := rctx.RoutePattern(); pattern != "" {
+				route = pattern
+			}
+		}
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+
+		m.httpDuration.
+			WithLabelValues(r.Method, route, strconv.Itoa(status)).
+			Observe(time.Since(start).Seconds())
+	})
+}
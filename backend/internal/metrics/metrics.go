@@ -0,0 +1,103 @@
+// Package metrics exposes Prometheus metrics. HTTP, storage and domain
+// metrics are collected by decorators around the router, the repositories
+// and the activity service, so the code they wrap stays free of
+// instrumentation.
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "waypoint"
+
+// Metrics owns the registry and the collectors the decorators update.
+type Metrics struct {
+	registry *prometheus.Registry
+
+	httpDuration *prometheus.HistogramVec
+	repoDuration *prometheus.HistogramVec
+
+	started          *prometheus.CounterVec
+	completed        *prometheus.CounterVec
+	cancelled        *prometheus.CounterVec
+	activityDuration *prometheus.HistogramVec
+	entityBusy       prometheus.Counter
+}
+
+// New registers the Waypoint collectors alongside the Go runtime and process
+// collectors on a registry of its own.
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		httpDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "http",
+			Name:      "request_duration_seconds",
+			Help:      "HTTP request latency by method, route pattern and status code.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route", "status"}),
+		repoDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "repository",
+			Name:      "operation_duration_seconds",
+			Help:      "Repository call latency by repository, operation and outcome.",
+			Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+		}, []string{"repository", "operation", "outcome"}),
+		started: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "activities_started_total",
+			Help:      "Activities started, by definition.",
+		}, []string{"definition_id"}),
+		completed: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "activities_completed_total",
+			Help:      "Activities completed, by definition.",
+		}, []string{"definition_id"}),
+		cancelled: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "activities_cancelled_total",
+			Help:      "Activities cancelled, by definition.",
+		}, []string{"definition_id"}),
+		activityDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "activity_duration_seconds",
+			Help:      "Time from start to completion of activities, by definition.",
+			// One minute to four hours covers a feed through a long nap.
+			Buckets: []float64{60, 300, 600, 900, 1800, 2700, 3600, 5400, 7200, 10800, 14400},
+		}, []string{"definition_id"}),
+		entityBusy: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "entity_busy_conflicts_total",
+			Help:      "Starts and resumes rejected because the child was already in an activity.",
+		}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.httpDuration,
+		m.repoDuration,
+		m.started,
+		m.completed,
+		m.cancelled,
+		m.activityDuration,
+		m.entityBusy,
+	)
+	return m
+}
+
+// RegisterDB exports the connection pool statistics of db under the given
+// name.
+func (m *Metrics) RegisterDB(name string, db *sql.DB) {
+	m.registry.MustRegister(collectors.NewDBStatsCollector(db, name))
+}
+
+// Handler serves the metrics in the Prometheus exposition format.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{Registry: m.registry})
+}
@@ -0,0 +1,110 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/luisteixeira/waypoint/backend/internal/domain"
+	"github.com/luisteixeira/waypoint/backend/internal/service"
+)
+
+// instrumentedActivityRepo times every call to the wrapped repository.
+type instrumentedActivityRepo struct {
+	next    service.ActivityRepository
+	metrics *Metrics
+}
+
+func NewActivityRepo(next service.ActivityRepository, m *Metrics) *instrumentedActivityRepo {
+	return &instrumentedActivityRepo{next: next, metrics: m}
+}
+
+func (r *instrumentedActivityRepo) CreateRealization(ctx context.Context, ar *domain.ActivityRealization, events ...domain.RealizationEvent) error {
+	start := time.Now()
+	err := r.next.CreateRealization(ctx, ar, events...)
+	r.metrics.observeRepo("activity", "create_realization", start, err)
+	return err
+}
+
+func (r *instrumentedActivityRepo) GetRealizationByID(ctx context.Context, id uuid.UUID) (*domain.ActivityRealization, error) {
+	start := time.Now()
+	ar, err := r.next.GetRealizationByID(ctx, id)
+	r.metrics.observeRepo("activity", "get_realization_by_id", start, err)
+	return ar, err
+}
+
+func (r *instrumentedActivityRepo) GetActiveByEntity(ctx context.Context, entityID uuid.UUID) (*domain.ActivityRealization, error) {
+	start := time.Now()
+	ar, err := r.next.GetActiveByEntity(ctx, entityID)
+	r.metrics.observeRepo("activity", "get_active_by_entity", start, err)
+	return ar, err
+}
+
+func (r *instrumentedActivityRepo) ListRealizations(ctx context.Context, filter domain.RealizationFilter) ([]domain.ActivityRealization, error) {
+	start := time.Now()
+	ars, err := r.next.ListRealizations(ctx, filter)
+	r.metrics.observeRepo("activity", "list_realizations", start, err)
+	return ars, err
+}
+
+func (r *instrumentedActivityRepo) UpdateRealization(ctx context.Context, ar *domain.ActivityRealization, events ...domain.RealizationEvent) error {
+	start := time.Now()
+	err := r.next.UpdateRealization(ctx, ar, events...)
+	r.metrics.observeRepo("activity", "update_realization", start, err)
+	return err
+}
+
+func (r *instrumentedActivityRepo) ListEvents(ctx context.Context, realizationID uuid.UUID) ([]domain.RealizationEvent, error) {
+	start := time.Now()
+	events, err := r.next.ListEvents(ctx, realizationID)
+	r.metrics.observeRepo("activity", "list_events", start, err)
+	return events, err
+}
+
+func (r *instrumentedActivityRepo) ReplaceRealization(ctx context.Context, ar *domain.ActivityRealization) error {
+	start := time.Now()
+	err := r.next.ReplaceRealization(ctx, ar)
+	r.metrics.observeRepo("activity", "replace_realization", start, err)
+	return err
+}
+
+// instrumentedDefinitionRepo times every call to the wrapped repository.
+type instrumentedDefinitionRepo struct {
+	next    service.DefinitionRepository
+	metrics *Metrics
+}
+
+func NewDefinitionRepo(next service.DefinitionRepository, m *Metrics) *instrumentedDefinitionRepo {
+	return &instrumentedDefinitionRepo{next: next, metrics: m}
+}
+
+func (r *instrumentedDefinitionRepo) GetOrCreateByName(ctx context.Context, name string) (*domain.ActivityDefinition, error) {
+	start := time.Now()
+	d, err := r.next.GetOrCreateByName(ctx, name)
+	r.metrics.observeRepo("definition", "get_or_create_by_name", start, err)
+	return d, err
+}
+
+func (r *instrumentedDefinitionRepo) ListByFamily(ctx context.Context) ([]domain.ActivityDefinition, error) {
+	start := time.Now()
+	definitions, err := r.next.ListByFamily(ctx)
+	r.metrics.observeRepo("definition", "list_by_family", start, err)
+	return definitions, err
+}
+
+// observeRepo records a repository call. Expected domain outcomes are told
+// apart from failures so error-rate alerts only fire on the latter.
+func (m *Metrics) observeRepo(repository, operation string, start time.Time, err error) {
+	outcome := "ok"
+	switch {
+	case err == nil:
+	case errors.Is(err, domain.ErrNotFound):
+		outcome = "not_found"
+	case errors.Is(err, domain.ErrConflict):
+		outcome = "conflict"
+	default:
+		outcome = "error"
+	}
+	m.repoDuration.WithLabelValues(repository, operation, outcome).Observe(time.Since(start).Seconds())
+}
@@ -0,0 +1,73 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+
+	"github.com/google/uuid"
+	"github.com/luisteixeira/waypoint/backend/internal/domain"
+	"github.com/luisteixeira/waypoint/backend/internal/service"
+)
+
+// instrumentedService counts lifecycle changes as they succeed. Changes made
+// within a larger unit of work, such as an atomic batch, are counted once it
+// commits, so a rolled-back change is never counted.
+type instrumentedService struct {
+	domain.ActivityService
+	metrics *Metrics
+}
+
+// NewActivityService wraps next so that starts, completions, cancellations,
+// activity durations and busy-child conflicts are counted.
+func NewActivityService(next domain.ActivityService, m *Metrics) *instrumentedService {
+	return &instrumentedService{ActivityService: next, metrics: m}
+}
+
+func (s *instrumentedService) StartActivity(ctx context.Context, input domain.StartActivityInput) (*domain.ActivityRealization, error) {
+	ar, err := s.ActivityService.StartActivity(ctx, input)
+	s.observe(err)
+	if err == nil {
+		service.AfterCommit(ctx, func() {
+			s.metrics.started.WithLabelValues(ar.DefinitionID.String()).Inc()
+		})
+	}
+	return ar, err
+}
+
+func (s *instrumentedService) ResumeActivity(ctx context.Context, realizationID uuid.UUID, expectedVersion int) (*domain.ActivityRealization, error) {
+	ar, err := s.ActivityService.ResumeActivity(ctx, realizationID, expectedVersion)
+	s.observe(err)
+	return ar, err
+}
+
+func (s *instrumentedService) CompleteActivity(ctx context.Context, realizationID uuid.UUID, expectedVersion int) (*domain.ActivityRealization, error) {
+	ar, err := s.ActivityService.CompleteActivity(ctx, realizationID, expectedVersion)
+	if err != nil {
+		return ar, err
+	}
+
+	definition := ar.DefinitionID.String()
+	service.AfterCommit(ctx, func() {
+		s.metrics.completed.WithLabelValues(definition).Inc()
+		if ar.StartedAt != nil && ar.FinishedAt != nil {
+			s.metrics.activityDuration.WithLabelValues(definition).Observe(ar.FinishedAt.Sub(*ar.StartedAt).Seconds())
+		}
+	})
+	return ar, nil
+}
+
+func (s *instrumentedService) CancelActivity(ctx context.Context, realizationID uuid.UUID, expectedVersion int) (*domain.ActivityRealization, error) {
+	ar, err := s.ActivityService.CancelActivity(ctx, realizationID, expectedVersion)
+	if err == nil {
+		service.AfterCommit(ctx, func() {
+			s.metrics.cancelled.WithLabelValues(ar.DefinitionID.String()).Inc()
+		})
+	}
+	return ar, err
+}
+
+func (s *instrumentedService) observe(err error) {
+	if errors.Is(err, domain.ErrEntityBusy) {
+		s.metrics.entityBusy.Inc()
+	}
+}
@@ -5,27 +5,44 @@ import (
 	"net/http"
 
 	"github.com/google/uuid"
+	"github.com/luisteixeira/waypoint/backend/internal/domain"
+	"github.com/luisteixeira/waypoint/backend/internal/problem"
 )
 
 type contextKey string
 
-const FamilyIDKey contextKey = "family_id"
+const (
+	FamilyIDKey contextKey = "family_id"
+	// CaregiverIDKey holds the caregiver acting on the request, when the
+	// client identifies one with X-Caregiver-ID.
+	CaregiverIDKey contextKey = "caregiver_id"
+)
 
 func TenantMiddleware(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		familyIDStr := r.Header.Get("X-Family-ID")
 		if familyIDStr == "" {
-			http.Error(w, "Missing Family Context", http.StatusUnauthorized)
+			problem.Render(w, r, &domain.UnauthorizedError{Reason: "missing family context"})
 			return
 		}
 
 		familyID, err := uuid.Parse(familyIDStr)
 		if err != nil {
-			http.Error(w, "Invalid Family ID", http.StatusBadRequest)
+			problem.Render(w, r, &domain.ValidationError{Field: "X-Family-ID", Reason: "invalid family id"})
 			return
 		}
 
 		ctx := context.WithValue(r.Context(), FamilyIDKey, familyID)
+
+		if caregiverIDStr := r.Header.Get("X-Caregiver-ID"); caregiverIDStr != "" {
+			caregiverID, err := uuid.Parse(caregiverIDStr)
+			if err != nil {
+				problem.Render(w, r, &domain.ValidationError{Field: "X-Caregiver-ID", Reason: "invalid caregiver id"})
+				return
+			}
+			ctx = context.WithValue(ctx, CaregiverIDKey, caregiverID)
+		}
+
 		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
@@ -0,0 +1,204 @@
+package mqtt
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/luisteixeira/waypoint/backend/internal/domain"
+	"github.com/luisteixeira/waypoint/backend/internal/middleware"
+	"github.com/luisteixeira/waypoint/backend/internal/outbox"
+	"github.com/luisteixeira/waypoint/backend/internal/service"
+)
+
+// commandTimeout bounds the handling of one command.
+const commandTimeout = 10 * time.Second
+
+// Bridge publishes entity states as an outbox sink and carries out the
+// commands published for them.
+type Bridge struct {
+	client       Client
+	prefix       string
+	activities   domain.ActivityService
+	realizations service.ActivityRepository
+	definitions  service.DefinitionRepository
+	now          func() time.Time
+}
+
+// NewBridge returns a bridge publishing under prefix, stamping states with the
+// time now tells. Commands go through activities, so they are recorded,
+// announced and published like any other change.
+func NewBridge(client Client, prefix string, activities domain.ActivityService, realizations service.ActivityRepository, definitions service.DefinitionRepository, now func() time.Time) *Bridge {
+	return &Bridge{
+		client:       client,
+		prefix:       prefix,
+		activities:   activities,
+		realizations: realizations,
+		definitions:  definitions,
+		now:          now,
+	}
+}
+
+func (b *Bridge) Name() string {
+	return "mqtt"
+}
+
+// Publish announces the current state of the event's entity. The state is
+// read afresh rather than taken from the event, so an event published late
+// never overwrites a newer state.
+func (b *Bridge) Publish(ctx context.Context, event outbox.Event) error {
+	if event.Realization == nil {
+		return nil
+	}
+	entityID := event.Realization.EntityID
+
+	current, err := b.current(ctx, entityID)
+	if err != nil {
+		return fmt.Errorf("failed to load the state of entity %s: %w", entityID, err)
+	}
+
+	state := State{EntityID: entityID, State: Idle, UpdatedAt: b.now().UTC()}
+	if current != nil {
+		state.State = string(current.Status)
+		state.RealizationID = &current.ID
+		state.DefinitionID = &current.DefinitionID
+		state.StartedAt = current.StartedAt
+		state.Version = current.Version
+		if state.Definition, err = b.definitionName(ctx, current.DefinitionID); err != nil {
+			return err
+		}
+	}
+
+	payload, err := json.Marshal(state)
+	if err != nil {
+		return err
+	}
+	return b.client.Publish(ctx, StateTopic(b.prefix, event.FamilyID, entityID), payload, true)
+}
+
+// current returns the entity's activity in progress or, failing that, the
+// most recently started paused one, whichever realization the event was
+// about. It returns nil when the entity is idle.
+func (b *Bridge) current(ctx context.Context, entityID uuid.UUID) (*domain.ActivityRealization, error) {
+	open, err := b.realizations.ListRealizations(ctx, domain.RealizationFilter{
+		EntityID: entityID,
+		Statuses: []domain.ActivityStatus{domain.StatusInProgress, domain.StatusPaused},
+	})
+	if err != nil || len(open) == 0 {
+		return nil, err
+	}
+	for i := range open {
+		if open[i].Status == domain.StatusInProgress {
+			return &open[i], nil
+		}
+	}
+	return &open[0], nil
+}
+
+// Listen subscribes to the command topics of every family.
+func (b *Bridge) Listen(ctx context.Context) error {
+	return b.client.Subscribe(ctx, b.prefix+"/+/+/command", func(topic string, payload []byte) {
+		ctx, cancel := context.WithTimeout(context.Background(), commandTimeout)
+		defer cancel()
+
+		if err := b.handle(ctx, topic, payload); err != nil {
+			slog.WarnContext(ctx, "mqtt command failed", "topic", topic, "error", err)
+		}
+	})
+}
+
+func (b *Bridge) handle(ctx context.Context, topic string, payload []byte) error {
+	familyID, entityID, err := parseCommandTopic(b.prefix, topic)
+	if err != nil {
+		return err
+	}
+	ctx = context.WithValue(ctx, middleware.FamilyIDKey, familyID)
+
+	var cmd Command
+	if err := json.Unmarshal(payload, &cmd); err != nil {
+		return fmt.Errorf("invalid command: %w", err)
+	}
+	if cmd.DefinitionID == uuid.Nil && cmd.Definition == "" {
+		return &domain.ValidationError{Field: "definition", Reason: "either definition_id or definition must be provided"}
+	}
+
+	switch cmd.Action {
+	case ActionStart:
+		return b.start(ctx, entityID, cmd)
+	case ActionComplete:
+		return b.complete(ctx, entityID, cmd)
+	case ActionToggle:
+		err := b.complete(ctx, entityID, cmd)
+		if err == errNothingToComplete {
+			return b.start(ctx, entityID, cmd)
+		}
+		return err
+	default:
+		return &domain.ValidationError{Field: "action", Reason: fmt.Sprintf("must be start, complete or toggle, got %q", cmd.Action)}
+	}
+}
+
+func (b *Bridge) start(ctx context.Context, entityID uuid.UUID, cmd Command) error {
+	_, err := b.activities.StartActivity(ctx, domain.StartActivityInput{
+		EntityID:           entityID,
+		DefinitionID:       cmd.DefinitionID,
+		NewDefinittionName: cmd.Definition,
+	})
+	return err
+}
+
+var errNothingToComplete = &domain.ConflictError{Reason: "no activity of that definition is in progress"}
+
+func (b *Bridge) complete(ctx context.Context, entityID uuid.UUID, cmd Command) error {
+	active, err := b.realizations.GetActiveByEntity(ctx, entityID)
+	if err != nil {
+		return err
+	}
+	if active == nil {
+		return errNothingToComplete
+	}
+
+	definitionID := cmd.DefinitionID
+	if definitionID == uuid.Nil {
+		if definitionID, err = b.definitionID(ctx, cmd.Definition); err != nil {
+			return err
+		}
+	}
+	if active.DefinitionID != definitionID {
+		return errNothingToComplete
+	}
+
+	_, err = b.activities.CompleteActivity(ctx, active.ID, active.Version)
+	return err
+}
+
+func (b *Bridge) definitionName(ctx context.Context, id uuid.UUID) (string, error) {
+	defs, err := b.definitions.ListByFamily(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to list definitions: %w", err)
+	}
+	for _, def := range defs {
+		if def.ID == id {
+			return def.Name, nil
+		}
+	}
+	return "", nil
+}
+
+// definitionID returns the ID of the family's definition with the given
+// name, or uuid.Nil if there is none.
+func (b *Bridge) definitionID(ctx context.Context, name string) (uuid.UUID, error) {
+	defs, err := b.definitions.ListByFamily(ctx)
+	if err != nil {
+		return uuid.Nil, fmt.Errorf("failed to list definitions: %w", err)
+	}
+	for _, def := range defs {
+		if def.Name == name {
+			return def.ID, nil
+		}
+	}
+	return uuid.Nil, nil
+}
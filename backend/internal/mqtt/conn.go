@@ -0,0 +1,122 @@
+package mqtt
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	paho "github.com/eclipse/paho.mqtt.golang"
+)
+
+// Options describe the broker connection.
+type Options struct {
+	// Broker is the broker URL, e.g. tcp://localhost:1883 or ssl://hub:8883.
+	Broker   string
+	ClientID string
+	Username string
+	Password string
+	// Prefix is the root of every topic.
+	Prefix string
+	// QoS is used for publishing and subscribing.
+	QoS byte
+}
+
+// Conn is a Client backed by a broker connection. It reconnects on its own,
+// subscribes again after every reconnect and keeps the status topic up to
+// date, with a last will marking the bridge offline if the connection drops.
+type Conn struct {
+	client paho.Client
+	opts   Options
+
+	mu   sync.Mutex
+	subs map[string]paho.MessageHandler
+}
+
+const (
+	online  = "online"
+	offline = "offline"
+)
+
+// Dial connects to the broker. If the broker cannot be reached before ctx is
+// done the connection keeps being retried in the background, so the server
+// can start while the broker is down.
+func Dial(ctx context.Context, opts Options) *Conn {
+	c := &Conn{opts: opts, subs: map[string]paho.MessageHandler{}}
+
+	options := paho.NewClientOptions().
+		AddBroker(opts.Broker).
+		SetClientID(opts.ClientID).
+		SetUsername(opts.Username).
+		SetPassword(opts.Password).
+		SetWill(StatusTopic(opts.Prefix), offline, opts.QoS, true).
+		SetAutoReconnect(true).
+		SetConnectRetry(true).
+		SetConnectRetryInterval(10 * time.Second).
+		SetOnConnectHandler(c.onConnect).
+		SetConnectionLostHandler(func(_ paho.Client, err error) {
+			slog.Warn("Lost the MQTT connection", "broker", opts.Broker, "error", err)
+		})
+	c.client = paho.NewClient(options)
+
+	if err := wait(ctx, c.client.Connect()); err != nil {
+		slog.Warn("Could not connect to the MQTT broker yet", "broker", opts.Broker, "error", err)
+	}
+	return c
+}
+
+// onConnect runs after every (re)connection.
+func (c *Conn) onConnect(client paho.Client) {
+	slog.Info("Connected to the MQTT broker", "broker", c.opts.Broker)
+	client.Publish(StatusTopic(c.opts.Prefix), c.opts.QoS, true, online)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for filter, handler := range c.subs {
+		client.Subscribe(filter, c.opts.QoS, handler)
+	}
+}
+
+func (c *Conn) Publish(ctx context.Context, topic string, payload []byte, retained bool) error {
+	if err := wait(ctx, c.client.Publish(topic, c.opts.QoS, retained, payload)); err != nil {
+		return fmt.Errorf("failed to publish to %s: %w", topic, err)
+	}
+	return nil
+}
+
+func (c *Conn) Subscribe(ctx context.Context, filter string, handle func(topic string, payload []byte)) error {
+	handler := func(_ paho.Client, msg paho.Message) {
+		handle(msg.Topic(), msg.Payload())
+	}
+
+	c.mu.Lock()
+	c.subs[filter] = handler
+	c.mu.Unlock()
+
+	// While disconnected the subscription is made once the connection is.
+	if !c.client.IsConnectionOpen() {
+		return nil
+	}
+	if err := wait(ctx, c.client.Subscribe(filter, c.opts.QoS, handler)); err != nil {
+		return fmt.Errorf("failed to subscribe to %s: %w", filter, err)
+	}
+	return nil
+}
+
+// Close marks the bridge offline and disconnects.
+func (c *Conn) Close() {
+	if c.client.IsConnectionOpen() {
+		c.client.Publish(StatusTopic(c.opts.Prefix), c.opts.QoS, true, offline).WaitTimeout(time.Second)
+	}
+	c.client.Disconnect(250)
+}
+
+func wait(ctx context.Context, token paho.Token) error {
+	select {
+	case <-token.Done():
+		return token.Error()
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
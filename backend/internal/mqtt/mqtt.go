@@ -0,0 +1,96 @@
+// Package mqtt bridges Waypoint to an MQTT broker for smart-home hubs such as
+// Home Assistant. The Bridge announces what each child is doing on retained
+// topics and starts or completes activities on commands, so a physical
+// button can drive the log.
+//
+// Topics live under a configurable prefix:
+//
+//	<prefix>/status                      "online" or "offline", retained
+//	<prefix>/<family>/<entity>/state     the child's current activity, retained
+//	<prefix>/<family>/<entity>/command   commands from the hub
+//
+// Anyone who can publish to a command topic acts for that family, so the
+// broker's ACLs must keep families apart.
+package mqtt
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Client is the part of an MQTT connection the bridge needs.
+type Client interface {
+	Publish(ctx context.Context, topic string, payload []byte, retained bool) error
+	// Subscribe calls handle for every message matching filter, one at a
+	// time, until the client is closed.
+	Subscribe(ctx context.Context, filter string, handle func(topic string, payload []byte)) error
+}
+
+// StatusTopic carries the bridge's availability.
+func StatusTopic(prefix string) string {
+	return prefix + "/status"
+}
+
+// StateTopic carries an entity's current activity.
+func StateTopic(prefix string, familyID, entityID uuid.UUID) string {
+	return fmt.Sprintf("%s/%s/%s/state", prefix, familyID, entityID)
+}
+
+// CommandTopic is where commands for an entity are published.
+func CommandTopic(prefix string, familyID, entityID uuid.UUID) string {
+	return fmt.Sprintf("%s/%s/%s/command", prefix, familyID, entityID)
+}
+
+// parseCommandTopic returns the family and entity of a command topic.
+func parseCommandTopic(prefix, topic string) (familyID, entityID uuid.UUID, err error) {
+	parts := strings.Split(strings.TrimPrefix(topic, prefix+"/"), "/")
+	if len(parts) != 3 || parts[2] != "command" {
+		return uuid.Nil, uuid.Nil, fmt.Errorf("%q is not a command topic", topic)
+	}
+	if familyID, err = uuid.Parse(parts[0]); err != nil {
+		return uuid.Nil, uuid.Nil, fmt.Errorf("invalid family in %q", topic)
+	}
+	if entityID, err = uuid.Parse(parts[1]); err != nil {
+		return uuid.Nil, uuid.Nil, fmt.Errorf("invalid entity in %q", topic)
+	}
+	return familyID, entityID, nil
+}
+
+// Idle is the state of an entity with no activity in progress or paused.
+const Idle = "idle"
+
+// State is the retained payload of a state topic. State is the status of the
+// current realization, or Idle.
+type State struct {
+	EntityID      uuid.UUID  `json:"entity_id"`
+	State         string     `json:"state"`
+	RealizationID *uuid.UUID `json:"realization_id,omitempty"`
+	DefinitionID  *uuid.UUID `json:"definition_id,omitempty"`
+	Definition    string     `json:"definition,omitempty"`
+	StartedAt     *time.Time `json:"started_at,omitempty"`
+	Version       int        `json:"version,omitempty"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+type Action string
+
+const (
+	ActionStart    Action = "start"
+	ActionComplete Action = "complete"
+	// ActionToggle completes the entity's activity if it is of the given
+	// definition and starts one otherwise, for single-button remotes.
+	ActionToggle Action = "toggle"
+)
+
+// Command is the payload of a command topic. The definition is named either
+// by ID or by name; starting by an unknown name creates the definition, as
+// the API does.
+type Command struct {
+	Action       Action    `json:"action"`
+	DefinitionID uuid.UUID `json:"definition_id"`
+	Definition   string    `json:"definition"`
+}
@@ -0,0 +1,34 @@
+package openapi
+
+import (
+	"html/template"
+	"net/http"
+)
+
+// swaggerUIVersion pins the Swagger UI assets loaded from the CDN.
+const swaggerUIVersion = "5.17.14"
+
+var docsPage = template.Must(template.New("docs").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+  <meta charset="utf-8">
+  <title>Waypoint API</title>
+  <link rel="stylesheet" href="https://unpkg.com/swagger-ui-dist@{{.Version}}/swagger-ui.css">
+</head>
+<body>
+  <div id="swagger-ui"></div>
+  <script src="https://unpkg.com/swagger-ui-dist@{{.Version}}/swagger-ui-bundle.js" crossorigin></script>
+  <script>
+    window.ui = SwaggerUIBundle({ url: {{.SpecURL}}, dom_id: "#swagger-ui" });
+  </script>
+</body>
+</html>
+`))
+
+// DocsHandler serves a Swagger UI page for the description at specURL.
+func DocsHandler(specURL string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		docsPage.Execute(w, map[string]string{"Version": swaggerUIVersion, "SpecURL": specURL})
+	}
+}
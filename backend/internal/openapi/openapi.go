@@ -0,0 +1,146 @@
+// Package openapi holds the OpenAPI description of /api/v1, serves it with a
+// Swagger UI page and validates incoming requests against it.
+package openapi
+
+import (
+	"context"
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/openapi3filter"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/getkin/kin-openapi/routers/gorillamux"
+	"github.com/google/uuid"
+	"github.com/luisteixeira/waypoint/backend/internal/domain"
+	"github.com/luisteixeira/waypoint/backend/internal/problem"
+)
+
+//go:embed openapi.yaml
+var spec []byte
+
+func init() {
+	openapi3.DefineStringFormatCallback("uuid", func(value string) error {
+		if _, err := uuid.Parse(value); err != nil {
+			return errors.New("must be a UUID")
+		}
+		return nil
+	})
+	openapi3filter.RegisterBodyDecoder("application/x-www-form-urlencoded", decodeForm)
+}
+
+// decodeForm decodes a form like kin-openapi's decoder, but leaves fields
+// the form does not contain out instead of setting them to null, which would
+// fail every optional non-nullable field.
+func decodeForm(body io.Reader, header http.Header, schema *openapi3.SchemaRef, encFn openapi3filter.EncodingFn) (any, error) {
+	value, err := openapi3filter.UrlencodedBodyDecoder(body, header, schema, encFn)
+	if obj, ok := value.(map[string]any); ok {
+		for name, v := range obj {
+			if v == nil {
+				delete(obj, name)
+			}
+		}
+	}
+	return value, err
+}
+
+// Spec is the parsed API description with a router over its operations.
+type Spec struct {
+	doc    *openapi3.T
+	json   []byte
+	router routers.Router
+}
+
+// Load parses and validates the embedded description.
+func Load() (*Spec, error) {
+	loader := openapi3.NewLoader()
+	doc, err := loader.LoadFromData(spec)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse OpenAPI description: %w", err)
+	}
+	if err := doc.Validate(context.Background()); err != nil {
+		return nil, fmt.Errorf("invalid OpenAPI description: %w", err)
+	}
+
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	router, err := gorillamux.NewRouter(doc)
+	if err != nil {
+		return nil, err
+	}
+	return &Spec{doc: doc, json: data, router: router}, nil
+}
+
+// Document returns the parsed description.
+func (s *Spec) Document() *openapi3.T {
+	return s.doc
+}
+
+// ServeJSON serves the description as JSON.
+func (s *Spec) ServeJSON(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(s.json)
+}
+
+// Validate rejects requests that do not match their operation in the
+// description with a validation problem naming the offending field. Requests
+// for routes the description does not cover are passed on untouched.
+func (s *Spec) Validate(next http.Handler) http.Handler {
+	options := &openapi3filter.Options{
+		AuthenticationFunc: openapi3filter.NoopAuthenticationFunc,
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route, pathParams, err := s.router.FindRoute(r)
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		input := &openapi3filter.RequestValidationInput{
+			Request:    r,
+			PathParams: pathParams,
+			Route:      route,
+			Options:    options,
+		}
+		if err := openapi3filter.ValidateRequest(r.Context(), input); err != nil {
+			problem.Render(w, r, validationError(err))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// validationError names the field a validation failure is about: the
+// parameter, or the path into the body using dots.
+func validationError(err error) error {
+	var requestErr *openapi3filter.RequestError
+	if !errors.As(err, &requestErr) {
+		return &domain.ValidationError{Field: "request", Reason: err.Error()}
+	}
+
+	field := "body"
+	if requestErr.Parameter != nil {
+		field = requestErr.Parameter.Name
+	}
+	reason := requestErr.Reason
+	if reason == "" && requestErr.Err != nil {
+		reason = requestErr.Err.Error()
+	}
+
+	var schemaErr *openapi3.SchemaError
+	if errors.As(requestErr.Err, &schemaErr) {
+		if pointer := schemaErr.JSONPointer(); len(pointer) > 0 && requestErr.Parameter == nil {
+			field = strings.Join(pointer, ".")
+		}
+		reason = schemaErr.Reason
+	}
+	return &domain.ValidationError{Field: field, Reason: reason}
+}
@@ -0,0 +1,111 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+	"time"
+
+	"github.com/luisteixeira/waypoint/backend/internal/middleware"
+)
+
+// Policy controls how events are retried. Events are retried until every
+// sink accepts them; there is no last attempt.
+type Policy struct {
+	// InitialBackoff is the wait after the first failure; it doubles after
+	// each further one, up to MaxBackoff.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+// Backoff returns how long to wait after the given number of failed attempts.
+func (p Policy) Backoff(attempts int) time.Duration {
+	wait := p.InitialBackoff
+	for i := 1; i < attempts && wait < p.MaxBackoff; i++ {
+		wait *= 2
+	}
+	return min(wait, p.MaxBackoff)
+}
+
+const (
+	// claimBatch is how many events one Dispatch publishes at most.
+	claimBatch = 100
+	// lease is how long claimed events stay hidden from other dispatchers.
+	lease = 5 * time.Minute
+)
+
+// Dispatcher publishes the events of a Store to its sinks.
+type Dispatcher struct {
+	store  Store
+	sinks  []Sink
+	policy Policy
+	now    func() time.Time
+}
+
+// NewDispatcher returns a dispatcher that claims events and schedules their
+// retries by the time now tells.
+func NewDispatcher(store Store, policy Policy, now func() time.Time, sinks ...Sink) *Dispatcher {
+	return &Dispatcher{store: store, sinks: sinks, policy: policy, now: now}
+}
+
+// Dispatch publishes the events due now, oldest first, and returns how many
+// every sink accepted.
+//
+// An event is only handed to the sinks that have not accepted it yet, and
+// the sinks that did are stored with the result. An event whose result was
+// never stored, because the process died while publishing it, is claimed
+// again once its lease runs out, so every sink sees every event at least
+// once.
+func (d *Dispatcher) Dispatch(ctx context.Context) (int, error) {
+	now := d.now()
+	due, err := d.store.ClaimDue(ctx, now, now.Add(lease), claimBatch)
+	if err != nil {
+		return 0, fmt.Errorf("failed to claim outbox events: %w", err)
+	}
+
+	published := 0
+	for _, event := range due {
+		if ctx.Err() != nil {
+			break
+		}
+		result := d.publish(ctx, event)
+		if result.Published {
+			published++
+		} else {
+			slog.WarnContext(ctx, "outbox event not published",
+				"event", event.ID, "type", event.Type, "attempt", event.Attempts+1, "error", result.Error)
+		}
+		if err := d.store.RecordResult(ctx, event.ID, result); err != nil {
+			return published, fmt.Errorf("failed to record outbox result: %w", err)
+		}
+	}
+	return published, nil
+}
+
+func (d *Dispatcher) publish(ctx context.Context, event Pending) Result {
+	ctx = context.WithValue(ctx, middleware.FamilyIDKey, event.FamilyID)
+	result := Result{Done: slices.Clone(event.Done)}
+
+	var errs []error
+	for _, sink := range d.sinks {
+		if slices.Contains(result.Done, sink.Name()) {
+			continue
+		}
+		if err := sink.Publish(ctx, event.Event); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", sink.Name(), err))
+			continue
+		}
+		result.Done = append(result.Done, sink.Name())
+	}
+
+	result.At = d.now()
+	if len(errs) == 0 {
+		result.Published = true
+		return result
+	}
+	result.Error = errors.Join(errs...).Error()
+	result.RetryAt = result.At.Add(d.policy.Backoff(event.Attempts + 1))
+	return result
+}
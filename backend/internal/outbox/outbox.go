@@ -0,0 +1,91 @@
+// Package outbox publishes what happens to activities without losing events
+// to a crash. Events are stored in the same unit of work as the change they
+// describe, and a Dispatcher hands them to every Sink afterwards, retrying
+// until each sink accepts them. A sink may see an event more than once, so
+// it should recognise repeats by the event ID.
+package outbox
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/luisteixeira/waypoint/backend/internal/domain"
+)
+
+type EventType string
+
+const (
+	EventActivityPlanned   EventType = "activity.planned"
+	EventActivityStarted   EventType = "activity.started"
+	EventActivityPaused    EventType = "activity.paused"
+	EventActivityResumed   EventType = "activity.resumed"
+	EventActivityCompleted EventType = "activity.completed"
+	EventActivityCancelled EventType = "activity.cancelled"
+	EventActivityEdited    EventType = "activity.edited"
+	EventActivityNoted     EventType = "activity.noted"
+	// EventActivityRebuilt follows a realization's projection being rebuilt
+	// from its stream, which may have changed it without a new event.
+	EventActivityRebuilt EventType = "activity.rebuilt"
+)
+
+// Event is a change to a realization, with the realization as it was right
+// after the change.
+type Event struct {
+	ID uuid.UUID
+	// Sequence orders the events of a store; it is set by Append.
+	Sequence int64
+	FamilyID uuid.UUID
+	// CaregiverID is the caregiver who made the change, or uuid.Nil when the
+	// client did not say.
+	CaregiverID uuid.UUID
+	Type        EventType
+	OccurredAt  time.Time
+	Realization *domain.ActivityRealization
+}
+
+// Pending is an event waiting to be published, with the sinks that already
+// accepted it.
+type Pending struct {
+	Event
+	Attempts      int
+	Done          []string
+	NextAttemptAt time.Time
+	LastError     string
+}
+
+// Result is the outcome of handing a pending event to the sinks once.
+type Result struct {
+	At time.Time
+	// Done lists every sink that has accepted the event so far.
+	Done []string
+	// Published is set once all sinks accepted the event; otherwise it is
+	// tried again at RetryAt.
+	Published bool
+	RetryAt   time.Time
+	Error     string
+}
+
+// Store keeps the outbox. Append works in the caller's family; the rest serve
+// the dispatcher across families.
+type Store interface {
+	// Append stores events, due at once, in the unit of work carried by ctx.
+	Append(ctx context.Context, events ...Event) error
+	// ClaimDue returns up to limit unpublished events due by now, oldest
+	// first, and hides them from other callers until leaseUntil.
+	ClaimDue(ctx context.Context, now, leaseUntil time.Time, limit int) ([]Pending, error)
+	// RecordResult stores the outcome of publishing a claimed event.
+	RecordResult(ctx context.Context, id uuid.UUID, result Result) error
+	// DeletePublished removes the events published before the given time and
+	// returns how many there were.
+	DeletePublished(ctx context.Context, before time.Time) (int64, error)
+}
+
+// Sink is somewhere events are published to.
+type Sink interface {
+	// Name identifies the sink in the outbox, so it must not change between
+	// releases.
+	Name() string
+	// Publish hands over one event. The context carries the event's family.
+	Publish(ctx context.Context, event Event) error
+}
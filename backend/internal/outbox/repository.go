@@ -0,0 +1,113 @@
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/luisteixeira/waypoint/backend/internal/domain"
+	"github.com/luisteixeira/waypoint/backend/internal/middleware"
+	"github.com/luisteixeira/waypoint/backend/internal/service"
+)
+
+// eventTypes maps the events of a realization's stream to the events
+// published about it.
+var eventTypes = map[domain.EventType]EventType{
+	domain.EventPlanned:   EventActivityPlanned,
+	domain.EventStarted:   EventActivityStarted,
+	domain.EventPaused:    EventActivityPaused,
+	domain.EventResumed:   EventActivityResumed,
+	domain.EventCompleted: EventActivityCompleted,
+	domain.EventCancelled: EventActivityCancelled,
+	domain.EventEdited:    EventActivityEdited,
+	domain.EventNoted:     EventActivityNoted,
+}
+
+// recordingRepo appends an event to the outbox for every change it saves.
+type recordingRepo struct {
+	service.ActivityRepository
+	store Store
+	tx    service.TxManager
+	now   func() time.Time
+}
+
+// NewActivityRepo wraps next so that every event saved to a realization's
+// stream, and every rebuild of its projection, appends an event to the outbox
+// in the same unit of work. A change that rolls back is never published and
+// one that commits always is, whichever service call made it. Rebuilds are
+// stamped with now.
+func NewActivityRepo(next service.ActivityRepository, store Store, tx service.TxManager, now func() time.Time) *recordingRepo {
+	return &recordingRepo{ActivityRepository: next, store: store, tx: tx, now: now}
+}
+
+func (r *recordingRepo) CreateRealization(ctx context.Context, ar *domain.ActivityRealization, events ...domain.RealizationEvent) error {
+	return r.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if err := r.ActivityRepository.CreateRealization(ctx, ar, events...); err != nil {
+			return err
+		}
+
+		// The stream is complete, so each event gets the realization as it
+		// was right after it. A realization started straight away was never
+		// planned as far as subscribers are concerned, so its planned event
+		// is not published.
+		var replayed domain.ActivityRealization
+		outgoing := make([]Event, 0, len(events))
+		for _, e := range events {
+			replayed.Apply(e)
+			if e.Type == domain.EventPlanned && ar.Status != domain.StatusPlanned {
+				continue
+			}
+			snapshot := replayed
+			snapshot.FamilyID, snapshot.Version = ar.FamilyID, ar.Version
+			outgoing = append(outgoing, newEvent(ctx, eventTypes[e.Type], e.OccurredAt, &snapshot))
+		}
+		return r.append(ctx, outgoing)
+	})
+}
+
+func (r *recordingRepo) UpdateRealization(ctx context.Context, ar *domain.ActivityRealization, events ...domain.RealizationEvent) error {
+	return r.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if err := r.ActivityRepository.UpdateRealization(ctx, ar, events...); err != nil {
+			return err
+		}
+
+		outgoing := make([]Event, len(events))
+		for i, e := range events {
+			outgoing[i] = newEvent(ctx, eventTypes[e.Type], e.OccurredAt, ar)
+		}
+		return r.append(ctx, outgoing)
+	})
+}
+
+func (r *recordingRepo) ReplaceRealization(ctx context.Context, ar *domain.ActivityRealization) error {
+	return r.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if err := r.ActivityRepository.ReplaceRealization(ctx, ar); err != nil {
+			return err
+		}
+		return r.append(ctx, []Event{newEvent(ctx, EventActivityRebuilt, r.now(), ar)})
+	})
+}
+
+func (r *recordingRepo) append(ctx context.Context, events []Event) error {
+	if len(events) == 0 {
+		return nil
+	}
+	if err := r.store.Append(ctx, events...); err != nil {
+		return fmt.Errorf("failed to append to the outbox: %w", err)
+	}
+	return nil
+}
+
+func newEvent(ctx context.Context, t EventType, occurredAt time.Time, ar *domain.ActivityRealization) Event {
+	caregiverID, _ := ctx.Value(middleware.CaregiverIDKey).(uuid.UUID)
+	realization := *ar
+	return Event{
+		ID:          uuid.New(),
+		FamilyID:    ar.FamilyID,
+		CaregiverID: caregiverID,
+		Type:        t,
+		OccurredAt:  occurredAt.UTC(),
+		Realization: &realization,
+	}
+}
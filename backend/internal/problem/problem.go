@@ -0,0 +1,101 @@
+// Package problem renders errors as RFC 7807 application/problem+json
+// responses.
+package problem
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/http"
+
+	"github.com/luisteixeira/waypoint/backend/internal/domain"
+)
+
+const ContentType = "application/problem+json"
+
+type Problem struct {
+	Type     string `json:"type"`
+	Title    string `json:"title"`
+	Status   int    `json:"status"`
+	Detail   string `json:"detail,omitempty"`
+	Instance string `json:"instance,omitempty"`
+
+	// Field names the offending input on validation problems.
+	Field string `json:"field,omitempty"`
+	// Current carries the latest state of a realization on version conflicts.
+	Current *domain.ActivityRealization `json:"current,omitempty"`
+}
+
+type kind struct {
+	target error
+	slug   string
+	title  string
+	status int
+}
+
+// kinds is checked in order, so more specific errors come first.
+var kinds = []kind{
+	{domain.ErrIdempotencyKeyReused, "idempotency-key-reused", "Idempotency key reused", http.StatusUnprocessableEntity},
+	{domain.ErrValidation, "validation", "Invalid request", http.StatusBadRequest},
+	{domain.ErrUnauthorized, "unauthorized", "Unauthorized", http.StatusUnauthorized},
+	{domain.ErrForbidden, "forbidden", "Forbidden", http.StatusForbidden},
+	{domain.ErrTooLarge, "too-large", "Request too large", http.StatusRequestEntityTooLarge},
+	{domain.ErrNotFound, "not-found", "Resource not found", http.StatusNotFound},
+	{domain.ErrInvalidTransition, "invalid-transition", "Invalid state transition", http.StatusConflict},
+	{domain.ErrConflict, "conflict", "Conflict", http.StatusConflict},
+}
+
+// From maps an error to the problem describing it. Errors that do not match a
+// domain error kind become an opaque internal server error.
+func From(err error) Problem {
+	for _, k := range kinds {
+		if !errors.Is(err, k.target) {
+			continue
+		}
+
+		p := Problem{
+			Type:   "/problems/" + k.slug,
+			Title:  k.title,
+			Status: k.status,
+			Detail: err.Error(),
+		}
+
+		var validation *domain.ValidationError
+		if errors.As(err, &validation) {
+			p.Field = validation.Field
+		}
+
+		var stale *domain.StaleVersionError
+		if errors.As(err, &stale) {
+			p.Type = "/problems/stale-version"
+			p.Current = stale.Current
+		}
+		return p
+	}
+
+	return Problem{
+		Type:   "about:blank",
+		Title:  http.StatusText(http.StatusInternalServerError),
+		Status: http.StatusInternalServerError,
+	}
+}
+
+// Render writes err as a problem response for the request.
+func Render(w http.ResponseWriter, r *http.Request, err error) {
+	p := From(err)
+	p.Instance = r.URL.Path
+
+	if p.Status == http.StatusInternalServerError {
+		slog.ErrorContext(r.Context(), "request failed", "method", r.Method, "path", r.URL.Path, "error", err)
+	} else {
+		slog.DebugContext(r.Context(), "request rejected", "method", r.Method, "path", r.URL.Path, "status", p.Status, "error", err)
+	}
+	if p.Current != nil {
+		w.Header().Set("ETag", fmt.Sprintf(`"%d"`, p.Current.Version))
+	}
+
+	w.Header().Set("Content-Type", ContentType)
+	w.WriteHeader(p.Status)
+	json.NewEncoder(w).Encode(p)
+}
@@ -0,0 +1,201 @@
+package push
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Push services accept 4096 bytes of encrypted content, which leaves this
+// much for the message once the header, padding delimiter and
+// authentication tag are taken off.
+const maxPayload = 4096 - headerSize - 1 - tagSize
+
+const (
+	saltSize   = 16
+	authSize   = 16
+	keySize    = 65
+	tagSize    = 16
+	headerSize = saltSize + 4 + 1 + keySize
+	recordSize = 4096
+)
+
+// ErrGone is returned by Send when the push service no longer knows the
+// subscription, because the browser unsubscribed or the subscription
+// expired. It should be deleted.
+var ErrGone = errors.New("push subscription is gone")
+
+// StatusError is a push service's refusal of a message.
+type StatusError struct {
+	Code int
+	Body string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("push service answered %d: %s", e.Code, e.Body)
+}
+
+// Temporary reports whether sending the message again later may succeed.
+func (e *StatusError) Temporary() bool {
+	return e.Code == http.StatusTooManyRequests || e.Code >= 500
+}
+
+// Options control how messages are sent.
+type Options struct {
+	// Subject is a mailto: or https: URL the push service can reach the
+	// operator at.
+	Subject string
+	// TTL is how long the push service keeps a message for a browser that
+	// is offline.
+	TTL     time.Duration
+	Timeout time.Duration
+}
+
+// Client sends messages to push services.
+type Client struct {
+	keys *Keys
+	opts Options
+	http *http.Client
+	now  func() time.Time
+}
+
+// NewClient returns a client that stamps VAPID tokens with the time now
+// tells.
+func NewClient(keys *Keys, opts Options, now func() time.Time) *Client {
+	return &Client{keys: keys, opts: opts, http: &http.Client{Timeout: opts.Timeout}, now: now}
+}
+
+// Send encrypts the message for the subscription's browser and posts it to
+// the push service.
+func (c *Client) Send(ctx context.Context, sub Subscription, msg Message) error {
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+	if len(payload) > maxPayload {
+		return fmt.Errorf("push message of %d bytes is over the %d byte limit", len(payload), maxPayload)
+	}
+	body, err := encrypt(sub, payload)
+	if err != nil {
+		return err
+	}
+	authorization, err := c.keys.authorization(sub.Endpoint, c.opts.Subject, c.now())
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, sub.Endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", authorization)
+	req.Header.Set("Content-Encoding", "aes128gcm")
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("TTL", strconv.Itoa(int(c.opts.TTL/time.Second)))
+	if msg.Urgency != "" {
+		req.Header.Set("Urgency", msg.Urgency)
+	}
+
+	res, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	switch {
+	case res.StatusCode >= 200 && res.StatusCode < 300:
+		return nil
+	case res.StatusCode == http.StatusNotFound || res.StatusCode == http.StatusGone:
+		return ErrGone
+	default:
+		text, _ := io.ReadAll(io.LimitReader(res.Body, 256))
+		return &StatusError{Code: res.StatusCode, Body: string(bytes.TrimSpace(text))}
+	}
+}
+
+// encrypt seals the payload for the browser as a single aes128gcm record
+// (RFC 8188), with the key agreed as RFC 8291 describes.
+func encrypt(sub Subscription, payload []byte) ([]byte, error) {
+	browserKey, authSecret, err := subscriptionKeys(sub)
+	if err != nil {
+		return nil, err
+	}
+
+	local, err := ecdh.P256().GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	shared, err := local.ECDH(browserKey)
+	if err != nil {
+		return nil, err
+	}
+	localPublic := local.PublicKey().Bytes()
+
+	info := append([]byte("WebPush: info\x00"), browserKey.Bytes()...)
+	info = append(info, localPublic...)
+	ikm, err := hkdf.Key(sha256.New, shared, authSecret, string(info), 32)
+	if err != nil {
+		return nil, err
+	}
+
+	salt := make([]byte, saltSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	cek, err := hkdf.Key(sha256.New, ikm, salt, "Content-Encoding: aes128gcm\x00", 16)
+	if err != nil {
+		return nil, err
+	}
+	nonce, err := hkdf.Key(sha256.New, ikm, salt, "Content-Encoding: nonce\x00", 12)
+	if err != nil {
+		return nil, err
+	}
+
+	block, err := aes.NewCipher(cek)
+	if err != nil {
+		return nil, err
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, err
+	}
+
+	body := make([]byte, 0, headerSize+len(payload)+1+tagSize)
+	body = append(body, salt...)
+	body = binary.BigEndian.AppendUint32(body, recordSize)
+	body = append(body, byte(len(localPublic)))
+	body = append(body, localPublic...)
+	// 0x02 marks the last record, with no padding after it.
+	plaintext := append(append([]byte{}, payload...), 0x02)
+	return gcm.Seal(body, nonce, plaintext, nil), nil
+}
+
+// subscriptionKeys decodes and checks the browser's keys.
+func subscriptionKeys(sub Subscription) (*ecdh.PublicKey, []byte, error) {
+	raw, err := decodeBase64(sub.P256DH)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid p256dh key: %w", err)
+	}
+	browserKey, err := ecdh.P256().NewPublicKey(raw)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid p256dh key: %w", err)
+	}
+	authSecret, err := decodeBase64(sub.Auth)
+	if err != nil || len(authSecret) != authSize {
+		return nil, nil, fmt.Errorf("invalid auth secret: must be %d bytes", authSize)
+	}
+	return browserKey, authSecret, nil
+}
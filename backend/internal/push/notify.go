@@ -0,0 +1,167 @@
+package push
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"slices"
+
+	"github.com/google/uuid"
+	"github.com/luisteixeira/waypoint/backend/internal/alert"
+	"github.com/luisteixeira/waypoint/backend/internal/outbox"
+	"github.com/luisteixeira/waypoint/backend/internal/service"
+)
+
+// Sink pushes activity starts and completions from the outbox to the
+// caregivers following the child. The caregiver who made the change, and
+// those taking part in the activity, are not told about it.
+type Sink struct {
+	store       Store
+	client      *Client
+	definitions service.DefinitionRepository
+}
+
+func NewSink(store Store, client *Client, definitions service.DefinitionRepository) *Sink {
+	return &Sink{store: store, client: client, definitions: definitions}
+}
+
+func (s *Sink) Name() string {
+	return "push"
+}
+
+// Publish returns an error only for messages the push services may accept
+// later, so that the outbox retries the event. Repeats carry the same tag,
+// so browsers that already showed the notification replace it.
+func (s *Sink) Publish(ctx context.Context, event outbox.Event) error {
+	ar := event.Realization
+	var wants func(Preferences) bool
+	var verb string
+	switch event.Type {
+	case outbox.EventActivityStarted:
+		wants, verb = func(p Preferences) bool { return p.ActivityStarted }, "started"
+	case outbox.EventActivityCompleted:
+		wants, verb = func(p Preferences) bool { return p.ActivityCompleted }, "completed"
+	default:
+		return nil
+	}
+	if ar == nil {
+		return nil
+	}
+
+	skip := append([]uuid.UUID{event.CaregiverID}, ar.CaregiversIDs...)
+	subs, err := recipients(ctx, s.store, ar.EntityID, wants, skip)
+	if err != nil || len(subs) == 0 {
+		return err
+	}
+
+	name := "An activity"
+	defs, err := s.definitions.ListByFamily(ctx)
+	if err != nil {
+		return fmt.Errorf("failed to list definitions: %w", err)
+	}
+	for _, def := range defs {
+		if def.ID == ar.DefinitionID {
+			name = def.Name
+		}
+	}
+
+	realizationID := ar.ID
+	return deliver(ctx, s.store, s.client, subs, Message{
+		Type:          string(event.Type),
+		Title:         fmt.Sprintf("%s %s", name, verb),
+		Tag:           event.ID.String(),
+		URL:           "/",
+		EntityID:      ar.EntityID,
+		RealizationID: &realizationID,
+		Urgency:       "normal",
+	})
+}
+
+// AlertNotifier pushes alerts to the caregivers following the alert's child.
+type AlertNotifier struct {
+	store  Store
+	client *Client
+}
+
+func NewAlertNotifier(store Store, client *Client) *AlertNotifier {
+	return &AlertNotifier{store: store, client: client}
+}
+
+func (n *AlertNotifier) Channel() alert.Channel {
+	return alert.ChannelPush
+}
+
+func (n *AlertNotifier) Notify(ctx context.Context, rule alert.Rule, a alert.Alert) error {
+	subs, err := recipients(ctx, n.store, a.EntityID, func(p Preferences) bool { return p.Alerts }, nil)
+	if err != nil || len(subs) == 0 {
+		return err
+	}
+
+	alertID := a.ID
+	return deliver(ctx, n.store, n.client, subs, Message{
+		Type:          "alert.triggered",
+		Title:         "Waypoint",
+		Body:          a.Message,
+		Tag:           a.ID.String(),
+		URL:           "/",
+		EntityID:      a.EntityID,
+		RealizationID: a.RealizationID,
+		AlertID:       &alertID,
+		Urgency:       "high",
+	})
+}
+
+// recipients returns the subscriptions of the family's caregivers who
+// follow the child and want the message, leaving out those in skip.
+// Caregivers without saved preferences follow no one, so hear nothing.
+func recipients(ctx context.Context, store Store, entityID uuid.UUID, wants func(Preferences) bool, skip []uuid.UUID) ([]Subscription, error) {
+	prefs, err := store.ListPreferences(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list push preferences: %w", err)
+	}
+	var caregivers []uuid.UUID
+	for _, p := range prefs {
+		if p.Follows(entityID) && wants(p) && !slices.Contains(skip, p.CaregiverID) {
+			caregivers = append(caregivers, p.CaregiverID)
+		}
+	}
+	if len(caregivers) == 0 {
+		return nil, nil
+	}
+
+	all, err := store.ListSubscriptions(ctx, uuid.Nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list push subscriptions: %w", err)
+	}
+	var subs []Subscription
+	for _, sub := range all {
+		if slices.Contains(caregivers, sub.CaregiverID) {
+			subs = append(subs, sub)
+		}
+	}
+	return subs, nil
+}
+
+// deliver sends the message to every subscription. Subscriptions the push
+// service reports gone are deleted and messages it refuses for good are
+// dropped; the errors that may clear up are returned.
+func deliver(ctx context.Context, store Store, client *Client, subs []Subscription, msg Message) error {
+	var errs []error
+	for _, sub := range subs {
+		err := client.Send(ctx, sub, msg)
+		var statusErr *StatusError
+		switch {
+		case err == nil:
+		case errors.Is(err, ErrGone):
+			if err := store.DeleteEndpoint(ctx, sub.Endpoint); err != nil {
+				errs = append(errs, fmt.Errorf("failed to delete push subscription %s: %w", sub.ID, err))
+			}
+		case errors.As(err, &statusErr) && !statusErr.Temporary():
+			slog.WarnContext(ctx, "Push service refused a message", "subscription_id", sub.ID, "error", err)
+		default:
+			errs = append(errs, fmt.Errorf("subscription %s: %w", sub.ID, err))
+		}
+	}
+	return errors.Join(errs...)
+}
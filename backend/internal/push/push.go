@@ -0,0 +1,104 @@
+// Package push sends Web Push notifications to caregivers' browsers. A
+// caregiver subscribes each browser they use and says which children they
+// follow; they then hear when another caregiver starts or completes an
+// activity for one of those children, and when an alert about one of them
+// fires. Messages are encrypted for the browser (RFC 8291) and signed with
+// the server's VAPID key (RFC 8292).
+package push
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Subscription is a browser that receives a caregiver's notifications, as
+// handed out by the browser's PushManager.
+type Subscription struct {
+	ID          uuid.UUID
+	FamilyID    uuid.UUID
+	CaregiverID uuid.UUID
+	// Endpoint is the push service URL messages are posted to. Only the
+	// browser and its push service know it, so it is kept private.
+	Endpoint string
+	// P256DH and Auth are the browser's keys, base64url-encoded.
+	P256DH    string
+	Auth      string
+	CreatedAt time.Time
+}
+
+// Preferences say what a caregiver is notified about.
+type Preferences struct {
+	FamilyID    uuid.UUID
+	CaregiverID uuid.UUID
+	// EntityIDs are the children the caregiver follows. Nothing is pushed
+	// about the others.
+	EntityIDs         []uuid.UUID
+	ActivityStarted   bool
+	ActivityCompleted bool
+	Alerts            bool
+	UpdatedAt         time.Time
+}
+
+// DefaultPreferences apply to a caregiver who has not saved any: every kind
+// of notification, but no children followed yet.
+func DefaultPreferences(familyID, caregiverID uuid.UUID) Preferences {
+	return Preferences{
+		FamilyID:          familyID,
+		CaregiverID:       caregiverID,
+		EntityIDs:         []uuid.UUID{},
+		ActivityStarted:   true,
+		ActivityCompleted: true,
+		Alerts:            true,
+	}
+}
+
+// Follows reports whether the caregiver follows the child.
+func (p Preferences) Follows(entityID uuid.UUID) bool {
+	return slices.Contains(p.EntityIDs, entityID)
+}
+
+// Store keeps subscriptions and preferences per family. Every method works in
+// the family the context carries.
+type Store interface {
+	// SaveSubscription stores a subscription, replacing any other with the
+	// same endpoint: a browser that subscribes again gets a new endpoint or
+	// keeps its old one, and either way only the latest registration counts.
+	SaveSubscription(ctx context.Context, sub *Subscription) error
+	// ListSubscriptions returns the caregiver's subscriptions, or the whole
+	// family's for uuid.Nil, oldest first.
+	ListSubscriptions(ctx context.Context, caregiverID uuid.UUID) ([]Subscription, error)
+	DeleteSubscription(ctx context.Context, caregiverID, id uuid.UUID) error
+	// DeleteEndpoint removes the subscription a push service reported gone.
+	DeleteEndpoint(ctx context.Context, endpoint string) error
+
+	// GetPreferences returns a domain.NotFoundError for a caregiver who has
+	// not saved any.
+	GetPreferences(ctx context.Context, caregiverID uuid.UUID) (*Preferences, error)
+	SavePreferences(ctx context.Context, prefs *Preferences) error
+	// ListPreferences returns the saved preferences of every caregiver in the
+	// family.
+	ListPreferences(ctx context.Context) ([]Preferences, error)
+}
+
+// Message is the payload of a notification. The service worker shows Title
+// and Body, and opens URL when the notification is clicked.
+type Message struct {
+	// Type is the webhook event type the message corresponds to, e.g.
+	// activity.started or alert.triggered.
+	Type  string `json:"type"`
+	Title string `json:"title"`
+	Body  string `json:"body,omitempty"`
+	// Tag makes a repeat of the same message replace the first one instead
+	// of showing twice.
+	Tag           string     `json:"tag"`
+	URL           string     `json:"url"`
+	EntityID      uuid.UUID  `json:"entity_id"`
+	RealizationID *uuid.UUID `json:"realization_id,omitempty"`
+	AlertID       *uuid.UUID `json:"alert_id,omitempty"`
+	// Urgency tells the push service how soon to wake the device: "normal"
+	// or "high".
+	Urgency string `json:"-"`
+}
@@ -0,0 +1,149 @@
+package push
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/luisteixeira/waypoint/backend/internal/domain"
+	"github.com/luisteixeira/waypoint/backend/internal/middleware"
+	"github.com/luisteixeira/waypoint/backend/internal/repository"
+)
+
+// SubscriptionInput is a browser's PushSubscription.
+type SubscriptionInput struct {
+	Endpoint string
+	P256DH   string
+	Auth     string
+}
+
+// PreferencesInput replaces a caregiver's preferences.
+type PreferencesInput struct {
+	EntityIDs         []uuid.UUID
+	ActivityStarted   bool
+	ActivityCompleted bool
+	Alerts            bool
+}
+
+// Service is what caregivers use to manage their subscriptions and
+// preferences. Every call acts for the caregiver the request identifies.
+type Service struct {
+	store     Store
+	publicKey string
+	now       func() time.Time
+}
+
+// NewService returns a service that stamps subscriptions and preferences with
+// the time now tells.
+func NewService(store Store, keys *Keys, now func() time.Time) *Service {
+	return &Service{store: store, publicKey: keys.PublicKey(), now: now}
+}
+
+// PublicKey is the VAPID key browsers subscribe with.
+func (s *Service) PublicKey() string {
+	return s.publicKey
+}
+
+func (s *Service) Subscribe(ctx context.Context, input SubscriptionInput) (*Subscription, error) {
+	caregiverID, err := caregiverFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	u, err := url.Parse(input.Endpoint)
+	if err != nil || u.Scheme != "https" || u.Host == "" {
+		return nil, &domain.ValidationError{Field: "endpoint", Reason: "must be an https URL"}
+	}
+	sub := &Subscription{
+		ID:          uuid.New(),
+		CaregiverID: caregiverID,
+		Endpoint:    input.Endpoint,
+		P256DH:      input.P256DH,
+		Auth:        input.Auth,
+		CreatedAt:   s.now().UTC().Truncate(time.Microsecond),
+	}
+	if _, _, err := subscriptionKeys(*sub); err != nil {
+		return nil, &domain.ValidationError{Field: "keys", Reason: err.Error()}
+	}
+
+	if err := s.store.SaveSubscription(ctx, sub); err != nil {
+		return nil, err
+	}
+	return sub, nil
+}
+
+func (s *Service) Subscriptions(ctx context.Context) ([]Subscription, error) {
+	caregiverID, err := caregiverFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s.store.ListSubscriptions(ctx, caregiverID)
+}
+
+func (s *Service) Unsubscribe(ctx context.Context, id uuid.UUID) error {
+	caregiverID, err := caregiverFromContext(ctx)
+	if err != nil {
+		return err
+	}
+	return s.store.DeleteSubscription(ctx, caregiverID, id)
+}
+
+// Preferences returns the caregiver's preferences, or the defaults if they
+// have not saved any.
+func (s *Service) Preferences(ctx context.Context) (*Preferences, error) {
+	caregiverID, err := caregiverFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	prefs, err := s.store.GetPreferences(ctx, caregiverID)
+	if errors.Is(err, domain.ErrNotFound) {
+		familyID, err := repository.GetFamilyIdFromContext(ctx)
+		if err != nil {
+			return nil, err
+		}
+		defaults := DefaultPreferences(familyID, caregiverID)
+		return &defaults, nil
+	}
+	return prefs, err
+}
+
+func (s *Service) SetPreferences(ctx context.Context, input PreferencesInput) (*Preferences, error) {
+	caregiverID, err := caregiverFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	entityIDs := []uuid.UUID{}
+	for _, id := range input.EntityIDs {
+		if id == uuid.Nil {
+			return nil, &domain.ValidationError{Field: "entity_ids", Reason: "must not contain the nil UUID"}
+		}
+		if !slices.Contains(entityIDs, id) {
+			entityIDs = append(entityIDs, id)
+		}
+	}
+
+	prefs := &Preferences{
+		CaregiverID:       caregiverID,
+		EntityIDs:         entityIDs,
+		ActivityStarted:   input.ActivityStarted,
+		ActivityCompleted: input.ActivityCompleted,
+		Alerts:            input.Alerts,
+		UpdatedAt:         s.now().UTC().Truncate(time.Microsecond),
+	}
+	if err := s.store.SavePreferences(ctx, prefs); err != nil {
+		return nil, err
+	}
+	return prefs, nil
+}
+
+// caregiverFromContext returns the caregiver the request identifies with
+// X-Caregiver-ID, which subscriptions and preferences belong to.
+func caregiverFromContext(ctx context.Context) (uuid.UUID, error) {
+	caregiverID, ok := ctx.Value(middleware.CaregiverIDKey).(uuid.UUID)
+	if !ok || caregiverID == uuid.Nil {
+		return uuid.Nil, &domain.ValidationError{Field: "X-Caregiver-ID", Reason: "is required to manage push notifications"}
+	}
+	return caregiverID, nil
+}
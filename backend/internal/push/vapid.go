@@ -0,0 +1,110 @@
+package push
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// tokenLifetime is how long a VAPID token is valid; push services reject
+// ones valid for more than a day.
+const tokenLifetime = 12 * time.Hour
+
+// Keys is the server's VAPID key pair, which push services use to tell its
+// messages apart from anyone else's.
+type Keys struct {
+	private *ecdsa.PrivateKey
+	public  []byte
+}
+
+// GenerateKeys returns a new key pair.
+func GenerateKeys() (*Keys, error) {
+	private, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	public, err := private.PublicKey.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	return &Keys{private: private, public: public}, nil
+}
+
+// ParseKeys reads a key pair in the form PublicKey and PrivateKey return
+// it: a base64url-encoded uncompressed P-256 point and scalar.
+func ParseKeys(publicKey, privateKey string) (*Keys, error) {
+	raw, err := decodeBase64(privateKey)
+	if err != nil {
+		return nil, fmt.Errorf("invalid VAPID private key: %w", err)
+	}
+	private, err := ecdsa.ParseRawPrivateKey(elliptic.P256(), raw)
+	if err != nil {
+		return nil, fmt.Errorf("invalid VAPID private key: %w", err)
+	}
+	public, err := private.PublicKey.Bytes()
+	if err != nil {
+		return nil, err
+	}
+	given, err := decodeBase64(publicKey)
+	if err != nil || !bytes.Equal(given, public) {
+		return nil, errors.New("the VAPID public key does not belong to the private key")
+	}
+	return &Keys{private: private, public: public}, nil
+}
+
+// PublicKey is the key browsers subscribe with, as their
+// applicationServerKey.
+func (k *Keys) PublicKey() string {
+	return base64.RawURLEncoding.EncodeToString(k.public)
+}
+
+func (k *Keys) PrivateKey() string {
+	raw, _ := k.private.Bytes()
+	return base64.RawURLEncoding.EncodeToString(raw)
+}
+
+// authorization returns the Authorization header for a message to the
+// endpoint: a token for the endpoint's origin, signed with the private key,
+// and the public key to check it with.
+func (k *Keys) authorization(endpoint, subject string, now time.Time) (string, error) {
+	u, err := url.Parse(endpoint)
+	if err != nil {
+		return "", err
+	}
+
+	header, _ := json.Marshal(map[string]string{"typ": "JWT", "alg": "ES256"})
+	claims, _ := json.Marshal(map[string]any{
+		"aud": u.Scheme + "://" + u.Host,
+		"exp": now.Add(tokenLifetime).Unix(),
+		"sub": subject,
+	})
+	signed := base64.RawURLEncoding.EncodeToString(header) + "." + base64.RawURLEncoding.EncodeToString(claims)
+
+	digest := sha256.Sum256([]byte(signed))
+	r, s, err := ecdsa.Sign(rand.Reader, k.private, digest[:])
+	if err != nil {
+		return "", err
+	}
+	// JWS wants the bare r and s, 32 bytes each, rather than ASN.1.
+	signature := make([]byte, 64)
+	r.FillBytes(signature[:32])
+	s.FillBytes(signature[32:])
+
+	token := signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+	return fmt.Sprintf("vapid t=%s, k=%s", token, k.PublicKey()), nil
+}
+
+// decodeBase64 reads the base64url keys of the Push API, which browsers
+// hand out without padding but some libraries pad.
+func decodeBase64(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+}
@@ -0,0 +1,299 @@
+package memory
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/luisteixeira/waypoint/backend/internal/domain"
+	"github.com/luisteixeira/waypoint/backend/internal/repository"
+)
+
+type InMemoryActivityRepo struct {
+	mu           sync.RWMutex
+	realizations map[uuid.UUID]domain.ActivityRealization
+	events       map[uuid.UUID][]domain.RealizationEvent
+	sequence     int64
+	// changed holds the change sequence each realization was last stamped
+	// with, for delta sync.
+	changed map[uuid.UUID]int64
+
+	// journal is set when the repository belongs to a persistent Store.
+	journal *journal
+}
+
+func NewInMemoryActivityRepo() *InMemoryActivityRepo {
+	return &InMemoryActivityRepo{
+		realizations: make(map[uuid.UUID]domain.ActivityRealization),
+		events:       make(map[uuid.UUID][]domain.RealizationEvent),
+		changed:      make(map[uuid.UUID]int64),
+	}
+}
+
+func (r *InMemoryActivityRepo) CreateRealization(ctx context.Context, activityRealization *domain.ActivityRealization, events ...domain.RealizationEvent) error {
+	familyID, err := repository.GetFamilyIdFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if activityRealization.ID == uuid.Nil {
+		activityRealization.ID = uuid.New()
+	}
+	activityRealization.FamilyID = familyID
+	if r.isBusy(activityRealization) {
+		return domain.ErrEntityBusy
+	}
+
+	next := clone(*activityRealization)
+	next.Version = 1
+	if err := r.save(ctx, next, events); err != nil {
+		return err
+	}
+	activityRealization.Version = next.Version
+	return nil
+}
+
+func (r *InMemoryActivityRepo) GetRealizationByID(ctx context.Context, id uuid.UUID) (*domain.ActivityRealization, error) {
+	familyID, err := repository.GetFamilyIdFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	res, ok := r.realizations[id]
+	if !ok || familyID != res.FamilyID {
+		return nil, &domain.NotFoundError{Resource: "activity realization", ID: id}
+	}
+	return clone(res), nil
+}
+
+func (r *InMemoryActivityRepo) GetActiveByEntity(ctx context.Context, entityID uuid.UUID) (*domain.ActivityRealization, error) {
+	familyID, err := repository.GetFamilyIdFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, ar := range r.realizations {
+		if ar.FamilyID == familyID &&
+			ar.EntityID == entityID &&
+			ar.Status == domain.StatusInProgress { // Explicit status check
+			return clone(ar), nil
+		}
+	}
+	return nil, nil
+}
+
+func (r *InMemoryActivityRepo) ListRealizations(ctx context.Context, filter domain.RealizationFilter) ([]domain.ActivityRealization, error) {
+	familyID, err := repository.GetFamilyIdFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	res := []domain.ActivityRealization{}
+	for _, ar := range r.realizations {
+		if ar.FamilyID != familyID ||
+			(filter.DefinitionID != uuid.Nil && ar.DefinitionID != filter.DefinitionID) ||
+			(filter.EntityID != uuid.Nil && ar.EntityID != filter.EntityID) ||
+			(len(filter.Statuses) > 0 && !slices.Contains(filter.Statuses, ar.Status)) ||
+			(filter.PlannedBefore != nil && (ar.PlannedFor == nil || ar.PlannedFor.After(*filter.PlannedBefore))) ||
+			(filter.StartedBefore != nil && (ar.StartedAt == nil || ar.StartedAt.After(*filter.StartedBefore))) {
+			continue
+		}
+		res = append(res, *clone(ar))
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		a, b := res[i].StartedAt, res[j].StartedAt
+		switch {
+		case a == nil || b == nil:
+			if (a == nil) != (b == nil) {
+				return b == nil
+			}
+		case !a.Equal(*b):
+			return a.After(*b)
+		}
+		return res[i].ID.String() < res[j].ID.String()
+	})
+	if filter.Limit > 0 && len(res) > filter.Limit {
+		res = res[:filter.Limit]
+	}
+	return res, nil
+}
+
+func (r *InMemoryActivityRepo) UpdateRealization(ctx context.Context, activityRealization *domain.ActivityRealization, events ...domain.RealizationEvent) error {
+	familyID, err := repository.GetFamilyIdFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	existing, ok := r.realizations[activityRealization.ID]
+	if !ok || existing.FamilyID != familyID {
+		return &domain.NotFoundError{Resource: "activity realization", ID: activityRealization.ID}
+	}
+	if existing.Version != activityRealization.Version {
+		return &domain.StaleVersionError{Current: clone(existing)}
+	}
+	activityRealization.FamilyID = familyID
+	if r.isBusy(activityRealization) {
+		return domain.ErrEntityBusy
+	}
+
+	next := clone(*activityRealization)
+	next.Version++
+	if err := r.save(ctx, next, events); err != nil {
+		return err
+	}
+	activityRealization.Version = next.Version
+	return nil
+}
+
+func (r *InMemoryActivityRepo) ListEvents(ctx context.Context, realizationID uuid.UUID) ([]domain.RealizationEvent, error) {
+	familyID, err := repository.GetFamilyIdFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var events []domain.RealizationEvent
+	for _, e := range r.events[realizationID] {
+		if e.FamilyID == familyID {
+			events = append(events, e)
+		}
+	}
+	if len(events) == 0 {
+		return nil, &domain.NotFoundError{Resource: "activity realization", ID: realizationID}
+	}
+	return events, nil
+}
+
+func (r *InMemoryActivityRepo) ReplaceRealization(ctx context.Context, activityRealization *domain.ActivityRealization) error {
+	familyID, err := repository.GetFamilyIdFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if existing, ok := r.realizations[activityRealization.ID]; ok && existing.FamilyID != familyID {
+		return &domain.NotFoundError{Resource: "activity realization", ID: activityRealization.ID}
+	}
+	activityRealization.FamilyID = familyID
+	if r.isBusy(activityRealization) {
+		return domain.ErrEntityBusy
+	}
+
+	next := clone(*activityRealization)
+	next.Version = r.realizations[activityRealization.ID].Version + 1
+	if err := r.save(ctx, next, nil); err != nil {
+		return err
+	}
+	activityRealization.Version = next.Version
+	return nil
+}
+
+// save stamps the events, writes the change to the journal if there is one
+// and only then applies it, so the log never misses a change readers have
+// seen. Inside a unit of work the change is applied at once and journaled
+// when the unit commits. save keeps its own copy of the realization so later
+// changes to the caller's value, including its caregivers slice, do not leak
+// into the repository. Callers must hold the lock.
+func (r *InMemoryActivityRepo) save(ctx context.Context, activityRealization *domain.ActivityRealization, events []domain.RealizationEvent) error {
+	for i := range events {
+		events[i].Sequence = r.sequence + int64(i) + 1
+		events[i].RealizationID = activityRealization.ID
+		events[i].FamilyID = activityRealization.FamilyID
+	}
+
+	change := entry{Realization: activityRealization, Events: events, Change: clock.next()}
+	if tx := txFromContext(ctx); tx != nil {
+		tx.record(r.journal, change, r.undoFor(activityRealization.ID))
+	} else if r.journal != nil {
+		if err := r.journal.append(change); err != nil {
+			return err
+		}
+	}
+
+	r.apply(activityRealization, events, change.Change)
+	return nil
+}
+
+// undoFor captures the realization's current state and returns a function
+// restoring it. Callers must hold the lock.
+func (r *InMemoryActivityRepo) undoFor(id uuid.UUID) func() {
+	previous, existed := r.realizations[id]
+	eventCount := len(r.events[id])
+	previousChange := r.changed[id]
+
+	return func() {
+		r.mu.Lock()
+		defer r.mu.Unlock()
+
+		if existed {
+			r.realizations[id] = previous
+			r.changed[id] = previousChange
+		} else {
+			delete(r.realizations, id)
+			delete(r.changed, id)
+		}
+		if eventCount == 0 {
+			delete(r.events, id)
+		} else {
+			r.events[id] = r.events[id][:eventCount]
+		}
+	}
+}
+
+// apply stores a change without journaling it. Callers must hold the lock.
+func (r *InMemoryActivityRepo) apply(activityRealization *domain.ActivityRealization, events []domain.RealizationEvent, change int64) {
+	r.realizations[activityRealization.ID] = *activityRealization
+	r.changed[activityRealization.ID] = change
+	for _, e := range events {
+		// Replaying the log over a snapshot may repeat events it already holds.
+		if e.Sequence <= r.sequence {
+			continue
+		}
+		r.sequence = e.Sequence
+		r.events[e.RealizationID] = append(r.events[e.RealizationID], e)
+	}
+}
+
+// isBusy mirrors the unique index on in-progress realizations: a child can
+// only take part in one activity at a time. Callers must hold the lock.
+func (r *InMemoryActivityRepo) isBusy(activityRealization *domain.ActivityRealization) bool {
+	if activityRealization.Status != domain.StatusInProgress {
+		return false
+	}
+	for id, ar := range r.realizations {
+		if id != activityRealization.ID &&
+			ar.FamilyID == activityRealization.FamilyID &&
+			ar.EntityID == activityRealization.EntityID &&
+			ar.Status == domain.StatusInProgress {
+			return true
+		}
+	}
+	return false
+}
+
+func clone(ar domain.ActivityRealization) *domain.ActivityRealization {
+	ar.CaregiversIDs = append([]uuid.UUID{}, ar.CaregiversIDs...)
+	return &ar
+}
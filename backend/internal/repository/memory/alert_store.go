@@ -0,0 +1,233 @@
+package memory
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/luisteixeira/waypoint/backend/internal/alert"
+	"github.com/luisteixeira/waypoint/backend/internal/domain"
+	"github.com/luisteixeira/waypoint/backend/internal/repository"
+)
+
+// InMemoryAlertStore keeps alert rules and alerts in memory. A Store's alert
+// store is snapshotted and logged like its realizations.
+type InMemoryAlertStore struct {
+	mu     sync.Mutex
+	rules  map[uuid.UUID]alert.Rule
+	alerts map[uuid.UUID]alert.Alert
+
+	// journal is set when the store belongs to a persistent Store.
+	journal *journal
+}
+
+func NewInMemoryAlertStore() *InMemoryAlertStore {
+	return &InMemoryAlertStore{
+		rules:  make(map[uuid.UUID]alert.Rule),
+		alerts: make(map[uuid.UUID]alert.Alert),
+	}
+}
+
+func (s *InMemoryAlertStore) CreateRule(ctx context.Context, rule *alert.Rule) error {
+	familyID, err := repository.GetFamilyIdFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rule.FamilyID = familyID
+	stored := *rule
+	stored.Channels = slices.Clone(rule.Channels)
+	return s.save(ctx, entry{AlertRule: &stored})
+}
+
+func (s *InMemoryAlertStore) ListRules(ctx context.Context) ([]alert.Rule, error) {
+	familyID, err := repository.GetFamilyIdFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.sortedRules(func(r alert.Rule) bool { return r.FamilyID == familyID }), nil
+}
+
+func (s *InMemoryAlertStore) DeleteRule(ctx context.Context, id uuid.UUID) error {
+	familyID, err := repository.GetFamilyIdFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	rule, ok := s.rules[id]
+	if !ok || rule.FamilyID != familyID {
+		return &domain.NotFoundError{Resource: "alert rule", ID: id}
+	}
+	return s.save(ctx, entry{AlertRule: &rule, Deleted: true})
+}
+
+func (s *InMemoryAlertStore) AllRules(ctx context.Context) ([]alert.Rule, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.sortedRules(func(alert.Rule) bool { return true }), nil
+}
+
+func (s *InMemoryAlertStore) CreateAlert(ctx context.Context, a *alert.Alert) error {
+	familyID, err := repository.GetFamilyIdFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Mirrors the unique index on unresolved alerts.
+	for _, existing := range s.alerts {
+		if existing.RuleID == a.RuleID && existing.Subject() == a.Subject() && existing.Status != alert.StatusResolved {
+			return alert.ErrAlreadyRaised
+		}
+	}
+	a.FamilyID = familyID
+	stored := cloneAlert(*a)
+	return s.save(ctx, entry{Alert: &stored})
+}
+
+func (s *InMemoryAlertStore) UpdateAlert(ctx context.Context, a *alert.Alert) error {
+	familyID, err := repository.GetFamilyIdFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.alerts[a.ID]
+	if !ok || stored.FamilyID != familyID {
+		return &domain.NotFoundError{Resource: "alert", ID: a.ID}
+	}
+	stored.Message = a.Message
+	stored.Status = a.Status
+	stored.SnoozedUntil = a.SnoozedUntil
+	stored.AcknowledgedAt = a.AcknowledgedAt
+	stored.ResolvedAt = a.ResolvedAt
+	stored = cloneAlert(stored)
+	return s.save(ctx, entry{Alert: &stored})
+}
+
+func (s *InMemoryAlertStore) GetAlert(ctx context.Context, id uuid.UUID) (*alert.Alert, error) {
+	familyID, err := repository.GetFamilyIdFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	a, ok := s.alerts[id]
+	if !ok || a.FamilyID != familyID {
+		return nil, &domain.NotFoundError{Resource: "alert", ID: id}
+	}
+	a = cloneAlert(a)
+	return &a, nil
+}
+
+func (s *InMemoryAlertStore) ListAlerts(ctx context.Context, statuses []alert.Status, limit int) ([]alert.Alert, error) {
+	familyID, err := repository.GetFamilyIdFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	alerts := []alert.Alert{}
+	for _, a := range s.alerts {
+		if a.FamilyID == familyID && slices.Contains(statuses, a.Status) {
+			alerts = append(alerts, cloneAlert(a))
+		}
+	}
+	sort.Slice(alerts, func(i, j int) bool {
+		if !alerts[i].TriggeredAt.Equal(alerts[j].TriggeredAt) {
+			return alerts[i].TriggeredAt.After(alerts[j].TriggeredAt)
+		}
+		return alerts[i].ID.String() < alerts[j].ID.String()
+	})
+	if limit > 0 && len(alerts) > limit {
+		alerts = alerts[:limit]
+	}
+	return alerts, nil
+}
+
+// save journals a change if the store belongs to a Store, and applies it.
+// The caller holds s.mu.
+func (s *InMemoryAlertStore) save(ctx context.Context, e entry) error {
+	return persist(ctx, &s.mu, s.journal, e, s.apply)
+}
+
+// apply stores a change without journaling it. Deleting a rule deletes its
+// alerts. The caller holds s.mu.
+func (s *InMemoryAlertStore) apply(e entry) func() {
+	switch {
+	case e.AlertRule != nil && e.Deleted:
+		undo := []func(){put(s.rules, e.AlertRule.ID, nil)}
+		for id, a := range s.alerts {
+			if a.RuleID == e.AlertRule.ID {
+				undo = append(undo, put(s.alerts, id, nil))
+			}
+		}
+		return undoAll(undo...)
+	case e.AlertRule != nil:
+		return put(s.rules, e.AlertRule.ID, e.AlertRule)
+	case e.Alert != nil:
+		return put(s.alerts, e.Alert.ID, e.Alert)
+	}
+	return func() {}
+}
+
+// sortedRules returns the rules matching keep, oldest first. The caller holds
+// s.mu.
+func (s *InMemoryAlertStore) sortedRules(keep func(alert.Rule) bool) []alert.Rule {
+	rules := []alert.Rule{}
+	for _, r := range s.rules {
+		if keep(r) {
+			r.Channels = slices.Clone(r.Channels)
+			rules = append(rules, r)
+		}
+	}
+	sort.Slice(rules, func(i, j int) bool {
+		if !rules[i].CreatedAt.Equal(rules[j].CreatedAt) {
+			return rules[i].CreatedAt.Before(rules[j].CreatedAt)
+		}
+		return rules[i].ID.String() < rules[j].ID.String()
+	})
+	return rules
+}
+
+// cloneAlert copies the alert's pointer fields, so stored alerts do not alias
+// the caller's.
+func cloneAlert(a alert.Alert) alert.Alert {
+	clonePtr := func(p *time.Time) *time.Time {
+		if p == nil {
+			return nil
+		}
+		v := *p
+		return &v
+	}
+	if a.RealizationID != nil {
+		id := *a.RealizationID
+		a.RealizationID = &id
+	}
+	a.SnoozedUntil = clonePtr(a.SnoozedUntil)
+	a.AcknowledgedAt = clonePtr(a.AcknowledgedAt)
+	a.ResolvedAt = clonePtr(a.ResolvedAt)
+	return a
+}
@@ -0,0 +1,95 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"sync"
+
+	"github.com/luisteixeira/waypoint/backend/internal/domain"
+	"github.com/luisteixeira/waypoint/backend/internal/repository"
+)
+
+// clock numbers changes for delta sync. It is shared by every repository in
+// the process so realizations and definitions are ordered together; the
+// numbers only need to grow.
+var clock changeClock
+
+type changeClock struct {
+	mu   sync.Mutex
+	last int64
+}
+
+func (c *changeClock) next() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.last++
+	return c.last
+}
+
+// observe moves the clock past a sequence loaded from disk.
+func (c *changeClock) observe(sequence int64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if sequence > c.last {
+		c.last = sequence
+	}
+}
+
+func (c *changeClock) current() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.last
+}
+
+// InMemoryChangeRepo derives the change feed from the sequences the
+// repositories stamp their objects with. Nothing is deleted in memory, so it
+// never returns tombstones, and there are no entities.
+//
+// Writers take their sequence while holding their repository's lock, and
+// ListChanges holds both, so every numbered change it could skip is already
+// visible. Changes made in a unit of work are visible before it commits;
+// read through the TxManager to see only committed ones.
+type InMemoryChangeRepo struct {
+	activities  *InMemoryActivityRepo
+	definitions *InMemoryDefintionRepo
+}
+
+func NewInMemoryChangeRepo(activities *InMemoryActivityRepo, definitions *InMemoryDefintionRepo) *InMemoryChangeRepo {
+	return &InMemoryChangeRepo{activities: activities, definitions: definitions}
+}
+
+func (r *InMemoryChangeRepo) ListChanges(ctx context.Context, after int64, limit int) ([]domain.Change, error) {
+	familyID, err := repository.GetFamilyIdFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	r.activities.mu.RLock()
+	defer r.activities.mu.RUnlock()
+	r.definitions.mu.RLock()
+	defer r.definitions.mu.RUnlock()
+
+	var changes []domain.Change
+	for id, sequence := range r.activities.changed {
+		ar := r.activities.realizations[id]
+		if sequence > after && ar.FamilyID == familyID {
+			changes = append(changes, domain.Change{Sequence: sequence, Kind: domain.ChangeRealization, ID: id, Realization: clone(ar)})
+		}
+	}
+	for id, sequence := range r.definitions.changed {
+		def := r.definitions.definitions[id]
+		if sequence > after && def.FamilyID == familyID {
+			changes = append(changes, domain.Change{Sequence: sequence, Kind: domain.ChangeDefinition, ID: id, Definition: &def})
+		}
+	}
+
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Sequence < changes[j].Sequence })
+	if len(changes) > limit {
+		changes = changes[:limit]
+	}
+	return changes, nil
+}
+
+func (r *InMemoryChangeRepo) LastSequence(ctx context.Context) (int64, error) {
+	return clock.current(), nil
+}
@@ -2,6 +2,7 @@ package memory
 
 import (
 	"context"
+	"sort"
 	"sync"
 
 	"github.com/google/uuid"
@@ -12,11 +13,18 @@ import (
 type InMemoryDefintionRepo struct {
 	mu          sync.RWMutex
 	definitions map[uuid.UUID]domain.ActivityDefinition
+	// changed holds the change sequence each definition was stamped with,
+	// for delta sync.
+	changed map[uuid.UUID]int64
+
+	// journal is set when the repository belongs to a persistent Store.
+	journal *journal
 }
 
 func NewInMemoryDefinitionRepo() *InMemoryDefintionRepo {
 	return &InMemoryDefintionRepo{
 		definitions: make(map[uuid.UUID]domain.ActivityDefinition),
+		changed:     make(map[uuid.UUID]int64),
 	}
 }
 
@@ -40,7 +48,21 @@ func (r *InMemoryDefintionRepo) GetOrCreateByName(ctx context.Context, name stri
 		FamilyID: familyID,
 		Name:     name,
 	}
+	change := entry{Definition: &newDef, Change: clock.next()}
+	if tx := txFromContext(ctx); tx != nil {
+		tx.record(r.journal, change, func() {
+			r.mu.Lock()
+			defer r.mu.Unlock()
+			delete(r.definitions, newDef.ID)
+			delete(r.changed, newDef.ID)
+		})
+	} else if r.journal != nil {
+		if err := r.journal.append(change); err != nil {
+			return nil, err
+		}
+	}
 	r.definitions[newDef.ID] = newDef
+	r.changed[newDef.ID] = change.Change
 	return &newDef, nil
 }
 
@@ -60,5 +82,6 @@ func (r *InMemoryDefintionRepo) ListByFamily(ctx context.Context) ([]domain.Acti
 		}
 	}
 
+	sort.Slice(defs, func(i, j int) bool { return defs[i].Name < defs[j].Name })
 	return defs, nil
 }
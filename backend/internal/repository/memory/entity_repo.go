@@ -0,0 +1,41 @@
+package memory
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/luisteixeira/waypoint/backend/internal/domain"
+	"github.com/luisteixeira/waypoint/backend/internal/repository"
+)
+
+// InMemoryEntityRepo derives the family's children from their realizations:
+// the memory backend keeps no entity records, so a child is known once an
+// activity was recorded for them. Their names are not known.
+type InMemoryEntityRepo struct {
+	activities *InMemoryActivityRepo
+}
+
+func NewInMemoryEntityRepo(activities *InMemoryActivityRepo) *InMemoryEntityRepo {
+	return &InMemoryEntityRepo{activities: activities}
+}
+
+func (r *InMemoryEntityRepo) ListByFamily(ctx context.Context) ([]domain.Entity, error) {
+	familyID, err := repository.GetFamilyIdFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	r.activities.mu.RLock()
+	defer r.activities.mu.RUnlock()
+
+	seen := make(map[uuid.UUID]bool)
+	var entities []domain.Entity
+	for _, ar := range r.activities.realizations {
+		if ar.FamilyID != familyID || seen[ar.EntityID] {
+			continue
+		}
+		seen[ar.EntityID] = true
+		entities = append(entities, domain.Entity{ID: ar.EntityID, FamilyID: familyID})
+	}
+	return entities, nil
+}
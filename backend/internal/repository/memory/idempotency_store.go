@@ -0,0 +1,136 @@
+package memory
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/luisteixeira/waypoint/backend/internal/idempotency"
+	"github.com/luisteixeira/waypoint/backend/internal/repository"
+)
+
+type idempotencyKey struct {
+	familyID uuid.UUID
+	key      string
+}
+
+// idempotencyRecord is how a Store persists a record, along with the family
+// it belongs to.
+type idempotencyRecord struct {
+	FamilyID uuid.UUID
+	idempotency.Record
+}
+
+// InMemoryIdempotencyStore keeps idempotency records in memory. Records of a
+// Store's idempotency store are snapshotted and logged like realizations.
+type InMemoryIdempotencyStore struct {
+	mu      sync.Mutex
+	records map[idempotencyKey]idempotency.Record
+
+	// journal is set when the store belongs to a persistent Store.
+	journal *journal
+}
+
+func NewInMemoryIdempotencyStore() *InMemoryIdempotencyStore {
+	return &InMemoryIdempotencyStore{
+		records: make(map[idempotencyKey]idempotency.Record),
+	}
+}
+
+func (s *InMemoryIdempotencyStore) Reserve(ctx context.Context, rec idempotency.Record) (*idempotency.Record, error) {
+	familyID, err := repository.GetFamilyIdFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k := idempotencyKey{familyID, rec.Key}
+	if existing, ok := s.records[k]; ok && existing.ExpiresAt.After(rec.CreatedAt) {
+		return &existing, nil
+	}
+
+	reserved := idempotencyRecord{FamilyID: familyID, Record: idempotency.Record{
+		Key:         rec.Key,
+		RequestHash: rec.RequestHash,
+		CreatedAt:   rec.CreatedAt,
+		ExpiresAt:   rec.ExpiresAt,
+	}}
+	return nil, s.save(ctx, entry{Idempotency: &reserved})
+}
+
+func (s *InMemoryIdempotencyStore) Complete(ctx context.Context, key string, status int, header http.Header, body []byte) error {
+	familyID, err := repository.GetFamilyIdFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k := idempotencyKey{familyID, key}
+	rec, ok := s.records[k]
+	if !ok {
+		return nil
+	}
+	rec.Status = status
+	rec.Header = header.Clone()
+	rec.Body = append([]byte(nil), body...)
+	return s.save(ctx, entry{Idempotency: &idempotencyRecord{FamilyID: familyID, Record: rec}})
+}
+
+func (s *InMemoryIdempotencyStore) Release(ctx context.Context, key string) error {
+	familyID, err := repository.GetFamilyIdFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	k := idempotencyKey{familyID, key}
+	if rec, ok := s.records[k]; ok && !rec.Completed() {
+		return s.save(ctx, entry{Idempotency: &idempotencyRecord{FamilyID: familyID, Record: rec}, Deleted: true})
+	}
+	return nil
+}
+
+func (s *InMemoryIdempotencyStore) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var expired []entry
+	for k, rec := range s.records {
+		if !rec.ExpiresAt.After(now) {
+			expired = append(expired, entry{Idempotency: &idempotencyRecord{FamilyID: k.familyID, Record: rec}, Deleted: true})
+		}
+	}
+	if len(expired) == 0 {
+		return 0, nil
+	}
+	if err := s.save(ctx, entry{Batch: expired}); err != nil {
+		return 0, err
+	}
+	return int64(len(expired)), nil
+}
+
+// save journals a change if the store belongs to a Store, and applies it.
+// Callers must hold the lock.
+func (s *InMemoryIdempotencyStore) save(ctx context.Context, e entry) error {
+	return persist(ctx, &s.mu, s.journal, e, s.apply)
+}
+
+// apply stores a change without journaling it. Callers must hold the lock.
+func (s *InMemoryIdempotencyStore) apply(e entry) func() {
+	if e.Idempotency == nil {
+		return func() {}
+	}
+	k := idempotencyKey{e.Idempotency.FamilyID, e.Idempotency.Key}
+	if e.Deleted {
+		return put(s.records, k, nil)
+	}
+	return put(s.records, k, &e.Idempotency.Record)
+}
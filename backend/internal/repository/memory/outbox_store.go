@@ -0,0 +1,143 @@
+package memory
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/luisteixeira/waypoint/backend/internal/outbox"
+	"github.com/luisteixeira/waypoint/backend/internal/repository"
+)
+
+// InMemoryOutboxStore keeps the outbox in memory. A Store's outbox is
+// snapshotted and logged like its realizations, and events appended in a unit
+// of work land in the same log line as the change they describe. The leases
+// ClaimDue takes are not logged: after a restart, claimed events are due
+// again. Events appended in a unit of work are removed if it rolls back.
+type InMemoryOutboxStore struct {
+	mu       sync.Mutex
+	sequence int64
+	events   map[uuid.UUID]outboxRecord
+
+	// journal is set when the store belongs to a persistent Store.
+	journal *journal
+}
+
+type outboxRecord struct {
+	outbox.Pending
+	PublishedAt *time.Time `json:"published_at,omitempty"`
+}
+
+func NewInMemoryOutboxStore() *InMemoryOutboxStore {
+	return &InMemoryOutboxStore{events: make(map[uuid.UUID]outboxRecord)}
+}
+
+func (s *InMemoryOutboxStore) Append(ctx context.Context, events ...outbox.Event) error {
+	familyID, err := repository.GetFamilyIdFromContext(ctx)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	appended := make([]entry, len(events))
+	for i, event := range events {
+		event.Sequence = s.sequence + int64(i) + 1
+		event.FamilyID = familyID
+		appended[i] = entry{Outbox: &outboxRecord{Pending: outbox.Pending{Event: event, NextAttemptAt: now}}}
+	}
+	if len(appended) == 0 {
+		return nil
+	}
+	return s.save(ctx, entry{Batch: appended})
+}
+
+func (s *InMemoryOutboxStore) ClaimDue(ctx context.Context, now, leaseUntil time.Time, limit int) ([]outbox.Pending, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var due []outboxRecord
+	for _, r := range s.events {
+		if r.PublishedAt == nil && !r.NextAttemptAt.After(now) {
+			due = append(due, r)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool { return due[i].Sequence < due[j].Sequence })
+	if len(due) > limit {
+		due = due[:limit]
+	}
+
+	claimed := make([]outbox.Pending, len(due))
+	for i, r := range due {
+		r.NextAttemptAt = leaseUntil
+		s.events[r.ID] = r
+		claimed[i] = r.Pending
+		claimed[i].Done = slices.Clone(r.Done)
+	}
+	return claimed, nil
+}
+
+func (s *InMemoryOutboxStore) RecordResult(ctx context.Context, id uuid.UUID, result outbox.Result) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	r, ok := s.events[id]
+	if !ok {
+		return nil
+	}
+	r.Attempts++
+	r.Done = slices.Clone(result.Done)
+	r.LastError = result.Error
+	if result.Published {
+		at := result.At
+		r.PublishedAt = &at
+	} else {
+		r.NextAttemptAt = result.RetryAt
+	}
+	return s.save(ctx, entry{Outbox: &r})
+}
+
+func (s *InMemoryOutboxStore) DeletePublished(ctx context.Context, before time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var deleted []entry
+	for _, r := range s.events {
+		if r.PublishedAt != nil && r.PublishedAt.Before(before) {
+			deleted = append(deleted, entry{Outbox: &r, Deleted: true})
+		}
+	}
+	if len(deleted) == 0 {
+		return 0, nil
+	}
+	if err := s.save(ctx, entry{Batch: deleted}); err != nil {
+		return 0, err
+	}
+	return int64(len(deleted)), nil
+}
+
+// save journals a change if the store belongs to a Store, and applies it.
+// The caller holds s.mu.
+func (s *InMemoryOutboxStore) save(ctx context.Context, e entry) error {
+	return persist(ctx, &s.mu, s.journal, e, s.apply)
+}
+
+// apply stores a change without journaling it. The sequence only grows, so
+// rolling an append back leaves a gap. The caller holds s.mu.
+func (s *InMemoryOutboxStore) apply(e entry) func() {
+	if e.Outbox == nil {
+		return func() {}
+	}
+	if e.Outbox.Sequence > s.sequence {
+		s.sequence = e.Outbox.Sequence
+	}
+	if e.Deleted {
+		return put(s.events, e.Outbox.ID, nil)
+	}
+	return put(s.events, e.Outbox.ID, e.Outbox)
+}
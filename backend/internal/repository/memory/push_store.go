@@ -0,0 +1,190 @@
+package memory
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"sync"
+
+	"github.com/google/uuid"
+	"github.com/luisteixeira/waypoint/backend/internal/domain"
+	"github.com/luisteixeira/waypoint/backend/internal/push"
+	"github.com/luisteixeira/waypoint/backend/internal/repository"
+)
+
+// InMemoryPushStore keeps push subscriptions and preferences in memory. A
+// Store's push store is snapshotted and logged like its realizations.
+type InMemoryPushStore struct {
+	mu            sync.Mutex
+	subscriptions map[uuid.UUID]push.Subscription
+	preferences   map[caregiverKey]push.Preferences
+
+	// journal is set when the store belongs to a persistent Store.
+	journal *journal
+}
+
+type caregiverKey struct {
+	familyID, caregiverID uuid.UUID
+}
+
+func NewInMemoryPushStore() *InMemoryPushStore {
+	return &InMemoryPushStore{
+		subscriptions: make(map[uuid.UUID]push.Subscription),
+		preferences:   make(map[caregiverKey]push.Preferences),
+	}
+}
+
+func (s *InMemoryPushStore) SaveSubscription(ctx context.Context, sub *push.Subscription) error {
+	familyID, err := repository.GetFamilyIdFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sub.FamilyID = familyID
+	stored := *sub
+	return s.save(ctx, entry{PushSubscription: &stored})
+}
+
+func (s *InMemoryPushStore) ListSubscriptions(ctx context.Context, caregiverID uuid.UUID) ([]push.Subscription, error) {
+	familyID, err := repository.GetFamilyIdFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	subs := []push.Subscription{}
+	for _, sub := range s.subscriptions {
+		if sub.FamilyID == familyID && (caregiverID == uuid.Nil || sub.CaregiverID == caregiverID) {
+			subs = append(subs, sub)
+		}
+	}
+	sort.Slice(subs, func(i, j int) bool {
+		if !subs[i].CreatedAt.Equal(subs[j].CreatedAt) {
+			return subs[i].CreatedAt.Before(subs[j].CreatedAt)
+		}
+		return subs[i].ID.String() < subs[j].ID.String()
+	})
+	return subs, nil
+}
+
+func (s *InMemoryPushStore) DeleteSubscription(ctx context.Context, caregiverID, id uuid.UUID) error {
+	familyID, err := repository.GetFamilyIdFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sub, ok := s.subscriptions[id]
+	if !ok || sub.FamilyID != familyID || sub.CaregiverID != caregiverID {
+		return &domain.NotFoundError{Resource: "push subscription", ID: id}
+	}
+	return s.save(ctx, entry{PushSubscription: &sub, Deleted: true})
+}
+
+func (s *InMemoryPushStore) DeleteEndpoint(ctx context.Context, endpoint string) error {
+	familyID, err := repository.GetFamilyIdFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var gone []entry
+	for _, sub := range s.subscriptions {
+		if sub.FamilyID == familyID && sub.Endpoint == endpoint {
+			gone = append(gone, entry{PushSubscription: &sub, Deleted: true})
+		}
+	}
+	if len(gone) == 0 {
+		return nil
+	}
+	return s.save(ctx, entry{Batch: gone})
+}
+
+func (s *InMemoryPushStore) GetPreferences(ctx context.Context, caregiverID uuid.UUID) (*push.Preferences, error) {
+	familyID, err := repository.GetFamilyIdFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	prefs, ok := s.preferences[caregiverKey{familyID, caregiverID}]
+	if !ok {
+		return nil, &domain.NotFoundError{Resource: "push preferences", ID: caregiverID}
+	}
+	prefs.EntityIDs = slices.Clone(prefs.EntityIDs)
+	return &prefs, nil
+}
+
+func (s *InMemoryPushStore) SavePreferences(ctx context.Context, prefs *push.Preferences) error {
+	familyID, err := repository.GetFamilyIdFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	prefs.FamilyID = familyID
+	stored := *prefs
+	stored.EntityIDs = slices.Clone(prefs.EntityIDs)
+	return s.save(ctx, entry{PushPreferences: &stored})
+}
+
+func (s *InMemoryPushStore) ListPreferences(ctx context.Context) ([]push.Preferences, error) {
+	familyID, err := repository.GetFamilyIdFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	all := []push.Preferences{}
+	for key, prefs := range s.preferences {
+		if key.familyID == familyID {
+			prefs.EntityIDs = slices.Clone(prefs.EntityIDs)
+			all = append(all, prefs)
+		}
+	}
+	sort.Slice(all, func(i, j int) bool { return all[i].CaregiverID.String() < all[j].CaregiverID.String() })
+	return all, nil
+}
+
+// save journals a change if the store belongs to a Store, and applies it.
+// The caller holds s.mu.
+func (s *InMemoryPushStore) save(ctx context.Context, e entry) error {
+	return persist(ctx, &s.mu, s.journal, e, s.apply)
+}
+
+// apply stores a change without journaling it. A subscription replaces any
+// other with the same endpoint. The caller holds s.mu.
+func (s *InMemoryPushStore) apply(e entry) func() {
+	switch {
+	case e.PushSubscription != nil && e.Deleted:
+		return put(s.subscriptions, e.PushSubscription.ID, nil)
+	case e.PushSubscription != nil:
+		var undo []func()
+		for id, existing := range s.subscriptions {
+			if existing.Endpoint == e.PushSubscription.Endpoint {
+				undo = append(undo, put(s.subscriptions, id, nil))
+			}
+		}
+		undo = append(undo, put(s.subscriptions, e.PushSubscription.ID, e.PushSubscription))
+		return undoAll(undo...)
+	case e.PushPreferences != nil:
+		k := caregiverKey{e.PushPreferences.FamilyID, e.PushPreferences.CaregiverID}
+		return put(s.preferences, k, e.PushPreferences)
+	}
+	return func() {}
+}
@@ -0,0 +1,523 @@
+package memory
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/luisteixeira/waypoint/backend/internal/alert"
+	"github.com/luisteixeira/waypoint/backend/internal/domain"
+	"github.com/luisteixeira/waypoint/backend/internal/push"
+	"github.com/luisteixeira/waypoint/backend/internal/webhook"
+)
+
+const (
+	snapshotFile = "snapshot.json"
+	logFile      = "changes.log"
+)
+
+// Store keeps the in-memory repositories and stores durable for servers
+// running without a database. Every change is appended to a log before it
+// becomes visible, and the whole state is periodically written to a snapshot
+// so the log stays short. Opening a Store loads the snapshot and replays the
+// log on top.
+type Store struct {
+	Activities  *InMemoryActivityRepo
+	Definitions *InMemoryDefintionRepo
+	Entities    *InMemoryEntityRepo
+	Changes     *InMemoryChangeRepo
+	Idempotency *InMemoryIdempotencyStore
+	Outbox      *InMemoryOutboxStore
+	Webhooks    *InMemoryWebhookStore
+	Alerts      *InMemoryAlertStore
+	Push        *InMemoryPushStore
+
+	dir     string
+	journal *journal
+	stop    chan struct{}
+	done    chan struct{}
+
+	mu          sync.Mutex
+	snapshotErr error
+}
+
+// snapshot is the complete state of a Store.
+type snapshot struct {
+	Sequence     int64                        `json:"sequence"`
+	Realizations []domain.ActivityRealization `json:"realizations"`
+	Events       []domain.RealizationEvent    `json:"events"`
+	Definitions  []domain.ActivityDefinition  `json:"definitions"`
+	// Changes holds the change sequence of every realization and definition.
+	Changes map[uuid.UUID]int64 `json:"changes,omitempty"`
+
+	Idempotency       []idempotencyRecord    `json:"idempotency,omitempty"`
+	Outbox            []outboxRecord         `json:"outbox,omitempty"`
+	OutboxSequence    int64                  `json:"outbox_sequence,omitempty"`
+	Webhooks          []webhook.Subscription `json:"webhooks,omitempty"`
+	Deliveries        []webhook.Delivery     `json:"deliveries,omitempty"`
+	AlertRules        []alert.Rule           `json:"alert_rules,omitempty"`
+	Alerts            []alert.Alert          `json:"alerts,omitempty"`
+	PushSubscriptions []push.Subscription    `json:"push_subscriptions,omitempty"`
+	PushPreferences   []push.Preferences     `json:"push_preferences,omitempty"`
+}
+
+// entry is one line of the append log. It carries the full new state of what
+// changed, so replaying an entry twice is harmless. A unit of work is logged
+// as a single entry holding a batch of changes.
+type entry struct {
+	Realization *domain.ActivityRealization `json:"realization,omitempty"`
+	Events      []domain.RealizationEvent   `json:"events,omitempty"`
+	Definition  *domain.ActivityDefinition  `json:"definition,omitempty"`
+	// Change is the change sequence the realization or definition was
+	// stamped with.
+	Change int64 `json:"change,omitempty"`
+
+	Idempotency      *idempotencyRecord    `json:"idempotency,omitempty"`
+	Outbox           *outboxRecord         `json:"outbox,omitempty"`
+	Webhook          *webhook.Subscription `json:"webhook,omitempty"`
+	Delivery         *webhook.Delivery     `json:"delivery,omitempty"`
+	AlertRule        *alert.Rule           `json:"alert_rule,omitempty"`
+	Alert            *alert.Alert          `json:"alert,omitempty"`
+	PushSubscription *push.Subscription    `json:"push_subscription,omitempty"`
+	PushPreferences  *push.Preferences     `json:"push_preferences,omitempty"`
+	// Deleted removes the record the entry holds instead of storing it.
+	// Realizations and definitions are never deleted.
+	Deleted bool `json:"deleted,omitempty"`
+
+	Batch []entry `json:"batch,omitempty"`
+}
+
+// Open loads the state persisted in dir, creating the directory if needed,
+// and snapshots it every snapshotInterval. A zero interval only snapshots on
+// Close.
+func Open(dir string, snapshotInterval time.Duration) (*Store, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create data directory: %w", err)
+	}
+
+	s := &Store{
+		Activities:  NewInMemoryActivityRepo(),
+		Definitions: NewInMemoryDefinitionRepo(),
+		Idempotency: NewInMemoryIdempotencyStore(),
+		Outbox:      NewInMemoryOutboxStore(),
+		Webhooks:    NewInMemoryWebhookStore(),
+		Alerts:      NewInMemoryAlertStore(),
+		Push:        NewInMemoryPushStore(),
+		dir:         dir,
+		stop:        make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+
+	if err := s.loadSnapshot(); err != nil {
+		return nil, err
+	}
+	f, err := s.replayLog()
+	if err != nil {
+		return nil, err
+	}
+	s.stampUnchanged()
+
+	s.Entities = NewInMemoryEntityRepo(s.Activities)
+	s.Changes = NewInMemoryChangeRepo(s.Activities, s.Definitions)
+	s.journal = &journal{file: f}
+	s.Activities.journal = s.journal
+	s.Definitions.journal = s.journal
+	s.Idempotency.journal = s.journal
+	s.Outbox.journal = s.journal
+	s.Webhooks.journal = s.journal
+	s.Alerts.journal = s.journal
+	s.Push.journal = s.journal
+
+	go s.snapshotLoop(snapshotInterval)
+	return s, nil
+}
+
+// Snapshot writes the current state to disk and empties the log.
+func (s *Store) Snapshot() error {
+	// Hold every lock so no change lands between the snapshot and the
+	// truncation of the log. Writers take a repository lock before the
+	// journal's, and so does this.
+	s.Activities.mu.RLock()
+	defer s.Activities.mu.RUnlock()
+	s.Definitions.mu.RLock()
+	defer s.Definitions.mu.RUnlock()
+	s.Idempotency.mu.Lock()
+	defer s.Idempotency.mu.Unlock()
+	s.Outbox.mu.Lock()
+	defer s.Outbox.mu.Unlock()
+	s.Webhooks.mu.Lock()
+	defer s.Webhooks.mu.Unlock()
+	s.Alerts.mu.Lock()
+	defer s.Alerts.mu.Unlock()
+	s.Push.mu.Lock()
+	defer s.Push.mu.Unlock()
+	s.journal.mu.Lock()
+	defer s.journal.mu.Unlock()
+
+	snap := snapshot{
+		Sequence:     s.Activities.sequence,
+		Realizations: []domain.ActivityRealization{},
+		Events:       []domain.RealizationEvent{},
+		Definitions:  []domain.ActivityDefinition{},
+		Changes:      make(map[uuid.UUID]int64),
+	}
+	for _, ar := range s.Activities.realizations {
+		snap.Realizations = append(snap.Realizations, ar)
+	}
+	for _, events := range s.Activities.events {
+		snap.Events = append(snap.Events, events...)
+	}
+	for _, d := range s.Definitions.definitions {
+		snap.Definitions = append(snap.Definitions, d)
+	}
+	for id, change := range s.Activities.changed {
+		snap.Changes[id] = change
+	}
+	for id, change := range s.Definitions.changed {
+		snap.Changes[id] = change
+	}
+	for k, rec := range s.Idempotency.records {
+		snap.Idempotency = append(snap.Idempotency, idempotencyRecord{FamilyID: k.familyID, Record: rec})
+	}
+	snap.OutboxSequence = s.Outbox.sequence
+	for _, r := range s.Outbox.events {
+		snap.Outbox = append(snap.Outbox, r)
+	}
+	for _, sub := range s.Webhooks.subscriptions {
+		snap.Webhooks = append(snap.Webhooks, sub)
+	}
+	for _, d := range s.Webhooks.deliveries {
+		snap.Deliveries = append(snap.Deliveries, d)
+	}
+	for _, rule := range s.Alerts.rules {
+		snap.AlertRules = append(snap.AlertRules, rule)
+	}
+	for _, a := range s.Alerts.alerts {
+		snap.Alerts = append(snap.Alerts, a)
+	}
+	for _, sub := range s.Push.subscriptions {
+		snap.PushSubscriptions = append(snap.PushSubscriptions, sub)
+	}
+	for _, prefs := range s.Push.preferences {
+		snap.PushPreferences = append(snap.PushPreferences, prefs)
+	}
+
+	if err := writeFileAtomic(filepath.Join(s.dir, snapshotFile), snap); err != nil {
+		return fmt.Errorf("failed to write snapshot: %w", err)
+	}
+	return s.journal.truncate()
+}
+
+// Check reports whether the last periodic snapshot failed, which usually
+// means the data directory is full or no longer writable.
+func (s *Store) Check(ctx context.Context) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.snapshotErr != nil {
+		return fmt.Errorf("last snapshot failed: %w", s.snapshotErr)
+	}
+	return nil
+}
+
+// Close stops the snapshot loop, takes a final snapshot and closes the log.
+func (s *Store) Close() error {
+	close(s.stop)
+	<-s.done
+
+	err := s.Snapshot()
+	return errors.Join(err, s.journal.file.Close())
+}
+
+func (s *Store) snapshotLoop(interval time.Duration) {
+	defer close(s.done)
+	if interval <= 0 {
+		<-s.stop
+		return
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			err := s.Snapshot()
+			if err != nil {
+				slog.Error("memory store snapshot failed", "error", err)
+			}
+			s.mu.Lock()
+			s.snapshotErr = err
+			s.mu.Unlock()
+		case <-s.stop:
+			return
+		}
+	}
+}
+
+func (s *Store) loadSnapshot() error {
+	data, err := os.ReadFile(filepath.Join(s.dir, snapshotFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read snapshot: %w", err)
+	}
+
+	var snap snapshot
+	if err := json.Unmarshal(data, &snap); err != nil {
+		return fmt.Errorf("failed to decode snapshot: %w", err)
+	}
+
+	for i := range snap.Realizations {
+		id := snap.Realizations[i].ID
+		s.Activities.realizations[id] = snap.Realizations[i]
+		if change, ok := snap.Changes[id]; ok {
+			s.Activities.changed[id] = change
+			clock.observe(change)
+		}
+	}
+	for _, e := range snap.Events {
+		s.Activities.events[e.RealizationID] = append(s.Activities.events[e.RealizationID], e)
+	}
+	for _, events := range s.Activities.events {
+		sort.Slice(events, func(i, j int) bool { return events[i].Sequence < events[j].Sequence })
+	}
+	s.Activities.sequence = snap.Sequence
+	for _, d := range snap.Definitions {
+		s.Definitions.definitions[d.ID] = d
+		if change, ok := snap.Changes[d.ID]; ok {
+			s.Definitions.changed[d.ID] = change
+			clock.observe(change)
+		}
+	}
+	for i := range snap.Idempotency {
+		s.Idempotency.apply(entry{Idempotency: &snap.Idempotency[i]})
+	}
+	s.Outbox.sequence = snap.OutboxSequence
+	for i := range snap.Outbox {
+		s.Outbox.apply(entry{Outbox: &snap.Outbox[i]})
+	}
+	for i := range snap.Webhooks {
+		s.Webhooks.apply(entry{Webhook: &snap.Webhooks[i]})
+	}
+	for i := range snap.Deliveries {
+		s.Webhooks.apply(entry{Delivery: &snap.Deliveries[i]})
+	}
+	for i := range snap.AlertRules {
+		s.Alerts.apply(entry{AlertRule: &snap.AlertRules[i]})
+	}
+	for i := range snap.Alerts {
+		s.Alerts.apply(entry{Alert: &snap.Alerts[i]})
+	}
+	for i := range snap.PushSubscriptions {
+		s.Push.apply(entry{PushSubscription: &snap.PushSubscriptions[i]})
+	}
+	for i := range snap.PushPreferences {
+		s.Push.apply(entry{PushPreferences: &snap.PushPreferences[i]})
+	}
+	return nil
+}
+
+// stampUnchanged numbers the objects loaded from files written before
+// changes were numbered, so the next sync returns them.
+func (s *Store) stampUnchanged() {
+	for id := range s.Activities.realizations {
+		if _, ok := s.Activities.changed[id]; !ok {
+			s.Activities.changed[id] = clock.next()
+		}
+	}
+	for id := range s.Definitions.definitions {
+		if _, ok := s.Definitions.changed[id]; !ok {
+			s.Definitions.changed[id] = clock.next()
+		}
+	}
+}
+
+// replayLog applies the log on top of the snapshot and returns it opened for
+// appending. A crash can leave a partly written last line behind; it is
+// dropped, since the change it held was never acknowledged.
+func (s *Store) replayLog() (*os.File, error) {
+	f, err := os.OpenFile(filepath.Join(s.dir, logFile), os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open change log: %w", err)
+	}
+
+	var valid int64
+	reader := bufio.NewReader(f)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("failed to read change log: %w", err)
+		}
+
+		var e entry
+		if err := json.Unmarshal(bytes.TrimSpace(line), &e); err != nil {
+			break
+		}
+		s.replay(e)
+		valid += int64(len(line))
+	}
+
+	if err := f.Truncate(valid); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("failed to trim change log: %w", err)
+	}
+	if _, err := f.Seek(valid, io.SeekStart); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return f, nil
+}
+
+func (s *Store) replay(e entry) {
+	if e.Change != 0 {
+		clock.observe(e.Change)
+	}
+	if e.Realization != nil {
+		s.Activities.apply(e.Realization, e.Events, e.Change)
+		if e.Change == 0 {
+			delete(s.Activities.changed, e.Realization.ID)
+		}
+	}
+	if e.Definition != nil {
+		s.Definitions.definitions[e.Definition.ID] = *e.Definition
+		if e.Change != 0 {
+			s.Definitions.changed[e.Definition.ID] = e.Change
+		}
+	}
+	s.Idempotency.apply(e)
+	s.Outbox.apply(e)
+	s.Webhooks.apply(e)
+	s.Alerts.apply(e)
+	s.Push.apply(e)
+	for _, change := range e.Batch {
+		s.replay(change)
+	}
+}
+
+// persist journals a change to a store and applies it. Outside a unit of
+// work the change is logged before it is applied; inside one it is applied
+// at once, logged when the unit commits and undone if it rolls back. apply
+// stores one entry, leaving its batch alone, and returns how to undo it. j
+// is nil for stores outside a Store. Callers must hold mu.
+func persist(ctx context.Context, mu sync.Locker, j *journal, e entry, apply func(entry) func()) error {
+	tx := txFromContext(ctx)
+	if tx == nil && j != nil {
+		if err := j.append(e); err != nil {
+			return err
+		}
+	}
+
+	undo := applyEntry(e, apply)
+	if tx != nil {
+		tx.record(j, e, func() {
+			mu.Lock()
+			defer mu.Unlock()
+			undo()
+		})
+	}
+	return nil
+}
+
+// applyEntry applies an entry and then its batch, and returns how to undo
+// them all.
+func applyEntry(e entry, apply func(entry) func()) func() {
+	undo := []func(){apply(e)}
+	for _, change := range e.Batch {
+		undo = append(undo, applyEntry(change, apply))
+	}
+	return undoAll(undo...)
+}
+
+func undoAll(undo ...func()) func() {
+	return func() {
+		for i := len(undo) - 1; i >= 0; i-- {
+			undo[i]()
+		}
+	}
+}
+
+// put stores v under k, or deletes k when v is nil, and returns how to
+// restore what k held before.
+func put[K comparable, V any](m map[K]V, k K, v *V) func() {
+	previous, existed := m[k]
+	if v == nil {
+		delete(m, k)
+	} else {
+		m[k] = *v
+	}
+	return func() {
+		if existed {
+			m[k] = previous
+		} else {
+			delete(m, k)
+		}
+	}
+}
+
+// journal is the append log shared by a Store's repositories.
+type journal struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+// append durably writes one change before the caller applies it.
+func (j *journal) append(e entry) error {
+	line, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if _, err := j.file.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("failed to append to change log: %w", err)
+	}
+	return j.file.Sync()
+}
+
+// truncate empties the log once a snapshot holds everything in it. Callers
+// must hold the lock.
+func (j *journal) truncate() error {
+	if err := j.file.Truncate(0); err != nil {
+		return fmt.Errorf("failed to truncate change log: %w", err)
+	}
+	_, err := j.file.Seek(0, io.SeekStart)
+	return err
+}
+
+func writeFileAtomic(path string, v any) error {
+	tmp := path + ".tmp"
+	f, err := os.Create(tmp)
+	if err != nil {
+		return err
+	}
+
+	if err := json.NewEncoder(f).Encode(v); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,83 @@
+package memory
+
+import (
+	"context"
+	"sync"
+
+	"github.com/luisteixeira/waypoint/backend/internal/service"
+)
+
+type txKey struct{}
+
+// memoryTx collects what a unit of work changed: how to undo each change and
+// the journal entries to write once it commits.
+type memoryTx struct {
+	undo    []func()
+	journal *journal
+	entries []entry
+}
+
+// TxManager gives the memory repositories units of work. Transactions are
+// serialized with each other; changes are visible to readers outside a
+// transaction before it commits, and are undone if it fails.
+type TxManager struct {
+	mu sync.Mutex
+}
+
+func NewTxManager() *TxManager {
+	return &TxManager{}
+}
+
+// WithinTx runs fn as one unit of work. A call made inside another unit of
+// work joins it.
+func (m *TxManager) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if txFromContext(ctx) != nil {
+		return fn(ctx)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	tx := &memoryTx{}
+	ctx, committed := service.CommitHooks(ctx)
+	if err := fn(context.WithValue(ctx, txKey{}, tx)); err != nil {
+		tx.rollback()
+		return err
+	}
+	if err := tx.commit(); err != nil {
+		tx.rollback()
+		return err
+	}
+	committed()
+	return nil
+}
+
+func txFromContext(ctx context.Context) *memoryTx {
+	tx, _ := ctx.Value(txKey{}).(*memoryTx)
+	return tx
+}
+
+// record registers a change applied inside the transaction. Repositories
+// outside a Store pass a nil journal.
+func (tx *memoryTx) record(j *journal, e entry, undo func()) {
+	tx.undo = append(tx.undo, undo)
+	if j != nil {
+		tx.journal = j
+		tx.entries = append(tx.entries, e)
+	}
+}
+
+// commit writes everything the transaction changed as a single log line, so
+// a crash either keeps or loses the unit of work as a whole.
+func (tx *memoryTx) commit() error {
+	if tx.journal == nil || len(tx.entries) == 0 {
+		return nil
+	}
+	return tx.journal.append(entry{Batch: tx.entries})
+}
+
+func (tx *memoryTx) rollback() {
+	for i := len(tx.undo) - 1; i >= 0; i-- {
+		tx.undo[i]()
+	}
+}
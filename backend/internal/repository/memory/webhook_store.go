@@ -0,0 +1,282 @@
+package memory
+
+import (
+	"context"
+	"encoding/json"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/luisteixeira/waypoint/backend/internal/domain"
+	"github.com/luisteixeira/waypoint/backend/internal/repository"
+	"github.com/luisteixeira/waypoint/backend/internal/webhook"
+)
+
+// InMemoryWebhookStore keeps webhook subscriptions and deliveries in memory.
+// A Store's webhook store is snapshotted and logged like its realizations,
+// except for the leases ClaimDue takes: after a restart, claimed deliveries
+// are due again. Deliveries queued in a unit of work are removed if it rolls
+// back.
+type InMemoryWebhookStore struct {
+	mu            sync.Mutex
+	subscriptions map[uuid.UUID]webhook.Subscription
+	deliveries    map[uuid.UUID]webhook.Delivery
+
+	// journal is set when the store belongs to a persistent Store.
+	journal *journal
+}
+
+func NewInMemoryWebhookStore() *InMemoryWebhookStore {
+	return &InMemoryWebhookStore{
+		subscriptions: make(map[uuid.UUID]webhook.Subscription),
+		deliveries:    make(map[uuid.UUID]webhook.Delivery),
+	}
+}
+
+func (s *InMemoryWebhookStore) CreateSubscription(ctx context.Context, sub *webhook.Subscription) error {
+	familyID, err := repository.GetFamilyIdFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sub.FamilyID = familyID
+	stored := *sub
+	stored.Events = append([]webhook.EventType(nil), sub.Events...)
+	return s.save(ctx, entry{Webhook: &stored})
+}
+
+func (s *InMemoryWebhookStore) ListSubscriptions(ctx context.Context) ([]webhook.Subscription, error) {
+	familyID, err := repository.GetFamilyIdFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	subs := []webhook.Subscription{}
+	for _, sub := range s.subscriptions {
+		if sub.FamilyID == familyID {
+			subs = append(subs, sub)
+		}
+	}
+	sort.Slice(subs, func(i, j int) bool {
+		if !subs[i].CreatedAt.Equal(subs[j].CreatedAt) {
+			return subs[i].CreatedAt.Before(subs[j].CreatedAt)
+		}
+		return subs[i].ID.String() < subs[j].ID.String()
+	})
+	return subs, nil
+}
+
+func (s *InMemoryWebhookStore) DeleteSubscription(ctx context.Context, id uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sub, err := s.subscription(ctx, id)
+	if err != nil {
+		return err
+	}
+	return s.save(ctx, entry{Webhook: &sub, Deleted: true})
+}
+
+func (s *InMemoryWebhookStore) Enqueue(ctx context.Context, event webhook.Event) ([]webhook.Delivery, error) {
+	familyID, err := repository.GetFamilyIdFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// An event the outbox hands over again is already queued; only the
+	// subscriptions it is new to get a delivery.
+	seen := map[uuid.UUID]bool{}
+	for _, d := range s.deliveries {
+		if d.EventID == event.ID {
+			seen[d.SubscriptionID] = true
+		}
+	}
+
+	deliveries := []webhook.Delivery{}
+	var queued []entry
+	for _, sub := range s.subscriptions {
+		if sub.FamilyID != familyID || !sub.Wants(event.Type) || seen[sub.ID] {
+			continue
+		}
+		d := webhook.Delivery{
+			ID:             uuid.New(),
+			SubscriptionID: sub.ID,
+			FamilyID:       familyID,
+			EventID:        event.ID,
+			EventType:      event.Type,
+			Payload:        payload,
+			Status:         webhook.DeliveryPending,
+			NextAttemptAt:  now,
+			CreatedAt:      now,
+		}
+		queued = append(queued, entry{Delivery: &d})
+		deliveries = append(deliveries, d)
+	}
+	if len(queued) == 0 {
+		return deliveries, nil
+	}
+	if err := s.save(ctx, entry{Batch: queued}); err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+func (s *InMemoryWebhookStore) ListDeliveries(ctx context.Context, subscriptionID uuid.UUID, limit int) ([]webhook.Delivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.subscription(ctx, subscriptionID); err != nil {
+		return nil, err
+	}
+
+	deliveries := []webhook.Delivery{}
+	for _, d := range s.deliveries {
+		if d.SubscriptionID == subscriptionID {
+			deliveries = append(deliveries, d)
+		}
+	}
+	sort.Slice(deliveries, func(i, j int) bool {
+		if !deliveries[i].CreatedAt.Equal(deliveries[j].CreatedAt) {
+			return deliveries[i].CreatedAt.After(deliveries[j].CreatedAt)
+		}
+		return deliveries[i].ID.String() < deliveries[j].ID.String()
+	})
+	if len(deliveries) > limit {
+		deliveries = deliveries[:limit]
+	}
+	return deliveries, nil
+}
+
+func (s *InMemoryWebhookStore) Redeliver(ctx context.Context, subscriptionID, deliveryID uuid.UUID) (*webhook.Delivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, err := s.subscription(ctx, subscriptionID); err != nil {
+		return nil, err
+	}
+	original, ok := s.deliveries[deliveryID]
+	if !ok || original.SubscriptionID != subscriptionID {
+		return nil, &domain.NotFoundError{Resource: "delivery", ID: deliveryID}
+	}
+
+	now := time.Now()
+	d := webhook.Delivery{
+		ID:             uuid.New(),
+		SubscriptionID: original.SubscriptionID,
+		FamilyID:       original.FamilyID,
+		EventID:        original.EventID,
+		EventType:      original.EventType,
+		Payload:        original.Payload,
+		Status:         webhook.DeliveryPending,
+		NextAttemptAt:  now,
+		CreatedAt:      now,
+	}
+	if err := s.save(ctx, entry{Delivery: &d}); err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+func (s *InMemoryWebhookStore) ClaimDue(ctx context.Context, now, leaseUntil time.Time, limit int) ([]webhook.Delivery, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var due []webhook.Delivery
+	for _, d := range s.deliveries {
+		if d.Status == webhook.DeliveryPending && !d.NextAttemptAt.After(now) {
+			due = append(due, d)
+		}
+	}
+	sort.Slice(due, func(i, j int) bool { return due[i].NextAttemptAt.Before(due[j].NextAttemptAt) })
+	if len(due) > limit {
+		due = due[:limit]
+	}
+
+	for i, d := range due {
+		d.NextAttemptAt = leaseUntil
+		s.deliveries[d.ID] = d
+
+		sub := s.subscriptions[d.SubscriptionID]
+		due[i].NextAttemptAt, due[i].URL, due[i].Secret = leaseUntil, sub.URL, sub.Secret
+	}
+	return due, nil
+}
+
+func (s *InMemoryWebhookStore) RecordAttempt(ctx context.Context, deliveryID uuid.UUID, attempt webhook.Attempt) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	d, ok := s.deliveries[deliveryID]
+	if !ok {
+		return nil
+	}
+	at := attempt.At
+	d.Status = attempt.Status()
+	d.Attempts++
+	d.LastAttemptAt = &at
+	d.ResponseStatus = attempt.ResponseStatus
+	d.LastError = attempt.Error
+	if attempt.RetryAt != nil {
+		d.NextAttemptAt = *attempt.RetryAt
+	}
+	if attempt.Delivered {
+		d.DeliveredAt = &at
+	}
+	return s.save(ctx, entry{Delivery: &d})
+}
+
+// subscription returns the family's subscription with the given id. The
+// caller holds s.mu.
+func (s *InMemoryWebhookStore) subscription(ctx context.Context, id uuid.UUID) (webhook.Subscription, error) {
+	familyID, err := repository.GetFamilyIdFromContext(ctx)
+	if err != nil {
+		return webhook.Subscription{}, err
+	}
+	sub, ok := s.subscriptions[id]
+	if !ok || sub.FamilyID != familyID {
+		return webhook.Subscription{}, &domain.NotFoundError{Resource: "webhook", ID: id}
+	}
+	return sub, nil
+}
+
+// save journals a change if the store belongs to a Store, and applies it.
+// The caller holds s.mu.
+func (s *InMemoryWebhookStore) save(ctx context.Context, e entry) error {
+	return persist(ctx, &s.mu, s.journal, e, s.apply)
+}
+
+// apply stores a change without journaling it. Deleting a subscription
+// deletes its deliveries. The caller holds s.mu.
+func (s *InMemoryWebhookStore) apply(e entry) func() {
+	switch {
+	case e.Webhook != nil && e.Deleted:
+		undo := []func(){put(s.subscriptions, e.Webhook.ID, nil)}
+		for id, d := range s.deliveries {
+			if d.SubscriptionID == e.Webhook.ID {
+				undo = append(undo, put(s.deliveries, id, nil))
+			}
+		}
+		return undoAll(undo...)
+	case e.Webhook != nil:
+		return put(s.subscriptions, e.Webhook.ID, e.Webhook)
+	case e.Delivery != nil:
+		return put(s.deliveries, e.Delivery.ID, e.Delivery)
+	}
+	return func() {}
+}
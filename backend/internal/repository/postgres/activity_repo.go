@@ -3,7 +3,9 @@ package postgres
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"fmt"
+	"strings"
 
 	"github.com/google/uuid"
 	"github.com/lib/pq"
@@ -19,38 +21,39 @@ func NewPostgresActivityRepo(db *sql.DB) *postgresActivityRepo {
 	return &postgresActivityRepo{db: db}
 }
 
-func (r *postgresActivityRepo) CreateRealization(ctx context.Context, activityRealization *domain.ActivityRealization) error {
+func (r *postgresActivityRepo) CreateRealization(ctx context.Context, activityRealization *domain.ActivityRealization, events ...domain.RealizationEvent) error {
 	familyID, err := repository.GetFamilyIdFromContext(ctx)
 	if err != nil {
 		return err
 	}
+	activityRealization.FamilyID = familyID
 
-	tx, err := r.db.BeginTx(ctx, nil)
-	if err != nil {
-		return err
+	if activityRealization.ID == uuid.Nil {
+		activityRealization.ID = uuid.New()
 	}
-	defer tx.Rollback()
 
-	err = tx.QueryRowContext(ctx, `
-		INSERT INTO activity_realizations (family_id, definition_id, entity_id, status, started_at)
-		VALUES ($1, $2, $3, $4, $5) RETURNING id`,
-		familyID, activityRealization.DefinitionID, activityRealization.EntityID, activityRealization.Status, activityRealization.StartedAt,
-	).Scan(&activityRealization.ID)
-	if err != nil {
-		return err
-	}
+	err = repository.InTx(ctx, r.db, func(tx repository.Querier) error {
+		if err := appendEvents(ctx, tx, activityRealization, events); err != nil {
+			return err
+		}
 
-	for _, caregiverID := range activityRealization.CaregiversIDs {
-		_, err := tx.ExecContext(ctx,
-			"INSERT INTO realization_caregivers (realization_id, caregiver_id) VALUES ($1, $2)",
-			activityRealization.ID, caregiverID,
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO activity_realizations (id, family_id, definition_id, entity_id, status, planned_for, started_at, finished_at, version)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, 1)`,
+			activityRealization.ID, familyID, activityRealization.DefinitionID, activityRealization.EntityID,
+			activityRealization.Status, activityRealization.PlannedFor, activityRealization.StartedAt, activityRealization.FinishedAt,
 		)
 		if err != nil {
-			return err
+			return mapError(err)
 		}
-	}
 
-	return tx.Commit()
+		return insertCaregivers(ctx, tx, activityRealization)
+	})
+	if err != nil {
+		return err
+	}
+	activityRealization.Version = 1
+	return nil
 }
 
 func (r *postgresActivityRepo) GetRealizationByID(ctx context.Context, id uuid.UUID) (*domain.ActivityRealization, error) {
@@ -62,7 +65,7 @@ func (r *postgresActivityRepo) GetRealizationByID(ctx context.Context, id uuid.U
 	query := `
 			SELECT
 				ar.id, ar.family_id, ar.definition_id, ar.entity_id, ar.status,
-				ar.started_at, ar.finished_at,
+				ar.planned_for, ar.started_at, ar.finished_at, ar.version,
 				COALESCE(array_agg(rc.caregiver_id) FILTER(WHERE rc.caregiver_id IS NOT NULL), '{}') as caregiver_ids
 			FROM activity_realizations ar
 			LEFT JOIN realization_caregivers rc ON ar.id = rc.realization_id
@@ -72,20 +75,22 @@ func (r *postgresActivityRepo) GetRealizationByID(ctx context.Context, id uuid.U
 	var activity_realization domain.ActivityRealization
 	var caregiverIDs []uuid.UUID
 
-	err = r.db.QueryRowContext(ctx, query, id, familyID).Scan(
+	err = repository.Conn(ctx, r.db).QueryRowContext(ctx, query, id, familyID).Scan(
 		&activity_realization.ID,
 		&activity_realization.FamilyID,
 		&activity_realization.DefinitionID,
 		&activity_realization.EntityID,
 		&activity_realization.Status,
+		&activity_realization.PlannedFor,
 		&activity_realization.StartedAt,
 		&activity_realization.FinishedAt,
+		&activity_realization.Version,
 		pq.Array(&caregiverIDs),
 	)
 
 	if err != nil {
 		if err == sql.ErrNoRows {
-			return nil, fmt.Errorf("Activity realization not found")
+			return nil, &domain.NotFoundError{Resource: "activity realization", ID: id}
 		}
 		return nil, fmt.Errorf("Failed to fetch realization: %w", err)
 	}
@@ -103,7 +108,7 @@ func (r *postgresActivityRepo) GetActiveByEntity(ctx context.Context, entityID u
 	query := `
 			SELECT
 				ar.id, ar.family_id, ar.definition_id, ar.entity_id, ar.status,
-				ar.started_at, ar.finished_at,
+				ar.planned_for, ar.started_at, ar.finished_at, ar.version,
 				COALESCE(array_agg(rc.caregiver_id) FILTER (WHERE rc.caregiver_id IS NOT NULL), '{}')
 			FROM activity_realizations as ar
 			LEFT JOIN realization_caregivers rc ON ar.id = rc.realization_id
@@ -115,9 +120,9 @@ func (r *postgresActivityRepo) GetActiveByEntity(ctx context.Context, entityID u
 	var ar domain.ActivityRealization
 	var caregiverIDs []uuid.UUID
 
-	err = r.db.QueryRowContext(ctx, query, entityID, familyID, domain.StatusInProgress).Scan(
+	err = repository.Conn(ctx, r.db).QueryRowContext(ctx, query, entityID, familyID, domain.StatusInProgress).Scan(
 		&ar.ID, &ar.FamilyID, &ar.DefinitionID, &ar.EntityID, &ar.Status,
-		&ar.StartedAt, &ar.FinishedAt, pq.Array(&caregiverIDs),
+		&ar.PlannedFor, &ar.StartedAt, &ar.FinishedAt, &ar.Version, pq.Array(&caregiverIDs),
 	)
 
 	if err != nil {
@@ -131,19 +136,241 @@ func (r *postgresActivityRepo) GetActiveByEntity(ctx context.Context, entityID u
 	return &ar, nil
 }
 
-func (r *postgresActivityRepo) UpdateRealization(ctx context.Context, activityRealization *domain.ActivityRealization) error {
+func (r *postgresActivityRepo) ListRealizations(ctx context.Context, filter domain.RealizationFilter) ([]domain.ActivityRealization, error) {
 	familyID, err := repository.GetFamilyIdFromContext(ctx)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
+	where := []string{"ar.family_id = $1"}
+	args := []any{familyID}
+	arg := func(v any) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+	if filter.DefinitionID != uuid.Nil {
+		where = append(where, "ar.definition_id = "+arg(filter.DefinitionID))
+	}
+	if filter.EntityID != uuid.Nil {
+		where = append(where, "ar.entity_id = "+arg(filter.EntityID))
+	}
+	if len(filter.Statuses) > 0 {
+		statuses := make([]string, len(filter.Statuses))
+		for i, status := range filter.Statuses {
+			statuses[i] = string(status)
+		}
+		where = append(where, "ar.status = ANY("+arg(pq.StringArray(statuses))+")")
+	}
+	if filter.PlannedBefore != nil {
+		where = append(where, "ar.planned_for <= "+arg(*filter.PlannedBefore))
+	}
+	if filter.StartedBefore != nil {
+		where = append(where, "ar.started_at <= "+arg(*filter.StartedBefore))
+	}
 	query := `
+			SELECT
+				ar.id, ar.family_id, ar.definition_id, ar.entity_id, ar.status,
+				ar.planned_for, ar.started_at, ar.finished_at, ar.version,
+				COALESCE(array_agg(rc.caregiver_id) FILTER(WHERE rc.caregiver_id IS NOT NULL), '{}') as caregiver_ids
+			FROM activity_realizations ar
+			LEFT JOIN realization_caregivers rc ON ar.id = rc.realization_id
+			WHERE ` + strings.Join(where, " AND ") + `
+			GROUP BY ar.id
+			ORDER BY ar.started_at DESC NULLS LAST, ar.id`
+	if filter.Limit > 0 {
+		query += " LIMIT " + arg(filter.Limit)
+	}
+
+	rows, err := repository.Conn(ctx, r.db).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list realizations: %w", err)
+	}
+	defer rows.Close()
+
+	res := []domain.ActivityRealization{}
+	for rows.Next() {
+		var ar domain.ActivityRealization
+		var caregiverIDs []uuid.UUID
+		err := rows.Scan(
+			&ar.ID, &ar.FamilyID, &ar.DefinitionID, &ar.EntityID, &ar.Status,
+			&ar.PlannedFor, &ar.StartedAt, &ar.FinishedAt, &ar.Version, pq.Array(&caregiverIDs),
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan realization: %w", err)
+		}
+		ar.CaregiversIDs = caregiverIDs
+		res = append(res, ar)
+	}
+	return res, rows.Err()
+}
+
+func (r *postgresActivityRepo) UpdateRealization(ctx context.Context, activityRealization *domain.ActivityRealization, events ...domain.RealizationEvent) error {
+	familyID, err := repository.GetFamilyIdFromContext(ctx)
+	if err != nil {
+		return err
+	}
+	activityRealization.FamilyID = familyID
+
+	var updated bool
+	err = repository.InTx(ctx, r.db, func(tx repository.Querier) error {
+		var err error
+		updated, err = updateProjection(ctx, tx, activityRealization, activityRealization.Version)
+		if err != nil || !updated {
+			return err
+		}
+		return appendEvents(ctx, tx, activityRealization, events)
+	})
+	if err != nil {
+		return err
+	}
+	if !updated {
+		return r.staleOrMissing(ctx, activityRealization.ID)
+	}
+	activityRealization.Version++
+	return nil
+}
+
+func (r *postgresActivityRepo) ListEvents(ctx context.Context, realizationID uuid.UUID) ([]domain.RealizationEvent, error) {
+	familyID, err := repository.GetFamilyIdFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := repository.Conn(ctx, r.db).QueryContext(ctx, `
+		SELECT sequence, realization_id, family_id, event_type, data, occurred_at
+		FROM realization_events
+		WHERE realization_id = $1 AND family_id = $2
+		ORDER BY sequence ASC`,
+		realizationID, familyID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list realization events: %w", err)
+	}
+	defer rows.Close()
+
+	var events []domain.RealizationEvent
+	for rows.Next() {
+		var e domain.RealizationEvent
+		var data []byte
+		if err := rows.Scan(&e.Sequence, &e.RealizationID, &e.FamilyID, &e.Type, &data, &e.OccurredAt); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(data, &e.Data); err != nil {
+			return nil, fmt.Errorf("failed to decode event %d: %w", e.Sequence, err)
+		}
+		events = append(events, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(events) == 0 {
+		return nil, &domain.NotFoundError{Resource: "activity realization", ID: realizationID}
+	}
+	return events, nil
+}
+
+func (r *postgresActivityRepo) ReplaceRealization(ctx context.Context, activityRealization *domain.ActivityRealization) error {
+	familyID, err := repository.GetFamilyIdFromContext(ctx)
+	if err != nil {
+		return err
+	}
+	activityRealization.FamilyID = familyID
+
+	return repository.InTx(ctx, r.db, func(tx repository.Querier) error {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO activity_realizations (id, family_id, definition_id, entity_id, status, planned_for, started_at, finished_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+			ON CONFLICT (id) DO NOTHING`,
+			activityRealization.ID, familyID, activityRealization.DefinitionID, activityRealization.EntityID,
+			activityRealization.Status, activityRealization.PlannedFor, activityRealization.StartedAt, activityRealization.FinishedAt,
+		)
+		if err != nil {
+			return mapError(err)
+		}
+
+		if _, err := updateProjection(ctx, tx, activityRealization, 0); err != nil {
+			return err
+		}
+
+		return tx.QueryRowContext(ctx,
+			"SELECT version FROM activity_realizations WHERE id = $1",
+			activityRealization.ID,
+		).Scan(&activityRealization.Version)
+	})
+}
+
+// staleOrMissing explains why a conditional update matched no rows.
+func (r *postgresActivityRepo) staleOrMissing(ctx context.Context, id uuid.UUID) error {
+	current, err := r.GetRealizationByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	return &domain.StaleVersionError{Current: current}
+}
+
+func appendEvents(ctx context.Context, tx repository.Querier, activityRealization *domain.ActivityRealization, events []domain.RealizationEvent) error {
+	for i := range events {
+		events[i].RealizationID = activityRealization.ID
+		events[i].FamilyID = activityRealization.FamilyID
+
+		data, err := json.Marshal(events[i].Data)
+		if err != nil {
+			return err
+		}
+
+		err = tx.QueryRowContext(ctx, `
+			INSERT INTO realization_events (realization_id, family_id, event_type, data, occurred_at)
+			VALUES ($1, $2, $3, $4, $5) RETURNING sequence`,
+			events[i].RealizationID, events[i].FamilyID, events[i].Type, data, events[i].OccurredAt,
+		).Scan(&events[i].Sequence)
+		if err != nil {
+			return fmt.Errorf("failed to append %s event: %w", events[i].Type, err)
+		}
+	}
+	return nil
+}
+
+// updateProjection overwrites the stored realization and bumps its version.
+// A non-zero expectedVersion makes the write conditional on the stored
+// version; it reports false when no row matched.
+func updateProjection(ctx context.Context, tx repository.Querier, activityRealization *domain.ActivityRealization, expectedVersion int) (bool, error) {
+	res, err := tx.ExecContext(ctx, `
 			UPDATE activity_realizations
-			SET status = $1, started_at=$2, finished_at = $3
-			WHERE id = $4 and family_id = $5
-	`
+			SET definition_id = $1, status = $2, planned_for = $3, started_at = $4, finished_at = $5, version = version + 1
+			WHERE id = $6 and family_id = $7 AND ($8 = 0 OR version = $8)`,
+		activityRealization.DefinitionID, activityRealization.Status, activityRealization.PlannedFor, activityRealization.StartedAt,
+		activityRealization.FinishedAt, activityRealization.ID, activityRealization.FamilyID, expectedVersion,
+	)
+	if err != nil {
+		return false, mapError(err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	if affected == 0 {
+		return false, nil
+	}
+
+	_, err = tx.ExecContext(ctx, "DELETE FROM realization_caregivers WHERE realization_id = $1", activityRealization.ID)
+	if err != nil {
+		return false, err
+	}
 
-	_, err = r.db.ExecContext(ctx, query, activityRealization.Status, activityRealization.StartedAt, activityRealization.FinishedAt,
-		activityRealization.ID, familyID)
-	return err
+	return true, insertCaregivers(ctx, tx, activityRealization)
+}
+
+func insertCaregivers(ctx context.Context, tx repository.Querier, activityRealization *domain.ActivityRealization) error {
+	for _, caregiverID := range activityRealization.CaregiversIDs {
+		_, err := tx.ExecContext(ctx,
+			"INSERT INTO realization_caregivers (realization_id, caregiver_id) VALUES ($1, $2)",
+			activityRealization.ID, caregiverID,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
 }
@@ -0,0 +1,242 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/luisteixeira/waypoint/backend/internal/alert"
+	"github.com/luisteixeira/waypoint/backend/internal/domain"
+	"github.com/luisteixeira/waypoint/backend/internal/repository"
+)
+
+// unresolvedAlertIndex allows at most one unresolved alert per rule and
+// subject.
+const unresolvedAlertIndex = "uq_unresolved_alert"
+
+const ruleColumns = `id, family_id, kind, definition_id, entity_id, threshold_seconds, channels, email, created_at`
+
+const alertColumns = `
+	id, family_id, rule_id, kind, definition_id, entity_id, realization_id, message,
+	status, triggered_at, snoozed_until, acknowledged_at, resolved_at`
+
+type postgresAlertStore struct {
+	db *sql.DB
+}
+
+func NewPostgresAlertStore(db *sql.DB) *postgresAlertStore {
+	return &postgresAlertStore{db: db}
+}
+
+func (s *postgresAlertStore) CreateRule(ctx context.Context, rule *alert.Rule) error {
+	familyID, err := repository.GetFamilyIdFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	rule.FamilyID = familyID
+	channels := make([]string, len(rule.Channels))
+	for i, c := range rule.Channels {
+		channels[i] = string(c)
+	}
+	entityID := uuid.NullUUID{UUID: rule.EntityID, Valid: rule.EntityID != uuid.Nil}
+	_, err = repository.Conn(ctx, s.db).ExecContext(ctx, `
+		INSERT INTO alert_rules (`+ruleColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`,
+		rule.ID, familyID, rule.Kind, rule.DefinitionID, entityID,
+		int64(rule.Threshold/time.Second), pq.Array(channels), rule.Email, rule.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create alert rule: %w", err)
+	}
+	return nil
+}
+
+func (s *postgresAlertStore) ListRules(ctx context.Context) ([]alert.Rule, error) {
+	familyID, err := repository.GetFamilyIdFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s.rules(ctx, "WHERE family_id = $1", familyID)
+}
+
+func (s *postgresAlertStore) AllRules(ctx context.Context) ([]alert.Rule, error) {
+	return s.rules(ctx, "")
+}
+
+func (s *postgresAlertStore) rules(ctx context.Context, where string, args ...any) ([]alert.Rule, error) {
+	rows, err := repository.Conn(ctx, s.db).QueryContext(ctx, `
+		SELECT `+ruleColumns+`
+		FROM alert_rules `+where+`
+		ORDER BY created_at, id`,
+		args...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list alert rules: %w", err)
+	}
+	defer rows.Close()
+
+	rules := []alert.Rule{}
+	for rows.Next() {
+		var rule alert.Rule
+		var entityID uuid.NullUUID
+		var threshold int64
+		var channels []string
+		err := rows.Scan(
+			&rule.ID, &rule.FamilyID, &rule.Kind, &rule.DefinitionID, &entityID,
+			&threshold, pq.Array(&channels), &rule.Email, &rule.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		rule.EntityID = entityID.UUID
+		rule.Threshold = time.Duration(threshold) * time.Second
+		for _, c := range channels {
+			rule.Channels = append(rule.Channels, alert.Channel(c))
+		}
+		rules = append(rules, rule)
+	}
+	return rules, rows.Err()
+}
+
+func (s *postgresAlertStore) DeleteRule(ctx context.Context, id uuid.UUID) error {
+	familyID, err := repository.GetFamilyIdFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	// The rule's alerts go with it, by cascade.
+	res, err := repository.Conn(ctx, s.db).ExecContext(ctx, "DELETE FROM alert_rules WHERE id = $1 AND family_id = $2", id, familyID)
+	if err != nil {
+		return fmt.Errorf("failed to delete alert rule: %w", err)
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return &domain.NotFoundError{Resource: "alert rule", ID: id}
+	}
+	return nil
+}
+
+func (s *postgresAlertStore) CreateAlert(ctx context.Context, a *alert.Alert) error {
+	familyID, err := repository.GetFamilyIdFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	a.FamilyID = familyID
+	_, err = repository.Conn(ctx, s.db).ExecContext(ctx, `
+		INSERT INTO alerts (`+alertColumns+`, subject_id)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)`,
+		a.ID, familyID, a.RuleID, a.Kind, a.DefinitionID, a.EntityID, a.RealizationID, a.Message,
+		a.Status, a.TriggeredAt, a.SnoozedUntil, a.AcknowledgedAt, a.ResolvedAt,
+		a.Subject(),
+	)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == uniqueViolation && pqErr.Constraint == unresolvedAlertIndex {
+		return alert.ErrAlreadyRaised
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create alert: %w", err)
+	}
+	return nil
+}
+
+func (s *postgresAlertStore) UpdateAlert(ctx context.Context, a *alert.Alert) error {
+	familyID, err := repository.GetFamilyIdFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	res, err := repository.Conn(ctx, s.db).ExecContext(ctx, `
+		UPDATE alerts
+		SET message = $1, status = $2, snoozed_until = $3, acknowledged_at = $4, resolved_at = $5
+		WHERE id = $6 AND family_id = $7`,
+		a.Message, a.Status, a.SnoozedUntil, a.AcknowledgedAt, a.ResolvedAt,
+		a.ID, familyID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update alert: %w", err)
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return &domain.NotFoundError{Resource: "alert", ID: a.ID}
+	}
+	return nil
+}
+
+func (s *postgresAlertStore) GetAlert(ctx context.Context, id uuid.UUID) (*alert.Alert, error) {
+	familyID, err := repository.GetFamilyIdFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	a, err := scanAlert(repository.Conn(ctx, s.db).QueryRowContext(ctx, `
+		SELECT `+alertColumns+`
+		FROM alerts
+		WHERE id = $1 AND family_id = $2`,
+		id, familyID,
+	))
+	if err == sql.ErrNoRows {
+		return nil, &domain.NotFoundError{Resource: "alert", ID: id}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch alert: %w", err)
+	}
+	return a, nil
+}
+
+func (s *postgresAlertStore) ListAlerts(ctx context.Context, statuses []alert.Status, limit int) ([]alert.Alert, error) {
+	familyID, err := repository.GetFamilyIdFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, len(statuses))
+	for i, status := range statuses {
+		names[i] = string(status)
+	}
+	args := []any{familyID, pq.Array(names)}
+	query := `
+		SELECT ` + alertColumns + `
+		FROM alerts
+		WHERE family_id = $1 AND status = ANY($2)
+		ORDER BY triggered_at DESC, id`
+	if limit > 0 {
+		query += ` LIMIT $3`
+		args = append(args, limit)
+	}
+
+	rows, err := repository.Conn(ctx, s.db).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list alerts: %w", err)
+	}
+	defer rows.Close()
+
+	alerts := []alert.Alert{}
+	for rows.Next() {
+		a, err := scanAlert(rows)
+		if err != nil {
+			return nil, err
+		}
+		alerts = append(alerts, *a)
+	}
+	return alerts, rows.Err()
+}
+
+func scanAlert(row interface{ Scan(dest ...any) error }) (*alert.Alert, error) {
+	var a alert.Alert
+	err := row.Scan(
+		&a.ID, &a.FamilyID, &a.RuleID, &a.Kind, &a.DefinitionID, &a.EntityID, &a.RealizationID, &a.Message,
+		&a.Status, &a.TriggeredAt, &a.SnoozedUntil, &a.AcknowledgedAt, &a.ResolvedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
@@ -0,0 +1,124 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/luisteixeira/waypoint/backend/internal/domain"
+	"github.com/luisteixeira/waypoint/backend/internal/repository"
+)
+
+type postgresChangeRepo struct {
+	db *sql.DB
+}
+
+// NewPostgresChangeRepo reads the sync_changes table the triggers of
+// migration 7 maintain.
+func NewPostgresChangeRepo(db *sql.DB) *postgresChangeRepo {
+	return &postgresChangeRepo{db: db}
+}
+
+func (r *postgresChangeRepo) ListChanges(ctx context.Context, after int64, limit int) ([]domain.Change, error) {
+	familyID, err := repository.GetFamilyIdFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// One statement sees one snapshot, so each object's state is the one its
+	// change was recorded for or a later one.
+	rows, err := repository.Conn(ctx, r.db).QueryContext(ctx, `
+		SELECT
+			c.sequence, c.kind, c.object_id, c.deleted,
+			ar.id, ar.definition_id, ar.entity_id, ar.status, ar.planned_for, ar.started_at, ar.finished_at, ar.version,
+			COALESCE((SELECT array_agg(rc.caregiver_id) FROM realization_caregivers rc WHERE rc.realization_id = ar.id), '{}'),
+			d.id, d.name, d.description, d.color_code,
+			e.id, e.name, e.date_of_birth
+		FROM sync_changes c
+		LEFT JOIN activity_realizations ar ON c.kind = 'realization' AND NOT c.deleted AND ar.id = c.object_id
+		LEFT JOIN activity_definitions d ON c.kind = 'definition' AND NOT c.deleted AND d.id = c.object_id
+		LEFT JOIN entities e ON c.kind = 'entity' AND NOT c.deleted AND e.id = c.object_id
+		WHERE c.family_id = $1 AND c.sequence > $2
+		ORDER BY c.sequence ASC
+		LIMIT $3`,
+		familyID, after, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list changes: %w", err)
+	}
+	defer rows.Close()
+
+	var changes []domain.Change
+	for rows.Next() {
+		var c domain.Change
+		var (
+			arID, definitionID, entityID uuid.NullUUID
+			status                       sql.NullString
+			plannedFor                   *time.Time
+			startedAt, finishedAt        *time.Time
+			version                      sql.NullInt64
+			caregiverIDs                 []uuid.UUID
+
+			defID                  uuid.NullUUID
+			defName                sql.NullString
+			description, colorCode *string
+
+			entID       uuid.NullUUID
+			entName     sql.NullString
+			dateOfBirth *time.Time
+		)
+		err := rows.Scan(
+			&c.Sequence, &c.Kind, &c.ID, &c.Deleted,
+			&arID, &definitionID, &entityID, &status, &plannedFor, &startedAt, &finishedAt, &version, pq.Array(&caregiverIDs),
+			&defID, &defName, &description, &colorCode,
+			&entID, &entName, &dateOfBirth,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case arID.Valid:
+			c.Realization = &domain.ActivityRealization{
+				ID:            arID.UUID,
+				FamilyID:      familyID,
+				DefinitionID:  definitionID.UUID,
+				EntityID:      entityID.UUID,
+				CaregiversIDs: caregiverIDs,
+				Status:        domain.ActivityStatus(status.String),
+				PlannedFor:    plannedFor,
+				StartedAt:     startedAt,
+				FinishedAt:    finishedAt,
+				Version:       int(version.Int64),
+			}
+		case defID.Valid:
+			c.Definition = &domain.ActivityDefinition{
+				ID:          defID.UUID,
+				FamilyID:    familyID,
+				Name:        defName.String,
+				Description: description,
+				ColorCode:   colorCode,
+			}
+		case entID.Valid:
+			c.Entity = &domain.Entity{ID: entID.UUID, FamilyID: familyID, Name: entName.String, DateOfBirth: dateOfBirth}
+		default:
+			c.Deleted = true
+		}
+		changes = append(changes, c)
+	}
+	return changes, rows.Err()
+}
+
+func (r *postgresChangeRepo) LastSequence(ctx context.Context) (int64, error) {
+	var last int64
+	err := repository.Conn(ctx, r.db).QueryRowContext(ctx,
+		"SELECT CASE WHEN is_called THEN last_value ELSE 0 END FROM sync_change_seq",
+	).Scan(&last)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read the change sequence: %w", err)
+	}
+	return last, nil
+}
@@ -31,7 +31,7 @@ func (r *postgresDefinitionRepo) GetOrCreateByName(ctx context.Context, name str
 	`
 
 	var def domain.ActivityDefinition
-	err = r.db.QueryRowContext(ctx, query, familyID, name).Scan(
+	err = repository.Conn(ctx, r.db).QueryRowContext(ctx, query, familyID, name).Scan(
 		&def.ID, &def.FamilyID, &def.Name, &def.Description, &def.ColorCode,
 	)
 
@@ -47,7 +47,7 @@ func (r *postgresDefinitionRepo) ListByFamily(ctx context.Context) ([]domain.Act
 		return nil, err
 	}
 
-	rows, err := r.db.QueryContext(ctx,
+	rows, err := repository.Conn(ctx, r.db).QueryContext(ctx,
 		`SELECT id, family_id, name, description, color_code
 		FROM activity_definitions
 		WHERE family_id = $1 ORDER BY name ASC`,
@@ -0,0 +1,46 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/luisteixeira/waypoint/backend/internal/domain"
+	"github.com/luisteixeira/waypoint/backend/internal/repository"
+)
+
+type postgresEntityRepo struct {
+	db *sql.DB
+}
+
+func NewPostgresEntityRepo(db *sql.DB) *postgresEntityRepo {
+	return &postgresEntityRepo{db: db}
+}
+
+func (r *postgresEntityRepo) ListByFamily(ctx context.Context) ([]domain.Entity, error) {
+	familyID, err := repository.GetFamilyIdFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := repository.Conn(ctx, r.db).QueryContext(ctx,
+		`SELECT id, family_id, name, date_of_birth
+		FROM entities
+		WHERE family_id = $1 ORDER BY name ASC`,
+		familyID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list entities: %w", err)
+	}
+	defer rows.Close()
+
+	var entities []domain.Entity
+	for rows.Next() {
+		var e domain.Entity
+		if err := rows.Scan(&e.ID, &e.FamilyID, &e.Name, &e.DateOfBirth); err != nil {
+			return nil, err
+		}
+		entities = append(entities, e)
+	}
+	return entities, rows.Err()
+}
@@ -0,0 +1,31 @@
+package postgres
+
+import (
+	"errors"
+
+	"github.com/lib/pq"
+	"github.com/luisteixeira/waypoint/backend/internal/domain"
+)
+
+const (
+	uniqueViolation     = "23505"
+	foreignKeyViolation = "23503"
+	undefinedTable      = "42P01"
+
+	// activeEntityIndex allows at most one in-progress realization per child.
+	activeEntityIndex = "uq_active_entity_realization"
+)
+
+// mapError translates constraint violations into domain errors.
+func mapError(err error) error {
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == uniqueViolation && pqErr.Constraint == activeEntityIndex {
+		return domain.ErrEntityBusy
+	}
+	return err
+}
+
+func isUndefinedTable(err error) bool {
+	var pqErr *pq.Error
+	return errors.As(err, &pqErr) && pqErr.Code == undefinedTable
+}
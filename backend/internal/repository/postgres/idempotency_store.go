@@ -0,0 +1,122 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/luisteixeira/waypoint/backend/internal/idempotency"
+	"github.com/luisteixeira/waypoint/backend/internal/repository"
+)
+
+type postgresIdempotencyStore struct {
+	db *sql.DB
+}
+
+func NewPostgresIdempotencyStore(db *sql.DB) *postgresIdempotencyStore {
+	return &postgresIdempotencyStore{db: db}
+}
+
+func (s *postgresIdempotencyStore) Reserve(ctx context.Context, rec idempotency.Record) (*idempotency.Record, error) {
+	familyID, err := repository.GetFamilyIdFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// An expired record is taken over as if the key were new.
+	res, err := repository.Conn(ctx, s.db).ExecContext(ctx, `
+		INSERT INTO idempotency_keys (family_id, key, request_hash, created_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (family_id, key) DO UPDATE SET
+			request_hash = EXCLUDED.request_hash,
+			status_code = NULL,
+			response_headers = NULL,
+			response_body = NULL,
+			created_at = EXCLUDED.created_at,
+			expires_at = EXCLUDED.expires_at
+		WHERE idempotency_keys.expires_at <= EXCLUDED.created_at`,
+		familyID, rec.Key, rec.RequestHash, rec.CreatedAt, rec.ExpiresAt,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to reserve idempotency key: %w", err)
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return nil, err
+	} else if n == 1 {
+		return nil, nil
+	}
+
+	existing := idempotency.Record{Key: rec.Key}
+	var status sql.NullInt64
+	var header []byte
+	err = repository.Conn(ctx, s.db).QueryRowContext(ctx, `
+		SELECT request_hash, status_code, response_headers, response_body, created_at, expires_at
+		FROM idempotency_keys
+		WHERE family_id = $1 AND key = $2`,
+		familyID, rec.Key,
+	).Scan(&existing.RequestHash, &status, &header, &existing.Body, &existing.CreatedAt, &existing.ExpiresAt)
+	if errors.Is(err, sql.ErrNoRows) {
+		// Released between the two statements; the client can retry.
+		return nil, idempotency.ErrRequestInProgress
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load idempotency key: %w", err)
+	}
+
+	existing.Status = int(status.Int64)
+	if header != nil {
+		if err := json.Unmarshal(header, &existing.Header); err != nil {
+			return nil, fmt.Errorf("failed to decode stored response headers: %w", err)
+		}
+	}
+	return &existing, nil
+}
+
+func (s *postgresIdempotencyStore) Complete(ctx context.Context, key string, status int, header http.Header, body []byte) error {
+	familyID, err := repository.GetFamilyIdFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	encoded, err := json.Marshal(header)
+	if err != nil {
+		return err
+	}
+	_, err = repository.Conn(ctx, s.db).ExecContext(ctx, `
+		UPDATE idempotency_keys
+		SET status_code = $3, response_headers = $4, response_body = $5
+		WHERE family_id = $1 AND key = $2`,
+		familyID, key, status, encoded, body,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to store idempotent response: %w", err)
+	}
+	return nil
+}
+
+func (s *postgresIdempotencyStore) Release(ctx context.Context, key string) error {
+	familyID, err := repository.GetFamilyIdFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	_, err = repository.Conn(ctx, s.db).ExecContext(ctx,
+		"DELETE FROM idempotency_keys WHERE family_id = $1 AND key = $2 AND status_code IS NULL",
+		familyID, key,
+	)
+	return err
+}
+
+func (s *postgresIdempotencyStore) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	res, err := repository.Conn(ctx, s.db).ExecContext(ctx,
+		"DELETE FROM idempotency_keys WHERE expires_at <= $1", now,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete expired idempotency keys: %w", err)
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,272 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/luisteixeira/waypoint/backend/internal/repository"
+	"github.com/luisteixeira/waypoint/backend/migrations"
+)
+
+// migrationLockID keys the advisory lock that keeps replicas starting at the
+// same time from migrating concurrently.
+const migrationLockID int64 = 0x77617970 // "wayp"
+
+// Migration is one numbered schema change, read from a pair of
+// <version>_<name>.up.sql and .down.sql files.
+type Migration struct {
+	Version uint
+	Name    string
+	Up      string
+	Down    string
+}
+
+// MigrationStatus reports whether a migration has been applied.
+type MigrationStatus struct {
+	Migration
+	Applied bool
+}
+
+// Migrator applies the embedded migrations. It keeps its state in the same
+// schema_migrations table as golang-migrate, so databases migrated with the
+// migrate/migrate container carry on where they left off.
+type Migrator struct {
+	db         *sql.DB
+	migrations []Migration
+}
+
+func NewMigrator(db *sql.DB) (*Migrator, error) {
+	loaded, err := LoadMigrations(migrations.FS)
+	if err != nil {
+		return nil, err
+	}
+	return &Migrator{db: db, migrations: loaded}, nil
+}
+
+// LoadMigrations reads the migrations in fsys, ordered by version.
+func LoadMigrations(fsys fs.FS) ([]Migration, error) {
+	files, err := fs.Glob(fsys, "*.sql")
+	if err != nil {
+		return nil, err
+	}
+
+	byVersion := map[uint]*Migration{}
+	for _, file := range files {
+		base, direction, ok := strings.Cut(strings.TrimSuffix(file, ".sql"), ".")
+		if !ok || (direction != "up" && direction != "down") {
+			return nil, fmt.Errorf("invalid migration name %s", file)
+		}
+		number, name, _ := strings.Cut(base, "_")
+		version, err := strconv.ParseUint(number, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration name %s: %w", file, err)
+		}
+
+		script, err := fs.ReadFile(fsys, file)
+		if err != nil {
+			return nil, err
+		}
+
+		m, ok := byVersion[uint(version)]
+		if !ok {
+			m = &Migration{Version: uint(version), Name: name}
+			byVersion[uint(version)] = m
+		}
+		if direction == "up" {
+			m.Up = string(script)
+		} else {
+			m.Down = string(script)
+		}
+	}
+
+	var loaded []Migration
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migration %d_%s has no up script", m.Version, m.Name)
+		}
+		loaded = append(loaded, *m)
+	}
+	sort.Slice(loaded, func(i, j int) bool { return loaded[i].Version < loaded[j].Version })
+	return loaded, nil
+}
+
+// Latest is the version the embedded migrations bring the schema to.
+func (m *Migrator) Latest() uint {
+	if len(m.migrations) == 0 {
+		return 0
+	}
+	return m.migrations[len(m.migrations)-1].Version
+}
+
+// Version returns the schema version and whether a migration failed half way,
+// which needs fixing by hand before migrating again.
+func (m *Migrator) Version(ctx context.Context) (uint, bool, error) {
+	return currentVersion(ctx, m.db)
+}
+
+// Check fails unless the schema is clean and at the latest embedded version,
+// so a replica does not serve traffic against a schema it does not expect.
+func (m *Migrator) Check(ctx context.Context) error {
+	version, dirty, err := m.Version(ctx)
+	if err != nil {
+		return err
+	}
+	if dirty {
+		return fmt.Errorf("schema version %d is dirty", version)
+	}
+	if version != m.Latest() {
+		return fmt.Errorf("schema is at version %d, expected %d", version, m.Latest())
+	}
+	return nil
+}
+
+// Up applies every pending migration and returns how many ran.
+func (m *Migrator) Up(ctx context.Context) (int, error) {
+	applied := 0
+	err := m.locked(ctx, func(conn *sql.Conn) error {
+		version, err := cleanVersion(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for _, migration := range m.migrations {
+			if migration.Version <= version {
+				continue
+			}
+			if err := apply(ctx, conn, migration.Up, &migration.Version); err != nil {
+				return fmt.Errorf("migration %d_%s failed: %w", migration.Version, migration.Name, err)
+			}
+			applied++
+		}
+		return nil
+	})
+	return applied, err
+}
+
+// Down rolls back the given number of applied migrations, newest first, and
+// returns how many were rolled back.
+func (m *Migrator) Down(ctx context.Context, steps int) (int, error) {
+	reverted := 0
+	err := m.locked(ctx, func(conn *sql.Conn) error {
+		version, err := cleanVersion(ctx, conn)
+		if err != nil {
+			return err
+		}
+
+		for i := len(m.migrations) - 1; i >= 0 && reverted < steps; i-- {
+			migration := m.migrations[i]
+			if migration.Version > version {
+				continue
+			}
+
+			// The schema drops to the previous migration, or to nothing.
+			var previous *uint
+			if i > 0 {
+				previous = &m.migrations[i-1].Version
+			}
+			if err := apply(ctx, conn, migration.Down, previous); err != nil {
+				return fmt.Errorf("rolling back %d_%s failed: %w", migration.Version, migration.Name, err)
+			}
+			reverted++
+		}
+		return nil
+	})
+	return reverted, err
+}
+
+// Status lists every embedded migration and whether it has been applied.
+func (m *Migrator) Status(ctx context.Context) ([]MigrationStatus, error) {
+	version, _, err := m.Version(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	statuses := make([]MigrationStatus, len(m.migrations))
+	for i, migration := range m.migrations {
+		statuses[i] = MigrationStatus{Migration: migration, Applied: migration.Version <= version}
+	}
+	return statuses, nil
+}
+
+// locked runs fn on a single connection holding the migration advisory lock.
+// Session-level advisory locks belong to a connection, so the work has to
+// happen on the same one.
+func (m *Migrator) locked(ctx context.Context, fn func(conn *sql.Conn) error) error {
+	conn, err := m.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := conn.ExecContext(ctx, "SELECT pg_advisory_lock($1)", migrationLockID); err != nil {
+		return fmt.Errorf("failed to acquire migration lock: %w", err)
+	}
+	defer conn.ExecContext(context.Background(), "SELECT pg_advisory_unlock($1)", migrationLockID)
+
+	_, err = conn.ExecContext(ctx,
+		"CREATE TABLE IF NOT EXISTS schema_migrations (version BIGINT NOT NULL PRIMARY KEY, dirty BOOLEAN NOT NULL)")
+	if err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+
+	return fn(conn)
+}
+
+// cleanVersion returns the schema version, refusing to continue from a
+// migration that failed half way.
+func cleanVersion(ctx context.Context, q repository.Querier) (uint, error) {
+	version, dirty, err := currentVersion(ctx, q)
+	if err != nil {
+		return 0, err
+	}
+	if dirty {
+		return 0, fmt.Errorf("schema version %d is dirty: fix the database and reset schema_migrations by hand", version)
+	}
+	return version, nil
+}
+
+func currentVersion(ctx context.Context, q repository.Querier) (uint, bool, error) {
+	var version uint
+	var dirty bool
+	err := q.QueryRowContext(ctx, "SELECT version, dirty FROM schema_migrations LIMIT 1").Scan(&version, &dirty)
+	if err == sql.ErrNoRows {
+		return 0, false, nil
+	}
+	if err != nil {
+		if isUndefinedTable(err) {
+			return 0, false, nil
+		}
+		return 0, false, fmt.Errorf("failed to read schema version: %w", err)
+	}
+	return version, dirty, nil
+}
+
+// apply runs a migration script and records the resulting version in one
+// transaction, so a failed script leaves the schema as it was. A nil version
+// means no migration is applied any more.
+func apply(ctx context.Context, conn *sql.Conn, script string, version *uint) error {
+	tx, err := conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, script); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, "DELETE FROM schema_migrations"); err != nil {
+		return err
+	}
+	if version != nil {
+		_, err := tx.ExecContext(ctx, "INSERT INTO schema_migrations (version, dirty) VALUES ($1, false)", *version)
+		if err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
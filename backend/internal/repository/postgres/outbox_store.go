@@ -0,0 +1,132 @@
+package postgres
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/luisteixeira/waypoint/backend/internal/outbox"
+	"github.com/luisteixeira/waypoint/backend/internal/repository"
+)
+
+type postgresOutboxStore struct {
+	db *sql.DB
+}
+
+func NewPostgresOutboxStore(db *sql.DB) *postgresOutboxStore {
+	return &postgresOutboxStore{db: db}
+}
+
+func (s *postgresOutboxStore) Append(ctx context.Context, events ...outbox.Event) error {
+	familyID, err := repository.GetFamilyIdFromContext(ctx)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+
+	return repository.InTx(ctx, s.db, func(q repository.Querier) error {
+		for _, event := range events {
+			realization, err := json.Marshal(event.Realization)
+			if err != nil {
+				return err
+			}
+			_, err = q.ExecContext(ctx, `
+				INSERT INTO outbox_events (id, family_id, caregiver_id, type, realization, occurred_at, next_attempt_at, created_at)
+				VALUES ($1, $2, $3, $4, $5, $6, $7, $7)`,
+				event.ID, familyID, uuid.NullUUID{UUID: event.CaregiverID, Valid: event.CaregiverID != uuid.Nil},
+				string(event.Type), realization, event.OccurredAt, now,
+			)
+			if err != nil {
+				return fmt.Errorf("failed to append outbox event: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+func (s *postgresOutboxStore) ClaimDue(ctx context.Context, now, leaseUntil time.Time, limit int) ([]outbox.Pending, error) {
+	// As for webhook deliveries, SKIP LOCKED lets several dispatchers claim
+	// disjoint batches and the lease hides the claimed rows until it ends.
+	rows, err := repository.Conn(ctx, s.db).QueryContext(ctx, `
+		WITH due AS (
+			SELECT sequence FROM outbox_events
+			WHERE published_at IS NULL AND next_attempt_at <= $1
+			ORDER BY sequence
+			LIMIT $3
+			FOR UPDATE SKIP LOCKED
+		)
+		UPDATE outbox_events e SET next_attempt_at = $2
+		FROM due WHERE e.sequence = due.sequence
+		RETURNING e.sequence, e.id, e.family_id, e.caregiver_id, e.type, e.realization, e.occurred_at,
+			e.attempts, e.done_sinks, e.next_attempt_at, e.last_error`,
+		now, leaseUntil, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim outbox events: %w", err)
+	}
+	defer rows.Close()
+
+	var claimed []outbox.Pending
+	for rows.Next() {
+		var p outbox.Pending
+		var caregiverID uuid.NullUUID
+		var realization []byte
+		var done pq.StringArray
+		err := rows.Scan(&p.Sequence, &p.ID, &p.FamilyID, &caregiverID, &p.Type, &realization, &p.OccurredAt,
+			&p.Attempts, &done, &p.NextAttemptAt, &p.LastError)
+		if err != nil {
+			return nil, err
+		}
+		p.CaregiverID = caregiverID.UUID
+		if err := json.Unmarshal(realization, &p.Realization); err != nil {
+			return nil, fmt.Errorf("failed to decode outbox event %s: %w", p.ID, err)
+		}
+		if len(done) > 0 {
+			p.Done = done
+		}
+		claimed = append(claimed, p)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	// RETURNING does not keep the order of the CTE.
+	slices.SortFunc(claimed, func(a, b outbox.Pending) int { return cmp.Compare(a.Sequence, b.Sequence) })
+	return claimed, nil
+}
+
+func (s *postgresOutboxStore) RecordResult(ctx context.Context, id uuid.UUID, result outbox.Result) error {
+	var publishedAt, retryAt *time.Time
+	if result.Published {
+		publishedAt = &result.At
+	} else {
+		retryAt = &result.RetryAt
+	}
+	_, err := repository.Conn(ctx, s.db).ExecContext(ctx, `
+		UPDATE outbox_events
+		SET attempts = attempts + 1, done_sinks = $2, last_error = $3,
+			next_attempt_at = COALESCE($4, next_attempt_at), published_at = $5
+		WHERE id = $1`,
+		id, pq.StringArray(append([]string{}, result.Done...)), result.Error, retryAt, publishedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record outbox result: %w", err)
+	}
+	return nil
+}
+
+func (s *postgresOutboxStore) DeletePublished(ctx context.Context, before time.Time) (int64, error) {
+	res, err := repository.Conn(ctx, s.db).ExecContext(ctx,
+		"DELETE FROM outbox_events WHERE published_at IS NOT NULL AND published_at < $1", before,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete published outbox events: %w", err)
+	}
+	return res.RowsAffected()
+}
@@ -0,0 +1,207 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/luisteixeira/waypoint/backend/internal/domain"
+	"github.com/luisteixeira/waypoint/backend/internal/push"
+	"github.com/luisteixeira/waypoint/backend/internal/repository"
+)
+
+const preferenceColumns = `family_id, caregiver_id, entity_ids, activity_started, activity_completed, alerts, updated_at`
+
+type postgresPushStore struct {
+	db *sql.DB
+}
+
+func NewPostgresPushStore(db *sql.DB) *postgresPushStore {
+	return &postgresPushStore{db: db}
+}
+
+func (s *postgresPushStore) SaveSubscription(ctx context.Context, sub *push.Subscription) error {
+	familyID, err := repository.GetFamilyIdFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	sub.FamilyID = familyID
+	// A browser that subscribes again, perhaps for another caregiver, takes
+	// its endpoint over from the old subscription.
+	return repository.InTx(ctx, s.db, func(q repository.Querier) error {
+		if _, err := q.ExecContext(ctx, "DELETE FROM push_subscriptions WHERE endpoint = $1", sub.Endpoint); err != nil {
+			return fmt.Errorf("failed to replace push subscription: %w", err)
+		}
+		_, err := q.ExecContext(ctx, `
+			INSERT INTO push_subscriptions (id, family_id, caregiver_id, endpoint, p256dh, auth, created_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+			sub.ID, familyID, sub.CaregiverID, sub.Endpoint, sub.P256DH, sub.Auth, sub.CreatedAt,
+		)
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == foreignKeyViolation {
+			return &domain.NotFoundError{Resource: "caregiver", ID: sub.CaregiverID}
+		}
+		if err != nil {
+			return fmt.Errorf("failed to save push subscription: %w", err)
+		}
+		return nil
+	})
+}
+
+func (s *postgresPushStore) ListSubscriptions(ctx context.Context, caregiverID uuid.UUID) ([]push.Subscription, error) {
+	familyID, err := repository.GetFamilyIdFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	where, args := "WHERE family_id = $1", []any{familyID}
+	if caregiverID != uuid.Nil {
+		where, args = where+" AND caregiver_id = $2", append(args, caregiverID)
+	}
+	rows, err := repository.Conn(ctx, s.db).QueryContext(ctx, `
+		SELECT id, family_id, caregiver_id, endpoint, p256dh, auth, created_at
+		FROM push_subscriptions `+where+`
+		ORDER BY created_at, id`,
+		args...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list push subscriptions: %w", err)
+	}
+	defer rows.Close()
+
+	subs := []push.Subscription{}
+	for rows.Next() {
+		var sub push.Subscription
+		err := rows.Scan(&sub.ID, &sub.FamilyID, &sub.CaregiverID, &sub.Endpoint, &sub.P256DH, &sub.Auth, &sub.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		subs = append(subs, sub)
+	}
+	return subs, rows.Err()
+}
+
+func (s *postgresPushStore) DeleteSubscription(ctx context.Context, caregiverID, id uuid.UUID) error {
+	familyID, err := repository.GetFamilyIdFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	res, err := repository.Conn(ctx, s.db).ExecContext(ctx,
+		"DELETE FROM push_subscriptions WHERE id = $1 AND family_id = $2 AND caregiver_id = $3",
+		id, familyID, caregiverID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to delete push subscription: %w", err)
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return &domain.NotFoundError{Resource: "push subscription", ID: id}
+	}
+	return nil
+}
+
+func (s *postgresPushStore) DeleteEndpoint(ctx context.Context, endpoint string) error {
+	familyID, err := repository.GetFamilyIdFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	_, err = repository.Conn(ctx, s.db).ExecContext(ctx,
+		"DELETE FROM push_subscriptions WHERE endpoint = $1 AND family_id = $2",
+		endpoint, familyID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to delete push subscription: %w", err)
+	}
+	return nil
+}
+
+func (s *postgresPushStore) GetPreferences(ctx context.Context, caregiverID uuid.UUID) (*push.Preferences, error) {
+	familyID, err := repository.GetFamilyIdFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	prefs, err := s.preferences(ctx, "WHERE family_id = $1 AND caregiver_id = $2", familyID, caregiverID)
+	if err != nil {
+		return nil, err
+	}
+	if len(prefs) == 0 {
+		return nil, &domain.NotFoundError{Resource: "push preferences", ID: caregiverID}
+	}
+	return &prefs[0], nil
+}
+
+func (s *postgresPushStore) SavePreferences(ctx context.Context, prefs *push.Preferences) error {
+	familyID, err := repository.GetFamilyIdFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	prefs.FamilyID = familyID
+	entityIDs := prefs.EntityIDs
+	if entityIDs == nil {
+		entityIDs = []uuid.UUID{}
+	}
+	_, err = repository.Conn(ctx, s.db).ExecContext(ctx, `
+		INSERT INTO push_preferences (`+preferenceColumns+`)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (family_id, caregiver_id) DO UPDATE SET
+			entity_ids = EXCLUDED.entity_ids,
+			activity_started = EXCLUDED.activity_started,
+			activity_completed = EXCLUDED.activity_completed,
+			alerts = EXCLUDED.alerts,
+			updated_at = EXCLUDED.updated_at`,
+		familyID, prefs.CaregiverID, pq.Array(entityIDs),
+		prefs.ActivityStarted, prefs.ActivityCompleted, prefs.Alerts, prefs.UpdatedAt,
+	)
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == foreignKeyViolation {
+		return &domain.NotFoundError{Resource: "caregiver", ID: prefs.CaregiverID}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to save push preferences: %w", err)
+	}
+	return nil
+}
+
+func (s *postgresPushStore) ListPreferences(ctx context.Context) ([]push.Preferences, error) {
+	familyID, err := repository.GetFamilyIdFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s.preferences(ctx, "WHERE family_id = $1", familyID)
+}
+
+func (s *postgresPushStore) preferences(ctx context.Context, where string, args ...any) ([]push.Preferences, error) {
+	rows, err := repository.Conn(ctx, s.db).QueryContext(ctx, `
+		SELECT `+preferenceColumns+`
+		FROM push_preferences `+where+`
+		ORDER BY caregiver_id`,
+		args...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list push preferences: %w", err)
+	}
+	defer rows.Close()
+
+	all := []push.Preferences{}
+	for rows.Next() {
+		var prefs push.Preferences
+		err := rows.Scan(
+			&prefs.FamilyID, &prefs.CaregiverID, pq.Array(&prefs.EntityIDs),
+			&prefs.ActivityStarted, &prefs.ActivityCompleted, &prefs.Alerts, &prefs.UpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, prefs)
+	}
+	return all, rows.Err()
+}
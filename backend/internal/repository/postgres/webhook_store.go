@@ -0,0 +1,301 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+	"github.com/luisteixeira/waypoint/backend/internal/domain"
+	"github.com/luisteixeira/waypoint/backend/internal/repository"
+	"github.com/luisteixeira/waypoint/backend/internal/webhook"
+)
+
+type postgresWebhookStore struct {
+	db *sql.DB
+}
+
+func NewPostgresWebhookStore(db *sql.DB) *postgresWebhookStore {
+	return &postgresWebhookStore{db: db}
+}
+
+func (s *postgresWebhookStore) CreateSubscription(ctx context.Context, sub *webhook.Subscription) error {
+	familyID, err := repository.GetFamilyIdFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	sub.FamilyID = familyID
+	_, err = repository.Conn(ctx, s.db).ExecContext(ctx, `
+		INSERT INTO webhook_subscriptions (id, family_id, url, events, description, secret, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		sub.ID, familyID, sub.URL, pq.Array(eventNames(sub.Events)), sub.Description, sub.Secret, sub.CreatedAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create webhook subscription: %w", err)
+	}
+	return nil
+}
+
+func (s *postgresWebhookStore) ListSubscriptions(ctx context.Context) ([]webhook.Subscription, error) {
+	familyID, err := repository.GetFamilyIdFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := repository.Conn(ctx, s.db).QueryContext(ctx, `
+		SELECT id, url, events, description, secret, created_at
+		FROM webhook_subscriptions
+		WHERE family_id = $1
+		ORDER BY created_at, id`,
+		familyID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list webhook subscriptions: %w", err)
+	}
+	defer rows.Close()
+
+	subs := []webhook.Subscription{}
+	for rows.Next() {
+		sub := webhook.Subscription{FamilyID: familyID}
+		var events []string
+		if err := rows.Scan(&sub.ID, &sub.URL, pq.Array(&events), &sub.Description, &sub.Secret, &sub.CreatedAt); err != nil {
+			return nil, err
+		}
+		sub.Events = eventTypes(events)
+		subs = append(subs, sub)
+	}
+	return subs, rows.Err()
+}
+
+func (s *postgresWebhookStore) DeleteSubscription(ctx context.Context, id uuid.UUID) error {
+	familyID, err := repository.GetFamilyIdFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	res, err := repository.Conn(ctx, s.db).ExecContext(ctx,
+		"DELETE FROM webhook_subscriptions WHERE id = $1 AND family_id = $2", id, familyID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to delete webhook subscription: %w", err)
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return &domain.NotFoundError{Resource: "webhook", ID: id}
+	}
+	return nil
+}
+
+func (s *postgresWebhookStore) Enqueue(ctx context.Context, event webhook.Event) ([]webhook.Delivery, error) {
+	familyID, err := repository.GetFamilyIdFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+
+	// An event the outbox hands over again is already queued; only the
+	// subscriptions it is new to get a delivery.
+	rows, err := repository.Conn(ctx, s.db).QueryContext(ctx, `
+		INSERT INTO webhook_deliveries
+			(id, subscription_id, family_id, event_id, event_type, payload, status, next_attempt_at, created_at)
+		SELECT gen_random_uuid(), id, family_id, $2, $3, $4, 'pending', $5, $5
+		FROM webhook_subscriptions
+		WHERE family_id = $1 AND $3 = ANY(events)
+		ON CONFLICT (subscription_id, event_id) WHERE redelivery_of IS NULL DO NOTHING
+		RETURNING `+deliveryColumns,
+		familyID, event.ID, string(event.Type), payload, now,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to queue webhook deliveries: %w", err)
+	}
+	return scanDeliveries(rows)
+}
+
+func (s *postgresWebhookStore) ListDeliveries(ctx context.Context, subscriptionID uuid.UUID, limit int) ([]webhook.Delivery, error) {
+	if err := s.checkSubscription(ctx, subscriptionID); err != nil {
+		return nil, err
+	}
+
+	rows, err := repository.Conn(ctx, s.db).QueryContext(ctx, `
+		SELECT `+deliveryColumns+`
+		FROM webhook_deliveries
+		WHERE subscription_id = $1
+		ORDER BY created_at DESC, id
+		LIMIT $2`,
+		subscriptionID, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list webhook deliveries: %w", err)
+	}
+	return scanDeliveries(rows)
+}
+
+func (s *postgresWebhookStore) Redeliver(ctx context.Context, subscriptionID, deliveryID uuid.UUID) (*webhook.Delivery, error) {
+	if err := s.checkSubscription(ctx, subscriptionID); err != nil {
+		return nil, err
+	}
+
+	rows, err := repository.Conn(ctx, s.db).QueryContext(ctx, `
+		INSERT INTO webhook_deliveries
+			(id, subscription_id, family_id, event_id, event_type, payload, status, next_attempt_at, created_at, redelivery_of)
+		SELECT gen_random_uuid(), subscription_id, family_id, event_id, event_type, payload, 'pending', $3, $3, id
+		FROM webhook_deliveries
+		WHERE id = $1 AND subscription_id = $2
+		RETURNING `+deliveryColumns,
+		deliveryID, subscriptionID, time.Now(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to queue webhook redelivery: %w", err)
+	}
+	deliveries, err := scanDeliveries(rows)
+	if err != nil {
+		return nil, err
+	}
+	if len(deliveries) == 0 {
+		return nil, &domain.NotFoundError{Resource: "delivery", ID: deliveryID}
+	}
+	return &deliveries[0], nil
+}
+
+func (s *postgresWebhookStore) ClaimDue(ctx context.Context, now, leaseUntil time.Time, limit int) ([]webhook.Delivery, error) {
+	// SKIP LOCKED lets several dispatchers claim disjoint batches; moving
+	// next_attempt_at to the end of the lease hides the claimed rows until
+	// then.
+	rows, err := repository.Conn(ctx, s.db).QueryContext(ctx, `
+		WITH due AS (
+			SELECT id FROM webhook_deliveries
+			WHERE status = 'pending' AND next_attempt_at <= $1
+			ORDER BY next_attempt_at
+			LIMIT $3
+			FOR UPDATE SKIP LOCKED
+		), claimed AS (
+			UPDATE webhook_deliveries d SET next_attempt_at = $2
+			FROM due WHERE d.id = due.id
+			RETURNING d.*
+		)
+		SELECT `+claimedColumns+`, s.url, s.secret
+		FROM claimed d
+		JOIN webhook_subscriptions s ON s.id = d.subscription_id
+		ORDER BY d.created_at`,
+		now, leaseUntil, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to claim webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	var deliveries []webhook.Delivery
+	var url, secret string
+	for rows.Next() {
+		d, err := scanDelivery(rows, &url, &secret)
+		if err != nil {
+			return nil, err
+		}
+		d.URL, d.Secret = url, secret
+		deliveries = append(deliveries, *d)
+	}
+	return deliveries, rows.Err()
+}
+
+func (s *postgresWebhookStore) RecordAttempt(ctx context.Context, deliveryID uuid.UUID, attempt webhook.Attempt) error {
+	var deliveredAt *time.Time
+	if attempt.Delivered {
+		deliveredAt = &attempt.At
+	}
+	_, err := repository.Conn(ctx, s.db).ExecContext(ctx, `
+		UPDATE webhook_deliveries
+		SET status = $2, attempts = attempts + 1, next_attempt_at = COALESCE($3, next_attempt_at),
+			last_attempt_at = $4, response_status = $5, last_error = $6, delivered_at = $7
+		WHERE id = $1`,
+		deliveryID, string(attempt.Status()), attempt.RetryAt, attempt.At, nullStatus(attempt.ResponseStatus), attempt.Error, deliveredAt,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record webhook attempt: %w", err)
+	}
+	return nil
+}
+
+func (s *postgresWebhookStore) checkSubscription(ctx context.Context, id uuid.UUID) error {
+	familyID, err := repository.GetFamilyIdFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	var exists bool
+	err = repository.Conn(ctx, s.db).QueryRowContext(ctx,
+		"SELECT EXISTS(SELECT 1 FROM webhook_subscriptions WHERE id = $1 AND family_id = $2)", id, familyID,
+	).Scan(&exists)
+	if err != nil {
+		return fmt.Errorf("failed to load webhook subscription: %w", err)
+	}
+	if !exists {
+		return &domain.NotFoundError{Resource: "webhook", ID: id}
+	}
+	return nil
+}
+
+const deliveryColumns = `id, subscription_id, family_id, event_id, event_type, payload, status, attempts,
+	next_attempt_at, last_attempt_at, response_status, last_error, created_at, delivered_at`
+
+const claimedColumns = `d.id, d.subscription_id, d.family_id, d.event_id, d.event_type, d.payload, d.status, d.attempts,
+	d.next_attempt_at, d.last_attempt_at, d.response_status, d.last_error, d.created_at, d.delivered_at`
+
+func scanDeliveries(rows *sql.Rows) ([]webhook.Delivery, error) {
+	defer rows.Close()
+
+	deliveries := []webhook.Delivery{}
+	for rows.Next() {
+		d, err := scanDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, *d)
+	}
+	return deliveries, rows.Err()
+}
+
+// scanDelivery reads deliveryColumns, followed by any extra columns.
+func scanDelivery(rows *sql.Rows, extra ...any) (*webhook.Delivery, error) {
+	var d webhook.Delivery
+	var responseStatus sql.NullInt64
+	fields := []any{
+		&d.ID, &d.SubscriptionID, &d.FamilyID, &d.EventID, &d.EventType, &d.Payload, &d.Status, &d.Attempts,
+		&d.NextAttemptAt, &d.LastAttemptAt, &responseStatus, &d.LastError, &d.CreatedAt, &d.DeliveredAt,
+	}
+	if err := rows.Scan(append(fields, extra...)...); err != nil {
+		return nil, err
+	}
+	d.ResponseStatus = int(responseStatus.Int64)
+	return &d, nil
+}
+
+// nullStatus stores a missing response status as NULL.
+func nullStatus(status int) sql.NullInt64 {
+	return sql.NullInt64{Int64: int64(status), Valid: status != 0}
+}
+
+func eventNames(events []webhook.EventType) []string {
+	names := make([]string, len(events))
+	for i, e := range events {
+		names[i] = string(e)
+	}
+	return names
+}
+
+func eventTypes(names []string) []webhook.EventType {
+	events := make([]webhook.EventType, len(names))
+	for i, n := range names {
+		events[i] = webhook.EventType(n)
+	}
+	return events
+}
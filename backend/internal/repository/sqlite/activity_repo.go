@@ -0,0 +1,374 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/luisteixeira/waypoint/backend/internal/domain"
+	"github.com/luisteixeira/waypoint/backend/internal/repository"
+)
+
+const selectRealization = `
+	SELECT
+		ar.id, ar.family_id, ar.definition_id, ar.entity_id, ar.status,
+		ar.planned_for, ar.started_at, ar.finished_at, ar.version,
+		COALESCE(group_concat(rc.caregiver_id), '')
+	FROM activity_realizations ar
+	LEFT JOIN realization_caregivers rc ON ar.id = rc.realization_id`
+
+type sqliteActivityRepo struct {
+	db *sql.DB
+}
+
+func NewSQLiteActivityRepo(db *sql.DB) *sqliteActivityRepo {
+	return &sqliteActivityRepo{db: db}
+}
+
+func (r *sqliteActivityRepo) CreateRealization(ctx context.Context, activityRealization *domain.ActivityRealization, events ...domain.RealizationEvent) error {
+	familyID, err := repository.GetFamilyIdFromContext(ctx)
+	if err != nil {
+		return err
+	}
+	activityRealization.FamilyID = familyID
+
+	if activityRealization.ID == uuid.Nil {
+		activityRealization.ID = uuid.New()
+	}
+
+	err = repository.InTx(ctx, r.db, func(tx repository.Querier) error {
+		if err := appendEvents(ctx, tx, activityRealization, events); err != nil {
+			return err
+		}
+
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO activity_realizations (id, family_id, definition_id, entity_id, status, planned_for, started_at, finished_at, version)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, 1)`,
+			activityRealization.ID, familyID, activityRealization.DefinitionID, activityRealization.EntityID,
+			activityRealization.Status, utc(activityRealization.PlannedFor), utc(activityRealization.StartedAt), utc(activityRealization.FinishedAt),
+		)
+		if err != nil {
+			return mapError(err)
+		}
+
+		return insertCaregivers(ctx, tx, activityRealization)
+	})
+	if err != nil {
+		return err
+	}
+	activityRealization.Version = 1
+	return nil
+}
+
+func (r *sqliteActivityRepo) GetRealizationByID(ctx context.Context, id uuid.UUID) (*domain.ActivityRealization, error) {
+	familyID, err := repository.GetFamilyIdFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	row := repository.Conn(ctx, r.db).QueryRowContext(ctx, selectRealization+`
+		WHERE ar.id = ? AND ar.family_id = ?
+		GROUP BY ar.id`,
+		id, familyID,
+	)
+
+	ar, err := scanRealization(row)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, &domain.NotFoundError{Resource: "activity realization", ID: id}
+		}
+		return nil, fmt.Errorf("failed to fetch realization: %w", err)
+	}
+	return ar, nil
+}
+
+func (r *sqliteActivityRepo) GetActiveByEntity(ctx context.Context, entityID uuid.UUID) (*domain.ActivityRealization, error) {
+	familyID, err := repository.GetFamilyIdFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	row := repository.Conn(ctx, r.db).QueryRowContext(ctx, selectRealization+`
+		WHERE ar.entity_id = ? AND ar.family_id = ? AND ar.status = ?
+		GROUP BY ar.id
+		LIMIT 1`,
+		entityID, familyID, domain.StatusInProgress,
+	)
+
+	ar, err := scanRealization(row)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to check active status: %w", err)
+	}
+	return ar, nil
+}
+
+func (r *sqliteActivityRepo) ListRealizations(ctx context.Context, filter domain.RealizationFilter) ([]domain.ActivityRealization, error) {
+	familyID, err := repository.GetFamilyIdFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	where := []string{"ar.family_id = ?"}
+	args := []any{familyID}
+	if filter.DefinitionID != uuid.Nil {
+		where = append(where, "ar.definition_id = ?")
+		args = append(args, filter.DefinitionID)
+	}
+	if filter.EntityID != uuid.Nil {
+		where = append(where, "ar.entity_id = ?")
+		args = append(args, filter.EntityID)
+	}
+	if len(filter.Statuses) > 0 {
+		where = append(where, "ar.status IN (?"+strings.Repeat(", ?", len(filter.Statuses)-1)+")")
+		for _, status := range filter.Statuses {
+			args = append(args, status)
+		}
+	}
+	if filter.PlannedBefore != nil {
+		where = append(where, "ar.planned_for <= ?")
+		args = append(args, utc(filter.PlannedBefore))
+	}
+	if filter.StartedBefore != nil {
+		where = append(where, "ar.started_at <= ?")
+		args = append(args, utc(filter.StartedBefore))
+	}
+	query := selectRealization + `
+		WHERE ` + strings.Join(where, " AND ") + `
+		GROUP BY ar.id
+		ORDER BY ar.started_at IS NULL, ar.started_at DESC, ar.id`
+	if filter.Limit > 0 {
+		query += ` LIMIT ?`
+		args = append(args, filter.Limit)
+	}
+
+	rows, err := repository.Conn(ctx, r.db).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list realizations: %w", err)
+	}
+	defer rows.Close()
+
+	res := []domain.ActivityRealization{}
+	for rows.Next() {
+		ar, err := scanRealization(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan realization: %w", err)
+		}
+		res = append(res, *ar)
+	}
+	return res, rows.Err()
+}
+
+func (r *sqliteActivityRepo) UpdateRealization(ctx context.Context, activityRealization *domain.ActivityRealization, events ...domain.RealizationEvent) error {
+	familyID, err := repository.GetFamilyIdFromContext(ctx)
+	if err != nil {
+		return err
+	}
+	activityRealization.FamilyID = familyID
+
+	var updated bool
+	err = repository.InTx(ctx, r.db, func(tx repository.Querier) error {
+		var err error
+		updated, err = updateProjection(ctx, tx, activityRealization, activityRealization.Version)
+		if err != nil || !updated {
+			return err
+		}
+		return appendEvents(ctx, tx, activityRealization, events)
+	})
+	if err != nil {
+		return err
+	}
+	if !updated {
+		return r.staleOrMissing(ctx, activityRealization.ID)
+	}
+	activityRealization.Version++
+	return nil
+}
+
+func (r *sqliteActivityRepo) ListEvents(ctx context.Context, realizationID uuid.UUID) ([]domain.RealizationEvent, error) {
+	familyID, err := repository.GetFamilyIdFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := repository.Conn(ctx, r.db).QueryContext(ctx, `
+		SELECT sequence, realization_id, family_id, event_type, data, occurred_at
+		FROM realization_events
+		WHERE realization_id = ? AND family_id = ?
+		ORDER BY sequence ASC`,
+		realizationID, familyID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list realization events: %w", err)
+	}
+	defer rows.Close()
+
+	var events []domain.RealizationEvent
+	for rows.Next() {
+		var e domain.RealizationEvent
+		var data string
+		if err := rows.Scan(&e.Sequence, &e.RealizationID, &e.FamilyID, &e.Type, &data, &e.OccurredAt); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(data), &e.Data); err != nil {
+			return nil, fmt.Errorf("failed to decode event %d: %w", e.Sequence, err)
+		}
+		events = append(events, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(events) == 0 {
+		return nil, &domain.NotFoundError{Resource: "activity realization", ID: realizationID}
+	}
+	return events, nil
+}
+
+func (r *sqliteActivityRepo) ReplaceRealization(ctx context.Context, activityRealization *domain.ActivityRealization) error {
+	familyID, err := repository.GetFamilyIdFromContext(ctx)
+	if err != nil {
+		return err
+	}
+	activityRealization.FamilyID = familyID
+
+	return repository.InTx(ctx, r.db, func(tx repository.Querier) error {
+		_, err := tx.ExecContext(ctx, `
+			INSERT INTO activity_realizations (id, family_id, definition_id, entity_id, status, planned_for, started_at, finished_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+			ON CONFLICT (id) DO NOTHING`,
+			activityRealization.ID, familyID, activityRealization.DefinitionID, activityRealization.EntityID,
+			activityRealization.Status, utc(activityRealization.PlannedFor), utc(activityRealization.StartedAt), utc(activityRealization.FinishedAt),
+		)
+		if err != nil {
+			return mapError(err)
+		}
+
+		if _, err := updateProjection(ctx, tx, activityRealization, 0); err != nil {
+			return err
+		}
+
+		return tx.QueryRowContext(ctx,
+			"SELECT version FROM activity_realizations WHERE id = ?",
+			activityRealization.ID,
+		).Scan(&activityRealization.Version)
+	})
+}
+
+// staleOrMissing explains why a conditional update matched no rows.
+func (r *sqliteActivityRepo) staleOrMissing(ctx context.Context, id uuid.UUID) error {
+	current, err := r.GetRealizationByID(ctx, id)
+	if err != nil {
+		return err
+	}
+	return &domain.StaleVersionError{Current: current}
+}
+
+func appendEvents(ctx context.Context, tx repository.Querier, activityRealization *domain.ActivityRealization, events []domain.RealizationEvent) error {
+	for i := range events {
+		events[i].RealizationID = activityRealization.ID
+		events[i].FamilyID = activityRealization.FamilyID
+
+		data, err := json.Marshal(events[i].Data)
+		if err != nil {
+			return err
+		}
+
+		err = tx.QueryRowContext(ctx, `
+			INSERT INTO realization_events (realization_id, family_id, event_type, data, occurred_at)
+			VALUES (?, ?, ?, ?, ?) RETURNING sequence`,
+			events[i].RealizationID, events[i].FamilyID, events[i].Type, string(data), events[i].OccurredAt.UTC(),
+		).Scan(&events[i].Sequence)
+		if err != nil {
+			return fmt.Errorf("failed to append %s event: %w", events[i].Type, err)
+		}
+	}
+	return nil
+}
+
+// updateProjection overwrites the stored realization and bumps its version.
+// A non-zero expectedVersion makes the write conditional on the stored
+// version; it reports false when no row matched.
+func updateProjection(ctx context.Context, tx repository.Querier, activityRealization *domain.ActivityRealization, expectedVersion int) (bool, error) {
+	res, err := tx.ExecContext(ctx, `
+		UPDATE activity_realizations
+		SET definition_id = ?, status = ?, planned_for = ?, started_at = ?, finished_at = ?, version = version + 1
+		WHERE id = ? AND family_id = ? AND (? = 0 OR version = ?)`,
+		activityRealization.DefinitionID, activityRealization.Status, utc(activityRealization.PlannedFor),
+		utc(activityRealization.StartedAt), utc(activityRealization.FinishedAt), activityRealization.ID, activityRealization.FamilyID,
+		expectedVersion, expectedVersion,
+	)
+	if err != nil {
+		return false, mapError(err)
+	}
+
+	affected, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	if affected == 0 {
+		return false, nil
+	}
+
+	_, err = tx.ExecContext(ctx, "DELETE FROM realization_caregivers WHERE realization_id = ?", activityRealization.ID)
+	if err != nil {
+		return false, err
+	}
+
+	return true, insertCaregivers(ctx, tx, activityRealization)
+}
+
+func insertCaregivers(ctx context.Context, tx repository.Querier, activityRealization *domain.ActivityRealization) error {
+	for _, caregiverID := range activityRealization.CaregiversIDs {
+		_, err := tx.ExecContext(ctx,
+			"INSERT INTO realization_caregivers (realization_id, caregiver_id) VALUES (?, ?)",
+			activityRealization.ID, caregiverID,
+		)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// scanRealization scans a row of selectRealization from a *sql.Row or
+// *sql.Rows.
+func scanRealization(row interface{ Scan(dest ...any) error }) (*domain.ActivityRealization, error) {
+	var ar domain.ActivityRealization
+	var caregivers string
+
+	err := row.Scan(
+		&ar.ID, &ar.FamilyID, &ar.DefinitionID, &ar.EntityID, &ar.Status,
+		&ar.PlannedFor, &ar.StartedAt, &ar.FinishedAt, &ar.Version, &caregivers,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	ar.CaregiversIDs = []uuid.UUID{}
+	for _, id := range strings.Split(caregivers, ",") {
+		if id == "" {
+			continue
+		}
+		caregiverID, err := uuid.Parse(id)
+		if err != nil {
+			return nil, err
+		}
+		ar.CaregiversIDs = append(ar.CaregiversIDs, caregiverID)
+	}
+	return &ar, nil
+}
+
+// utc stores timestamps in UTC so they compare and sort as text.
+func utc(t *time.Time) interface{} {
+	if t == nil {
+		return nil
+	}
+	return t.UTC()
+}
@@ -0,0 +1,251 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/luisteixeira/waypoint/backend/internal/alert"
+	"github.com/luisteixeira/waypoint/backend/internal/domain"
+	"github.com/luisteixeira/waypoint/backend/internal/repository"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+const ruleColumns = `id, family_id, kind, definition_id, entity_id, threshold_seconds, channels, email, created_at`
+
+const alertColumns = `
+	id, family_id, rule_id, kind, definition_id, entity_id, realization_id, message,
+	status, triggered_at, snoozed_until, acknowledged_at, resolved_at`
+
+type sqliteAlertStore struct {
+	db *sql.DB
+}
+
+func NewSQLiteAlertStore(db *sql.DB) *sqliteAlertStore {
+	return &sqliteAlertStore{db: db}
+}
+
+func (s *sqliteAlertStore) CreateRule(ctx context.Context, rule *alert.Rule) error {
+	familyID, err := repository.GetFamilyIdFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	rule.FamilyID = familyID
+	channels := make([]string, len(rule.Channels))
+	for i, c := range rule.Channels {
+		channels[i] = string(c)
+	}
+	_, err = repository.Conn(ctx, s.db).ExecContext(ctx, `
+		INSERT INTO alert_rules (`+ruleColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		rule.ID, familyID, rule.Kind, rule.DefinitionID, nullUUID(rule.EntityID),
+		int64(rule.Threshold/time.Second), strings.Join(channels, ","), rule.Email, rule.CreatedAt.UTC(),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create alert rule: %w", err)
+	}
+	return nil
+}
+
+func (s *sqliteAlertStore) ListRules(ctx context.Context) ([]alert.Rule, error) {
+	familyID, err := repository.GetFamilyIdFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s.rules(ctx, "WHERE family_id = ?", familyID)
+}
+
+func (s *sqliteAlertStore) AllRules(ctx context.Context) ([]alert.Rule, error) {
+	return s.rules(ctx, "")
+}
+
+func (s *sqliteAlertStore) rules(ctx context.Context, where string, args ...any) ([]alert.Rule, error) {
+	rows, err := repository.Conn(ctx, s.db).QueryContext(ctx, `
+		SELECT `+ruleColumns+`
+		FROM alert_rules `+where+`
+		ORDER BY created_at, id`,
+		args...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list alert rules: %w", err)
+	}
+	defer rows.Close()
+
+	rules := []alert.Rule{}
+	for rows.Next() {
+		var rule alert.Rule
+		var entityID uuid.NullUUID
+		var threshold int64
+		var channels string
+		err := rows.Scan(
+			&rule.ID, &rule.FamilyID, &rule.Kind, &rule.DefinitionID, &entityID,
+			&threshold, &channels, &rule.Email, &rule.CreatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		rule.EntityID = entityID.UUID
+		rule.Threshold = time.Duration(threshold) * time.Second
+		for _, c := range strings.Split(channels, ",") {
+			if c != "" {
+				rule.Channels = append(rule.Channels, alert.Channel(c))
+			}
+		}
+		rules = append(rules, rule)
+	}
+	return rules, rows.Err()
+}
+
+func (s *sqliteAlertStore) DeleteRule(ctx context.Context, id uuid.UUID) error {
+	familyID, err := repository.GetFamilyIdFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Foreign keys are not enforced, so the alerts do not cascade.
+	return repository.InTx(ctx, s.db, func(q repository.Querier) error {
+		res, err := q.ExecContext(ctx, "DELETE FROM alert_rules WHERE id = ? AND family_id = ?", id, familyID)
+		if err != nil {
+			return fmt.Errorf("failed to delete alert rule: %w", err)
+		}
+		if n, err := res.RowsAffected(); err != nil {
+			return err
+		} else if n == 0 {
+			return &domain.NotFoundError{Resource: "alert rule", ID: id}
+		}
+		_, err = q.ExecContext(ctx, "DELETE FROM alerts WHERE rule_id = ?", id)
+		return err
+	})
+}
+
+func (s *sqliteAlertStore) CreateAlert(ctx context.Context, a *alert.Alert) error {
+	familyID, err := repository.GetFamilyIdFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	a.FamilyID = familyID
+	_, err = repository.Conn(ctx, s.db).ExecContext(ctx, `
+		INSERT INTO alerts (`+alertColumns+`, subject_id)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		a.ID, familyID, a.RuleID, a.Kind, a.DefinitionID, a.EntityID, a.RealizationID, a.Message,
+		a.Status, a.TriggeredAt.UTC(), utc(a.SnoozedUntil), utc(a.AcknowledgedAt), utc(a.ResolvedAt),
+		a.Subject(),
+	)
+	var sqliteErr *sqlite.Error
+	if errors.As(err, &sqliteErr) && sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE {
+		return alert.ErrAlreadyRaised
+	}
+	if err != nil {
+		return fmt.Errorf("failed to create alert: %w", err)
+	}
+	return nil
+}
+
+func (s *sqliteAlertStore) UpdateAlert(ctx context.Context, a *alert.Alert) error {
+	familyID, err := repository.GetFamilyIdFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	res, err := repository.Conn(ctx, s.db).ExecContext(ctx, `
+		UPDATE alerts
+		SET message = ?, status = ?, snoozed_until = ?, acknowledged_at = ?, resolved_at = ?
+		WHERE id = ? AND family_id = ?`,
+		a.Message, a.Status, utc(a.SnoozedUntil), utc(a.AcknowledgedAt), utc(a.ResolvedAt),
+		a.ID, familyID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to update alert: %w", err)
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return &domain.NotFoundError{Resource: "alert", ID: a.ID}
+	}
+	return nil
+}
+
+func (s *sqliteAlertStore) GetAlert(ctx context.Context, id uuid.UUID) (*alert.Alert, error) {
+	familyID, err := repository.GetFamilyIdFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	a, err := scanAlert(repository.Conn(ctx, s.db).QueryRowContext(ctx, `
+		SELECT `+alertColumns+`
+		FROM alerts
+		WHERE id = ? AND family_id = ?`,
+		id, familyID,
+	))
+	if err == sql.ErrNoRows {
+		return nil, &domain.NotFoundError{Resource: "alert", ID: id}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch alert: %w", err)
+	}
+	return a, nil
+}
+
+func (s *sqliteAlertStore) ListAlerts(ctx context.Context, statuses []alert.Status, limit int) ([]alert.Alert, error) {
+	familyID, err := repository.GetFamilyIdFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if len(statuses) == 0 {
+		return []alert.Alert{}, nil
+	}
+
+	args := []any{familyID}
+	for _, status := range statuses {
+		args = append(args, status)
+	}
+	query := `
+		SELECT ` + alertColumns + `
+		FROM alerts
+		WHERE family_id = ? AND status IN (?` + strings.Repeat(", ?", len(statuses)-1) + `)
+		ORDER BY triggered_at DESC, id`
+	if limit > 0 {
+		query += ` LIMIT ?`
+		args = append(args, limit)
+	}
+
+	rows, err := repository.Conn(ctx, s.db).QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list alerts: %w", err)
+	}
+	defer rows.Close()
+
+	alerts := []alert.Alert{}
+	for rows.Next() {
+		a, err := scanAlert(rows)
+		if err != nil {
+			return nil, err
+		}
+		alerts = append(alerts, *a)
+	}
+	return alerts, rows.Err()
+}
+
+func scanAlert(row interface{ Scan(dest ...any) error }) (*alert.Alert, error) {
+	var a alert.Alert
+	err := row.Scan(
+		&a.ID, &a.FamilyID, &a.RuleID, &a.Kind, &a.DefinitionID, &a.EntityID, &a.RealizationID, &a.Message,
+		&a.Status, &a.TriggeredAt, &a.SnoozedUntil, &a.AcknowledgedAt, &a.ResolvedAt,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+// nullUUID stores uuid.Nil as NULL.
+func nullUUID(id uuid.UUID) uuid.NullUUID {
+	return uuid.NullUUID{UUID: id, Valid: id != uuid.Nil}
+}
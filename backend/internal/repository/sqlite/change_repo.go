@@ -0,0 +1,131 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/luisteixeira/waypoint/backend/internal/domain"
+	"github.com/luisteixeira/waypoint/backend/internal/repository"
+)
+
+type sqliteChangeRepo struct {
+	db *sql.DB
+}
+
+// NewSQLiteChangeRepo reads the sync_changes table the triggers of
+// migration 3 maintain.
+func NewSQLiteChangeRepo(db *sql.DB) *sqliteChangeRepo {
+	return &sqliteChangeRepo{db: db}
+}
+
+func (r *sqliteChangeRepo) ListChanges(ctx context.Context, after int64, limit int) ([]domain.Change, error) {
+	familyID, err := repository.GetFamilyIdFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := repository.Conn(ctx, r.db).QueryContext(ctx, `
+		SELECT
+			c.sequence, c.kind, c.object_id, c.deleted,
+			ar.id, ar.definition_id, ar.entity_id, ar.status, ar.planned_for, ar.started_at, ar.finished_at, ar.version,
+			COALESCE((SELECT group_concat(rc.caregiver_id) FROM realization_caregivers rc WHERE rc.realization_id = ar.id), ''),
+			d.id, d.name, d.description, d.color_code,
+			e.id, e.name, e.date_of_birth
+		FROM sync_changes c
+		LEFT JOIN activity_realizations ar ON c.kind = 'realization' AND NOT c.deleted AND ar.id = c.object_id
+		LEFT JOIN activity_definitions d ON c.kind = 'definition' AND NOT c.deleted AND d.id = c.object_id
+		LEFT JOIN entities e ON c.kind = 'entity' AND NOT c.deleted AND e.id = c.object_id
+		WHERE c.family_id = ? AND c.sequence > ?
+		ORDER BY c.sequence ASC
+		LIMIT ?`,
+		familyID, after, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list changes: %w", err)
+	}
+	defer rows.Close()
+
+	var changes []domain.Change
+	for rows.Next() {
+		var c domain.Change
+		var (
+			arID, definitionID, entityID uuid.NullUUID
+			status                       sql.NullString
+			plannedFor                   *time.Time
+			startedAt, finishedAt        *time.Time
+			version                      sql.NullInt64
+			caregivers                   string
+
+			defID                  uuid.NullUUID
+			defName                sql.NullString
+			description, colorCode *string
+
+			entID       uuid.NullUUID
+			entName     sql.NullString
+			dateOfBirth *time.Time
+		)
+		err := rows.Scan(
+			&c.Sequence, &c.Kind, &c.ID, &c.Deleted,
+			&arID, &definitionID, &entityID, &status, &plannedFor, &startedAt, &finishedAt, &version, &caregivers,
+			&defID, &defName, &description, &colorCode,
+			&entID, &entName, &dateOfBirth,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case arID.Valid:
+			ar := &domain.ActivityRealization{
+				ID:            arID.UUID,
+				FamilyID:      familyID,
+				DefinitionID:  definitionID.UUID,
+				EntityID:      entityID.UUID,
+				CaregiversIDs: []uuid.UUID{},
+				Status:        domain.ActivityStatus(status.String),
+				PlannedFor:    plannedFor,
+				StartedAt:     startedAt,
+				FinishedAt:    finishedAt,
+				Version:       int(version.Int64),
+			}
+			for _, id := range strings.Split(caregivers, ",") {
+				if id == "" {
+					continue
+				}
+				caregiverID, err := uuid.Parse(id)
+				if err != nil {
+					return nil, err
+				}
+				ar.CaregiversIDs = append(ar.CaregiversIDs, caregiverID)
+			}
+			c.Realization = ar
+		case defID.Valid:
+			c.Definition = &domain.ActivityDefinition{
+				ID:          defID.UUID,
+				FamilyID:    familyID,
+				Name:        defName.String,
+				Description: description,
+				ColorCode:   colorCode,
+			}
+		case entID.Valid:
+			c.Entity = &domain.Entity{ID: entID.UUID, FamilyID: familyID, Name: entName.String, DateOfBirth: dateOfBirth}
+		default:
+			c.Deleted = true
+		}
+		changes = append(changes, c)
+	}
+	return changes, rows.Err()
+}
+
+func (r *sqliteChangeRepo) LastSequence(ctx context.Context) (int64, error) {
+	var last int64
+	err := repository.Conn(ctx, r.db).QueryRowContext(ctx, "SELECT value FROM sync_sequence").Scan(&last)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read the change sequence: %w", err)
+	}
+	return last, nil
+}
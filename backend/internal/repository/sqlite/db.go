@@ -0,0 +1,124 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"embed"
+	"fmt"
+	"io/fs"
+	"sort"
+	"strconv"
+	"strings"
+
+	_ "modernc.org/sqlite"
+)
+
+//go:embed migrations/*.sql
+var migrations embed.FS
+
+// Open opens the database file at path, creating it if needed. SQLite allows a
+// single writer, so the pool is limited to one connection and callers queue
+// instead of failing with SQLITE_BUSY.
+func Open(path string) (*sql.DB, error) {
+	dsn := fmt.Sprintf("file:%s?_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)", path)
+
+	db, err := sql.Open("sqlite", dsn)
+	if err != nil {
+		return nil, err
+	}
+	db.SetMaxOpenConns(1)
+
+	if err := db.Ping(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return db, nil
+}
+
+// CheckSchema fails if any embedded migration has not been applied.
+func CheckSchema(ctx context.Context, db *sql.DB) error {
+	files, err := migrationFiles()
+	if err != nil {
+		return err
+	}
+
+	var applied int
+	err = db.QueryRowContext(ctx, "SELECT COUNT(*) FROM schema_migrations").Scan(&applied)
+	if err != nil {
+		return fmt.Errorf("failed to read schema version: %w", err)
+	}
+	if applied < len(files) {
+		return fmt.Errorf("%d of %d migrations applied", applied, len(files))
+	}
+	return nil
+}
+
+// Migrate applies the embedded schema migrations that have not run yet.
+func Migrate(ctx context.Context, db *sql.DB) error {
+	_, err := db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (version INTEGER PRIMARY KEY)`)
+	if err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %w", err)
+	}
+
+	files, err := migrationFiles()
+	if err != nil {
+		return err
+	}
+
+	for _, file := range files {
+		var applied bool
+		err = db.QueryRowContext(ctx, "SELECT EXISTS(SELECT 1 FROM schema_migrations WHERE version = ?)", file.version).Scan(&applied)
+		if err != nil {
+			return err
+		}
+		if applied {
+			continue
+		}
+
+		script, err := migrations.ReadFile(file.name)
+		if err != nil {
+			return err
+		}
+
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		if _, err := tx.ExecContext(ctx, string(script)); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("migration %s failed: %w", file.name, err)
+		}
+		if _, err := tx.ExecContext(ctx, "INSERT INTO schema_migrations (version) VALUES (?)", file.version); err != nil {
+			tx.Rollback()
+			return err
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type migrationFile struct {
+	name    string
+	version int
+}
+
+// migrationFiles lists the embedded up migrations in the order they apply.
+func migrationFiles() ([]migrationFile, error) {
+	names, err := fs.Glob(migrations, "migrations/*.up.sql")
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+
+	files := make([]migrationFile, len(names))
+	for i, name := range names {
+		version, err := strconv.Atoi(strings.SplitN(strings.TrimPrefix(name, "migrations/"), "_", 2)[0])
+		if err != nil {
+			return nil, fmt.Errorf("invalid migration name %s: %w", name, err)
+		}
+		files[i] = migrationFile{name: name, version: version}
+	}
+	return files, nil
+}
@@ -0,0 +1,69 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/luisteixeira/waypoint/backend/internal/domain"
+	"github.com/luisteixeira/waypoint/backend/internal/repository"
+)
+
+type sqliteDefinitionRepo struct {
+	db *sql.DB
+}
+
+func NewSQLiteDefinitionRepo(db *sql.DB) *sqliteDefinitionRepo {
+	return &sqliteDefinitionRepo{db: db}
+}
+
+func (r *sqliteDefinitionRepo) GetOrCreateByName(ctx context.Context, name string) (*domain.ActivityDefinition, error) {
+	familyID, err := repository.GetFamilyIdFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+		INSERT INTO activity_definitions (id, family_id, name)
+		VALUES (?, ?, ?)
+		ON CONFLICT (family_id, name) DO UPDATE SET name = excluded.name
+		RETURNING id, family_id, name, description, color_code`
+
+	var def domain.ActivityDefinition
+	err = repository.Conn(ctx, r.db).QueryRowContext(ctx, query, uuid.New(), familyID, name).Scan(
+		&def.ID, &def.FamilyID, &def.Name, &def.Description, &def.ColorCode,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get or create definition: %w", err)
+	}
+	return &def, nil
+}
+
+func (r *sqliteDefinitionRepo) ListByFamily(ctx context.Context) ([]domain.ActivityDefinition, error) {
+	familyID, err := repository.GetFamilyIdFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := repository.Conn(ctx, r.db).QueryContext(ctx,
+		`SELECT id, family_id, name, description, color_code
+		FROM activity_definitions
+		WHERE family_id = ? ORDER BY name ASC`,
+		familyID,
+	)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var defs []domain.ActivityDefinition
+	for rows.Next() {
+		var d domain.ActivityDefinition
+		if err := rows.Scan(&d.ID, &d.FamilyID, &d.Name, &d.Description, &d.ColorCode); err != nil {
+			return nil, err
+		}
+		defs = append(defs, d)
+	}
+	return defs, rows.Err()
+}
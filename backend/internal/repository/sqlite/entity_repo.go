@@ -0,0 +1,46 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+
+	"github.com/luisteixeira/waypoint/backend/internal/domain"
+	"github.com/luisteixeira/waypoint/backend/internal/repository"
+)
+
+type sqliteEntityRepo struct {
+	db *sql.DB
+}
+
+func NewSQLiteEntityRepo(db *sql.DB) *sqliteEntityRepo {
+	return &sqliteEntityRepo{db: db}
+}
+
+func (r *sqliteEntityRepo) ListByFamily(ctx context.Context) ([]domain.Entity, error) {
+	familyID, err := repository.GetFamilyIdFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := repository.Conn(ctx, r.db).QueryContext(ctx,
+		`SELECT id, family_id, name, date_of_birth
+		FROM entities
+		WHERE family_id = ? ORDER BY name ASC`,
+		familyID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list entities: %w", err)
+	}
+	defer rows.Close()
+
+	var entities []domain.Entity
+	for rows.Next() {
+		var e domain.Entity
+		if err := rows.Scan(&e.ID, &e.FamilyID, &e.Name, &e.DateOfBirth); err != nil {
+			return nil, err
+		}
+		entities = append(entities, e)
+	}
+	return entities, rows.Err()
+}
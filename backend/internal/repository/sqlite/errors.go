@@ -0,0 +1,25 @@
+package sqlite
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/luisteixeira/waypoint/backend/internal/domain"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+// activeEntityColumns identifies the unique index allowing at most one
+// in-progress realization per child in SQLite's constraint messages.
+const activeEntityColumns = "activity_realizations.family_id, activity_realizations.entity_id"
+
+// mapError translates constraint violations into domain errors.
+func mapError(err error) error {
+	var sqliteErr *sqlite.Error
+	if errors.As(err, &sqliteErr) &&
+		sqliteErr.Code() == sqlite3.SQLITE_CONSTRAINT_UNIQUE &&
+		strings.Contains(sqliteErr.Error(), activeEntityColumns) {
+		return domain.ErrEntityBusy
+	}
+	return err
+}
@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS realization_events;
+DROP TABLE IF EXISTS realization_caregivers;
+DROP TABLE IF EXISTS activity_realizations;
+DROP TABLE IF EXISTS activity_definitions;
+DROP TABLE IF EXISTS entities;
+DROP TABLE IF EXISTS caregivers;
+DROP TABLE IF EXISTS families;
@@ -0,0 +1,66 @@
+CREATE TABLE families (
+    id TEXT PRIMARY KEY,
+    name TEXT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE caregivers (
+    id TEXT PRIMARY KEY,
+    family_id TEXT REFERENCES families(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    email TEXT UNIQUE NOT NULL,
+    password_hash TEXT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE entities (
+    id TEXT PRIMARY KEY,
+    family_id TEXT REFERENCES families(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    date_of_birth DATE,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE activity_definitions(
+    id TEXT PRIMARY KEY,
+    family_id TEXT NOT NULL,
+    name TEXT NOT NULL,
+    description TEXT,
+    color_code TEXT,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (family_id, name)
+);
+
+CREATE TABLE activity_realizations(
+    id TEXT PRIMARY KEY,
+    family_id TEXT NOT NULL,
+    definition_id TEXT NOT NULL,
+    entity_id TEXT NOT NULL,
+    status TEXT NOT NULL,
+    started_at TIMESTAMP,
+    finished_at TIMESTAMP,
+    version INTEGER NOT NULL DEFAULT 1
+);
+
+CREATE UNIQUE INDEX uq_active_entity_realization
+ON activity_realizations (family_id, entity_id)
+WHERE status = 'in_progress';
+
+CREATE TABLE realization_caregivers(
+    realization_id TEXT REFERENCES activity_realizations(id) ON DELETE CASCADE,
+    caregiver_id TEXT NOT NULL,
+    PRIMARY KEY (realization_id, caregiver_id)
+);
+
+CREATE TABLE realization_events(
+    sequence INTEGER PRIMARY KEY AUTOINCREMENT,
+    realization_id TEXT NOT NULL,
+    family_id TEXT NOT NULL,
+    event_type TEXT NOT NULL,
+    data TEXT NOT NULL DEFAULT '{}',
+    occurred_at TIMESTAMP NOT NULL,
+    recorded_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_realization_events_stream
+ON realization_events (realization_id, sequence);
@@ -0,0 +1,11 @@
+DROP TRIGGER IF EXISTS sync_activity_realizations_insert;
+DROP TRIGGER IF EXISTS sync_activity_realizations_update;
+DROP TRIGGER IF EXISTS sync_activity_realizations_delete;
+DROP TRIGGER IF EXISTS sync_activity_definitions_insert;
+DROP TRIGGER IF EXISTS sync_activity_definitions_update;
+DROP TRIGGER IF EXISTS sync_activity_definitions_delete;
+DROP TRIGGER IF EXISTS sync_entities_insert;
+DROP TRIGGER IF EXISTS sync_entities_update;
+DROP TRIGGER IF EXISTS sync_entities_delete;
+DROP TABLE IF EXISTS sync_changes;
+DROP TABLE IF EXISTS sync_sequence;
@@ -0,0 +1,168 @@
+-- sync_changes holds the latest change to every realization, definition and
+-- entity, numbered from the counter in sync_sequence, so clients can ask for
+-- everything that changed after the last sequence they saw. Deleted rows are
+-- kept as tombstones. SQLite has a single writer, so numbering in triggers
+-- keeps changes in commit order. Updates that leave a row as it was, like
+-- upserts finding it already there, are not changes.
+CREATE TABLE sync_sequence (value INTEGER NOT NULL);
+INSERT INTO sync_sequence (value) VALUES (0);
+
+CREATE TABLE sync_changes(
+    kind TEXT NOT NULL,
+    object_id TEXT NOT NULL,
+    family_id TEXT NOT NULL,
+    sequence INTEGER NOT NULL,
+    deleted INTEGER NOT NULL DEFAULT 0,
+    changed_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (kind, object_id)
+);
+
+CREATE INDEX idx_sync_changes_family_sequence
+ON sync_changes (family_id, sequence);
+
+CREATE TRIGGER sync_activity_realizations_insert AFTER INSERT ON activity_realizations
+WHEN NEW.family_id IS NOT NULL
+BEGIN
+    UPDATE sync_sequence SET value = value + 1;
+    INSERT INTO sync_changes (kind, object_id, family_id, sequence, deleted, changed_at)
+    VALUES ('realization', NEW.id, NEW.family_id, (SELECT value FROM sync_sequence), 0, CURRENT_TIMESTAMP)
+    ON CONFLICT (kind, object_id) DO UPDATE
+    SET family_id = excluded.family_id,
+        sequence = excluded.sequence,
+        deleted = excluded.deleted,
+        changed_at = excluded.changed_at;
+END;
+
+CREATE TRIGGER sync_activity_realizations_update AFTER UPDATE ON activity_realizations
+WHEN NEW.family_id IS NOT NULL AND (
+    NEW.family_id IS NOT OLD.family_id
+    OR NEW.definition_id IS NOT OLD.definition_id
+    OR NEW.entity_id IS NOT OLD.entity_id
+    OR NEW.status IS NOT OLD.status
+    OR NEW.started_at IS NOT OLD.started_at
+    OR NEW.finished_at IS NOT OLD.finished_at
+    OR NEW.version IS NOT OLD.version
+)
+BEGIN
+    UPDATE sync_sequence SET value = value + 1;
+    INSERT INTO sync_changes (kind, object_id, family_id, sequence, deleted, changed_at)
+    VALUES ('realization', NEW.id, NEW.family_id, (SELECT value FROM sync_sequence), 0, CURRENT_TIMESTAMP)
+    ON CONFLICT (kind, object_id) DO UPDATE
+    SET family_id = excluded.family_id,
+        sequence = excluded.sequence,
+        deleted = excluded.deleted,
+        changed_at = excluded.changed_at;
+END;
+
+CREATE TRIGGER sync_activity_realizations_delete AFTER DELETE ON activity_realizations
+WHEN OLD.family_id IS NOT NULL
+BEGIN
+    UPDATE sync_sequence SET value = value + 1;
+    INSERT INTO sync_changes (kind, object_id, family_id, sequence, deleted, changed_at)
+    VALUES ('realization', OLD.id, OLD.family_id, (SELECT value FROM sync_sequence), 1, CURRENT_TIMESTAMP)
+    ON CONFLICT (kind, object_id) DO UPDATE
+    SET family_id = excluded.family_id,
+        sequence = excluded.sequence,
+        deleted = excluded.deleted,
+        changed_at = excluded.changed_at;
+END;
+
+CREATE TRIGGER sync_activity_definitions_insert AFTER INSERT ON activity_definitions
+WHEN NEW.family_id IS NOT NULL
+BEGIN
+    UPDATE sync_sequence SET value = value + 1;
+    INSERT INTO sync_changes (kind, object_id, family_id, sequence, deleted, changed_at)
+    VALUES ('definition', NEW.id, NEW.family_id, (SELECT value FROM sync_sequence), 0, CURRENT_TIMESTAMP)
+    ON CONFLICT (kind, object_id) DO UPDATE
+    SET family_id = excluded.family_id,
+        sequence = excluded.sequence,
+        deleted = excluded.deleted,
+        changed_at = excluded.changed_at;
+END;
+
+CREATE TRIGGER sync_activity_definitions_update AFTER UPDATE ON activity_definitions
+WHEN NEW.family_id IS NOT NULL AND (
+    NEW.family_id IS NOT OLD.family_id
+    OR NEW.name IS NOT OLD.name
+    OR NEW.description IS NOT OLD.description
+    OR NEW.color_code IS NOT OLD.color_code
+)
+BEGIN
+    UPDATE sync_sequence SET value = value + 1;
+    INSERT INTO sync_changes (kind, object_id, family_id, sequence, deleted, changed_at)
+    VALUES ('definition', NEW.id, NEW.family_id, (SELECT value FROM sync_sequence), 0, CURRENT_TIMESTAMP)
+    ON CONFLICT (kind, object_id) DO UPDATE
+    SET family_id = excluded.family_id,
+        sequence = excluded.sequence,
+        deleted = excluded.deleted,
+        changed_at = excluded.changed_at;
+END;
+
+CREATE TRIGGER sync_activity_definitions_delete AFTER DELETE ON activity_definitions
+WHEN OLD.family_id IS NOT NULL
+BEGIN
+    UPDATE sync_sequence SET value = value + 1;
+    INSERT INTO sync_changes (kind, object_id, family_id, sequence, deleted, changed_at)
+    VALUES ('definition', OLD.id, OLD.family_id, (SELECT value FROM sync_sequence), 1, CURRENT_TIMESTAMP)
+    ON CONFLICT (kind, object_id) DO UPDATE
+    SET family_id = excluded.family_id,
+        sequence = excluded.sequence,
+        deleted = excluded.deleted,
+        changed_at = excluded.changed_at;
+END;
+
+CREATE TRIGGER sync_entities_insert AFTER INSERT ON entities
+WHEN NEW.family_id IS NOT NULL
+BEGIN
+    UPDATE sync_sequence SET value = value + 1;
+    INSERT INTO sync_changes (kind, object_id, family_id, sequence, deleted, changed_at)
+    VALUES ('entity', NEW.id, NEW.family_id, (SELECT value FROM sync_sequence), 0, CURRENT_TIMESTAMP)
+    ON CONFLICT (kind, object_id) DO UPDATE
+    SET family_id = excluded.family_id,
+        sequence = excluded.sequence,
+        deleted = excluded.deleted,
+        changed_at = excluded.changed_at;
+END;
+
+CREATE TRIGGER sync_entities_update AFTER UPDATE ON entities
+WHEN NEW.family_id IS NOT NULL AND (
+    NEW.family_id IS NOT OLD.family_id
+    OR NEW.name IS NOT OLD.name
+    OR NEW.date_of_birth IS NOT OLD.date_of_birth
+)
+BEGIN
+    UPDATE sync_sequence SET value = value + 1;
+    INSERT INTO sync_changes (kind, object_id, family_id, sequence, deleted, changed_at)
+    VALUES ('entity', NEW.id, NEW.family_id, (SELECT value FROM sync_sequence), 0, CURRENT_TIMESTAMP)
+    ON CONFLICT (kind, object_id) DO UPDATE
+    SET family_id = excluded.family_id,
+        sequence = excluded.sequence,
+        deleted = excluded.deleted,
+        changed_at = excluded.changed_at;
+END;
+
+CREATE TRIGGER sync_entities_delete AFTER DELETE ON entities
+WHEN OLD.family_id IS NOT NULL
+BEGIN
+    UPDATE sync_sequence SET value = value + 1;
+    INSERT INTO sync_changes (kind, object_id, family_id, sequence, deleted, changed_at)
+    VALUES ('entity', OLD.id, OLD.family_id, (SELECT value FROM sync_sequence), 1, CURRENT_TIMESTAMP)
+    ON CONFLICT (kind, object_id) DO UPDATE
+    SET family_id = excluded.family_id,
+        sequence = excluded.sequence,
+        deleted = excluded.deleted,
+        changed_at = excluded.changed_at;
+END;
+
+-- Number what already exists, so the first sync returns it.
+INSERT INTO sync_changes (kind, object_id, family_id, sequence)
+SELECT kind, id, family_id, ROW_NUMBER() OVER (ORDER BY rank, id)
+FROM (
+    SELECT 'entity' AS kind, 0 AS rank, id, family_id FROM entities WHERE family_id IS NOT NULL
+    UNION ALL
+    SELECT 'definition', 1, id, family_id FROM activity_definitions
+    UNION ALL
+    SELECT 'realization', 2, id, family_id FROM activity_realizations
+);
+
+UPDATE sync_sequence SET value = (SELECT COUNT(*) FROM sync_changes);
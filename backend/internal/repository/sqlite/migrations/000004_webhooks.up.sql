@@ -0,0 +1,32 @@
+CREATE TABLE webhook_subscriptions (
+    id TEXT PRIMARY KEY,
+    family_id TEXT NOT NULL,
+    url TEXT NOT NULL,
+    -- events is a comma-separated list of event types.
+    events TEXT NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    secret TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX idx_webhook_subscriptions_family ON webhook_subscriptions (family_id);
+
+CREATE TABLE webhook_deliveries (
+    id TEXT PRIMARY KEY,
+    subscription_id TEXT NOT NULL REFERENCES webhook_subscriptions(id) ON DELETE CASCADE,
+    family_id TEXT NOT NULL,
+    event_id TEXT NOT NULL,
+    event_type TEXT NOT NULL,
+    payload BLOB NOT NULL,
+    status TEXT NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP NOT NULL,
+    last_attempt_at TIMESTAMP,
+    response_status INTEGER,
+    last_error TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL,
+    delivered_at TIMESTAMP
+);
+
+CREATE INDEX idx_webhook_deliveries_due ON webhook_deliveries (status, next_attempt_at);
+CREATE INDEX idx_webhook_deliveries_subscription ON webhook_deliveries (subscription_id, created_at);
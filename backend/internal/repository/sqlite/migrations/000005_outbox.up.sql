@@ -0,0 +1,17 @@
+CREATE TABLE outbox_events (
+    sequence INTEGER PRIMARY KEY AUTOINCREMENT,
+    id TEXT NOT NULL UNIQUE,
+    family_id TEXT NOT NULL,
+    type TEXT NOT NULL,
+    realization BLOB NOT NULL,
+    occurred_at TIMESTAMP NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    -- done_sinks is a comma-separated list of the sinks that accepted the event.
+    done_sinks TEXT NOT NULL DEFAULT '',
+    next_attempt_at TIMESTAMP NOT NULL,
+    last_error TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL,
+    published_at TIMESTAMP
+);
+
+CREATE INDEX idx_outbox_events_due ON outbox_events (published_at, next_attempt_at);
@@ -0,0 +1,34 @@
+CREATE TABLE alert_rules (
+    id TEXT PRIMARY KEY,
+    family_id TEXT NOT NULL,
+    kind TEXT NOT NULL,
+    definition_id TEXT NOT NULL,
+    entity_id TEXT,
+    threshold_seconds INTEGER NOT NULL,
+    -- channels is a comma-separated list of channels.
+    channels TEXT NOT NULL DEFAULT '',
+    email TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX idx_alert_rules_family ON alert_rules (family_id);
+
+CREATE TABLE alerts (
+    id TEXT PRIMARY KEY,
+    family_id TEXT NOT NULL,
+    rule_id TEXT NOT NULL,
+    kind TEXT NOT NULL,
+    definition_id TEXT NOT NULL,
+    entity_id TEXT NOT NULL,
+    realization_id TEXT,
+    subject_id TEXT NOT NULL,
+    message TEXT NOT NULL,
+    status TEXT NOT NULL,
+    triggered_at TIMESTAMP NOT NULL,
+    snoozed_until TIMESTAMP,
+    acknowledged_at TIMESTAMP,
+    resolved_at TIMESTAMP
+);
+
+CREATE UNIQUE INDEX uq_unresolved_alert ON alerts (rule_id, subject_id) WHERE status <> 'resolved';
+CREATE INDEX idx_alerts_family ON alerts (family_id, status, triggered_at);
@@ -0,0 +1,3 @@
+DROP TABLE IF EXISTS push_preferences;
+DROP TABLE IF EXISTS push_subscriptions;
+ALTER TABLE outbox_events DROP COLUMN caregiver_id;
@@ -0,0 +1,25 @@
+ALTER TABLE outbox_events ADD COLUMN caregiver_id TEXT;
+
+CREATE TABLE push_subscriptions (
+    id TEXT PRIMARY KEY,
+    family_id TEXT NOT NULL,
+    caregiver_id TEXT NOT NULL,
+    endpoint TEXT NOT NULL UNIQUE,
+    p256dh TEXT NOT NULL,
+    auth TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX idx_push_subscriptions_caregiver ON push_subscriptions (family_id, caregiver_id);
+
+CREATE TABLE push_preferences (
+    family_id TEXT NOT NULL,
+    caregiver_id TEXT NOT NULL,
+    -- entity_ids is a comma-separated list of the children followed.
+    entity_ids TEXT NOT NULL DEFAULT '',
+    activity_started BOOLEAN NOT NULL,
+    activity_completed BOOLEAN NOT NULL,
+    alerts BOOLEAN NOT NULL,
+    updated_at TIMESTAMP NOT NULL,
+    PRIMARY KEY (family_id, caregiver_id)
+);
@@ -0,0 +1 @@
+ALTER TABLE activity_realizations DROP COLUMN planned_for;
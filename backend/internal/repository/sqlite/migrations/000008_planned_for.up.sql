@@ -0,0 +1 @@
+ALTER TABLE activity_realizations ADD COLUMN planned_for TIMESTAMP;
@@ -0,0 +1,2 @@
+DROP INDEX idx_webhook_deliveries_event;
+ALTER TABLE webhook_deliveries DROP COLUMN redelivery_of;
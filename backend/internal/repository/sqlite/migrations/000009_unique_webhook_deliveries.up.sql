@@ -0,0 +1,24 @@
+-- redelivery_of links a redelivery to the delivery it repeats, so that only
+-- the first delivery of an event to a subscription has to be unique.
+ALTER TABLE webhook_deliveries ADD COLUMN redelivery_of TEXT;
+
+-- Retried outbox events may already have queued an event twice; the oldest
+-- delivery is kept as the original.
+UPDATE webhook_deliveries
+SET redelivery_of = (
+    SELECT first.id FROM webhook_deliveries first
+    WHERE first.subscription_id = webhook_deliveries.subscription_id
+      AND first.event_id = webhook_deliveries.event_id
+    ORDER BY first.created_at, first.id
+    LIMIT 1
+)
+WHERE id <> (
+    SELECT first.id FROM webhook_deliveries first
+    WHERE first.subscription_id = webhook_deliveries.subscription_id
+      AND first.event_id = webhook_deliveries.event_id
+    ORDER BY first.created_at, first.id
+    LIMIT 1
+);
+
+CREATE UNIQUE INDEX idx_webhook_deliveries_event ON webhook_deliveries (subscription_id, event_id)
+    WHERE redelivery_of IS NULL;
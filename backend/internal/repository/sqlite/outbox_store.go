@@ -0,0 +1,131 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/luisteixeira/waypoint/backend/internal/outbox"
+	"github.com/luisteixeira/waypoint/backend/internal/repository"
+)
+
+type sqliteOutboxStore struct {
+	db *sql.DB
+}
+
+func NewSQLiteOutboxStore(db *sql.DB) *sqliteOutboxStore {
+	return &sqliteOutboxStore{db: db}
+}
+
+func (s *sqliteOutboxStore) Append(ctx context.Context, events ...outbox.Event) error {
+	familyID, err := repository.GetFamilyIdFromContext(ctx)
+	if err != nil {
+		return err
+	}
+	now := time.Now().UTC()
+
+	return repository.InTx(ctx, s.db, func(q repository.Querier) error {
+		for _, event := range events {
+			realization, err := json.Marshal(event.Realization)
+			if err != nil {
+				return err
+			}
+			_, err = q.ExecContext(ctx, `
+				INSERT INTO outbox_events (id, family_id, caregiver_id, type, realization, occurred_at, next_attempt_at, created_at)
+				VALUES (?, ?, ?, ?, ?, ?, ?, ?)`,
+				event.ID, familyID, nullUUID(event.CaregiverID), string(event.Type), realization, event.OccurredAt.UTC(), now, now,
+			)
+			if err != nil {
+				return fmt.Errorf("failed to append outbox event: %w", err)
+			}
+		}
+		return nil
+	})
+}
+
+func (s *sqliteOutboxStore) ClaimDue(ctx context.Context, now, leaseUntil time.Time, limit int) ([]outbox.Pending, error) {
+	var claimed []outbox.Pending
+	err := repository.InTx(ctx, s.db, func(q repository.Querier) error {
+		rows, err := q.QueryContext(ctx, `
+			SELECT sequence, id, family_id, caregiver_id, type, realization, occurred_at, attempts, done_sinks, last_error
+			FROM outbox_events
+			WHERE published_at IS NULL AND next_attempt_at <= ?
+			ORDER BY sequence
+			LIMIT ?`,
+			now.UTC(), limit,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to claim outbox events: %w", err)
+		}
+		for rows.Next() {
+			var p outbox.Pending
+			var caregiverID uuid.NullUUID
+			var realization []byte
+			var done string
+			if err := rows.Scan(&p.Sequence, &p.ID, &p.FamilyID, &caregiverID, &p.Type, &realization, &p.OccurredAt, &p.Attempts, &done, &p.LastError); err != nil {
+				rows.Close()
+				return err
+			}
+			p.CaregiverID = caregiverID.UUID
+			if err := json.Unmarshal(realization, &p.Realization); err != nil {
+				rows.Close()
+				return fmt.Errorf("failed to decode outbox event %s: %w", p.ID, err)
+			}
+			if done != "" {
+				p.Done = strings.Split(done, ",")
+			}
+			p.NextAttemptAt = leaseUntil
+			claimed = append(claimed, p)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		for _, p := range claimed {
+			_, err := q.ExecContext(ctx, "UPDATE outbox_events SET next_attempt_at = ? WHERE id = ?", leaseUntil.UTC(), p.ID)
+			if err != nil {
+				return fmt.Errorf("failed to lease outbox event: %w", err)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return claimed, nil
+}
+
+func (s *sqliteOutboxStore) RecordResult(ctx context.Context, id uuid.UUID, result outbox.Result) error {
+	var publishedAt, retryAt *time.Time
+	if result.Published {
+		publishedAt = &result.At
+	} else {
+		retryAt = &result.RetryAt
+	}
+	_, err := repository.Conn(ctx, s.db).ExecContext(ctx, `
+		UPDATE outbox_events
+		SET attempts = attempts + 1, done_sinks = ?, last_error = ?,
+			next_attempt_at = COALESCE(?, next_attempt_at), published_at = ?
+		WHERE id = ?`,
+		strings.Join(result.Done, ","), result.Error, utc(retryAt), utc(publishedAt), id,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record outbox result: %w", err)
+	}
+	return nil
+}
+
+func (s *sqliteOutboxStore) DeletePublished(ctx context.Context, before time.Time) (int64, error) {
+	res, err := repository.Conn(ctx, s.db).ExecContext(ctx,
+		"DELETE FROM outbox_events WHERE published_at IS NOT NULL AND published_at < ?", before.UTC(),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete published outbox events: %w", err)
+	}
+	return res.RowsAffected()
+}
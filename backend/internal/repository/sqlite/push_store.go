@@ -0,0 +1,210 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/luisteixeira/waypoint/backend/internal/domain"
+	"github.com/luisteixeira/waypoint/backend/internal/push"
+	"github.com/luisteixeira/waypoint/backend/internal/repository"
+)
+
+const preferenceColumns = `family_id, caregiver_id, entity_ids, activity_started, activity_completed, alerts, updated_at`
+
+type sqlitePushStore struct {
+	db *sql.DB
+}
+
+func NewSQLitePushStore(db *sql.DB) *sqlitePushStore {
+	return &sqlitePushStore{db: db}
+}
+
+func (s *sqlitePushStore) SaveSubscription(ctx context.Context, sub *push.Subscription) error {
+	familyID, err := repository.GetFamilyIdFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	sub.FamilyID = familyID
+	// A browser that subscribes again, perhaps for another caregiver, takes
+	// its endpoint over from the old subscription.
+	return repository.InTx(ctx, s.db, func(q repository.Querier) error {
+		if _, err := q.ExecContext(ctx, "DELETE FROM push_subscriptions WHERE endpoint = ?", sub.Endpoint); err != nil {
+			return fmt.Errorf("failed to replace push subscription: %w", err)
+		}
+		_, err := q.ExecContext(ctx, `
+			INSERT INTO push_subscriptions (id, family_id, caregiver_id, endpoint, p256dh, auth, created_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?)`,
+			sub.ID, familyID, sub.CaregiverID, sub.Endpoint, sub.P256DH, sub.Auth, sub.CreatedAt.UTC(),
+		)
+		if err != nil {
+			return fmt.Errorf("failed to save push subscription: %w", err)
+		}
+		return nil
+	})
+}
+
+func (s *sqlitePushStore) ListSubscriptions(ctx context.Context, caregiverID uuid.UUID) ([]push.Subscription, error) {
+	familyID, err := repository.GetFamilyIdFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	where, args := "WHERE family_id = ?", []any{familyID}
+	if caregiverID != uuid.Nil {
+		where, args = where+" AND caregiver_id = ?", append(args, caregiverID)
+	}
+	rows, err := repository.Conn(ctx, s.db).QueryContext(ctx, `
+		SELECT id, family_id, caregiver_id, endpoint, p256dh, auth, created_at
+		FROM push_subscriptions `+where+`
+		ORDER BY created_at, id`,
+		args...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list push subscriptions: %w", err)
+	}
+	defer rows.Close()
+
+	subs := []push.Subscription{}
+	for rows.Next() {
+		var sub push.Subscription
+		err := rows.Scan(&sub.ID, &sub.FamilyID, &sub.CaregiverID, &sub.Endpoint, &sub.P256DH, &sub.Auth, &sub.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+		subs = append(subs, sub)
+	}
+	return subs, rows.Err()
+}
+
+func (s *sqlitePushStore) DeleteSubscription(ctx context.Context, caregiverID, id uuid.UUID) error {
+	familyID, err := repository.GetFamilyIdFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	res, err := repository.Conn(ctx, s.db).ExecContext(ctx,
+		"DELETE FROM push_subscriptions WHERE id = ? AND family_id = ? AND caregiver_id = ?",
+		id, familyID, caregiverID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to delete push subscription: %w", err)
+	}
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return &domain.NotFoundError{Resource: "push subscription", ID: id}
+	}
+	return nil
+}
+
+func (s *sqlitePushStore) DeleteEndpoint(ctx context.Context, endpoint string) error {
+	familyID, err := repository.GetFamilyIdFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	_, err = repository.Conn(ctx, s.db).ExecContext(ctx,
+		"DELETE FROM push_subscriptions WHERE endpoint = ? AND family_id = ?",
+		endpoint, familyID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to delete push subscription: %w", err)
+	}
+	return nil
+}
+
+func (s *sqlitePushStore) GetPreferences(ctx context.Context, caregiverID uuid.UUID) (*push.Preferences, error) {
+	familyID, err := repository.GetFamilyIdFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	prefs, err := s.preferences(ctx, "WHERE family_id = ? AND caregiver_id = ?", familyID, caregiverID)
+	if err != nil {
+		return nil, err
+	}
+	if len(prefs) == 0 {
+		return nil, &domain.NotFoundError{Resource: "push preferences", ID: caregiverID}
+	}
+	return &prefs[0], nil
+}
+
+func (s *sqlitePushStore) SavePreferences(ctx context.Context, prefs *push.Preferences) error {
+	familyID, err := repository.GetFamilyIdFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	prefs.FamilyID = familyID
+	entityIDs := make([]string, len(prefs.EntityIDs))
+	for i, id := range prefs.EntityIDs {
+		entityIDs[i] = id.String()
+	}
+	_, err = repository.Conn(ctx, s.db).ExecContext(ctx, `
+		INSERT INTO push_preferences (`+preferenceColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (family_id, caregiver_id) DO UPDATE SET
+			entity_ids = EXCLUDED.entity_ids,
+			activity_started = EXCLUDED.activity_started,
+			activity_completed = EXCLUDED.activity_completed,
+			alerts = EXCLUDED.alerts,
+			updated_at = EXCLUDED.updated_at`,
+		familyID, prefs.CaregiverID, strings.Join(entityIDs, ","),
+		prefs.ActivityStarted, prefs.ActivityCompleted, prefs.Alerts, prefs.UpdatedAt.UTC(),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to save push preferences: %w", err)
+	}
+	return nil
+}
+
+func (s *sqlitePushStore) ListPreferences(ctx context.Context) ([]push.Preferences, error) {
+	familyID, err := repository.GetFamilyIdFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s.preferences(ctx, "WHERE family_id = ?", familyID)
+}
+
+func (s *sqlitePushStore) preferences(ctx context.Context, where string, args ...any) ([]push.Preferences, error) {
+	rows, err := repository.Conn(ctx, s.db).QueryContext(ctx, `
+		SELECT `+preferenceColumns+`
+		FROM push_preferences `+where+`
+		ORDER BY caregiver_id`,
+		args...,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list push preferences: %w", err)
+	}
+	defer rows.Close()
+
+	all := []push.Preferences{}
+	for rows.Next() {
+		var prefs push.Preferences
+		var entityIDs string
+		err := rows.Scan(
+			&prefs.FamilyID, &prefs.CaregiverID, &entityIDs,
+			&prefs.ActivityStarted, &prefs.ActivityCompleted, &prefs.Alerts, &prefs.UpdatedAt,
+		)
+		if err != nil {
+			return nil, err
+		}
+		prefs.EntityIDs = []uuid.UUID{}
+		for _, id := range strings.Split(entityIDs, ",") {
+			if id == "" {
+				continue
+			}
+			entityID, err := uuid.Parse(id)
+			if err != nil {
+				return nil, fmt.Errorf("invalid followed entity %q: %w", id, err)
+			}
+			prefs.EntityIDs = append(prefs.EntityIDs, entityID)
+		}
+		all = append(all, prefs)
+	}
+	return all, rows.Err()
+}
@@ -0,0 +1,366 @@
+package sqlite
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/luisteixeira/waypoint/backend/internal/domain"
+	"github.com/luisteixeira/waypoint/backend/internal/repository"
+	"github.com/luisteixeira/waypoint/backend/internal/webhook"
+)
+
+type sqliteWebhookStore struct {
+	db *sql.DB
+}
+
+func NewSQLiteWebhookStore(db *sql.DB) *sqliteWebhookStore {
+	return &sqliteWebhookStore{db: db}
+}
+
+func (s *sqliteWebhookStore) CreateSubscription(ctx context.Context, sub *webhook.Subscription) error {
+	familyID, err := repository.GetFamilyIdFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	sub.FamilyID = familyID
+	_, err = repository.Conn(ctx, s.db).ExecContext(ctx, `
+		INSERT INTO webhook_subscriptions (id, family_id, url, events, description, secret, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`,
+		sub.ID, familyID, sub.URL, joinEvents(sub.Events), sub.Description, sub.Secret, sub.CreatedAt.UTC(),
+	)
+	if err != nil {
+		return fmt.Errorf("failed to create webhook subscription: %w", err)
+	}
+	return nil
+}
+
+func (s *sqliteWebhookStore) ListSubscriptions(ctx context.Context) ([]webhook.Subscription, error) {
+	familyID, err := repository.GetFamilyIdFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s.subscriptions(ctx, repository.Conn(ctx, s.db), familyID)
+}
+
+func (s *sqliteWebhookStore) subscriptions(ctx context.Context, q repository.Querier, familyID uuid.UUID) ([]webhook.Subscription, error) {
+	rows, err := q.QueryContext(ctx, `
+		SELECT id, url, events, description, secret, created_at
+		FROM webhook_subscriptions
+		WHERE family_id = ?
+		ORDER BY created_at, id`,
+		familyID,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list webhook subscriptions: %w", err)
+	}
+	defer rows.Close()
+
+	subs := []webhook.Subscription{}
+	for rows.Next() {
+		sub := webhook.Subscription{FamilyID: familyID}
+		var events string
+		if err := rows.Scan(&sub.ID, &sub.URL, &events, &sub.Description, &sub.Secret, &sub.CreatedAt); err != nil {
+			return nil, err
+		}
+		for _, e := range strings.Split(events, ",") {
+			sub.Events = append(sub.Events, webhook.EventType(e))
+		}
+		subs = append(subs, sub)
+	}
+	return subs, rows.Err()
+}
+
+func (s *sqliteWebhookStore) DeleteSubscription(ctx context.Context, id uuid.UUID) error {
+	familyID, err := repository.GetFamilyIdFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	// Foreign keys are not enforced, so the deliveries do not cascade.
+	return repository.InTx(ctx, s.db, func(q repository.Querier) error {
+		res, err := q.ExecContext(ctx, "DELETE FROM webhook_subscriptions WHERE id = ? AND family_id = ?", id, familyID)
+		if err != nil {
+			return fmt.Errorf("failed to delete webhook subscription: %w", err)
+		}
+		if n, err := res.RowsAffected(); err != nil {
+			return err
+		} else if n == 0 {
+			return &domain.NotFoundError{Resource: "webhook", ID: id}
+		}
+		_, err = q.ExecContext(ctx, "DELETE FROM webhook_deliveries WHERE subscription_id = ?", id)
+		return err
+	})
+}
+
+func (s *sqliteWebhookStore) Enqueue(ctx context.Context, event webhook.Event) ([]webhook.Delivery, error) {
+	familyID, err := repository.GetFamilyIdFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now().UTC()
+
+	deliveries := []webhook.Delivery{}
+	err = repository.InTx(ctx, s.db, func(q repository.Querier) error {
+		subs, err := s.subscriptions(ctx, q, familyID)
+		if err != nil {
+			return err
+		}
+		for _, sub := range subs {
+			if !sub.Wants(event.Type) {
+				continue
+			}
+			d := webhook.Delivery{
+				ID:             uuid.New(),
+				SubscriptionID: sub.ID,
+				FamilyID:       familyID,
+				EventID:        event.ID,
+				EventType:      event.Type,
+				Payload:        payload,
+				Status:         webhook.DeliveryPending,
+				NextAttemptAt:  now,
+				CreatedAt:      now,
+			}
+			// An event the outbox hands over again is already queued; only
+			// the subscriptions it is new to get a delivery.
+			inserted, err := insertDelivery(ctx, q, d, nil)
+			if err != nil {
+				return err
+			}
+			if inserted {
+				deliveries = append(deliveries, d)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+func (s *sqliteWebhookStore) ListDeliveries(ctx context.Context, subscriptionID uuid.UUID, limit int) ([]webhook.Delivery, error) {
+	if err := s.checkSubscription(ctx, subscriptionID); err != nil {
+		return nil, err
+	}
+
+	rows, err := repository.Conn(ctx, s.db).QueryContext(ctx, `
+		SELECT `+deliveryColumns+`
+		FROM webhook_deliveries
+		WHERE subscription_id = ?
+		ORDER BY created_at DESC, id
+		LIMIT ?`,
+		subscriptionID, limit,
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list webhook deliveries: %w", err)
+	}
+	defer rows.Close()
+
+	deliveries := []webhook.Delivery{}
+	for rows.Next() {
+		d, err := scanDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, *d)
+	}
+	return deliveries, rows.Err()
+}
+
+func (s *sqliteWebhookStore) Redeliver(ctx context.Context, subscriptionID, deliveryID uuid.UUID) (*webhook.Delivery, error) {
+	if err := s.checkSubscription(ctx, subscriptionID); err != nil {
+		return nil, err
+	}
+
+	var redelivery *webhook.Delivery
+	err := repository.InTx(ctx, s.db, func(q repository.Querier) error {
+		rows, err := q.QueryContext(ctx, `
+			SELECT `+deliveryColumns+`
+			FROM webhook_deliveries
+			WHERE id = ? AND subscription_id = ?`,
+			deliveryID, subscriptionID,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to load webhook delivery: %w", err)
+		}
+		if rows.Next() {
+			redelivery, err = scanDelivery(rows)
+		}
+		rows.Close()
+		if err != nil {
+			return err
+		}
+		if redelivery == nil {
+			return &domain.NotFoundError{Resource: "delivery", ID: deliveryID}
+		}
+
+		now := time.Now().UTC()
+		*redelivery = webhook.Delivery{
+			ID:             uuid.New(),
+			SubscriptionID: redelivery.SubscriptionID,
+			FamilyID:       redelivery.FamilyID,
+			EventID:        redelivery.EventID,
+			EventType:      redelivery.EventType,
+			Payload:        redelivery.Payload,
+			Status:         webhook.DeliveryPending,
+			NextAttemptAt:  now,
+			CreatedAt:      now,
+		}
+		_, err = insertDelivery(ctx, q, *redelivery, &deliveryID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return redelivery, nil
+}
+
+func (s *sqliteWebhookStore) ClaimDue(ctx context.Context, now, leaseUntil time.Time, limit int) ([]webhook.Delivery, error) {
+	var deliveries []webhook.Delivery
+	err := repository.InTx(ctx, s.db, func(q repository.Querier) error {
+		rows, err := q.QueryContext(ctx, `
+			SELECT `+claimedColumns+`, s.url, s.secret
+			FROM webhook_deliveries d
+			JOIN webhook_subscriptions s ON s.id = d.subscription_id
+			WHERE d.status = 'pending' AND d.next_attempt_at <= ?
+			ORDER BY d.next_attempt_at, d.created_at
+			LIMIT ?`,
+			now.UTC(), limit,
+		)
+		if err != nil {
+			return fmt.Errorf("failed to claim webhook deliveries: %w", err)
+		}
+		var url, secret string
+		for rows.Next() {
+			d, err := scanDelivery(rows, &url, &secret)
+			if err != nil {
+				rows.Close()
+				return err
+			}
+			d.URL, d.Secret = url, secret
+			deliveries = append(deliveries, *d)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		for i := range deliveries {
+			_, err := q.ExecContext(ctx, "UPDATE webhook_deliveries SET next_attempt_at = ? WHERE id = ?", leaseUntil.UTC(), deliveries[i].ID)
+			if err != nil {
+				return fmt.Errorf("failed to lease webhook delivery: %w", err)
+			}
+			deliveries[i].NextAttemptAt = leaseUntil
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return deliveries, nil
+}
+
+func (s *sqliteWebhookStore) RecordAttempt(ctx context.Context, deliveryID uuid.UUID, attempt webhook.Attempt) error {
+	var deliveredAt *time.Time
+	if attempt.Delivered {
+		deliveredAt = &attempt.At
+	}
+	_, err := repository.Conn(ctx, s.db).ExecContext(ctx, `
+		UPDATE webhook_deliveries
+		SET status = ?, attempts = attempts + 1, next_attempt_at = COALESCE(?, next_attempt_at),
+			last_attempt_at = ?, response_status = ?, last_error = ?, delivered_at = ?
+		WHERE id = ?`,
+		string(attempt.Status()), utc(attempt.RetryAt), attempt.At.UTC(), nullStatus(attempt.ResponseStatus), attempt.Error, utc(deliveredAt), deliveryID,
+	)
+	if err != nil {
+		return fmt.Errorf("failed to record webhook attempt: %w", err)
+	}
+	return nil
+}
+
+func (s *sqliteWebhookStore) checkSubscription(ctx context.Context, id uuid.UUID) error {
+	familyID, err := repository.GetFamilyIdFromContext(ctx)
+	if err != nil {
+		return err
+	}
+
+	var found uuid.UUID
+	err = repository.Conn(ctx, s.db).QueryRowContext(ctx,
+		"SELECT id FROM webhook_subscriptions WHERE id = ? AND family_id = ?", id, familyID,
+	).Scan(&found)
+	if errors.Is(err, sql.ErrNoRows) {
+		return &domain.NotFoundError{Resource: "webhook", ID: id}
+	}
+	if err != nil {
+		return fmt.Errorf("failed to load webhook subscription: %w", err)
+	}
+	return nil
+}
+
+// insertDelivery stores d, as a redelivery of the given delivery if one is
+// named. It reports false, storing nothing, if d is the first delivery of an
+// event that was already queued for its subscription.
+func insertDelivery(ctx context.Context, q repository.Querier, d webhook.Delivery, redeliveryOf *uuid.UUID) (bool, error) {
+	res, err := q.ExecContext(ctx, `
+		INSERT INTO webhook_deliveries
+			(id, subscription_id, family_id, event_id, event_type, payload, status, next_attempt_at, created_at, redelivery_of)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (subscription_id, event_id) WHERE redelivery_of IS NULL DO NOTHING`,
+		d.ID, d.SubscriptionID, d.FamilyID, d.EventID, string(d.EventType), []byte(d.Payload), string(d.Status),
+		d.NextAttemptAt.UTC(), d.CreatedAt.UTC(), redeliveryOf,
+	)
+	if err != nil {
+		return false, fmt.Errorf("failed to queue webhook delivery: %w", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to queue webhook delivery: %w", err)
+	}
+	return n == 1, nil
+}
+
+const deliveryColumns = `id, subscription_id, family_id, event_id, event_type, payload, status, attempts,
+	next_attempt_at, last_attempt_at, response_status, last_error, created_at, delivered_at`
+
+const claimedColumns = `d.id, d.subscription_id, d.family_id, d.event_id, d.event_type, d.payload, d.status, d.attempts,
+	d.next_attempt_at, d.last_attempt_at, d.response_status, d.last_error, d.created_at, d.delivered_at`
+
+// scanDelivery reads deliveryColumns, followed by any extra columns.
+func scanDelivery(rows *sql.Rows, extra ...any) (*webhook.Delivery, error) {
+	var d webhook.Delivery
+	var responseStatus sql.NullInt64
+	fields := []any{
+		&d.ID, &d.SubscriptionID, &d.FamilyID, &d.EventID, &d.EventType, &d.Payload, &d.Status, &d.Attempts,
+		&d.NextAttemptAt, &d.LastAttemptAt, &responseStatus, &d.LastError, &d.CreatedAt, &d.DeliveredAt,
+	}
+	if err := rows.Scan(append(fields, extra...)...); err != nil {
+		return nil, err
+	}
+	d.ResponseStatus = int(responseStatus.Int64)
+	return &d, nil
+}
+
+// nullStatus stores a missing response status as NULL.
+func nullStatus(status int) sql.NullInt64 {
+	return sql.NullInt64{Int64: int64(status), Valid: status != 0}
+}
+
+func joinEvents(events []webhook.EventType) string {
+	names := make([]string, len(events))
+	for i, e := range events {
+		names[i] = string(e)
+	}
+	return strings.Join(names, ",")
+}
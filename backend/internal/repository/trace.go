@@ -0,0 +1,84 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"strings"
+
+	"github.com/luisteixeira/waypoint/backend/internal/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("github.com/luisteixeira/waypoint/backend/internal/repository")
+
+// tracedQuerier starts a client span for every query. Arguments are left out
+// so personal data does not end up in traces.
+type tracedQuerier struct {
+	q      Querier
+	system attribute.KeyValue
+}
+
+func traced(q Querier, db *sql.DB) Querier {
+	return tracedQuerier{q: q, system: dbSystem(db)}
+}
+
+func (t tracedQuerier) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	ctx, span := t.start(ctx, query)
+	defer span.End()
+	result, err := t.q.ExecContext(ctx, query, args...)
+	record(span, err)
+	return result, err
+}
+
+func (t tracedQuerier) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	ctx, span := t.start(ctx, query)
+	defer span.End()
+	rows, err := t.q.QueryContext(ctx, query, args...)
+	record(span, err)
+	return rows, err
+}
+
+func (t tracedQuerier) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	ctx, span := t.start(ctx, query)
+	defer span.End()
+	row := t.q.QueryRowContext(ctx, query, args...)
+	record(span, row.Err())
+	return row
+}
+
+func (t tracedQuerier) start(ctx context.Context, query string) (context.Context, trace.Span) {
+	query = strings.Join(strings.Fields(query), " ")
+	operation, _, _ := strings.Cut(query, " ")
+
+	ctx, span := tracer.Start(ctx, operation,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(t.system, semconv.DBOperationName(operation), semconv.DBQueryText(query)),
+	)
+	if family, ok := tracing.FamilyID(ctx); ok {
+		span.SetAttributes(family)
+	}
+	return ctx, span
+}
+
+func record(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+}
+
+func dbSystem(db *sql.DB) attribute.KeyValue {
+	switch driver := fmt.Sprintf("%T", db.Driver()); driver {
+	case "*pq.Driver":
+		return semconv.DBSystemPostgreSQL
+	case "*sqlite.Driver":
+		return semconv.DBSystemSqlite
+	default:
+		return semconv.DBSystemOtherSQL
+	}
+}
@@ -0,0 +1,79 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+
+	"github.com/luisteixeira/waypoint/backend/internal/service"
+)
+
+// Querier is the part of *sql.DB and *sql.Tx the SQL repositories use, so
+// the same code runs inside or outside a unit of work.
+type Querier interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+type txKey struct{}
+
+type sqlTxManager struct {
+	db *sql.DB
+}
+
+// NewSQLTxManager returns a service.TxManager for repositories built on db.
+func NewSQLTxManager(db *sql.DB) *sqlTxManager {
+	return &sqlTxManager{db: db}
+}
+
+// WithinTx runs fn in a database transaction carried by the context it is
+// given. A call made inside another unit of work joins it.
+func (m *sqlTxManager) WithinTx(ctx context.Context, fn func(ctx context.Context) error) error {
+	if _, ok := ctx.Value(txKey{}).(*sql.Tx); ok {
+		return fn(ctx)
+	}
+
+	tx, err := m.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	ctx, committed := service.CommitHooks(ctx)
+	if err := fn(context.WithValue(ctx, txKey{}, tx)); err != nil {
+		return err
+	}
+	if err := tx.Commit(); err != nil {
+		return err
+	}
+	committed()
+	return nil
+}
+
+// Conn returns the transaction carried by ctx, or db when there is none.
+func Conn(ctx context.Context, db *sql.DB) Querier {
+	if tx, ok := ctx.Value(txKey{}).(*sql.Tx); ok {
+		return traced(tx, db)
+	}
+	return traced(db, db)
+}
+
+// InTx runs fn in the transaction carried by ctx, leaving its outcome to the
+// unit of work. Outside one it begins a transaction of its own and commits
+// it when fn succeeds.
+func InTx(ctx context.Context, db *sql.DB, fn func(q Querier) error) error {
+	if tx, ok := ctx.Value(txKey{}).(*sql.Tx); ok {
+		return fn(traced(tx, db))
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err := fn(traced(tx, db)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
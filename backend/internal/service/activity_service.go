@@ -3,110 +3,382 @@ package service
 import (
 	"context"
 	"fmt"
+	"slices"
+	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/google/uuid"
 	"github.com/luisteixeira/waypoint/backend/internal/domain"
 )
 
+// ClientTimeWindow bounds the client timestamps the service accepts for
+// actions queued offline: at most MaxAge behind its own clock, to allow for
+// time spent offline, and at most MaxAhead in front of it, to allow for
+// clock drift.
+type ClientTimeWindow struct {
+	MaxAge   time.Duration
+	MaxAhead time.Duration
+}
+
+// DefaultClientTimeWindow is the window the server accepts unless configured
+// otherwise.
+var DefaultClientTimeWindow = ClientTimeWindow{MaxAge: 72 * time.Hour, MaxAhead: 2 * time.Minute}
+
 type activityService struct {
 	repo    ActivityRepository
 	defRepo DefinitionRepository
+	tx      TxManager
+	machine *domain.StateMachine
+	window  ClientTimeWindow
 }
 
-func NewActivityService(repo ActivityRepository, defRepo DefinitionRepository) *activityService {
-	return &activityService{
+// NewActivityService returns the service, accepting client timestamps within
+// window for actions queued offline.
+func NewActivityService(repo ActivityRepository, defRepo DefinitionRepository, tx TxManager, window ClientTimeWindow) *activityService {
+	s := &activityService{
 		repo:    repo,
 		defRepo: defRepo,
+		tx:      tx,
+		machine: domain.NewStateMachine(AfterCommit),
+		window:  window,
 	}
+	s.machine.Before(s.ensureEntityFree)
+	return s
 }
 
 func (s *activityService) StartActivity(ctx context.Context, input domain.StartActivityInput) (*domain.ActivityRealization, error) {
-	var realization *domain.ActivityRealization
-	var err error
-
 	if input.RealizationID != uuid.Nil {
-		realization, err = s.repo.GetRealizationByID(ctx, input.RealizationID)
+		// Only a planned realization is started; a paused one is resumed
+		// through ResumeActivity.
+		return s.transition(ctx, input.RealizationID, input.ExpectedVersion, domain.StatusInProgress, domain.StatusPlanned)
+	}
+
+	now, err := s.now(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var realization *domain.ActivityRealization
+	err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		defID, err := s.resolveDefinitionID(ctx, input)
 		if err != nil {
-			return nil, err
+			return err
 		}
 
-		if realization.Status != domain.StatusPlanned {
-			return nil, fmt.Errorf("cannot start activity: current status is %s", realization.Status)
-		}
-	} else {
+		var planned domain.RealizationEvent
+		realization, planned = newRealization(defID, input, now)
+
+		_, err = s.machine.Transition(ctx, realization, domain.StatusInProgress, now, func(ctx context.Context, started domain.RealizationEvent) error {
+			return s.repo.CreateRealization(ctx, realization, planned, started)
+		})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return realization, nil
+}
+
+func (s *activityService) PlanActivity(ctx context.Context, input domain.StartActivityInput) (*domain.ActivityRealization, error) {
+	now, err := s.now(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var activityRealization *domain.ActivityRealization
+	err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
 		defID, err := s.resolveDefinitionID(ctx, input)
 		if err != nil {
-			return nil, err
+			return err
 		}
 
-		realization = &domain.ActivityRealization{
-			ID:            uuid.New(),
-			DefinitionID:  defID,
-			EntityID:      input.EntityID,
-			CaregiversIDs: input.CaregiversIDs,
-			Status:        domain.StatusPlanned,
-		}
+		var planned domain.RealizationEvent
+		activityRealization, planned = newRealization(defID, input, now)
+		return s.repo.CreateRealization(ctx, activityRealization, planned)
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	active, err := s.repo.GetActiveByEntity(ctx, input.EntityID)
+	return activityRealization, nil
+}
+
+func (s *activityService) CompleteActivity(ctx context.Context, id uuid.UUID, expectedVersion int) (*domain.ActivityRealization, error) {
+	return s.transition(ctx, id, expectedVersion, domain.StatusCompleted)
+}
+
+func (s *activityService) PauseActivity(ctx context.Context, id uuid.UUID, expectedVersion int) (*domain.ActivityRealization, error) {
+	return s.transition(ctx, id, expectedVersion, domain.StatusPaused)
+}
+
+func (s *activityService) ResumeActivity(ctx context.Context, id uuid.UUID, expectedVersion int) (*domain.ActivityRealization, error) {
+	return s.transition(ctx, id, expectedVersion, domain.StatusInProgress)
+}
+
+func (s *activityService) CancelActivity(ctx context.Context, id uuid.UUID, expectedVersion int) (*domain.ActivityRealization, error) {
+	return s.transition(ctx, id, expectedVersion, domain.StatusCancelled)
+}
+
+func (s *activityService) EditActivity(ctx context.Context, id uuid.UUID, expectedVersion int, input domain.EditActivityInput) (*domain.ActivityRealization, error) {
+	var activityRealization *domain.ActivityRealization
+	err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		var err error
+		activityRealization, err = s.editActivity(ctx, id, expectedVersion, input)
+		return err
+	})
 	if err != nil {
-		return nil, fmt.Errorf("failed to check child status: %w", err)
+		return nil, err
 	}
-	if active != nil {
-		return nil, domain.ErrEntityBusy
+	return activityRealization, nil
+}
+
+func (s *activityService) editActivity(ctx context.Context, id uuid.UUID, expectedVersion int, input domain.EditActivityInput) (*domain.ActivityRealization, error) {
+	now, err := s.now(ctx)
+	if err != nil {
+		return nil, err
 	}
 
-	now := time.Now()
-	realization.Status = domain.StatusInProgress
-	realization.StartedAt = &now
+	activityRealization, err := s.loadForUpdate(ctx, id, expectedVersion)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.ensureInOrder(ctx, activityRealization, now); err != nil {
+		return nil, err
+	}
 
-	if input.RealizationID != uuid.Nil {
-		err = s.repo.UpdateRealization(ctx, realization)
-	} else {
-		err = s.repo.CreateRealization(ctx, realization)
+	startedAt, finishedAt := activityRealization.StartedAt, activityRealization.FinishedAt
+	if input.StartedAt != nil {
+		startedAt = input.StartedAt
 	}
-	if err != nil {
+	if input.FinishedAt != nil {
+		finishedAt = input.FinishedAt
+	}
+	if startedAt != nil && finishedAt != nil && finishedAt.Before(*startedAt) {
+		return nil, &domain.ValidationError{Field: "finished_at", Reason: "must not be before started_at"}
+	}
+	// Edited times come from the client too, so they are held to the same
+	// window as its queued actions.
+	if input.StartedAt != nil {
+		if err := s.ensureInWindow("started_at", *input.StartedAt); err != nil {
+			return nil, err
+		}
+	}
+	if input.FinishedAt != nil {
+		if err := s.ensureInWindow("finished_at", *input.FinishedAt); err != nil {
+			return nil, err
+		}
+	}
+
+	event := record(activityRealization, domain.EventEdited, now, domain.EventData{
+		DefinitionID:  input.DefinitionID,
+		CaregiversIDs: input.CaregiversIDs,
+		StartedAt:     input.StartedAt,
+		FinishedAt:    input.FinishedAt,
+	})
+	if err := s.repo.UpdateRealization(ctx, activityRealization, event); err != nil {
 		return nil, err
 	}
-	return realization, nil
+	return activityRealization, nil
 }
 
-func (s *activityService) PlanActivity(ctx context.Context, input domain.StartActivityInput) (*domain.ActivityRealization, error) {
-	defID, err := s.resolveDefinitionID(ctx, input)
+// maxNoteLength bounds notes, counted in characters.
+const maxNoteLength = 2000
+
+func (s *activityService) AddNote(ctx context.Context, id uuid.UUID, expectedVersion int, text string) (*domain.ActivityRealization, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil, &domain.ValidationError{Field: "text", Reason: "must not be empty"}
+	}
+	if utf8.RuneCountInString(text) > maxNoteLength {
+		return nil, &domain.ValidationError{Field: "text", Reason: fmt.Sprintf("must be at most %d characters", maxNoteLength)}
+	}
+
+	now, err := s.now(ctx)
 	if err != nil {
 		return nil, err
 	}
 
-	activityRealization := &domain.ActivityRealization{
-		DefinitionID:  defID,
-		EntityID:      input.EntityID,
-		CaregiversIDs: input.CaregiversIDs,
-		Status:        domain.StatusPlanned,
+	var activityRealization *domain.ActivityRealization
+	err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		var err error
+		activityRealization, err = s.loadForUpdate(ctx, id, expectedVersion)
+		if err != nil {
+			return err
+		}
+		if err := s.ensureInOrder(ctx, activityRealization, now); err != nil {
+			return err
+		}
+
+		event := record(activityRealization, domain.EventNoted, now, domain.EventData{Note: text})
+		return s.repo.UpdateRealization(ctx, activityRealization, event)
+	})
+	if err != nil {
+		return nil, err
 	}
+	return activityRealization, nil
+}
 
-	if err := s.repo.CreateRealization(ctx, activityRealization); err != nil {
+func (s *activityService) GetActivity(ctx context.Context, id uuid.UUID) (*domain.ActivityRealization, error) {
+	return s.repo.GetRealizationByID(ctx, id)
+}
+
+func (s *activityService) GetActivityAt(ctx context.Context, id uuid.UUID, at time.Time) (*domain.ActivityRealization, error) {
+	events, err := s.repo.ListEvents(ctx, id)
+	if err != nil {
 		return nil, err
 	}
 
+	activityRealization := domain.ProjectAt(events, at)
+	if activityRealization == nil {
+		return nil, &domain.NotFoundError{Resource: "activity realization", ID: id}
+	}
+	return activityRealization, nil
+}
+
+func (s *activityService) GetActivityHistory(ctx context.Context, id uuid.UUID) ([]domain.RealizationEvent, error) {
+	return s.repo.ListEvents(ctx, id)
+}
+
+// RebuildActivity replays the realization's event stream and overwrites the
+// stored projection with the result.
+func (s *activityService) RebuildActivity(ctx context.Context, id uuid.UUID) (*domain.ActivityRealization, error) {
+	var activityRealization *domain.ActivityRealization
+	err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		events, err := s.repo.ListEvents(ctx, id)
+		if err != nil {
+			return err
+		}
+
+		activityRealization = domain.Project(events)
+		if activityRealization == nil {
+			return &domain.NotFoundError{Resource: "activity realization", ID: id}
+		}
+		return s.repo.ReplaceRealization(ctx, activityRealization)
+	})
+	if err != nil {
+		return nil, err
+	}
 	return activityRealization, nil
 }
 
-func (s *activityService) CompleteActivity(ctx context.Context, id uuid.UUID) error {
+// now returns the time changes made with ctx happened: the client's time
+// when the action was queued offline, or the server's own clock.
+func (s *activityService) now(ctx context.Context) (time.Time, error) {
+	at, ok := domain.ClientTime(ctx)
+	if !ok {
+		return time.Now(), nil
+	}
+	if err := s.ensureInWindow("client_timestamp", at); err != nil {
+		return time.Time{}, err
+	}
+	return at, nil
+}
+
+// ensureInWindow rejects a time the client sent for field if it lies outside
+// the service's ClientTimeWindow.
+func (s *activityService) ensureInWindow(field string, at time.Time) error {
+	now := time.Now()
+	if at.Before(now.Add(-s.window.MaxAge)) {
+		return &domain.ValidationError{Field: field, Reason: fmt.Sprintf("must be less than %s old", s.window.MaxAge)}
+	}
+	if at.After(now.Add(s.window.MaxAhead)) {
+		return &domain.ValidationError{Field: field, Reason: "must not be in the future"}
+	}
+	return nil
+}
+
+// loadForUpdate fetches a realization that is about to be changed, rejecting
+// the change early if the caller's view of it is already outdated.
+func (s *activityService) loadForUpdate(ctx context.Context, id uuid.UUID, expectedVersion int) (*domain.ActivityRealization, error) {
 	activityRealization, err := s.repo.GetRealizationByID(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	if expectedVersion != 0 && activityRealization.Version != expectedVersion {
+		return nil, &domain.StaleVersionError{Current: activityRealization}
+	}
+	return activityRealization, nil
+}
+
+// ensureInOrder rejects a client time earlier than the realization's last
+// event, so that a queued action cannot rewrite what happened after it was
+// taken. The server's own clock is trusted.
+func (s *activityService) ensureInOrder(ctx context.Context, ar *domain.ActivityRealization, at time.Time) error {
+	if _, ok := domain.ClientTime(ctx); !ok {
+		return nil
+	}
+
+	events, err := s.repo.ListEvents(ctx, ar.ID)
 	if err != nil {
 		return err
 	}
+	for _, e := range events {
+		if at.Before(e.OccurredAt) {
+			return &domain.ValidationError{Field: "client_timestamp", Reason: "must not be before the activity's last change"}
+		}
+	}
+	return nil
+}
 
-	if activityRealization.Status != domain.StatusInProgress {
-		return fmt.Errorf("Cannot complete activity: current status is %s", activityRealization.Status)
+// transition loads a realization and moves it to the given status through the
+// state machine, saving the resulting event in the same unit of work. When
+// from is given, the realization must be in one of those statuses as well.
+func (s *activityService) transition(ctx context.Context, id uuid.UUID, expectedVersion int, to domain.ActivityStatus, from ...domain.ActivityStatus) (*domain.ActivityRealization, error) {
+	now, err := s.now(ctx)
+	if err != nil {
+		return nil, err
 	}
 
-	now := time.Now()
-	activityRealization.Status = domain.StatusCompleted
-	activityRealization.FinishedAt = &now
+	var activityRealization *domain.ActivityRealization
+	err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		var err error
+		activityRealization, err = s.loadForUpdate(ctx, id, expectedVersion)
+		if err != nil {
+			return err
+		}
+		if len(from) > 0 && !slices.Contains(from, activityRealization.Status) {
+			return &domain.InvalidTransitionError{From: activityRealization.Status, To: to}
+		}
+		if err := s.ensureInOrder(ctx, activityRealization, now); err != nil {
+			return err
+		}
+
+		// A queued action may reach the server after its realization was
+		// started by someone else, later than the client thought.
+		finishing := to == domain.StatusCompleted || to == domain.StatusCancelled
+		if finishing && activityRealization.StartedAt != nil && now.Before(*activityRealization.StartedAt) {
+			return &domain.ValidationError{Field: "client_timestamp", Reason: "must not be before the activity started"}
+		}
 
-	return s.repo.UpdateRealization(ctx, activityRealization)
+		_, err = s.machine.Transition(ctx, activityRealization, to, now, func(ctx context.Context, event domain.RealizationEvent) error {
+			return s.repo.UpdateRealization(ctx, activityRealization, event)
+		})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return activityRealization, nil
+}
+
+// ensureEntityFree keeps a child from taking part in two activities at once.
+// The repositories enforce the same rule atomically; checking here first gives
+// a clean error without a failed write.
+func (s *activityService) ensureEntityFree(ctx context.Context, change domain.StateChange) error {
+	if change.To != domain.StatusInProgress {
+		return nil
+	}
+
+	active, err := s.repo.GetActiveByEntity(ctx, change.Realization.EntityID)
+	if err != nil {
+		return fmt.Errorf("failed to check child status: %w", err)
+	}
+	if active != nil && active.ID != change.Realization.ID {
+		return domain.ErrEntityBusy
+	}
+	return nil
 }
 
 func (s *activityService) resolveDefinitionID(ctx context.Context, input domain.StartActivityInput) (uuid.UUID, error) {
@@ -115,7 +387,10 @@ func (s *activityService) resolveDefinitionID(ctx context.Context, input domain.
 	}
 
 	if input.NewDefinittionName == "" {
-		return uuid.Nil, fmt.Errorf("either definition_id or new_definition_name must be provided")
+		return uuid.Nil, &domain.ValidationError{
+			Field:  "definition_id",
+			Reason: "either definition_id or new_definition_name must be provided",
+		}
 	}
 
 	def, err := s.defRepo.GetOrCreateByName(ctx, input.NewDefinittionName)
@@ -124,3 +399,31 @@ func (s *activityService) resolveDefinitionID(ctx context.Context, input domain.
 	}
 	return def.ID, nil
 }
+
+// newRealization creates a planned realization along with the event that
+// records its creation.
+func newRealization(defID uuid.UUID, input domain.StartActivityInput, at time.Time) (*domain.ActivityRealization, domain.RealizationEvent) {
+	activityRealization := &domain.ActivityRealization{ID: uuid.New()}
+	planned := record(activityRealization, domain.EventPlanned, at, domain.EventData{
+		DefinitionID:  &defID,
+		EntityID:      &input.EntityID,
+		CaregiversIDs: input.CaregiversIDs,
+		PlannedFor:    input.PlannedFor,
+	})
+	return activityRealization, planned
+}
+
+// record builds an event that does not change the status, such as creation or
+// an edit, and applies it to the realization. Status changes go through the
+// state machine instead.
+func record(ar *domain.ActivityRealization, eventType domain.EventType, at time.Time, data domain.EventData) domain.RealizationEvent {
+	event := domain.RealizationEvent{
+		RealizationID: ar.ID,
+		FamilyID:      ar.FamilyID,
+		Type:          eventType,
+		OccurredAt:    at,
+		Data:          data,
+	}
+	ar.Apply(event)
+	return event
+}
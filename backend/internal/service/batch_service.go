@@ -0,0 +1,157 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/google/uuid"
+	"github.com/luisteixeira/waypoint/backend/internal/domain"
+)
+
+// MaxBatchOperations bounds the size of a batch so one request cannot hold a
+// transaction open for long.
+const MaxBatchOperations = 100
+
+// errBatchAborted rolls back an atomic batch after one of its operations
+// failed. The failure itself is reported in the operation's result.
+var errBatchAborted = errors.New("batch aborted")
+
+type batchService struct {
+	activities domain.ActivityService
+	tx         TxManager
+}
+
+// NewBatchService applies batches through activities, so every operation
+// goes through the same rules and instrumentation as a single request.
+func NewBatchService(activities domain.ActivityService, tx TxManager) *batchService {
+	return &batchService{activities: activities, tx: tx}
+}
+
+func (s *batchService) ApplyBatch(ctx context.Context, ops []domain.BatchOperation, atomic bool) ([]domain.BatchResult, error) {
+	if err := validateBatch(ops); err != nil {
+		return nil, err
+	}
+
+	results := make([]domain.BatchResult, len(ops))
+	for i, op := range ops {
+		results[i] = domain.BatchResult{ID: op.ID, Type: op.Type, ClientTimestamp: op.ClientTimestamp, Status: domain.BatchSkipped}
+	}
+
+	if !atomic {
+		s.apply(ctx, ops, results, false)
+		return results, nil
+	}
+
+	err := s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		return s.apply(ctx, ops, results, true)
+	})
+	if errors.Is(err, errBatchAborted) {
+		for i := range results {
+			if results[i].Status == domain.BatchApplied {
+				results[i].Status = domain.BatchRolledBack
+				results[i].Realization = nil
+			}
+		}
+		return results, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return results, nil
+}
+
+// apply runs the operations in order, recording each outcome in results. When
+// stopOnFailure is set it returns errBatchAborted at the first failure.
+func (s *batchService) apply(ctx context.Context, ops []domain.BatchOperation, results []domain.BatchResult, stopOnFailure bool) error {
+	started := make(map[string]uuid.UUID)
+	for i, op := range ops {
+		ar, err := s.applyOne(ctx, op, started)
+		if err != nil {
+			results[i].Status = domain.BatchFailed
+			results[i].Err = err
+			if stopOnFailure {
+				return errBatchAborted
+			}
+			continue
+		}
+
+		results[i].Status = domain.BatchApplied
+		results[i].Realization = ar
+		if op.Type == domain.BatchStart {
+			started[op.ID] = ar.ID
+		}
+	}
+	return nil
+}
+
+// applyOne applies a single operation as of its client timestamp, so the
+// activity records when the action was taken rather than when it was synced.
+func (s *batchService) applyOne(ctx context.Context, op domain.BatchOperation, started map[string]uuid.UUID) (*domain.ActivityRealization, error) {
+	ctx = domain.WithClientTime(ctx, op.ClientTimestamp)
+	if op.Type == domain.BatchStart {
+		return s.activities.StartActivity(ctx, op.Start)
+	}
+
+	realizationID := op.RealizationID
+	if op.Ref != "" {
+		id, ok := started[op.Ref]
+		if !ok {
+			return nil, &domain.ValidationError{Field: "ref", Reason: fmt.Sprintf("operation %q was not applied", op.Ref)}
+		}
+		realizationID = id
+	}
+
+	switch op.Type {
+	case domain.BatchComplete:
+		return s.activities.CompleteActivity(ctx, realizationID, op.ExpectedVersion)
+	case domain.BatchNote:
+		return s.activities.AddNote(ctx, realizationID, op.ExpectedVersion, op.Note)
+	default:
+		return nil, &domain.ValidationError{Field: "type", Reason: fmt.Sprintf("unknown operation %q", op.Type)}
+	}
+}
+
+// validateBatch rejects a malformed batch as a whole, before anything is
+// applied.
+func validateBatch(ops []domain.BatchOperation) error {
+	if len(ops) == 0 {
+		return &domain.ValidationError{Field: "operations", Reason: "must not be empty"}
+	}
+	if len(ops) > MaxBatchOperations {
+		return &domain.ValidationError{Field: "operations", Reason: fmt.Sprintf("must hold at most %d operations", MaxBatchOperations)}
+	}
+
+	types := make(map[string]domain.BatchOperationType, len(ops))
+	for i, op := range ops {
+		field := func(name string) string { return fmt.Sprintf("operations.%d.%s", i, name) }
+
+		if op.ID == "" {
+			return &domain.ValidationError{Field: field("id"), Reason: "must not be empty"}
+		}
+		if _, seen := types[op.ID]; seen {
+			return &domain.ValidationError{Field: field("id"), Reason: fmt.Sprintf("%q is used by another operation", op.ID)}
+		}
+		if op.ClientTimestamp.IsZero() {
+			return &domain.ValidationError{Field: field("client_timestamp"), Reason: "must be set"}
+		}
+
+		switch op.Type {
+		case domain.BatchStart:
+			if op.Start.EntityID == uuid.Nil && op.Start.RealizationID == uuid.Nil {
+				return &domain.ValidationError{Field: field("entity_id"), Reason: "must be set"}
+			}
+		case domain.BatchComplete, domain.BatchNote:
+			if (op.RealizationID == uuid.Nil) == (op.Ref == "") {
+				return &domain.ValidationError{Field: field("realization_id"), Reason: "exactly one of realization_id or ref must be set"}
+			}
+			if op.Ref != "" && types[op.Ref] != domain.BatchStart {
+				return &domain.ValidationError{Field: field("ref"), Reason: "must name an earlier start operation"}
+			}
+		default:
+			return &domain.ValidationError{Field: field("type"), Reason: "must be start, complete or note"}
+		}
+		types[op.ID] = op.Type
+	}
+	return nil
+}
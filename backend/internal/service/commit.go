@@ -0,0 +1,33 @@
+package service
+
+import "context"
+
+type commitHooksKey struct{}
+
+type commitHooks struct {
+	fns []func()
+}
+
+// CommitHooks prepares ctx for a new unit of work so that AfterCommit can
+// defer work until it commits. TxManagers call it when they begin a unit of
+// work and call the returned function once it has committed; a unit of work
+// that rolls back never runs its hooks.
+func CommitHooks(ctx context.Context) (context.Context, func()) {
+	hooks := &commitHooks{}
+	return context.WithValue(ctx, commitHooksKey{}, hooks), func() {
+		for _, fn := range hooks.fns {
+			fn()
+		}
+	}
+}
+
+// AfterCommit runs fn once the unit of work carried by ctx commits, or at
+// once when there is none. It suits side effects that must not outlive a
+// rollback, such as counting what was saved.
+func AfterCommit(ctx context.Context, fn func()) {
+	if hooks, ok := ctx.Value(commitHooksKey{}).(*commitHooks); ok {
+		hooks.fns = append(hooks.fns, fn)
+		return
+	}
+	fn()
+}
@@ -7,14 +7,50 @@ import (
 	"github.com/luisteixeira/waypoint/backend/internal/domain"
 )
 
+// ActivityRepository stores realizations as an append-only event stream. The
+// events passed to CreateRealization and UpdateRealization are appended to the
+// stream and the realization is stored as their projection, atomically.
+//
+// UpdateRealization only succeeds if the stored version still matches the
+// realization's Version, which it then increments. A missing realization
+// yields domain.ErrNotFound and an outdated one a *domain.StaleVersionError.
 type ActivityRepository interface {
-	CreateRealization(ctx context.Context, activityRealization *domain.ActivityRealization) error
+	CreateRealization(ctx context.Context, activityRealization *domain.ActivityRealization, events ...domain.RealizationEvent) error
 	GetRealizationByID(ctx context.Context, id uuid.UUID) (*domain.ActivityRealization, error)
 	GetActiveByEntity(ctx context.Context, entityID uuid.UUID) (*domain.ActivityRealization, error)
-	UpdateRealization(ctx context.Context, activityRealization *domain.ActivityRealization) error
+	// ListRealizations returns the family's realizations matching filter,
+	// most recently started first and unstarted ones last.
+	ListRealizations(ctx context.Context, filter domain.RealizationFilter) ([]domain.ActivityRealization, error)
+	UpdateRealization(ctx context.Context, activityRealization *domain.ActivityRealization, events ...domain.RealizationEvent) error
+	ListEvents(ctx context.Context, realizationID uuid.UUID) ([]domain.RealizationEvent, error)
+	ReplaceRealization(ctx context.Context, activityRealization *domain.ActivityRealization) error
 }
 
 type DefinitionRepository interface {
 	GetOrCreateByName(ctx context.Context, name string) (*domain.ActivityDefinition, error)
 	ListByFamily(ctx context.Context) ([]domain.ActivityDefinition, error)
 }
+
+// EntityRepository reads the children activities are recorded for.
+type EntityRepository interface {
+	ListByFamily(ctx context.Context) ([]domain.Entity, error)
+}
+
+// ChangeRepository reads the change feed behind delta sync. Sequences only
+// grow, and a change never becomes visible after one with a higher sequence
+// in the same family, so a reader that resumes after the last sequence it saw
+// misses nothing.
+type ChangeRepository interface {
+	// ListChanges returns up to limit of the family's changes after the given
+	// sequence, oldest first, with the current state of each object.
+	ListChanges(ctx context.Context, after int64, limit int) ([]domain.Change, error)
+	// LastSequence returns the highest sequence issued, in any family.
+	LastSequence(ctx context.Context) (int64, error)
+}
+
+// TxManager runs several repository calls as one unit of work: either all of
+// their changes take effect or none do. Repositories pick the transaction up
+// from the context passed to fn, so fn must use that context.
+type TxManager interface {
+	WithinTx(ctx context.Context, fn func(ctx context.Context) error) error
+}
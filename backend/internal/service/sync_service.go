@@ -0,0 +1,110 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+
+	"github.com/luisteixeira/waypoint/backend/internal/domain"
+)
+
+const (
+	// DefaultSyncLimit is how many changes a page holds when the client does
+	// not say.
+	DefaultSyncLimit = 500
+	// MaxSyncLimit bounds the page size a client can ask for.
+	MaxSyncLimit = 1000
+)
+
+type syncService struct {
+	changes ChangeRepository
+	tx      TxManager
+}
+
+// NewSyncService serves delta sync from the change feed in changes.
+func NewSyncService(changes ChangeRepository, tx TxManager) *syncService {
+	return &syncService{changes: changes, tx: tx}
+}
+
+// Changes returns up to limit changes after cursor. The cursor is the last
+// change sequence the client has seen; clients should treat it as opaque.
+// Tombstones are left out of a sync from scratch, since the client has
+// nothing to delete.
+func (s *syncService) Changes(ctx context.Context, cursor string, limit int) (*domain.SyncPage, error) {
+	after, err := parseCursor(cursor)
+	if err != nil {
+		return nil, err
+	}
+	if limit == 0 {
+		limit = DefaultSyncLimit
+	}
+	if limit < 0 || limit > MaxSyncLimit {
+		return nil, &domain.ValidationError{Field: "limit", Reason: fmt.Sprintf("must be between 1 and %d", MaxSyncLimit)}
+	}
+
+	page := &domain.SyncPage{
+		Realizations: []domain.ActivityRealization{},
+		Definitions:  []domain.ActivityDefinition{},
+		Entities:     []domain.Entity{},
+		Deleted:      []domain.Tombstone{},
+	}
+
+	// Reading in a unit of work keeps the memory backend from returning
+	// changes that are not committed yet.
+	err = s.tx.WithinTx(ctx, func(ctx context.Context) error {
+		if after > 0 {
+			last, err := s.changes.LastSequence(ctx)
+			if err != nil {
+				return err
+			}
+			if after > last {
+				page.Reset = true
+				after = 0
+			}
+		}
+
+		changes, err := s.changes.ListChanges(ctx, after, limit+1)
+		if err != nil {
+			return err
+		}
+		if len(changes) > limit {
+			page.HasMore = true
+			changes = changes[:limit]
+		}
+
+		for _, c := range changes {
+			switch {
+			case c.Deleted:
+				if after > 0 {
+					page.Deleted = append(page.Deleted, domain.Tombstone{Kind: c.Kind, ID: c.ID})
+				}
+			case c.Realization != nil:
+				page.Realizations = append(page.Realizations, *c.Realization)
+			case c.Definition != nil:
+				page.Definitions = append(page.Definitions, *c.Definition)
+			case c.Entity != nil:
+				page.Entities = append(page.Entities, *c.Entity)
+			}
+		}
+		if len(changes) > 0 {
+			after = changes[len(changes)-1].Sequence
+		}
+		page.Cursor = strconv.FormatInt(after, 10)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return page, nil
+}
+
+func parseCursor(cursor string) (int64, error) {
+	if cursor == "" {
+		return 0, nil
+	}
+	after, err := strconv.ParseInt(cursor, 10, 64)
+	if err != nil || after < 0 {
+		return 0, &domain.ValidationError{Field: "cursor", Reason: "must be a cursor returned by the sync endpoint"}
+	}
+	return after, nil
+}
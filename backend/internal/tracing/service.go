@@ -0,0 +1,120 @@
+package tracing
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/luisteixeira/waypoint/backend/internal/domain"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracedService wraps each activity service call in a span carrying the
+// family and realization it concerns.
+type tracedService struct {
+	next   domain.ActivityService
+	tracer trace.Tracer
+}
+
+func NewActivityService(next domain.ActivityService) *tracedService {
+	return &tracedService{next: next, tracer: otel.Tracer(instrumentation)}
+}
+
+func (s *tracedService) StartActivity(ctx context.Context, input domain.StartActivityInput) (*domain.ActivityRealization, error) {
+	ctx, span := s.start(ctx, "StartActivity", input.RealizationID)
+	ar, err := s.next.StartActivity(ctx, input)
+	return ar, s.end(span, ar, err)
+}
+
+func (s *tracedService) PlanActivity(ctx context.Context, input domain.StartActivityInput) (*domain.ActivityRealization, error) {
+	ctx, span := s.start(ctx, "PlanActivity", input.RealizationID)
+	ar, err := s.next.PlanActivity(ctx, input)
+	return ar, s.end(span, ar, err)
+}
+
+func (s *tracedService) CompleteActivity(ctx context.Context, realizationID uuid.UUID, expectedVersion int) (*domain.ActivityRealization, error) {
+	ctx, span := s.start(ctx, "CompleteActivity", realizationID)
+	ar, err := s.next.CompleteActivity(ctx, realizationID, expectedVersion)
+	return ar, s.end(span, ar, err)
+}
+
+func (s *tracedService) PauseActivity(ctx context.Context, realizationID uuid.UUID, expectedVersion int) (*domain.ActivityRealization, error) {
+	ctx, span := s.start(ctx, "PauseActivity", realizationID)
+	ar, err := s.next.PauseActivity(ctx, realizationID, expectedVersion)
+	return ar, s.end(span, ar, err)
+}
+
+func (s *tracedService) ResumeActivity(ctx context.Context, realizationID uuid.UUID, expectedVersion int) (*domain.ActivityRealization, error) {
+	ctx, span := s.start(ctx, "ResumeActivity", realizationID)
+	ar, err := s.next.ResumeActivity(ctx, realizationID, expectedVersion)
+	return ar, s.end(span, ar, err)
+}
+
+func (s *tracedService) CancelActivity(ctx context.Context, realizationID uuid.UUID, expectedVersion int) (*domain.ActivityRealization, error) {
+	ctx, span := s.start(ctx, "CancelActivity", realizationID)
+	ar, err := s.next.CancelActivity(ctx, realizationID, expectedVersion)
+	return ar, s.end(span, ar, err)
+}
+
+func (s *tracedService) EditActivity(ctx context.Context, realizationID uuid.UUID, expectedVersion int, input domain.EditActivityInput) (*domain.ActivityRealization, error) {
+	ctx, span := s.start(ctx, "EditActivity", realizationID)
+	ar, err := s.next.EditActivity(ctx, realizationID, expectedVersion, input)
+	return ar, s.end(span, ar, err)
+}
+
+func (s *tracedService) AddNote(ctx context.Context, realizationID uuid.UUID, expectedVersion int, text string) (*domain.ActivityRealization, error) {
+	ctx, span := s.start(ctx, "AddNote", realizationID)
+	ar, err := s.next.AddNote(ctx, realizationID, expectedVersion, text)
+	return ar, s.end(span, ar, err)
+}
+
+func (s *tracedService) GetActivity(ctx context.Context, realizationID uuid.UUID) (*domain.ActivityRealization, error) {
+	ctx, span := s.start(ctx, "GetActivity", realizationID)
+	ar, err := s.next.GetActivity(ctx, realizationID)
+	return ar, s.end(span, ar, err)
+}
+
+func (s *tracedService) GetActivityAt(ctx context.Context, realizationID uuid.UUID, at time.Time) (*domain.ActivityRealization, error) {
+	ctx, span := s.start(ctx, "GetActivityAt", realizationID)
+	ar, err := s.next.GetActivityAt(ctx, realizationID, at)
+	return ar, s.end(span, ar, err)
+}
+
+func (s *tracedService) GetActivityHistory(ctx context.Context, realizationID uuid.UUID) ([]domain.RealizationEvent, error) {
+	ctx, span := s.start(ctx, "GetActivityHistory", realizationID)
+	events, err := s.next.GetActivityHistory(ctx, realizationID)
+	return events, s.end(span, nil, err)
+}
+
+func (s *tracedService) RebuildActivity(ctx context.Context, realizationID uuid.UUID) (*domain.ActivityRealization, error) {
+	ctx, span := s.start(ctx, "RebuildActivity", realizationID)
+	ar, err := s.next.RebuildActivity(ctx, realizationID)
+	return ar, s.end(span, ar, err)
+}
+
+func (s *tracedService) start(ctx context.Context, operation string, realizationID uuid.UUID) (context.Context, trace.Span) {
+	ctx, span := s.tracer.Start(ctx, "activityService."+operation)
+	if family, ok := FamilyID(ctx); ok {
+		span.SetAttributes(family)
+	}
+	if realizationID != uuid.Nil {
+		span.SetAttributes(RealizationIDKey.String(realizationID.String()))
+	}
+	return ctx, span
+}
+
+// end records the outcome on the span and passes err through. Starts learn
+// their realization ID from the result.
+func (s *tracedService) end(span trace.Span, ar *domain.ActivityRealization, err error) error {
+	defer span.End()
+	if ar != nil {
+		span.SetAttributes(RealizationIDKey.String(ar.ID.String()))
+	}
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	return err
+}
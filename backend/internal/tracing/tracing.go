@@ -0,0 +1,87 @@
+// Package tracing sets up OpenTelemetry and provides the spans for HTTP
+// requests and the activity service. Query spans are started by the
+// repository package, which every SQL query passes through.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/google/uuid"
+	"github.com/luisteixeira/waypoint/backend/internal/config"
+	"github.com/luisteixeira/waypoint/backend/internal/middleware"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+)
+
+const instrumentation = "github.com/luisteixeira/waypoint/backend"
+
+// Attribute keys shared by every span that knows about a family or a
+// realization.
+const (
+	FamilyIDKey      = attribute.Key("waypoint.family_id")
+	RealizationIDKey = attribute.Key("waypoint.realization_id")
+)
+
+// Setup installs the global tracer provider and W3C propagators for the
+// configured exporter. The returned function flushes pending spans and must
+// be called on shutdown. With the none exporter tracing stays a no-op.
+func Setup(ctx context.Context, cfg config.TracingConfig) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch cfg.Exporter {
+	case "none":
+		return func(context.Context) error { return nil }, nil
+	case "stdout":
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	case "otlp":
+		var opts []otlptracehttp.Option
+		if cfg.Endpoint != "" {
+			opts = append(opts, otlptracehttp.WithEndpoint(cfg.Endpoint))
+		}
+		if cfg.Insecure {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+		exporter, err = otlptracehttp.New(ctx, opts...)
+	default:
+		return nil, fmt.Errorf("unknown tracing exporter %q", cfg.Exporter)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s exporter: %w", cfg.Exporter, err)
+	}
+
+	res, err := resource.New(ctx,
+		resource.WithAttributes(semconv.ServiceName(cfg.ServiceName)),
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to describe tracing resource: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// FamilyID returns the family attribute for the tenant carried by ctx.
+func FamilyID(ctx context.Context) (attribute.KeyValue, bool) {
+	familyID, ok := ctx.Value(middleware.FamilyIDKey).(uuid.UUID)
+	if !ok {
+		return attribute.KeyValue{}, false
+	}
+	return FamilyIDKey.String(familyID.String()), true
+}
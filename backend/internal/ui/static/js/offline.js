@@ -0,0 +1,72 @@
+// Registers the service worker and keeps the caregiver informed about actions
+// made offline: how many are waiting, and when they have been synced.
+(function () {
+  if (!('serviceWorker' in navigator)) {
+    return;
+  }
+
+  navigator.serviceWorker.register('/sw.js', { scope: '/' });
+
+  function banner() {
+    return document.getElementById('sync-status');
+  }
+
+  function show(text) {
+    const el = banner();
+    if (!el) {
+      return;
+    }
+    el.textContent = text;
+    el.hidden = text === '';
+  }
+
+  let pending = 0;
+
+  function render() {
+    if (!navigator.onLine) {
+      show(pending > 0
+        ? `Offline. ${pending} ${pending === 1 ? 'action' : 'actions'} will sync when you are back online.`
+        : 'Offline. Actions you take will sync when you are back online.');
+    } else if (pending > 0) {
+      show(`Syncing ${pending} ${pending === 1 ? 'action' : 'actions'}...`);
+    } else {
+      show('');
+    }
+  }
+
+  function post(message) {
+    navigator.serviceWorker.ready.then((registration) => {
+      if (registration.active) {
+        registration.active.postMessage(message);
+      }
+    });
+  }
+
+  navigator.serviceWorker.addEventListener('message', (event) => {
+    const message = event.data || {};
+    if (typeof message.pending === 'number') {
+      pending = message.pending;
+    }
+    render();
+
+    if (message.type === 'synced') {
+      if (message.failed && message.failed.length > 0) {
+        show(`${message.failed.length} offline ${message.failed.length === 1 ? 'action' : 'actions'} could not be synced: ${message.failed[0].detail}`);
+      }
+      document.body.dispatchEvent(new CustomEvent('waypoint:synced', { detail: message }));
+    }
+  });
+
+  // Browsers without Background Sync rely on the page to say when the
+  // connection is back.
+  window.addEventListener('online', () => {
+    render();
+    post({ type: 'flush' });
+  });
+  window.addEventListener('offline', render);
+
+  document.addEventListener('DOMContentLoaded', () => {
+    render();
+    post({ type: navigator.onLine ? 'flush' : 'status' });
+  });
+})();
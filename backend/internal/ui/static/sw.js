@@ -0,0 +1,445 @@
+// Service worker for the Waypoint dashboard. It keeps the page shell available
+// without a network and queues start and complete actions made offline,
+// replaying them through POST /api/v1/batch, stamped with the time they were
+// made, once the connection returns. It also shows the Web Push messages the
+// server sends caregivers who subscribed through /api/v1/push/subscriptions.
+//
+// It is served from /sw.js rather than /static/ so that it controls the
+// whole site.
+
+const CACHE = 'waypoint-shell-v1';
+const SHELL = [
+  '/',
+  '/static/css/styles.css',
+  '/static/js/htmx.min.js',
+  '/static/js/alpine.min.js',
+  '/static/js/offline.js',
+  '/static/manifest.webmanifest',
+  '/static/icons/icon.svg',
+];
+
+const SYNC_TAG = 'waypoint-sync';
+const DB_NAME = 'waypoint';
+const QUEUE = 'queue';
+const REFS = 'refs';
+
+// Matches the server's limit on operations per batch.
+const MAX_BATCH = 100;
+// Prefix of the IDs given to activities started offline, before the server
+// has assigned them one.
+const QUEUED_PREFIX = 'queued-';
+
+const START_PATH = /^\/api\/v1\/activities\/start$/;
+const COMPLETE_PATH = /^\/api\/v1\/activities\/([^/]+)\/complete$/;
+
+self.addEventListener('install', (event) => {
+  event.waitUntil(
+    caches.open(CACHE)
+      .then((cache) => cache.addAll(SHELL))
+      .then(() => self.skipWaiting()),
+  );
+});
+
+self.addEventListener('activate', (event) => {
+  event.waitUntil((async () => {
+    const names = await caches.keys();
+    await Promise.all(names.filter((name) => name !== CACHE).map((name) => caches.delete(name)));
+    await self.clients.claim();
+    await flush().catch(() => {});
+  })());
+});
+
+self.addEventListener('fetch', (event) => {
+  const request = event.request;
+  const url = new URL(request.url);
+  if (url.origin !== self.location.origin) {
+    return;
+  }
+
+  if (request.method === 'POST' && (START_PATH.test(url.pathname) || COMPLETE_PATH.test(url.pathname))) {
+    event.respondWith(sendOrQueue(request, url));
+    return;
+  }
+  if (request.method !== 'GET' || url.pathname.startsWith('/api/')) {
+    return;
+  }
+
+  if (request.mode === 'navigate') {
+    event.respondWith(networkFirst(request));
+  } else if (url.pathname.startsWith('/static/')) {
+    event.respondWith(cacheFirst(request));
+  }
+});
+
+// Browsers with Background Sync wake the worker once they are back online.
+self.addEventListener('sync', (event) => {
+  if (event.tag === SYNC_TAG) {
+    event.waitUntil(flush());
+  }
+});
+
+// The page asks for a flush when it comes back online, for browsers without
+// Background Sync.
+self.addEventListener('message', (event) => {
+  if (event.data && event.data.type === 'flush') {
+    event.waitUntil(flush().catch(() => {}));
+  } else if (event.data && event.data.type === 'status') {
+    event.waitUntil(pendingCount().then((pending) => notify({ type: 'status', pending })));
+  }
+});
+
+// Push messages are JSON with a title, an optional body, a tag that repeats of
+// the same message share, and the URL to open when the notification is
+// clicked.
+self.addEventListener('push', (event) => {
+  let message = {};
+  try {
+    message = event.data ? event.data.json() : {};
+  } catch (err) {
+    message = { body: event.data.text() };
+  }
+  event.waitUntil(self.registration.showNotification(message.title || 'Waypoint', {
+    body: message.body || '',
+    tag: message.tag,
+    icon: '/static/icons/icon.svg',
+    data: { url: message.url || '/' },
+  }));
+});
+
+self.addEventListener('notificationclick', (event) => {
+  event.notification.close();
+  const url = new URL((event.notification.data && event.notification.data.url) || '/', self.location.origin);
+  event.waitUntil((async () => {
+    const clients = await self.clients.matchAll({ includeUncontrolled: true, type: 'window' });
+    const open = clients.find((client) => new URL(client.url).pathname === url.pathname);
+    if (open) {
+      return open.focus();
+    }
+    return self.clients.openWindow(url.href);
+  })());
+});
+
+async function networkFirst(request) {
+  try {
+    const response = await fetch(request);
+    if (response.ok) {
+      const cache = await caches.open(CACHE);
+      await cache.put('/', response.clone());
+    }
+    return response;
+  } catch (err) {
+    const cached = await caches.match('/');
+    if (cached) {
+      return cached;
+    }
+    throw err;
+  }
+}
+
+async function cacheFirst(request) {
+  const cached = await caches.match(request);
+  const refresh = fetch(request).then(async (response) => {
+    if (response.ok) {
+      const cache = await caches.open(CACHE);
+      await cache.put(request, response.clone());
+    }
+    return response;
+  });
+
+  if (cached) {
+    refresh.catch(() => {});
+    return cached;
+  }
+  return refresh;
+}
+
+// sendOrQueue passes the action to the server, or queues it when the server
+// cannot be reached. Actions on activities that were themselves started
+// offline are always queued, since the server does not know them yet.
+async function sendOrQueue(request, url) {
+  const copy = request.clone();
+  const match = url.pathname.match(COMPLETE_PATH);
+  if (!match || !match[1].startsWith(QUEUED_PREFIX)) {
+    try {
+      return await fetch(request);
+    } catch (err) {
+      // Offline: fall through and queue the action.
+    }
+  }
+
+  const entry = await toEntry(copy, url);
+  await enqueue(entry);
+  if (self.registration.sync) {
+    await self.registration.sync.register(SYNC_TAG).catch(() => {});
+  }
+  await notify({ type: 'queued', pending: await pendingCount() });
+  return queuedResponse(entry.op);
+}
+
+// toEntry turns a start or complete request into a batch operation, stamped
+// with the time the caregiver made it.
+async function toEntry(request, url) {
+  const op = {
+    id: crypto.randomUUID(),
+    client_timestamp: new Date().toISOString(),
+  };
+
+  const match = url.pathname.match(COMPLETE_PATH);
+  if (match) {
+    op.type = 'complete';
+    const id = decodeURIComponent(match[1]);
+    if (id.startsWith(QUEUED_PREFIX)) {
+      op.ref = id.slice(QUEUED_PREFIX.length);
+    } else {
+      op.realization_id = id;
+    }
+    const version = parseInt((request.headers.get('If-Match') || '').replace(/^W\//, '').replace(/"/g, ''), 10);
+    if (version > 0) {
+      op.version = version;
+    }
+  } else {
+    op.type = 'start';
+    Object.assign(op, await readActivity(request));
+  }
+
+  return { family: request.headers.get('X-Family-ID'), op };
+}
+
+async function readActivity(request) {
+  const type = request.headers.get('Content-Type') || '';
+  if (type.includes('application/json')) {
+    const body = await request.json();
+    return {
+      entity_id: body.entity_id,
+      definition_id: body.definition_id,
+      new_definition_name: body.new_definition_name,
+      caregiver_ids: body.caregiver_ids || undefined,
+    };
+  }
+
+  const form = await request.formData();
+  const activity = {
+    entity_id: form.get('entity_id') || undefined,
+    definition_id: form.get('definition_id') || undefined,
+    new_definition_name: form.get('new_definition_name') || undefined,
+  };
+  const caregivers = form.getAll('caregiver_ids');
+  if (caregivers.length > 0) {
+    activity.caregiver_ids = caregivers;
+  }
+  return activity;
+}
+
+// queuedResponse answers the page as the server would have, with a card the
+// dashboard can swap in, so the caregiver can keep going offline.
+function queuedResponse(op) {
+  let html;
+  if (op.type === 'start') {
+    const id = QUEUED_PREFIX + op.id;
+    html = `<div id="activity-${id}" class="bg-white p-4 rounded-lg shadow border-l-4 border-gray-400 flex justify-between items-center">
+    <div>
+        <h3 class="font-bold text-gray-800">${escapeHTML(op.new_definition_name || 'Activity')}</h3>
+        <p class="text-sm text-gray-500">Saved offline, syncs when back online</p>
+    </div>
+    <button hx-post="/api/v1/activities/${id}/complete"
+            hx-target="#activity-${id}"
+            hx-swap="outerHTML"
+            class="text-sm bg-gray-100 hover:bg-red-50 text-gray-600 hover:text-red-600 px-3 py-1 rounded transition">
+        Complete
+    </button>
+</div>`;
+  } else {
+    html = `<div class="bg-white p-4 rounded-lg shadow border-l-4 border-gray-400">
+    <p class="text-sm text-gray-500">Completed offline, syncs when back online</p>
+</div>`;
+  }
+
+  return new Response(html, {
+    status: 202,
+    headers: { 'Content-Type': 'text/html; charset=utf-8' },
+  });
+}
+
+function escapeHTML(value) {
+  return String(value).replace(/[&<>"']/g, (c) => ({
+    '&': '&amp;', '<': '&lt;', '>': '&gt;', '"': '&quot;', "'": '&#39;',
+  })[c]);
+}
+
+let flushing = null;
+
+// flush sends the queued actions, oldest first, one batch per family. It
+// rejects when the server cannot be reached so Background Sync retries.
+function flush() {
+  if (!flushing) {
+    flushing = doFlush().finally(() => { flushing = null; });
+  }
+  return flushing;
+}
+
+async function doFlush() {
+  const entries = await readQueue();
+  if (entries.length === 0) {
+    return;
+  }
+
+  const families = new Map();
+  for (const entry of entries) {
+    if (!families.has(entry.family)) {
+      families.set(entry.family, []);
+    }
+    families.get(entry.family).push(entry);
+  }
+
+  let applied = 0;
+  const failed = [];
+  for (const [family, queued] of families) {
+    for (let i = 0; i < queued.length; i += MAX_BATCH) {
+      const outcome = await sendBatch(family, queued.slice(i, i + MAX_BATCH));
+      applied += outcome.applied;
+      failed.push(...outcome.failed);
+    }
+  }
+
+  await notify({ type: 'synced', applied, failed, pending: await pendingCount() });
+}
+
+async function sendBatch(family, entries) {
+  const ops = [];
+  const dropped = [];
+  const inBatch = new Set(entries.map((e) => e.op.id));
+  for (const entry of entries) {
+    const op = { ...entry.op };
+    if (op.ref && !inBatch.has(op.ref)) {
+      // The activity was started in an earlier batch.
+      const realization = await lookupRef(op.ref);
+      if (!realization) {
+        dropped.push(entry);
+        continue;
+      }
+      op.realization_id = realization;
+      delete op.ref;
+    }
+    ops.push(op);
+  }
+
+  const failed = dropped.map((entry) => ({ id: entry.op.id, type: entry.op.type, detail: 'its activity could not be started' }));
+  await removeEntries(dropped);
+  if (ops.length === 0) {
+    return { applied: 0, failed };
+  }
+
+  // Retries of the same batch replay the first response instead of applying
+  // it twice.
+  const response = await fetch('/api/v1/batch', {
+    method: 'POST',
+    headers: {
+      'Content-Type': 'application/json',
+      'X-Family-ID': family,
+      'Idempotency-Key': await batchKey(ops),
+    },
+    body: JSON.stringify({ operations: ops }),
+  });
+
+  const sent = entries.filter((entry) => !dropped.includes(entry));
+  // A lapsed session (401/403) says nothing about the batch itself; keep it
+  // queued until the user signs in again.
+  if (response.status >= 500 || [401, 403, 409, 429].includes(response.status)) {
+    throw new Error(`sync failed with ${response.status}`);
+  }
+  if (!response.ok) {
+    // The server will never accept this batch; keeping it would block the
+    // queue forever.
+    const problem = await response.json().catch(() => ({}));
+    await removeEntries(sent);
+    return {
+      applied: 0,
+      failed: failed.concat(sent.map((entry) => ({ id: entry.op.id, type: entry.op.type, detail: problem.detail || response.statusText }))),
+    };
+  }
+
+  const { results } = await response.json();
+  let applied = 0;
+  for (const result of results) {
+    if (result.status === 'applied') {
+      applied++;
+      if (result.type === 'start' && result.realization) {
+        await saveRef(result.id, result.realization.id);
+      }
+    } else {
+      failed.push({ id: result.id, type: result.type, detail: result.error ? result.error.detail || result.error.title : result.status });
+    }
+  }
+  await removeEntries(sent);
+  return { applied, failed };
+}
+
+async function batchKey(ops) {
+  const ids = new TextEncoder().encode(ops.map((op) => op.id).join(','));
+  const digest = await crypto.subtle.digest('SHA-256', ids);
+  return 'batch-' + Array.from(new Uint8Array(digest), (b) => b.toString(16).padStart(2, '0')).join('');
+}
+
+async function notify(message) {
+  const clients = await self.clients.matchAll({ includeUncontrolled: true, type: 'window' });
+  for (const client of clients) {
+    client.postMessage(message);
+  }
+}
+
+// IndexedDB keeps the queue across restarts of the worker and the browser.
+
+function openDB() {
+  return new Promise((resolve, reject) => {
+    const open = indexedDB.open(DB_NAME, 1);
+    open.onupgradeneeded = () => {
+      open.result.createObjectStore(QUEUE, { keyPath: 'seq', autoIncrement: true });
+      open.result.createObjectStore(REFS, { keyPath: 'op' });
+    };
+    open.onsuccess = () => resolve(open.result);
+    open.onerror = () => reject(open.error);
+  });
+}
+
+async function withStore(name, mode, fn) {
+  const db = await openDB();
+  return new Promise((resolve, reject) => {
+    const tx = db.transaction(name, mode);
+    const request = fn(tx.objectStore(name));
+    tx.oncomplete = () => resolve(request && request.result);
+    tx.onerror = () => reject(tx.error);
+    tx.onabort = () => reject(tx.error);
+  }).finally(() => db.close());
+}
+
+function enqueue(entry) {
+  return withStore(QUEUE, 'readwrite', (store) => store.add(entry));
+}
+
+function readQueue() {
+  return withStore(QUEUE, 'readonly', (store) => store.getAll());
+}
+
+function pendingCount() {
+  return withStore(QUEUE, 'readonly', (store) => store.count());
+}
+
+function removeEntries(entries) {
+  if (entries.length === 0) {
+    return Promise.resolve();
+  }
+  return withStore(QUEUE, 'readwrite', (store) => {
+    for (const entry of entries) {
+      store.delete(entry.seq);
+    }
+  });
+}
+
+function saveRef(op, realization) {
+  return withStore(REFS, 'readwrite', (store) => store.put({ op, realization }));
+}
+
+async function lookupRef(op) {
+  const ref = await withStore(REFS, 'readonly', (store) => store.get(op));
+  return ref && ref.realization;
+}
@@ -0,0 +1,43 @@
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/luisteixeira/waypoint/backend/internal/alert"
+)
+
+// AlertNotifier delivers alerts to the subscriptions of the family that ask
+// for alert.triggered events.
+type AlertNotifier struct {
+	store Store
+	now   func() time.Time
+}
+
+// NewAlertNotifier returns a notifier that stamps events with the time now
+// tells.
+func NewAlertNotifier(store Store, now func() time.Time) *AlertNotifier {
+	return &AlertNotifier{store: store, now: now}
+}
+
+func (n *AlertNotifier) Channel() alert.Channel {
+	return alert.ChannelWebhook
+}
+
+// Notify queues the alert as a new event, so an alert raised again after a
+// snooze is delivered again.
+func (n *AlertNotifier) Notify(ctx context.Context, rule alert.Rule, a alert.Alert) error {
+	_, err := n.store.Enqueue(ctx, Event{
+		ID:         uuid.New(),
+		Type:       EventAlertTriggered,
+		OccurredAt: n.now().UTC().Truncate(time.Microsecond),
+		FamilyID:   a.FamilyID,
+		Alert:      &a,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to queue webhook deliveries: %w", err)
+	}
+	return nil
+}
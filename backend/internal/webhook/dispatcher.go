@@ -0,0 +1,119 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"time"
+)
+
+// Policy controls how deliveries are sent and retried.
+type Policy struct {
+	// Timeout bounds each request to a receiver.
+	Timeout time.Duration
+	// MaxAttempts is how many times a delivery is sent before it is marked
+	// failed.
+	MaxAttempts int
+	// InitialBackoff is the wait after the first failure; it doubles after
+	// each further one, up to MaxBackoff.
+	InitialBackoff time.Duration
+	MaxBackoff     time.Duration
+}
+
+// Backoff returns how long to wait after the given number of failed attempts.
+func (p Policy) Backoff(attempts int) time.Duration {
+	wait := p.InitialBackoff
+	for i := 1; i < attempts && wait < p.MaxBackoff; i++ {
+		wait *= 2
+	}
+	return min(wait, p.MaxBackoff)
+}
+
+// claimBatch is how many deliveries one Dispatch sends at most.
+const claimBatch = 50
+
+// Dispatcher sends due deliveries from a Store to their subscriptions.
+type Dispatcher struct {
+	store  Store
+	client *http.Client
+	policy Policy
+	now    func() time.Time
+}
+
+// NewDispatcher returns a dispatcher that signs deliveries and schedules
+// retries by the time now tells.
+func NewDispatcher(store Store, policy Policy, now func() time.Time) *Dispatcher {
+	return &Dispatcher{
+		store:  store,
+		client: &http.Client{Timeout: policy.Timeout},
+		policy: policy,
+		now:    now,
+	}
+}
+
+// Dispatch sends the deliveries due now, one after the other, and returns
+// how many the receivers accepted. It is meant to run as a periodic worker
+// job. Each delivery is leased for long enough to be sent, so a dispatcher
+// that dies mid-way leaves it to be picked up again later.
+func (d *Dispatcher) Dispatch(ctx context.Context) (int, error) {
+	now := d.now()
+	due, err := d.store.ClaimDue(ctx, now, now.Add(claimBatch*d.policy.Timeout), claimBatch)
+	if err != nil {
+		return 0, fmt.Errorf("failed to claim webhook deliveries: %w", err)
+	}
+
+	delivered := 0
+	for _, delivery := range due {
+		if ctx.Err() != nil {
+			break
+		}
+		attempt := d.send(ctx, delivery)
+		if attempt.Delivered {
+			delivered++
+		} else {
+			slog.WarnContext(ctx, "webhook delivery failed",
+				"delivery", delivery.ID, "subscription", delivery.SubscriptionID,
+				"attempt", delivery.Attempts+1, "status", attempt.ResponseStatus, "error", attempt.Error)
+		}
+		if err := d.store.RecordAttempt(ctx, delivery.ID, attempt); err != nil {
+			return delivered, fmt.Errorf("failed to record webhook attempt: %w", err)
+		}
+	}
+	return delivered, nil
+}
+
+func (d *Dispatcher) send(ctx context.Context, delivery Delivery) Attempt {
+	at := d.now()
+	attempt := Attempt{At: at}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.URL, bytes.NewReader(delivery.Payload))
+	if err == nil {
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("User-Agent", "Waypoint-Webhooks/1")
+		req.Header.Set(EventHeader, string(delivery.EventType))
+		req.Header.Set(DeliveryHeader, delivery.ID.String())
+		req.Header.Set(SignatureHeader, Sign(delivery.Secret, at, delivery.Payload))
+
+		var res *http.Response
+		if res, err = d.client.Do(req); err == nil {
+			io.Copy(io.Discard, io.LimitReader(res.Body, 64<<10))
+			res.Body.Close()
+			attempt.ResponseStatus = res.StatusCode
+			if res.StatusCode >= 200 && res.StatusCode < 300 {
+				attempt.Delivered = true
+				return attempt
+			}
+			err = fmt.Errorf("receiver answered %s", res.Status)
+		}
+	}
+
+	attempt.Error = err.Error()
+	if attempts := delivery.Attempts + 1; attempts < d.policy.MaxAttempts {
+		retryAt := at.Add(d.policy.Backoff(attempts))
+		attempt.RetryAt = &retryAt
+	}
+	return attempt
+}
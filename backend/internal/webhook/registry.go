@@ -0,0 +1,89 @@
+package webhook
+
+import (
+	"context"
+	"net/url"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/luisteixeira/waypoint/backend/internal/domain"
+)
+
+// Delivery log page sizes.
+const (
+	DefaultDeliveryLimit = 50
+	MaxDeliveryLimit     = 200
+)
+
+// Registry is what families use to manage their webhooks.
+type Registry struct {
+	store Store
+}
+
+func NewRegistry(store Store) *Registry {
+	return &Registry{store: store}
+}
+
+// Subscribe registers rawURL for the given events and returns the new
+// subscription, with the secret its deliveries will be signed with.
+func (r *Registry) Subscribe(ctx context.Context, rawURL string, events []EventType, description string) (*Subscription, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return nil, &domain.ValidationError{Field: "url", Reason: "must be an absolute http or https URL"}
+	}
+	if len(events) == 0 {
+		return nil, &domain.ValidationError{Field: "events", Reason: "must name at least one event"}
+	}
+	var wanted []EventType
+	for _, e := range events {
+		if !slices.Contains(EventTypes, e) {
+			return nil, &domain.ValidationError{Field: "events", Reason: "unknown event " + string(e)}
+		}
+		if !slices.Contains(wanted, e) {
+			wanted = append(wanted, e)
+		}
+	}
+
+	secret, err := NewSecret()
+	if err != nil {
+		return nil, err
+	}
+	sub := &Subscription{
+		ID:          uuid.New(),
+		URL:         u.String(),
+		Events:      wanted,
+		Description: description,
+		Secret:      secret,
+		CreatedAt:   time.Now().UTC().Truncate(time.Microsecond),
+	}
+	if err := r.store.CreateSubscription(ctx, sub); err != nil {
+		return nil, err
+	}
+	return sub, nil
+}
+
+func (r *Registry) Subscriptions(ctx context.Context) ([]Subscription, error) {
+	return r.store.ListSubscriptions(ctx)
+}
+
+func (r *Registry) Unsubscribe(ctx context.Context, id uuid.UUID) error {
+	return r.store.DeleteSubscription(ctx, id)
+}
+
+// Deliveries returns the subscription's most recent deliveries, newest first.
+// A limit of zero picks DefaultDeliveryLimit.
+func (r *Registry) Deliveries(ctx context.Context, subscriptionID uuid.UUID, limit int) ([]Delivery, error) {
+	switch {
+	case limit == 0:
+		limit = DefaultDeliveryLimit
+	case limit < 0 || limit > MaxDeliveryLimit:
+		return nil, &domain.ValidationError{Field: "limit", Reason: "must be between 1 and 200"}
+	}
+	return r.store.ListDeliveries(ctx, subscriptionID, limit)
+}
+
+// Redeliver sends an event again, as a new delivery with attempts of its own.
+func (r *Registry) Redeliver(ctx context.Context, subscriptionID, deliveryID uuid.UUID) (*Delivery, error) {
+	return r.store.Redeliver(ctx, subscriptionID, deliveryID)
+}
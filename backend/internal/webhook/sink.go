@@ -0,0 +1,46 @@
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"slices"
+
+	"github.com/luisteixeira/waypoint/backend/internal/outbox"
+)
+
+// Sink queues webhook deliveries for the lifecycle events published by the
+// outbox. Events are delivered with the outbox event ID, so receivers can
+// tell a repeat from a new event.
+type Sink struct {
+	store Store
+}
+
+func NewSink(store Store) *Sink {
+	return &Sink{store: store}
+}
+
+func (s *Sink) Name() string {
+	return "webhooks"
+}
+
+// Publish queues a delivery to every subscription that wants the event. The
+// outbox events that subscriptions cannot ask for are skipped, and an event
+// the outbox retries is not queued twice for the same subscription.
+func (s *Sink) Publish(ctx context.Context, event outbox.Event) error {
+	t := EventType(event.Type)
+	if !slices.Contains(EventTypes, t) {
+		return nil
+	}
+
+	_, err := s.store.Enqueue(ctx, Event{
+		ID:          event.ID,
+		Type:        t,
+		OccurredAt:  event.OccurredAt,
+		FamilyID:    event.FamilyID,
+		Realization: event.Realization,
+	})
+	if err != nil {
+		return fmt.Errorf("failed to queue webhook deliveries: %w", err)
+	}
+	return nil
+}
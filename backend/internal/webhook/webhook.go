@@ -0,0 +1,150 @@
+// Package webhook notifies URLs registered by a family when activities are
+// planned, started, completed or cancelled, and when alerts are raised.
+// Deliveries are queued by a Sink as the outbox publishes the changes, or by
+// an AlertNotifier, and sent by a Dispatcher, signed and retried with
+// exponential backoff until the receiver accepts them.
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/luisteixeira/waypoint/backend/internal/alert"
+	"github.com/luisteixeira/waypoint/backend/internal/domain"
+	"github.com/luisteixeira/waypoint/backend/internal/outbox"
+)
+
+type EventType string
+
+const (
+	EventActivityPlanned   = EventType(outbox.EventActivityPlanned)
+	EventActivityStarted   = EventType(outbox.EventActivityStarted)
+	EventActivityCompleted = EventType(outbox.EventActivityCompleted)
+	EventActivityCancelled = EventType(outbox.EventActivityCancelled)
+	// EventAlertTriggered is sent for the alerts of rules with the webhook
+	// channel.
+	EventAlertTriggered EventType = "alert.triggered"
+)
+
+// EventTypes lists every event a subscription can ask for.
+var EventTypes = []EventType{EventActivityPlanned, EventActivityStarted, EventActivityCompleted, EventActivityCancelled, EventAlertTriggered}
+
+// Subscription asks for the given events to be posted to URL. Secret signs
+// the deliveries; it is only shown when the subscription is created.
+type Subscription struct {
+	ID          uuid.UUID
+	FamilyID    uuid.UUID
+	URL         string
+	Events      []EventType
+	Description string
+	Secret      string
+	CreatedAt   time.Time
+}
+
+// Wants reports whether the subscription asked for events of type t.
+func (s *Subscription) Wants(t EventType) bool {
+	for _, e := range s.Events {
+		if e == t {
+			return true
+		}
+	}
+	return false
+}
+
+// Event is the body of a delivery. Receivers should use ID to ignore
+// repeats: a delivery may arrive more than once, and in any order. Activity
+// events carry the realization and alert events the alert.
+type Event struct {
+	ID          uuid.UUID                   `json:"id"`
+	Type        EventType                   `json:"type"`
+	OccurredAt  time.Time                   `json:"occurred_at"`
+	FamilyID    uuid.UUID                   `json:"family_id"`
+	Realization *domain.ActivityRealization `json:"realization,omitempty"`
+	Alert       *alert.Alert                `json:"alert,omitempty"`
+}
+
+type DeliveryStatus string
+
+const (
+	DeliveryPending   DeliveryStatus = "pending"
+	DeliveryDelivered DeliveryStatus = "delivered"
+	// DeliveryFailed marks a delivery that used up its attempts.
+	DeliveryFailed DeliveryStatus = "failed"
+)
+
+// Delivery is one event on its way to one subscription, and the log of how
+// that went.
+type Delivery struct {
+	ID             uuid.UUID
+	SubscriptionID uuid.UUID
+	FamilyID       uuid.UUID
+	EventID        uuid.UUID
+	EventType      EventType
+	Payload        json.RawMessage
+
+	Status         DeliveryStatus
+	Attempts       int
+	NextAttemptAt  time.Time
+	LastAttemptAt  *time.Time
+	ResponseStatus int
+	LastError      string
+	CreatedAt      time.Time
+	DeliveredAt    *time.Time
+
+	// URL and Secret are filled in for the dispatcher by ClaimDue.
+	URL    string
+	Secret string
+}
+
+// Attempt is the outcome of sending a delivery once.
+type Attempt struct {
+	At             time.Time
+	ResponseStatus int
+	Error          string
+	Delivered      bool
+	// RetryAt is when to try again after a failure; nil gives up.
+	RetryAt *time.Time
+}
+
+// Status is the status a delivery has after the attempt.
+func (a Attempt) Status() DeliveryStatus {
+	switch {
+	case a.Delivered:
+		return DeliveryDelivered
+	case a.RetryAt == nil:
+		return DeliveryFailed
+	default:
+		return DeliveryPending
+	}
+}
+
+// Store keeps subscriptions and deliveries per family, taken from the context
+// like the service repositories do. Only the dispatcher's ClaimDue and
+// RecordAttempt work across families.
+type Store interface {
+	CreateSubscription(ctx context.Context, sub *Subscription) error
+	ListSubscriptions(ctx context.Context) ([]Subscription, error)
+	// DeleteSubscription removes a subscription and its deliveries.
+	DeleteSubscription(ctx context.Context, id uuid.UUID) error
+
+	// Enqueue stores a pending delivery of event, due at once, for every
+	// subscription of the family that wants its type, and returns them. A
+	// subscription the event was already queued for is skipped, so handing
+	// the same event over twice is harmless.
+	Enqueue(ctx context.Context, event Event) ([]Delivery, error)
+	// ListDeliveries returns the subscription's most recent deliveries, newest
+	// first.
+	ListDeliveries(ctx context.Context, subscriptionID uuid.UUID, limit int) ([]Delivery, error)
+	// Redeliver stores a new pending delivery of the same event as the given
+	// one, due at once, and returns it.
+	Redeliver(ctx context.Context, subscriptionID, deliveryID uuid.UUID) (*Delivery, error)
+
+	// ClaimDue returns up to limit pending deliveries due by now, in any
+	// family, and hides them from other callers until leaseUntil so two
+	// dispatchers do not send the same delivery at once.
+	ClaimDue(ctx context.Context, now, leaseUntil time.Time, limit int) ([]Delivery, error)
+	// RecordAttempt stores the outcome of sending a claimed delivery.
+	RecordAttempt(ctx context.Context, deliveryID uuid.UUID, attempt Attempt) error
+}
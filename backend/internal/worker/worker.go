@@ -0,0 +1,146 @@
+// Package worker runs the server's background jobs and stops them together on
+// shutdown.
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Func is one run of a background job. It should return once ctx is done.
+type Func func(ctx context.Context) error
+
+// Group owns a set of periodic jobs.
+type Group struct {
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu   sync.Mutex
+	jobs map[string]*job
+}
+
+type job struct {
+	interval time.Duration
+	// housekeeping jobs are left out of Check.
+	housekeeping bool
+	lastRun      time.Time
+	lastErr      error
+	// failures counts the runs that failed in a row.
+	failures int
+}
+
+// maxFailures is how many runs in a row a job may fail before Check reports
+// it, so that a transient error does not take the server out of rotation.
+const maxFailures = 3
+
+func NewGroup() *Group {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Group{ctx: ctx, cancel: cancel, jobs: map[string]*job{}}
+}
+
+// Every runs fn immediately and then every interval until the group stops.
+// Runs never overlap; a run that overruns the interval delays the next one.
+func (g *Group) Every(name string, interval time.Duration, fn Func) {
+	g.start(name, &job{interval: interval}, fn)
+}
+
+// Housekeeping runs fn like Every, but leaves it out of Check: a job that only
+// tidies up old data is not worth taking the server out of rotation for.
+// Failures are still logged.
+func (g *Group) Housekeeping(name string, interval time.Duration, fn Func) {
+	g.start(name, &job{interval: interval, housekeeping: true}, fn)
+}
+
+func (g *Group) start(name string, j *job, fn Func) {
+	g.mu.Lock()
+	if _, exists := g.jobs[name]; exists {
+		g.mu.Unlock()
+		panic(fmt.Sprintf("worker: job %q registered twice", name))
+	}
+	j.lastRun = time.Now()
+	g.jobs[name] = j
+	g.mu.Unlock()
+
+	g.wg.Add(1)
+	go func() {
+		defer g.wg.Done()
+		ticker := time.NewTicker(j.interval)
+		defer ticker.Stop()
+		for {
+			err := fn(g.ctx)
+			if g.ctx.Err() != nil {
+				return
+			}
+			if err != nil {
+				slog.ErrorContext(g.ctx, "background job failed", "job", name, "error", err)
+			}
+			g.mu.Lock()
+			j.lastRun, j.lastErr = time.Now(), err
+			if err != nil {
+				j.failures++
+			} else {
+				j.failures = 0
+			}
+			g.mu.Unlock()
+
+			select {
+			case <-ticker.C:
+			case <-g.ctx.Done():
+				return
+			}
+		}
+	}()
+}
+
+// Check reports the jobs that failed maxFailures runs in a row or that have
+// not finished a run for three intervals, which points at a job stuck on a
+// dependency. Housekeeping jobs are not checked.
+func (g *Group) Check(ctx context.Context) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	names := make([]string, 0, len(g.jobs))
+	for name, j := range g.jobs {
+		if !j.housekeeping {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	var errs []error
+	for _, name := range names {
+		j := g.jobs[name]
+		switch {
+		case j.failures >= maxFailures:
+			errs = append(errs, fmt.Errorf("%s: failed %d runs in a row: %w", name, j.failures, j.lastErr))
+		case time.Since(j.lastRun) > 3*j.interval:
+			errs = append(errs, fmt.Errorf("%s: no run finished since %s", name, j.lastRun.Format(time.RFC3339)))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Stop cancels the running jobs and waits for them to return, or for ctx to
+// expire.
+func (g *Group) Stop(ctx context.Context) error {
+	g.cancel()
+
+	done := make(chan struct{})
+	go func() {
+		g.wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("background jobs did not stop: %w", ctx.Err())
+	}
+}
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS realization_events;
\ No newline at end of file
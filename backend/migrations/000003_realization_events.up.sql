@@ -0,0 +1,36 @@
+CREATE TABLE realization_events(
+    sequence BIGSERIAL PRIMARY KEY,
+    realization_id UUID NOT NULL,
+    family_id UUID NOT NULL,
+    event_type TEXT NOT NULL,
+    data JSONB NOT NULL DEFAULT '{}',
+    occurred_at TIMESTAMP WITH TIME ZONE NOT NULL,
+    recorded_at TIMESTAMP WITH TIME ZONE DEFAULT NOW()
+);
+
+CREATE INDEX idx_realization_events_stream
+ON realization_events (realization_id, sequence);
+
+-- Seed a stream for realizations recorded before events existed, so every
+-- projection can be rebuilt from its events.
+INSERT INTO realization_events (realization_id, family_id, event_type, data, occurred_at)
+SELECT ar.id, ar.family_id, 'planned',
+    jsonb_build_object(
+        'definition_id', ar.definition_id,
+        'entity_id', ar.entity_id,
+        'caregiver_ids', COALESCE(
+            (SELECT jsonb_agg(rc.caregiver_id) FROM realization_caregivers rc WHERE rc.realization_id = ar.id),
+            '[]'::jsonb)
+    ),
+    COALESCE(ar.started_at, NOW())
+FROM activity_realizations ar;
+
+INSERT INTO realization_events (realization_id, family_id, event_type, data, occurred_at)
+SELECT ar.id, ar.family_id, 'started', '{}', ar.started_at
+FROM activity_realizations ar
+WHERE ar.started_at IS NOT NULL;
+
+INSERT INTO realization_events (realization_id, family_id, event_type, data, occurred_at)
+SELECT ar.id, ar.family_id, ar.status, '{}', ar.finished_at
+FROM activity_realizations ar
+WHERE ar.finished_at IS NOT NULL AND ar.status IN ('completed', 'cancelled');
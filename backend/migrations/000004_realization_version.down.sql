@@ -0,0 +1 @@
+ALTER TABLE activity_realizations DROP COLUMN IF EXISTS version;
\ No newline at end of file
@@ -0,0 +1,5 @@
+DROP INDEX IF EXISTS uq_active_entity_realization;
+
+CREATE INDEX idx_active_entity_realization 
+ON activity_realizations (entity_id, family_id) 
+WHERE status = 'in_progress';
@@ -0,0 +1,28 @@
+DROP INDEX IF EXISTS idx_active_entity_realization;
+
+-- Before the index, nothing stopped a child from being in two activities at
+-- once. Keep the most recently started of each child's activities in progress
+-- and cancel the rest, recording the cancellation in their event streams so
+-- the projections can still be rebuilt from them.
+WITH ranked AS (
+    SELECT id, ROW_NUMBER() OVER (
+        PARTITION BY family_id, entity_id
+        ORDER BY started_at DESC NULLS LAST, id DESC
+    ) AS recency
+    FROM activity_realizations
+    WHERE status = 'in_progress' AND family_id IS NOT NULL AND entity_id IS NOT NULL
+),
+cancelled AS (
+    UPDATE activity_realizations ar
+    SET status = 'cancelled', finished_at = NOW(), version = ar.version + 1
+    FROM ranked
+    WHERE ar.id = ranked.id AND ranked.recency > 1
+    RETURNING ar.id, ar.family_id, ar.finished_at
+)
+INSERT INTO realization_events (realization_id, family_id, event_type, data, occurred_at)
+SELECT id, family_id, 'cancelled', '{}', finished_at
+FROM cancelled;
+
+CREATE UNIQUE INDEX uq_active_entity_realization
+ON activity_realizations (family_id, entity_id)
+WHERE status = 'in_progress';
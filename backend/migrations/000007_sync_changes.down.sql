@@ -0,0 +1,6 @@
+DROP TRIGGER IF EXISTS sync_entities ON entities;
+DROP TRIGGER IF EXISTS sync_activity_definitions ON activity_definitions;
+DROP TRIGGER IF EXISTS sync_activity_realizations ON activity_realizations;
+DROP FUNCTION IF EXISTS record_sync_change();
+DROP TABLE IF EXISTS sync_changes;
+DROP SEQUENCE IF EXISTS sync_change_seq;
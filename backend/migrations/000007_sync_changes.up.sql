@@ -0,0 +1,74 @@
+-- sync_changes holds the latest change to every realization, definition and
+-- entity, numbered from sync_change_seq, so clients can ask for everything
+-- that changed after the last sequence they saw. Deleted rows are kept as
+-- tombstones.
+CREATE SEQUENCE sync_change_seq;
+
+CREATE TABLE sync_changes(
+    kind TEXT NOT NULL,
+    object_id UUID NOT NULL,
+    family_id UUID NOT NULL,
+    sequence BIGINT NOT NULL,
+    deleted BOOLEAN NOT NULL DEFAULT FALSE,
+    changed_at TIMESTAMP WITH TIME ZONE NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (kind, object_id)
+);
+
+CREATE INDEX idx_sync_changes_family_sequence
+ON sync_changes (family_id, sequence);
+
+-- record_sync_change runs as a deferred constraint trigger, at commit. The
+-- advisory lock makes writers in a family take their sequence numbers one at
+-- a time and keeps it until they have committed, so a change never becomes
+-- visible after one with a higher number and readers cannot skip past it.
+CREATE FUNCTION record_sync_change() RETURNS trigger AS $$
+DECLARE
+    changed RECORD;
+BEGIN
+    IF TG_OP = 'DELETE' THEN
+        changed := OLD;
+    ELSE
+        changed := NEW;
+    END IF;
+    -- Upserts that find the row already there rewrite it unchanged.
+    IF changed.family_id IS NULL OR (TG_OP = 'UPDATE' AND NEW IS NOT DISTINCT FROM OLD) THEN
+        RETURN NULL;
+    END IF;
+
+    PERFORM pg_advisory_xact_lock(2037, hashtext(changed.family_id::text));
+
+    INSERT INTO sync_changes (kind, object_id, family_id, sequence, deleted, changed_at)
+    VALUES (TG_ARGV[0], changed.id, changed.family_id, nextval('sync_change_seq'), TG_OP = 'DELETE', NOW())
+    ON CONFLICT (kind, object_id) DO UPDATE
+    SET family_id = EXCLUDED.family_id,
+        sequence = EXCLUDED.sequence,
+        deleted = EXCLUDED.deleted,
+        changed_at = EXCLUDED.changed_at;
+    RETURN NULL;
+END;
+$$ LANGUAGE plpgsql;
+
+CREATE CONSTRAINT TRIGGER sync_activity_realizations
+AFTER INSERT OR UPDATE OR DELETE ON activity_realizations
+DEFERRABLE INITIALLY DEFERRED
+FOR EACH ROW EXECUTE FUNCTION record_sync_change('realization');
+
+CREATE CONSTRAINT TRIGGER sync_activity_definitions
+AFTER INSERT OR UPDATE OR DELETE ON activity_definitions
+DEFERRABLE INITIALLY DEFERRED
+FOR EACH ROW EXECUTE FUNCTION record_sync_change('definition');
+
+CREATE CONSTRAINT TRIGGER sync_entities
+AFTER INSERT OR UPDATE OR DELETE ON entities
+DEFERRABLE INITIALLY DEFERRED
+FOR EACH ROW EXECUTE FUNCTION record_sync_change('entity');
+
+-- Number what already exists, so the first sync returns it.
+INSERT INTO sync_changes (kind, object_id, family_id, sequence)
+SELECT 'entity', id, family_id, nextval('sync_change_seq') FROM entities WHERE family_id IS NOT NULL;
+
+INSERT INTO sync_changes (kind, object_id, family_id, sequence)
+SELECT 'definition', id, family_id, nextval('sync_change_seq') FROM activity_definitions;
+
+INSERT INTO sync_changes (kind, object_id, family_id, sequence)
+SELECT 'realization', id, family_id, nextval('sync_change_seq') FROM activity_realizations WHERE family_id IS NOT NULL;
@@ -0,0 +1,20 @@
+-- outbox_events is written in the transaction that changed the activity and
+-- read by the dispatcher, which hands each event to every sink. done_sinks
+-- lists the sinks that accepted it, so a retry only goes to the others.
+CREATE TABLE outbox_events (
+    sequence BIGSERIAL PRIMARY KEY,
+    id UUID NOT NULL UNIQUE,
+    family_id UUID NOT NULL REFERENCES families(id) ON DELETE CASCADE,
+    type TEXT NOT NULL,
+    realization JSONB NOT NULL,
+    occurred_at TIMESTAMPTZ NOT NULL,
+    attempts INTEGER NOT NULL DEFAULT 0,
+    done_sinks TEXT[] NOT NULL DEFAULT '{}',
+    next_attempt_at TIMESTAMPTZ NOT NULL,
+    last_error TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL,
+    published_at TIMESTAMPTZ
+);
+
+CREATE INDEX idx_outbox_events_due ON outbox_events (next_attempt_at) WHERE published_at IS NULL;
+CREATE INDEX idx_outbox_events_published ON outbox_events (published_at) WHERE published_at IS NOT NULL;
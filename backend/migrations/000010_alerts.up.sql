@@ -0,0 +1,37 @@
+-- alert_rules say what the evaluator watches for. A rule without an entity
+-- watches every child of the family.
+CREATE TABLE alert_rules (
+    id UUID PRIMARY KEY,
+    family_id UUID NOT NULL REFERENCES families(id) ON DELETE CASCADE,
+    kind TEXT NOT NULL,
+    definition_id UUID NOT NULL REFERENCES activity_definitions(id) ON DELETE CASCADE,
+    entity_id UUID REFERENCES entities(id) ON DELETE CASCADE,
+    threshold_seconds BIGINT NOT NULL,
+    channels TEXT[] NOT NULL DEFAULT '{}',
+    email TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX idx_alert_rules_family ON alert_rules (family_id);
+
+-- subject_id is the realization an alert is about or, failing that, its
+-- child; a rule raises at most one unresolved alert per subject.
+CREATE TABLE alerts (
+    id UUID PRIMARY KEY,
+    family_id UUID NOT NULL REFERENCES families(id) ON DELETE CASCADE,
+    rule_id UUID NOT NULL REFERENCES alert_rules(id) ON DELETE CASCADE,
+    kind TEXT NOT NULL,
+    definition_id UUID NOT NULL,
+    entity_id UUID NOT NULL,
+    realization_id UUID,
+    subject_id UUID NOT NULL,
+    message TEXT NOT NULL,
+    status TEXT NOT NULL,
+    triggered_at TIMESTAMPTZ NOT NULL,
+    snoozed_until TIMESTAMPTZ,
+    acknowledged_at TIMESTAMPTZ,
+    resolved_at TIMESTAMPTZ
+);
+
+CREATE UNIQUE INDEX uq_unresolved_alert ON alerts (rule_id, subject_id) WHERE status <> 'resolved';
+CREATE INDEX idx_alerts_family ON alerts (family_id, status, triggered_at);
@@ -0,0 +1,30 @@
+-- caregiver_id is the caregiver who made the change, so that they are not
+-- pushed a notification about it.
+ALTER TABLE outbox_events ADD COLUMN caregiver_id UUID;
+
+-- push_subscriptions are the browsers a caregiver receives Web Push
+-- messages in. A browser's endpoint belongs to one subscription at a time.
+CREATE TABLE push_subscriptions (
+    id UUID PRIMARY KEY,
+    family_id UUID NOT NULL REFERENCES families(id) ON DELETE CASCADE,
+    caregiver_id UUID NOT NULL REFERENCES caregivers(id) ON DELETE CASCADE,
+    endpoint TEXT NOT NULL UNIQUE,
+    p256dh TEXT NOT NULL,
+    auth TEXT NOT NULL,
+    created_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX idx_push_subscriptions_caregiver ON push_subscriptions (family_id, caregiver_id);
+
+-- push_preferences say which children a caregiver follows and what they
+-- want to hear about them.
+CREATE TABLE push_preferences (
+    family_id UUID NOT NULL REFERENCES families(id) ON DELETE CASCADE,
+    caregiver_id UUID NOT NULL REFERENCES caregivers(id) ON DELETE CASCADE,
+    entity_ids UUID[] NOT NULL DEFAULT '{}',
+    activity_started BOOLEAN NOT NULL,
+    activity_completed BOOLEAN NOT NULL,
+    alerts BOOLEAN NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (family_id, caregiver_id)
+);
@@ -0,0 +1,3 @@
+-- planned_for is when a planned realization is meant to start. Realizations
+-- planned before it existed have none and are never reminded about.
+ALTER TABLE activity_realizations ADD COLUMN planned_for TIMESTAMP WITH TIME ZONE;
@@ -0,0 +1,19 @@
+-- redelivery_of links a redelivery to the delivery it repeats, so that only
+-- the first delivery of an event to a subscription has to be unique.
+ALTER TABLE webhook_deliveries ADD COLUMN redelivery_of UUID;
+
+-- Retried outbox events may already have queued an event twice; the oldest
+-- delivery is kept as the original.
+UPDATE webhook_deliveries d
+SET redelivery_of = first.id
+FROM (
+    SELECT DISTINCT ON (subscription_id, event_id) id, subscription_id, event_id
+    FROM webhook_deliveries
+    ORDER BY subscription_id, event_id, created_at, id
+) first
+WHERE d.subscription_id = first.subscription_id
+  AND d.event_id = first.event_id
+  AND d.id <> first.id;
+
+CREATE UNIQUE INDEX idx_webhook_deliveries_event ON webhook_deliveries (subscription_id, event_id)
+    WHERE redelivery_of IS NULL;
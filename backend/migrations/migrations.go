@@ -0,0 +1,10 @@
+// Package migrations holds the Postgres schema migrations, embedded so the
+// server binary can apply them itself.
+package migrations
+
+import "embed"
+
+// FS contains the numbered *.up.sql and *.down.sql files.
+//
+//go:embed *.sql
+var FS embed.FS
@@ -0,0 +1,131 @@
+package alert_test
+
+import (
+	"bufio"
+	"context"
+	"net"
+	"net/mail"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/luisteixeira/waypoint/backend/internal/alert"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// envelope is a message received by the fake SMTP server.
+type envelope struct {
+	from, to string
+	data     string
+}
+
+// serveSMTP accepts one plain SMTP session on a local port and returns its
+// address and the message it receives.
+func serveSMTP(t *testing.T) (string, <-chan envelope) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	t.Cleanup(func() { ln.Close() })
+
+	received := make(chan envelope, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		r := bufio.NewReader(conn)
+		reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+		reply("220 localhost ESMTP")
+
+		var msg envelope
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			line = strings.TrimRight(line, "\r\n")
+			command := strings.ToUpper(line)
+			switch {
+			case strings.HasPrefix(command, "EHLO"), strings.HasPrefix(command, "HELO"):
+				reply("250 localhost")
+			case strings.HasPrefix(command, "MAIL FROM:"):
+				msg.from = strings.Trim(line[len("MAIL FROM:"):], "<>")
+				reply("250 OK")
+			case strings.HasPrefix(command, "RCPT TO:"):
+				msg.to = strings.Trim(line[len("RCPT TO:"):], "<>")
+				reply("250 OK")
+			case command == "DATA":
+				reply("354 Go ahead")
+				var data strings.Builder
+				for {
+					line, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					if line == ".\r\n" {
+						break
+					}
+					data.WriteString(line)
+				}
+				msg.data = data.String()
+				received <- msg
+				reply("250 Queued")
+			case command == "QUIT":
+				reply("221 Bye")
+				return
+			default:
+				reply("502 Not implemented")
+			}
+		}
+	}()
+	return ln.Addr().String(), received
+}
+
+func TestEmailNotifier(t *testing.T) {
+	t.Run("Mails the alert to the rule's address", func(t *testing.T) {
+		addr, received := serveSMTP(t)
+		n := alert.NewEmailNotifier(alert.SMTPOptions{Addr: addr, From: "waypoint@localhost"})
+		assert.Equal(t, alert.ChannelEmail, n.Channel())
+
+		rule := alert.Rule{ID: uuid.New(), Email: "parent@example.com"}
+		a := alert.Alert{
+			ID:          uuid.New(),
+			Message:     "Nap has been going on for 3h 5m",
+			TriggeredAt: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+		}
+		require.NoError(t, n.Notify(context.Background(), rule, a))
+
+		msg := <-received
+		assert.Equal(t, "waypoint@localhost", msg.from)
+		assert.Equal(t, "parent@example.com", msg.to)
+
+		parsed, err := mail.ReadMessage(strings.NewReader(msg.data))
+		require.NoError(t, err)
+		assert.Equal(t, "<parent@example.com>", parsed.Header.Get("To"))
+		assert.Equal(t, "Waypoint: Nap has been going on for 3h 5m", parsed.Header.Get("Subject"))
+		assert.Contains(t, parsed.Header.Get("Message-ID"), a.ID.String())
+		body := new(strings.Builder)
+		_, err = bufio.NewReader(parsed.Body).WriteTo(body)
+		require.NoError(t, err)
+		assert.Contains(t, body.String(), "Nap has been going on for 3h 5m.")
+	})
+
+	t.Run("Fails without an address", func(t *testing.T) {
+		n := alert.NewEmailNotifier(alert.SMTPOptions{Addr: "127.0.0.1:1", From: "waypoint@localhost"})
+		assert.Error(t, n.Notify(context.Background(), alert.Rule{ID: uuid.New()}, alert.Alert{ID: uuid.New()}))
+	})
+
+	t.Run("Fails when the server cannot be reached", func(t *testing.T) {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		addr := ln.Addr().String()
+		ln.Close()
+
+		n := alert.NewEmailNotifier(alert.SMTPOptions{Addr: addr, From: "waypoint@localhost"})
+		err = n.Notify(context.Background(), alert.Rule{ID: uuid.New(), Email: "parent@example.com"}, alert.Alert{ID: uuid.New()})
+		assert.ErrorContains(t, err, "failed to reach the mail server")
+	})
+}
@@ -0,0 +1,376 @@
+package alert_test
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/luisteixeira/waypoint/backend/internal/alert"
+	"github.com/luisteixeira/waypoint/backend/internal/domain"
+	"github.com/luisteixeira/waypoint/backend/internal/middleware"
+	"github.com/luisteixeira/waypoint/backend/internal/repository/memory"
+	"github.com/luisteixeira/waypoint/backend/internal/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// notifier records the alerts it is given, failing if err is set.
+type notifier struct {
+	channel alert.Channel
+	err     error
+
+	mu     sync.Mutex
+	alerts []alert.Alert
+}
+
+func (n *notifier) Channel() alert.Channel { return n.channel }
+
+func (n *notifier) Notify(ctx context.Context, rule alert.Rule, a alert.Alert) error {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.alerts = append(n.alerts, a)
+	return n.err
+}
+
+func (n *notifier) notified() []alert.Alert {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	return append([]alert.Alert(nil), n.alerts...)
+}
+
+// failingDefinitions fails to list the definitions of the given families.
+type failingDefinitions struct {
+	*memory.InMemoryDefintionRepo
+	families []uuid.UUID
+}
+
+func (d *failingDefinitions) ListByFamily(ctx context.Context) ([]domain.ActivityDefinition, error) {
+	if slices.Contains(d.families, ctx.Value(middleware.FamilyIDKey).(uuid.UUID)) {
+		return nil, errors.New("connection reset")
+	}
+	return d.InMemoryDefintionRepo.ListByFamily(ctx)
+}
+
+type fixture struct {
+	ctx          context.Context
+	now          time.Time
+	store        *memory.InMemoryAlertStore
+	realizations *memory.InMemoryActivityRepo
+	definitions  *memory.InMemoryDefintionRepo
+	activities   domain.ActivityService
+	service      *alert.Service
+	evaluator    *alert.Evaluator
+	webhooks     *notifier
+	napID        uuid.UUID
+	feedID       uuid.UUID
+}
+
+func newFixture(t *testing.T) *fixture {
+	f := &fixture{
+		ctx:          context.WithValue(context.Background(), middleware.FamilyIDKey, uuid.New()),
+		now:          time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+		store:        memory.NewInMemoryAlertStore(),
+		realizations: memory.NewInMemoryActivityRepo(),
+		definitions:  memory.NewInMemoryDefinitionRepo(),
+		webhooks:     &notifier{channel: alert.ChannelWebhook},
+	}
+	f.service = alert.NewService(f.store, f.definitions, memory.NewInMemoryEntityRepo(f.realizations), f.clock, f.webhooks)
+	f.evaluator = alert.NewEvaluator(f.store, f.realizations, f.definitions, f.clock, f.webhooks)
+	f.activities = service.NewActivityService(f.realizations, f.definitions, memory.NewTxManager(), service.DefaultClientTimeWindow)
+
+	nap, err := f.definitions.GetOrCreateByName(f.ctx, "Nap")
+	require.NoError(t, err)
+	feed, err := f.definitions.GetOrCreateByName(f.ctx, "Feed")
+	require.NoError(t, err)
+	f.napID, f.feedID = nap.ID, feed.ID
+	return f
+}
+
+// clock is the fixture's clock, which tests move by setting now.
+func (f *fixture) clock() time.Time {
+	return f.now
+}
+
+func (f *fixture) rule(t *testing.T, input alert.RuleInput) *alert.Rule {
+	rule, err := f.service.CreateRule(f.ctx, input)
+	require.NoError(t, err)
+	return rule
+}
+
+// child returns a new child known to the family. The memory backend knows
+// children by their realizations, so a cancelled nap is recorded for them.
+func (f *fixture) child(t *testing.T) uuid.UUID {
+	entityID := uuid.New()
+	f.realization(t, f.napID, entityID, domain.StatusCancelled, 48*time.Hour)
+	return entityID
+}
+
+// realization stores a realization of the definition that started ago
+// before the fixture's clock.
+func (f *fixture) realization(t *testing.T, definitionID, entityID uuid.UUID, status domain.ActivityStatus, ago time.Duration) *domain.ActivityRealization {
+	startedAt := f.now.Add(-ago)
+	ar := &domain.ActivityRealization{
+		ID:           uuid.New(),
+		DefinitionID: definitionID,
+		EntityID:     entityID,
+		Status:       status,
+		StartedAt:    &startedAt,
+		Version:      1,
+	}
+	if status == domain.StatusCompleted {
+		ar.FinishedAt = &startedAt
+	}
+	require.NoError(t, f.realizations.CreateRealization(f.ctx, ar))
+	return ar
+}
+
+// plan plans a realization of the definition through the activity service,
+// for ago before the fixture's clock.
+func (f *fixture) plan(t *testing.T, definitionID, entityID uuid.UUID, ago time.Duration) *domain.ActivityRealization {
+	plannedFor := f.now.Add(-ago)
+	ar, err := f.activities.PlanActivity(f.ctx, domain.StartActivityInput{
+		DefinitionID: definitionID,
+		EntityID:     entityID,
+		PlannedFor:   &plannedFor,
+	})
+	require.NoError(t, err)
+	return ar
+}
+
+func (f *fixture) evaluate(t *testing.T) int {
+	raised, err := f.evaluator.Evaluate(context.Background())
+	require.NoError(t, err)
+	return raised
+}
+
+func (f *fixture) alerts(t *testing.T, statuses ...alert.Status) []alert.Alert {
+	alerts, err := f.service.Alerts(f.ctx, statuses, 0)
+	require.NoError(t, err)
+	return alerts
+}
+
+func TestEvaluator(t *testing.T) {
+	t.Run("Raises an alert for each realization running too long", func(t *testing.T) {
+		f := newFixture(t)
+		rule := f.rule(t, alert.RuleInput{
+			Kind: alert.KindRunningLong, DefinitionID: f.napID, Threshold: 3 * time.Hour,
+			Channels: []alert.Channel{alert.ChannelWebhook},
+		})
+		long := f.realization(t, f.napID, uuid.New(), domain.StatusInProgress, 3*time.Hour+5*time.Minute)
+		f.realization(t, f.napID, uuid.New(), domain.StatusInProgress, time.Hour)
+		f.realization(t, f.feedID, uuid.New(), domain.StatusInProgress, 5*time.Hour)
+
+		assert.Equal(t, 1, f.evaluate(t))
+
+		alerts := f.alerts(t)
+		require.Len(t, alerts, 1)
+		a := alerts[0]
+		assert.Equal(t, rule.ID, a.RuleID)
+		assert.Equal(t, alert.KindRunningLong, a.Kind)
+		assert.Equal(t, long.EntityID, a.EntityID)
+		require.NotNil(t, a.RealizationID)
+		assert.Equal(t, long.ID, *a.RealizationID)
+		assert.Equal(t, alert.StatusOpen, a.Status)
+		assert.Equal(t, f.now, a.TriggeredAt)
+		assert.Equal(t, "Nap has been going on for 3h 5m", a.Message)
+
+		notified := f.webhooks.notified()
+		require.Len(t, notified, 1)
+		assert.Equal(t, a.ID, notified[0].ID)
+	})
+
+	t.Run("Raises an alert once while its condition holds", func(t *testing.T) {
+		f := newFixture(t)
+		f.rule(t, alert.RuleInput{
+			Kind: alert.KindRunningLong, DefinitionID: f.napID, Threshold: 3 * time.Hour,
+			Channels: []alert.Channel{alert.ChannelWebhook},
+		})
+		f.realization(t, f.napID, uuid.New(), domain.StatusPaused, 4*time.Hour)
+
+		assert.Equal(t, 1, f.evaluate(t))
+		f.now = f.now.Add(time.Hour)
+		assert.Equal(t, 0, f.evaluate(t))
+		assert.Len(t, f.alerts(t), 1)
+		assert.Len(t, f.webhooks.notified(), 1)
+	})
+
+	t.Run("Resolves an alert once its condition clears", func(t *testing.T) {
+		f := newFixture(t)
+		f.rule(t, alert.RuleInput{Kind: alert.KindRunningLong, DefinitionID: f.napID, Threshold: 3 * time.Hour})
+		nap := f.realization(t, f.napID, uuid.New(), domain.StatusInProgress, 4*time.Hour)
+		f.evaluate(t)
+
+		nap.Status = domain.StatusCompleted
+		nap.FinishedAt = &f.now
+		require.NoError(t, f.realizations.UpdateRealization(f.ctx, nap))
+		f.now = f.now.Add(time.Minute)
+		f.evaluate(t)
+
+		assert.Empty(t, f.alerts(t))
+		resolved := f.alerts(t, alert.StatusResolved)
+		require.Len(t, resolved, 1)
+		require.NotNil(t, resolved[0].ResolvedAt)
+		assert.Equal(t, f.now, *resolved[0].ResolvedAt)
+	})
+
+	t.Run("Reminds about planned realizations that have not started", func(t *testing.T) {
+		f := newFixture(t)
+		entityID := f.child(t)
+		f.rule(t, alert.RuleInput{Kind: alert.KindNotStarted, DefinitionID: f.feedID, EntityID: entityID, Threshold: 15 * time.Minute})
+		late := f.plan(t, f.feedID, entityID, 20*time.Minute)
+		f.plan(t, f.feedID, entityID, 10*time.Minute)
+		f.plan(t, f.feedID, uuid.New(), time.Hour)
+		f.plan(t, f.napID, entityID, time.Hour)
+		started := f.plan(t, f.feedID, entityID, time.Hour)
+		_, err := f.activities.StartActivity(f.ctx, domain.StartActivityInput{RealizationID: started.ID})
+		require.NoError(t, err)
+		_, err = f.activities.PlanActivity(f.ctx, domain.StartActivityInput{DefinitionID: f.feedID, EntityID: entityID})
+		require.NoError(t, err, "planned for no particular time")
+
+		assert.Equal(t, 1, f.evaluate(t))
+		alerts := f.alerts(t)
+		require.Len(t, alerts, 1)
+		assert.Equal(t, late.ID, *alerts[0].RealizationID)
+		assert.Equal(t, "Feed has not started 20m after its planned time", alerts[0].Message)
+	})
+
+	t.Run("Alerts when nothing has been logged for a while", func(t *testing.T) {
+		f := newFixture(t)
+		entityID := f.child(t)
+		f.rule(t, alert.RuleInput{Kind: alert.KindNoActivity, DefinitionID: f.feedID, EntityID: entityID, Threshold: 4 * time.Hour})
+		f.now = f.now.Add(time.Hour)
+		f.realization(t, f.feedID, entityID, domain.StatusCompleted, 30*time.Minute)
+
+		f.now = f.now.Add(3 * time.Hour)
+		assert.Equal(t, 0, f.evaluate(t), "the last feed was 3h30m ago")
+
+		f.now = f.now.Add(time.Hour)
+		assert.Equal(t, 1, f.evaluate(t))
+		alerts := f.alerts(t)
+		require.Len(t, alerts, 1)
+		assert.Equal(t, entityID, alerts[0].EntityID)
+		assert.Nil(t, alerts[0].RealizationID)
+		assert.Equal(t, "No Feed logged in 4h 30m", alerts[0].Message)
+
+		f.realization(t, f.feedID, entityID, domain.StatusInProgress, 0)
+		f.evaluate(t)
+		assert.Empty(t, f.alerts(t), "a feed is under way")
+	})
+
+	t.Run("Counts a no-activity threshold from when the rule was made", func(t *testing.T) {
+		f := newFixture(t)
+		entityID := uuid.New()
+		f.realization(t, f.feedID, entityID, domain.StatusCompleted, 24*time.Hour)
+		f.rule(t, alert.RuleInput{Kind: alert.KindNoActivity, DefinitionID: f.feedID, EntityID: entityID, Threshold: 4 * time.Hour})
+
+		assert.Equal(t, 0, f.evaluate(t))
+		f.now = f.now.Add(4 * time.Hour)
+		assert.Equal(t, 1, f.evaluate(t))
+	})
+
+	t.Run("Raises a snoozed alert again once the snooze is over", func(t *testing.T) {
+		f := newFixture(t)
+		f.rule(t, alert.RuleInput{
+			Kind: alert.KindRunningLong, DefinitionID: f.napID, Threshold: 3 * time.Hour,
+			Channels: []alert.Channel{alert.ChannelWebhook},
+		})
+		f.realization(t, f.napID, uuid.New(), domain.StatusInProgress, 3*time.Hour)
+		f.evaluate(t)
+		a := f.alerts(t)[0]
+
+		snoozed, err := f.service.Snooze(f.ctx, a.ID, f.now.Add(30*time.Minute))
+		require.NoError(t, err)
+		assert.Equal(t, alert.StatusSnoozed, snoozed.Status)
+
+		f.now = f.now.Add(20 * time.Minute)
+		assert.Equal(t, 0, f.evaluate(t))
+		assert.Equal(t, alert.StatusSnoozed, f.alerts(t)[0].Status)
+
+		f.now = f.now.Add(10 * time.Minute)
+		assert.Equal(t, 1, f.evaluate(t))
+		alerts := f.alerts(t)
+		require.Len(t, alerts, 1)
+		assert.Equal(t, a.ID, alerts[0].ID)
+		assert.Equal(t, alert.StatusOpen, alerts[0].Status)
+		assert.Nil(t, alerts[0].SnoozedUntil)
+		assert.Equal(t, "Nap has been going on for 3h 30m", alerts[0].Message)
+		assert.Len(t, f.webhooks.notified(), 2)
+	})
+
+	t.Run("Keeps an acknowledged alert quiet", func(t *testing.T) {
+		f := newFixture(t)
+		f.rule(t, alert.RuleInput{
+			Kind: alert.KindRunningLong, DefinitionID: f.napID, Threshold: 3 * time.Hour,
+			Channels: []alert.Channel{alert.ChannelWebhook},
+		})
+		f.realization(t, f.napID, uuid.New(), domain.StatusInProgress, 3*time.Hour)
+		f.evaluate(t)
+		a := f.alerts(t)[0]
+
+		acknowledged, err := f.service.Acknowledge(f.ctx, a.ID)
+		require.NoError(t, err)
+		assert.Equal(t, alert.StatusAcknowledged, acknowledged.Status)
+		assert.Equal(t, f.now, *acknowledged.AcknowledgedAt)
+
+		f.now = f.now.Add(24 * time.Hour)
+		assert.Equal(t, 0, f.evaluate(t))
+		assert.Equal(t, alert.StatusAcknowledged, f.alerts(t)[0].Status)
+		assert.Len(t, f.webhooks.notified(), 1)
+	})
+
+	t.Run("Keeps the alert when a notifier fails", func(t *testing.T) {
+		f := newFixture(t)
+		f.webhooks.err = errors.New("receiver is down")
+		f.rule(t, alert.RuleInput{
+			Kind: alert.KindRunningLong, DefinitionID: f.napID, Threshold: 3 * time.Hour,
+			Channels: []alert.Channel{alert.ChannelWebhook},
+		})
+		f.realization(t, f.napID, uuid.New(), domain.StatusInProgress, 3*time.Hour)
+
+		assert.Equal(t, 1, f.evaluate(t))
+		assert.Len(t, f.alerts(t), 1)
+	})
+
+	t.Run("Checks each family against its own realizations", func(t *testing.T) {
+		f := newFixture(t)
+		f.rule(t, alert.RuleInput{Kind: alert.KindRunningLong, DefinitionID: f.napID, Threshold: time.Hour})
+
+		other := context.WithValue(context.Background(), middleware.FamilyIDKey, uuid.New())
+		nap, err := f.definitions.GetOrCreateByName(other, "Nap")
+		require.NoError(t, err)
+		startedAt := f.now.Add(-2 * time.Hour)
+		require.NoError(t, f.realizations.CreateRealization(other, &domain.ActivityRealization{
+			ID: uuid.New(), DefinitionID: nap.ID, EntityID: uuid.New(),
+			Status: domain.StatusInProgress, StartedAt: &startedAt, Version: 1,
+		}))
+
+		assert.Equal(t, 0, f.evaluate(t))
+	})
+
+	t.Run("A failing family does not hold up the others", func(t *testing.T) {
+		f := newFixture(t)
+		f.rule(t, alert.RuleInput{Kind: alert.KindRunningLong, DefinitionID: f.napID, Threshold: time.Hour})
+		f.realization(t, f.napID, uuid.New(), domain.StatusInProgress, 2*time.Hour)
+
+		brokenID := uuid.New()
+		broken := context.WithValue(context.Background(), middleware.FamilyIDKey, brokenID)
+		nap, err := f.definitions.GetOrCreateByName(broken, "Nap")
+		require.NoError(t, err)
+		_, err = f.service.CreateRule(broken, alert.RuleInput{Kind: alert.KindRunningLong, DefinitionID: nap.ID, Threshold: time.Hour})
+		require.NoError(t, err)
+
+		definitions := &failingDefinitions{InMemoryDefintionRepo: f.definitions, families: []uuid.UUID{brokenID}}
+		evaluator := alert.NewEvaluator(f.store, f.realizations, definitions, f.clock, f.webhooks)
+		raised, err := evaluator.Evaluate(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, 1, raised)
+
+		definitions.families = append(definitions.families, f.ctx.Value(middleware.FamilyIDKey).(uuid.UUID))
+		_, err = evaluator.Evaluate(context.Background())
+		assert.ErrorContains(t, err, "connection reset", "fails when no family could be evaluated")
+	})
+}
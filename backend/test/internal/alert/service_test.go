@@ -0,0 +1,120 @@
+package alert_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/luisteixeira/waypoint/backend/internal/alert"
+	"github.com/luisteixeira/waypoint/backend/internal/domain"
+	"github.com/luisteixeira/waypoint/backend/internal/repository/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestService(t *testing.T) {
+	t.Run("Validates new rules", func(t *testing.T) {
+		f := newFixture(t)
+		email := []alert.Channel{alert.ChannelEmail}
+		webhook := []alert.Channel{alert.ChannelWebhook}
+
+		tests := []struct {
+			name  string
+			input alert.RuleInput
+			field string
+		}{
+			{"Unknown kind", alert.RuleInput{Kind: "late", DefinitionID: f.napID, Threshold: time.Hour}, "kind"},
+			{"No definition", alert.RuleInput{Kind: alert.KindRunningLong, Threshold: time.Hour}, "definition_id"},
+			{"Unknown definition", alert.RuleInput{Kind: alert.KindRunningLong, DefinitionID: uuid.New(), Threshold: time.Hour}, "definition_id"},
+			{"Unknown child", alert.RuleInput{Kind: alert.KindRunningLong, DefinitionID: f.napID, EntityID: uuid.New(), Threshold: time.Hour}, "entity_id"},
+			{"No child for no_activity", alert.RuleInput{Kind: alert.KindNoActivity, DefinitionID: f.feedID, Threshold: time.Hour}, "entity_id"},
+			{"Threshold too short", alert.RuleInput{Kind: alert.KindRunningLong, DefinitionID: f.napID, Threshold: 30 * time.Second}, "threshold_minutes"},
+			{"Threshold too long", alert.RuleInput{Kind: alert.KindRunningLong, DefinitionID: f.napID, Threshold: 8 * 24 * time.Hour}, "threshold_minutes"},
+			{"Unavailable channel", alert.RuleInput{Kind: alert.KindRunningLong, DefinitionID: f.napID, Threshold: time.Hour, Channels: email, Email: "a@example.com"}, "channels"},
+			{"Email without the email channel", alert.RuleInput{Kind: alert.KindRunningLong, DefinitionID: f.napID, Threshold: time.Hour, Channels: webhook, Email: "a@example.com"}, "email"},
+		}
+		for _, tt := range tests {
+			t.Run(tt.name, func(t *testing.T) {
+				_, err := f.service.CreateRule(f.ctx, tt.input)
+				var validation *domain.ValidationError
+				require.ErrorAs(t, err, &validation)
+				assert.Equal(t, tt.field, validation.Field)
+			})
+		}
+
+		rules, err := f.service.Rules(f.ctx)
+		require.NoError(t, err)
+		assert.Empty(t, rules)
+	})
+
+	t.Run("Requires an address for the email channel", func(t *testing.T) {
+		f := newFixture(t)
+		svc := alert.NewService(f.store, f.definitions, memory.NewInMemoryEntityRepo(f.realizations), f.clock, &notifier{channel: alert.ChannelEmail})
+		input := alert.RuleInput{Kind: alert.KindRunningLong, DefinitionID: f.napID, Threshold: time.Hour, Channels: []alert.Channel{alert.ChannelEmail}}
+
+		_, err := svc.CreateRule(f.ctx, input)
+		var validation *domain.ValidationError
+		require.ErrorAs(t, err, &validation)
+		assert.Equal(t, "email", validation.Field)
+
+		input.Email = "Parent <parent@example.com>"
+		input.Channels = append(input.Channels, alert.ChannelEmail)
+		rule, err := svc.CreateRule(f.ctx, input)
+		require.NoError(t, err)
+		assert.Equal(t, []alert.Channel{alert.ChannelEmail}, rule.Channels)
+		assert.Equal(t, "parent@example.com", rule.Email, "only the address is kept")
+	})
+
+	t.Run("Deletes a rule with its alerts", func(t *testing.T) {
+		f := newFixture(t)
+		rule := f.rule(t, alert.RuleInput{Kind: alert.KindRunningLong, DefinitionID: f.napID, Threshold: time.Hour})
+		f.realization(t, f.napID, uuid.New(), domain.StatusInProgress, 2*time.Hour)
+		f.evaluate(t)
+		require.Len(t, f.alerts(t), 1)
+
+		require.NoError(t, f.service.DeleteRule(f.ctx, rule.ID))
+		assert.Empty(t, f.alerts(t))
+		assert.ErrorIs(t, f.service.DeleteRule(f.ctx, rule.ID), domain.ErrNotFound)
+	})
+
+	t.Run("Validates alert queries", func(t *testing.T) {
+		f := newFixture(t)
+		_, err := f.service.Alerts(f.ctx, []alert.Status{"dismissed"}, 0)
+		assert.ErrorContains(t, err, "status")
+		_, err = f.service.Alerts(f.ctx, nil, alert.MaxAlertLimit+1)
+		assert.ErrorContains(t, err, "limit")
+	})
+
+	t.Run("Only snoozes until a later time", func(t *testing.T) {
+		f := newFixture(t)
+		f.rule(t, alert.RuleInput{Kind: alert.KindRunningLong, DefinitionID: f.napID, Threshold: time.Hour})
+		f.realization(t, f.napID, uuid.New(), domain.StatusInProgress, 2*time.Hour)
+		f.evaluate(t)
+		a := f.alerts(t)[0]
+
+		_, err := f.service.Snooze(f.ctx, a.ID, f.now)
+		var validation *domain.ValidationError
+		require.ErrorAs(t, err, &validation)
+		assert.Equal(t, "until", validation.Field)
+
+		_, err = f.service.Snooze(f.ctx, uuid.New(), f.now.Add(time.Hour))
+		assert.ErrorIs(t, err, domain.ErrNotFound)
+	})
+
+	t.Run("Leaves resolved alerts alone", func(t *testing.T) {
+		f := newFixture(t)
+		f.rule(t, alert.RuleInput{Kind: alert.KindNotStarted, DefinitionID: f.napID, Threshold: 15 * time.Minute})
+		nap := f.plan(t, f.napID, uuid.New(), time.Hour)
+		f.evaluate(t)
+		a := f.alerts(t)[0]
+
+		_, err := f.activities.StartActivity(f.ctx, domain.StartActivityInput{RealizationID: nap.ID})
+		require.NoError(t, err)
+		f.evaluate(t)
+
+		_, err = f.service.Acknowledge(f.ctx, a.ID)
+		assert.ErrorIs(t, err, domain.ErrConflict)
+		_, err = f.service.Snooze(f.ctx, a.ID, f.now.Add(time.Hour))
+		assert.ErrorIs(t, err, domain.ErrConflict)
+	})
+}
@@ -0,0 +1,237 @@
+package config_test
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/luisteixeira/waypoint/backend/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// isolate unsets the variables Load reads so the host environment (CI sets
+// DB_*) does not leak into the tests.
+func isolate(t *testing.T) {
+	for _, name := range []string{
+		"WAYPOINT_CONFIG", "SERVER_ADDR", "SERVER_READ_TIMEOUT", "STORAGE_BACKEND", "SQLITE_PATH",
+		"DATABASE_URL", "DB_HOST", "DB_PORT", "DB_USER", "DB_PASSWORD", "DB_NAME", "DB_SSLMODE",
+		"AUTO_MIGRATE", "IDEMPOTENCY_TTL", "SYNC_MAX_CLIENT_AGE", "SYNC_MAX_CLIENT_AHEAD", "OUTBOX_RETENTION", "WEBHOOK_MAX_ATTEMPTS", "MQTT_BROKER", "MQTT_TOPIC_PREFIX", "ALERTS_EVALUATE_INTERVAL", "ALERTS_SMTP_ADDR", "ALERTS_SMTP_FROM", "PUSH_VAPID_PUBLIC_KEY", "PUSH_VAPID_PRIVATE_KEY", "PUSH_SUBJECT", "TRACING_EXPORTER", "TRACING_SAMPLE_RATIO", "LOG_LEVEL", "LOG_FORMAT", "TEST_FAMILY_ID", "TEST_ENTITY_ID",
+	} {
+		t.Setenv(name, "")
+		os.Unsetenv(name)
+	}
+}
+
+func writeFile(t *testing.T, name, content string) string {
+	path := filepath.Join(t.TempDir(), name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+	return path
+}
+
+func TestLoad(t *testing.T) {
+	t.Run("Defaults need only the database credentials", func(t *testing.T) {
+		isolate(t)
+		t.Setenv("DB_USER", "waypoint")
+		t.Setenv("DB_NAME", "waypoint")
+
+		cfg, args, err := config.Load(nil)
+		require.NoError(t, err)
+		assert.Empty(t, args)
+		assert.Equal(t, ":8080", cfg.Server.Addr)
+		assert.Equal(t, "postgres", cfg.Storage.Backend)
+		assert.Equal(t, "postgres://waypoint:@localhost:5432/waypoint?sslmode=disable", cfg.Database.ConnString())
+	})
+
+	t.Run("Reads YAML", func(t *testing.T) {
+		isolate(t)
+		path := writeFile(t, "waypoint.yaml", `
+server:
+  addr: ":9000"
+  read_timeout: 3s
+storage:
+  backend: sqlite
+  sqlite_path: /var/lib/waypoint.db
+`)
+
+		cfg, _, err := config.Load([]string{"-config", path})
+		require.NoError(t, err)
+		assert.Equal(t, ":9000", cfg.Server.Addr)
+		assert.Equal(t, 3*time.Second, cfg.Server.ReadTimeout)
+		assert.Equal(t, 10*time.Second, cfg.Server.WriteTimeout, "unset keys keep their default")
+		assert.Equal(t, "/var/lib/waypoint.db", cfg.Storage.SQLitePath)
+	})
+
+	t.Run("Reads TOML", func(t *testing.T) {
+		isolate(t)
+		path := writeFile(t, "waypoint.toml", `
+[storage]
+backend = "memory"
+snapshot_interval = "1m"
+`)
+
+		cfg, _, err := config.Load([]string{"-config", path, "-tracing-sample-ratio", "0.25"})
+		require.NoError(t, err)
+		assert.Equal(t, "memory", cfg.Storage.Backend)
+		assert.Equal(t, time.Minute, cfg.Storage.SnapshotInterval)
+		assert.Equal(t, 0.25, cfg.Tracing.SampleRatio)
+	})
+
+	t.Run("Rejects unknown keys", func(t *testing.T) {
+		isolate(t)
+		yamlPath := writeFile(t, "waypoint.yaml", "storage:\n  backnd: memory\n")
+		_, _, err := config.Load([]string{"-config", yamlPath})
+		assert.ErrorContains(t, err, "backnd")
+
+		tomlPath := writeFile(t, "waypoint.toml", "[storage]\nbacknd = \"memory\"\n")
+		_, _, err = config.Load([]string{"-config", tomlPath})
+		assert.ErrorContains(t, err, "backnd")
+	})
+
+	t.Run("Flags override the environment, which overrides the file", func(t *testing.T) {
+		isolate(t)
+		path := writeFile(t, "waypoint.yaml", "server:\n  addr: \":7000\"\n  read_timeout: 7s\nstorage:\n  backend: memory\n")
+		t.Setenv("WAYPOINT_CONFIG", path)
+		t.Setenv("SERVER_ADDR", ":7001")
+		t.Setenv("SERVER_READ_TIMEOUT", "8s")
+
+		cfg, args, err := config.Load([]string{"-addr", ":7002", "migrate", "up"})
+		require.NoError(t, err)
+		assert.Equal(t, ":7002", cfg.Server.Addr)
+		assert.Equal(t, 8*time.Second, cfg.Server.ReadTimeout)
+		assert.Equal(t, []string{"migrate", "up"}, args)
+	})
+
+	t.Run("Rejects malformed values", func(t *testing.T) {
+		isolate(t)
+		t.Setenv("SERVER_READ_TIMEOUT", "soon")
+		_, _, err := config.Load([]string{"-storage", "memory"})
+		assert.ErrorContains(t, err, "SERVER_READ_TIMEOUT")
+
+		isolate(t)
+		_, _, err = config.Load([]string{"-storage", "memory", "-db-port", "five"})
+		assert.Error(t, err)
+	})
+}
+
+func TestValidate(t *testing.T) {
+	t.Run("Reports every invalid field", func(t *testing.T) {
+		cfg := config.Default()
+		cfg.Server.Addr = ""
+		cfg.Server.TLS.KeyFile = "server.key"
+		cfg.Database.SSLMode = "sometimes"
+		cfg.Demo.FamilyID = "not-a-uuid"
+		cfg.Tracing.Exporter = "zipkin"
+		cfg.Tracing.SampleRatio = 1.5
+		cfg.Idempotency.TTL = 0
+		cfg.Sync.MaxClientAge = -time.Hour
+		cfg.Outbox.Retention = 0
+		cfg.Webhooks.MaxAttempts = 0
+		cfg.Alerts.EvaluateInterval = 0
+
+		err := cfg.Validate()
+		var errs config.ValidationErrors
+		require.ErrorAs(t, err, &errs)
+
+		var fields []string
+		for _, fe := range errs {
+			fields = append(fields, fe.Field)
+		}
+		assert.ElementsMatch(t, []string{
+			"server.addr", "server.tls.cert_file", "database.user", "database.name",
+			"database.sslmode", "demo.family_id",
+			"idempotency.ttl", "sync.max_client_age", "outbox.retention", "webhooks.max_attempts", "alerts.evaluate_interval", "tracing.exporter", "tracing.sample_ratio",
+		}, fields)
+	})
+
+	t.Run("Rejects an unknown backend", func(t *testing.T) {
+		cfg := config.Default()
+		cfg.Storage.Backend = "mongo"
+		assert.ErrorContains(t, cfg.Validate(), "storage.backend")
+	})
+
+	t.Run("A DSN replaces the individual database fields", func(t *testing.T) {
+		cfg := config.Default()
+		cfg.Database.DSN = "postgres://waypoint:secret@db/waypoint?sslmode=require"
+		require.NoError(t, cfg.Validate())
+		assert.Equal(t, cfg.Database.DSN, cfg.Database.ConnString())
+	})
+
+	t.Run("Idle connections cannot exceed open connections", func(t *testing.T) {
+		cfg := config.Default()
+		cfg.Storage.Backend = "memory"
+		cfg.Database.MaxOpenConns = 2
+		cfg.Database.MaxIdleConns = 5
+		// The database is only validated for the postgres backend.
+		require.NoError(t, cfg.Validate())
+
+		cfg.Storage.Backend = "postgres"
+		cfg.Database.DSN = "postgres://db/waypoint"
+		assert.ErrorContains(t, cfg.Validate(), "database.max_idle_conns")
+	})
+
+	t.Run("The MQTT bridge is only validated when enabled", func(t *testing.T) {
+		cfg := config.Default()
+		cfg.Database.DSN = "postgres://db/waypoint"
+		cfg.MQTT.TopicPrefix = "home/#"
+		require.NoError(t, cfg.Validate())
+
+		cfg.MQTT.Broker = "localhost:1883"
+		cfg.MQTT.QoS = 3
+		var errs config.ValidationErrors
+		require.ErrorAs(t, cfg.Validate(), &errs)
+		var fields []string
+		for _, fe := range errs {
+			fields = append(fields, fe.Field)
+		}
+		assert.ElementsMatch(t, []string{"mqtt.broker", "mqtt.topic_prefix", "mqtt.qos"}, fields)
+
+		cfg.MQTT.Broker = "tcp://localhost:1883"
+		cfg.MQTT.TopicPrefix = "home/waypoint"
+		cfg.MQTT.QoS = 1
+		require.NoError(t, cfg.Validate())
+	})
+
+	t.Run("The SMTP relay is only validated when set", func(t *testing.T) {
+		cfg := config.Default()
+		cfg.Database.DSN = "postgres://db/waypoint"
+		cfg.Alerts.SMTPFrom = "waypoint"
+		require.NoError(t, cfg.Validate())
+
+		cfg.Alerts.SMTPAddr = "localhost"
+		var errs config.ValidationErrors
+		require.ErrorAs(t, cfg.Validate(), &errs)
+		var fields []string
+		for _, fe := range errs {
+			fields = append(fields, fe.Field)
+		}
+		assert.ElementsMatch(t, []string{"alerts.smtp_addr", "alerts.smtp_from"}, fields)
+
+		cfg.Alerts.SMTPAddr = "localhost:25"
+		cfg.Alerts.SMTPFrom = "Waypoint <alerts@example.com>"
+		require.NoError(t, cfg.Validate())
+	})
+
+	t.Run("Push settings are only validated with a private key", func(t *testing.T) {
+		cfg := config.Default()
+		cfg.Database.DSN = "postgres://db/waypoint"
+		cfg.Push.Subject = "admin@example.com"
+		cfg.Push.TTL = 0
+		require.NoError(t, cfg.Validate())
+
+		cfg.Push.VAPIDPrivateKey = "private"
+		var errs config.ValidationErrors
+		require.ErrorAs(t, cfg.Validate(), &errs)
+		var fields []string
+		for _, fe := range errs {
+			fields = append(fields, fe.Field)
+		}
+		assert.ElementsMatch(t, []string{"push.vapid_public_key", "push.subject", "push.ttl"}, fields)
+
+		cfg.Push.VAPIDPublicKey = "public"
+		cfg.Push.Subject = "mailto:admin@example.com"
+		cfg.Push.TTL = time.Hour
+		require.NoError(t, cfg.Validate())
+	})
+}
@@ -0,0 +1,149 @@
+package domain_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/luisteixeira/waypoint/backend/internal/domain"
+	"github.com/luisteixeira/waypoint/backend/internal/repository/memory"
+	"github.com/luisteixeira/waypoint/backend/internal/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCanTransition(t *testing.T) {
+	allowed := []struct{ from, to domain.ActivityStatus }{
+		{domain.StatusPlanned, domain.StatusInProgress},
+		{domain.StatusPlanned, domain.StatusCancelled},
+		{domain.StatusInProgress, domain.StatusPaused},
+		{domain.StatusInProgress, domain.StatusCompleted},
+		{domain.StatusInProgress, domain.StatusCancelled},
+		{domain.StatusPaused, domain.StatusInProgress},
+		{domain.StatusPaused, domain.StatusCompleted},
+		{domain.StatusPaused, domain.StatusCancelled},
+	}
+	for _, tc := range allowed {
+		assert.True(t, domain.CanTransition(tc.from, tc.to), "%s -> %s", tc.from, tc.to)
+	}
+
+	denied := []struct{ from, to domain.ActivityStatus }{
+		{domain.StatusPlanned, domain.StatusCompleted},
+		{domain.StatusPlanned, domain.StatusPaused},
+		{domain.StatusCompleted, domain.StatusInProgress},
+		{domain.StatusCancelled, domain.StatusInProgress},
+		{domain.StatusCompleted, domain.StatusCancelled},
+	}
+	for _, tc := range denied {
+		assert.False(t, domain.CanTransition(tc.from, tc.to), "%s -> %s", tc.from, tc.to)
+	}
+}
+
+func TestStateMachine_Transition(t *testing.T) {
+	ctx := context.Background()
+	at := time.Date(2025, 3, 1, 14, 0, 0, 0, time.UTC)
+	save := func(context.Context, domain.RealizationEvent) error { return nil }
+	runNow := func(ctx context.Context, fn func()) { fn() }
+
+	newPlanned := func() *domain.ActivityRealization {
+		return &domain.ActivityRealization{ID: uuid.New(), Status: domain.StatusPlanned}
+	}
+
+	t.Run("Stamps timestamps and records the matching event", func(t *testing.T) {
+		m := domain.NewStateMachine(runNow)
+		ar := newPlanned()
+
+		started, err := m.Transition(ctx, ar, domain.StatusInProgress, at, save)
+		require.NoError(t, err)
+		assert.Equal(t, domain.EventStarted, started.Type)
+		assert.Equal(t, domain.StatusInProgress, ar.Status)
+		assert.Equal(t, at, *ar.StartedAt)
+
+		finishedAt := at.Add(time.Hour)
+		completed, err := m.Transition(ctx, ar, domain.StatusCompleted, finishedAt, save)
+		require.NoError(t, err)
+		assert.Equal(t, domain.EventCompleted, completed.Type)
+		assert.Equal(t, finishedAt, *ar.FinishedAt)
+	})
+
+	t.Run("Rejects transitions missing from the table", func(t *testing.T) {
+		m := domain.NewStateMachine(runNow)
+		ar := newPlanned()
+
+		_, err := m.Transition(ctx, ar, domain.StatusPaused, at, save)
+		assert.ErrorIs(t, err, domain.ErrInvalidTransition)
+		assert.Equal(t, domain.StatusPlanned, ar.Status)
+	})
+
+	t.Run("Before hooks can veto a transition", func(t *testing.T) {
+		m := domain.NewStateMachine(runNow)
+		veto := errors.New("not now")
+		m.Before(func(ctx context.Context, change domain.StateChange) error {
+			assert.Equal(t, domain.StatusPlanned, change.Realization.Status)
+			return veto
+		})
+		saved := false
+
+		ar := newPlanned()
+		_, err := m.Transition(ctx, ar, domain.StatusInProgress, at, func(context.Context, domain.RealizationEvent) error {
+			saved = true
+			return nil
+		})
+		assert.ErrorIs(t, err, veto)
+		assert.False(t, saved)
+		assert.Equal(t, domain.StatusPlanned, ar.Status)
+	})
+
+	t.Run("After hooks only run once the transition is saved", func(t *testing.T) {
+		m := domain.NewStateMachine(runNow)
+		var seen []domain.StateChange
+		m.After(func(ctx context.Context, change domain.StateChange) {
+			seen = append(seen, change)
+		})
+
+		ar := newPlanned()
+		_, err := m.Transition(ctx, ar, domain.StatusInProgress, at, func(context.Context, domain.RealizationEvent) error {
+			return errors.New("disk full")
+		})
+		assert.Error(t, err)
+		assert.Empty(t, seen)
+		assert.Equal(t, domain.StatusPlanned, ar.Status, "failed saves leave the realization untouched")
+
+		_, err = m.Transition(ctx, ar, domain.StatusCancelled, at, save)
+		require.NoError(t, err)
+		require.Len(t, seen, 1)
+		assert.Equal(t, domain.StatusPlanned, seen[0].From)
+		assert.Equal(t, domain.StatusCancelled, seen[0].To)
+		assert.Equal(t, domain.StatusCancelled, seen[0].Realization.Status)
+	})
+
+	t.Run("After hooks wait for the unit of work to commit", func(t *testing.T) {
+		m := domain.NewStateMachine(service.AfterCommit)
+		tx := memory.NewTxManager()
+		var seen []domain.StateChange
+		m.After(func(ctx context.Context, change domain.StateChange) {
+			seen = append(seen, change)
+		})
+
+		failure := errors.New("later operation failed")
+		err := tx.WithinTx(ctx, func(ctx context.Context) error {
+			if _, err := m.Transition(ctx, newPlanned(), domain.StatusInProgress, at, save); err != nil {
+				return err
+			}
+			return failure
+		})
+		require.ErrorIs(t, err, failure)
+		assert.Empty(t, seen, "a unit of work that rolls back runs no after hooks")
+
+		err = tx.WithinTx(ctx, func(ctx context.Context) error {
+			_, err := m.Transition(ctx, newPlanned(), domain.StatusInProgress, at, save)
+			assert.Empty(t, seen, "after hooks run only once the unit of work commits")
+			return err
+		})
+		require.NoError(t, err)
+		require.Len(t, seen, 1)
+		assert.Equal(t, domain.StatusInProgress, seen[0].Realization.Status)
+	})
+}
@@ -7,14 +7,17 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/google/uuid"
 	"github.com/luisteixeira/waypoint/backend/internal/domain"
 	"github.com/luisteixeira/waypoint/backend/internal/handler"
 	"github.com/luisteixeira/waypoint/backend/internal/middleware"
+	"github.com/luisteixeira/waypoint/backend/internal/outbox"
+	"github.com/luisteixeira/waypoint/backend/internal/repository/memory"
 	"github.com/luisteixeira/waypoint/backend/internal/service"
-	"github.com/luisteixeira/waypoint/backend/test/internal/repository/memory"
+	"github.com/luisteixeira/waypoint/backend/internal/webhook"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
@@ -30,6 +33,7 @@ func TestActivityHandler_PlanAndStart(t *testing.T) {
 		payload := map[string]interface{}{
 			"entity_id":           entityID,
 			"new_definition_name": "Afternoon nap",
+			"planned_for":         "2024-05-01T14:00:00+01:00",
 		}
 		body, _ := json.Marshal(payload)
 
@@ -46,6 +50,8 @@ func TestActivityHandler_PlanAndStart(t *testing.T) {
 		err := json.Unmarshal(w.Body.Bytes(), &response)
 		require.NoError(t, err)
 		assert.Equal(t, domain.StatusPlanned, response.Status)
+		require.NotNil(t, response.PlannedFor)
+		assert.True(t, time.Date(2024, 5, 1, 13, 0, 0, 0, time.UTC).Equal(*response.PlannedFor))
 		plannedID = response.ID
 	})
 
@@ -173,21 +179,128 @@ func TestActivityHandler_FullLifecycle(t *testing.T) {
 	})
 }
 
+func TestActivityHandler_IfMatch(t *testing.T) {
+	router := setupTestRouter()
+	familyID := uuid.New().String()
+
+	payload, _ := json.Marshal(map[string]interface{}{
+		"entity_id":           uuid.New(),
+		"new_definition_name": "Nap",
+	})
+	request := httptest.NewRequest("POST", "/api/v1/activities/start", bytes.NewBuffer(payload))
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("X-Family-ID", familyID)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, request)
+	require.Equal(t, http.StatusCreated, w.Code)
+
+	etag := w.Header().Get("ETag")
+	require.NotEmpty(t, etag)
+
+	var started domain.ActivityRealization
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &started))
+
+	t.Run("Complete with the current ETag", func(t *testing.T) {
+		url := fmt.Sprintf("/api/v1/activities/%s/complete", started.ID)
+		request := httptest.NewRequest("POST", url, nil)
+		request.Header.Set("X-Family-ID", familyID)
+		request.Header.Set("If-Match", etag)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, request)
+
+		assert.Equal(t, http.StatusNoContent, w.Code)
+		assert.NotEqual(t, etag, w.Header().Get("ETag"))
+	})
+
+	t.Run("Cancel with a stale ETag returns the current state", func(t *testing.T) {
+		url := fmt.Sprintf("/api/v1/activities/%s/cancel", started.ID)
+		request := httptest.NewRequest("POST", url, nil)
+		request.Header.Set("X-Family-ID", familyID)
+		request.Header.Set("If-Match", etag)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, request)
+
+		assert.Equal(t, http.StatusConflict, w.Code)
+
+		var response struct {
+			Current domain.ActivityRealization `json:"current"`
+		}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, domain.StatusCompleted, response.Current.Status)
+	})
+
+	t.Run("Unknown activity returns not found", func(t *testing.T) {
+		url := fmt.Sprintf("/api/v1/activities/%s/complete", uuid.New())
+		request := httptest.NewRequest("POST", url, nil)
+		request.Header.Set("X-Family-ID", familyID)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, request)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("Starting an unknown planned activity returns a single not found problem", func(t *testing.T) {
+		payload, _ := json.Marshal(map[string]interface{}{
+			"realization_id": uuid.New(),
+			"entity_id":      uuid.New(),
+		})
+		request := httptest.NewRequest("POST", "/api/v1/activities/start", bytes.NewBuffer(payload))
+		request.Header.Set("Content-Type", "application/json")
+		request.Header.Set("X-Family-ID", familyID)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, request)
+
+		assert.Equal(t, http.StatusNotFound, w.Code)
+		assert.Equal(t, "application/problem+json", w.Header().Get("Content-Type"))
+
+		var response map[string]interface{}
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, "/problems/not-found", response["type"])
+	})
+
+	t.Run("A request without a family is unauthorized", func(t *testing.T) {
+		request := httptest.NewRequest("GET", "/api/v1/activities/"+uuid.NewString(), nil)
+		w := httptest.NewRecorder()
+
+		router.ServeHTTP(w, request)
+
+		assert.Equal(t, http.StatusUnauthorized, w.Code)
+		assert.Contains(t, w.Body.String(), "/problems/unauthorized")
+	})
+}
+
 func setupTestRouter() *chi.Mux {
+	router, _ := setupPublishingRouter()
+	return router
+}
+
+// setupPublishingRouter also returns the dispatcher that publishes the
+// outbox events recorded by the router's requests.
+func setupPublishingRouter() (*chi.Mux, *outbox.Dispatcher) {
 	activityRepo := memory.NewInMemoryActivityRepo()
 	definitionRepo := memory.NewInMemoryDefinitionRepo()
-	svc := service.NewActivityService(activityRepo, definitionRepo)
-	handler := handler.NewActivityHandler(svc)
+	tx := memory.NewTxManager()
+	events := memory.NewInMemoryOutboxStore()
+	webhooks := memory.NewInMemoryWebhookStore()
+	svc := service.NewActivityService(outbox.NewActivityRepo(activityRepo, events, tx, time.Now), definitionRepo, tx, service.DefaultClientTimeWindow)
+	activityHandler := handler.NewActivityHandler(svc)
+	batchHandler := handler.NewBatchHandler(service.NewBatchService(svc, tx))
+	syncHandler := handler.NewSyncHandler(service.NewSyncService(memory.NewInMemoryChangeRepo(activityRepo, definitionRepo), tx))
+	webhookHandler := handler.NewWebhookHandler(webhook.NewRegistry(webhooks))
 
 	router := chi.NewRouter()
 	router.Route("/api/v1", func(r chi.Router) {
 		r.Use(middleware.TenantMiddleware)
-		r.Route("/activities", func(r chi.Router) {
-			r.Post("/plan", handler.PlanActivity)
-			r.Post("/start", handler.StartActivity)
-			r.Post("/{id}/complete", handler.CompleteActivity)
-		})
+		activityHandler.Routes(r)
+		batchHandler.Routes(r)
+		syncHandler.Routes(r)
+		webhookHandler.Routes(r)
 	})
 
-	return router
+	publisher := outbox.NewDispatcher(events, outbox.Policy{InitialBackoff: time.Second, MaxBackoff: time.Second}, time.Now, webhook.NewSink(webhooks))
+	return router, publisher
 }
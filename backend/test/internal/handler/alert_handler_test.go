@@ -0,0 +1,161 @@
+package handler_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/luisteixeira/waypoint/backend/internal/alert"
+	"github.com/luisteixeira/waypoint/backend/internal/domain"
+	"github.com/luisteixeira/waypoint/backend/internal/handler"
+	"github.com/luisteixeira/waypoint/backend/internal/middleware"
+	"github.com/luisteixeira/waypoint/backend/internal/repository/memory"
+	"github.com/luisteixeira/waypoint/backend/internal/service"
+	"github.com/luisteixeira/waypoint/backend/internal/webhook"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAlertHandler(t *testing.T) {
+	activities, definitions, tx := memory.NewInMemoryActivityRepo(), memory.NewInMemoryDefinitionRepo(), memory.NewTxManager()
+	alerts, webhooks := memory.NewInMemoryAlertStore(), memory.NewInMemoryWebhookStore()
+	notifier := webhook.NewAlertNotifier(webhooks, time.Now)
+	activityHandler := handler.NewActivityHandler(service.NewActivityService(activities, definitions, tx, service.DefaultClientTimeWindow))
+	webhookHandler := handler.NewWebhookHandler(webhook.NewRegistry(webhooks))
+	alertHandler := handler.NewAlertHandler(alert.NewService(alerts, definitions, memory.NewInMemoryEntityRepo(activities), time.Now, notifier))
+
+	// The evaluator runs ahead of the wall clock by offset.
+	var offset time.Duration
+	evaluator := alert.NewEvaluator(alerts, activities, definitions, func() time.Time { return time.Now().Add(offset) }, notifier)
+
+	router := chi.NewRouter()
+	router.Route("/api/v1", func(r chi.Router) {
+		r.Use(middleware.TenantMiddleware)
+		activityHandler.Routes(r)
+		webhookHandler.Routes(r)
+		alertHandler.Routes(r)
+	})
+
+	familyID := uuid.NewString()
+	send := func(method, target, body string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(method, target, strings.NewReader(body))
+		request.Header.Set("Content-Type", "application/json")
+		request.Header.Set("X-Family-ID", familyID)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, request)
+		return w
+	}
+
+	w := send("POST", "/api/v1/activities/start", `{"entity_id":"`+uuid.NewString()+`","new_definition_name":"Nap"}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var nap domain.ActivityRealization
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &nap))
+	w = send("POST", "/api/v1/webhooks", `{"url":"https://example.com/alerts","events":["alert.triggered"]}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var sub handler.WebhookResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &sub))
+
+	w = send("POST", "/api/v1/alert-rules",
+		`{"kind":"running_long","definition_id":"`+nap.DefinitionID.String()+`","threshold_minutes":180,"channels":["webhook"]}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var rule handler.AlertRuleResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &rule))
+	assert.Equal(t, "/api/v1/alert-rules/"+rule.ID.String(), w.Header().Get("Location"))
+	assert.Equal(t, 180, rule.Threshold)
+	assert.Nil(t, rule.EntityID)
+	assert.Equal(t, []alert.Channel{alert.ChannelWebhook}, rule.Channels)
+
+	t.Run("Lists rules", func(t *testing.T) {
+		w := send("GET", "/api/v1/alert-rules", "")
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var rules []handler.AlertRuleResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &rules))
+		require.Len(t, rules, 1)
+		assert.Equal(t, rule.ID, rules[0].ID)
+	})
+
+	t.Run("Rejects a rule for an unavailable channel", func(t *testing.T) {
+		w := send("POST", "/api/v1/alert-rules",
+			`{"kind":"running_long","definition_id":"`+nap.DefinitionID.String()+`","threshold_minutes":180,"channels":["email"],"email":"a@example.com"}`)
+		assert.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+	})
+
+	var raised alert.Alert
+	t.Run("Lists raised alerts and sends them to webhooks", func(t *testing.T) {
+		w := send("GET", "/api/v1/alerts", "")
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.JSONEq(t, `[]`, w.Body.String())
+
+		offset = 3*time.Hour + time.Minute
+		n, err := evaluator.Evaluate(context.Background())
+		require.NoError(t, err)
+		require.Equal(t, 1, n)
+
+		w = send("GET", "/api/v1/alerts?status=open&limit=10", "")
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var listed []alert.Alert
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &listed))
+		require.Len(t, listed, 1)
+		raised = listed[0]
+		assert.Equal(t, rule.ID, raised.RuleID)
+		assert.Equal(t, nap.ID, *raised.RealizationID)
+		assert.Equal(t, "Nap has been going on for 3h 1m", raised.Message)
+
+		w = send("GET", "/api/v1/webhooks/"+sub.ID.String()+"/deliveries", "")
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var deliveries []handler.DeliveryResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &deliveries))
+		require.Len(t, deliveries, 1)
+		assert.Equal(t, webhook.EventAlertTriggered, deliveries[0].EventType)
+	})
+
+	t.Run("Snoozes an alert", func(t *testing.T) {
+		until := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+		w := send("POST", "/api/v1/alerts/"+raised.ID.String()+"/snooze", `{"until":"`+until.Format(time.RFC3339)+`"}`)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var snoozed alert.Alert
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &snoozed))
+		assert.Equal(t, alert.StatusSnoozed, snoozed.Status)
+		assert.True(t, until.Equal(*snoozed.SnoozedUntil))
+
+		w = send("POST", "/api/v1/alerts/"+raised.ID.String()+"/snooze", `{"until":"2020-01-01T00:00:00Z"}`)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("Acknowledges an alert", func(t *testing.T) {
+		w := send("POST", "/api/v1/alerts/"+raised.ID.String()+"/acknowledge", "")
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var acknowledged alert.Alert
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &acknowledged))
+		assert.Equal(t, alert.StatusAcknowledged, acknowledged.Status)
+		assert.NotNil(t, acknowledged.AcknowledgedAt)
+
+		w = send("POST", "/api/v1/alerts/"+uuid.NewString()+"/acknowledge", "")
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("Rejects an invalid alert query", func(t *testing.T) {
+		w := send("GET", "/api/v1/alerts?status=dismissed", "")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		w = send("GET", "/api/v1/alerts?limit=-1", "")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("Deletes a rule", func(t *testing.T) {
+		w := send("DELETE", "/api/v1/alert-rules/"+rule.ID.String(), "")
+		assert.Equal(t, http.StatusNoContent, w.Code)
+
+		w = send("DELETE", "/api/v1/alert-rules/"+rule.ID.String(), "")
+		assert.Equal(t, http.StatusNotFound, w.Code)
+
+		w = send("GET", "/api/v1/alerts?status=open,snoozed,acknowledged,resolved", "")
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.JSONEq(t, `[]`, w.Body.String())
+	})
+}
@@ -0,0 +1,86 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/luisteixeira/waypoint/backend/internal/domain"
+	"github.com/luisteixeira/waypoint/backend/internal/handler"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBatchHandler(t *testing.T) {
+	router := setupTestRouter()
+	familyID := uuid.NewString()
+	entityID := uuid.NewString()
+
+	send := func(body string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest("POST", "/api/v1/batch", strings.NewReader(body))
+		request.Header.Set("Content-Type", "application/json")
+		request.Header.Set("X-Family-ID", familyID)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, request)
+		return w
+	}
+
+	// Offline actions are replayed some time after they happened.
+	started := time.Now().Add(-time.Hour).Truncate(time.Second).UTC()
+	at := func(offset time.Duration) string { return started.Add(offset).Format(time.RFC3339) }
+
+	t.Run("Reports the outcome of each operation", func(t *testing.T) {
+		w := send(`{"operations":[
+			{"id":"a","type":"start","client_timestamp":"` + at(0) + `","entity_id":"` + entityID + `","new_definition_name":"Playground"},
+			{"id":"b","type":"note","client_timestamp":"` + at(5*time.Minute) + `","ref":"a","text":"Swings"},
+			{"id":"c","type":"complete","client_timestamp":"` + at(30*time.Minute) + `","realization_id":"` + uuid.NewString() + `"}
+		]}`)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var response handler.BatchResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		require.Len(t, response.Results, 3)
+
+		assert.Equal(t, domain.BatchApplied, response.Results[0].Status)
+		assert.Equal(t, domain.StatusInProgress, response.Results[0].Realization.Status)
+		require.NotNil(t, response.Results[0].Realization.StartedAt)
+		assert.True(t, started.Equal(*response.Results[0].Realization.StartedAt), "started at the client timestamp")
+		assert.Equal(t, domain.BatchApplied, response.Results[1].Status)
+		assert.Equal(t, at(5*time.Minute), response.Results[1].ClientTimestamp.Format(time.RFC3339))
+
+		assert.Equal(t, domain.BatchFailed, response.Results[2].Status)
+		require.NotNil(t, response.Results[2].Error)
+		assert.Equal(t, "/problems/not-found", response.Results[2].Error.Type)
+		assert.Nil(t, response.Results[2].Realization)
+	})
+
+	t.Run("Fails operations outside the accepted clock window", func(t *testing.T) {
+		w := send(`{"operations":[
+			{"id":"a","type":"start","client_timestamp":"` + at(-30*24*time.Hour) + `","entity_id":"` + entityID + `","new_definition_name":"Nap"},
+			{"id":"b","type":"start","client_timestamp":"` + at(2*time.Hour) + `","entity_id":"` + uuid.NewString() + `","new_definition_name":"Nap"}
+		]}`)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var response handler.BatchResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		require.Len(t, response.Results, 2)
+		for _, result := range response.Results {
+			assert.Equal(t, domain.BatchFailed, result.Status)
+			require.NotNil(t, result.Error)
+			assert.Equal(t, "/problems/validation", result.Error.Type)
+		}
+	})
+
+	t.Run("Rejects a malformed batch", func(t *testing.T) {
+		w := send(`{"operations":[{"id":"a","type":"complete","client_timestamp":"` + at(0) + `"}]}`)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+
+		var response map[string]any
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Equal(t, "operations.0.realization_id", response["field"])
+	})
+}
@@ -0,0 +1,125 @@
+package handler_test
+
+import (
+	"crypto/ecdh"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/luisteixeira/waypoint/backend/internal/handler"
+	"github.com/luisteixeira/waypoint/backend/internal/middleware"
+	"github.com/luisteixeira/waypoint/backend/internal/push"
+	"github.com/luisteixeira/waypoint/backend/internal/repository/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPushHandler(t *testing.T) {
+	keys, err := push.GenerateKeys()
+	require.NoError(t, err)
+	pushHandler := handler.NewPushHandler(push.NewService(memory.NewInMemoryPushStore(), keys, time.Now))
+
+	router := chi.NewRouter()
+	router.Route("/api/v1", func(r chi.Router) {
+		r.Use(middleware.TenantMiddleware)
+		pushHandler.Routes(r)
+	})
+
+	familyID, caregiverID := uuid.NewString(), uuid.NewString()
+	send := func(method, target, caregiver, body string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(method, target, strings.NewReader(body))
+		request.Header.Set("Content-Type", "application/json")
+		request.Header.Set("X-Family-ID", familyID)
+		if caregiver != "" {
+			request.Header.Set("X-Caregiver-ID", caregiver)
+		}
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, request)
+		return w
+	}
+
+	browserKey, err := ecdh.P256().GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	auth := make([]byte, 16)
+	_, err = rand.Read(auth)
+	require.NoError(t, err)
+	subscription := `{"endpoint":"https://push.example.com/send/abc","keys":{"p256dh":"` +
+		base64.RawURLEncoding.EncodeToString(browserKey.PublicKey().Bytes()) + `","auth":"` +
+		base64.RawURLEncoding.EncodeToString(auth) + `"}}`
+
+	t.Run("Hands out the public key", func(t *testing.T) {
+		w := send("GET", "/api/v1/push/key", "", "")
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.JSONEq(t, `{"public_key":"`+keys.PublicKey()+`"}`, w.Body.String())
+	})
+
+	t.Run("Needs the caregiver", func(t *testing.T) {
+		w := send("POST", "/api/v1/push/subscriptions", "", subscription)
+		assert.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+		assert.Contains(t, w.Body.String(), "X-Caregiver-ID")
+	})
+
+	t.Run("Manages subscriptions", func(t *testing.T) {
+		w := send("POST", "/api/v1/push/subscriptions", caregiverID, subscription)
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+		var sub handler.PushSubscriptionResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &sub))
+		assert.Equal(t, "/api/v1/push/subscriptions/"+sub.ID.String(), w.Header().Get("Location"))
+		assert.Equal(t, "https://push.example.com/send/abc", sub.Endpoint)
+
+		w = send("GET", "/api/v1/push/subscriptions", caregiverID, "")
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var subs []handler.PushSubscriptionResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &subs))
+		require.Len(t, subs, 1)
+		assert.Equal(t, sub.ID, subs[0].ID)
+		assert.NotContains(t, w.Body.String(), "p256dh", "the browser's keys are not handed back")
+
+		w = send("DELETE", "/api/v1/push/subscriptions/"+sub.ID.String(), uuid.NewString(), "")
+		assert.Equal(t, http.StatusNotFound, w.Code, "another caregiver's subscription")
+		w = send("DELETE", "/api/v1/push/subscriptions/"+sub.ID.String(), caregiverID, "")
+		assert.Equal(t, http.StatusNoContent, w.Code, w.Body.String())
+		w = send("DELETE", "/api/v1/push/subscriptions/"+sub.ID.String(), caregiverID, "")
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("Rejects keys that are not a browser's", func(t *testing.T) {
+		w := send("POST", "/api/v1/push/subscriptions", caregiverID,
+			`{"endpoint":"https://push.example.com/send/abc","keys":{"p256dh":"abc","auth":"abc"}}`)
+		assert.Equal(t, http.StatusBadRequest, w.Code, w.Body.String())
+		assert.Contains(t, w.Body.String(), `"field":"keys"`)
+	})
+
+	t.Run("Manages preferences", func(t *testing.T) {
+		w := send("GET", "/api/v1/push/preferences", caregiverID, "")
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.JSONEq(t, `{"entity_ids":[],"activity_started":true,"activity_completed":true,"alerts":true,"updated_at":null}`, w.Body.String())
+
+		entityID := uuid.NewString()
+		w = send("PUT", "/api/v1/push/preferences", caregiverID,
+			`{"entity_ids":["`+entityID+`"],"activity_started":false,"activity_completed":true,"alerts":true}`)
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var saved handler.PushPreferencesResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &saved))
+		require.NotNil(t, saved.UpdatedAt)
+
+		w = send("GET", "/api/v1/push/preferences", caregiverID, "")
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var prefs handler.PushPreferencesResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &prefs))
+		assert.Equal(t, []uuid.UUID{uuid.MustParse(entityID)}, prefs.EntityIDs)
+		assert.False(t, prefs.ActivityStarted)
+		assert.True(t, prefs.ActivityCompleted)
+
+		w = send("GET", "/api/v1/push/preferences", uuid.NewString(), "")
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		assert.Contains(t, w.Body.String(), `"entity_ids":[]`, "each caregiver has their own")
+	})
+}
@@ -0,0 +1,53 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/luisteixeira/waypoint/backend/internal/handler"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSyncHandler(t *testing.T) {
+	router := setupTestRouter()
+	familyID := uuid.NewString()
+
+	send := func(method, target, body string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(method, target, strings.NewReader(body))
+		request.Header.Set("Content-Type", "application/json")
+		request.Header.Set("X-Family-ID", familyID)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, request)
+		return w
+	}
+
+	w := send("POST", "/api/v1/activities/start", `{"entity_id":"`+uuid.NewString()+`","new_definition_name":"Nap"}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+
+	t.Run("Returns the family's changes and a cursor", func(t *testing.T) {
+		w := send("GET", "/api/v1/sync", "")
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var response handler.SyncResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Len(t, response.Realizations, 1)
+		assert.Len(t, response.Definitions, 1)
+		assert.NotNil(t, response.Entities)
+		assert.NotNil(t, response.Deleted)
+
+		w = send("GET", "/api/v1/sync?cursor="+response.Cursor, "")
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &response))
+		assert.Empty(t, response.Realizations)
+	})
+
+	t.Run("Rejects a malformed limit", func(t *testing.T) {
+		w := send("GET", "/api/v1/sync?limit=none", "")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+}
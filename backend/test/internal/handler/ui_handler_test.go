@@ -0,0 +1,48 @@
+package handler_test
+
+import (
+	"encoding/json"
+	"html"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/luisteixeira/waypoint/backend/internal/handler"
+	"github.com/luisteixeira/waypoint/backend/internal/repository/memory"
+	"github.com/luisteixeira/waypoint/backend/internal/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUIHandler_ServiceWorker(t *testing.T) {
+	svc := service.NewActivityService(memory.NewInMemoryActivityRepo(), memory.NewInMemoryDefinitionRepo(), memory.NewTxManager(), service.DefaultClientTimeWindow)
+	h := handler.NewUIHandler(svc, uuid.New(), uuid.New())
+
+	w := httptest.NewRecorder()
+	h.ServiceWorker(w, httptest.NewRequest("GET", "/sw.js", nil))
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "text/javascript; charset=utf-8", w.Header().Get("Content-Type"))
+	assert.Equal(t, "no-cache", w.Header().Get("Cache-Control"))
+	assert.Equal(t, "/", w.Header().Get("Service-Worker-Allowed"))
+	assert.Contains(t, w.Body.String(), "/api/v1/batch")
+}
+
+func TestUIHandler_ShowDashboard(t *testing.T) {
+	svc := service.NewActivityService(memory.NewInMemoryActivityRepo(), memory.NewInMemoryDefinitionRepo(), memory.NewTxManager(), service.DefaultClientTimeWindow)
+	familyID := uuid.New()
+	h := handler.NewUIHandler(svc, familyID, uuid.New())
+
+	w := httptest.NewRecorder()
+	h.ShowDashboard(w, httptest.NewRequest("GET", "/", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	// htmx ignores hx-headers that are not a JSON object.
+	match := regexp.MustCompile(`hx-headers='([^']*)'`).FindStringSubmatch(w.Body.String())
+	require.Len(t, match, 2)
+	var headers map[string]string
+	require.NoError(t, json.Unmarshal([]byte(html.UnescapeString(match[1])), &headers))
+	assert.Equal(t, familyID.String(), headers["X-Family-ID"])
+}
@@ -0,0 +1,99 @@
+package handler_test
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/luisteixeira/waypoint/backend/internal/handler"
+	"github.com/luisteixeira/waypoint/backend/internal/webhook"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebhookHandler(t *testing.T) {
+	router, publisher := setupPublishingRouter()
+	familyID := uuid.NewString()
+
+	send := func(method, target, body string) *httptest.ResponseRecorder {
+		request := httptest.NewRequest(method, target, strings.NewReader(body))
+		request.Header.Set("Content-Type", "application/json")
+		request.Header.Set("X-Family-ID", familyID)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, request)
+		return w
+	}
+
+	w := send("POST", "/api/v1/webhooks", `{"url":"https://example.com/hook","events":["activity.started"],"description":"Lights"}`)
+	require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+	var created handler.WebhookResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &created))
+	assert.Equal(t, "/api/v1/webhooks/"+created.ID.String(), w.Header().Get("Location"))
+	assert.NotEmpty(t, created.Secret)
+	assert.Equal(t, "Lights", created.Description)
+
+	base := "/api/v1/webhooks/" + created.ID.String()
+
+	t.Run("Lists webhooks without their secrets", func(t *testing.T) {
+		w := send("GET", "/api/v1/webhooks", "")
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+		var listed []handler.WebhookResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &listed))
+		require.Len(t, listed, 1)
+		assert.Equal(t, created.ID, listed[0].ID)
+		assert.Equal(t, []webhook.EventType{webhook.EventActivityStarted}, listed[0].Events)
+		assert.NotContains(t, w.Body.String(), created.Secret)
+	})
+
+	t.Run("Logs deliveries and redelivers them", func(t *testing.T) {
+		w := send("POST", "/api/v1/activities/start", `{"entity_id":"`+uuid.NewString()+`","new_definition_name":"Nap"}`)
+		require.Equal(t, http.StatusCreated, w.Code, w.Body.String())
+		_, err := publisher.Dispatch(context.Background())
+		require.NoError(t, err)
+
+		w = send("GET", base+"/deliveries", "")
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var deliveries []handler.DeliveryResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &deliveries))
+		require.Len(t, deliveries, 1)
+		assert.Equal(t, webhook.EventActivityStarted, deliveries[0].EventType)
+		assert.Equal(t, webhook.DeliveryPending, deliveries[0].Status)
+		assert.NotNil(t, deliveries[0].NextAttemptAt)
+
+		w = send("POST", base+"/deliveries/"+deliveries[0].ID.String()+"/redeliver", "")
+		require.Equal(t, http.StatusAccepted, w.Code, w.Body.String())
+		var again handler.DeliveryResponse
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &again))
+		assert.Equal(t, deliveries[0].EventID, again.EventID)
+		assert.NotEqual(t, deliveries[0].ID, again.ID)
+
+		w = send("POST", base+"/deliveries/"+uuid.NewString()+"/redeliver", "")
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("Rejects an invalid webhook", func(t *testing.T) {
+		w := send("POST", "/api/v1/webhooks", `{"url":"mailto:someone@example.com","events":["activity.started"]}`)
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+	})
+
+	t.Run("Hides other families' webhooks", func(t *testing.T) {
+		request := httptest.NewRequest("GET", base+"/deliveries", nil)
+		request.Header.Set("X-Family-ID", uuid.NewString())
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, request)
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+
+	t.Run("Deletes a webhook", func(t *testing.T) {
+		w := send("DELETE", base, "")
+		assert.Equal(t, http.StatusNoContent, w.Code)
+
+		w = send("DELETE", base, "")
+		assert.Equal(t, http.StatusNotFound, w.Code)
+	})
+}
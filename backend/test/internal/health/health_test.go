@@ -0,0 +1,72 @@
+package health_test
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/luisteixeira/waypoint/backend/internal/health"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func probe(t *testing.T, handler http.HandlerFunc) (int, health.Report) {
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	var report health.Report
+	require.NoError(t, json.NewDecoder(rec.Body).Decode(&report))
+	return rec.Code, report
+}
+
+func TestHealth(t *testing.T) {
+	t.Run("Ready when every check passes", func(t *testing.T) {
+		h := health.New(time.Second)
+		h.Register("database", func(ctx context.Context) error { return nil })
+
+		code, report := probe(t, h.Ready)
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, "ok", report.Status)
+		assert.Equal(t, map[string]string{"database": "ok"}, report.Checks)
+	})
+
+	t.Run("Not ready when a check fails, but still live", func(t *testing.T) {
+		h := health.New(time.Second)
+		h.Register("database", func(ctx context.Context) error { return errors.New("connection refused") })
+		h.Register("schema", func(ctx context.Context) error { return nil })
+
+		code, report := probe(t, h.Ready)
+		assert.Equal(t, http.StatusServiceUnavailable, code)
+		assert.Equal(t, "connection refused", report.Checks["database"])
+		assert.Equal(t, "ok", report.Checks["schema"])
+
+		code, _ = probe(t, h.Live)
+		assert.Equal(t, http.StatusOK, code)
+	})
+
+	t.Run("A hung check times out", func(t *testing.T) {
+		h := health.New(10 * time.Millisecond)
+		h.Register("database", func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		})
+
+		code, report := probe(t, h.Ready)
+		assert.Equal(t, http.StatusServiceUnavailable, code)
+		assert.Contains(t, report.Checks["database"], "deadline")
+	})
+
+	t.Run("Draining fails readiness", func(t *testing.T) {
+		h := health.New(time.Second)
+		h.Drain()
+		h.Drain()
+
+		code, report := probe(t, h.Ready)
+		assert.Equal(t, http.StatusServiceUnavailable, code)
+		assert.Equal(t, "draining", report.Status)
+	})
+}
@@ -0,0 +1,227 @@
+package idempotency_test
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/luisteixeira/waypoint/backend/internal/idempotency"
+	"github.com/luisteixeira/waypoint/backend/internal/middleware"
+	"github.com/luisteixeira/waypoint/backend/internal/problem"
+	"github.com/luisteixeira/waypoint/backend/internal/repository/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// counter answers every request with a new number, so a replayed response is
+// told apart from a fresh one.
+type counter struct {
+	calls  atomic.Int32
+	status int
+}
+
+func (c *counter) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	n := c.calls.Add(1)
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("ETag", fmt.Sprintf(`"%d"`, n))
+	w.Header().Set("X-Served-By", "handler")
+	w.WriteHeader(c.status)
+	fmt.Fprintf(w, `{"call":%d}`, n)
+}
+
+func setup(next http.Handler) http.Handler {
+	return middleware.TenantMiddleware(idempotency.Middleware(memory.NewInMemoryIdempotencyStore(), time.Hour)(next))
+}
+
+func post(h http.Handler, familyID uuid.UUID, key, body string) *httptest.ResponseRecorder {
+	r := httptest.NewRequest(http.MethodPost, "/api/v1/activities/start", strings.NewReader(body))
+	r.Header.Set("Content-Type", "application/json")
+	r.Header.Set("X-Family-ID", familyID.String())
+	if key != "" {
+		r.Header.Set(idempotency.HeaderKey, key)
+	}
+	w := httptest.NewRecorder()
+	h.ServeHTTP(w, r)
+	return w
+}
+
+func TestMiddleware(t *testing.T) {
+	t.Run("Replays the first response", func(t *testing.T) {
+		c := &counter{status: http.StatusCreated}
+		h := setup(c)
+		familyID := uuid.New()
+
+		first := post(h, familyID, "key-1", `{"entity_id":"x"}`)
+		retry := post(h, familyID, "key-1", `{"entity_id":"x"}`)
+
+		assert.EqualValues(t, 1, c.calls.Load(), "the handler runs once")
+		assert.Equal(t, http.StatusCreated, retry.Code)
+		assert.Equal(t, first.Body.String(), retry.Body.String())
+		assert.Equal(t, `"1"`, retry.Header().Get("ETag"))
+		assert.Equal(t, "application/json", retry.Header().Get("Content-Type"))
+		assert.Empty(t, retry.Header().Get("X-Served-By"), "only response metadata is replayed")
+		assert.Empty(t, first.Header().Get(idempotency.HeaderReplayed))
+		assert.Equal(t, "true", retry.Header().Get(idempotency.HeaderReplayed))
+	})
+
+	t.Run("Rejects a key reused for another request", func(t *testing.T) {
+		c := &counter{status: http.StatusCreated}
+		h := setup(c)
+		familyID := uuid.New()
+
+		post(h, familyID, "key-1", `{"entity_id":"x"}`)
+		w := post(h, familyID, "key-1", `{"entity_id":"y"}`)
+
+		assert.Equal(t, http.StatusUnprocessableEntity, w.Code)
+		assert.Equal(t, problem.ContentType, w.Header().Get("Content-Type"))
+		var p problem.Problem
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &p))
+		assert.Equal(t, "/problems/idempotency-key-reused", p.Type)
+		assert.EqualValues(t, 1, c.calls.Load())
+	})
+
+	t.Run("Keys are scoped to the family", func(t *testing.T) {
+		c := &counter{status: http.StatusCreated}
+		h := setup(c)
+
+		post(h, uuid.New(), "key-1", `{}`)
+		w := post(h, uuid.New(), "key-1", `{"other":true}`)
+
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.EqualValues(t, 2, c.calls.Load())
+	})
+
+	t.Run("Requests without a key are not stored", func(t *testing.T) {
+		c := &counter{status: http.StatusCreated}
+		h := setup(c)
+		familyID := uuid.New()
+
+		post(h, familyID, "", `{}`)
+		post(h, familyID, "", `{}`)
+		assert.EqualValues(t, 2, c.calls.Load())
+	})
+
+	t.Run("Other methods pass through", func(t *testing.T) {
+		c := &counter{status: http.StatusOK}
+		h := setup(c)
+
+		for range 2 {
+			r := httptest.NewRequest(http.MethodGet, "/api/v1/activities/x", nil)
+			r.Header.Set("X-Family-ID", uuid.NewString())
+			r.Header.Set(idempotency.HeaderKey, "key-1")
+			h.ServeHTTP(httptest.NewRecorder(), r)
+		}
+		assert.EqualValues(t, 2, c.calls.Load())
+	})
+
+	t.Run("Server errors can be retried", func(t *testing.T) {
+		c := &counter{status: http.StatusInternalServerError}
+		h := setup(c)
+		familyID := uuid.New()
+
+		post(h, familyID, "key-1", `{}`)
+		c.status = http.StatusCreated
+		w := post(h, familyID, "key-1", `{}`)
+
+		assert.Equal(t, http.StatusCreated, w.Code)
+		assert.EqualValues(t, 2, c.calls.Load())
+	})
+
+	t.Run("Client errors are replayed", func(t *testing.T) {
+		c := &counter{status: http.StatusConflict}
+		h := setup(c)
+		familyID := uuid.New()
+
+		post(h, familyID, "key-1", `{}`)
+		w := post(h, familyID, "key-1", `{}`)
+
+		assert.Equal(t, http.StatusConflict, w.Code)
+		assert.EqualValues(t, 1, c.calls.Load())
+	})
+
+	t.Run("A retry while the first request runs is a conflict", func(t *testing.T) {
+		started := make(chan struct{})
+		release := make(chan struct{})
+		h := setup(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			close(started)
+			<-release
+			w.WriteHeader(http.StatusCreated)
+		}))
+		familyID := uuid.New()
+
+		done := make(chan *httptest.ResponseRecorder)
+		go func() { done <- post(h, familyID, "key-1", `{}`) }()
+		<-started
+
+		w := post(h, familyID, "key-1", `{}`)
+		assert.Equal(t, http.StatusConflict, w.Code)
+
+		close(release)
+		assert.Equal(t, http.StatusCreated, (<-done).Code)
+	})
+
+	t.Run("A panicking handler releases the key", func(t *testing.T) {
+		store := memory.NewInMemoryIdempotencyStore()
+		h := idempotency.Middleware(store, time.Hour)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			panic("boom")
+		}))
+		familyID := uuid.New()
+		r := httptest.NewRequest(http.MethodPost, "/api/v1/activities/start", strings.NewReader(`{}`))
+		r = r.WithContext(context.WithValue(r.Context(), middleware.FamilyIDKey, familyID))
+		r.Header.Set(idempotency.HeaderKey, "key-1")
+
+		assert.Panics(t, func() { h.ServeHTTP(httptest.NewRecorder(), r) })
+
+		ctx := context.WithValue(context.Background(), middleware.FamilyIDKey, familyID)
+		existing, err := store.Reserve(ctx, idempotency.Record{Key: "key-1", CreatedAt: time.Now(), ExpiresAt: time.Now().Add(time.Hour)})
+		require.NoError(t, err)
+		assert.Nil(t, existing)
+	})
+
+	t.Run("Rejects overlong keys", func(t *testing.T) {
+		c := &counter{status: http.StatusCreated}
+		w := post(setup(c), uuid.New(), strings.Repeat("k", 256), `{}`)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Zero(t, c.calls.Load())
+	})
+
+	t.Run("Rejects oversized bodies", func(t *testing.T) {
+		c := &counter{status: http.StatusCreated}
+		w := post(setup(c), uuid.New(), "key-1", `{"note":"`+strings.Repeat("z", 2<<20)+`"}`)
+
+		assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+		var p problem.Problem
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &p))
+		assert.Equal(t, "/problems/too-large", p.Type)
+		assert.Zero(t, c.calls.Load())
+	})
+
+	t.Run("Tells conditional requests apart by If-Match", func(t *testing.T) {
+		c := &counter{status: http.StatusOK}
+		h := setup(c)
+		familyID := uuid.New()
+
+		send := func(ifMatch string) *httptest.ResponseRecorder {
+			r := httptest.NewRequest(http.MethodPost, "/api/v1/activities/x/complete", strings.NewReader(`{}`))
+			r.Header.Set("X-Family-ID", familyID.String())
+			r.Header.Set(idempotency.HeaderKey, "key-1")
+			r.Header.Set("If-Match", ifMatch)
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+			return w
+		}
+
+		assert.Equal(t, http.StatusOK, send(`"1"`).Code)
+		assert.Equal(t, http.StatusOK, send(`"1"`).Code)
+		assert.Equal(t, http.StatusUnprocessableEntity, send(`"2"`).Code)
+		assert.EqualValues(t, 1, c.calls.Load())
+	})
+}
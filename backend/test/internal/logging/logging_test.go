@@ -0,0 +1,125 @@
+package logging_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
+	"github.com/google/uuid"
+	"github.com/luisteixeira/waypoint/backend/internal/config"
+	"github.com/luisteixeira/waypoint/backend/internal/logging"
+	"github.com/luisteixeira/waypoint/backend/internal/middleware"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func records(t *testing.T, buf *bytes.Buffer) []map[string]any {
+	var out []map[string]any
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var record map[string]any
+		require.NoError(t, json.Unmarshal([]byte(line), &record))
+		out = append(out, record)
+	}
+	return out
+}
+
+func TestLogger(t *testing.T) {
+	t.Run("Adds the IDs carried by the context", func(t *testing.T) {
+		var buf bytes.Buffer
+		logger := logging.New(config.LoggingConfig{Level: "info", Format: "json"}, &buf)
+
+		familyID, caregiverID := uuid.New(), uuid.New()
+		ctx := context.WithValue(context.Background(), chimiddleware.RequestIDKey, "req-1")
+		ctx = context.WithValue(ctx, middleware.FamilyIDKey, familyID)
+		ctx = context.WithValue(ctx, middleware.CaregiverIDKey, caregiverID)
+		logger.With("component", "test").InfoContext(ctx, "started")
+
+		got := records(t, &buf)
+		require.Len(t, got, 1)
+		assert.Equal(t, "req-1", got[0]["request_id"])
+		assert.Equal(t, familyID.String(), got[0]["family_id"])
+		assert.Equal(t, caregiverID.String(), got[0]["caregiver_id"])
+		assert.Equal(t, "test", got[0]["component"])
+	})
+
+	t.Run("Redacts sensitive attributes", func(t *testing.T) {
+		var buf bytes.Buffer
+		logger := logging.New(config.LoggingConfig{Level: "info", Format: "json"}, &buf)
+
+		logger.Info("connecting",
+			"db_password", "hunter2",
+			"Authorization", "Bearer abc",
+			"dsn", "postgres://admin:pw@db/waypoint",
+			"host", "db")
+
+		out := buf.String()
+		assert.NotContains(t, out, "hunter2")
+		assert.NotContains(t, out, "Bearer abc")
+		assert.NotContains(t, out, "admin:pw")
+
+		got := records(t, &buf)
+		assert.Equal(t, logging.Redacted, got[0]["db_password"])
+		assert.Equal(t, "db", got[0]["host"])
+	})
+
+	t.Run("Honours the configured level", func(t *testing.T) {
+		var buf bytes.Buffer
+		logger := logging.New(config.LoggingConfig{Level: "warn", Format: "text"}, &buf)
+
+		logger.Info("quiet")
+		logger.Warn("loud")
+
+		assert.NotContains(t, buf.String(), "quiet")
+		assert.Contains(t, buf.String(), "msg=loud")
+	})
+}
+
+func TestMiddleware(t *testing.T) {
+	var buf bytes.Buffer
+	logger := logging.New(config.LoggingConfig{Level: "info", Format: "json"}, &buf)
+
+	router := chi.NewRouter()
+	router.Use(chimiddleware.RequestID)
+	router.Use(logging.Middleware(logger))
+	router.Route("/api/v1", func(r chi.Router) {
+		r.Use(middleware.TenantMiddleware)
+		r.Get("/activities/{id}", func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("{}"))
+		})
+	})
+
+	familyID, caregiverID := uuid.New(), uuid.New()
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/activities/"+uuid.NewString(), nil)
+	req.Header.Set("X-Family-ID", familyID.String())
+	req.Header.Set("X-Caregiver-ID", caregiverID.String())
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusOK, rec.Code)
+	assert.NotEmpty(t, rec.Header().Get("X-Request-ID"))
+
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/activities/"+uuid.NewString(), nil)
+	req.Header.Set("X-Family-ID", familyID.String())
+	req.Header.Set("X-Caregiver-ID", "nobody")
+	rec = httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	assert.Equal(t, http.StatusBadRequest, rec.Code, "a malformed caregiver is rejected")
+
+	got := records(t, &buf)
+	require.Len(t, got, 2)
+	assert.Equal(t, "INFO", got[0]["level"])
+	assert.Equal(t, "/api/v1/activities/{id}", got[0]["route"])
+	assert.Equal(t, float64(http.StatusOK), got[0]["status"])
+	assert.NotEmpty(t, got[0]["request_id"])
+	assert.Equal(t, familyID.String(), got[0]["family_id"])
+	assert.Equal(t, caregiverID.String(), got[0]["caregiver_id"])
+	assert.Equal(t, "WARN", got[1]["level"])
+}
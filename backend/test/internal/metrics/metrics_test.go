@@ -0,0 +1,122 @@
+package metrics_test
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/luisteixeira/waypoint/backend/internal/domain"
+	"github.com/luisteixeira/waypoint/backend/internal/metrics"
+	"github.com/luisteixeira/waypoint/backend/internal/middleware"
+	"github.com/luisteixeira/waypoint/backend/internal/repository/memory"
+	"github.com/luisteixeira/waypoint/backend/internal/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func scrape(t *testing.T, m *metrics.Metrics) string {
+	rec := httptest.NewRecorder()
+	m.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	body, err := io.ReadAll(rec.Body)
+	require.NoError(t, err)
+	return string(body)
+}
+
+func TestActivityMetrics(t *testing.T) {
+	m := metrics.New()
+	activities := metrics.NewActivityRepo(memory.NewInMemoryActivityRepo(), m)
+	definitions := metrics.NewDefinitionRepo(memory.NewInMemoryDefinitionRepo(), m)
+	svc := metrics.NewActivityService(service.NewActivityService(activities, definitions, memory.NewTxManager(), service.DefaultClientTimeWindow), m)
+
+	ctx := context.WithValue(context.Background(), middleware.FamilyIDKey, uuid.New())
+	definitionID, entityID := uuid.New(), uuid.New()
+	input := domain.StartActivityInput{DefinitionID: definitionID, EntityID: entityID}
+
+	first, err := svc.StartActivity(ctx, input)
+	require.NoError(t, err)
+	_, err = svc.StartActivity(ctx, input)
+	require.ErrorIs(t, err, domain.ErrEntityBusy)
+	_, err = svc.CompleteActivity(ctx, first.ID, 0)
+	require.NoError(t, err)
+
+	second, err := svc.StartActivity(ctx, input)
+	require.NoError(t, err)
+	_, err = svc.CancelActivity(ctx, second.ID, 0)
+	require.NoError(t, err)
+
+	_, err = svc.GetActivity(ctx, uuid.New())
+	require.ErrorIs(t, err, domain.ErrNotFound)
+
+	body := scrape(t, m)
+	label := `{definition_id="` + definitionID.String() + `"}`
+	assert.Contains(t, body, "waypoint_activities_started_total"+label+" 2")
+	assert.Contains(t, body, "waypoint_activities_completed_total"+label+" 1")
+	assert.Contains(t, body, "waypoint_activities_cancelled_total"+label+" 1")
+	assert.Contains(t, body, "waypoint_activity_duration_seconds_count"+label+" 1")
+	assert.Contains(t, body, "waypoint_entity_busy_conflicts_total 1")
+	assert.Contains(t, body, `waypoint_repository_operation_duration_seconds_count{operation="create_realization",outcome="ok",repository="activity"} 2`)
+	assert.Contains(t, body, `waypoint_repository_operation_duration_seconds_count{operation="get_realization_by_id",outcome="not_found",repository="activity"} 1`)
+}
+
+func TestActivityMetrics_RolledBackBatch(t *testing.T) {
+	m := metrics.New()
+	tx := memory.NewTxManager()
+	svc := metrics.NewActivityService(service.NewActivityService(memory.NewInMemoryActivityRepo(), memory.NewInMemoryDefinitionRepo(), tx, service.DefaultClientTimeWindow), m)
+	batch := service.NewBatchService(svc, tx)
+
+	ctx := context.WithValue(context.Background(), middleware.FamilyIDKey, uuid.New())
+	definitionID, startedAt := uuid.New(), time.Now().Add(-time.Hour)
+	start := domain.BatchOperation{
+		ID:              "start",
+		Type:            domain.BatchStart,
+		ClientTimestamp: startedAt,
+		Start:           domain.StartActivityInput{DefinitionID: definitionID, EntityID: uuid.New()},
+	}
+	complete := domain.BatchOperation{ID: "complete", Type: domain.BatchComplete, ClientTimestamp: startedAt.Add(time.Minute), Ref: "start"}
+	unknown := domain.BatchOperation{ID: "unknown", Type: domain.BatchComplete, ClientTimestamp: startedAt, RealizationID: uuid.New()}
+
+	results, err := batch.ApplyBatch(ctx, []domain.BatchOperation{start, complete, unknown}, true)
+	require.NoError(t, err)
+	require.Equal(t, domain.BatchRolledBack, results[0].Status)
+
+	body := scrape(t, m)
+	label := `{definition_id="` + definitionID.String() + `"}`
+	assert.NotContains(t, body, "waypoint_activities_started_total"+label)
+	assert.NotContains(t, body, "waypoint_activities_completed_total"+label)
+
+	_, err = batch.ApplyBatch(ctx, []domain.BatchOperation{start, complete}, true)
+	require.NoError(t, err)
+
+	body = scrape(t, m)
+	assert.Contains(t, body, "waypoint_activities_started_total"+label+" 1")
+	assert.Contains(t, body, "waypoint_activities_completed_total"+label+" 1")
+	assert.Contains(t, body, "waypoint_activity_duration_seconds_count"+label+" 1")
+}
+
+func TestHTTPMetrics(t *testing.T) {
+	m := metrics.New()
+	router := chi.NewRouter()
+	router.Use(m.Middleware)
+	router.Get("/activities/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+	router.Get("/ok", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	})
+
+	for _, path := range []string{"/activities/" + uuid.NewString(), "/activities/" + uuid.NewString(), "/ok", "/nope"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	body := scrape(t, m)
+	assert.Contains(t, body, `waypoint_http_request_duration_seconds_count{method="GET",route="/activities/{id}",status="404"} 2`)
+	assert.Contains(t, body, `waypoint_http_request_duration_seconds_count{method="GET",route="/ok",status="200"} 1`)
+	assert.Contains(t, body, `waypoint_http_request_duration_seconds_count{method="GET",route="unmatched",status="404"} 1`)
+}
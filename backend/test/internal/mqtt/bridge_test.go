@@ -0,0 +1,243 @@
+package mqtt_test
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/luisteixeira/waypoint/backend/internal/domain"
+	"github.com/luisteixeira/waypoint/backend/internal/middleware"
+	"github.com/luisteixeira/waypoint/backend/internal/mqtt"
+	"github.com/luisteixeira/waypoint/backend/internal/outbox"
+	"github.com/luisteixeira/waypoint/backend/internal/repository/memory"
+	"github.com/luisteixeira/waypoint/backend/internal/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// broker stands in for an MQTT connection: it keeps the last retained message
+// of every topic and hands published commands to the subscriber.
+type broker struct {
+	mu       sync.Mutex
+	retained map[string][]byte
+	filter   string
+	handle   func(topic string, payload []byte)
+}
+
+func (b *broker) Publish(ctx context.Context, topic string, payload []byte, retained bool) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if retained {
+		b.retained[topic] = payload
+	}
+	return nil
+}
+
+func (b *broker) Subscribe(ctx context.Context, filter string, handle func(topic string, payload []byte)) error {
+	b.filter, b.handle = filter, handle
+	return nil
+}
+
+// command delivers a command as the broker would, if the topic matches the
+// subscription's filter.
+func (b *broker) command(t *testing.T, topic string, payload string) {
+	t.Helper()
+	pattern := strings.Split(b.filter, "/")
+	parts := strings.Split(topic, "/")
+	if len(pattern) != len(parts) {
+		return
+	}
+	for i := range pattern {
+		if pattern[i] != "+" && pattern[i] != parts[i] {
+			return
+		}
+	}
+	b.handle(topic, []byte(payload))
+}
+
+func (b *broker) state(t *testing.T, topic string) mqtt.State {
+	t.Helper()
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	payload, ok := b.retained[topic]
+	require.True(t, ok, "nothing retained on %s", topic)
+	var state mqtt.State
+	require.NoError(t, json.Unmarshal(payload, &state))
+	return state
+}
+
+type fixture struct {
+	ctx        context.Context
+	familyID   uuid.UUID
+	entityID   uuid.UUID
+	broker     *broker
+	activities domain.ActivityService
+	publisher  *outbox.Dispatcher
+	now        time.Time
+}
+
+func setup(t *testing.T) *fixture {
+	tx := memory.NewTxManager()
+	realizations := memory.NewInMemoryActivityRepo()
+	definitions := memory.NewInMemoryDefinitionRepo()
+	events := memory.NewInMemoryOutboxStore()
+	activities := service.NewActivityService(outbox.NewActivityRepo(realizations, events, tx, time.Now), definitions, tx, service.DefaultClientTimeWindow)
+
+	f := &fixture{
+		familyID:   uuid.New(),
+		entityID:   uuid.New(),
+		broker:     &broker{retained: map[string][]byte{}},
+		activities: activities,
+		now:        time.Date(2026, 3, 1, 7, 30, 0, 0, time.UTC),
+	}
+	f.ctx = context.WithValue(context.Background(), middleware.FamilyIDKey, f.familyID)
+
+	bridge := mqtt.NewBridge(f.broker, "waypoint", activities, realizations, definitions, func() time.Time { return f.now })
+	require.NoError(t, bridge.Listen(context.Background()))
+	f.publisher = outbox.NewDispatcher(events, outbox.Policy{InitialBackoff: time.Second, MaxBackoff: time.Second}, time.Now, bridge)
+	return f
+}
+
+func (f *fixture) publish(t *testing.T) {
+	t.Helper()
+	_, err := f.publisher.Dispatch(context.Background())
+	require.NoError(t, err)
+}
+
+func (f *fixture) command(t *testing.T, payload string) {
+	t.Helper()
+	f.broker.command(t, mqtt.CommandTopic("waypoint", f.familyID, f.entityID), payload)
+	f.publish(t)
+}
+
+func (f *fixture) state(t *testing.T) mqtt.State {
+	t.Helper()
+	return f.broker.state(t, mqtt.StateTopic("waypoint", f.familyID, f.entityID))
+}
+
+func TestBridge(t *testing.T) {
+	t.Run("Announces the current activity", func(t *testing.T) {
+		f := setup(t)
+		ar, err := f.activities.StartActivity(f.ctx, domain.StartActivityInput{EntityID: f.entityID, NewDefinittionName: "Nap"})
+		require.NoError(t, err)
+		f.publish(t)
+
+		state := f.state(t)
+		assert.Equal(t, f.entityID, state.EntityID)
+		assert.Equal(t, string(domain.StatusInProgress), state.State)
+		assert.Equal(t, "Nap", state.Definition)
+		require.NotNil(t, state.RealizationID)
+		assert.Equal(t, ar.ID, *state.RealizationID)
+		assert.Equal(t, ar.DefinitionID, *state.DefinitionID)
+		assert.Equal(t, ar.Version, state.Version)
+		assert.Equal(t, f.now, state.UpdatedAt)
+
+		ar, err = f.activities.PauseActivity(f.ctx, ar.ID, ar.Version)
+		require.NoError(t, err)
+		f.publish(t)
+		assert.Equal(t, string(domain.StatusPaused), f.state(t).State)
+
+		ar, err = f.activities.ResumeActivity(f.ctx, ar.ID, ar.Version)
+		require.NoError(t, err)
+		_, err = f.activities.CompleteActivity(f.ctx, ar.ID, ar.Version)
+		require.NoError(t, err)
+		f.publish(t)
+
+		state = f.state(t)
+		assert.Equal(t, mqtt.Idle, state.State)
+		assert.Nil(t, state.RealizationID)
+		assert.Empty(t, state.Definition)
+	})
+
+	t.Run("Keeps the activity in progress when an old one changes", func(t *testing.T) {
+		f := setup(t)
+		old, err := f.activities.StartActivity(f.ctx, domain.StartActivityInput{EntityID: f.entityID, NewDefinittionName: "Nap"})
+		require.NoError(t, err)
+		old, err = f.activities.CompleteActivity(f.ctx, old.ID, old.Version)
+		require.NoError(t, err)
+		current, err := f.activities.StartActivity(f.ctx, domain.StartActivityInput{EntityID: f.entityID, NewDefinittionName: "Feed"})
+		require.NoError(t, err)
+		_, err = f.activities.AddNote(f.ctx, old.ID, old.Version, "Woke up happy")
+		require.NoError(t, err)
+		f.publish(t)
+
+		state := f.state(t)
+		assert.Equal(t, "Feed", state.Definition)
+		assert.Equal(t, current.ID, *state.RealizationID)
+	})
+
+	t.Run("Keeps a paused activity when an old one changes", func(t *testing.T) {
+		f := setup(t)
+		old, err := f.activities.StartActivity(f.ctx, domain.StartActivityInput{EntityID: f.entityID, NewDefinittionName: "Nap"})
+		require.NoError(t, err)
+		old, err = f.activities.CompleteActivity(f.ctx, old.ID, old.Version)
+		require.NoError(t, err)
+		current, err := f.activities.StartActivity(f.ctx, domain.StartActivityInput{EntityID: f.entityID, NewDefinittionName: "Feed"})
+		require.NoError(t, err)
+		_, err = f.activities.PauseActivity(f.ctx, current.ID, current.Version)
+		require.NoError(t, err)
+		f.publish(t)
+		_, err = f.activities.AddNote(f.ctx, old.ID, old.Version, "Woke up happy")
+		require.NoError(t, err)
+		f.publish(t)
+
+		state := f.state(t)
+		assert.Equal(t, string(domain.StatusPaused), state.State)
+		assert.Equal(t, "Feed", state.Definition)
+		assert.Equal(t, current.ID, *state.RealizationID)
+	})
+
+	t.Run("Starts and completes activities on command", func(t *testing.T) {
+		f := setup(t)
+
+		f.command(t, `{"action":"start","definition":"Nap"}`)
+		state := f.state(t)
+		assert.Equal(t, string(domain.StatusInProgress), state.State)
+		assert.Equal(t, "Nap", state.Definition)
+
+		f.command(t, `{"action":"complete","definition":"Feed"}`)
+		assert.Equal(t, string(domain.StatusInProgress), f.state(t).State, "only the named definition is completed")
+
+		f.command(t, `{"action":"complete","definition_id":"`+state.DefinitionID.String()+`"}`)
+		assert.Equal(t, mqtt.Idle, f.state(t).State)
+	})
+
+	t.Run("Toggles an activity", func(t *testing.T) {
+		f := setup(t)
+
+		f.command(t, `{"action":"toggle","definition":"Nap"}`)
+		assert.Equal(t, string(domain.StatusInProgress), f.state(t).State)
+
+		f.command(t, `{"action":"toggle","definition":"Nap"}`)
+		assert.Equal(t, mqtt.Idle, f.state(t).State)
+
+		f.command(t, `{"action":"toggle","definition":"Nap"}`)
+		assert.Equal(t, string(domain.StatusInProgress), f.state(t).State)
+	})
+
+	t.Run("Ignores invalid commands", func(t *testing.T) {
+		f := setup(t)
+
+		f.command(t, `not json`)
+		f.command(t, `{"action":"explode","definition":"Nap"}`)
+		f.command(t, `{"action":"start"}`)
+		f.broker.command(t, "waypoint/not-a-family/"+f.entityID.String()+"/command", `{"action":"start","definition":"Nap"}`)
+		f.publish(t)
+
+		f.broker.mu.Lock()
+		defer f.broker.mu.Unlock()
+		assert.Empty(t, f.broker.retained)
+	})
+}
+
+func TestTopics(t *testing.T) {
+	familyID, entityID := uuid.New(), uuid.New()
+	assert.Equal(t, "home/status", mqtt.StatusTopic("home"))
+	assert.Equal(t, "home/"+familyID.String()+"/"+entityID.String()+"/state", mqtt.StateTopic("home", familyID, entityID))
+	assert.Equal(t, "home/"+familyID.String()+"/"+entityID.String()+"/command", mqtt.CommandTopic("home", familyID, entityID))
+}
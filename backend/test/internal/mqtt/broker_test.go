@@ -0,0 +1,88 @@
+package mqtt_test
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/luisteixeira/waypoint/backend/internal/domain"
+	"github.com/luisteixeira/waypoint/backend/internal/mqtt"
+	"github.com/luisteixeira/waypoint/backend/internal/outbox"
+	"github.com/luisteixeira/waypoint/backend/internal/repository/memory"
+	"github.com/luisteixeira/waypoint/backend/internal/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestBroker runs the bridge against the broker named by
+// WAYPOINT_TEST_MQTT_BROKER, e.g. the Mosquitto container `make test-mqtt`
+// starts. It is skipped when the variable is not set.
+func TestBroker(t *testing.T) {
+	broker := os.Getenv("WAYPOINT_TEST_MQTT_BROKER")
+	if broker == "" {
+		t.Skip("WAYPOINT_TEST_MQTT_BROKER not set")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// Every run gets topics of its own, so retained messages from earlier
+	// runs do not interfere.
+	prefix := "waypoint-test/" + uuid.NewString()
+	dial := func(clientID string) *mqtt.Conn {
+		conn := mqtt.Dial(ctx, mqtt.Options{Broker: broker, ClientID: clientID, Prefix: prefix, QoS: 1})
+		t.Cleanup(conn.Close)
+		return conn
+	}
+
+	tx := memory.NewTxManager()
+	realizations := memory.NewInMemoryActivityRepo()
+	definitions := memory.NewInMemoryDefinitionRepo()
+	events := memory.NewInMemoryOutboxStore()
+	activities := service.NewActivityService(outbox.NewActivityRepo(realizations, events, tx, time.Now), definitions, tx, service.DefaultClientTimeWindow)
+
+	bridge := mqtt.NewBridge(dial("waypoint-"+uuid.NewString()), prefix, activities, realizations, definitions, time.Now)
+	require.NoError(t, bridge.Listen(ctx))
+	publisher := outbox.NewDispatcher(events, outbox.Policy{InitialBackoff: time.Second, MaxBackoff: time.Second}, time.Now, bridge)
+
+	hub := dial("hub-" + uuid.NewString())
+	messages := make(chan [2]string, 10)
+	require.NoError(t, hub.Subscribe(ctx, prefix+"/#", func(topic string, payload []byte) {
+		messages <- [2]string{topic, string(payload)}
+	}))
+	next := func(topic string) string {
+		t.Helper()
+		for {
+			select {
+			case msg := <-messages:
+				if msg[0] == topic {
+					return msg[1]
+				}
+			case <-ctx.Done():
+				t.Fatalf("nothing published on %s", topic)
+			}
+		}
+	}
+
+	assert.Equal(t, "online", next(mqtt.StatusTopic(prefix)))
+
+	familyID, entityID := uuid.New(), uuid.New()
+	command := `{"action":"start","definition":"Nap"}`
+	require.NoError(t, hub.Publish(ctx, mqtt.CommandTopic(prefix, familyID, entityID), []byte(command), false))
+
+	// The command is handled asynchronously; publish once it has been.
+	require.Eventually(t, func() bool {
+		published, err := publisher.Dispatch(ctx)
+		require.NoError(t, err)
+		return published > 0
+	}, 5*time.Second, 50*time.Millisecond)
+
+	var state mqtt.State
+	require.NoError(t, json.Unmarshal([]byte(next(mqtt.StateTopic(prefix, familyID, entityID))), &state))
+	assert.Equal(t, entityID, state.EntityID)
+	assert.Equal(t, string(domain.StatusInProgress), state.State)
+	assert.Equal(t, "Nap", state.Definition)
+}
@@ -0,0 +1,157 @@
+package openapi_test
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/luisteixeira/waypoint/backend/internal/alert"
+	"github.com/luisteixeira/waypoint/backend/internal/handler"
+	"github.com/luisteixeira/waypoint/backend/internal/middleware"
+	"github.com/luisteixeira/waypoint/backend/internal/openapi"
+	"github.com/luisteixeira/waypoint/backend/internal/push"
+	"github.com/luisteixeira/waypoint/backend/internal/repository/memory"
+	"github.com/luisteixeira/waypoint/backend/internal/service"
+	"github.com/luisteixeira/waypoint/backend/internal/webhook"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupRouter(t *testing.T) (*chi.Mux, *openapi.Spec) {
+	spec, err := openapi.Load()
+	require.NoError(t, err)
+
+	activities, definitions, tx := memory.NewInMemoryActivityRepo(), memory.NewInMemoryDefinitionRepo(), memory.NewTxManager()
+	svc := service.NewActivityService(activities, definitions, tx, service.DefaultClientTimeWindow)
+	activityHandler := handler.NewActivityHandler(svc)
+	batchHandler := handler.NewBatchHandler(service.NewBatchService(svc, tx))
+	syncHandler := handler.NewSyncHandler(service.NewSyncService(memory.NewInMemoryChangeRepo(activities, definitions), tx))
+	webhooks := memory.NewInMemoryWebhookStore()
+	webhookHandler := handler.NewWebhookHandler(webhook.NewRegistry(webhooks))
+	alertHandler := handler.NewAlertHandler(alert.NewService(memory.NewInMemoryAlertStore(), definitions, memory.NewInMemoryEntityRepo(activities), time.Now, webhook.NewAlertNotifier(webhooks, time.Now)))
+	keys, err := push.GenerateKeys()
+	require.NoError(t, err)
+	pushHandler := handler.NewPushHandler(push.NewService(memory.NewInMemoryPushStore(), keys, time.Now))
+
+	router := chi.NewRouter()
+	router.Route("/api/v1", func(r chi.Router) {
+		r.Get("/openapi.json", spec.ServeJSON)
+		r.Group(func(r chi.Router) {
+			r.Use(middleware.TenantMiddleware)
+			r.Use(spec.Validate)
+			activityHandler.Routes(r)
+			batchHandler.Routes(r)
+			syncHandler.Routes(r)
+			webhookHandler.Routes(r)
+			alertHandler.Routes(r)
+			pushHandler.Routes(r)
+		})
+	})
+	return router, spec
+}
+
+func TestSpecDescribesEveryRoute(t *testing.T) {
+	router, spec := setupRouter(t)
+
+	err := chi.Walk(router, func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		if route == "/api/v1/openapi.json" {
+			return nil
+		}
+		path := spec.Document().Paths.Find(strings.TrimPrefix(route, "/api/v1"))
+		if assert.NotNil(t, path, "%s is not described", route) {
+			assert.NotNil(t, path.GetOperation(method), "%s %s is not described", method, route)
+		}
+		return nil
+	})
+	require.NoError(t, err)
+}
+
+func TestServeJSON(t *testing.T) {
+	router, _ := setupRouter(t)
+
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/openapi.json", nil))
+	require.Equal(t, http.StatusOK, rec.Code)
+
+	var doc map[string]any
+	require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &doc))
+	assert.Equal(t, "3.0.3", doc["openapi"])
+	assert.Contains(t, doc["paths"], "/activities/start")
+}
+
+func TestValidate(t *testing.T) {
+	router, _ := setupRouter(t)
+	familyID := uuid.NewString()
+
+	send := func(method, target, contentType, body string) (int, map[string]any) {
+		req := httptest.NewRequest(method, target, strings.NewReader(body))
+		req.Header.Set("X-Family-ID", familyID)
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		rec := httptest.NewRecorder()
+		router.ServeHTTP(rec, req)
+
+		var response map[string]any
+		json.Unmarshal(rec.Body.Bytes(), &response)
+		return rec.Code, response
+	}
+
+	tests := []struct {
+		name        string
+		method      string
+		target      string
+		contentType string
+		body        string
+		field       string
+	}{
+		{"Malformed entity in JSON", "POST", "/api/v1/activities/start", "application/json",
+			`{"entity_id":"not-a-uuid","new_definition_name":"Nap"}`, "entity_id"},
+		{"Malformed caregiver in JSON", "POST", "/api/v1/activities/plan", "application/json",
+			`{"entity_id":"` + uuid.NewString() + `","caregiver_ids":["` + uuid.NewString() + `","x"]}`, "caregiver_ids.1"},
+		{"Missing entity", "POST", "/api/v1/activities/start", "application/json",
+			`{"new_definition_name":"Nap"}`, "entity_id"},
+		{"Wrong type", "POST", "/api/v1/activities/start", "application/json",
+			`{"entity_id":"` + uuid.NewString() + `","new_definition_name":7}`, "new_definition_name"},
+		{"Malformed entity in a form", "POST", "/api/v1/activities/start", "application/x-www-form-urlencoded",
+			url.Values{"entity_id": {"nope"}, "new_definition_name": {"Nap"}}.Encode(), "entity_id"},
+		{"Malformed path ID", "GET", "/api/v1/activities/123", "", "", "id"},
+		{"Malformed timestamp", "GET", "/api/v1/activities/" + uuid.NewString() + "?at=yesterday", "", "", "at"},
+		{"Malformed edit", "PATCH", "/api/v1/activities/" + uuid.NewString(), "application/json",
+			`{"started_at":"9am"}`, "started_at"},
+		{"Unknown batch operation", "POST", "/api/v1/batch", "application/json",
+			`{"operations":[{"id":"1","type":"pause","client_timestamp":"2024-05-01T10:00:00Z"}]}`, "operations.0.type"},
+		{"Sync page too large", "GET", "/api/v1/sync?limit=5000", "", "", "limit"},
+		{"Unknown webhook event", "POST", "/api/v1/webhooks", "application/json",
+			`{"url":"https://example.com/hook","events":["activity.paused"]}`, "events.0"},
+		{"Unknown alert kind", "POST", "/api/v1/alert-rules", "application/json",
+			`{"kind":"late","definition_id":"` + uuid.NewString() + `","threshold_minutes":15}`, "kind"},
+		{"Push subscription without keys", "POST", "/api/v1/push/subscriptions", "application/json",
+			`{"endpoint":"https://push.example.com/send/1"}`, "keys"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status, response := send(tt.method, tt.target, tt.contentType, tt.body)
+			assert.Equal(t, http.StatusBadRequest, status, response)
+			assert.Equal(t, "/problems/validation", response["type"])
+			assert.Equal(t, tt.field, response["field"], response["detail"])
+		})
+	}
+
+	t.Run("Valid requests pass", func(t *testing.T) {
+		status, response := send("POST", "/api/v1/activities/start", "application/json",
+			`{"entity_id":"`+uuid.NewString()+`","new_definition_name":"Nap","caregiver_ids":null}`)
+		assert.Equal(t, http.StatusCreated, status, response)
+
+		form := url.Values{"entity_id": {uuid.NewString()}, "new_definition_name": {"Bath"}}
+		status, response = send("POST", "/api/v1/activities/start", "application/x-www-form-urlencoded", form.Encode())
+		assert.Equal(t, http.StatusCreated, status, response)
+	})
+}
@@ -0,0 +1,176 @@
+package outbox_test
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/luisteixeira/waypoint/backend/internal/domain"
+	"github.com/luisteixeira/waypoint/backend/internal/middleware"
+	"github.com/luisteixeira/waypoint/backend/internal/outbox"
+	"github.com/luisteixeira/waypoint/backend/internal/repository"
+	"github.com/luisteixeira/waypoint/backend/internal/repository/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// sink records what it is published, failing the first failures times.
+type sink struct {
+	name     string
+	failures int
+
+	mu       sync.Mutex
+	events   []outbox.Event
+	families []uuid.UUID
+}
+
+func (s *sink) Name() string { return s.name }
+
+func (s *sink) Publish(ctx context.Context, event outbox.Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.failures > 0 {
+		s.failures--
+		return errors.New(s.name + " is down")
+	}
+	familyID, err := repository.GetFamilyIdFromContext(ctx)
+	if err != nil {
+		return err
+	}
+	s.events = append(s.events, event)
+	s.families = append(s.families, familyID)
+	return nil
+}
+
+func (s *sink) published() []outbox.Event {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]outbox.Event(nil), s.events...)
+}
+
+var policy = outbox.Policy{InitialBackoff: time.Minute, MaxBackoff: 3 * time.Minute}
+
+type fixture struct {
+	ctx   context.Context
+	store *memory.InMemoryOutboxStore
+	now   time.Time
+}
+
+func setup(t *testing.T) *fixture {
+	return &fixture{
+		ctx:   context.WithValue(context.Background(), middleware.FamilyIDKey, uuid.New()),
+		store: memory.NewInMemoryOutboxStore(),
+		now:   time.Now().Add(time.Second),
+	}
+}
+
+func (f *fixture) dispatcher(sinks ...outbox.Sink) *outbox.Dispatcher {
+	return outbox.NewDispatcher(f.store, policy, func() time.Time { return f.now }, sinks...)
+}
+
+func (f *fixture) append(t *testing.T, eventType outbox.EventType) outbox.Event {
+	t.Helper()
+	event := outbox.Event{
+		ID:          uuid.New(),
+		Type:        eventType,
+		OccurredAt:  time.Now().UTC(),
+		Realization: &domain.ActivityRealization{ID: uuid.New(), Status: domain.StatusInProgress, Version: 1},
+	}
+	require.NoError(t, f.store.Append(f.ctx, event))
+	return event
+}
+
+func dispatch(t *testing.T, d *outbox.Dispatcher) int {
+	t.Helper()
+	published, err := d.Dispatch(context.Background())
+	require.NoError(t, err)
+	return published
+}
+
+func TestDispatch(t *testing.T) {
+	t.Run("Publishes events to every sink in order", func(t *testing.T) {
+		f := setup(t)
+		a, b := &sink{name: "a"}, &sink{name: "b"}
+		d := f.dispatcher(a, b)
+		first := f.append(t, outbox.EventActivityStarted)
+		second := f.append(t, outbox.EventActivityCompleted)
+
+		assert.Equal(t, 2, dispatch(t, d))
+		for _, s := range []*sink{a, b} {
+			published := s.published()
+			require.Len(t, published, 2)
+			assert.Equal(t, first.ID, published[0].ID)
+			assert.Equal(t, second.ID, published[1].ID)
+			assert.Equal(t, f.ctx.Value(middleware.FamilyIDKey), s.families[0])
+		}
+
+		assert.Zero(t, dispatch(t, d), "published events are not published again")
+		assert.Len(t, a.published(), 2)
+	})
+
+	t.Run("Retries only the sinks that failed", func(t *testing.T) {
+		f := setup(t)
+		healthy, flaky := &sink{name: "healthy"}, &sink{name: "flaky", failures: 2}
+		d := f.dispatcher(healthy, flaky)
+		event := f.append(t, outbox.EventActivityStarted)
+
+		assert.Zero(t, dispatch(t, d))
+		assert.Len(t, healthy.published(), 1)
+		assert.Empty(t, flaky.published())
+
+		f.now = f.now.Add(30 * time.Second)
+		assert.Zero(t, dispatch(t, d), "not retried before the backoff")
+
+		f.now = f.now.Add(30 * time.Second)
+		assert.Zero(t, dispatch(t, d))
+		f.now = f.now.Add(time.Minute)
+		assert.Zero(t, dispatch(t, d), "the backoff doubles")
+
+		f.now = f.now.Add(time.Minute)
+		assert.Equal(t, 1, dispatch(t, d))
+		require.Len(t, flaky.published(), 1)
+		assert.Equal(t, event.ID, flaky.published()[0].ID)
+		assert.Len(t, healthy.published(), 1, "the healthy sink sees the event once")
+	})
+
+	t.Run("Publishes events left behind by a crash", func(t *testing.T) {
+		f := setup(t)
+		event := f.append(t, outbox.EventActivityStarted)
+
+		// A dispatcher that dies after claiming never records the result.
+		_, err := f.store.ClaimDue(context.Background(), f.now, f.now.Add(5*time.Minute), 100)
+		require.NoError(t, err)
+
+		s := &sink{name: "a"}
+		d := f.dispatcher(s)
+		assert.Zero(t, dispatch(t, d), "claimed events are hidden while leased")
+
+		f.now = f.now.Add(5 * time.Minute)
+		assert.Equal(t, 1, dispatch(t, d))
+		require.Len(t, s.published(), 1)
+		assert.Equal(t, event.ID, s.published()[0].ID)
+	})
+
+	t.Run("Deletes published events past their retention", func(t *testing.T) {
+		f := setup(t)
+		f.append(t, outbox.EventActivityStarted)
+		f.append(t, outbox.EventActivityCompleted)
+		d := f.dispatcher(&sink{name: "a", failures: 1})
+		dispatch(t, d)
+
+		deleted, err := f.store.DeletePublished(context.Background(), f.now.Add(time.Second))
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), deleted, "the failed event is kept")
+	})
+}
+
+func TestPolicyBackoff(t *testing.T) {
+	assert.Equal(t, time.Minute, policy.Backoff(1))
+	assert.Equal(t, 2*time.Minute, policy.Backoff(2))
+	assert.Equal(t, 3*time.Minute, policy.Backoff(3))
+	assert.Equal(t, 3*time.Minute, policy.Backoff(10))
+}
@@ -0,0 +1,167 @@
+package outbox_test
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/luisteixeira/waypoint/backend/internal/domain"
+	"github.com/luisteixeira/waypoint/backend/internal/middleware"
+	"github.com/luisteixeira/waypoint/backend/internal/outbox"
+	"github.com/luisteixeira/waypoint/backend/internal/repository/memory"
+	"github.com/luisteixeira/waypoint/backend/internal/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestActivityRepo(t *testing.T) {
+	now := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+	setupService := func(t *testing.T) (context.Context, domain.ActivityService, *memory.TxManager, *memory.InMemoryOutboxStore) {
+		tx := memory.NewTxManager()
+		store := memory.NewInMemoryOutboxStore()
+		repo := outbox.NewActivityRepo(memory.NewInMemoryActivityRepo(), store, tx, func() time.Time { return now })
+		ctx := context.WithValue(context.Background(), middleware.FamilyIDKey, uuid.New())
+		return ctx, service.NewActivityService(repo, memory.NewInMemoryDefinitionRepo(), tx, service.DefaultClientTimeWindow), tx, store
+	}
+
+	types := func(events []outbox.Pending) []outbox.EventType {
+		var types []outbox.EventType
+		for _, e := range events {
+			types = append(types, e.Type)
+		}
+		return types
+	}
+
+	// pending claims everything in the outbox.
+	pending := func(t *testing.T, store outbox.Store) []outbox.Pending {
+		t.Helper()
+		claimed, err := store.ClaimDue(context.Background(), time.Now().Add(time.Second), time.Now().Add(time.Minute), 100)
+		require.NoError(t, err)
+		return claimed
+	}
+
+	t.Run("Records an event for every change", func(t *testing.T) {
+		ctx, activities, _, store := setupService(t)
+		input := domain.StartActivityInput{EntityID: uuid.New(), NewDefinittionName: "Nap"}
+
+		ar, err := activities.PlanActivity(ctx, input)
+		require.NoError(t, err)
+		input.RealizationID, input.ExpectedVersion = ar.ID, ar.Version
+		ar, err = activities.StartActivity(ctx, input)
+		require.NoError(t, err)
+		ar, err = activities.PauseActivity(ctx, ar.ID, ar.Version)
+		require.NoError(t, err)
+		ar, err = activities.ResumeActivity(ctx, ar.ID, ar.Version)
+		require.NoError(t, err)
+		ar, err = activities.AddNote(ctx, ar.ID, ar.Version, "Fell asleep quickly")
+		require.NoError(t, err)
+		completed, err := activities.CompleteActivity(ctx, ar.ID, ar.Version)
+		require.NoError(t, err)
+
+		events := pending(t, store)
+		assert.Equal(t, []outbox.EventType{
+			outbox.EventActivityPlanned, outbox.EventActivityStarted, outbox.EventActivityPaused,
+			outbox.EventActivityResumed, outbox.EventActivityNoted, outbox.EventActivityCompleted,
+		}, types(events))
+
+		last := events[len(events)-1]
+		assert.Equal(t, ctx.Value(middleware.FamilyIDKey), last.FamilyID)
+		assert.Equal(t, completed.ID, last.Realization.ID)
+		assert.Equal(t, domain.StatusCompleted, last.Realization.Status)
+		assert.Equal(t, completed.Version, last.Realization.Version)
+		assert.WithinDuration(t, time.Now(), last.OccurredAt, time.Minute)
+	})
+
+	t.Run("Records only the start of a realization started straight away", func(t *testing.T) {
+		ctx, activities, _, store := setupService(t)
+
+		ar, err := activities.StartActivity(ctx, domain.StartActivityInput{EntityID: uuid.New(), NewDefinittionName: "Nap"})
+		require.NoError(t, err)
+
+		events := pending(t, store)
+		require.Equal(t, []outbox.EventType{outbox.EventActivityStarted}, types(events))
+		assert.Equal(t, domain.StatusInProgress, events[0].Realization.Status)
+		assert.Equal(t, ar.ID, events[0].Realization.ID)
+		assert.Equal(t, ar.FamilyID, events[0].Realization.FamilyID)
+		assert.Equal(t, ar.Version, events[0].Realization.Version)
+	})
+
+	t.Run("Records the plan of a planned realization", func(t *testing.T) {
+		ctx, activities, _, store := setupService(t)
+
+		ar, err := activities.PlanActivity(ctx, domain.StartActivityInput{EntityID: uuid.New(), NewDefinittionName: "Nap"})
+		require.NoError(t, err)
+
+		events := pending(t, store)
+		require.Equal(t, []outbox.EventType{outbox.EventActivityPlanned}, types(events))
+		assert.Equal(t, domain.StatusPlanned, events[0].Realization.Status)
+		assert.Nil(t, events[0].Realization.StartedAt)
+		assert.Equal(t, ar.ID, events[0].Realization.ID)
+	})
+
+	t.Run("Records a rebuilt projection", func(t *testing.T) {
+		ctx, activities, _, store := setupService(t)
+
+		ar, err := activities.StartActivity(ctx, domain.StartActivityInput{EntityID: uuid.New(), NewDefinittionName: "Nap"})
+		require.NoError(t, err)
+		_, err = activities.RebuildActivity(ctx, ar.ID)
+		require.NoError(t, err)
+
+		events := pending(t, store)
+		require.Len(t, events, 2)
+		assert.Equal(t, outbox.EventActivityRebuilt, events[1].Type)
+		assert.Equal(t, ar.ID, events[1].Realization.ID)
+		assert.Equal(t, now, events[1].OccurredAt)
+	})
+
+	t.Run("Records nothing for a change that fails", func(t *testing.T) {
+		ctx, activities, _, store := setupService(t)
+
+		_, err := activities.CompleteActivity(ctx, uuid.New(), 0)
+		require.ErrorIs(t, err, domain.ErrNotFound)
+		assert.Empty(t, pending(t, store))
+	})
+
+	t.Run("Records nothing when the unit of work rolls back", func(t *testing.T) {
+		ctx, activities, tx, store := setupService(t)
+
+		failure := errors.New("later operation failed")
+		err := tx.WithinTx(ctx, func(ctx context.Context) error {
+			if _, err := activities.StartActivity(ctx, domain.StartActivityInput{EntityID: uuid.New(), NewDefinittionName: "Nap"}); err != nil {
+				return err
+			}
+			return failure
+		})
+		require.ErrorIs(t, err, failure)
+		assert.Empty(t, pending(t, store))
+	})
+
+	t.Run("Stamps queued offline actions with the client time", func(t *testing.T) {
+		ctx, activities, _, store := setupService(t)
+
+		at := time.Now().Add(-time.Hour).UTC().Truncate(time.Second)
+		_, err := activities.StartActivity(domain.WithClientTime(ctx, at), domain.StartActivityInput{EntityID: uuid.New(), NewDefinittionName: "Nap"})
+		require.NoError(t, err)
+
+		events := pending(t, store)
+		require.Len(t, events, 1)
+		assert.True(t, at.Equal(events[0].OccurredAt), events[0].OccurredAt)
+	})
+
+	t.Run("Records who made the change", func(t *testing.T) {
+		ctx, activities, _, store := setupService(t)
+		caregiverID := uuid.New()
+
+		ar, err := activities.StartActivity(context.WithValue(ctx, middleware.CaregiverIDKey, caregiverID), domain.StartActivityInput{EntityID: uuid.New(), NewDefinittionName: "Nap"})
+		require.NoError(t, err)
+		_, err = activities.CompleteActivity(ctx, ar.ID, ar.Version)
+		require.NoError(t, err)
+
+		events := pending(t, store)
+		require.Equal(t, []outbox.EventType{outbox.EventActivityStarted, outbox.EventActivityCompleted}, types(events))
+		assert.Equal(t, caregiverID, events[0].CaregiverID)
+		assert.Equal(t, uuid.Nil, events[1].CaregiverID, "the client did not say")
+	})
+}
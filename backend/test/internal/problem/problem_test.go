@@ -0,0 +1,67 @@
+package problem_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/luisteixeira/waypoint/backend/internal/domain"
+	"github.com/luisteixeira/waypoint/backend/internal/problem"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFrom(t *testing.T) {
+	cases := []struct {
+		name   string
+		err    error
+		status int
+		typ    string
+	}{
+		{"not found", &domain.NotFoundError{Resource: "activity realization", ID: uuid.New()}, http.StatusNotFound, "/problems/not-found"},
+		{"wrapped not found", fmt.Errorf("loading: %w", &domain.NotFoundError{Resource: "activity realization"}), http.StatusNotFound, "/problems/not-found"},
+		{"entity busy", domain.ErrEntityBusy, http.StatusConflict, "/problems/conflict"},
+		{"stale version", &domain.StaleVersionError{Current: &domain.ActivityRealization{Version: 3}}, http.StatusConflict, "/problems/stale-version"},
+		{"invalid transition", &domain.InvalidTransitionError{From: domain.StatusCompleted, To: domain.StatusPaused}, http.StatusConflict, "/problems/invalid-transition"},
+		{"validation", &domain.ValidationError{Field: "at", Reason: "bad"}, http.StatusBadRequest, "/problems/validation"},
+		{"unauthorized", &domain.UnauthorizedError{Reason: "no family"}, http.StatusUnauthorized, "/problems/unauthorized"},
+		{"forbidden", &domain.ForbiddenError{Reason: "not yours"}, http.StatusForbidden, "/problems/forbidden"},
+		{"idempotency key reused", domain.ErrIdempotencyKeyReused, http.StatusUnprocessableEntity, "/problems/idempotency-key-reused"},
+		{"unknown", errors.New("connection reset"), http.StatusInternalServerError, "about:blank"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			p := problem.From(tc.err)
+			assert.Equal(t, tc.status, p.Status)
+			assert.Equal(t, tc.typ, p.Type)
+		})
+	}
+
+	t.Run("internal errors do not leak details", func(t *testing.T) {
+		p := problem.From(errors.New("pq: password authentication failed"))
+		assert.Empty(t, p.Detail)
+	})
+}
+
+func TestRender(t *testing.T) {
+	current := &domain.ActivityRealization{ID: uuid.New(), Status: domain.StatusCompleted, Version: 4}
+
+	request := httptest.NewRequest("POST", "/api/v1/activities/x/cancel", nil)
+	w := httptest.NewRecorder()
+	problem.Render(w, request, &domain.StaleVersionError{Current: current})
+
+	assert.Equal(t, http.StatusConflict, w.Code)
+	assert.Equal(t, problem.ContentType, w.Header().Get("Content-Type"))
+	assert.Equal(t, `"4"`, w.Header().Get("ETag"))
+
+	var p problem.Problem
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &p))
+	assert.Equal(t, "/api/v1/activities/x/cancel", p.Instance)
+	require.NotNil(t, p.Current)
+	assert.Equal(t, current.ID, p.Current.ID)
+}
@@ -0,0 +1,287 @@
+package push_test
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/ecdh"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hkdf"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"io"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/luisteixeira/waypoint/backend/internal/push"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// browser is a subscribed browser: its keys, and the messages its push
+// service received for it.
+type browser struct {
+	key  *ecdh.PrivateKey
+	auth []byte
+}
+
+func newBrowser(t *testing.T) *browser {
+	key, err := ecdh.P256().GenerateKey(rand.Reader)
+	require.NoError(t, err)
+	auth := make([]byte, 16)
+	_, err = rand.Read(auth)
+	require.NoError(t, err)
+	return &browser{key: key, auth: auth}
+}
+
+func (b *browser) subscription(endpoint string) push.Subscription {
+	return push.Subscription{
+		ID:       uuid.New(),
+		Endpoint: endpoint,
+		P256DH:   base64.RawURLEncoding.EncodeToString(b.key.PublicKey().Bytes()),
+		Auth:     base64.RawURLEncoding.EncodeToString(b.auth),
+	}
+}
+
+// decrypt opens an aes128gcm body the way RFC 8291 has the browser do it.
+func (b *browser) decrypt(t *testing.T, body []byte) push.Message {
+	t.Helper()
+
+	require.Greater(t, len(body), 86)
+	salt, recordSize, idLen := body[:16], binary.BigEndian.Uint32(body[16:20]), int(body[20])
+	assert.EqualValues(t, 4096, recordSize)
+	require.Equal(t, 65, idLen)
+	serverKey, err := ecdh.P256().NewPublicKey(body[21 : 21+idLen])
+	require.NoError(t, err)
+
+	shared, err := b.key.ECDH(serverKey)
+	require.NoError(t, err)
+	info := append([]byte("WebPush: info\x00"), b.key.PublicKey().Bytes()...)
+	info = append(info, serverKey.Bytes()...)
+	ikm, err := hkdf.Key(sha256.New, shared, b.auth, string(info), 32)
+	require.NoError(t, err)
+	cek, err := hkdf.Key(sha256.New, ikm, salt, "Content-Encoding: aes128gcm\x00", 16)
+	require.NoError(t, err)
+	nonce, err := hkdf.Key(sha256.New, ikm, salt, "Content-Encoding: nonce\x00", 12)
+	require.NoError(t, err)
+
+	block, err := aes.NewCipher(cek)
+	require.NoError(t, err)
+	gcm, err := cipher.NewGCM(block)
+	require.NoError(t, err)
+	plaintext, err := gcm.Open(nil, nonce, body[21+idLen:], nil)
+	require.NoError(t, err)
+	require.NotEmpty(t, plaintext)
+	require.Equal(t, byte(0x02), plaintext[len(plaintext)-1], "a single, last record")
+
+	var msg push.Message
+	require.NoError(t, json.Unmarshal(plaintext[:len(plaintext)-1], &msg))
+	return msg
+}
+
+// request is a message a push service received.
+type request struct {
+	path   string
+	header http.Header
+	body   []byte
+}
+
+// pushService is a fake push service. It answers each endpoint path with
+// the status set for it, 201 otherwise.
+type pushService struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	statuses map[string]int
+	requests []request
+}
+
+func newPushService(t *testing.T) *pushService {
+	s := &pushService{statuses: map[string]int{}}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.requests = append(s.requests, request{path: r.URL.Path, header: r.Header.Clone(), body: body})
+		if status, ok := s.statuses[r.URL.Path]; ok {
+			w.WriteHeader(status)
+			io.WriteString(w, "no thanks")
+			return
+		}
+		w.WriteHeader(http.StatusCreated)
+	}))
+	t.Cleanup(s.Close)
+	return s
+}
+
+func (s *pushService) answer(path string, status int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.statuses[path] = status
+}
+
+func (s *pushService) received() []request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]request(nil), s.requests...)
+}
+
+func generateKeys(t *testing.T) *push.Keys {
+	keys, err := push.GenerateKeys()
+	require.NoError(t, err)
+	return keys
+}
+
+// verifyVAPID checks the Authorization header is a token signed with the
+// key it names, and returns the token's claims.
+func verifyVAPID(t *testing.T, header string, keys *push.Keys) map[string]any {
+	t.Helper()
+
+	require.True(t, strings.HasPrefix(header, "vapid t="), header)
+	token, publicKey, ok := strings.Cut(strings.TrimPrefix(header, "vapid t="), ", k=")
+	require.True(t, ok, header)
+	assert.Equal(t, keys.PublicKey(), publicKey)
+
+	parts := strings.Split(token, ".")
+	require.Len(t, parts, 3)
+	rawKey, err := base64.RawURLEncoding.DecodeString(publicKey)
+	require.NoError(t, err)
+	key, err := ecdsa.ParseUncompressedPublicKey(elliptic.P256(), rawKey)
+	require.NoError(t, err)
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	require.NoError(t, err)
+	require.Len(t, signature, 64)
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	r, s := new(big.Int).SetBytes(signature[:32]), new(big.Int).SetBytes(signature[32:])
+	assert.True(t, ecdsa.Verify(key, digest[:], r, s), "the token is signed with the VAPID key")
+
+	var jwtHeader map[string]any
+	raw, err := base64.RawURLEncoding.DecodeString(parts[0])
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(raw, &jwtHeader))
+	assert.Equal(t, "ES256", jwtHeader["alg"])
+
+	var claims map[string]any
+	raw, err = base64.RawURLEncoding.DecodeString(parts[1])
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(raw, &claims))
+	return claims
+}
+
+func TestClient(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	newClient := func(t *testing.T, keys *push.Keys) *push.Client {
+		opts := push.Options{Subject: "mailto:admin@example.com", TTL: time.Hour, Timeout: 5 * time.Second}
+		return push.NewClient(keys, opts, func() time.Time { return now })
+	}
+
+	t.Run("Sends an encrypted, signed message", func(t *testing.T) {
+		service, keys, b := newPushService(t), generateKeys(t), newBrowser(t)
+		realizationID := uuid.New()
+		msg := push.Message{
+			Type:          "activity.started",
+			Title:         "Nap started",
+			Tag:           "event-1",
+			URL:           "/",
+			EntityID:      uuid.New(),
+			RealizationID: &realizationID,
+			Urgency:       "normal",
+		}
+
+		require.NoError(t, newClient(t, keys).Send(context.Background(), b.subscription(service.URL+"/send/abc"), msg))
+
+		received := service.received()
+		require.Len(t, received, 1)
+		req := received[0]
+		assert.Equal(t, "/send/abc", req.path)
+		assert.Equal(t, "aes128gcm", req.header.Get("Content-Encoding"))
+		assert.Equal(t, "application/octet-stream", req.header.Get("Content-Type"))
+		assert.Equal(t, "3600", req.header.Get("TTL"))
+		assert.Equal(t, "normal", req.header.Get("Urgency"))
+
+		claims := verifyVAPID(t, req.header.Get("Authorization"), keys)
+		assert.Equal(t, service.URL, claims["aud"], "the audience is the push service's origin")
+		assert.Equal(t, "mailto:admin@example.com", claims["sub"])
+		assert.EqualValues(t, now.Add(12*time.Hour).Unix(), claims["exp"])
+
+		got := b.decrypt(t, req.body)
+		msg.Urgency = ""
+		assert.Equal(t, msg, got)
+	})
+
+	t.Run("Reports subscriptions the push service forgot", func(t *testing.T) {
+		service, b := newPushService(t), newBrowser(t)
+		service.answer("/gone", http.StatusGone)
+		service.answer("/unknown", http.StatusNotFound)
+		client := newClient(t, generateKeys(t))
+
+		err := client.Send(context.Background(), b.subscription(service.URL+"/gone"), push.Message{Title: "Hi"})
+		assert.ErrorIs(t, err, push.ErrGone)
+		err = client.Send(context.Background(), b.subscription(service.URL+"/unknown"), push.Message{Title: "Hi"})
+		assert.ErrorIs(t, err, push.ErrGone)
+	})
+
+	t.Run("Tells temporary refusals from permanent ones", func(t *testing.T) {
+		service, b := newPushService(t), newBrowser(t)
+		service.answer("/busy", http.StatusTooManyRequests)
+		service.answer("/down", http.StatusBadGateway)
+		service.answer("/bad", http.StatusBadRequest)
+		client := newClient(t, generateKeys(t))
+
+		for path, temporary := range map[string]bool{"/busy": true, "/down": true, "/bad": false} {
+			err := client.Send(context.Background(), b.subscription(service.URL+path), push.Message{Title: "Hi"})
+			var statusErr *push.StatusError
+			require.True(t, errors.As(err, &statusErr), "%s: %v", path, err)
+			assert.Equal(t, temporary, statusErr.Temporary(), path)
+			assert.Equal(t, "no thanks", statusErr.Body)
+		}
+	})
+
+	t.Run("Refuses messages too large to send", func(t *testing.T) {
+		service, b := newPushService(t), newBrowser(t)
+		msg := push.Message{Title: "Nap", Body: strings.Repeat("z", 4096)}
+
+		err := newClient(t, generateKeys(t)).Send(context.Background(), b.subscription(service.URL+"/send"), msg)
+		assert.ErrorContains(t, err, "limit")
+		assert.Empty(t, service.received())
+	})
+}
+
+func TestKeys(t *testing.T) {
+	t.Run("Parse what they print", func(t *testing.T) {
+		keys := generateKeys(t)
+
+		parsed, err := push.ParseKeys(keys.PublicKey(), keys.PrivateKey())
+		require.NoError(t, err)
+		assert.Equal(t, keys.PublicKey(), parsed.PublicKey())
+		assert.Equal(t, keys.PrivateKey(), parsed.PrivateKey())
+
+		_, err = push.ParseKeys(keys.PublicKey()+"==", keys.PrivateKey())
+		assert.NoError(t, err, "padding is tolerated")
+	})
+
+	t.Run("Reject a public key of another pair", func(t *testing.T) {
+		keys, other := generateKeys(t), generateKeys(t)
+
+		_, err := push.ParseKeys(other.PublicKey(), keys.PrivateKey())
+		assert.ErrorContains(t, err, "does not belong")
+	})
+
+	t.Run("Reject a malformed private key", func(t *testing.T) {
+		keys := generateKeys(t)
+
+		_, err := push.ParseKeys(keys.PublicKey(), "not a key")
+		assert.ErrorContains(t, err, "invalid VAPID private key")
+	})
+}
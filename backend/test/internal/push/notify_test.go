@@ -0,0 +1,197 @@
+package push_test
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/luisteixeira/waypoint/backend/internal/alert"
+	"github.com/luisteixeira/waypoint/backend/internal/domain"
+	"github.com/luisteixeira/waypoint/backend/internal/middleware"
+	"github.com/luisteixeira/waypoint/backend/internal/outbox"
+	"github.com/luisteixeira/waypoint/backend/internal/push"
+	"github.com/luisteixeira/waypoint/backend/internal/repository/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type notifyFixture struct {
+	ctx      context.Context
+	store    *memory.InMemoryPushStore
+	service  *pushService
+	sink     *push.Sink
+	notifier *push.AlertNotifier
+	browsers map[string]*browser
+	napID    uuid.UUID
+	entityID uuid.UUID
+}
+
+func newNotifyFixture(t *testing.T) *notifyFixture {
+	f := &notifyFixture{
+		ctx:      context.WithValue(context.Background(), middleware.FamilyIDKey, uuid.New()),
+		store:    memory.NewInMemoryPushStore(),
+		service:  newPushService(t),
+		browsers: map[string]*browser{},
+		entityID: uuid.New(),
+	}
+	definitions := memory.NewInMemoryDefinitionRepo()
+	nap, err := definitions.GetOrCreateByName(f.ctx, "Nap")
+	require.NoError(t, err)
+	f.napID = nap.ID
+
+	client := push.NewClient(generateKeys(t), push.Options{Subject: "mailto:admin@example.com", TTL: time.Hour, Timeout: 5 * time.Second}, time.Now)
+	f.sink = push.NewSink(f.store, client, definitions)
+	f.notifier = push.NewAlertNotifier(f.store, client)
+	return f
+}
+
+// caregiver subscribes a new caregiver with the preferences, or none if
+// prefs is nil. Their browser's endpoint path is /<caregiver ID>.
+func (f *notifyFixture) caregiver(t *testing.T, prefs *push.Preferences) uuid.UUID {
+	caregiverID := uuid.New()
+	path := "/" + caregiverID.String()
+	b := newBrowser(t)
+	f.browsers[path] = b
+
+	sub := b.subscription(f.service.URL + path)
+	sub.CaregiverID = caregiverID
+	sub.CreatedAt = time.Now()
+	require.NoError(t, f.store.SaveSubscription(f.ctx, &sub))
+	if prefs != nil {
+		prefs.CaregiverID = caregiverID
+		require.NoError(t, f.store.SavePreferences(f.ctx, prefs))
+	}
+	return caregiverID
+}
+
+// following prefers everything about the fixture's child.
+func (f *notifyFixture) following() *push.Preferences {
+	prefs := push.DefaultPreferences(uuid.Nil, uuid.Nil)
+	prefs.EntityIDs = []uuid.UUID{f.entityID}
+	return &prefs
+}
+
+// pushed returns the messages each caregiver's browser received.
+func (f *notifyFixture) pushed(t *testing.T) map[uuid.UUID][]push.Message {
+	pushed := map[uuid.UUID][]push.Message{}
+	for _, req := range f.service.received() {
+		caregiverID := uuid.MustParse(req.path[1:])
+		pushed[caregiverID] = append(pushed[caregiverID], f.browsers[req.path].decrypt(t, req.body))
+	}
+	return pushed
+}
+
+func (f *notifyFixture) event(eventType outbox.EventType, actorID uuid.UUID, participants ...uuid.UUID) outbox.Event {
+	return outbox.Event{
+		ID:          uuid.New(),
+		Type:        eventType,
+		CaregiverID: actorID,
+		Realization: &domain.ActivityRealization{
+			ID:            uuid.New(),
+			DefinitionID:  f.napID,
+			EntityID:      f.entityID,
+			Status:        domain.StatusInProgress,
+			CaregiversIDs: participants,
+		},
+	}
+}
+
+func TestSink(t *testing.T) {
+	t.Run("Pushes to the caregivers following the child", func(t *testing.T) {
+		f := newNotifyFixture(t)
+		actor := f.caregiver(t, f.following())
+		participant := f.caregiver(t, f.following())
+		follower := f.caregiver(t, f.following())
+		elsewhere := f.following()
+		elsewhere.EntityIDs = []uuid.UUID{uuid.New()}
+		f.caregiver(t, elsewhere)
+		f.caregiver(t, nil)
+
+		event := f.event(outbox.EventActivityStarted, actor, participant)
+		require.NoError(t, f.sink.Publish(f.ctx, event))
+
+		pushed := f.pushed(t)
+		require.Len(t, pushed, 1, "only the follower who is not taking part")
+		require.Len(t, pushed[follower], 1)
+		msg := pushed[follower][0]
+		assert.Equal(t, "activity.started", msg.Type)
+		assert.Equal(t, "Nap started", msg.Title)
+		assert.Equal(t, event.ID.String(), msg.Tag)
+		assert.Equal(t, f.entityID, msg.EntityID)
+		assert.Equal(t, &event.Realization.ID, msg.RealizationID)
+	})
+
+	t.Run("Respects what caregivers want to hear about", func(t *testing.T) {
+		f := newNotifyFixture(t)
+		startsOnly := f.following()
+		startsOnly.ActivityCompleted = false
+		starts := f.caregiver(t, startsOnly)
+		everything := f.caregiver(t, f.following())
+
+		require.NoError(t, f.sink.Publish(f.ctx, f.event(outbox.EventActivityCompleted, uuid.Nil)))
+
+		pushed := f.pushed(t)
+		assert.Empty(t, pushed[starts])
+		require.Len(t, pushed[everything], 1)
+		assert.Equal(t, "Nap completed", pushed[everything][0].Title)
+	})
+
+	t.Run("Ignores other events", func(t *testing.T) {
+		f := newNotifyFixture(t)
+		f.caregiver(t, f.following())
+
+		require.NoError(t, f.sink.Publish(f.ctx, f.event(outbox.EventActivityPaused, uuid.Nil)))
+		assert.Empty(t, f.service.received())
+	})
+
+	t.Run("Forgets subscriptions the push service has dropped", func(t *testing.T) {
+		f := newNotifyFixture(t)
+		gone := f.caregiver(t, f.following())
+		f.service.answer("/"+gone.String(), http.StatusGone)
+
+		require.NoError(t, f.sink.Publish(f.ctx, f.event(outbox.EventActivityStarted, uuid.Nil)))
+		subs, err := f.store.ListSubscriptions(f.ctx, gone)
+		require.NoError(t, err)
+		assert.Empty(t, subs)
+	})
+
+	t.Run("Retries only what may succeed later", func(t *testing.T) {
+		f := newNotifyFixture(t)
+		refused := f.caregiver(t, f.following())
+		f.service.answer("/"+refused.String(), http.StatusBadRequest)
+		require.NoError(t, f.sink.Publish(f.ctx, f.event(outbox.EventActivityStarted, uuid.Nil)))
+
+		busy := f.caregiver(t, f.following())
+		f.service.answer("/"+busy.String(), http.StatusServiceUnavailable)
+		assert.Error(t, f.sink.Publish(f.ctx, f.event(outbox.EventActivityStarted, uuid.Nil)))
+	})
+}
+
+func TestAlertNotifier(t *testing.T) {
+	f := newNotifyFixture(t)
+	follower := f.caregiver(t, f.following())
+	quiet := f.following()
+	quiet.Alerts = false
+	f.caregiver(t, quiet)
+	assert.Equal(t, alert.ChannelPush, f.notifier.Channel())
+
+	a := alert.Alert{
+		ID:       uuid.New(),
+		Kind:     alert.KindRunningLong,
+		EntityID: f.entityID,
+		Message:  "Nap has been going on for 3h",
+	}
+	require.NoError(t, f.notifier.Notify(f.ctx, alert.Rule{}, a))
+
+	pushed := f.pushed(t)
+	require.Len(t, pushed, 1)
+	require.Len(t, pushed[follower], 1)
+	msg := pushed[follower][0]
+	assert.Equal(t, "alert.triggered", msg.Type)
+	assert.Equal(t, a.Message, msg.Body)
+	assert.Equal(t, a.ID.String(), msg.Tag)
+	assert.Equal(t, &a.ID, msg.AlertID)
+	assert.Equal(t, "high", f.service.received()[0].header.Get("Urgency"))
+}
@@ -0,0 +1,141 @@
+package push_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/luisteixeira/waypoint/backend/internal/domain"
+	"github.com/luisteixeira/waypoint/backend/internal/middleware"
+	"github.com/luisteixeira/waypoint/backend/internal/push"
+	"github.com/luisteixeira/waypoint/backend/internal/repository/memory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// caregiverContext acts for the caregiver of the family.
+func caregiverContext(familyID, caregiverID uuid.UUID) context.Context {
+	ctx := context.WithValue(context.Background(), middleware.FamilyIDKey, familyID)
+	return context.WithValue(ctx, middleware.CaregiverIDKey, caregiverID)
+}
+
+func TestService(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	newService := func(t *testing.T) (*push.Service, *push.Keys) {
+		keys := generateKeys(t)
+		return push.NewService(memory.NewInMemoryPushStore(), keys, func() time.Time { return now }), keys
+	}
+	input := func(b *browser) push.SubscriptionInput {
+		sub := b.subscription("https://push.example.com/send/" + uuid.NewString())
+		return push.SubscriptionInput{Endpoint: sub.Endpoint, P256DH: sub.P256DH, Auth: sub.Auth}
+	}
+
+	t.Run("Hands out the public key", func(t *testing.T) {
+		service, keys := newService(t)
+		assert.Equal(t, keys.PublicKey(), service.PublicKey())
+	})
+
+	t.Run("Subscribes the caregiver", func(t *testing.T) {
+		service, _ := newService(t)
+		familyID, caregiverID := uuid.New(), uuid.New()
+		ctx := caregiverContext(familyID, caregiverID)
+
+		sub, err := service.Subscribe(ctx, input(newBrowser(t)))
+		require.NoError(t, err)
+		assert.Equal(t, familyID, sub.FamilyID)
+		assert.Equal(t, caregiverID, sub.CaregiverID)
+		assert.Equal(t, now, sub.CreatedAt)
+
+		_, err = service.Subscribe(caregiverContext(familyID, uuid.New()), input(newBrowser(t)))
+		require.NoError(t, err)
+		subs, err := service.Subscriptions(ctx)
+		require.NoError(t, err)
+		require.Len(t, subs, 1, "only the caregiver's own")
+		assert.Equal(t, sub.ID, subs[0].ID)
+
+		require.NoError(t, service.Unsubscribe(ctx, sub.ID))
+		assert.ErrorIs(t, service.Unsubscribe(ctx, sub.ID), domain.ErrNotFound)
+	})
+
+	t.Run("Checks the subscription", func(t *testing.T) {
+		service, _ := newService(t)
+		ctx := caregiverContext(uuid.New(), uuid.New())
+		b := newBrowser(t)
+
+		plain := input(b)
+		plain.Endpoint = "http://push.example.com/send/1"
+		noKey := input(b)
+		noKey.P256DH = "bm90IGEga2V5"
+		shortAuth := input(b)
+		shortAuth.Auth = "c2hvcnQ"
+
+		for _, tt := range []struct {
+			input push.SubscriptionInput
+			field string
+		}{
+			{plain, "endpoint"},
+			{noKey, "keys"},
+			{shortAuth, "keys"},
+		} {
+			_, err := service.Subscribe(ctx, tt.input)
+			var validation *domain.ValidationError
+			require.ErrorAs(t, err, &validation)
+			assert.Equal(t, tt.field, validation.Field)
+		}
+	})
+
+	t.Run("Needs to know the caregiver", func(t *testing.T) {
+		service, _ := newService(t)
+		ctx := context.WithValue(context.Background(), middleware.FamilyIDKey, uuid.New())
+
+		_, err := service.Subscribe(ctx, input(newBrowser(t)))
+		var validation *domain.ValidationError
+		require.ErrorAs(t, err, &validation)
+		assert.Equal(t, "X-Caregiver-ID", validation.Field)
+
+		_, err = service.Subscriptions(ctx)
+		assert.ErrorAs(t, err, &validation)
+		assert.ErrorAs(t, service.Unsubscribe(ctx, uuid.New()), &validation)
+		_, err = service.Preferences(ctx)
+		assert.ErrorAs(t, err, &validation)
+		_, err = service.SetPreferences(ctx, push.PreferencesInput{})
+		assert.ErrorAs(t, err, &validation)
+	})
+
+	t.Run("Defaults the preferences", func(t *testing.T) {
+		service, _ := newService(t)
+		familyID, caregiverID := uuid.New(), uuid.New()
+
+		prefs, err := service.Preferences(caregiverContext(familyID, caregiverID))
+		require.NoError(t, err)
+		assert.Equal(t, push.DefaultPreferences(familyID, caregiverID), *prefs)
+		assert.Empty(t, prefs.EntityIDs, "no one is followed until the caregiver says so")
+		assert.True(t, prefs.UpdatedAt.IsZero())
+	})
+
+	t.Run("Saves the preferences", func(t *testing.T) {
+		service, _ := newService(t)
+		ctx := caregiverContext(uuid.New(), uuid.New())
+		first, second := uuid.New(), uuid.New()
+
+		saved, err := service.SetPreferences(ctx, push.PreferencesInput{
+			EntityIDs:       []uuid.UUID{first, second, first},
+			ActivityStarted: true,
+		})
+		require.NoError(t, err)
+		assert.Equal(t, []uuid.UUID{first, second}, saved.EntityIDs, "duplicates are dropped")
+		assert.Equal(t, now, saved.UpdatedAt)
+
+		prefs, err := service.Preferences(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, saved, prefs)
+		assert.False(t, prefs.ActivityCompleted)
+		assert.False(t, prefs.Alerts)
+
+		_, err = service.SetPreferences(ctx, push.PreferencesInput{EntityIDs: []uuid.UUID{uuid.Nil}})
+		var validation *domain.ValidationError
+		require.ErrorAs(t, err, &validation)
+		assert.Equal(t, "entity_ids", validation.Field)
+	})
+}
@@ -0,0 +1,473 @@
+package conformance
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/luisteixeira/waypoint/backend/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func runActivityTests(t *testing.T, open func(t *testing.T) Backend) {
+	runCases(t, open, []testCase{
+		{"Create and retrieve", testCreateAndRetrieve},
+		{"Create assigns a missing ID", testCreateAssignsID},
+		{"Caregivers", testCaregivers},
+		{"Not found", testNotFound},
+		{"Missing family", testMissingFamily},
+		{"Tenant isolation", testTenantIsolation},
+		{"Active lookups", testActiveLookups},
+		{"Listing", testListRealizations},
+		{"One active realization per child", testActiveEntityInvariant},
+		{"Event stream", testEventStream},
+		{"Optimistic concurrency", testOptimisticConcurrency},
+	})
+}
+
+func (f family) newRealization(status domain.ActivityStatus) *domain.ActivityRealization {
+	started := time.Now().Add(-time.Hour).Round(time.Second)
+	return &domain.ActivityRealization{
+		ID:            uuid.New(),
+		DefinitionID:  f.newDefinition("Nap"),
+		EntityID:      f.newEntity(),
+		CaregiversIDs: []uuid.UUID{},
+		Status:        status,
+		StartedAt:     &started,
+	}
+}
+
+func testCreateAndRetrieve(t *testing.T, b Backend) {
+	f := newFamily(t, b)
+	input := f.newRealization(domain.StatusInProgress)
+	input.PlannedFor = ptr(input.StartedAt.Add(-5 * time.Minute))
+	id := input.ID
+
+	require.NoError(t, f.Activities.CreateRealization(f.ctx, input))
+	assert.Equal(t, id, input.ID, "the caller's ID must be kept")
+	assert.Equal(t, f.id, input.FamilyID, "the family comes from the context")
+	assert.Equal(t, 1, input.Version)
+
+	output, err := f.Activities.GetRealizationByID(f.ctx, id)
+	require.NoError(t, err)
+	assert.Equal(t, id, output.ID)
+	assert.Equal(t, f.id, output.FamilyID)
+	assert.Equal(t, input.DefinitionID, output.DefinitionID)
+	assert.Equal(t, input.EntityID, output.EntityID)
+	assert.Equal(t, domain.StatusInProgress, output.Status)
+	require.NotNil(t, output.PlannedFor)
+	assert.True(t, input.PlannedFor.Equal(*output.PlannedFor))
+	assert.True(t, input.StartedAt.Equal(*output.StartedAt))
+	assert.Nil(t, output.FinishedAt)
+	assert.Equal(t, 1, output.Version)
+}
+
+func testCreateAssignsID(t *testing.T, b Backend) {
+	f := newFamily(t, b)
+	input := f.newRealization(domain.StatusPlanned)
+	input.ID = uuid.Nil
+
+	require.NoError(t, f.Activities.CreateRealization(f.ctx, input))
+	require.NotEqual(t, uuid.Nil, input.ID)
+
+	_, err := f.Activities.GetRealizationByID(f.ctx, input.ID)
+	assert.NoError(t, err)
+}
+
+func testCaregivers(t *testing.T, b Backend) {
+	f := newFamily(t, b)
+	first, second, third := f.newCaregiver(), f.newCaregiver(), f.newCaregiver()
+
+	ar := f.newRealization(domain.StatusInProgress)
+	ar.CaregiversIDs = []uuid.UUID{first, second}
+	require.NoError(t, f.Activities.CreateRealization(f.ctx, ar))
+
+	stored, err := f.Activities.GetRealizationByID(f.ctx, ar.ID)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []uuid.UUID{first, second}, stored.CaregiversIDs)
+
+	active, err := f.Activities.GetActiveByEntity(f.ctx, ar.EntityID)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []uuid.UUID{first, second}, active.CaregiversIDs)
+
+	t.Run("Update replaces the set", func(t *testing.T) {
+		ar.CaregiversIDs = []uuid.UUID{third}
+		require.NoError(t, f.Activities.UpdateRealization(f.ctx, ar))
+
+		stored, err := f.Activities.GetRealizationByID(f.ctx, ar.ID)
+		require.NoError(t, err)
+		assert.Equal(t, []uuid.UUID{third}, stored.CaregiversIDs)
+	})
+
+	t.Run("Update can clear the set", func(t *testing.T) {
+		ar.CaregiversIDs = nil
+		require.NoError(t, f.Activities.UpdateRealization(f.ctx, ar))
+
+		stored, err := f.Activities.GetRealizationByID(f.ctx, ar.ID)
+		require.NoError(t, err)
+		assert.Empty(t, stored.CaregiversIDs)
+	})
+
+	t.Run("Stored caregivers do not alias the caller's slice", func(t *testing.T) {
+		other := f.newRealization(domain.StatusPlanned)
+		other.CaregiversIDs = []uuid.UUID{first}
+		require.NoError(t, f.Activities.CreateRealization(f.ctx, other))
+		other.CaregiversIDs[0] = second
+
+		stored, err := f.Activities.GetRealizationByID(f.ctx, other.ID)
+		require.NoError(t, err)
+		assert.Equal(t, []uuid.UUID{first}, stored.CaregiversIDs)
+	})
+}
+
+func testNotFound(t *testing.T, b Backend) {
+	f := newFamily(t, b)
+	missing := f.newRealization(domain.StatusInProgress)
+	missing.Version = 1
+
+	_, err := f.Activities.GetRealizationByID(f.ctx, missing.ID)
+	assertNotFound(t, err, missing.ID)
+
+	err = f.Activities.UpdateRealization(f.ctx, missing)
+	assertNotFound(t, err, missing.ID)
+
+	_, err = f.Activities.ListEvents(f.ctx, missing.ID)
+	assertNotFound(t, err, missing.ID)
+}
+
+func testMissingFamily(t *testing.T, b Backend) {
+	f := newFamily(t, b)
+	ar := f.newRealization(domain.StatusInProgress)
+	ctx := context.Background()
+
+	assert.ErrorIs(t, f.Activities.CreateRealization(ctx, ar), domain.ErrUnauthorized)
+	assert.ErrorIs(t, f.Activities.UpdateRealization(ctx, ar), domain.ErrUnauthorized)
+	assert.ErrorIs(t, f.Activities.ReplaceRealization(ctx, ar), domain.ErrUnauthorized)
+
+	_, err := f.Activities.GetRealizationByID(ctx, ar.ID)
+	assert.ErrorIs(t, err, domain.ErrUnauthorized)
+	_, err = f.Activities.GetActiveByEntity(ctx, ar.EntityID)
+	assert.ErrorIs(t, err, domain.ErrUnauthorized)
+	_, err = f.Activities.ListEvents(ctx, ar.ID)
+	assert.ErrorIs(t, err, domain.ErrUnauthorized)
+	_, err = f.Activities.ListRealizations(ctx, domain.RealizationFilter{})
+	assert.ErrorIs(t, err, domain.ErrUnauthorized)
+}
+
+func testTenantIsolation(t *testing.T, b Backend) {
+	f := newFamily(t, b)
+	intruder := newFamily(t, b)
+
+	ar := f.newRealization(domain.StatusInProgress)
+	event := domain.RealizationEvent{Type: domain.EventStarted, OccurredAt: *ar.StartedAt}
+	require.NoError(t, f.Activities.CreateRealization(f.ctx, ar, event))
+
+	t.Run("Reads", func(t *testing.T) {
+		output, err := f.Activities.GetRealizationByID(intruder.ctx, ar.ID)
+		assertNotFound(t, err, ar.ID)
+		assert.Nil(t, output)
+
+		active, err := f.Activities.GetActiveByEntity(intruder.ctx, ar.EntityID)
+		assert.NoError(t, err)
+		assert.Nil(t, active)
+
+		_, err = f.Activities.ListEvents(intruder.ctx, ar.ID)
+		assertNotFound(t, err, ar.ID)
+	})
+
+	t.Run("Updates", func(t *testing.T) {
+		// The realization still names its own family, which must not let
+		// another tenant write to it.
+		hijack := *ar
+		hijack.Status = domain.StatusCancelled
+
+		err := f.Activities.UpdateRealization(intruder.ctx, &hijack)
+		assertNotFound(t, err, ar.ID)
+
+		stored, err := f.Activities.GetRealizationByID(f.ctx, ar.ID)
+		require.NoError(t, err)
+		assert.Equal(t, domain.StatusInProgress, stored.Status)
+		assert.Equal(t, 1, stored.Version)
+	})
+
+	t.Run("Children are scoped to their family", func(t *testing.T) {
+		// Another family may have a child with the same ID in progress.
+		other := intruder.newRealization(domain.StatusInProgress)
+		other.EntityID = ar.EntityID
+		assert.NoError(t, f.Activities.CreateRealization(intruder.ctx, other))
+	})
+}
+
+func testActiveLookups(t *testing.T, b Backend) {
+	f := newFamily(t, b)
+	entityID := f.newEntity()
+
+	active, err := f.Activities.GetActiveByEntity(f.ctx, entityID)
+	require.NoError(t, err)
+	assert.Nil(t, active, "a child without realizations is free")
+
+	planned := f.newRealization(domain.StatusPlanned)
+	planned.EntityID = entityID
+	require.NoError(t, f.Activities.CreateRealization(f.ctx, planned))
+
+	active, err = f.Activities.GetActiveByEntity(f.ctx, entityID)
+	require.NoError(t, err)
+	assert.Nil(t, active, "planned realizations are not active")
+
+	running := f.newRealization(domain.StatusInProgress)
+	running.EntityID = entityID
+	require.NoError(t, f.Activities.CreateRealization(f.ctx, running))
+
+	active, err = f.Activities.GetActiveByEntity(f.ctx, entityID)
+	require.NoError(t, err)
+	require.NotNil(t, active)
+	assert.Equal(t, running.ID, active.ID)
+
+	active, err = f.Activities.GetActiveByEntity(f.ctx, f.newEntity())
+	require.NoError(t, err)
+	assert.Nil(t, active, "other children stay free")
+
+	finished := time.Now()
+	running.Status = domain.StatusCompleted
+	running.FinishedAt = &finished
+	require.NoError(t, f.Activities.UpdateRealization(f.ctx, running))
+
+	active, err = f.Activities.GetActiveByEntity(f.ctx, entityID)
+	require.NoError(t, err)
+	assert.Nil(t, active, "completing frees the child")
+
+	t.Run("Starting a second activity for a busy child is rejected", func(t *testing.T) {
+		planned.Status = domain.StatusInProgress
+		require.NoError(t, f.Activities.UpdateRealization(f.ctx, planned))
+
+		another := f.newRealization(domain.StatusPlanned)
+		another.EntityID = entityID
+		require.NoError(t, f.Activities.CreateRealization(f.ctx, another))
+
+		another.Status = domain.StatusInProgress
+		assert.ErrorIs(t, f.Activities.UpdateRealization(f.ctx, another), domain.ErrEntityBusy)
+	})
+}
+
+// testActiveEntityInvariant races many inserts of an in-progress realization
+// for the same child, bypassing the service's pre-check, and expects the
+// repository to let exactly one through.
+func testActiveEntityInvariant(t *testing.T, b Backend) {
+	const attempts = 20
+	f := newFamily(t, b)
+	entityID := f.newEntity()
+	definitionID := f.newDefinition("Nap")
+
+	var wg sync.WaitGroup
+	errs := make(chan error, attempts)
+	start := make(chan struct{})
+
+	for i := 0; i < attempts; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-start
+
+			now := time.Now()
+			errs <- f.Activities.CreateRealization(f.ctx, &domain.ActivityRealization{
+				ID:           uuid.New(),
+				DefinitionID: definitionID,
+				EntityID:     entityID,
+				Status:       domain.StatusInProgress,
+				StartedAt:    &now,
+			})
+		}()
+	}
+
+	close(start)
+	wg.Wait()
+	close(errs)
+
+	succeeded := 0
+	for err := range errs {
+		if err == nil {
+			succeeded++
+			continue
+		}
+		assert.ErrorIs(t, err, domain.ErrEntityBusy)
+	}
+	assert.Equal(t, 1, succeeded, "exactly one concurrent start should win")
+
+	active, err := f.Activities.GetActiveByEntity(f.ctx, entityID)
+	require.NoError(t, err)
+	require.NotNil(t, active)
+}
+
+func testEventStream(t *testing.T, b Backend) {
+	f := newFamily(t, b)
+	started := time.Now().Add(-time.Hour).Round(time.Second)
+	definitionID := f.newDefinition("Nap")
+
+	ar := &domain.ActivityRealization{ID: uuid.New()}
+	events := []domain.RealizationEvent{
+		{RealizationID: ar.ID, Type: domain.EventPlanned, OccurredAt: started, Data: domain.EventData{
+			DefinitionID:  &definitionID,
+			EntityID:      ptr(f.newEntity()),
+			CaregiversIDs: []uuid.UUID{f.newCaregiver()},
+		}},
+		{RealizationID: ar.ID, Type: domain.EventStarted, OccurredAt: started},
+	}
+	for _, e := range events {
+		ar.Apply(e)
+	}
+	require.NoError(t, f.Activities.CreateRealization(f.ctx, ar, events...))
+	assert.Equal(t, 1, ar.Version)
+
+	paused := domain.RealizationEvent{RealizationID: ar.ID, Type: domain.EventPaused, OccurredAt: started.Add(time.Minute)}
+	ar.Apply(paused)
+	require.NoError(t, f.Activities.UpdateRealization(f.ctx, ar, paused))
+	assert.Equal(t, 2, ar.Version)
+
+	t.Run("Lists events in order", func(t *testing.T) {
+		stored, err := f.Activities.ListEvents(f.ctx, ar.ID)
+		require.NoError(t, err)
+		require.Len(t, stored, 3)
+		assert.Equal(t, domain.EventPlanned, stored[0].Type)
+		assert.Equal(t, domain.EventPaused, stored[2].Type)
+		assert.True(t, stored[0].Sequence < stored[2].Sequence)
+		assert.Equal(t, ar.CaregiversIDs, stored[0].Data.CaregiversIDs)
+		for _, e := range stored {
+			assert.Equal(t, ar.ID, e.RealizationID)
+			assert.Equal(t, f.id, e.FamilyID)
+		}
+
+		projected := domain.Project(stored)
+		assert.Equal(t, domain.StatusPaused, projected.Status)
+		assert.True(t, started.Equal(*projected.StartedAt))
+	})
+
+	t.Run("Replaces the projection and bumps the version", func(t *testing.T) {
+		stored, err := f.Activities.ListEvents(f.ctx, ar.ID)
+		require.NoError(t, err)
+
+		rebuilt := domain.Project(stored)
+		require.NoError(t, f.Activities.ReplaceRealization(f.ctx, rebuilt))
+		assert.Equal(t, 3, rebuilt.Version)
+
+		current, err := f.Activities.GetRealizationByID(f.ctx, ar.ID)
+		require.NoError(t, err)
+		assert.Equal(t, domain.StatusPaused, current.Status)
+		assert.Equal(t, 3, current.Version)
+	})
+}
+
+func testOptimisticConcurrency(t *testing.T, b Backend) {
+	f := newFamily(t, b)
+	ar := f.newRealization(domain.StatusInProgress)
+	require.NoError(t, f.Activities.CreateRealization(f.ctx, ar))
+
+	outdated := *ar
+	ar.Status = domain.StatusPaused
+	require.NoError(t, f.Activities.UpdateRealization(f.ctx, ar))
+	assert.Equal(t, 2, ar.Version)
+
+	outdated.Status = domain.StatusCancelled
+	err := f.Activities.UpdateRealization(f.ctx, &outdated)
+
+	var stale *domain.StaleVersionError
+	require.ErrorAs(t, err, &stale)
+	assert.ErrorIs(t, err, domain.ErrConflict)
+	assert.Equal(t, 2, stale.Current.Version)
+	assert.Equal(t, domain.StatusPaused, stale.Current.Status)
+	assert.Equal(t, 1, outdated.Version, "a rejected update leaves the version alone")
+}
+
+func assertNotFound(t *testing.T, err error, id uuid.UUID) {
+	t.Helper()
+
+	var notFound *domain.NotFoundError
+	if assert.ErrorAs(t, err, &notFound) {
+		assert.Equal(t, "activity realization", notFound.Resource)
+		assert.Equal(t, id, notFound.ID)
+	}
+	assert.ErrorIs(t, err, domain.ErrNotFound)
+}
+
+func ptr[T any](v T) *T {
+	return &v
+}
+
+func testListRealizations(t *testing.T, b Backend) {
+	f := newFamily(t, b)
+	other := newFamily(t, b)
+	entityID := f.newEntity()
+	base := time.Now().Add(-4 * time.Hour).Round(time.Second)
+
+	create := func(status domain.ActivityStatus, started *time.Time) *domain.ActivityRealization {
+		ar := f.newRealization(status)
+		ar.EntityID = entityID
+		ar.StartedAt = started
+		require.NoError(t, f.Activities.CreateRealization(f.ctx, ar))
+		return ar
+	}
+	at := func(d time.Duration) *time.Time {
+		t := base.Add(d)
+		return &t
+	}
+	unstarted := create(domain.StatusPlanned, nil)
+	oldest := create(domain.StatusCompleted, at(0))
+	newest := create(domain.StatusInProgress, at(2*time.Hour))
+	middle := create(domain.StatusPlanned, at(time.Hour))
+	require.NoError(t, other.Activities.CreateRealization(other.ctx, other.newRealization(domain.StatusCompleted)))
+
+	ids := func(filter domain.RealizationFilter) []uuid.UUID {
+		t.Helper()
+		ars, err := f.Activities.ListRealizations(f.ctx, filter)
+		require.NoError(t, err)
+		ids := make([]uuid.UUID, len(ars))
+		for i, ar := range ars {
+			ids[i] = ar.ID
+		}
+		return ids
+	}
+
+	assert.Equal(t, []uuid.UUID{newest.ID, middle.ID, oldest.ID, unstarted.ID}, ids(domain.RealizationFilter{}),
+		"most recently started first, unstarted last, other families left out")
+	assert.Equal(t, []uuid.UUID{newest.ID, middle.ID}, ids(domain.RealizationFilter{Limit: 2}))
+	assert.Equal(t, []uuid.UUID{middle.ID, unstarted.ID},
+		ids(domain.RealizationFilter{Statuses: []domain.ActivityStatus{domain.StatusPlanned}}))
+	assert.Equal(t, []uuid.UUID{newest.ID, oldest.ID},
+		ids(domain.RealizationFilter{Statuses: []domain.ActivityStatus{domain.StatusInProgress, domain.StatusCompleted}}))
+	assert.Equal(t, []uuid.UUID{middle.ID, oldest.ID}, ids(domain.RealizationFilter{StartedBefore: at(time.Hour)}),
+		"the bound is inclusive and unstarted realizations never match")
+	assert.Equal(t, []uuid.UUID{newest.ID}, ids(domain.RealizationFilter{DefinitionID: newest.DefinitionID, EntityID: entityID, Statuses: []domain.ActivityStatus{domain.StatusInProgress}}))
+	assert.Empty(t, ids(domain.RealizationFilter{EntityID: f.newEntity()}))
+
+	t.Run("Caregivers are listed", func(t *testing.T) {
+		caregiverID := f.newCaregiver()
+		middle.CaregiversIDs = []uuid.UUID{caregiverID}
+		require.NoError(t, f.Activities.UpdateRealization(f.ctx, middle))
+
+		ars, err := f.Activities.ListRealizations(f.ctx, domain.RealizationFilter{Statuses: []domain.ActivityStatus{domain.StatusPlanned}, Limit: 1})
+		require.NoError(t, err)
+		require.Len(t, ars, 1)
+		assert.Equal(t, []uuid.UUID{caregiverID}, ars[0].CaregiversIDs)
+		assert.Equal(t, 2, ars[0].Version)
+	})
+
+	t.Run("Planned time bounds", func(t *testing.T) {
+		entityID := f.newEntity()
+		plan := func(plannedFor *time.Time) *domain.ActivityRealization {
+			ar := f.newRealization(domain.StatusPlanned)
+			ar.EntityID = entityID
+			ar.StartedAt = nil
+			ar.PlannedFor = plannedFor
+			require.NoError(t, f.Activities.CreateRealization(f.ctx, ar))
+			return ar
+		}
+		early := plan(at(0))
+		plan(at(time.Hour + time.Second))
+		plan(nil)
+
+		assert.Equal(t, []uuid.UUID{early.ID}, ids(domain.RealizationFilter{EntityID: entityID, PlannedBefore: at(time.Hour)}),
+			"realizations planned for no particular time never match")
+	})
+}
@@ -0,0 +1,295 @@
+package conformance
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/luisteixeira/waypoint/backend/internal/alert"
+	"github.com/luisteixeira/waypoint/backend/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func runAlertTests(t *testing.T, open func(t *testing.T) Backend) {
+	runCases(t, open, []testCase{
+		{"Stores rules", testAlertRules},
+		{"Lists rules across families", testAlertAllRules},
+		{"Stores and updates alerts", testAlerts},
+		{"One unresolved alert per rule and subject", testAlertDeduplication},
+		{"Lists alerts by status", testAlertList},
+		{"Deleting a rule drops its alerts", testAlertRuleDelete},
+		{"Keeps families apart", testAlertByFamily},
+		{"Missing family", testAlertMissingFamily},
+	})
+}
+
+func (f family) newRule(kind alert.Kind, entityID uuid.UUID) alert.Rule {
+	f.t.Helper()
+
+	rule := alert.Rule{
+		ID:           uuid.New(),
+		Kind:         kind,
+		DefinitionID: f.newDefinition("Nap"),
+		EntityID:     entityID,
+		Threshold:    3 * time.Hour,
+		Channels:     []alert.Channel{alert.ChannelEmail, alert.ChannelWebhook},
+		Email:        "parents@example.com",
+		CreatedAt:    time.Now().UTC().Truncate(time.Microsecond),
+	}
+	require.NoError(f.t, f.Alerts.CreateRule(f.ctx, &rule))
+	return rule
+}
+
+func (f family) newAlert(rule alert.Rule, realizationID *uuid.UUID, triggeredAt time.Time) alert.Alert {
+	f.t.Helper()
+
+	a := alert.Alert{
+		ID:            uuid.New(),
+		RuleID:        rule.ID,
+		Kind:          rule.Kind,
+		DefinitionID:  rule.DefinitionID,
+		EntityID:      f.newEntity(),
+		RealizationID: realizationID,
+		Message:       "Nap has been going on for 3h",
+		Status:        alert.StatusOpen,
+		TriggeredAt:   triggeredAt.UTC().Truncate(time.Microsecond),
+	}
+	if rule.EntityID != uuid.Nil {
+		a.EntityID = rule.EntityID
+	}
+	require.NoError(f.t, f.Alerts.CreateAlert(f.ctx, &a))
+	return a
+}
+
+func (f family) alertIDs(statuses ...alert.Status) []uuid.UUID {
+	f.t.Helper()
+
+	alerts, err := f.Alerts.ListAlerts(f.ctx, statuses, 0)
+	require.NoError(f.t, err)
+	ids := make([]uuid.UUID, len(alerts))
+	for i, a := range alerts {
+		ids[i] = a.ID
+	}
+	return ids
+}
+
+func testAlertRules(t *testing.T, b Backend) {
+	f := newFamily(t, b)
+	everyChild := f.newRule(alert.KindRunningLong, uuid.Nil)
+	oneChild := f.newRule(alert.KindNoActivity, f.newEntity())
+	assert.Equal(t, f.id, everyChild.FamilyID, "the family comes from the context")
+
+	rules, err := f.Alerts.ListRules(f.ctx)
+	require.NoError(t, err)
+	require.Len(t, rules, 2)
+	for i, want := range []alert.Rule{everyChild, oneChild} {
+		got := rules[i]
+		assert.Equal(t, want.ID, got.ID)
+		assert.Equal(t, f.id, got.FamilyID)
+		assert.Equal(t, want.Kind, got.Kind)
+		assert.Equal(t, want.DefinitionID, got.DefinitionID)
+		assert.Equal(t, want.EntityID, got.EntityID)
+		assert.Equal(t, want.Threshold, got.Threshold)
+		assert.Equal(t, want.Channels, got.Channels)
+		assert.Equal(t, want.Email, got.Email)
+		assert.True(t, want.CreatedAt.Equal(got.CreatedAt))
+	}
+
+	t.Run("A rule without channels", func(t *testing.T) {
+		other := newFamily(t, b)
+		rule := alert.Rule{ID: uuid.New(), Kind: alert.KindNotStarted, DefinitionID: other.newDefinition("Feed"), Threshold: time.Minute, CreatedAt: time.Now()}
+		require.NoError(t, other.Alerts.CreateRule(other.ctx, &rule))
+
+		rules, err := other.Alerts.ListRules(other.ctx)
+		require.NoError(t, err)
+		require.Len(t, rules, 1)
+		assert.Empty(t, rules[0].Channels)
+	})
+}
+
+func testAlertAllRules(t *testing.T, b Backend) {
+	f, other := newFamily(t, b), newFamily(t, b)
+	mine := f.newRule(alert.KindRunningLong, uuid.Nil)
+	theirs := other.newRule(alert.KindRunningLong, uuid.Nil)
+
+	// The store may be shared with other tests.
+	rules, err := f.Alerts.AllRules(context.Background())
+	require.NoError(t, err)
+	found := map[uuid.UUID]uuid.UUID{}
+	for _, rule := range rules {
+		found[rule.ID] = rule.FamilyID
+	}
+	assert.Equal(t, f.id, found[mine.ID])
+	assert.Equal(t, other.id, found[theirs.ID])
+}
+
+func testAlerts(t *testing.T, b Backend) {
+	f := newFamily(t, b)
+	rule := f.newRule(alert.KindRunningLong, uuid.Nil)
+	realizationID := uuid.New()
+	a := f.newAlert(rule, &realizationID, time.Now())
+	assert.Equal(t, f.id, a.FamilyID, "the family comes from the context")
+
+	stored, err := f.Alerts.GetAlert(f.ctx, a.ID)
+	require.NoError(t, err)
+	assert.Equal(t, a.RuleID, stored.RuleID)
+	assert.Equal(t, a.Kind, stored.Kind)
+	assert.Equal(t, a.DefinitionID, stored.DefinitionID)
+	assert.Equal(t, a.EntityID, stored.EntityID)
+	require.NotNil(t, stored.RealizationID)
+	assert.Equal(t, realizationID, *stored.RealizationID)
+	assert.Equal(t, a.Message, stored.Message)
+	assert.Equal(t, alert.StatusOpen, stored.Status)
+	assert.True(t, a.TriggeredAt.Equal(stored.TriggeredAt))
+	assert.Nil(t, stored.SnoozedUntil)
+	assert.Nil(t, stored.AcknowledgedAt)
+	assert.Nil(t, stored.ResolvedAt)
+
+	until := time.Now().Add(time.Hour).UTC().Truncate(time.Microsecond)
+	stored.Status = alert.StatusSnoozed
+	stored.SnoozedUntil = &until
+	stored.Message = "Nap has been going on for 4h"
+	require.NoError(t, f.Alerts.UpdateAlert(f.ctx, stored))
+
+	updated, err := f.Alerts.GetAlert(f.ctx, a.ID)
+	require.NoError(t, err)
+	assert.Equal(t, alert.StatusSnoozed, updated.Status)
+	assert.Equal(t, "Nap has been going on for 4h", updated.Message)
+	require.NotNil(t, updated.SnoozedUntil)
+	assert.True(t, until.Equal(*updated.SnoozedUntil))
+
+	resolved := time.Now().UTC().Truncate(time.Microsecond)
+	updated.Status = alert.StatusResolved
+	updated.SnoozedUntil = nil
+	updated.ResolvedAt = &resolved
+	require.NoError(t, f.Alerts.UpdateAlert(f.ctx, updated))
+
+	updated, err = f.Alerts.GetAlert(f.ctx, a.ID)
+	require.NoError(t, err)
+	assert.Equal(t, alert.StatusResolved, updated.Status)
+	assert.Nil(t, updated.SnoozedUntil)
+	require.NotNil(t, updated.ResolvedAt)
+	assert.True(t, resolved.Equal(*updated.ResolvedAt))
+
+	t.Run("Not found", func(t *testing.T) {
+		missing := uuid.New()
+		_, err := f.Alerts.GetAlert(f.ctx, missing)
+		assert.ErrorIs(t, err, domain.ErrNotFound)
+		err = f.Alerts.UpdateAlert(f.ctx, &alert.Alert{ID: missing, Status: alert.StatusOpen})
+		assert.ErrorIs(t, err, domain.ErrNotFound)
+	})
+}
+
+func testAlertDeduplication(t *testing.T, b Backend) {
+	f := newFamily(t, b)
+	entityID := f.newEntity()
+	rule := f.newRule(alert.KindNoActivity, entityID)
+	first := f.newAlert(rule, nil, time.Now())
+
+	repeat := alert.Alert{ID: uuid.New(), RuleID: rule.ID, Kind: rule.Kind, DefinitionID: rule.DefinitionID, EntityID: entityID, Status: alert.StatusOpen, TriggeredAt: time.Now()}
+	err := f.Alerts.CreateAlert(f.ctx, &repeat)
+	assert.ErrorIs(t, err, alert.ErrAlreadyRaised)
+	assert.ErrorIs(t, err, domain.ErrConflict)
+
+	// Another rule, or another subject, may alert at the same time.
+	f.newAlert(f.newRule(alert.KindNoActivity, entityID), nil, time.Now())
+	realizationID := uuid.New()
+	f.newAlert(rule, &realizationID, time.Now())
+
+	// Once resolved, the subject can alert again.
+	resolved := time.Now()
+	first.Status = alert.StatusResolved
+	first.ResolvedAt = &resolved
+	require.NoError(t, f.Alerts.UpdateAlert(f.ctx, &first))
+	assert.NoError(t, f.Alerts.CreateAlert(f.ctx, &repeat))
+}
+
+func testAlertList(t *testing.T, b Backend) {
+	f := newFamily(t, b)
+	rule := f.newRule(alert.KindRunningLong, uuid.Nil)
+	now := time.Now()
+	subject := func() *uuid.UUID {
+		id := uuid.New()
+		return &id
+	}
+
+	oldest := f.newAlert(rule, subject(), now.Add(-2*time.Hour))
+	newest := f.newAlert(rule, subject(), now)
+	resolved := f.newAlert(rule, subject(), now.Add(-time.Hour))
+	resolved.Status = alert.StatusResolved
+	resolved.ResolvedAt = &now
+	require.NoError(t, f.Alerts.UpdateAlert(f.ctx, &resolved))
+
+	assert.Equal(t, []uuid.UUID{newest.ID, oldest.ID}, f.alertIDs(alert.Unresolved...), "most recently triggered first")
+	assert.Equal(t, []uuid.UUID{resolved.ID}, f.alertIDs(alert.StatusResolved))
+	assert.Equal(t, []uuid.UUID{newest.ID, resolved.ID, oldest.ID}, f.alertIDs(alert.StatusOpen, alert.StatusResolved))
+	assert.Empty(t, f.alertIDs(alert.StatusSnoozed))
+
+	alerts, err := f.Alerts.ListAlerts(f.ctx, alert.Unresolved, 1)
+	require.NoError(t, err)
+	require.Len(t, alerts, 1)
+	assert.Equal(t, newest.ID, alerts[0].ID)
+}
+
+func testAlertRuleDelete(t *testing.T, b Backend) {
+	f := newFamily(t, b)
+	rule := f.newRule(alert.KindRunningLong, uuid.Nil)
+	kept := f.newRule(alert.KindRunningLong, uuid.Nil)
+	a := f.newAlert(rule, nil, time.Now())
+	other := f.newAlert(kept, nil, time.Now())
+
+	require.NoError(t, f.Alerts.DeleteRule(f.ctx, rule.ID))
+
+	_, err := f.Alerts.GetAlert(f.ctx, a.ID)
+	assert.ErrorIs(t, err, domain.ErrNotFound)
+	_, err = f.Alerts.GetAlert(f.ctx, other.ID)
+	assert.NoError(t, err)
+
+	rules, err := f.Alerts.ListRules(f.ctx)
+	require.NoError(t, err)
+	require.Len(t, rules, 1)
+	assert.Equal(t, kept.ID, rules[0].ID)
+
+	assert.ErrorIs(t, f.Alerts.DeleteRule(f.ctx, rule.ID), domain.ErrNotFound)
+}
+
+func testAlertByFamily(t *testing.T, b Backend) {
+	f, intruder := newFamily(t, b), newFamily(t, b)
+	rule := f.newRule(alert.KindRunningLong, uuid.Nil)
+	a := f.newAlert(rule, nil, time.Now())
+
+	rules, err := intruder.Alerts.ListRules(intruder.ctx)
+	require.NoError(t, err)
+	assert.Empty(t, rules)
+	assert.Empty(t, intruder.alertIDs(alert.Unresolved...))
+
+	_, err = intruder.Alerts.GetAlert(intruder.ctx, a.ID)
+	assert.ErrorIs(t, err, domain.ErrNotFound)
+
+	hijack := a
+	hijack.Status = alert.StatusAcknowledged
+	assert.ErrorIs(t, intruder.Alerts.UpdateAlert(intruder.ctx, &hijack), domain.ErrNotFound)
+	assert.ErrorIs(t, intruder.Alerts.DeleteRule(intruder.ctx, rule.ID), domain.ErrNotFound)
+
+	stored, err := f.Alerts.GetAlert(f.ctx, a.ID)
+	require.NoError(t, err)
+	assert.Equal(t, alert.StatusOpen, stored.Status)
+}
+
+func testAlertMissingFamily(t *testing.T, b Backend) {
+	ctx := context.Background()
+	s := b.Alerts
+
+	assert.ErrorIs(t, s.CreateRule(ctx, &alert.Rule{ID: uuid.New()}), domain.ErrUnauthorized)
+	_, err := s.ListRules(ctx)
+	assert.ErrorIs(t, err, domain.ErrUnauthorized)
+	assert.ErrorIs(t, s.DeleteRule(ctx, uuid.New()), domain.ErrUnauthorized)
+	assert.ErrorIs(t, s.CreateAlert(ctx, &alert.Alert{ID: uuid.New()}), domain.ErrUnauthorized)
+	assert.ErrorIs(t, s.UpdateAlert(ctx, &alert.Alert{ID: uuid.New()}), domain.ErrUnauthorized)
+	_, err = s.GetAlert(ctx, uuid.New())
+	assert.ErrorIs(t, err, domain.ErrUnauthorized)
+	_, err = s.ListAlerts(ctx, alert.Unresolved, 0)
+	assert.ErrorIs(t, err, domain.ErrUnauthorized)
+}
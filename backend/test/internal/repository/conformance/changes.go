@@ -0,0 +1,146 @@
+package conformance
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/luisteixeira/waypoint/backend/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func runChangeTests(t *testing.T, open func(t *testing.T) Backend) {
+	runCases(t, open, []testCase{
+		{"Lists creations and updates in order", testChangesInOrder},
+		{"Resumes after a sequence", testChangesResume},
+		{"Keeps families apart", testChangesByFamily},
+		{"Leaves out rolled back changes", testChangesRollback},
+		{"Missing family", testChangesMissingFamily},
+	})
+}
+
+// changes lists the family's changes to realizations and definitions after
+// the given sequence. Backends with foreign keys also report the entities
+// the fixtures seed, which these tests ignore.
+func (f family) changes(after int64) []domain.Change {
+	f.t.Helper()
+
+	all, err := f.Changes.ListChanges(f.ctx, after, 1000)
+	require.NoError(f.t, err)
+
+	var changes []domain.Change
+	for _, c := range all {
+		if c.Kind != domain.ChangeEntity {
+			changes = append(changes, c)
+		}
+	}
+	return changes
+}
+
+func changedIDs(changes []domain.Change) []uuid.UUID {
+	var ids []uuid.UUID
+	for _, c := range changes {
+		ids = append(ids, c.ID)
+	}
+	return ids
+}
+
+func testChangesInOrder(t *testing.T, b Backend) {
+	f := newFamily(t, b)
+
+	first := f.newRealization(domain.StatusInProgress)
+	require.NoError(t, f.Activities.CreateRealization(f.ctx, first))
+	second := f.newRealization(domain.StatusPlanned)
+	require.NoError(t, f.Activities.CreateRealization(f.ctx, second))
+
+	first.Status = domain.StatusCompleted
+	require.NoError(t, f.Activities.UpdateRealization(f.ctx, first))
+
+	changes := f.changes(0)
+	assert.Equal(t, []uuid.UUID{first.DefinitionID, second.ID, first.ID}, changedIDs(changes),
+		"each object appears once, at its latest change")
+	for i := 1; i < len(changes); i++ {
+		assert.Greater(t, changes[i].Sequence, changes[i-1].Sequence)
+	}
+
+	require.Len(t, changes, 3)
+	assert.Equal(t, domain.ChangeDefinition, changes[0].Kind)
+	require.NotNil(t, changes[0].Definition)
+	assert.Equal(t, "Nap", changes[0].Definition.Name)
+
+	assert.Equal(t, domain.ChangeRealization, changes[2].Kind)
+	assert.False(t, changes[2].Deleted)
+	require.NotNil(t, changes[2].Realization)
+	assert.Equal(t, domain.StatusCompleted, changes[2].Realization.Status)
+	assert.Equal(t, 2, changes[2].Realization.Version)
+	assert.Equal(t, f.id, changes[2].Realization.FamilyID)
+
+	last, err := f.Changes.LastSequence(f.ctx)
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, last, changes[2].Sequence)
+}
+
+func testChangesResume(t *testing.T, b Backend) {
+	f := newFamily(t, b)
+
+	first := f.newRealization(domain.StatusInProgress)
+	require.NoError(t, f.Activities.CreateRealization(f.ctx, first))
+	cursor := f.changes(0)[1].Sequence
+
+	assert.Empty(t, f.changes(cursor), "nothing changed after the cursor")
+
+	second := f.newRealization(domain.StatusPlanned)
+	require.NoError(t, f.Activities.CreateRealization(f.ctx, second))
+	bath := f.newDefinition("Bath")
+
+	assert.Equal(t, []uuid.UUID{second.ID, bath}, changedIDs(f.changes(cursor)))
+
+	limited, err := f.Changes.ListChanges(f.ctx, 0, 1)
+	require.NoError(t, err)
+	assert.Len(t, limited, 1)
+}
+
+func testChangesByFamily(t *testing.T, b Backend) {
+	f := newFamily(t, b)
+	neighbour := newFamily(t, b)
+
+	ar := f.newRealization(domain.StatusInProgress)
+	require.NoError(t, f.Activities.CreateRealization(f.ctx, ar))
+	neighbour.newDefinition("Walk")
+
+	assert.Equal(t, []uuid.UUID{ar.DefinitionID, ar.ID}, changedIDs(f.changes(0)))
+	assert.Len(t, neighbour.changes(0), 1)
+}
+
+func testChangesRollback(t *testing.T, b Backend) {
+	f := newFamily(t, b)
+	existing := f.newRealization(domain.StatusInProgress)
+	require.NoError(t, f.Activities.CreateRealization(f.ctx, existing))
+	before := f.changes(0)
+
+	failure := errors.New("something went wrong")
+	created := f.newRealization(domain.StatusPlanned)
+	err := f.Tx.WithinTx(f.ctx, func(ctx context.Context) error {
+		if err := f.createInTx(ctx, created); err != nil {
+			return err
+		}
+		existing.Status = domain.StatusPaused
+		if err := f.Activities.UpdateRealization(ctx, existing); err != nil {
+			return err
+		}
+		return failure
+	})
+	require.ErrorIs(t, err, failure)
+
+	after := f.changes(0)
+	assert.Equal(t, changedIDs(before), changedIDs(after))
+	require.Len(t, after, 2)
+	assert.Equal(t, domain.StatusInProgress, after[1].Realization.Status)
+}
+
+func testChangesMissingFamily(t *testing.T, b Backend) {
+	_, err := b.Changes.ListChanges(context.Background(), 0, 10)
+	assert.ErrorIs(t, err, domain.ErrUnauthorized)
+}
@@ -0,0 +1,115 @@
+// Package conformance is the behaviour every implementation of the service
+// repository interfaces must share, whatever it stores data in. Backends run
+// it from their own tests through Run.
+package conformance
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/luisteixeira/waypoint/backend/internal/alert"
+	"github.com/luisteixeira/waypoint/backend/internal/idempotency"
+	"github.com/luisteixeira/waypoint/backend/internal/middleware"
+	"github.com/luisteixeira/waypoint/backend/internal/outbox"
+	"github.com/luisteixeira/waypoint/backend/internal/push"
+	"github.com/luisteixeira/waypoint/backend/internal/service"
+	"github.com/luisteixeira/waypoint/backend/internal/webhook"
+	"github.com/stretchr/testify/require"
+)
+
+// Backend is one storage backend under test.
+type Backend struct {
+	Activities  service.ActivityRepository
+	Definitions service.DefinitionRepository
+	Changes     service.ChangeRepository
+	Tx          service.TxManager
+	Idempotency idempotency.Store
+	Outbox      outbox.Store
+	Webhooks    webhook.Store
+	Alerts      alert.Store
+	Push        push.Store
+
+	// Seed creates the rows realizations reference. Backends without
+	// foreign keys can leave it nil.
+	Seed Seeder
+}
+
+// Seeder creates families, children and caregivers directly in the store,
+// since the repositories under test cannot. Implementations should remove
+// what they create when the test ends.
+type Seeder interface {
+	Family(t *testing.T) uuid.UUID
+	Entity(t *testing.T, familyID uuid.UUID) uuid.UUID
+	Caregiver(t *testing.T, familyID uuid.UUID) uuid.UUID
+}
+
+// Run executes the whole suite. open is called once per test and may hand
+// out fresh stores or share one; every test works in families of its own.
+func Run(t *testing.T, open func(t *testing.T) Backend) {
+	t.Run("ActivityRepository", func(t *testing.T) { runActivityTests(t, open) })
+	t.Run("DefinitionRepository", func(t *testing.T) { runDefinitionTests(t, open) })
+	t.Run("ChangeRepository", func(t *testing.T) { runChangeTests(t, open) })
+	t.Run("TxManager", func(t *testing.T) { runTxTests(t, open) })
+	t.Run("IdempotencyStore", func(t *testing.T) { runIdempotencyTests(t, open) })
+	t.Run("OutboxStore", func(t *testing.T) { runOutboxTests(t, open) })
+	t.Run("WebhookStore", func(t *testing.T) { runWebhookTests(t, open) })
+	t.Run("AlertStore", func(t *testing.T) { runAlertTests(t, open) })
+	t.Run("PushStore", func(t *testing.T) { runPushTests(t, open) })
+}
+
+// family is a tenant-scoped view of a backend.
+type family struct {
+	Backend
+	t   *testing.T
+	id  uuid.UUID
+	ctx context.Context
+}
+
+func newFamily(t *testing.T, b Backend) family {
+	t.Helper()
+
+	id := uuid.New()
+	if b.Seed != nil {
+		id = b.Seed.Family(t)
+	}
+	return family{
+		Backend: b,
+		t:       t,
+		id:      id,
+		ctx:     context.WithValue(context.Background(), middleware.FamilyIDKey, id),
+	}
+}
+
+func (f family) newEntity() uuid.UUID {
+	if f.Seed == nil {
+		return uuid.New()
+	}
+	return f.Seed.Entity(f.t, f.id)
+}
+
+func (f family) newCaregiver() uuid.UUID {
+	if f.Seed == nil {
+		return uuid.New()
+	}
+	return f.Seed.Caregiver(f.t, f.id)
+}
+
+func (f family) newDefinition(name string) uuid.UUID {
+	def, err := f.Definitions.GetOrCreateByName(f.ctx, name)
+	require.NoError(f.t, err)
+	return def.ID
+}
+
+type testCase struct {
+	name string
+	run  func(t *testing.T, b Backend)
+}
+
+func runCases(t *testing.T, open func(t *testing.T) Backend, cases []testCase) {
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			tc.run(t, open(t))
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package conformance
+
+import (
+	"context"
+	"testing"
+
+	"github.com/luisteixeira/waypoint/backend/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func runDefinitionTests(t *testing.T, open func(t *testing.T) Backend) {
+	runCases(t, open, []testCase{
+		{"Get or create by name", testGetOrCreateByName},
+		{"List by family", testListByFamily},
+		{"Missing family", testDefinitionsMissingFamily},
+	})
+}
+
+func testGetOrCreateByName(t *testing.T, b Backend) {
+	f := newFamily(t, b)
+
+	created, err := f.Definitions.GetOrCreateByName(f.ctx, "Nap")
+	require.NoError(t, err)
+	assert.Equal(t, f.id, created.FamilyID)
+	assert.Equal(t, "Nap", created.Name)
+
+	again, err := f.Definitions.GetOrCreateByName(f.ctx, "Nap")
+	require.NoError(t, err)
+	assert.Equal(t, created.ID, again.ID, "the same name resolves to the same definition")
+
+	other, err := f.Definitions.GetOrCreateByName(f.ctx, "Bath")
+	require.NoError(t, err)
+	assert.NotEqual(t, created.ID, other.ID)
+}
+
+func testListByFamily(t *testing.T, b Backend) {
+	f := newFamily(t, b)
+	neighbour := newFamily(t, b)
+
+	for _, name := range []string{"Nap", "Bath", "Meal"} {
+		f.newDefinition(name)
+	}
+	neighbour.newDefinition("Nap")
+	neighbour.newDefinition("Walk")
+
+	defs, err := f.Definitions.ListByFamily(f.ctx)
+	require.NoError(t, err)
+
+	var names []string
+	for _, d := range defs {
+		assert.Equal(t, f.id, d.FamilyID)
+		names = append(names, d.Name)
+	}
+	assert.Equal(t, []string{"Bath", "Meal", "Nap"}, names, "definitions are listed by name")
+}
+
+func testDefinitionsMissingFamily(t *testing.T, b Backend) {
+	_, err := b.Definitions.GetOrCreateByName(context.Background(), "Nap")
+	assert.ErrorIs(t, err, domain.ErrUnauthorized)
+
+	_, err = b.Definitions.ListByFamily(context.Background())
+	assert.ErrorIs(t, err, domain.ErrUnauthorized)
+}
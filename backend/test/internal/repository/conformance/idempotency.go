@@ -0,0 +1,147 @@
+package conformance
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/luisteixeira/waypoint/backend/internal/domain"
+	"github.com/luisteixeira/waypoint/backend/internal/idempotency"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func runIdempotencyTests(t *testing.T, open func(t *testing.T) Backend) {
+	runCases(t, open, []testCase{
+		{"Reserve then replay", testIdempotencyReplay},
+		{"Keys are scoped to the family", testIdempotencyFamilyScope},
+		{"Release frees the key", testIdempotencyRelease},
+		{"Expired keys are taken over", testIdempotencyExpired},
+		{"Delete expired", testIdempotencyDeleteExpired},
+		{"Missing family", testIdempotencyMissingFamily},
+	})
+}
+
+func newIdempotencyRecord(hash string, ttl time.Duration) idempotency.Record {
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	return idempotency.Record{
+		Key:         uuid.NewString(),
+		RequestHash: hash,
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(ttl),
+	}
+}
+
+func newIdempotencyRecordFor(key, hash string) idempotency.Record {
+	rec := newIdempotencyRecord(hash, time.Hour)
+	rec.Key = key
+	return rec
+}
+
+func testIdempotencyReplay(t *testing.T, b Backend) {
+	f := newFamily(t, b)
+	rec := newIdempotencyRecord("first", time.Hour)
+
+	existing, err := f.Idempotency.Reserve(f.ctx, rec)
+	require.NoError(t, err)
+	assert.Nil(t, existing, "a new key is reserved")
+
+	pending, err := f.Idempotency.Reserve(f.ctx, rec)
+	require.NoError(t, err)
+	require.NotNil(t, pending)
+	assert.False(t, pending.Completed(), "the first request has not finished")
+	assert.Equal(t, "first", pending.RequestHash)
+
+	header := http.Header{"Content-Type": {"application/json"}, "Etag": {`"1"`}}
+	require.NoError(t, f.Idempotency.Complete(f.ctx, rec.Key, http.StatusCreated, header, []byte(`{"id":1}`)))
+
+	stored, err := f.Idempotency.Reserve(f.ctx, newIdempotencyRecordFor(rec.Key, "second"))
+	require.NoError(t, err)
+	require.NotNil(t, stored)
+	assert.True(t, stored.Completed())
+	assert.Equal(t, "first", stored.RequestHash, "a retry does not replace the record")
+	assert.Equal(t, http.StatusCreated, stored.Status)
+	assert.Equal(t, header, stored.Header)
+	assert.Equal(t, `{"id":1}`, string(stored.Body))
+	assert.WithinDuration(t, rec.ExpiresAt, stored.ExpiresAt, time.Millisecond)
+}
+
+func testIdempotencyFamilyScope(t *testing.T, b Backend) {
+	f := newFamily(t, b)
+	neighbour := newFamily(t, b)
+	rec := newIdempotencyRecord("hash", time.Hour)
+
+	_, err := f.Idempotency.Reserve(f.ctx, rec)
+	require.NoError(t, err)
+
+	existing, err := neighbour.Idempotency.Reserve(neighbour.ctx, rec)
+	require.NoError(t, err)
+	assert.Nil(t, existing, "another family can use the same key")
+}
+
+func testIdempotencyRelease(t *testing.T, b Backend) {
+	f := newFamily(t, b)
+	rec := newIdempotencyRecord("hash", time.Hour)
+
+	_, err := f.Idempotency.Reserve(f.ctx, rec)
+	require.NoError(t, err)
+	require.NoError(t, f.Idempotency.Release(f.ctx, rec.Key))
+
+	existing, err := f.Idempotency.Reserve(f.ctx, rec)
+	require.NoError(t, err)
+	assert.Nil(t, existing, "a released key can be reserved again")
+
+	require.NoError(t, f.Idempotency.Complete(f.ctx, rec.Key, http.StatusOK, nil, nil))
+	require.NoError(t, f.Idempotency.Release(f.ctx, rec.Key))
+
+	stored, err := f.Idempotency.Reserve(f.ctx, rec)
+	require.NoError(t, err)
+	require.NotNil(t, stored, "completed records are not released")
+	assert.Equal(t, http.StatusOK, stored.Status)
+}
+
+func testIdempotencyExpired(t *testing.T, b Backend) {
+	f := newFamily(t, b)
+	expired := newIdempotencyRecord("old", -time.Minute)
+
+	_, err := f.Idempotency.Reserve(f.ctx, expired)
+	require.NoError(t, err)
+	require.NoError(t, f.Idempotency.Complete(f.ctx, expired.Key, http.StatusOK, nil, []byte("old")))
+
+	existing, err := f.Idempotency.Reserve(f.ctx, newIdempotencyRecordFor(expired.Key, "new"))
+	require.NoError(t, err)
+	assert.Nil(t, existing, "an expired record no longer holds the key")
+
+	pending, err := f.Idempotency.Reserve(f.ctx, newIdempotencyRecordFor(expired.Key, "newer"))
+	require.NoError(t, err)
+	require.NotNil(t, pending)
+	assert.Equal(t, "new", pending.RequestHash)
+	assert.False(t, pending.Completed(), "the old response was dropped")
+	assert.Empty(t, pending.Body)
+}
+
+func testIdempotencyDeleteExpired(t *testing.T, b Backend) {
+	f := newFamily(t, b)
+	expired := newIdempotencyRecord("old", -time.Minute)
+	live := newIdempotencyRecord("live", time.Hour)
+
+	for _, rec := range []idempotency.Record{expired, live} {
+		_, err := f.Idempotency.Reserve(f.ctx, rec)
+		require.NoError(t, err)
+	}
+
+	deleted, err := f.Idempotency.DeleteExpired(context.Background(), time.Now())
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, deleted, int64(1))
+
+	existing, err := f.Idempotency.Reserve(f.ctx, live)
+	require.NoError(t, err)
+	assert.NotNil(t, existing, "unexpired records are kept")
+}
+
+func testIdempotencyMissingFamily(t *testing.T, b Backend) {
+	_, err := b.Idempotency.Reserve(context.Background(), newIdempotencyRecord("hash", time.Hour))
+	assert.ErrorIs(t, err, domain.ErrUnauthorized)
+}
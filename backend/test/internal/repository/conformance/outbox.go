@@ -0,0 +1,209 @@
+package conformance
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/luisteixeira/waypoint/backend/internal/domain"
+	"github.com/luisteixeira/waypoint/backend/internal/outbox"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func runOutboxTests(t *testing.T, open func(t *testing.T) Backend) {
+	runCases(t, open, []testCase{
+		{"Appends and claims events in order", testOutboxAppend},
+		{"Leases claimed events", testOutboxClaim},
+		{"Records results", testOutboxResults},
+		{"Commits with the realization", testOutboxCommit},
+		{"Rolls back with the realization", testOutboxRollback},
+		{"Deletes published events", testOutboxDelete},
+		{"Missing family", testOutboxMissingFamily},
+	})
+}
+
+func (f family) appendEvent(t outbox.EventType) outbox.Event {
+	f.t.Helper()
+
+	event := f.newEvent(t)
+	require.NoError(f.t, f.Outbox.Append(f.ctx, event))
+	return event
+}
+
+func (f family) newEvent(t outbox.EventType) outbox.Event {
+	return outbox.Event{
+		ID:         uuid.New(),
+		Type:       t,
+		OccurredAt: time.Now().UTC().Truncate(time.Microsecond),
+		Realization: &domain.ActivityRealization{
+			ID:       uuid.New(),
+			FamilyID: f.id,
+			Status:   domain.StatusInProgress,
+			Version:  1,
+		},
+	}
+}
+
+// claimEvents claims everything due at now and keeps the family's events.
+// The store may be shared with other tests, whose events are left leased.
+func (f family) claimEvents(now time.Time) []outbox.Pending {
+	f.t.Helper()
+
+	all, err := f.Outbox.ClaimDue(context.Background(), now, now.Add(time.Minute), 1000)
+	require.NoError(f.t, err)
+
+	var claimed []outbox.Pending
+	for _, p := range all {
+		if p.FamilyID == f.id {
+			claimed = append(claimed, p)
+		}
+	}
+	return claimed
+}
+
+func testOutboxAppend(t *testing.T, b Backend) {
+	f := newFamily(t, b)
+	neighbour := newFamily(t, b)
+	first := f.appendEvent(outbox.EventActivityStarted)
+	other := neighbour.appendEvent(outbox.EventActivityStarted)
+	second := f.newEvent(outbox.EventActivityCompleted)
+	second.CaregiverID = f.newCaregiver()
+	require.NoError(t, f.Outbox.Append(f.ctx, second))
+
+	now := time.Now().Add(time.Second)
+	all, err := f.Outbox.ClaimDue(context.Background(), now, now.Add(time.Minute), 1000)
+	require.NoError(t, err)
+	var claimed []outbox.Pending
+	for _, p := range all {
+		switch p.FamilyID {
+		case f.id:
+			claimed = append(claimed, p)
+		case neighbour.id:
+			assert.Equal(t, other.ID, p.ID)
+		}
+	}
+	require.Len(t, claimed, 2)
+	assert.Equal(t, first.ID, claimed[0].ID)
+	assert.Equal(t, second.ID, claimed[1].ID)
+	assert.Less(t, claimed[0].Sequence, claimed[1].Sequence)
+
+	p := claimed[0]
+	assert.Equal(t, f.id, p.FamilyID, "the family comes from the context")
+	assert.Equal(t, outbox.EventActivityStarted, p.Type)
+	assert.True(t, first.OccurredAt.Equal(p.OccurredAt), p.OccurredAt)
+	require.NotNil(t, p.Realization)
+	assert.Equal(t, first.Realization.ID, p.Realization.ID)
+	assert.Equal(t, domain.StatusInProgress, p.Realization.Status)
+	assert.Zero(t, p.Attempts)
+	assert.Empty(t, p.Done)
+	assert.Equal(t, uuid.Nil, p.CaregiverID)
+	assert.Equal(t, second.CaregiverID, claimed[1].CaregiverID)
+}
+
+func testOutboxClaim(t *testing.T, b Backend) {
+	f := newFamily(t, b)
+	event := f.appendEvent(outbox.EventActivityStarted)
+
+	now := time.Now().Add(time.Second)
+	claimed := f.claimEvents(now)
+	require.Len(t, claimed, 1)
+	assert.Equal(t, event.ID, claimed[0].ID)
+
+	assert.Empty(t, f.claimEvents(now), "claimed events are leased")
+	assert.Len(t, f.claimEvents(now.Add(2*time.Minute)), 1, "an expired lease is claimed again")
+}
+
+func testOutboxResults(t *testing.T, b Backend) {
+	f := newFamily(t, b)
+	event := f.appendEvent(outbox.EventActivityStarted)
+
+	now := time.Now().Add(time.Second).UTC().Truncate(time.Microsecond)
+	require.Len(t, f.claimEvents(now), 1)
+
+	retryAt := now.Add(time.Hour)
+	require.NoError(t, f.Outbox.RecordResult(context.Background(), event.ID, outbox.Result{
+		At: now, Done: []string{"webhooks"}, RetryAt: retryAt, Error: "mqtt: broker unreachable",
+	}))
+
+	assert.Empty(t, f.claimEvents(retryAt.Add(-time.Second)), "not due before the retry")
+	claimed := f.claimEvents(retryAt)
+	require.Len(t, claimed, 1)
+	assert.Equal(t, 1, claimed[0].Attempts)
+	assert.Equal(t, []string{"webhooks"}, claimed[0].Done)
+	assert.Equal(t, "mqtt: broker unreachable", claimed[0].LastError)
+
+	require.NoError(t, f.Outbox.RecordResult(context.Background(), event.ID, outbox.Result{
+		At: retryAt, Done: []string{"webhooks", "mqtt"}, Published: true,
+	}))
+	assert.Empty(t, f.claimEvents(retryAt.Add(24*time.Hour)), "published events are done")
+}
+
+func testOutboxCommit(t *testing.T, b Backend) {
+	f := newFamily(t, b)
+
+	ar := f.newRealization(domain.StatusInProgress)
+	event := f.newEvent(outbox.EventActivityStarted)
+	err := f.Tx.WithinTx(f.ctx, func(ctx context.Context) error {
+		if err := f.createInTx(ctx, ar); err != nil {
+			return err
+		}
+		return f.Outbox.Append(ctx, event)
+	})
+	require.NoError(t, err)
+
+	claimed := f.claimEvents(time.Now().Add(time.Second))
+	require.Len(t, claimed, 1)
+	assert.Equal(t, event.ID, claimed[0].ID)
+}
+
+func testOutboxRollback(t *testing.T, b Backend) {
+	f := newFamily(t, b)
+
+	failure := errors.New("something went wrong")
+	ar := f.newRealization(domain.StatusInProgress)
+	err := f.Tx.WithinTx(f.ctx, func(ctx context.Context) error {
+		if err := f.createInTx(ctx, ar); err != nil {
+			return err
+		}
+		if err := f.Outbox.Append(ctx, f.newEvent(outbox.EventActivityStarted)); err != nil {
+			return err
+		}
+		return failure
+	})
+	require.ErrorIs(t, err, failure)
+
+	_, err = f.Activities.GetRealizationByID(f.ctx, ar.ID)
+	assert.ErrorIs(t, err, domain.ErrNotFound)
+	assert.Empty(t, f.claimEvents(time.Now().Add(time.Second)))
+}
+
+func testOutboxDelete(t *testing.T, b Backend) {
+	f := newFamily(t, b)
+	published := f.appendEvent(outbox.EventActivityStarted)
+	pending := f.appendEvent(outbox.EventActivityCompleted)
+
+	now := time.Now().Add(time.Second).UTC().Truncate(time.Microsecond)
+	require.Len(t, f.claimEvents(now), 2)
+	require.NoError(t, f.Outbox.RecordResult(context.Background(), published.ID, outbox.Result{At: now, Published: true}))
+	require.NoError(t, f.Outbox.RecordResult(context.Background(), pending.ID, outbox.Result{At: now, RetryAt: now.Add(time.Minute), Error: "down"}))
+
+	deleted, err := f.Outbox.DeletePublished(context.Background(), now.Add(-time.Second))
+	require.NoError(t, err)
+	assert.Zero(t, deleted, "nothing was published that early")
+
+	deleted, err = f.Outbox.DeletePublished(context.Background(), now.Add(time.Second))
+	require.NoError(t, err)
+	assert.GreaterOrEqual(t, deleted, int64(1))
+
+	claimed := f.claimEvents(now.Add(time.Hour))
+	require.Len(t, claimed, 1, "pending events are kept")
+	assert.Equal(t, pending.ID, claimed[0].ID)
+}
+
+func testOutboxMissingFamily(t *testing.T, b Backend) {
+	err := b.Outbox.Append(context.Background(), outbox.Event{ID: uuid.New(), Type: outbox.EventActivityStarted})
+	assert.ErrorIs(t, err, domain.ErrUnauthorized)
+}
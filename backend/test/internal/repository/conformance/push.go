@@ -0,0 +1,187 @@
+package conformance
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/luisteixeira/waypoint/backend/internal/domain"
+	"github.com/luisteixeira/waypoint/backend/internal/push"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func runPushTests(t *testing.T, open func(t *testing.T) Backend) {
+	runCases(t, open, []testCase{
+		{"Stores subscriptions", testPushSubscriptions},
+		{"A browser's endpoint moves to its latest subscription", testPushEndpointTakeover},
+		{"Deletes subscriptions by endpoint", testPushDeleteEndpoint},
+		{"Stores preferences", testPushPreferences},
+		{"Keeps families apart", testPushByFamily},
+		{"Missing family", testPushMissingFamily},
+	})
+}
+
+func (f family) newPushSubscription(caregiverID uuid.UUID, endpoint string, createdAt time.Time) push.Subscription {
+	f.t.Helper()
+
+	sub := push.Subscription{
+		ID:          uuid.New(),
+		CaregiverID: caregiverID,
+		Endpoint:    endpoint,
+		P256DH:      "BKey" + uuid.NewString(),
+		Auth:        "auth" + uuid.NewString(),
+		CreatedAt:   createdAt.UTC().Truncate(time.Microsecond),
+	}
+	require.NoError(f.t, f.Push.SaveSubscription(f.ctx, &sub))
+	return sub
+}
+
+func (f family) pushSubscriptionIDs(caregiverID uuid.UUID) []uuid.UUID {
+	f.t.Helper()
+
+	subs, err := f.Push.ListSubscriptions(f.ctx, caregiverID)
+	require.NoError(f.t, err)
+	ids := make([]uuid.UUID, len(subs))
+	for i, sub := range subs {
+		ids[i] = sub.ID
+	}
+	return ids
+}
+
+func newEndpoint() string {
+	return "https://push.example.com/send/" + uuid.NewString()
+}
+
+func testPushSubscriptions(t *testing.T, b Backend) {
+	f := newFamily(t, b)
+	mum, dad := f.newCaregiver(), f.newCaregiver()
+	now := time.Now()
+	laptop := f.newPushSubscription(mum, newEndpoint(), now)
+	phone := f.newPushSubscription(dad, newEndpoint(), now.Add(time.Second))
+	tablet := f.newPushSubscription(mum, newEndpoint(), now.Add(2*time.Second))
+
+	assert.Equal(t, []uuid.UUID{laptop.ID, tablet.ID}, f.pushSubscriptionIDs(mum))
+	assert.Equal(t, []uuid.UUID{laptop.ID, phone.ID, tablet.ID}, f.pushSubscriptionIDs(uuid.Nil), "oldest first")
+
+	subs, err := f.Push.ListSubscriptions(f.ctx, dad)
+	require.NoError(t, err)
+	require.Len(t, subs, 1)
+	stored := subs[0]
+	assert.Equal(t, f.id, stored.FamilyID, "the family comes from the context")
+	assert.Equal(t, dad, stored.CaregiverID)
+	assert.Equal(t, phone.Endpoint, stored.Endpoint)
+	assert.Equal(t, phone.P256DH, stored.P256DH)
+	assert.Equal(t, phone.Auth, stored.Auth)
+	assert.True(t, phone.CreatedAt.Equal(stored.CreatedAt), stored.CreatedAt)
+
+	assert.ErrorIs(t, f.Push.DeleteSubscription(f.ctx, dad, laptop.ID), domain.ErrNotFound, "only its caregiver removes a subscription")
+	require.NoError(t, f.Push.DeleteSubscription(f.ctx, mum, laptop.ID))
+	assert.ErrorIs(t, f.Push.DeleteSubscription(f.ctx, mum, laptop.ID), domain.ErrNotFound)
+	assert.Equal(t, []uuid.UUID{tablet.ID}, f.pushSubscriptionIDs(mum))
+}
+
+func testPushEndpointTakeover(t *testing.T, b Backend) {
+	f := newFamily(t, b)
+	mum, dad := f.newCaregiver(), f.newCaregiver()
+	endpoint := newEndpoint()
+	f.newPushSubscription(mum, endpoint, time.Now())
+	again := f.newPushSubscription(dad, endpoint, time.Now())
+
+	assert.Empty(t, f.pushSubscriptionIDs(mum))
+	assert.Equal(t, []uuid.UUID{again.ID}, f.pushSubscriptionIDs(dad))
+}
+
+func testPushDeleteEndpoint(t *testing.T, b Backend) {
+	f := newFamily(t, b)
+	caregiver := f.newCaregiver()
+	gone := f.newPushSubscription(caregiver, newEndpoint(), time.Now())
+	kept := f.newPushSubscription(caregiver, newEndpoint(), time.Now().Add(time.Second))
+
+	require.NoError(t, f.Push.DeleteEndpoint(f.ctx, gone.Endpoint))
+	require.NoError(t, f.Push.DeleteEndpoint(f.ctx, gone.Endpoint), "deleting twice is fine")
+	assert.Equal(t, []uuid.UUID{kept.ID}, f.pushSubscriptionIDs(caregiver))
+}
+
+func testPushPreferences(t *testing.T, b Backend) {
+	f := newFamily(t, b)
+	caregiver := f.newCaregiver()
+	_, err := f.Push.GetPreferences(f.ctx, caregiver)
+	assert.ErrorIs(t, err, domain.ErrNotFound)
+
+	first, second := f.newEntity(), f.newEntity()
+	prefs := push.Preferences{
+		CaregiverID:     caregiver,
+		EntityIDs:       []uuid.UUID{first, second},
+		ActivityStarted: true,
+		Alerts:          true,
+		UpdatedAt:       time.Now().UTC().Truncate(time.Microsecond),
+	}
+	require.NoError(t, f.Push.SavePreferences(f.ctx, &prefs))
+	assert.Equal(t, f.id, prefs.FamilyID)
+
+	stored, err := f.Push.GetPreferences(f.ctx, caregiver)
+	require.NoError(t, err)
+	assert.Equal(t, f.id, stored.FamilyID)
+	assert.Equal(t, caregiver, stored.CaregiverID)
+	assert.ElementsMatch(t, []uuid.UUID{first, second}, stored.EntityIDs)
+	assert.True(t, stored.ActivityStarted)
+	assert.False(t, stored.ActivityCompleted)
+	assert.True(t, stored.Alerts)
+	assert.True(t, prefs.UpdatedAt.Equal(stored.UpdatedAt), stored.UpdatedAt)
+
+	prefs.EntityIDs = []uuid.UUID{}
+	prefs.ActivityCompleted = true
+	require.NoError(t, f.Push.SavePreferences(f.ctx, &prefs))
+	stored, err = f.Push.GetPreferences(f.ctx, caregiver)
+	require.NoError(t, err)
+	assert.Empty(t, stored.EntityIDs)
+	assert.True(t, stored.ActivityCompleted)
+
+	other := push.Preferences{CaregiverID: f.newCaregiver(), EntityIDs: []uuid.UUID{first}, UpdatedAt: time.Now().UTC()}
+	require.NoError(t, f.Push.SavePreferences(f.ctx, &other))
+	all, err := f.Push.ListPreferences(f.ctx)
+	require.NoError(t, err)
+	caregivers := make([]uuid.UUID, len(all))
+	for i, p := range all {
+		caregivers[i] = p.CaregiverID
+	}
+	assert.ElementsMatch(t, []uuid.UUID{caregiver, other.CaregiverID}, caregivers)
+}
+
+func testPushByFamily(t *testing.T, b Backend) {
+	f, intruder := newFamily(t, b), newFamily(t, b)
+	caregiver := f.newCaregiver()
+	sub := f.newPushSubscription(caregiver, newEndpoint(), time.Now())
+	prefs := push.Preferences{CaregiverID: caregiver, EntityIDs: []uuid.UUID{f.newEntity()}, Alerts: true, UpdatedAt: time.Now().UTC()}
+	require.NoError(t, f.Push.SavePreferences(f.ctx, &prefs))
+
+	assert.Empty(t, intruder.pushSubscriptionIDs(uuid.Nil))
+	assert.Empty(t, intruder.pushSubscriptionIDs(caregiver))
+	assert.ErrorIs(t, intruder.Push.DeleteSubscription(intruder.ctx, caregiver, sub.ID), domain.ErrNotFound)
+	require.NoError(t, intruder.Push.DeleteEndpoint(intruder.ctx, sub.Endpoint))
+	_, err := intruder.Push.GetPreferences(intruder.ctx, caregiver)
+	assert.ErrorIs(t, err, domain.ErrNotFound)
+	all, err := intruder.Push.ListPreferences(intruder.ctx)
+	require.NoError(t, err)
+	assert.Empty(t, all)
+
+	assert.Equal(t, []uuid.UUID{sub.ID}, f.pushSubscriptionIDs(caregiver))
+}
+
+func testPushMissingFamily(t *testing.T, b Backend) {
+	ctx := context.Background()
+	s := b.Push
+
+	assert.ErrorIs(t, s.SaveSubscription(ctx, &push.Subscription{ID: uuid.New()}), domain.ErrUnauthorized)
+	_, err := s.ListSubscriptions(ctx, uuid.Nil)
+	assert.ErrorIs(t, err, domain.ErrUnauthorized)
+	assert.ErrorIs(t, s.DeleteSubscription(ctx, uuid.New(), uuid.New()), domain.ErrUnauthorized)
+	assert.ErrorIs(t, s.DeleteEndpoint(ctx, newEndpoint()), domain.ErrUnauthorized)
+	_, err = s.GetPreferences(ctx, uuid.New())
+	assert.ErrorIs(t, err, domain.ErrUnauthorized)
+	assert.ErrorIs(t, s.SavePreferences(ctx, &push.Preferences{CaregiverID: uuid.New()}), domain.ErrUnauthorized)
+	_, err = s.ListPreferences(ctx)
+	assert.ErrorIs(t, err, domain.ErrUnauthorized)
+}
@@ -0,0 +1,117 @@
+package conformance
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/luisteixeira/waypoint/backend/internal/domain"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func runTxTests(t *testing.T, open func(t *testing.T) Backend) {
+	runCases(t, open, []testCase{
+		{"Commits every change", testTxCommit},
+		{"Rolls back every change", testTxRollback},
+		{"Nested units of work join the outer one", testTxNested},
+	})
+}
+
+// createInTx creates a definition and a realization using it. Everything
+// inside a unit of work must use its context: SQLite has a single connection,
+// which the transaction holds.
+func (f family) createInTx(ctx context.Context, ar *domain.ActivityRealization) error {
+	def, err := f.Definitions.GetOrCreateByName(ctx, "Swimming")
+	if err != nil {
+		return err
+	}
+
+	ar.DefinitionID = def.ID
+	event := domain.RealizationEvent{Type: domain.EventStarted, OccurredAt: *ar.StartedAt}
+	return f.Activities.CreateRealization(ctx, ar, event)
+}
+
+func testTxCommit(t *testing.T, b Backend) {
+	f := newFamily(t, b)
+
+	ar := f.newRealization(domain.StatusInProgress)
+	err := f.Tx.WithinTx(f.ctx, func(ctx context.Context) error {
+		if err := f.createInTx(ctx, ar); err != nil {
+			return err
+		}
+
+		// Reads inside the unit of work see its own writes.
+		_, err := f.Activities.GetRealizationByID(ctx, ar.ID)
+		return err
+	})
+	require.NoError(t, err)
+
+	stored, err := f.Activities.GetRealizationByID(f.ctx, ar.ID)
+	require.NoError(t, err)
+	assert.Equal(t, 1, stored.Version)
+
+	events, err := f.Activities.ListEvents(f.ctx, ar.ID)
+	require.NoError(t, err)
+	assert.Len(t, events, 1)
+
+	defs, err := f.Definitions.ListByFamily(f.ctx)
+	require.NoError(t, err)
+	assert.Len(t, defs, 2, "Nap from the fixture and Swimming")
+}
+
+func testTxRollback(t *testing.T, b Backend) {
+	f := newFamily(t, b)
+	existing := f.newRealization(domain.StatusInProgress)
+	require.NoError(t, f.Activities.CreateRealization(f.ctx, existing))
+
+	failure := errors.New("something went wrong")
+	created := f.newRealization(domain.StatusInProgress)
+	err := f.Tx.WithinTx(f.ctx, func(ctx context.Context) error {
+		if err := f.createInTx(ctx, created); err != nil {
+			return err
+		}
+
+		existing.Status = domain.StatusPaused
+		if err := f.Activities.UpdateRealization(ctx, existing); err != nil {
+			return err
+		}
+		return failure
+	})
+	require.ErrorIs(t, err, failure)
+
+	_, err = f.Activities.GetRealizationByID(f.ctx, created.ID)
+	assertNotFound(t, err, created.ID)
+	_, err = f.Activities.ListEvents(f.ctx, created.ID)
+	assertNotFound(t, err, created.ID)
+
+	stored, err := f.Activities.GetRealizationByID(f.ctx, existing.ID)
+	require.NoError(t, err)
+	assert.Equal(t, domain.StatusInProgress, stored.Status)
+	assert.Equal(t, 1, stored.Version)
+
+	defs, err := f.Definitions.ListByFamily(f.ctx)
+	require.NoError(t, err)
+	require.Len(t, defs, 1)
+	assert.Equal(t, "Nap", defs[0].Name, "the definition created in the unit of work is gone")
+}
+
+func testTxNested(t *testing.T, b Backend) {
+	f := newFamily(t, b)
+
+	failure := errors.New("outer failure")
+	created := f.newRealization(domain.StatusInProgress)
+	err := f.Tx.WithinTx(f.ctx, func(ctx context.Context) error {
+		err := f.Tx.WithinTx(ctx, func(ctx context.Context) error {
+			return f.createInTx(ctx, created)
+		})
+		if err != nil {
+			return err
+		}
+		return failure
+	})
+	require.ErrorIs(t, err, failure)
+
+	_, err = f.Activities.GetRealizationByID(f.ctx, created.ID)
+	assertNotFound(t, err, created.ID)
+}
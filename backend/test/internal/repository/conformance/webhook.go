@@ -0,0 +1,319 @@
+package conformance
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/luisteixeira/waypoint/backend/internal/domain"
+	"github.com/luisteixeira/waypoint/backend/internal/webhook"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func runWebhookTests(t *testing.T, open func(t *testing.T) Backend) {
+	runCases(t, open, []testCase{
+		{"Queues deliveries for subscriptions that want the event", testWebhookEnqueue},
+		{"Keeps families apart", testWebhookByFamily},
+		{"Leases claimed deliveries", testWebhookClaim},
+		{"Records attempts", testWebhookAttempts},
+		{"Queues an event once per subscription", testWebhookEnqueueOnce},
+		{"Redelivers an event", testWebhookRedeliver},
+		{"Deleting a subscription drops its deliveries", testWebhookDelete},
+		{"Queues nothing when the unit of work rolls back", testWebhookRollback},
+		{"Missing family", testWebhookMissingFamily},
+	})
+}
+
+func (f family) newSubscription(events ...webhook.EventType) webhook.Subscription {
+	f.t.Helper()
+
+	sub := webhook.Subscription{
+		ID:        uuid.New(),
+		URL:       "https://example.com/" + uuid.NewString(),
+		Events:    events,
+		Secret:    "whsec_" + uuid.NewString(),
+		CreatedAt: time.Now().UTC().Truncate(time.Microsecond),
+	}
+	require.NoError(f.t, f.Webhooks.CreateSubscription(f.ctx, &sub))
+	return sub
+}
+
+func (f family) enqueue(t webhook.EventType) []webhook.Delivery {
+	f.t.Helper()
+
+	deliveries, err := f.Webhooks.Enqueue(f.ctx, webhook.Event{
+		ID:          uuid.New(),
+		Type:        t,
+		OccurredAt:  time.Now().UTC(),
+		FamilyID:    f.id,
+		Realization: &domain.ActivityRealization{ID: uuid.New(), FamilyID: f.id, Status: domain.StatusInProgress},
+	})
+	require.NoError(f.t, err)
+	return deliveries
+}
+
+func (f family) deliveries(subscriptionID uuid.UUID) []webhook.Delivery {
+	f.t.Helper()
+
+	deliveries, err := f.Webhooks.ListDeliveries(f.ctx, subscriptionID, 100)
+	require.NoError(f.t, err)
+	return deliveries
+}
+
+// claim claims everything due at now and keeps the family's deliveries. The
+// store may be shared with other tests, whose deliveries are left leased.
+func (f family) claim(now time.Time) []webhook.Delivery {
+	f.t.Helper()
+
+	all, err := f.Webhooks.ClaimDue(f.ctx, now, now.Add(time.Minute), 1000)
+	require.NoError(f.t, err)
+
+	var claimed []webhook.Delivery
+	for _, d := range all {
+		if d.FamilyID == f.id {
+			claimed = append(claimed, d)
+		}
+	}
+	return claimed
+}
+
+func testWebhookEnqueue(t *testing.T, b Backend) {
+	f := newFamily(t, b)
+	started := f.newSubscription(webhook.EventActivityStarted, webhook.EventActivityCompleted)
+	cancelled := f.newSubscription(webhook.EventActivityCancelled)
+
+	subs, err := f.Webhooks.ListSubscriptions(f.ctx)
+	require.NoError(t, err)
+	require.Len(t, subs, 2)
+	if subs[0].ID != started.ID {
+		subs[0], subs[1] = subs[1], subs[0]
+	}
+	assert.Equal(t, started.ID, subs[0].ID)
+	assert.Equal(t, f.id, subs[0].FamilyID)
+	assert.Equal(t, started.URL, subs[0].URL)
+	assert.Equal(t, started.Events, subs[0].Events)
+	assert.Equal(t, started.Secret, subs[0].Secret)
+	assert.Equal(t, cancelled.ID, subs[1].ID)
+
+	queued := f.enqueue(webhook.EventActivityStarted)
+	require.Len(t, queued, 1)
+	assert.Equal(t, started.ID, queued[0].SubscriptionID)
+	assert.Equal(t, webhook.DeliveryPending, queued[0].Status)
+
+	listed := f.deliveries(started.ID)
+	require.Len(t, listed, 1)
+	d := listed[0]
+	assert.Equal(t, queued[0].ID, d.ID)
+	assert.Equal(t, f.id, d.FamilyID)
+	assert.Equal(t, webhook.EventActivityStarted, d.EventType)
+	assert.Equal(t, webhook.DeliveryPending, d.Status)
+	assert.Zero(t, d.Attempts)
+	assert.Nil(t, d.LastAttemptAt)
+	assert.JSONEq(t, string(queued[0].Payload), string(d.Payload))
+
+	assert.Empty(t, f.deliveries(cancelled.ID))
+	assert.Empty(t, f.enqueue(webhook.EventActivityPlanned), "nobody asked for planned activities")
+
+	f.enqueue(webhook.EventActivityCompleted)
+	listed = f.deliveries(started.ID)
+	require.Len(t, listed, 2)
+	assert.Equal(t, webhook.EventActivityCompleted, listed[0].EventType, "newest first")
+}
+
+func testWebhookEnqueueOnce(t *testing.T, b Backend) {
+	f := newFamily(t, b)
+	first := f.newSubscription(webhook.EventActivityStarted)
+	event := webhook.Event{ID: uuid.New(), Type: webhook.EventActivityStarted, OccurredAt: time.Now().UTC(), FamilyID: f.id}
+
+	queued, err := f.Webhooks.Enqueue(f.ctx, event)
+	require.NoError(t, err)
+	require.Len(t, queued, 1)
+
+	// The outbox hands the event over again after a subscription was added.
+	second := f.newSubscription(webhook.EventActivityStarted)
+	queued, err = f.Webhooks.Enqueue(f.ctx, event)
+	require.NoError(t, err)
+	require.Len(t, queued, 1)
+	assert.Equal(t, second.ID, queued[0].SubscriptionID)
+
+	queued, err = f.Webhooks.Enqueue(f.ctx, event)
+	require.NoError(t, err)
+	assert.Empty(t, queued)
+	assert.Len(t, f.deliveries(first.ID), 1)
+	assert.Len(t, f.deliveries(second.ID), 1)
+
+	// Redeliveries are deliberate and do not count as duplicates.
+	_, err = f.Webhooks.Redeliver(f.ctx, first.ID, f.deliveries(first.ID)[0].ID)
+	require.NoError(t, err)
+	assert.Len(t, f.deliveries(first.ID), 2)
+	queued, err = f.Webhooks.Enqueue(f.ctx, event)
+	require.NoError(t, err)
+	assert.Empty(t, queued)
+}
+
+func testWebhookByFamily(t *testing.T, b Backend) {
+	f := newFamily(t, b)
+	neighbour := newFamily(t, b)
+	sub := f.newSubscription(webhook.EventActivityStarted)
+
+	assert.Empty(t, neighbour.enqueue(webhook.EventActivityStarted))
+
+	subs, err := neighbour.Webhooks.ListSubscriptions(neighbour.ctx)
+	require.NoError(t, err)
+	assert.Empty(t, subs)
+
+	_, err = neighbour.Webhooks.ListDeliveries(neighbour.ctx, sub.ID, 10)
+	assert.ErrorIs(t, err, domain.ErrNotFound)
+	assert.ErrorIs(t, neighbour.Webhooks.DeleteSubscription(neighbour.ctx, sub.ID), domain.ErrNotFound)
+}
+
+func testWebhookClaim(t *testing.T, b Backend) {
+	f := newFamily(t, b)
+	sub := f.newSubscription(webhook.EventActivityStarted)
+	queued := f.enqueue(webhook.EventActivityStarted)
+
+	now := time.Now().Add(time.Second)
+	claimed := f.claim(now)
+	require.Len(t, claimed, 1)
+	assert.Equal(t, queued[0].ID, claimed[0].ID)
+	assert.Equal(t, sub.URL, claimed[0].URL)
+	assert.Equal(t, sub.Secret, claimed[0].Secret)
+	assert.JSONEq(t, string(queued[0].Payload), string(claimed[0].Payload))
+
+	assert.Empty(t, f.claim(now), "claimed deliveries are leased")
+	assert.Len(t, f.claim(now.Add(2*time.Minute)), 1, "an expired lease is claimed again")
+}
+
+func testWebhookAttempts(t *testing.T, b Backend) {
+	f := newFamily(t, b)
+	sub := f.newSubscription(webhook.EventActivityStarted)
+	queued := f.enqueue(webhook.EventActivityStarted)
+	id := queued[0].ID
+
+	now := time.Now().Add(time.Second).UTC().Truncate(time.Microsecond)
+	require.Len(t, f.claim(now), 1)
+
+	retryAt := now.Add(time.Hour)
+	require.NoError(t, f.Webhooks.RecordAttempt(f.ctx, id, webhook.Attempt{
+		At: now, ResponseStatus: http.StatusBadGateway, Error: "receiver answered 502", RetryAt: &retryAt,
+	}))
+
+	d := f.deliveries(sub.ID)[0]
+	assert.Equal(t, webhook.DeliveryPending, d.Status)
+	assert.Equal(t, 1, d.Attempts)
+	assert.Equal(t, http.StatusBadGateway, d.ResponseStatus)
+	assert.Equal(t, "receiver answered 502", d.LastError)
+	require.NotNil(t, d.LastAttemptAt)
+	assert.WithinDuration(t, now, *d.LastAttemptAt, time.Millisecond)
+	assert.WithinDuration(t, retryAt, d.NextAttemptAt, time.Millisecond)
+
+	assert.Empty(t, f.claim(retryAt.Add(-time.Second)), "not due before the retry")
+	require.Len(t, f.claim(retryAt), 1)
+
+	require.NoError(t, f.Webhooks.RecordAttempt(f.ctx, id, webhook.Attempt{At: retryAt, ResponseStatus: http.StatusNoContent, Delivered: true}))
+	d = f.deliveries(sub.ID)[0]
+	assert.Equal(t, webhook.DeliveryDelivered, d.Status)
+	assert.Equal(t, 2, d.Attempts)
+	assert.Empty(t, d.LastError)
+	require.NotNil(t, d.DeliveredAt)
+	assert.WithinDuration(t, retryAt, *d.DeliveredAt, time.Millisecond)
+	assert.Empty(t, f.claim(retryAt.Add(time.Hour)), "delivered deliveries are done")
+
+	failing := f.enqueue(webhook.EventActivityStarted)[0].ID
+	require.Len(t, f.claim(time.Now().Add(time.Second)), 1)
+	require.NoError(t, f.Webhooks.RecordAttempt(f.ctx, failing, webhook.Attempt{At: now, Error: "connection refused"}))
+	d = f.deliveries(sub.ID)[0]
+	assert.Equal(t, failing, d.ID)
+	assert.Equal(t, webhook.DeliveryFailed, d.Status)
+	assert.Zero(t, d.ResponseStatus)
+	assert.Empty(t, f.claim(now.Add(24*time.Hour)), "failed deliveries are not retried")
+}
+
+func testWebhookRedeliver(t *testing.T, b Backend) {
+	f := newFamily(t, b)
+	sub := f.newSubscription(webhook.EventActivityCompleted)
+	original := f.enqueue(webhook.EventActivityCompleted)[0]
+
+	now := time.Now().Add(time.Second)
+	require.Len(t, f.claim(now), 1)
+	require.NoError(t, f.Webhooks.RecordAttempt(f.ctx, original.ID, webhook.Attempt{At: now, Error: "gave up"}))
+
+	again, err := f.Webhooks.Redeliver(f.ctx, sub.ID, original.ID)
+	require.NoError(t, err)
+	assert.NotEqual(t, original.ID, again.ID)
+	assert.Equal(t, original.EventID, again.EventID)
+	assert.Equal(t, webhook.DeliveryPending, again.Status)
+	assert.Zero(t, again.Attempts)
+	assert.JSONEq(t, string(original.Payload), string(again.Payload))
+
+	listed := f.deliveries(sub.ID)
+	require.Len(t, listed, 2)
+	assert.Equal(t, webhook.DeliveryFailed, statusOf(listed, original.ID), "the log keeps the original")
+
+	claimed := f.claim(time.Now().Add(time.Second))
+	require.Len(t, claimed, 1)
+	assert.Equal(t, again.ID, claimed[0].ID)
+
+	_, err = f.Webhooks.Redeliver(f.ctx, sub.ID, uuid.New())
+	assert.ErrorIs(t, err, domain.ErrNotFound)
+	other := f.newSubscription(webhook.EventActivityCompleted)
+	_, err = f.Webhooks.Redeliver(f.ctx, other.ID, original.ID)
+	assert.ErrorIs(t, err, domain.ErrNotFound, "the delivery belongs to another subscription")
+}
+
+func statusOf(deliveries []webhook.Delivery, id uuid.UUID) webhook.DeliveryStatus {
+	for _, d := range deliveries {
+		if d.ID == id {
+			return d.Status
+		}
+	}
+	return ""
+}
+
+func testWebhookDelete(t *testing.T, b Backend) {
+	f := newFamily(t, b)
+	sub := f.newSubscription(webhook.EventActivityStarted)
+	f.enqueue(webhook.EventActivityStarted)
+
+	require.NoError(t, f.Webhooks.DeleteSubscription(f.ctx, sub.ID))
+
+	subs, err := f.Webhooks.ListSubscriptions(f.ctx)
+	require.NoError(t, err)
+	assert.Empty(t, subs)
+	assert.Empty(t, f.claim(time.Now().Add(time.Second)))
+	_, err = f.Webhooks.ListDeliveries(f.ctx, sub.ID, 10)
+	assert.ErrorIs(t, err, domain.ErrNotFound)
+	assert.ErrorIs(t, f.Webhooks.DeleteSubscription(f.ctx, sub.ID), domain.ErrNotFound)
+}
+
+func testWebhookRollback(t *testing.T, b Backend) {
+	f := newFamily(t, b)
+	sub := f.newSubscription(webhook.EventActivityStarted)
+
+	failure := errors.New("something went wrong")
+	err := f.Tx.WithinTx(f.ctx, func(ctx context.Context) error {
+		queued, err := f.Webhooks.Enqueue(ctx, webhook.Event{ID: uuid.New(), Type: webhook.EventActivityStarted, FamilyID: f.id})
+		if err != nil {
+			return err
+		}
+		require.Len(t, queued, 1)
+		return failure
+	})
+	require.ErrorIs(t, err, failure)
+
+	assert.Empty(t, f.deliveries(sub.ID))
+}
+
+func testWebhookMissingFamily(t *testing.T, b Backend) {
+	ctx := context.Background()
+
+	_, err := b.Webhooks.ListSubscriptions(ctx)
+	assert.ErrorIs(t, err, domain.ErrUnauthorized)
+	_, err = b.Webhooks.Enqueue(ctx, webhook.Event{ID: uuid.New(), Type: webhook.EventActivityStarted})
+	assert.ErrorIs(t, err, domain.ErrUnauthorized)
+	assert.ErrorIs(t, b.Webhooks.CreateSubscription(ctx, &webhook.Subscription{ID: uuid.New()}), domain.ErrUnauthorized)
+}
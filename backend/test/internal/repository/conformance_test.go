@@ -0,0 +1,224 @@
+package repository_test
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/uuid"
+	_ "github.com/lib/pq"
+	"github.com/luisteixeira/waypoint/backend/internal/domain"
+	"github.com/luisteixeira/waypoint/backend/internal/middleware"
+	"github.com/luisteixeira/waypoint/backend/internal/repository"
+	"github.com/luisteixeira/waypoint/backend/internal/repository/memory"
+	"github.com/luisteixeira/waypoint/backend/internal/repository/postgres"
+	"github.com/luisteixeira/waypoint/backend/internal/repository/sqlite"
+	"github.com/luisteixeira/waypoint/backend/test/internal/repository/conformance"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryRepository(t *testing.T) {
+	conformance.Run(t, func(t *testing.T) conformance.Backend {
+		activities, definitions := memory.NewInMemoryActivityRepo(), memory.NewInMemoryDefinitionRepo()
+		return conformance.Backend{
+			Activities:  activities,
+			Definitions: definitions,
+			Changes:     memory.NewInMemoryChangeRepo(activities, definitions),
+			Tx:          memory.NewTxManager(),
+			Idempotency: memory.NewInMemoryIdempotencyStore(),
+			Outbox:      memory.NewInMemoryOutboxStore(),
+			Webhooks:    memory.NewInMemoryWebhookStore(),
+			Alerts:      memory.NewInMemoryAlertStore(),
+			Push:        memory.NewInMemoryPushStore(),
+		}
+	})
+}
+
+func TestSQLiteRepository(t *testing.T) {
+	conformance.Run(t, func(t *testing.T) conformance.Backend {
+		db, err := sqlite.Open(filepath.Join(t.TempDir(), "waypoint.db"))
+		require.NoError(t, err)
+		t.Cleanup(func() { db.Close() })
+		require.NoError(t, sqlite.Migrate(context.Background(), db))
+
+		return conformance.Backend{
+			Activities:  sqlite.NewSQLiteActivityRepo(db),
+			Definitions: sqlite.NewSQLiteDefinitionRepo(db),
+			Changes:     sqlite.NewSQLiteChangeRepo(db),
+			Tx:          repository.NewSQLTxManager(db),
+			Idempotency: sqlite.NewSQLiteIdempotencyStore(db),
+			Outbox:      sqlite.NewSQLiteOutboxStore(db),
+			Webhooks:    sqlite.NewSQLiteWebhookStore(db),
+			Alerts:      sqlite.NewSQLiteAlertStore(db),
+			Push:        sqlite.NewSQLitePushStore(db),
+		}
+	})
+}
+
+// TestSQLiteChangeFeed covers what the repositories cannot do themselves:
+// entities, and deletions made directly in the database.
+func TestSQLiteChangeFeed(t *testing.T) {
+	db, err := sqlite.Open(filepath.Join(t.TempDir(), "waypoint.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	require.NoError(t, sqlite.Migrate(context.Background(), db))
+
+	familyID := uuid.New()
+	ctx := context.WithValue(context.Background(), middleware.FamilyIDKey, familyID)
+	changes := sqlite.NewSQLiteChangeRepo(db)
+
+	entityID := uuid.New()
+	_, err = db.Exec("INSERT INTO entities (id, family_id, name, date_of_birth) VALUES (?, ?, ?, ?)",
+		entityID, familyID, "Ada", "2023-04-05")
+	require.NoError(t, err)
+
+	ar := &domain.ActivityRealization{ID: uuid.New(), DefinitionID: uuid.New(), EntityID: entityID, Status: domain.StatusPlanned}
+	require.NoError(t, sqlite.NewSQLiteActivityRepo(db).CreateRealization(ctx, ar))
+
+	list, err := changes.ListChanges(ctx, 0, 10)
+	require.NoError(t, err)
+	require.Len(t, list, 2)
+	require.NotNil(t, list[0].Entity)
+	assert.Equal(t, "Ada", list[0].Entity.Name)
+	require.NotNil(t, list[0].Entity.DateOfBirth)
+	assert.Equal(t, "2023-04-05", list[0].Entity.DateOfBirth.Format("2006-01-02"))
+	cursor := list[1].Sequence
+
+	_, err = db.Exec("UPDATE entities SET name = name WHERE id = ?", entityID)
+	require.NoError(t, err)
+	list, err = changes.ListChanges(ctx, cursor, 10)
+	require.NoError(t, err)
+	assert.Empty(t, list, "rewriting a row unchanged is not a change")
+
+	_, err = db.Exec("DELETE FROM activity_realizations WHERE id = ?", ar.ID)
+	require.NoError(t, err)
+	list, err = changes.ListChanges(ctx, cursor, 10)
+	require.NoError(t, err)
+	require.Len(t, list, 1)
+	assert.Equal(t, domain.Change{Sequence: list[0].Sequence, Kind: domain.ChangeRealization, ID: ar.ID, Deleted: true}, list[0])
+
+	last, err := changes.LastSequence(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, list[0].Sequence, last)
+}
+
+// TestSQLiteEntities lists the children entered directly in the database,
+// as nothing else writes them.
+func TestSQLiteEntities(t *testing.T) {
+	db, err := sqlite.Open(filepath.Join(t.TempDir(), "waypoint.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	require.NoError(t, sqlite.Migrate(context.Background(), db))
+
+	familyID := uuid.New()
+	ctx := context.WithValue(context.Background(), middleware.FamilyIDKey, familyID)
+	ada, bo := uuid.New(), uuid.New()
+	for _, row := range []struct {
+		id, familyID uuid.UUID
+		name         string
+	}{{bo, familyID, "Bo"}, {ada, familyID, "Ada"}, {uuid.New(), uuid.New(), "Cy"}} {
+		_, err := db.Exec("INSERT INTO entities (id, family_id, name) VALUES (?, ?, ?)", row.id, row.familyID, row.name)
+		require.NoError(t, err)
+	}
+
+	entities, err := sqlite.NewSQLiteEntityRepo(db).ListByFamily(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []domain.Entity{
+		{ID: ada, FamilyID: familyID, Name: "Ada"},
+		{ID: bo, FamilyID: familyID, Name: "Bo"},
+	}, entities, "only the family's children, by name")
+}
+
+// TestPostgresRepository runs against the migrated database named by
+// WAYPOINT_TEST_DATABASE_URL, e.g. the one `make test-postgres` starts. It is
+// skipped when the variable is not set.
+func TestPostgresRepository(t *testing.T) {
+	dsn := os.Getenv("WAYPOINT_TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("WAYPOINT_TEST_DATABASE_URL not set")
+	}
+
+	db, err := sql.Open("postgres", dsn)
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	require.NoError(t, db.Ping())
+
+	conformance.Run(t, func(t *testing.T) conformance.Backend {
+		return conformance.Backend{
+			Activities:  postgres.NewPostgresActivityRepo(db),
+			Definitions: postgres.NewPostgresDefinitionRepo(db),
+			Changes:     postgres.NewPostgresChangeRepo(db),
+			Tx:          repository.NewSQLTxManager(db),
+			Idempotency: postgres.NewPostgresIdempotencyStore(db),
+			Outbox:      postgres.NewPostgresOutboxStore(db),
+			Webhooks:    postgres.NewPostgresWebhookStore(db),
+			Alerts:      postgres.NewPostgresAlertStore(db),
+			Push:        postgres.NewPostgresPushStore(db),
+			Seed:        postgresSeeder{db: db},
+		}
+	})
+}
+
+// postgresSeeder creates the rows realizations reference and removes
+// everything a family owns once the test is done.
+type postgresSeeder struct {
+	db *sql.DB
+}
+
+func (s postgresSeeder) Family(t *testing.T) uuid.UUID {
+	t.Helper()
+
+	familyID := uuid.New()
+	_, err := s.db.Exec("INSERT INTO families (id, name) VALUES ($1, $2)", familyID, "Test family")
+	require.NoError(t, err)
+
+	// Children go before their parents, since not every reference cascades.
+	t.Cleanup(func() {
+		for _, stmt := range []string{
+			"DELETE FROM push_preferences WHERE family_id = $1",
+			"DELETE FROM push_subscriptions WHERE family_id = $1",
+			"DELETE FROM alerts WHERE family_id = $1",
+			"DELETE FROM alert_rules WHERE family_id = $1",
+			"DELETE FROM webhook_deliveries WHERE family_id = $1",
+			"DELETE FROM webhook_subscriptions WHERE family_id = $1",
+			"DELETE FROM outbox_events WHERE family_id = $1",
+			"DELETE FROM idempotency_keys WHERE family_id = $1",
+			"DELETE FROM sync_changes WHERE family_id = $1",
+			"DELETE FROM realization_caregivers WHERE realization_id IN (SELECT id FROM activity_realizations WHERE family_id = $1)",
+			"DELETE FROM realization_events WHERE family_id = $1",
+			"DELETE FROM activity_realizations WHERE family_id = $1",
+			"DELETE FROM activity_definitions WHERE family_id = $1",
+			"DELETE FROM caregivers WHERE family_id = $1",
+			"DELETE FROM entities WHERE family_id = $1",
+			"DELETE FROM families WHERE id = $1",
+		} {
+			_, err := s.db.Exec(stmt, familyID)
+			require.NoError(t, err, stmt)
+		}
+	})
+	return familyID
+}
+
+func (s postgresSeeder) Entity(t *testing.T, familyID uuid.UUID) uuid.UUID {
+	t.Helper()
+
+	var id uuid.UUID
+	require.NoError(t, s.db.QueryRow(
+		"INSERT INTO entities (family_id, name) VALUES ($1, $2) RETURNING id", familyID, "Test child",
+	).Scan(&id))
+	return id
+}
+
+func (s postgresSeeder) Caregiver(t *testing.T, familyID uuid.UUID) uuid.UUID {
+	t.Helper()
+
+	var id uuid.UUID
+	require.NoError(t, s.db.QueryRow(
+		"INSERT INTO caregivers (family_id, name, email, password_hash) VALUES ($1, $2, $3, '') RETURNING id",
+		familyID, "Test caregiver", uuid.NewString()+"@example.com",
+	).Scan(&id))
+	return id
+}
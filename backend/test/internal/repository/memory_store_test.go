@@ -0,0 +1,250 @@
+package repository_test
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/luisteixeira/waypoint/backend/internal/alert"
+	"github.com/luisteixeira/waypoint/backend/internal/domain"
+	"github.com/luisteixeira/waypoint/backend/internal/idempotency"
+	"github.com/luisteixeira/waypoint/backend/internal/middleware"
+	"github.com/luisteixeira/waypoint/backend/internal/outbox"
+	"github.com/luisteixeira/waypoint/backend/internal/push"
+	"github.com/luisteixeira/waypoint/backend/internal/repository/memory"
+	"github.com/luisteixeira/waypoint/backend/internal/webhook"
+	"github.com/luisteixeira/waypoint/backend/test/internal/repository/conformance"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemoryStoreRepository(t *testing.T) {
+	conformance.Run(t, func(t *testing.T) conformance.Backend {
+		store, err := memory.Open(t.TempDir(), 0)
+		require.NoError(t, err)
+		t.Cleanup(func() { store.Close() })
+
+		return conformance.Backend{
+			Activities:  store.Activities,
+			Definitions: store.Definitions,
+			Changes:     store.Changes,
+			Tx:          memory.NewTxManager(),
+			Idempotency: store.Idempotency,
+			Outbox:      store.Outbox,
+			Webhooks:    store.Webhooks,
+			Alerts:      store.Alerts,
+			Push:        store.Push,
+		}
+	})
+}
+
+func TestMemoryStorePersistence(t *testing.T) {
+	dir := t.TempDir()
+	ctx := context.WithValue(context.Background(), middleware.FamilyIDKey, uuid.New())
+
+	store, err := memory.Open(dir, 0)
+	require.NoError(t, err)
+
+	def, err := store.Definitions.GetOrCreateByName(ctx, "Nap")
+	require.NoError(t, err)
+
+	started := time.Now().Add(-time.Hour).Round(time.Second)
+	ar := &domain.ActivityRealization{
+		ID:           uuid.New(),
+		DefinitionID: def.ID,
+		EntityID:     uuid.New(),
+		Status:       domain.StatusInProgress,
+		StartedAt:    &started,
+	}
+	require.NoError(t, store.Activities.CreateRealization(ctx, ar,
+		domain.RealizationEvent{Type: domain.EventPlanned, OccurredAt: started},
+		domain.RealizationEvent{Type: domain.EventStarted, OccurredAt: started},
+	))
+
+	// reopen simulates a restart without a clean shutdown, leaving the
+	// previous store's files as they are.
+	reopen := func(t *testing.T) *memory.Store {
+		t.Helper()
+		store, err := memory.Open(dir, 0)
+		require.NoError(t, err)
+		return store
+	}
+
+	t.Run("Replays the log after a crash", func(t *testing.T) {
+		restored := reopen(t)
+
+		got, err := restored.Activities.GetRealizationByID(ctx, ar.ID)
+		require.NoError(t, err)
+		assert.Equal(t, domain.StatusInProgress, got.Status)
+		assert.Equal(t, 1, got.Version)
+
+		defs, err := restored.Definitions.ListByFamily(ctx)
+		require.NoError(t, err)
+		require.Len(t, defs, 1)
+		assert.Equal(t, def.ID, defs[0].ID)
+	})
+
+	t.Run("Restores a snapshot plus later changes", func(t *testing.T) {
+		require.NoError(t, store.Snapshot())
+
+		finished := started.Add(30 * time.Minute)
+		ar.Status = domain.StatusCompleted
+		ar.FinishedAt = &finished
+		require.NoError(t, store.Activities.UpdateRealization(ctx, ar,
+			domain.RealizationEvent{Type: domain.EventCompleted, OccurredAt: finished},
+		))
+
+		restored := reopen(t)
+
+		got, err := restored.Activities.GetRealizationByID(ctx, ar.ID)
+		require.NoError(t, err)
+		assert.Equal(t, domain.StatusCompleted, got.Status)
+		assert.Equal(t, 2, got.Version)
+
+		events, err := restored.Activities.ListEvents(ctx, ar.ID)
+		require.NoError(t, err)
+		require.Len(t, events, 3)
+		assert.Equal(t, domain.EventCompleted, events[2].Type)
+	})
+
+	t.Run("Keeps change sequences, so sync cursors stay valid", func(t *testing.T) {
+		want, err := store.Changes.ListChanges(ctx, 0, 100)
+		require.NoError(t, err)
+		require.Len(t, want, 2)
+
+		got, err := reopen(t).Changes.ListChanges(ctx, 0, 100)
+		require.NoError(t, err)
+		require.Len(t, got, 2)
+		for i := range want {
+			assert.Equal(t, want[i].ID, got[i].ID)
+			assert.Equal(t, want[i].Sequence, got[i].Sequence)
+		}
+	})
+
+	t.Run("Drops a torn last line", func(t *testing.T) {
+		f, err := os.OpenFile(filepath.Join(dir, "changes.log"), os.O_APPEND|os.O_WRONLY, 0)
+		require.NoError(t, err)
+		_, err = f.WriteString(`{"realization":{"id":`)
+		require.NoError(t, err)
+		require.NoError(t, f.Close())
+
+		restored := reopen(t)
+
+		got, err := restored.Activities.GetRealizationByID(ctx, ar.ID)
+		require.NoError(t, err)
+		assert.Equal(t, domain.StatusCompleted, got.Status)
+
+		// New changes must land after the surviving entries.
+		_, err = restored.Definitions.GetOrCreateByName(ctx, "Bath")
+		require.NoError(t, err)
+		require.NoError(t, restored.Close())
+
+		again := reopen(t)
+		defs, err := again.Definitions.ListByFamily(ctx)
+		require.NoError(t, err)
+		assert.Len(t, defs, 2)
+	})
+
+	t.Run("Keeps webhooks, alerts, push subscriptions and idempotency records", func(t *testing.T) {
+		restored := reopen(t)
+
+		sub := &webhook.Subscription{ID: uuid.New(), URL: "https://example.com/hook", Events: []webhook.EventType{webhook.EventActivityStarted}, CreatedAt: started}
+		require.NoError(t, restored.Webhooks.CreateSubscription(ctx, sub))
+		_, err := restored.Webhooks.Enqueue(ctx, webhook.Event{ID: uuid.New(), Type: webhook.EventActivityStarted, OccurredAt: started})
+		require.NoError(t, err)
+
+		rule := &alert.Rule{ID: uuid.New(), Kind: alert.KindRunningLong, DefinitionID: def.ID, Threshold: time.Hour, Channels: []alert.Channel{alert.ChannelPush}, CreatedAt: started}
+		require.NoError(t, restored.Alerts.CreateRule(ctx, rule))
+
+		pushSub := &push.Subscription{ID: uuid.New(), CaregiverID: uuid.New(), Endpoint: "https://push.example.com/1", CreatedAt: started}
+		require.NoError(t, restored.Push.SaveSubscription(ctx, pushSub))
+
+		_, err = restored.Idempotency.Reserve(ctx, idempotency.Record{Key: "k1", RequestHash: "h1", CreatedAt: started, ExpiresAt: started.Add(48 * time.Hour)})
+		require.NoError(t, err)
+		require.NoError(t, restored.Idempotency.Complete(ctx, "k1", 201, nil, []byte(`{}`)))
+
+		require.NoError(t, restored.Snapshot())
+		gone := &alert.Rule{ID: uuid.New(), Kind: alert.KindNotStarted, DefinitionID: def.ID, Threshold: time.Hour, Channels: []alert.Channel{alert.ChannelPush}, CreatedAt: started}
+		require.NoError(t, restored.Alerts.CreateRule(ctx, gone))
+		require.NoError(t, restored.Alerts.DeleteRule(ctx, gone.ID))
+
+		again := reopen(t)
+
+		subs, err := again.Webhooks.ListSubscriptions(ctx)
+		require.NoError(t, err)
+		require.Len(t, subs, 1)
+		assert.Equal(t, sub.URL, subs[0].URL)
+		deliveries, err := again.Webhooks.ListDeliveries(ctx, sub.ID, 10)
+		require.NoError(t, err)
+		assert.Len(t, deliveries, 1)
+
+		rules, err := again.Alerts.ListRules(ctx)
+		require.NoError(t, err)
+		require.Len(t, rules, 1)
+		assert.Equal(t, rule.ID, rules[0].ID)
+
+		pushSubs, err := again.Push.ListSubscriptions(ctx, uuid.Nil)
+		require.NoError(t, err)
+		require.Len(t, pushSubs, 1)
+		assert.Equal(t, pushSub.Endpoint, pushSubs[0].Endpoint)
+
+		rec, err := again.Idempotency.Reserve(ctx, idempotency.Record{Key: "k1", RequestHash: "h1", CreatedAt: started, ExpiresAt: started.Add(48 * time.Hour)})
+		require.NoError(t, err)
+		require.NotNil(t, rec)
+		assert.Equal(t, 201, rec.Status)
+		assert.Equal(t, []byte(`{}`), rec.Body)
+	})
+
+	t.Run("Logs a unit of work only when it commits", func(t *testing.T) {
+		restored := reopen(t)
+		tx := memory.NewTxManager()
+
+		err := tx.WithinTx(ctx, func(ctx context.Context) error {
+			_, err := restored.Definitions.GetOrCreateByName(ctx, "Walk")
+			return err
+		})
+		require.NoError(t, err)
+
+		err = tx.WithinTx(ctx, func(ctx context.Context) error {
+			if _, err := restored.Definitions.GetOrCreateByName(ctx, "Swim"); err != nil {
+				return err
+			}
+			return assert.AnError
+		})
+		require.ErrorIs(t, err, assert.AnError)
+
+		defs, err := reopen(t).Definitions.ListByFamily(ctx)
+		require.NoError(t, err)
+		var names []string
+		for _, d := range defs {
+			names = append(names, d.Name)
+		}
+		assert.Equal(t, []string{"Bath", "Nap", "Walk"}, names)
+	})
+
+	t.Run("Logs outbox events in the same line as their change", func(t *testing.T) {
+		restored := reopen(t)
+		tx := memory.NewTxManager()
+		repo := outbox.NewActivityRepo(restored.Activities, restored.Outbox, tx, time.Now)
+
+		planned := &domain.ActivityRealization{ID: uuid.New(), DefinitionID: def.ID, EntityID: uuid.New(), Status: domain.StatusPlanned}
+		require.NoError(t, repo.CreateRealization(ctx, planned, domain.RealizationEvent{Type: domain.EventPlanned, OccurredAt: started}))
+
+		data, err := os.ReadFile(filepath.Join(dir, "changes.log"))
+		require.NoError(t, err)
+		lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+		last := lines[len(lines)-1]
+		assert.Contains(t, last, planned.ID.String())
+		assert.Contains(t, last, `"outbox"`)
+
+		due, err := reopen(t).Outbox.ClaimDue(ctx, time.Now(), time.Now().Add(time.Minute), 10)
+		require.NoError(t, err)
+		require.Len(t, due, 1)
+		assert.Equal(t, outbox.EventActivityPlanned, due[0].Type)
+		assert.Equal(t, planned.ID, due[0].Realization.ID)
+	})
+}
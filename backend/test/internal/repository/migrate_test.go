@@ -0,0 +1,152 @@
+package repository_test
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/luisteixeira/waypoint/backend/internal/repository/postgres"
+	"github.com/luisteixeira/waypoint/backend/internal/repository/sqlite"
+	"github.com/luisteixeira/waypoint/backend/migrations"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEmbeddedMigrations(t *testing.T) {
+	loaded, err := postgres.LoadMigrations(migrations.FS)
+	require.NoError(t, err)
+	require.NotEmpty(t, loaded)
+
+	for i, m := range loaded {
+		assert.Equal(t, uint(i+1), m.Version, "migrations are numbered without gaps")
+		assert.NotEmpty(t, m.Name)
+		assert.NotEmpty(t, m.Down, "migration %d has no down script", m.Version)
+	}
+}
+
+func TestPostgresMigrator(t *testing.T) {
+	dsn := os.Getenv("WAYPOINT_TEST_DATABASE_URL")
+	if dsn == "" {
+		t.Skip("WAYPOINT_TEST_DATABASE_URL not set")
+	}
+
+	db, err := sql.Open("postgres", dsn)
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	ctx := context.Background()
+	migrator, err := postgres.NewMigrator(db)
+	require.NoError(t, err)
+
+	_, err = migrator.Up(ctx)
+	require.NoError(t, err)
+
+	t.Run("Up is idempotent", func(t *testing.T) {
+		applied, err := migrator.Up(ctx)
+		require.NoError(t, err)
+		assert.Zero(t, applied)
+
+		version, dirty, err := migrator.Version(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, migrator.Latest(), version)
+		assert.False(t, dirty)
+	})
+
+	t.Run("Status lists every migration as applied", func(t *testing.T) {
+		statuses, err := migrator.Status(ctx)
+		require.NoError(t, err)
+		for _, s := range statuses {
+			assert.True(t, s.Applied, "migration %d", s.Version)
+		}
+	})
+
+	t.Run("Down and up again", func(t *testing.T) {
+		reverted, err := migrator.Down(ctx, 1)
+		require.NoError(t, err)
+		assert.Equal(t, 1, reverted)
+
+		version, _, err := migrator.Version(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, migrator.Latest()-1, version)
+		assert.Error(t, migrator.Check(ctx), "a schema behind the binary is not ready")
+
+		applied, err := migrator.Up(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, 1, applied)
+		assert.NoError(t, migrator.Check(ctx))
+	})
+
+	t.Run("Cancels all but the latest activity a child is in before enforcing one", func(t *testing.T) {
+		const uniqueActiveEntity = 5
+		_, err := migrator.Down(ctx, int(migrator.Latest()-uniqueActiveEntity+1))
+		require.NoError(t, err)
+
+		familyID, entityID, definitionID := uuid.New(), uuid.New(), uuid.New()
+		older, newer, elsewhere := uuid.New(), uuid.New(), uuid.New()
+		started := time.Now().Add(-time.Hour).UTC().Truncate(time.Microsecond)
+		seed := []struct {
+			query string
+			args  []any
+		}{
+			{`INSERT INTO families (id, name) VALUES ($1, 'Migration')`, []any{familyID}},
+			{`INSERT INTO entities (id, family_id, name) VALUES ($1, $2, 'Ada')`, []any{entityID, familyID}},
+			{`INSERT INTO activity_definitions (id, family_id, name) VALUES ($1, $2, 'Nap')`, []any{definitionID, familyID}},
+			{`INSERT INTO activity_realizations (id, family_id, definition_id, entity_id, status, started_at)
+				VALUES ($1, $2, $3, $4, 'in_progress', $5), ($6, $2, $3, $4, 'in_progress', $7), ($8, $2, $3, NULL, 'in_progress', $5)`,
+				[]any{older, familyID, definitionID, entityID, started, newer, started.Add(time.Minute), elsewhere}},
+		}
+		for _, stmt := range seed {
+			_, err := db.ExecContext(ctx, stmt.query, stmt.args...)
+			require.NoError(t, err)
+		}
+		t.Cleanup(func() {
+			db.ExecContext(ctx, `DELETE FROM realization_events WHERE family_id = $1`, familyID)
+			db.ExecContext(ctx, `DELETE FROM families WHERE id = $1`, familyID)
+			db.ExecContext(ctx, `DELETE FROM sync_changes WHERE family_id = $1`, familyID)
+		})
+
+		_, err = migrator.Up(ctx)
+		require.NoError(t, err)
+		require.NoError(t, migrator.Check(ctx))
+
+		status := func(id uuid.UUID) (status string, version int) {
+			require.NoError(t, db.QueryRowContext(ctx, `SELECT status, version FROM activity_realizations WHERE id = $1`, id).Scan(&status, &version))
+			return status, version
+		}
+		s, version := status(older)
+		assert.Equal(t, "cancelled", s)
+		assert.Equal(t, 2, version)
+		s, _ = status(newer)
+		assert.Equal(t, "in_progress", s)
+		s, _ = status(elsewhere)
+		assert.Equal(t, "in_progress", s, "a realization of no child is left alone")
+
+		var events []string
+		rows, err := db.QueryContext(ctx, `SELECT event_type FROM realization_events WHERE realization_id = $1 ORDER BY sequence`, older)
+		require.NoError(t, err)
+		defer rows.Close()
+		for rows.Next() {
+			var eventType string
+			require.NoError(t, rows.Scan(&eventType))
+			events = append(events, eventType)
+		}
+		require.NoError(t, rows.Err())
+		assert.Equal(t, []string{"cancelled"}, events)
+	})
+}
+
+func TestSQLiteSchemaCheck(t *testing.T) {
+	ctx := context.Background()
+	db, err := sqlite.Open(filepath.Join(t.TempDir(), "waypoint.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	assert.Error(t, sqlite.CheckSchema(ctx, db), "an unmigrated database is not ready")
+
+	require.NoError(t, sqlite.Migrate(ctx, db))
+	assert.NoError(t, sqlite.CheckSchema(ctx, db))
+}
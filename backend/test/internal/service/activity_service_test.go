@@ -3,19 +3,21 @@ package service_test
 import (
 	"context"
 	"testing"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/luisteixeira/waypoint/backend/internal/domain"
 	"github.com/luisteixeira/waypoint/backend/internal/middleware"
+	"github.com/luisteixeira/waypoint/backend/internal/repository/memory"
 	"github.com/luisteixeira/waypoint/backend/internal/service"
-	"github.com/luisteixeira/waypoint/backend/test/internal/repository/memory"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestActivityService_StartActivity(t *testing.T) {
 	repo := memory.NewInMemoryActivityRepo()
 	defRepo := memory.NewInMemoryDefinitionRepo()
-	svc := service.NewActivityService(repo, defRepo)
+	svc := service.NewActivityService(repo, defRepo, memory.NewTxManager(), service.DefaultClientTimeWindow)
 
 	familyID := uuid.New()
 	entityID := uuid.New()
@@ -58,12 +60,43 @@ func TestActivityService_StartActivity(t *testing.T) {
 		assert.ErrorIs(t, err, domain.ErrEntityBusy)
 		assert.Nil(t, ar)
 	})
+
+	t.Run("A failed start leaves no new definition behind", func(t *testing.T) {
+		input := domain.StartActivityInput{
+			EntityID:           entityID,
+			NewDefinittionName: "Swimming",
+		}
+
+		_, err := svc.StartActivity(ctx, input)
+		require.ErrorIs(t, err, domain.ErrEntityBusy)
+
+		defs, err := defRepo.ListByFamily(ctx)
+		require.NoError(t, err)
+		assert.Empty(t, defs)
+	})
+
+	t.Run("Starts a planned activity but does not resume a paused one", func(t *testing.T) {
+		otherID := uuid.New()
+		planned, err := svc.PlanActivity(ctx, domain.StartActivityInput{EntityID: otherID, NewDefinittionName: "Bath"})
+		require.NoError(t, err)
+
+		started, err := svc.StartActivity(ctx, domain.StartActivityInput{RealizationID: planned.ID, ExpectedVersion: planned.Version})
+		require.NoError(t, err)
+		assert.Equal(t, domain.StatusInProgress, started.Status)
+
+		paused, err := svc.PauseActivity(ctx, started.ID, started.Version)
+		require.NoError(t, err)
+		_, err = svc.StartActivity(ctx, domain.StartActivityInput{RealizationID: paused.ID, ExpectedVersion: paused.Version})
+		var invalid *domain.InvalidTransitionError
+		require.ErrorAs(t, err, &invalid)
+		assert.Equal(t, domain.StatusPaused, invalid.From)
+	})
 }
 
 func TestActivityService_PlanActivity(t *testing.T) {
 	repo := memory.NewInMemoryActivityRepo()
 	defRepo := memory.NewInMemoryDefinitionRepo()
-	svc := service.NewActivityService(repo, defRepo)
+	svc := service.NewActivityService(repo, defRepo, memory.NewTxManager(), service.DefaultClientTimeWindow)
 
 	familyID := uuid.New()
 	entityID := uuid.New()
@@ -117,3 +150,226 @@ func TestActivityService_PlanActivity(t *testing.T) {
 		assert.Contains(t, err.Error(), "either definition_id or new_definition_name")
 	})
 }
+
+func TestActivityService_EventHistory(t *testing.T) {
+	repo := memory.NewInMemoryActivityRepo()
+	defRepo := memory.NewInMemoryDefinitionRepo()
+	svc := service.NewActivityService(repo, defRepo, memory.NewTxManager(), service.DefaultClientTimeWindow)
+
+	familyID := uuid.New()
+	ctx := context.WithValue(context.Background(), middleware.FamilyIDKey, familyID)
+
+	ar, err := svc.StartActivity(ctx, domain.StartActivityInput{
+		EntityID:           uuid.New(),
+		NewDefinittionName: "Nap",
+	})
+	require.NoError(t, err)
+
+	beforePause := time.Now()
+	time.Sleep(time.Millisecond)
+
+	_, err = svc.PauseActivity(ctx, ar.ID, 0)
+	require.NoError(t, err)
+	_, err = svc.ResumeActivity(ctx, ar.ID, 0)
+	require.NoError(t, err)
+
+	caregiverID := uuid.New()
+	_, err = svc.EditActivity(ctx, ar.ID, 0, domain.EditActivityInput{CaregiversIDs: []uuid.UUID{caregiverID}})
+	require.NoError(t, err)
+	_, err = svc.CompleteActivity(ctx, ar.ID, 0)
+	require.NoError(t, err)
+	noted, err := svc.AddNote(ctx, ar.ID, 0, "  Slept the whole way home  ")
+	require.NoError(t, err)
+
+	t.Run("Notes are recorded without changing the state", func(t *testing.T) {
+		assert.Equal(t, domain.StatusCompleted, noted.Status)
+
+		events, err := svc.GetActivityHistory(ctx, ar.ID)
+		require.NoError(t, err)
+		last := events[len(events)-1]
+		assert.Equal(t, domain.EventNoted, last.Type)
+		assert.Equal(t, "Slept the whole way home", last.Data.Note)
+
+		_, err = svc.AddNote(ctx, ar.ID, 0, " ")
+		assert.ErrorIs(t, err, domain.ErrValidation)
+	})
+
+	t.Run("Records every lifecycle event in order", func(t *testing.T) {
+		events, err := svc.GetActivityHistory(ctx, ar.ID)
+		require.NoError(t, err)
+
+		var types []domain.EventType
+		for _, e := range events {
+			types = append(types, e.Type)
+			assert.Equal(t, ar.ID, e.RealizationID)
+		}
+		assert.Equal(t, []domain.EventType{
+			domain.EventPlanned, domain.EventStarted, domain.EventPaused,
+			domain.EventResumed, domain.EventEdited, domain.EventCompleted, domain.EventNoted,
+		}, types)
+	})
+
+	t.Run("Reconstructs the state at a point in time", func(t *testing.T) {
+		past, err := svc.GetActivityAt(ctx, ar.ID, beforePause)
+		require.NoError(t, err)
+		assert.Equal(t, domain.StatusInProgress, past.Status)
+		assert.Empty(t, past.CaregiversIDs)
+		assert.Nil(t, past.FinishedAt)
+
+		current, err := svc.GetActivity(ctx, ar.ID)
+		require.NoError(t, err)
+		assert.Equal(t, domain.StatusCompleted, current.Status)
+		assert.Equal(t, []uuid.UUID{caregiverID}, current.CaregiversIDs)
+	})
+
+	t.Run("Rebuilds the projection from events", func(t *testing.T) {
+		rebuilt, err := svc.RebuildActivity(ctx, ar.ID)
+		require.NoError(t, err)
+
+		current, err := svc.GetActivity(ctx, ar.ID)
+		require.NoError(t, err)
+		assert.Equal(t, current.Status, rebuilt.Status)
+		assert.Equal(t, current.CaregiversIDs, rebuilt.CaregiversIDs)
+		assert.Equal(t, current.FinishedAt.UnixNano(), rebuilt.FinishedAt.UnixNano())
+	})
+
+	t.Run("Fail to pause an activity that is not in progress", func(t *testing.T) {
+		_, err := svc.PauseActivity(ctx, ar.ID, 0)
+		assert.Error(t, err)
+	})
+}
+
+func TestActivityService_OptimisticConcurrency(t *testing.T) {
+	repo := memory.NewInMemoryActivityRepo()
+	defRepo := memory.NewInMemoryDefinitionRepo()
+	svc := service.NewActivityService(repo, defRepo, memory.NewTxManager(), service.DefaultClientTimeWindow)
+
+	ctx := context.WithValue(context.Background(), middleware.FamilyIDKey, uuid.New())
+
+	ar, err := svc.StartActivity(ctx, domain.StartActivityInput{
+		EntityID:           uuid.New(),
+		NewDefinittionName: "Nap",
+	})
+	require.NoError(t, err)
+	seenVersion := ar.Version
+
+	t.Run("First writer wins and bumps the version", func(t *testing.T) {
+		completed, err := svc.CompleteActivity(ctx, ar.ID, seenVersion)
+		require.NoError(t, err)
+		assert.Equal(t, seenVersion+1, completed.Version)
+	})
+
+	t.Run("Second writer with the same version is rejected with the current state", func(t *testing.T) {
+		_, err := svc.CancelActivity(ctx, ar.ID, seenVersion)
+
+		var stale *domain.StaleVersionError
+		require.ErrorAs(t, err, &stale)
+		assert.Equal(t, domain.StatusCompleted, stale.Current.Status)
+		assert.Equal(t, seenVersion+1, stale.Current.Version)
+	})
+
+	t.Run("Repository rejects a blind update of an outdated copy", func(t *testing.T) {
+		outdated := *ar
+		outdated.Status = domain.StatusCancelled
+
+		err := repo.UpdateRealization(ctx, &outdated)
+		var stale *domain.StaleVersionError
+		assert.ErrorAs(t, err, &stale)
+	})
+
+	t.Run("Missing realization yields a not-found error", func(t *testing.T) {
+		_, err := svc.CompleteActivity(ctx, uuid.New(), 0)
+		assert.ErrorIs(t, err, domain.ErrNotFound)
+	})
+}
+
+func TestActivityService_ClientTime(t *testing.T) {
+	svc := service.NewActivityService(memory.NewInMemoryActivityRepo(), memory.NewInMemoryDefinitionRepo(), memory.NewTxManager(),
+		service.ClientTimeWindow{MaxAge: 24 * time.Hour, MaxAhead: time.Minute})
+	ctx := context.WithValue(context.Background(), middleware.FamilyIDKey, uuid.New())
+
+	start := func(ctx context.Context) (*domain.ActivityRealization, error) {
+		return svc.StartActivity(ctx, domain.StartActivityInput{EntityID: uuid.New(), NewDefinittionName: "Nap"})
+	}
+
+	t.Run("Stamps the activity with the client's times", func(t *testing.T) {
+		startedAt := time.Now().Add(-2 * time.Hour).UTC()
+		finishedAt := startedAt.Add(90 * time.Minute)
+
+		ar, err := start(domain.WithClientTime(ctx, startedAt))
+		require.NoError(t, err)
+		ar, err = svc.CompleteActivity(domain.WithClientTime(ctx, finishedAt), ar.ID, ar.Version)
+		require.NoError(t, err)
+
+		require.NotNil(t, ar.StartedAt)
+		require.NotNil(t, ar.FinishedAt)
+		assert.True(t, startedAt.Equal(*ar.StartedAt))
+		assert.True(t, finishedAt.Equal(*ar.FinishedAt))
+	})
+
+	t.Run("Rejects times outside the window", func(t *testing.T) {
+		var validation *domain.ValidationError
+
+		_, err := start(domain.WithClientTime(ctx, time.Now().Add(-25*time.Hour)))
+		require.ErrorAs(t, err, &validation)
+		assert.Equal(t, "client_timestamp", validation.Field)
+
+		_, err = start(domain.WithClientTime(ctx, time.Now().Add(time.Hour)))
+		require.ErrorAs(t, err, &validation)
+		assert.Equal(t, "client_timestamp", validation.Field)
+	})
+
+	t.Run("Rejects edited times outside the window", func(t *testing.T) {
+		ar, err := start(ctx)
+		require.NoError(t, err)
+		var validation *domain.ValidationError
+
+		farPast := time.Now().Add(-25 * time.Hour)
+		_, err = svc.EditActivity(ctx, ar.ID, ar.Version, domain.EditActivityInput{StartedAt: &farPast})
+		require.ErrorAs(t, err, &validation)
+		assert.Equal(t, "started_at", validation.Field)
+
+		farFuture := time.Now().Add(time.Hour)
+		_, err = svc.EditActivity(ctx, ar.ID, ar.Version, domain.EditActivityInput{FinishedAt: &farFuture})
+		require.ErrorAs(t, err, &validation)
+		assert.Equal(t, "finished_at", validation.Field)
+
+		earlier := time.Now().Add(-time.Hour)
+		_, err = svc.EditActivity(ctx, ar.ID, ar.Version, domain.EditActivityInput{StartedAt: &earlier})
+		assert.NoError(t, err)
+	})
+
+	t.Run("Allows a client clock slightly ahead", func(t *testing.T) {
+		_, err := start(domain.WithClientTime(ctx, time.Now().Add(30*time.Second)))
+		assert.NoError(t, err)
+	})
+
+	t.Run("Rejects finishing before the activity started", func(t *testing.T) {
+		ar, err := start(ctx)
+		require.NoError(t, err)
+
+		_, err = svc.CompleteActivity(domain.WithClientTime(ctx, ar.StartedAt.Add(-time.Minute)), ar.ID, ar.Version)
+		assert.ErrorIs(t, err, domain.ErrValidation)
+	})
+
+	t.Run("Rejects times before the last change", func(t *testing.T) {
+		startedAt := time.Now().Add(-2 * time.Hour)
+		ar, err := start(domain.WithClientTime(ctx, startedAt))
+		require.NoError(t, err)
+		ar, err = svc.PauseActivity(domain.WithClientTime(ctx, startedAt.Add(time.Hour)), ar.ID, ar.Version)
+		require.NoError(t, err)
+
+		earlier := domain.WithClientTime(ctx, startedAt.Add(30*time.Minute))
+		var validation *domain.ValidationError
+		_, err = svc.ResumeActivity(earlier, ar.ID, ar.Version)
+		require.ErrorAs(t, err, &validation)
+		assert.Equal(t, "client_timestamp", validation.Field)
+		_, err = svc.AddNote(earlier, ar.ID, ar.Version, "Woke up")
+		assert.ErrorIs(t, err, domain.ErrValidation)
+		_, err = svc.EditActivity(earlier, ar.ID, ar.Version, domain.EditActivityInput{CaregiversIDs: []uuid.UUID{uuid.New()}})
+		assert.ErrorIs(t, err, domain.ErrValidation)
+
+		_, err = svc.ResumeActivity(domain.WithClientTime(ctx, startedAt.Add(time.Hour)), ar.ID, ar.Version)
+		assert.NoError(t, err, "the same time as the last change is fine")
+	})
+}
@@ -0,0 +1,174 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/luisteixeira/waypoint/backend/internal/domain"
+	"github.com/luisteixeira/waypoint/backend/internal/middleware"
+	"github.com/luisteixeira/waypoint/backend/internal/repository/memory"
+	"github.com/luisteixeira/waypoint/backend/internal/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setupBatch(t *testing.T) (domain.BatchService, domain.ActivityService, context.Context) {
+	t.Helper()
+
+	tx := memory.NewTxManager()
+	activities := service.NewActivityService(memory.NewInMemoryActivityRepo(), memory.NewInMemoryDefinitionRepo(), tx, service.DefaultClientTimeWindow)
+	ctx := context.WithValue(context.Background(), middleware.FamilyIDKey, uuid.New())
+	return service.NewBatchService(activities, tx), activities, ctx
+}
+
+func startOp(id string, entityID uuid.UUID) domain.BatchOperation {
+	return domain.BatchOperation{
+		ID:              id,
+		Type:            domain.BatchStart,
+		ClientTimestamp: time.Now(),
+		Start:           domain.StartActivityInput{EntityID: entityID, NewDefinittionName: "Playground"},
+	}
+}
+
+func refOp(id string, opType domain.BatchOperationType, ref string) domain.BatchOperation {
+	return domain.BatchOperation{ID: id, Type: opType, ClientTimestamp: time.Now(), Ref: ref}
+}
+
+func statuses(results []domain.BatchResult) []domain.BatchStatus {
+	var out []domain.BatchStatus
+	for _, r := range results {
+		out = append(out, r.Status)
+	}
+	return out
+}
+
+func TestBatchService_ApplyBatch(t *testing.T) {
+	t.Run("Applies operations in order, resolving references", func(t *testing.T) {
+		batch, activities, ctx := setupBatch(t)
+
+		ops := []domain.BatchOperation{
+			startOp("op-1", uuid.New()),
+			refOp("op-2", domain.BatchComplete, "op-1"),
+			refOp("op-3", domain.BatchNote, "op-1"),
+		}
+		ops[2].Note = "Went down the big slide"
+		results, err := batch.ApplyBatch(ctx, ops, false)
+		require.NoError(t, err)
+
+		assert.Equal(t, []domain.BatchStatus{domain.BatchApplied, domain.BatchApplied, domain.BatchApplied}, statuses(results))
+		assert.Equal(t, "op-2", results[1].ID)
+		assert.Equal(t, domain.StatusCompleted, results[1].Realization.Status)
+
+		id := results[0].Realization.ID
+		assert.Equal(t, id, results[2].Realization.ID)
+		events, err := activities.GetActivityHistory(ctx, id)
+		require.NoError(t, err)
+		assert.Equal(t, domain.EventNoted, events[len(events)-1].Type)
+	})
+
+	t.Run("Stamps each operation with its client timestamp", func(t *testing.T) {
+		batch, activities, ctx := setupBatch(t)
+		startedAt := time.Now().Add(-time.Hour).UTC()
+
+		ops := []domain.BatchOperation{
+			startOp("op-1", uuid.New()),
+			refOp("op-2", domain.BatchNote, "op-1"),
+			refOp("op-3", domain.BatchComplete, "op-1"),
+		}
+		ops[0].ClientTimestamp = startedAt
+		ops[1].ClientTimestamp, ops[1].Note = startedAt.Add(10*time.Minute), "Swings"
+		ops[2].ClientTimestamp = startedAt.Add(30 * time.Minute)
+		results, err := batch.ApplyBatch(ctx, ops, false)
+		require.NoError(t, err)
+		require.Equal(t, []domain.BatchStatus{domain.BatchApplied, domain.BatchApplied, domain.BatchApplied}, statuses(results))
+
+		ar := results[2].Realization
+		assert.True(t, startedAt.Equal(*ar.StartedAt))
+		assert.True(t, ops[2].ClientTimestamp.Equal(*ar.FinishedAt))
+		events, err := activities.GetActivityHistory(ctx, ar.ID)
+		require.NoError(t, err)
+		assert.True(t, ops[1].ClientTimestamp.Equal(events[2].OccurredAt), "the note")
+	})
+
+	t.Run("Failures do not stop the rest of a batch", func(t *testing.T) {
+		batch, _, ctx := setupBatch(t)
+		entityID := uuid.New()
+
+		results, err := batch.ApplyBatch(ctx, []domain.BatchOperation{
+			startOp("op-1", entityID),
+			startOp("op-2", entityID),
+			refOp("op-3", domain.BatchComplete, "op-2"),
+			refOp("op-4", domain.BatchComplete, "op-1"),
+		}, false)
+		require.NoError(t, err)
+
+		assert.Equal(t, []domain.BatchStatus{
+			domain.BatchApplied, domain.BatchFailed, domain.BatchFailed, domain.BatchApplied,
+		}, statuses(results))
+		assert.ErrorIs(t, results[1].Err, domain.ErrEntityBusy)
+		assert.ErrorIs(t, results[2].Err, domain.ErrValidation, "a reference to a failed start cannot be resolved")
+	})
+
+	t.Run("Atomic batches apply nothing when an operation fails", func(t *testing.T) {
+		batch, activities, ctx := setupBatch(t)
+		entityID := uuid.New()
+
+		results, err := batch.ApplyBatch(ctx, []domain.BatchOperation{
+			startOp("op-1", entityID),
+			refOp("op-2", domain.BatchComplete, "op-1"),
+			refOp("op-3", domain.BatchComplete, "op-1"),
+			startOp("op-4", uuid.New()),
+		}, true)
+		require.NoError(t, err)
+
+		assert.Equal(t, []domain.BatchStatus{
+			domain.BatchRolledBack, domain.BatchRolledBack, domain.BatchFailed, domain.BatchSkipped,
+		}, statuses(results))
+		assert.ErrorIs(t, results[2].Err, domain.ErrInvalidTransition)
+		assert.Nil(t, results[0].Realization)
+
+		_, err = activities.StartActivity(ctx, domain.StartActivityInput{EntityID: entityID, NewDefinittionName: "Nap"})
+		assert.NoError(t, err, "the rolled back start left the child free")
+	})
+
+	t.Run("Atomic batches apply everything when all operations succeed", func(t *testing.T) {
+		batch, _, ctx := setupBatch(t)
+
+		results, err := batch.ApplyBatch(ctx, []domain.BatchOperation{
+			startOp("op-1", uuid.New()),
+			refOp("op-2", domain.BatchComplete, "op-1"),
+		}, true)
+		require.NoError(t, err)
+		assert.Equal(t, []domain.BatchStatus{domain.BatchApplied, domain.BatchApplied}, statuses(results))
+	})
+
+	t.Run("Rejects malformed batches as a whole", func(t *testing.T) {
+		batch, _, ctx := setupBatch(t)
+		noTimestamp := startOp("op-1", uuid.New())
+		noTimestamp.ClientTimestamp = time.Time{}
+
+		cases := []struct {
+			name  string
+			ops   []domain.BatchOperation
+			field string
+		}{
+			{"empty", nil, "operations"},
+			{"duplicate IDs", []domain.BatchOperation{startOp("op-1", uuid.New()), startOp("op-1", uuid.New())}, "operations.1.id"},
+			{"missing timestamp", []domain.BatchOperation{noTimestamp}, "operations.0.client_timestamp"},
+			{"forward reference", []domain.BatchOperation{refOp("op-1", domain.BatchComplete, "op-2"), startOp("op-2", uuid.New())}, "operations.0.ref"},
+			{"no realization", []domain.BatchOperation{{ID: "op-1", Type: domain.BatchNote, ClientTimestamp: time.Now()}}, "operations.0.realization_id"},
+			{"unknown type", []domain.BatchOperation{{ID: "op-1", Type: "pause", ClientTimestamp: time.Now()}}, "operations.0.type"},
+		}
+		for _, tc := range cases {
+			t.Run(tc.name, func(t *testing.T) {
+				_, err := batch.ApplyBatch(ctx, tc.ops, false)
+
+				var validation *domain.ValidationError
+				require.ErrorAs(t, err, &validation)
+				assert.Equal(t, tc.field, validation.Field)
+			})
+		}
+	})
+}
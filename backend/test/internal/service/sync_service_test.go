@@ -0,0 +1,100 @@
+package service_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/luisteixeira/waypoint/backend/internal/domain"
+	"github.com/luisteixeira/waypoint/backend/internal/middleware"
+	"github.com/luisteixeira/waypoint/backend/internal/repository/memory"
+	"github.com/luisteixeira/waypoint/backend/internal/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSyncService_Changes(t *testing.T) {
+	activities, definitions, tx := memory.NewInMemoryActivityRepo(), memory.NewInMemoryDefinitionRepo(), memory.NewTxManager()
+	svc := service.NewActivityService(activities, definitions, tx, service.DefaultClientTimeWindow)
+	sync := service.NewSyncService(memory.NewInMemoryChangeRepo(activities, definitions), tx)
+	ctx := context.WithValue(context.Background(), middleware.FamilyIDKey, uuid.New())
+
+	nap, err := svc.StartActivity(ctx, domain.StartActivityInput{EntityID: uuid.New(), NewDefinittionName: "Nap"})
+	require.NoError(t, err)
+
+	var cursor string
+	t.Run("Returns everything without a cursor", func(t *testing.T) {
+		page, err := sync.Changes(ctx, "", 0)
+		require.NoError(t, err)
+
+		require.Len(t, page.Definitions, 1)
+		assert.Equal(t, "Nap", page.Definitions[0].Name)
+		require.Len(t, page.Realizations, 1)
+		assert.Equal(t, nap.ID, page.Realizations[0].ID)
+		assert.Empty(t, page.Entities)
+		assert.Empty(t, page.Deleted)
+		assert.False(t, page.HasMore)
+		assert.False(t, page.Reset)
+		assert.NotEmpty(t, page.Cursor)
+		cursor = page.Cursor
+	})
+
+	t.Run("Returns only what changed after the cursor", func(t *testing.T) {
+		page, err := sync.Changes(ctx, cursor, 0)
+		require.NoError(t, err)
+		assert.Empty(t, page.Realizations)
+		assert.Equal(t, cursor, page.Cursor, "the cursor stays put when nothing changed")
+
+		completed, err := svc.CompleteActivity(ctx, nap.ID, nap.Version)
+		require.NoError(t, err)
+
+		page, err = sync.Changes(ctx, cursor, 0)
+		require.NoError(t, err)
+		assert.Empty(t, page.Definitions)
+		require.Len(t, page.Realizations, 1)
+		assert.Equal(t, completed.Version, page.Realizations[0].Version)
+		assert.NotEqual(t, cursor, page.Cursor)
+	})
+
+	t.Run("Pages through changes", func(t *testing.T) {
+		_, err := svc.StartActivity(ctx, domain.StartActivityInput{EntityID: uuid.New(), NewDefinittionName: "Bath"})
+		require.NoError(t, err)
+
+		var seen int
+		next := ""
+		for {
+			page, err := sync.Changes(ctx, next, 1)
+			require.NoError(t, err)
+			seen += len(page.Realizations) + len(page.Definitions)
+			next = page.Cursor
+			if !page.HasMore {
+				break
+			}
+		}
+		assert.Equal(t, 4, seen, "two definitions and two realizations")
+	})
+
+	t.Run("Starts over from an unknown cursor", func(t *testing.T) {
+		page, err := sync.Changes(ctx, "9223372036854775000", 0)
+		require.NoError(t, err)
+		assert.True(t, page.Reset)
+		assert.Len(t, page.Realizations, 2)
+	})
+
+	t.Run("Rejects malformed input", func(t *testing.T) {
+		var validation *domain.ValidationError
+
+		_, err := sync.Changes(ctx, "yesterday", 0)
+		require.ErrorAs(t, err, &validation)
+		assert.Equal(t, "cursor", validation.Field)
+
+		_, err = sync.Changes(ctx, "", service.MaxSyncLimit+1)
+		require.ErrorAs(t, err, &validation)
+		assert.Equal(t, "limit", validation.Field)
+	})
+
+	t.Run("Requires a family", func(t *testing.T) {
+		_, err := sync.Changes(context.Background(), "", 0)
+		assert.ErrorIs(t, err, domain.ErrUnauthorized)
+	})
+}
@@ -0,0 +1,99 @@
+package tracing_test
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+	"github.com/luisteixeira/waypoint/backend/internal/handler"
+	"github.com/luisteixeira/waypoint/backend/internal/middleware"
+	"github.com/luisteixeira/waypoint/backend/internal/repository"
+	"github.com/luisteixeira/waypoint/backend/internal/repository/sqlite"
+	"github.com/luisteixeira/waypoint/backend/internal/service"
+	"github.com/luisteixeira/waypoint/backend/internal/tracing"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func attr(span sdktrace.ReadOnlySpan, key attribute.Key) string {
+	for _, kv := range span.Attributes() {
+		if kv.Key == key {
+			return kv.Value.Emit()
+		}
+	}
+	return ""
+}
+
+// TestRequestTrace follows one request from the router through the service
+// down to the SQL queries it runs.
+func TestRequestTrace(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	db, err := sqlite.Open(filepath.Join(t.TempDir(), "waypoint.db"))
+	require.NoError(t, err)
+	t.Cleanup(func() { db.Close() })
+	require.NoError(t, sqlite.Migrate(t.Context(), db))
+
+	svc := tracing.NewActivityService(service.NewActivityService(
+		sqlite.NewSQLiteActivityRepo(db), sqlite.NewSQLiteDefinitionRepo(db), repository.NewSQLTxManager(db), service.DefaultClientTimeWindow))
+	activityHandler := handler.NewActivityHandler(svc)
+
+	router := chi.NewRouter()
+	router.Use(tracing.Middleware)
+	router.Route("/api/v1", func(r chi.Router) {
+		r.Use(middleware.TenantMiddleware)
+		r.Post("/activities/start", activityHandler.StartActivity)
+	})
+
+	familyID := uuid.New()
+	body := `{"entity_id":"` + uuid.NewString() + `","new_definition_name":"Nap"}`
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/activities/start", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Family-ID", familyID.String())
+	// The caller's trace is continued rather than a new one started.
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	rec := httptest.NewRecorder()
+	router.ServeHTTP(rec, req)
+	require.Equal(t, http.StatusCreated, rec.Code, rec.Body.String())
+
+	spans := recorder.Ended()
+	byName := map[string]sdktrace.ReadOnlySpan{}
+	var queries []sdktrace.ReadOnlySpan
+	for _, span := range spans {
+		assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", span.SpanContext().TraceID().String())
+		byName[span.Name()] = span
+		if attr(span, "db.system") != "" {
+			queries = append(queries, span)
+		}
+	}
+
+	server, ok := byName["POST /api/v1/activities/start"]
+	require.True(t, ok, "server span named after the route pattern")
+	assert.Equal(t, familyID.String(), attr(server, tracing.FamilyIDKey))
+	assert.Equal(t, "201", attr(server, "http.response.status_code"))
+
+	start, ok := byName["activityService.StartActivity"]
+	require.True(t, ok)
+	assert.Equal(t, server.SpanContext().SpanID(), start.Parent().SpanID())
+	assert.Equal(t, familyID.String(), attr(start, tracing.FamilyIDKey))
+	assert.NotEmpty(t, attr(start, tracing.RealizationIDKey))
+
+	require.NotEmpty(t, queries)
+	for _, query := range queries {
+		assert.Equal(t, "sqlite", attr(query, "db.system"))
+		assert.NotEmpty(t, attr(query, "db.query.text"))
+		assert.Equal(t, familyID.String(), attr(query, tracing.FamilyIDKey))
+		assert.Equal(t, start.SpanContext().SpanID(), query.Parent().SpanID(), "query %s", query.Name())
+	}
+}
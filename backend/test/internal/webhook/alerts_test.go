@@ -0,0 +1,52 @@
+package webhook_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/luisteixeira/waypoint/backend/internal/alert"
+	"github.com/luisteixeira/waypoint/backend/internal/webhook"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAlertNotifier(t *testing.T) {
+	f := setup(t)
+	sub := f.subscribe(t, "https://example.com/alerts", webhook.EventAlertTriggered)
+	other := f.subscribe(t, "https://example.com/started", webhook.EventActivityStarted)
+
+	now := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	n := webhook.NewAlertNotifier(f.store, func() time.Time { return now })
+	assert.Equal(t, alert.ChannelWebhook, n.Channel())
+
+	realizationID := uuid.New()
+	a := alert.Alert{
+		ID:            uuid.New(),
+		RuleID:        uuid.New(),
+		Kind:          alert.KindRunningLong,
+		RealizationID: &realizationID,
+		Message:       "Nap has been going on for 3h",
+		Status:        alert.StatusOpen,
+		TriggeredAt:   now,
+	}
+	require.NoError(t, n.Notify(f.ctx, alert.Rule{ID: a.RuleID}, a))
+	require.NoError(t, n.Notify(f.ctx, alert.Rule{ID: a.RuleID}, a))
+
+	deliveries := f.deliveries(t, sub)
+	require.Len(t, deliveries, 2, "an alert raised again is delivered again")
+	assert.NotEqual(t, deliveries[0].EventID, deliveries[1].EventID)
+	assert.Equal(t, webhook.EventAlertTriggered, deliveries[0].EventType)
+	assert.Empty(t, f.deliveries(t, other))
+
+	var sent webhook.Event
+	require.NoError(t, json.Unmarshal(deliveries[0].Payload, &sent))
+	assert.Equal(t, webhook.EventAlertTriggered, sent.Type)
+	assert.True(t, now.Equal(sent.OccurredAt), sent.OccurredAt)
+	assert.Nil(t, sent.Realization)
+	require.NotNil(t, sent.Alert)
+	assert.Equal(t, a.ID, sent.Alert.ID)
+	assert.Equal(t, a.Message, sent.Alert.Message)
+	assert.Equal(t, realizationID, *sent.Alert.RealizationID)
+}
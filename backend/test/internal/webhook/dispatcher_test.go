@@ -0,0 +1,327 @@
+package webhook_test
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/luisteixeira/waypoint/backend/internal/domain"
+	"github.com/luisteixeira/waypoint/backend/internal/middleware"
+	"github.com/luisteixeira/waypoint/backend/internal/repository/memory"
+	"github.com/luisteixeira/waypoint/backend/internal/webhook"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// receiver stands in for a family's webhook endpoint. It records what it is
+// sent and answers with the statuses queued in replies, then 204.
+type receiver struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	requests []received
+	replies  []int
+}
+
+type received struct {
+	header http.Header
+	body   []byte
+}
+
+func newReceiver(t *testing.T, replies ...int) *receiver {
+	rcv := &receiver{replies: replies}
+	rcv.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		rcv.mu.Lock()
+		defer rcv.mu.Unlock()
+		rcv.requests = append(rcv.requests, received{header: r.Header.Clone(), body: body})
+		status := http.StatusNoContent
+		if len(rcv.replies) > 0 {
+			status, rcv.replies = rcv.replies[0], rcv.replies[1:]
+		}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(rcv.Close)
+	return rcv
+}
+
+func (r *receiver) received() []received {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]received(nil), r.requests...)
+}
+
+// clock is a settable time for the dispatcher.
+type clock struct {
+	mu  sync.Mutex
+	now time.Time
+}
+
+func (c *clock) Now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.now
+}
+
+func (c *clock) Advance(d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.now = c.now.Add(d)
+}
+
+var policy = webhook.Policy{
+	Timeout:        time.Second,
+	MaxAttempts:    3,
+	InitialBackoff: time.Minute,
+	MaxBackoff:     90 * time.Second,
+}
+
+type fixture struct {
+	ctx        context.Context
+	store      *memory.InMemoryWebhookStore
+	registry   *webhook.Registry
+	dispatcher *webhook.Dispatcher
+	clock      *clock
+}
+
+func setup(t *testing.T) fixture {
+	store := memory.NewInMemoryWebhookStore()
+	c := &clock{now: time.Now().Add(time.Second)}
+	dispatcher := webhook.NewDispatcher(store, policy, c.Now)
+
+	return fixture{
+		ctx:        context.WithValue(context.Background(), middleware.FamilyIDKey, uuid.New()),
+		store:      store,
+		registry:   webhook.NewRegistry(store),
+		dispatcher: dispatcher,
+		clock:      c,
+	}
+}
+
+func (f fixture) subscribe(t *testing.T, url string, events ...webhook.EventType) *webhook.Subscription {
+	t.Helper()
+	sub, err := f.registry.Subscribe(f.ctx, url, events, "")
+	require.NoError(t, err)
+	return sub
+}
+
+func (f fixture) publish(t *testing.T, eventType webhook.EventType) webhook.Event {
+	t.Helper()
+	event := webhook.Event{
+		ID:          uuid.New(),
+		Type:        eventType,
+		OccurredAt:  time.Now().UTC(),
+		Realization: &domain.ActivityRealization{ID: uuid.New(), Status: domain.StatusInProgress, Version: 1},
+	}
+	_, err := f.store.Enqueue(f.ctx, event)
+	require.NoError(t, err)
+	return event
+}
+
+func (f fixture) dispatch(t *testing.T) int {
+	t.Helper()
+	delivered, err := f.dispatcher.Dispatch(context.Background())
+	require.NoError(t, err)
+	return delivered
+}
+
+func (f fixture) deliveries(t *testing.T, sub *webhook.Subscription) []webhook.Delivery {
+	t.Helper()
+	deliveries, err := f.registry.Deliveries(f.ctx, sub.ID, 0)
+	require.NoError(t, err)
+	return deliveries
+}
+
+func TestDispatch(t *testing.T) {
+	t.Run("Sends signed deliveries", func(t *testing.T) {
+		f := setup(t)
+		rcv := newReceiver(t)
+		sub := f.subscribe(t, rcv.URL, webhook.EventActivityStarted)
+		event := f.publish(t, webhook.EventActivityStarted)
+
+		assert.Equal(t, 1, f.dispatch(t))
+
+		requests := rcv.received()
+		require.Len(t, requests, 1)
+		req := requests[0]
+		assert.Equal(t, "application/json", req.header.Get("Content-Type"))
+		assert.Equal(t, "activity.started", req.header.Get(webhook.EventHeader))
+		require.NoError(t, webhook.Verify(sub.Secret, req.header.Get(webhook.SignatureHeader), req.body, f.clock.Now(), time.Minute))
+		assert.Error(t, webhook.Verify("whsec_other", req.header.Get(webhook.SignatureHeader), req.body, f.clock.Now(), time.Minute))
+
+		var body webhook.Event
+		require.NoError(t, json.Unmarshal(req.body, &body))
+		assert.Equal(t, event.ID, body.ID)
+		assert.Equal(t, webhook.EventActivityStarted, body.Type)
+		assert.Equal(t, event.Realization.ID, body.Realization.ID)
+
+		deliveries := f.deliveries(t, sub)
+		require.Len(t, deliveries, 1)
+		assert.Equal(t, deliveries[0].ID.String(), req.header.Get(webhook.DeliveryHeader))
+		assert.Equal(t, webhook.DeliveryDelivered, deliveries[0].Status)
+		assert.Equal(t, http.StatusNoContent, deliveries[0].ResponseStatus)
+
+		assert.Zero(t, f.dispatch(t), "a delivered event is not sent again")
+		assert.Len(t, rcv.received(), 1)
+	})
+
+	t.Run("Only sends the events a subscription wants", func(t *testing.T) {
+		f := setup(t)
+		completed := newReceiver(t)
+		cancelled := newReceiver(t)
+		f.subscribe(t, completed.URL, webhook.EventActivityCompleted)
+		f.subscribe(t, cancelled.URL, webhook.EventActivityCancelled)
+
+		f.publish(t, webhook.EventActivityCompleted)
+		f.publish(t, webhook.EventActivityPlanned)
+
+		assert.Equal(t, 1, f.dispatch(t))
+		assert.Len(t, completed.received(), 1)
+		assert.Empty(t, cancelled.received())
+	})
+
+	t.Run("Retries with exponential backoff", func(t *testing.T) {
+		f := setup(t)
+		rcv := newReceiver(t, http.StatusInternalServerError, http.StatusServiceUnavailable)
+		sub := f.subscribe(t, rcv.URL, webhook.EventActivityStarted)
+		f.publish(t, webhook.EventActivityStarted)
+
+		assert.Zero(t, f.dispatch(t))
+		d := f.deliveries(t, sub)[0]
+		assert.Equal(t, webhook.DeliveryPending, d.Status)
+		assert.Equal(t, 1, d.Attempts)
+		assert.Equal(t, http.StatusInternalServerError, d.ResponseStatus)
+		assert.Contains(t, d.LastError, "500")
+		assert.Equal(t, f.clock.Now().Add(time.Minute), d.NextAttemptAt)
+
+		f.clock.Advance(59 * time.Second)
+		f.dispatch(t)
+		assert.Len(t, rcv.received(), 1, "not retried before the backoff")
+
+		f.clock.Advance(time.Second)
+		assert.Zero(t, f.dispatch(t))
+		d = f.deliveries(t, sub)[0]
+		assert.Equal(t, 2, d.Attempts)
+		assert.Equal(t, f.clock.Now().Add(90*time.Second), d.NextAttemptAt, "the backoff doubles up to the maximum")
+
+		f.clock.Advance(90 * time.Second)
+		assert.Equal(t, 1, f.dispatch(t))
+		d = f.deliveries(t, sub)[0]
+		assert.Equal(t, webhook.DeliveryDelivered, d.Status)
+		assert.Equal(t, 3, d.Attempts)
+		assert.Len(t, rcv.received(), 3)
+	})
+
+	t.Run("Gives up after the last attempt", func(t *testing.T) {
+		f := setup(t)
+		rcv := newReceiver(t, http.StatusBadRequest, http.StatusBadRequest, http.StatusBadRequest, http.StatusBadRequest)
+		sub := f.subscribe(t, rcv.URL, webhook.EventActivityCancelled)
+		f.publish(t, webhook.EventActivityCancelled)
+
+		for range policy.MaxAttempts {
+			f.dispatch(t)
+			f.clock.Advance(time.Hour)
+		}
+		f.dispatch(t)
+
+		assert.Len(t, rcv.received(), policy.MaxAttempts)
+		d := f.deliveries(t, sub)[0]
+		assert.Equal(t, webhook.DeliveryFailed, d.Status)
+		assert.Equal(t, policy.MaxAttempts, d.Attempts)
+	})
+
+	t.Run("Records unreachable receivers", func(t *testing.T) {
+		f := setup(t)
+		rcv := newReceiver(t)
+		rcv.Close()
+		sub := f.subscribe(t, rcv.URL, webhook.EventActivityStarted)
+		f.publish(t, webhook.EventActivityStarted)
+
+		assert.Zero(t, f.dispatch(t))
+		d := f.deliveries(t, sub)[0]
+		assert.Equal(t, webhook.DeliveryPending, d.Status)
+		assert.Zero(t, d.ResponseStatus)
+		assert.NotEmpty(t, d.LastError)
+	})
+
+	t.Run("Redelivers a failed event", func(t *testing.T) {
+		f := setup(t)
+		rcv := newReceiver(t, http.StatusGone, http.StatusGone, http.StatusGone)
+		sub := f.subscribe(t, rcv.URL, webhook.EventActivityCompleted)
+		event := f.publish(t, webhook.EventActivityCompleted)
+		for range policy.MaxAttempts {
+			f.dispatch(t)
+			f.clock.Advance(time.Hour)
+		}
+		failed := f.deliveries(t, sub)[0]
+		require.Equal(t, webhook.DeliveryFailed, failed.Status)
+
+		again, err := f.registry.Redeliver(f.ctx, sub.ID, failed.ID)
+		require.NoError(t, err)
+		assert.Equal(t, 1, f.dispatch(t))
+
+		requests := rcv.received()
+		last := requests[len(requests)-1]
+		assert.Equal(t, again.ID.String(), last.header.Get(webhook.DeliveryHeader))
+		var body webhook.Event
+		require.NoError(t, json.Unmarshal(last.body, &body))
+		assert.Equal(t, event.ID, body.ID, "receivers can tell it is the same event")
+	})
+}
+
+func TestPolicyBackoff(t *testing.T) {
+	p := webhook.Policy{InitialBackoff: 30 * time.Second, MaxBackoff: time.Hour}
+	assert.Equal(t, 30*time.Second, p.Backoff(1))
+	assert.Equal(t, time.Minute, p.Backoff(2))
+	assert.Equal(t, 4*time.Minute, p.Backoff(4))
+	assert.Equal(t, time.Hour, p.Backoff(20))
+}
+
+func TestVerify(t *testing.T) {
+	body := []byte(`{"id":"1"}`)
+	at := time.Unix(1_700_000_000, 0)
+	header := webhook.Sign("whsec_test", at, body)
+
+	assert.NoError(t, webhook.Verify("whsec_test", header, body, at.Add(time.Minute), 5*time.Minute))
+	assert.Error(t, webhook.Verify("whsec_test", header, []byte(`{"id":"2"}`), at, 5*time.Minute), "the body was changed")
+	assert.Error(t, webhook.Verify("whsec_test", header, body, at.Add(time.Hour), 5*time.Minute), "the signature is too old")
+	assert.Error(t, webhook.Verify("whsec_test", "v1=abc", body, at, 5*time.Minute), "no timestamp")
+}
+
+func TestRegistry(t *testing.T) {
+	f := setup(t)
+
+	t.Run("Returns a signing secret", func(t *testing.T) {
+		sub, err := f.registry.Subscribe(f.ctx, "https://example.com/hook", []webhook.EventType{webhook.EventActivityStarted, webhook.EventActivityStarted}, "home automation")
+		require.NoError(t, err)
+		assert.Regexp(t, `^whsec_[0-9a-f]{64}$`, sub.Secret)
+		assert.Equal(t, []webhook.EventType{webhook.EventActivityStarted}, sub.Events, "repeated events are dropped")
+	})
+
+	tests := []struct {
+		name   string
+		url    string
+		events []webhook.EventType
+		field  string
+	}{
+		{"Relative URL", "/hook", []webhook.EventType{webhook.EventActivityStarted}, "url"},
+		{"Other scheme", "ftp://example.com/hook", []webhook.EventType{webhook.EventActivityStarted}, "url"},
+		{"No events", "https://example.com/hook", nil, "events"},
+		{"Unknown event", "https://example.com/hook", []webhook.EventType{"activity.paused"}, "events"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := f.registry.Subscribe(f.ctx, tt.url, tt.events, "")
+			var verr *domain.ValidationError
+			require.ErrorAs(t, err, &verr)
+			assert.Equal(t, tt.field, verr.Field)
+		})
+	}
+}
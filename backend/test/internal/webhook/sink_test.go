@@ -0,0 +1,64 @@
+package webhook_test
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/luisteixeira/waypoint/backend/internal/domain"
+	"github.com/luisteixeira/waypoint/backend/internal/outbox"
+	"github.com/luisteixeira/waypoint/backend/internal/webhook"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSink(t *testing.T) {
+	event := func(t outbox.EventType) outbox.Event {
+		return outbox.Event{
+			ID:          uuid.New(),
+			Type:        t,
+			OccurredAt:  time.Now().UTC().Truncate(time.Second),
+			Realization: &domain.ActivityRealization{ID: uuid.New(), Status: domain.StatusCompleted, Version: 2},
+		}
+	}
+
+	t.Run("Queues a delivery with the outbox event", func(t *testing.T) {
+		f := setup(t)
+		sub := f.subscribe(t, "https://example.com/hook", webhook.EventActivityCompleted)
+		published := event(outbox.EventActivityCompleted)
+
+		require.NoError(t, webhook.NewSink(f.store).Publish(f.ctx, published))
+
+		deliveries := f.deliveries(t, sub)
+		require.Len(t, deliveries, 1)
+		assert.Equal(t, published.ID, deliveries[0].EventID)
+		assert.Equal(t, webhook.EventActivityCompleted, deliveries[0].EventType)
+
+		var sent webhook.Event
+		require.NoError(t, json.Unmarshal(deliveries[0].Payload, &sent))
+		assert.Equal(t, published.ID, sent.ID)
+		assert.Equal(t, published.Realization.ID, sent.Realization.ID)
+		assert.True(t, published.OccurredAt.Equal(sent.OccurredAt), sent.OccurredAt)
+	})
+
+	t.Run("Skips events subscriptions cannot ask for", func(t *testing.T) {
+		f := setup(t)
+		sub := f.subscribe(t, "https://example.com/hook", webhook.EventTypes...)
+		sink := webhook.NewSink(f.store)
+
+		require.NoError(t, sink.Publish(f.ctx, event(outbox.EventActivityPaused)))
+		require.NoError(t, sink.Publish(f.ctx, event(outbox.EventActivityNoted)))
+		assert.Empty(t, f.deliveries(t, sub))
+	})
+
+	t.Run("Only queues for subscriptions that want the event", func(t *testing.T) {
+		f := setup(t)
+		started := f.subscribe(t, "https://example.com/started", webhook.EventActivityStarted)
+		completed := f.subscribe(t, "https://example.com/completed", webhook.EventActivityCompleted)
+
+		require.NoError(t, webhook.NewSink(f.store).Publish(f.ctx, event(outbox.EventActivityStarted)))
+		assert.Len(t, f.deliveries(t, started), 1)
+		assert.Empty(t, f.deliveries(t, completed))
+	})
+}
@@ -0,0 +1,107 @@
+package worker_test
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/luisteixeira/waypoint/backend/internal/worker"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGroup(t *testing.T) {
+	t.Run("Runs jobs until stopped", func(t *testing.T) {
+		g := worker.NewGroup()
+		var runs atomic.Int32
+		g.Every("tick", time.Millisecond, func(ctx context.Context) error {
+			runs.Add(1)
+			return nil
+		})
+
+		require.Eventually(t, func() bool { return runs.Load() >= 3 }, time.Second, time.Millisecond)
+		require.NoError(t, g.Check(context.Background()))
+		require.NoError(t, g.Stop(context.Background()))
+
+		stopped := runs.Load()
+		time.Sleep(10 * time.Millisecond)
+		assert.Equal(t, stopped, runs.Load(), "no runs after Stop")
+	})
+
+	t.Run("A failing job is unhealthy until it recovers", func(t *testing.T) {
+		g := worker.NewGroup()
+		defer g.Stop(context.Background())
+
+		var failing atomic.Bool
+		failing.Store(true)
+		g.Every("dispatch", time.Millisecond, func(ctx context.Context) error {
+			if failing.Load() {
+				return errors.New("broker unreachable")
+			}
+			return nil
+		})
+
+		require.Eventually(t, func() bool { return g.Check(context.Background()) != nil }, time.Second, time.Millisecond)
+		assert.ErrorContains(t, g.Check(context.Background()), "dispatch: failed 3 runs in a row: broker unreachable")
+
+		failing.Store(false)
+		require.Eventually(t, func() bool { return g.Check(context.Background()) == nil }, time.Second, time.Millisecond)
+	})
+
+	t.Run("A job that fails once stays healthy", func(t *testing.T) {
+		g := worker.NewGroup()
+		defer g.Stop(context.Background())
+
+		var runs atomic.Int32
+		g.Every("dispatch", time.Hour, func(ctx context.Context) error {
+			runs.Add(1)
+			return errors.New("broker unreachable")
+		})
+
+		require.Eventually(t, func() bool { return runs.Load() == 1 }, time.Second, time.Millisecond)
+		assert.NoError(t, g.Check(context.Background()))
+	})
+
+	t.Run("Housekeeping jobs do not affect health", func(t *testing.T) {
+		g := worker.NewGroup()
+		defer g.Stop(context.Background())
+
+		var runs atomic.Int32
+		g.Housekeeping("cleanup", time.Millisecond, func(ctx context.Context) error {
+			runs.Add(1)
+			return errors.New("disk full")
+		})
+
+		require.Eventually(t, func() bool { return runs.Load() > 5 }, time.Second, time.Millisecond)
+		assert.NoError(t, g.Check(context.Background()))
+	})
+
+	t.Run("A stuck job is unhealthy", func(t *testing.T) {
+		g := worker.NewGroup()
+		defer g.Stop(context.Background())
+
+		g.Every("stuck", time.Millisecond, func(ctx context.Context) error {
+			<-ctx.Done()
+			return ctx.Err()
+		})
+
+		require.Eventually(t, func() bool { return g.Check(context.Background()) != nil }, time.Second, time.Millisecond)
+		assert.ErrorContains(t, g.Check(context.Background()), "stuck: no run finished")
+	})
+
+	t.Run("Stop gives up when jobs ignore cancellation", func(t *testing.T) {
+		g := worker.NewGroup()
+		release := make(chan struct{})
+		defer close(release)
+		g.Every("stubborn", time.Hour, func(ctx context.Context) error {
+			<-release
+			return nil
+		})
+
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+		assert.ErrorIs(t, g.Stop(ctx), context.DeadlineExceeded)
+	})
+}